@@ -0,0 +1,203 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package adb implements the host side of the Android Debug Bridge protocol
+over USB on top of libusb, so that tools can talk to Android devices
+without the adb server.
+
+An ADB interface is a vendor-specific interface with subclass 0x42 and
+protocol 1, whose bulk endpoint pair carries ADB messages: a 24 byte
+header followed by an optional payload. FindInterfaces returns such
+interfaces and Open claims one.
+
+Connect exchanges CNXN messages with the device. A device that requires
+authentication sends an AUTH token, which Connect signs with each of the
+given RSA keys in turn; when the device accepts none of them, Connect
+sends the first key's public half for the user to accept on the device.
+ParsePrivateKey reads the keys adb keeps in ~/.android/adbkey, and
+AndroidPublicKey encodes a public key the way adb does.
+
+Once connected, OpenStream opens a stream to a service on the device with
+OPEN, and the device's OKAY, WRTE and CLSE messages are multiplexed onto
+the open streams by a background reader. A Stream is an io.ReadWriteCloser
+that acknowledges each WRTE as its data is read, so a slow reader holds
+back only its own stream. Shell and ShellOutput run commands through the
+shell: service, and Sync opens the sync: service for Stat, List, Pull
+and Push.
+*/
+package adb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the timeout in milliseconds used for transfers, and
+// for the device to answer OPEN and WRTE messages, unless Device.Timeout
+// is changed.
+const DefaultTimeout = 5000
+
+// Interface subclass and protocol of ADB interfaces.
+const (
+	SubclassADB = 0x42
+	ProtocolADB = 0x01
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: claiming the
+// ADB interface and exchanging messages over its two bulk endpoints.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+}
+
+// Interface is the ADB interface of a device and its endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	BulkIn     *libusb.EndpointDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+}
+
+// FindInterfaces returns the ADB interfaces in a configuration.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("adb: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassVendorSpec,
+	) {
+		if desc.InterfaceSubClass != SubclassADB || desc.InterfaceProtocol != ProtocolADB ||
+			desc.AlternateSetting != 0 {
+			continue
+		}
+		iface := &Interface{Descriptor: desc}
+		for _, ep := range desc.EndpointDescriptors {
+			if ep.TransferType() != libusb.BulkTransfer {
+				continue
+			}
+			if ep.Direction() == libusb.EndpointIn && iface.BulkIn == nil {
+				iface.BulkIn = ep
+			} else if ep.Direction() == libusb.EndpointOut && iface.BulkOut == nil {
+				iface.BulkOut = ep
+			}
+		}
+		if iface.BulkIn == nil || iface.BulkOut == nil {
+			return nil, fmt.Errorf(
+				"adb: interface %d has no bulk endpoint pair",
+				desc.InterfaceNumber,
+			)
+		}
+		found = append(found, iface)
+	}
+	return found, nil
+}
+
+// Device is a claimed ADB interface. Its methods may be called
+// concurrently.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds, and how long the
+	// device has to answer OPEN and WRTE messages. Zero waits forever.
+	Timeout int
+	// KeyName is the name the device shows next to a public key Connect
+	// sends it. It defaults to user@host.
+	KeyName string
+
+	writeMu sync.Mutex
+
+	// version, maxPayload and banner are set by Connect before the
+	// reader starts.
+	version    uint32
+	maxPayload int
+	banner     string
+
+	streamMu sync.Mutex
+	streams  map[uint32]*Stream
+	lastID   uint32
+
+	readers  sync.WaitGroup
+	done     chan struct{}
+	failOnce sync.Once
+	failed   chan struct{}
+	readErr  error
+
+	mu        sync.Mutex
+	closed    bool
+	connected bool
+	claims    *usbif.Claims
+}
+
+// Open claims an ADB interface. A running adb server holds the interface
+// too, so it has to be stopped first. Connect must be called before
+// streams are opened.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("adb: nil handle or interface")
+	}
+	dev := &Device{
+		handle:    handle,
+		Interface: iface,
+		Timeout:   DefaultTimeout,
+		streams:   make(map[uint32]*Stream),
+		done:      make(chan struct{}),
+		failed:    make(chan struct{}),
+	}
+	claims, err := usbif.Claim(handle, "adb", iface.Descriptor.InterfaceNumber)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	return dev, nil
+}
+
+// Close closes the open streams without sending CLSE for them, stops the
+// background reader, and releases the interface. The device's adbd sees
+// the transport go away and drops the connection. Calling Close again
+// returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	close(dev.done)
+	dev.mu.Unlock()
+	dev.readers.Wait()
+	dev.closeStreams(os.ErrClosed)
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
+
+// usable returns the error operations on the device fail with, or nil if
+// it's connected and working.
+func (dev *Device) usable() error {
+	dev.mu.Lock()
+	closed, connected := dev.closed, dev.connected
+	dev.mu.Unlock()
+	switch {
+	case closed:
+		return os.ErrClosed
+	case !connected:
+		return fmt.Errorf("adb: device isn't connected")
+	}
+	select {
+	case <-dev.failed:
+		return dev.readErr
+	default:
+		return nil
+	}
+}
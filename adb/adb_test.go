@@ -0,0 +1,384 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+const testBanner = "device::ro.product.name=sunfish;ro.product.model=Pixel 4a;" +
+	"features=shell_v2,cmd"
+
+// fakeHandle is an adbd: it parses the messages the host writes and
+// queues its answers for the host to read, the header and the payload of
+// each in separate transfers.
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+
+	// trusted is the key whose signatures adbd accepts; when nil, adbd
+	// doesn't ask for authentication.
+	trusted *rsa.PublicKey
+	// token is the last AUTH token adbd sent, and publicKey the payload
+	// of the AUTH public key message.
+	token     []byte
+	publicKey []byte
+	// maxPayload is the maximum payload adbd offers in its CNXN.
+	maxPayload uint32
+
+	// header is the header of a message whose payload is still being
+	// written, and payload what's been written of it.
+	header  *message
+	length  int
+	payload []byte
+
+	// writes counts the host's transfers, sent collects the messages the
+	// host sent, and replies are the transfers the host is yet to read.
+	writes  int
+	sent    []message
+	replies [][]byte
+
+	lastID   uint32
+	services map[uint32]*fakeService
+	files    map[string][]byte
+}
+
+// fakeService is a stream adbd has open, keyed by its local ID.
+type fakeService struct {
+	name   string
+	remote uint32
+	// onWrite handles the data the host writes.
+	onWrite func(fs *fakeService, data []byte)
+	// onOkay, if set, runs when the host acknowledges a write.
+	onOkay func(fs *fakeService)
+	buf    []byte
+	// file is the file a sync SEND is writing.
+	file []byte
+}
+
+func newFakeHandle() *fakeHandle {
+	return &fakeHandle{
+		maxPayload: 4096,
+		services:   make(map[uint32]*fakeService),
+		files:      make(map[string][]byte),
+	}
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		fh.writes++
+		fh.hostWrite(data[:length])
+		return length, nil
+	}
+	if len(fh.replies) == 0 {
+		fh.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		fh.mu.Lock()
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.replies[0]
+	n := copy(data[:length], p)
+	if n < len(p) {
+		fh.replies[0] = p[n:]
+	} else {
+		fh.replies = fh.replies[1:]
+	}
+	return n, nil
+}
+
+// hostWrite takes a transfer from the host: a header, or some of a
+// payload. Zero-length packets are ignored.
+func (fh *fakeHandle) hostWrite(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	if fh.header == nil {
+		m, length, _, err := parseHeader(b)
+		if err != nil {
+			panic(err)
+		}
+		if length == 0 {
+			fh.handle(m)
+			return
+		}
+		fh.header, fh.length = &m, length
+		return
+	}
+	fh.payload = append(fh.payload, b...)
+	if len(fh.payload) >= fh.length {
+		m := *fh.header
+		m.data = fh.payload
+		fh.header, fh.payload = nil, nil
+		fh.handle(m)
+	}
+}
+
+// queue queues a message for the host to read.
+func (fh *fakeHandle) queue(command, arg0, arg1 uint32, data []byte) {
+	m := message{command: command, arg0: arg0, arg1: arg1, data: data}
+	fh.replies = append(fh.replies, m.marshalHeader())
+	if len(data) > 0 {
+		fh.replies = append(fh.replies, data)
+	}
+}
+
+func (fh *fakeHandle) connected() {
+	fh.queue(cmdCNXN, versionSkipChecksum, fh.maxPayload, []byte(testBanner))
+}
+
+func (fh *fakeHandle) challenge() {
+	fh.token = make([]byte, 20)
+	for i := range fh.token {
+		fh.token[i] = byte(len(fh.sent) + i)
+	}
+	fh.queue(cmdAUTH, authToken, 0, fh.token)
+}
+
+func (fh *fakeHandle) handle(m message) {
+	fh.sent = append(fh.sent, m)
+	switch m.command {
+	case cmdCNXN:
+		if fh.trusted == nil {
+			fh.connected()
+		} else {
+			fh.challenge()
+		}
+	case cmdAUTH:
+		switch m.arg0 {
+		case authSignature:
+			if rsa.VerifyPKCS1v15(fh.trusted, crypto.SHA1, fh.token, m.data) == nil {
+				fh.connected()
+			} else {
+				fh.challenge()
+			}
+		case authRSAPublicKey:
+			// The user accepts the key.
+			fh.publicKey = m.data
+			fh.connected()
+		}
+	case cmdOPEN:
+		fh.open(m.arg0, string(m.data[:len(m.data)-1]))
+	case cmdWRTE:
+		if fs := fh.services[m.arg1]; fs != nil {
+			fh.queue(cmdOKAY, m.arg1, fs.remote, nil)
+			fs.onWrite(fs, m.data)
+		}
+	case cmdOKAY:
+		if fs := fh.services[m.arg1]; fs != nil && fs.onOkay != nil {
+			fs.onOkay(fs)
+		}
+	case cmdCLSE:
+		delete(fh.services, m.arg1)
+	}
+}
+
+// open opens a service for a stream the host opened.
+func (fh *fakeHandle) open(remote uint32, name string) {
+	fs := &fakeService{name: name, remote: remote}
+	switch name {
+	case "shell:echo hi":
+		fs.onOkay = func(fs *fakeService) { fh.closeService(fs) }
+	case "shell:cat":
+		fs.onWrite = func(fs *fakeService, data []byte) { fh.write(fs, data) }
+	case "sync:":
+		fs.onWrite = fh.syncWrite
+	case "shell:hang":
+		fs.onWrite = func(*fakeService, []byte) {}
+	default:
+		fh.queue(cmdCLSE, 0, remote, nil)
+		return
+	}
+	fh.lastID++
+	fh.services[fh.lastID] = fs
+	fh.queue(cmdOKAY, fh.lastID, remote, nil)
+	if name == "shell:echo hi" {
+		fh.write(fs, []byte("hi\n"))
+	}
+}
+
+// localID returns the ID adbd gave a service.
+func (fh *fakeHandle) localID(fs *fakeService) uint32 {
+	for id, s := range fh.services {
+		if s == fs {
+			return id
+		}
+	}
+	return 0
+}
+
+func (fh *fakeHandle) write(fs *fakeService, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), int(fh.maxPayload))
+		fh.queue(cmdWRTE, fh.localID(fs), fs.remote, data[:n])
+		data = data[n:]
+	}
+}
+
+func (fh *fakeHandle) closeService(fs *fakeService) {
+	fh.queue(cmdCLSE, fh.localID(fs), fs.remote, nil)
+	delete(fh.services, fh.localID(fs))
+}
+
+// sentCommands returns the names of the commands the host sent.
+func (fh *fakeHandle) sentCommands() []string {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	var names []string
+	for _, m := range fh.sent {
+		names = append(names, commandName(m.command))
+	}
+	return names
+}
+
+// testConfig returns a configuration with a vendor-specific interface 0
+// that isn't ADB's, and the ADB interface 1 with the bulk endpoints 0x81
+// and 0x01.
+func testConfig() *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: 0xFF,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:   1,
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: SubclassADB,
+				InterfaceProtocol: ProtocolADB,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	dev.Timeout = 1000
+	return dev
+}
+
+// connectTestDevice opens a device and connects to it without
+// authentication.
+func connectTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	dev := openTestDevice(t, fh)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := dev.Connect(ctx); err != nil {
+		dev.Close()
+		t.Fatalf("Connect: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.Descriptor.InterfaceNumber != 1 || iface.BulkIn.EndpointAddress != 0x81 ||
+		iface.BulkOut.EndpointAddress != 0x01 {
+		t.Errorf("interface = %+v", iface)
+	}
+
+	config := testConfig()
+	config.SupportedInterfaces[1].InterfaceDescriptors[0].EndpointDescriptors =
+		config.SupportedInterfaces[1].InterfaceDescriptors[0].EndpointDescriptors[:1]
+	if _, err := FindInterfaces(config); err == nil {
+		t.Error("interface without bulk OUT endpoint: expected error, got nil")
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := newFakeHandle()
+	fh.Active = true
+	dev := openTestDevice(t, fh)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 1", "claim 1", "release 1", "attach 1"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.OpenStream("shell:"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("OpenStream after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestNotConnected(t *testing.T) {
+	dev := openTestDevice(t, newFakeHandle())
+	defer dev.Close()
+	if _, err := dev.OpenStream("shell:"); err == nil {
+		t.Error("OpenStream before Connect: expected error, got nil")
+	}
+}
+
+func TestReaderFailure(t *testing.T) {
+	fh := newFakeHandle()
+	dev := connectTestDevice(t, fh)
+	defer dev.Close()
+	s, err := dev.Shell("cat")
+	if err != nil {
+		t.Fatalf("Shell: unexpected error %v", err)
+	}
+	// A message with a bad magic stops the reader.
+	fh.mu.Lock()
+	bad := (&message{command: cmdWRTE}).marshalHeader()
+	binary.LittleEndian.PutUint32(bad[20:], 0)
+	fh.replies = append(fh.replies, bad)
+	fh.mu.Unlock()
+
+	if _, err := s.Read(make([]byte, 8)); err == nil || errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after reader failure: got %v, want the reader's error", err)
+	}
+	if _, err := dev.OpenStream("shell:"); err == nil {
+		t.Error("OpenStream after reader failure: expected error, got nil")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// AUTH message types, in arg0.
+const (
+	authToken        = 1
+	authSignature    = 2
+	authRSAPublicKey = 3
+)
+
+const (
+	// readPoll is the timeout in milliseconds of each read Connect and
+	// the background reader make, and so the longest Close waits for
+	// them to notice.
+	readPoll = 250
+	// keyBits is the size of the RSA keys adbd accepts.
+	keyBits = 2048
+)
+
+// Connect connects to the device: it sends CNXN, answers the device's
+// AUTH tokens with signatures made with each of keys in turn, and once
+// none is left sends the public half of the first key and waits for the
+// user to accept it on the device. It returns once the device answers
+// with its own CNXN, or when ctx is done. Connect must not be called
+// concurrently with itself.
+func (dev *Device) Connect(ctx context.Context, keys ...*rsa.PrivateKey) error {
+	dev.mu.Lock()
+	closed, connected := dev.closed, dev.connected
+	dev.mu.Unlock()
+	switch {
+	case closed:
+		return os.ErrClosed
+	case connected:
+		return fmt.Errorf("adb: device is already connected")
+	}
+	err := dev.send(message{
+		command: cmdCNXN,
+		arg0:    versionSkipChecksum,
+		arg1:    maxPayload,
+		data:    []byte("host::\x00"),
+	})
+	if err != nil {
+		return err
+	}
+	var signed int
+	var sentKey bool
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("adb: connecting: %w", err)
+		}
+		if dev.isClosed() {
+			return os.ErrClosed
+		}
+		m, err := dev.receive(readPoll)
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		switch m.command {
+		case cmdCNXN:
+			return dev.connect(m)
+		case cmdAUTH:
+			if m.arg0 != authToken {
+				return fmt.Errorf("adb: unexpected AUTH message type %d", m.arg0)
+			}
+			switch {
+			case len(keys) == 0:
+				return fmt.Errorf("adb: device requires authentication, and no keys were given")
+			case signed < len(keys):
+				sig, err := rsa.SignPKCS1v15(rand.Reader, keys[signed], crypto.SHA1, m.data)
+				if err != nil {
+					return fmt.Errorf("adb: signing AUTH token: %w", err)
+				}
+				signed++
+				err = dev.send(message{command: cmdAUTH, arg0: authSignature, data: sig})
+				if err != nil {
+					return err
+				}
+			case sentKey:
+				return fmt.Errorf("adb: device rejected the public key")
+			default:
+				pub, err := AndroidPublicKey(&keys[0].PublicKey, dev.keyName())
+				if err != nil {
+					return err
+				}
+				sentKey = true
+				err = dev.send(message{
+					command: cmdAUTH,
+					arg0:    authRSAPublicKey,
+					data:    append(pub, 0),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		// Anything else is left over from an earlier connection.
+	}
+}
+
+// connect records the device's CNXN message and starts the reader.
+func (dev *Device) connect(m message) error {
+	if m.arg1 == 0 {
+		return fmt.Errorf("adb: device offered a maximum payload of 0")
+	}
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	dev.version = m.arg0
+	dev.maxPayload = min(int(m.arg1), maxPayload)
+	dev.banner = strings.TrimRight(string(m.data), "\x00")
+	dev.connected = true
+	dev.readers.Add(1)
+	go dev.run()
+	return nil
+}
+
+func (dev *Device) keyName() string {
+	if dev.KeyName != "" {
+		return dev.KeyName
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
+
+// Banner returns the banner the device sent in its CNXN message, such as
+// "device::ro.product.name=...;features=...".
+func (dev *Device) Banner() string {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.banner
+}
+
+// Properties returns the properties in the device's banner, such as
+// ro.product.model and features.
+func (dev *Device) Properties() map[string]string {
+	props := make(map[string]string)
+	_, list, _ := strings.Cut(dev.Banner(), "::")
+	for _, prop := range strings.Split(list, ";") {
+		if key, value, ok := strings.Cut(prop, "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// Features returns the features the device lists in its banner, such as
+// shell_v2 and cmd.
+func (dev *Device) Features() []string {
+	features := dev.Properties()["features"]
+	if features == "" {
+		return nil
+	}
+	return strings.Split(features, ",")
+}
+
+// AndroidPublicKey encodes a 2048-bit RSA public key the way adb writes
+// ~/.android/adbkey.pub and sends it to devices: the base64 encoding of
+// the key in the layout adbd reads, a space, and a name for the key.
+func AndroidPublicKey(pub *rsa.PublicKey, name string) ([]byte, error) {
+	if pub == nil || pub.N.BitLen() != keyBits {
+		return nil, fmt.Errorf("adb: public key must be %d bits", keyBits)
+	}
+	const words = keyBits / 32
+	// n0inv is -1/n mod 2^32, for Montgomery multiplication.
+	r32 := new(big.Int).Lsh(big.NewInt(1), 32)
+	n0 := new(big.Int).Mod(pub.N, r32)
+	n0inv := new(big.Int).Sub(r32, new(big.Int).ModInverse(n0, r32))
+	// rr is (2^2048)^2 mod n.
+	rr := new(big.Int).Exp(big.NewInt(2), big.NewInt(2*keyBits), pub.N)
+
+	b := make([]byte, 0, 12+2*keyBits/8)
+	b = binary.LittleEndian.AppendUint32(b, words)
+	b = binary.LittleEndian.AppendUint32(b, uint32(n0inv.Uint64()))
+	b = appendLittleEndian(b, pub.N)
+	b = appendLittleEndian(b, rr)
+	b = binary.LittleEndian.AppendUint32(b, uint32(pub.E))
+
+	enc := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(enc, b)
+	return append(append(enc, ' '), name...), nil
+}
+
+// appendLittleEndian appends a key-sized number least significant byte
+// first.
+func appendLittleEndian(b []byte, n *big.Int) []byte {
+	be := n.FillBytes(make([]byte, keyBits/8))
+	for i := len(be) - 1; i >= 0; i-- {
+		b = append(b, be[i])
+	}
+	return b
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA private key in PKCS #8 or
+// PKCS #1 form, such as the one adb keeps in ~/.android/adbkey.
+func ParsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("adb: no PEM data in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("adb: parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("adb: private key is a %T, not an RSA key", key)
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKeys returns two 2048-bit keys, generated once per test run.
+var testKeys = sync.OnceValue(func() []*rsa.PrivateKey {
+	keys := make([]*rsa.PrivateKey, 2)
+	for i := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, keyBits)
+		if err != nil {
+			panic(err)
+		}
+		keys[i] = key
+	}
+	return keys
+})
+
+func TestConnect(t *testing.T) {
+	fh := newFakeHandle()
+	dev := connectTestDevice(t, fh)
+	defer dev.Close()
+	if got := dev.Banner(); got != testBanner {
+		t.Errorf("Banner = %q, want %q", got, testBanner)
+	}
+	if got := dev.Properties()["ro.product.model"]; got != "Pixel 4a" {
+		t.Errorf("ro.product.model = %q, want %q", got, "Pixel 4a")
+	}
+	if got, want := dev.Features(), []string{"shell_v2", "cmd"}; !slices.Equal(got, want) {
+		t.Errorf("Features = %q, want %q", got, want)
+	}
+	if dev.maxPayload != 4096 {
+		t.Errorf("maxPayload = %d, want 4096", dev.maxPayload)
+	}
+	cnxn := fh.sent[0]
+	if cnxn.arg0 != versionSkipChecksum || cnxn.arg1 != maxPayload ||
+		string(cnxn.data) != "host::\x00" {
+		t.Errorf("host sent %v %q", cnxn, cnxn.data)
+	}
+}
+
+func TestConnectAuth(t *testing.T) {
+	keys := testKeys()
+	testCases := []struct {
+		name      string
+		trusted   *rsa.PrivateKey
+		keys      []*rsa.PrivateKey
+		want      []string
+		publicKey bool
+	}{
+		{"first key accepted", keys[0], keys, []string{"CNXN", "AUTH"}, false},
+		{"second key accepted", keys[1], keys, []string{"CNXN", "AUTH", "AUTH"}, false},
+		{"public key sent", keys[1], keys[:1], []string{"CNXN", "AUTH", "AUTH"}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := newFakeHandle()
+			fh.trusted = &tc.trusted.PublicKey
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			dev.KeyName = "test@host"
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := dev.Connect(ctx, tc.keys...); err != nil {
+				t.Fatalf("Connect: unexpected error %v", err)
+			}
+			if got := fh.sentCommands(); !slices.Equal(got, tc.want) {
+				t.Errorf("host sent %q, want %q", got, tc.want)
+			}
+			if !tc.publicKey {
+				return
+			}
+			pub, err := AndroidPublicKey(&tc.keys[0].PublicKey, "test@host")
+			if err != nil {
+				t.Fatalf("AndroidPublicKey: unexpected error %v", err)
+			}
+			if want := append(pub, 0); !bytes.Equal(fh.publicKey, want) {
+				t.Errorf("public key = %q, want %q", fh.publicKey, want)
+			}
+		})
+	}
+}
+
+func TestConnectErrors(t *testing.T) {
+	t.Run("no keys", func(t *testing.T) {
+		fh := newFakeHandle()
+		fh.trusted = &testKeys()[0].PublicKey
+		dev := openTestDevice(t, fh)
+		defer dev.Close()
+		if err := dev.Connect(context.Background()); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		dev := openTestDevice(t, newFakeHandle())
+		defer dev.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := dev.Connect(ctx); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+	t.Run("already connected", func(t *testing.T) {
+		dev := connectTestDevice(t, newFakeHandle())
+		defer dev.Close()
+		if err := dev.Connect(context.Background()); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestAndroidPublicKey(t *testing.T) {
+	key := &testKeys()[0].PublicKey
+	encoded, err := AndroidPublicKey(key, "user@host")
+	if err != nil {
+		t.Fatalf("AndroidPublicKey: unexpected error %v", err)
+	}
+	b64, name, ok := strings.Cut(string(encoded), " ")
+	if !ok || name != "user@host" {
+		t.Fatalf("AndroidPublicKey = %q", encoded)
+	}
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatalf("decoding key: unexpected error %v", err)
+	}
+	if len(b) != 524 {
+		t.Fatalf("key is %d bytes, want 524", len(b))
+	}
+	if words := binary.LittleEndian.Uint32(b[0:4]); words != 64 {
+		t.Errorf("modulus size = %d words, want 64", words)
+	}
+	n0 := uint32(new(big.Int).Mod(key.N, big.NewInt(1<<32)).Uint64())
+	if n0inv := binary.LittleEndian.Uint32(b[4:8]); n0*n0inv != 0xFFFFFFFF {
+		t.Errorf("n0inv = %#x isn't -1/n mod 2^32", n0inv)
+	}
+	modulus := slices.Clone(b[8:264])
+	slices.Reverse(modulus)
+	if new(big.Int).SetBytes(modulus).Cmp(key.N) != 0 {
+		t.Error("modulus doesn't match the key")
+	}
+	if e := binary.LittleEndian.Uint32(b[520:524]); e != uint32(key.E) {
+		t.Errorf("exponent = %d, want %d", e, key.E)
+	}
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AndroidPublicKey(&small.PublicKey, "user@host"); err == nil {
+		t.Error("1024-bit key: expected error, got nil")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key := testKeys()[0]
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name string
+		pem  []byte
+		ok   bool
+	}{
+		{"PKCS #8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), true},
+		{
+			"PKCS #1",
+			pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(key),
+			}),
+			true,
+		},
+		{"not PEM", []byte("adbkey"), false},
+		{"not a key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParsePrivateKey(tc.pem)
+			if !tc.ok {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !got.Equal(key) {
+				t.Error("parsed key doesn't match")
+			}
+		})
+	}
+}
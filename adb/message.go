@@ -0,0 +1,168 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// Message commands, their four letter names read as little-endian
+// integers.
+const (
+	cmdCNXN = 0x4E584E43
+	cmdAUTH = 0x48545541
+	cmdOPEN = 0x4E45504F
+	cmdOKAY = 0x59414B4F
+	cmdCLSE = 0x45534C43
+	cmdWRTE = 0x45545257
+)
+
+const (
+	headerSize = 24
+	// versionSkipChecksum is the protocol version from which payload
+	// checksums are neither sent nor checked. It's the version Connect
+	// offers.
+	versionSkipChecksum = 0x01000001
+	// maxPayload is the largest payload Connect offers to take.
+	maxPayload = 1 << 20
+)
+
+// message is an ADB message.
+type message struct {
+	command uint32
+	arg0    uint32
+	arg1    uint32
+	data    []byte
+}
+
+// String implements the Stringer interface for message.
+func (m message) String() string {
+	return fmt.Sprintf("%s(%#x, %#x) with %d bytes", commandName(m.command), m.arg0, m.arg1,
+		len(m.data))
+}
+
+// commandName returns the four letter name of a command.
+func commandName(command uint32) string {
+	name := binary.LittleEndian.AppendUint32(nil, command)
+	for _, c := range name {
+		if c < 'A' || c > 'Z' {
+			return fmt.Sprintf("command %#08x", command)
+		}
+	}
+	return string(name)
+}
+
+// checksum returns the payload checksum of the original protocol, the sum
+// of the payload's bytes.
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for _, b := range data {
+		sum += uint32(b)
+	}
+	return sum
+}
+
+func (m message) marshalHeader() []byte {
+	b := make([]byte, 0, headerSize)
+	b = binary.LittleEndian.AppendUint32(b, m.command)
+	b = binary.LittleEndian.AppendUint32(b, m.arg0)
+	b = binary.LittleEndian.AppendUint32(b, m.arg1)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(m.data)))
+	b = binary.LittleEndian.AppendUint32(b, checksum(m.data))
+	return binary.LittleEndian.AppendUint32(b, ^m.command)
+}
+
+// parseHeader parses a message header, returning the message without its
+// payload, and the payload's length and checksum.
+func parseHeader(b []byte) (message, int, uint32, error) {
+	if len(b) != headerSize {
+		return message{}, 0, 0, fmt.Errorf("adb: message header is %d bytes, want 24", len(b))
+	}
+	m := message{
+		command: binary.LittleEndian.Uint32(b[0:4]),
+		arg0:    binary.LittleEndian.Uint32(b[4:8]),
+		arg1:    binary.LittleEndian.Uint32(b[8:12]),
+	}
+	if magic := binary.LittleEndian.Uint32(b[20:24]); magic != ^m.command {
+		return message{}, 0, 0, fmt.Errorf("adb: bad magic in message header % x", b)
+	}
+	return m, int(binary.LittleEndian.Uint32(b[12:16])), binary.LittleEndian.Uint32(b[16:20]), nil
+}
+
+// send writes a message: its header, its payload, and a zero-length
+// packet if the payload fills its last packet.
+func (dev *Device) send(m message) error {
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+	if err := dev.write(m.marshalHeader()); err != nil {
+		return fmt.Errorf("adb: sending %s: %w", commandName(m.command), err)
+	}
+	if len(m.data) == 0 {
+		return nil
+	}
+	if err := dev.write(m.data); err != nil {
+		return fmt.Errorf("adb: sending %s payload: %w", commandName(m.command), err)
+	}
+	if mps := int(dev.Interface.BulkOut.MaxPacketSize); mps > 0 && len(m.data)%mps == 0 {
+		if err := dev.write(nil); err != nil {
+			return fmt.Errorf("adb: sending zero-length packet: %w", err)
+		}
+	}
+	return nil
+}
+
+func (dev *Device) write(data []byte) error {
+	_, err := usbif.Write(dev.handle, dev.Interface.BulkOut.EndpointAddress, data, dev.Timeout)
+	return err
+}
+
+// receive reads a message, waiting timeout milliseconds for its header.
+// The payload, once the header is in, is read with Timeout.
+func (dev *Device) receive(timeout int) (message, error) {
+	header := make([]byte, headerSize)
+	n, err := dev.handle.BulkTransfer(
+		dev.Interface.BulkIn.EndpointAddress,
+		header,
+		len(header),
+		timeout,
+	)
+	if err != nil {
+		return message{}, fmt.Errorf("adb: reading message header: %w", err)
+	}
+	m, length, sum, err := parseHeader(header[:n])
+	if err != nil {
+		return message{}, err
+	}
+	if length > maxPayload {
+		return message{}, fmt.Errorf(
+			"adb: %s payload is %d bytes, the limit is %d",
+			commandName(m.command),
+			length,
+			maxPayload,
+		)
+	}
+	m.data = make([]byte, length)
+	for read := 0; read < length; {
+		n, err := dev.handle.BulkTransfer(
+			dev.Interface.BulkIn.EndpointAddress,
+			m.data[read:],
+			length-read,
+			dev.Timeout,
+		)
+		if err != nil {
+			return message{}, fmt.Errorf("adb: reading %s payload: %w", commandName(m.command), err)
+		}
+		read += n
+	}
+	// Devices that speak the original protocol checksum their payloads.
+	if dev.version != 0 && dev.version < versionSkipChecksum && checksum(m.data) != sum {
+		return message{}, fmt.Errorf("adb: %s payload checksum mismatch", commandName(m.command))
+	}
+	return m, nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	m := message{command: cmdOPEN, arg0: 1, arg1: 0, data: []byte("shell:\x00")}
+	header := m.marshalHeader()
+	want := []byte{
+		'O', 'P', 'E', 'N',
+		0x01, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x07, 0x00, 0x00, 0x00,
+		0x52, 0x02, 0x00, 0x00,
+		0xB0, 0xAF, 0xBA, 0xB1,
+	}
+	if !bytes.Equal(header, want) {
+		t.Fatalf("header = % x, want % x", header, want)
+	}
+	got, length, sum, err := parseHeader(header)
+	if err != nil {
+		t.Fatalf("parseHeader: unexpected error %v", err)
+	}
+	if got.command != cmdOPEN || got.arg0 != 1 || got.arg1 != 0 || length != 7 || sum != 0x252 {
+		t.Errorf("parseHeader = %v, %d, %#x", got, length, sum)
+	}
+}
+
+func TestParseHeaderErrors(t *testing.T) {
+	good := message{command: cmdCLSE}.marshalHeader()
+	badMagic := message{command: cmdCLSE}.marshalHeader()
+	badMagic[23] ^= 0xFF
+	testCases := []struct {
+		name   string
+		header []byte
+	}{
+		{"short header", good[:20]},
+		{"bad magic", badMagic},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, _, err := parseHeader(tc.header); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	testCases := []struct {
+		command uint32
+		want    string
+	}{
+		{cmdCNXN, "CNXN"},
+		{cmdAUTH, "AUTH"},
+		{cmdWRTE, "WRTE"},
+		{0x01020304, "command 0x01020304"},
+	}
+	for _, tc := range testCases {
+		if got := commandName(tc.command); got != tc.want {
+			t.Errorf("commandName(%#x) = %q, want %q", tc.command, got, tc.want)
+		}
+	}
+}
+
+func TestSendZeroLengthPacket(t *testing.T) {
+	testCases := []struct {
+		name    string
+		size    int
+		packets int
+	}{
+		{"short payload", 100, 2},
+		{"payload fills its last packet", 1024, 3},
+		{"no payload", 0, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := newFakeHandle()
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			err := dev.send(message{command: cmdWRTE, data: make([]byte, tc.size)})
+			if err != nil {
+				t.Fatalf("send: unexpected error %v", err)
+			}
+			if fh.writes != tc.packets {
+				t.Errorf("sent %d transfers, want %d", fh.writes, tc.packets)
+			}
+		})
+	}
+}
+
+func TestReceiveChecksum(t *testing.T) {
+	fh := newFakeHandle()
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	// The original protocol version, whose payloads are checksummed.
+	dev.version = 0x01000000
+	m := message{command: cmdWRTE, data: []byte("data")}
+	header := m.marshalHeader()
+	fh.replies = [][]byte{header, []byte("data"), header, []byte("dat!")}
+	if got, err := dev.receive(100); err != nil || string(got.data) != "data" {
+		t.Errorf("receive = %v, %v", got, err)
+	}
+	if _, err := dev.receive(100); err == nil {
+		t.Error("receive with a bad checksum: expected error, got nil")
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import "io"
+
+// Shell runs a command in the device's shell and returns a stream of its
+// input and output. An empty command opens an interactive shell. The
+// device closes the stream when the command exits.
+func (dev *Device) Shell(command string) (*Stream, error) {
+	return dev.OpenStream("shell:" + command)
+}
+
+// ShellOutput runs a command in the device's shell and returns its
+// output, standard output and standard error together.
+func (dev *Device) ShellOutput(command string) ([]byte, error) {
+	s, err := dev.Shell(command)
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(s)
+	s.Close()
+	return out, err
+}
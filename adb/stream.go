@@ -0,0 +1,369 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// Stream is a stream to a service on the device. Read, Write and Close
+// may be called concurrently.
+type Stream struct {
+	dev     *Device
+	Service string
+	localID uint32
+
+	// opened is closed when the device accepts the stream, acks is
+	// signaled by the OKAY for each WRTE, and done is closed when the
+	// stream is closed at either end.
+	opened chan struct{}
+	acks   chan struct{}
+	done   chan struct{}
+	// ready is signaled when data arrives or the stream is closed.
+	ready chan struct{}
+
+	mu          sync.Mutex
+	remoteID    uint32
+	isOpen      bool
+	queue       [][]byte
+	err         error
+	localClosed bool
+
+	readMu  sync.Mutex
+	pending []byte
+
+	writeMu sync.Mutex
+}
+
+// OpenStream opens a stream to a service on the device, such as
+// "shell:ls" or "sync:", and waits up to Timeout for the device to accept
+// it.
+func (dev *Device) OpenStream(service string) (*Stream, error) {
+	if err := dev.usable(); err != nil {
+		return nil, err
+	}
+	s := &Stream{
+		dev:     dev,
+		Service: service,
+		opened:  make(chan struct{}),
+		acks:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		ready:   make(chan struct{}, 1),
+	}
+	dev.streamMu.Lock()
+	if dev.streams == nil {
+		dev.streamMu.Unlock()
+		return nil, dev.usable()
+	}
+	for {
+		dev.lastID++
+		if _, ok := dev.streams[dev.lastID]; dev.lastID != 0 && !ok {
+			break
+		}
+	}
+	s.localID = dev.lastID
+	dev.streams[s.localID] = s
+	dev.streamMu.Unlock()
+
+	err := dev.send(message{command: cmdOPEN, arg0: s.localID, data: []byte(service + "\x00")})
+	if err == nil {
+		err = s.wait(s.opened, "OPEN")
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Read reads data the device wrote to the stream, acknowledging each of
+// the device's writes once it's been read. Read waits until data
+// arrives; it returns io.EOF once the device closes the stream and its
+// data has been read.
+func (s *Stream) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	for len(s.pending) == 0 {
+		s.mu.Lock()
+		if s.localClosed {
+			s.mu.Unlock()
+			return 0, os.ErrClosed
+		}
+		if len(s.queue) > 0 {
+			s.pending = s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			open, remote := s.err == nil, s.remoteID
+			s.mu.Unlock()
+			if !open {
+				continue
+			}
+			err := s.dev.send(message{command: cmdOKAY, arg0: s.localID, arg1: remote})
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		err := s.err
+		s.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		<-s.ready
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write writes p to the stream in WRTE messages of at most the device's
+// maximum payload, waiting up to Timeout for the device to acknowledge
+// each. Once the device closes the stream, Write returns
+// io.ErrClosedPipe.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	var written int
+	for written < len(p) {
+		if err := s.writeErr(); err != nil {
+			return written, err
+		}
+		n := min(len(p)-written, s.dev.maxPayload)
+		s.mu.Lock()
+		remote := s.remoteID
+		s.mu.Unlock()
+		err := s.dev.send(message{
+			command: cmdWRTE,
+			arg0:    s.localID,
+			arg1:    remote,
+			data:    p[written : written+n],
+		})
+		if err != nil {
+			return written, err
+		}
+		if err := s.wait(s.acks, "WRTE"); err != nil {
+			if closedErr := s.writeErr(); closedErr != nil {
+				return written, closedErr
+			}
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// writeErr returns the error a Write fails with, or nil if the stream is
+// open.
+func (s *Stream) writeErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.localClosed:
+		return os.ErrClosed
+	case s.err == io.EOF:
+		return io.ErrClosedPipe
+	}
+	return s.err
+}
+
+// wait waits up to Timeout for a channel to be signaled, returning the
+// stream's error if it's closed first.
+func (s *Stream) wait(ch <-chan struct{}, command string) error {
+	var timeout <-chan time.Time
+	if s.dev.Timeout > 0 {
+		t := time.NewTimer(time.Duration(s.dev.Timeout) * time.Millisecond)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.err
+	case <-timeout:
+		return fmt.Errorf(
+			"adb: %s: device didn't answer %s: %w",
+			s.Service,
+			command,
+			os.ErrDeadlineExceeded,
+		)
+	}
+}
+
+// Close closes the stream, discarding data that hasn't been read, and
+// tells the device unless the device closed it first. Calling Close
+// again returns os.ErrClosed.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	if s.localClosed {
+		s.mu.Unlock()
+		return os.ErrClosed
+	}
+	s.localClosed = true
+	s.queue = nil
+	open, remote := s.err == nil, s.remoteID
+	s.mu.Unlock()
+	s.dev.removeStream(s.localID)
+	s.finish(os.ErrClosed)
+	if !open {
+		return nil
+	}
+	return s.dev.send(message{command: cmdCLSE, arg0: s.localID, arg1: remote})
+}
+
+// finish closes the stream with the error Read and Write return, unless
+// it's closed already.
+func (s *Stream) finish(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+		close(s.done)
+	}
+	s.mu.Unlock()
+	s.signal(s.ready)
+}
+
+func (s *Stream) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// okay handles an OKAY, which accepts the stream or acknowledges a WRTE.
+func (s *Stream) okay(remote uint32) {
+	s.mu.Lock()
+	if !s.isOpen {
+		s.isOpen = true
+		s.remoteID = remote
+		close(s.opened)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.signal(s.acks)
+}
+
+// deliver queues the data of a WRTE for Read.
+func (s *Stream) deliver(data []byte) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.queue = append(s.queue, data)
+	s.mu.Unlock()
+	s.signal(s.ready)
+}
+
+// remoteClose handles a CLSE, which refuses the stream if it isn't open
+// yet.
+func (s *Stream) remoteClose() {
+	s.mu.Lock()
+	open := s.isOpen
+	s.mu.Unlock()
+	if !open {
+		s.finish(fmt.Errorf("adb: device refused to open %s", s.Service))
+		return
+	}
+	s.finish(io.EOF)
+}
+
+// run reads messages and hands them to their streams until the device
+// is closed or a read fails.
+func (dev *Device) run() {
+	defer dev.readers.Done()
+	for {
+		select {
+		case <-dev.done:
+			return
+		default:
+		}
+		m, err := dev.receive(readPoll)
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err == nil {
+			err = dev.dispatch(m)
+		}
+		if err != nil {
+			dev.fail(err)
+			return
+		}
+	}
+}
+
+// dispatch hands a message to the stream it's addressed to. Messages for
+// streams that are already closed are dropped.
+func (dev *Device) dispatch(m message) error {
+	switch m.command {
+	case cmdOKAY, cmdWRTE, cmdCLSE:
+	case cmdCNXN:
+		return fmt.Errorf("adb: device reset the connection")
+	default:
+		return nil
+	}
+	// arg1 is the local ID of the stream.
+	s := dev.stream(m.arg1)
+	if s == nil {
+		return nil
+	}
+	switch m.command {
+	case cmdOKAY:
+		s.okay(m.arg0)
+	case cmdWRTE:
+		s.deliver(m.data)
+	case cmdCLSE:
+		dev.removeStream(s.localID)
+		s.remoteClose()
+	}
+	return nil
+}
+
+func (dev *Device) stream(id uint32) *Stream {
+	dev.streamMu.Lock()
+	defer dev.streamMu.Unlock()
+	return dev.streams[id]
+}
+
+func (dev *Device) removeStream(id uint32) {
+	dev.streamMu.Lock()
+	defer dev.streamMu.Unlock()
+	delete(dev.streams, id)
+}
+
+// fail records the error the reader stopped on and closes the streams
+// with it.
+func (dev *Device) fail(err error) {
+	dev.failOnce.Do(func() {
+		dev.readErr = err
+		close(dev.failed)
+	})
+	dev.closeStreams(dev.readErr)
+}
+
+// closeStreams closes the open streams with an error, and stops new ones
+// being opened.
+func (dev *Device) closeStreams(err error) {
+	dev.streamMu.Lock()
+	streams := dev.streams
+	dev.streams = nil
+	dev.streamMu.Unlock()
+	for _, s := range streams {
+		s.finish(err)
+	}
+}
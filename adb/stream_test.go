@@ -0,0 +1,157 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestShellOutput(t *testing.T) {
+	fh := newFakeHandle()
+	dev := connectTestDevice(t, fh)
+	defer dev.Close()
+	out, err := dev.ShellOutput("echo hi")
+	if err != nil {
+		t.Fatalf("ShellOutput: unexpected error %v", err)
+	}
+	if string(out) != "hi\n" {
+		t.Errorf("ShellOutput = %q, want %q", out, "hi\n")
+	}
+	// CNXN, OPEN, and the OKAY for the output. The device closed the
+	// stream, so closing it sends nothing.
+	want := []string{"CNXN", "OPEN", "OKAY"}
+	if got := fh.sentCommands(); !slices.Equal(got, want) {
+		t.Errorf("host sent %q, want %q", got, want)
+	}
+}
+
+func TestStreamReadWrite(t *testing.T) {
+	fh := newFakeHandle()
+	dev := connectTestDevice(t, fh)
+	defer dev.Close()
+	s, err := dev.Shell("cat")
+	if err != nil {
+		t.Fatalf("Shell: unexpected error %v", err)
+	}
+	var _ io.ReadWriteCloser = s
+
+	// Larger than the device's maximum payload, so written in two WRTEs.
+	data := bytes.Repeat([]byte("0123456789abcdef"), 300)
+	if n, err := s.Write(data); err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(s, got); err != nil {
+		t.Fatalf("Read: unexpected error %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Read didn't return the data written")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if err := s.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := s.Write(data); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := s.Read(got); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after Close: got %v, want os.ErrClosed", err)
+	}
+	fh.mu.Lock()
+	open := len(fh.services)
+	fh.mu.Unlock()
+	if open != 0 {
+		t.Errorf("device has %d streams open after Close, want 0", open)
+	}
+}
+
+func TestStreamRemoteClose(t *testing.T) {
+	dev := connectTestDevice(t, newFakeHandle())
+	defer dev.Close()
+	s, err := dev.Shell("echo hi")
+	if err != nil {
+		t.Fatalf("Shell: unexpected error %v", err)
+	}
+	if _, err := io.ReadAll(s); err != nil {
+		t.Fatalf("ReadAll: unexpected error %v", err)
+	}
+	if _, err := s.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write after the device closed: got %v, want io.ErrClosedPipe", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: unexpected error %v", err)
+	}
+}
+
+func TestOpenStreamRefused(t *testing.T) {
+	dev := connectTestDevice(t, newFakeHandle())
+	defer dev.Close()
+	if _, err := dev.OpenStream("jdwp:1"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	fh := newFakeHandle()
+	dev := connectTestDevice(t, fh)
+	defer dev.Close()
+	s, err := dev.Shell("hang")
+	if err != nil {
+		t.Fatalf("Shell: unexpected error %v", err)
+	}
+	// The device acknowledges the first write, and drops the next
+	// acknowledgement.
+	if _, err := s.Write([]byte("a")); err != nil {
+		t.Fatalf("Write: unexpected error %v", err)
+	}
+	fh.mu.Lock()
+	for _, fs := range fh.services {
+		// Drop the OKAY queued ahead of onWrite.
+		fs.onWrite = func(*fakeService, []byte) { fh.replies = fh.replies[:len(fh.replies)-1] }
+	}
+	fh.mu.Unlock()
+	dev.Timeout = 20
+	if _, err := s.Write([]byte("b")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write: got %v, want os.ErrDeadlineExceeded", err)
+	}
+}
+
+func TestCloseClosesStreams(t *testing.T) {
+	dev := connectTestDevice(t, newFakeHandle())
+	s, err := dev.Shell("cat")
+	if err != nil {
+		t.Fatalf("Shell: unexpected error %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Read(make([]byte, 8))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read: got %v, want os.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read didn't return after Close")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("closing a stream of a closed device: unexpected error %v", err)
+	}
+}
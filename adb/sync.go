@@ -0,0 +1,331 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sync requests and responses.
+const (
+	syncSTAT = "STAT"
+	syncLIST = "LIST"
+	syncDENT = "DENT"
+	syncRECV = "RECV"
+	syncSEND = "SEND"
+	syncDATA = "DATA"
+	syncDONE = "DONE"
+	syncOKAY = "OKAY"
+	syncFAIL = "FAIL"
+	syncQUIT = "QUIT"
+)
+
+const (
+	// syncMaxPath is the longest path the sync service takes.
+	syncMaxPath = 1024
+	// syncMaxData is the largest DATA chunk the sync service takes.
+	syncMaxData = 64 * 1024
+	// defaultPushMode is the permission Push gives files when it's passed
+	// none.
+	defaultPushMode = 0o644
+)
+
+// Unix file type and mode bits in sync responses.
+const (
+	unixTypeMask = 0o170000
+	unixSocket   = 0o140000
+	unixSymlink  = 0o120000
+	unixRegular  = 0o100000
+	unixBlock    = 0o060000
+	unixDir      = 0o040000
+	unixChar     = 0o020000
+	unixFIFO     = 0o010000
+	unixSetuid   = 0o4000
+	unixSetgid   = 0o2000
+	unixSticky   = 0o1000
+)
+
+// FileInfo describes a file on the device.
+type FileInfo struct {
+	Name    string
+	Mode    os.FileMode
+	Size    uint32
+	ModTime time.Time
+}
+
+// Sync is a session with the device's sync: service, which transfers
+// files. Its methods may be called concurrently, and run one at a time.
+type Sync struct {
+	stream *Stream
+	mu     sync.Mutex
+}
+
+// Sync opens a session with the sync: service.
+func (dev *Device) Sync() (*Sync, error) {
+	s, err := dev.OpenStream("sync:")
+	if err != nil {
+		return nil, err
+	}
+	return &Sync{stream: s}, nil
+}
+
+// Close ends the session.
+func (sc *Sync) Close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	err := sc.request(syncQUIT, nil)
+	switch {
+	case errors.Is(err, os.ErrClosed):
+		return err
+	case errors.Is(err, io.ErrClosedPipe):
+		// The device may close the stream before acknowledging QUIT.
+		err = nil
+	}
+	return errors.Join(err, sc.stream.Close())
+}
+
+// Stat returns information about a file on the device. An error wrapping
+// os.ErrNotExist is returned if there's no such file.
+func (sc *Sync) Stat(path string) (*FileInfo, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.request(syncSTAT, []byte(path)); err != nil {
+		return nil, err
+	}
+	var resp [16]byte
+	if err := sc.read(resp[:], syncSTAT); err != nil {
+		return nil, err
+	}
+	if id := string(resp[:4]); id != syncSTAT {
+		return nil, fmt.Errorf("adb: sync STAT answered with %q", id)
+	}
+	// The device answers with a zero mode when the file doesn't exist.
+	if binary.LittleEndian.Uint32(resp[4:8]) == 0 {
+		return nil, fmt.Errorf("adb: sync STAT %s: %w", path, os.ErrNotExist)
+	}
+	return parseFileInfo(path, resp[4:]), nil
+}
+
+// List returns the entries of a directory on the device, including . and
+// .. when the device lists them.
+func (sc *Sync) List(path string) ([]*FileInfo, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.request(syncLIST, []byte(path)); err != nil {
+		return nil, err
+	}
+	var entries []*FileInfo
+	for {
+		var resp [20]byte
+		if err := sc.read(resp[:], syncLIST); err != nil {
+			return nil, err
+		}
+		switch id := string(resp[:4]); id {
+		case syncDONE:
+			return entries, nil
+		case syncDENT:
+		default:
+			return nil, fmt.Errorf("adb: sync LIST answered with %q", id)
+		}
+		name := make([]byte, binary.LittleEndian.Uint32(resp[16:20]))
+		if len(name) > syncMaxPath {
+			return nil, fmt.Errorf("adb: sync LIST entry name is %d bytes", len(name))
+		}
+		if err := sc.read(name, syncLIST); err != nil {
+			return nil, err
+		}
+		entries = append(entries, parseFileInfo(string(name), resp[4:16]))
+	}
+}
+
+// Pull copies a file on the device to w, returning the number of bytes
+// copied.
+func (sc *Sync) Pull(path string, w io.Writer) (int64, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.request(syncRECV, []byte(path)); err != nil {
+		return 0, err
+	}
+	var copied int64
+	for {
+		id, length, err := sc.readHeader(syncRECV)
+		if err != nil {
+			return copied, err
+		}
+		switch id {
+		case syncDONE:
+			return copied, nil
+		case syncFAIL:
+			return copied, sc.failure(syncRECV, path, length)
+		case syncDATA:
+		default:
+			return copied, fmt.Errorf("adb: sync RECV answered with %q", id)
+		}
+		if length > syncMaxData {
+			return copied, fmt.Errorf("adb: sync DATA chunk is %d bytes", length)
+		}
+		n, err := io.CopyN(w, sc.stream, int64(length))
+		copied += n
+		if err != nil {
+			return copied, fmt.Errorf("adb: sync RECV %s: %w", path, err)
+		}
+	}
+}
+
+// Push copies r to a file on the device, creating it with permissions
+// mode, or 0644 if mode is zero, and setting its modification time to
+// mtime.
+func (sc *Sync) Push(r io.Reader, path string, mode os.FileMode, mtime time.Time) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	perm := unixPermissions(mode)
+	if perm == 0 {
+		perm = defaultPushMode
+	}
+	target := path + "," + strconv.FormatUint(uint64(unixRegular|perm), 10)
+	if err := sc.request(syncSEND, []byte(target)); err != nil {
+		return err
+	}
+	buf := make([]byte, 8+syncMaxData)
+	for {
+		n, err := io.ReadFull(r, buf[8:])
+		if n > 0 {
+			copy(buf, syncDATA)
+			binary.LittleEndian.PutUint32(buf[4:8], uint32(n))
+			if _, err := sc.stream.Write(buf[:8+n]); err != nil {
+				return fmt.Errorf("adb: sync SEND %s: %w", path, err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("adb: sync SEND %s: reading data: %w", path, err)
+		}
+	}
+	done := binary.LittleEndian.AppendUint32([]byte(syncDONE), uint32(mtime.Unix()))
+	if _, err := sc.stream.Write(done); err != nil {
+		return fmt.Errorf("adb: sync SEND %s: %w", path, err)
+	}
+	id, length, err := sc.readHeader(syncSEND)
+	if err != nil {
+		return err
+	}
+	switch id {
+	case syncOKAY:
+		return nil
+	case syncFAIL:
+		return sc.failure(syncSEND, path, length)
+	}
+	return fmt.Errorf("adb: sync SEND answered with %q", id)
+}
+
+// request sends a sync request with its argument.
+func (sc *Sync) request(id string, arg []byte) error {
+	if len(arg) > syncMaxPath {
+		return fmt.Errorf("adb: sync path is %d bytes, the limit is %d", len(arg), syncMaxPath)
+	}
+	req := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(arg)))
+	if _, err := sc.stream.Write(append(req, arg...)); err != nil {
+		return fmt.Errorf("adb: sync %s: %w", id, err)
+	}
+	return nil
+}
+
+// read reads a response, or part of one, in full.
+func (sc *Sync) read(b []byte, request string) error {
+	if _, err := io.ReadFull(sc.stream, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("adb: sync %s: reading response: %w", request, err)
+	}
+	return nil
+}
+
+// readHeader reads the ID and length that start most responses.
+func (sc *Sync) readHeader(request string) (string, uint32, error) {
+	var header [8]byte
+	if err := sc.read(header[:], request); err != nil {
+		return "", 0, err
+	}
+	return string(header[:4]), binary.LittleEndian.Uint32(header[4:]), nil
+}
+
+// failure reads the message of a FAIL response.
+func (sc *Sync) failure(request, path string, length uint32) error {
+	if length > syncMaxPath {
+		return fmt.Errorf("adb: sync FAIL message is %d bytes", length)
+	}
+	msg := make([]byte, length)
+	if err := sc.read(msg, request); err != nil {
+		return err
+	}
+	return fmt.Errorf("adb: sync %s %s: %s", request, path, msg)
+}
+
+// parseFileInfo parses the mode, size and modification time of a STAT or
+// DENT response.
+func parseFileInfo(name string, b []byte) *FileInfo {
+	return &FileInfo{
+		Name:    name,
+		Mode:    fileMode(binary.LittleEndian.Uint32(b[0:4])),
+		Size:    binary.LittleEndian.Uint32(b[4:8]),
+		ModTime: time.Unix(int64(binary.LittleEndian.Uint32(b[8:12])), 0),
+	}
+}
+
+// fileMode converts a Unix st_mode to an os.FileMode.
+func fileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0o777)
+	switch mode & unixTypeMask {
+	case unixSocket:
+		m |= os.ModeSocket
+	case unixSymlink:
+		m |= os.ModeSymlink
+	case unixBlock:
+		m |= os.ModeDevice
+	case unixDir:
+		m |= os.ModeDir
+	case unixChar:
+		m |= os.ModeDevice | os.ModeCharDevice
+	case unixFIFO:
+		m |= os.ModeNamedPipe
+	}
+	if mode&unixSetuid != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&unixSetgid != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&unixSticky != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// unixPermissions converts the permission bits of an os.FileMode to those
+// of a Unix st_mode.
+func unixPermissions(mode os.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= unixSetuid
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= unixSetgid
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= unixSticky
+	}
+	return perm
+}
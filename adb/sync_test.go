@@ -0,0 +1,216 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMTime is the modification time of the files the fake sync service
+// reports.
+const testMTime = 1700000000
+
+// syncWrite is the fake sync: service. It keeps the data the host writes
+// until it holds whole requests, and answers each.
+func (fh *fakeHandle) syncWrite(fs *fakeService, data []byte) {
+	fs.buf = append(fs.buf, data...)
+	for len(fs.buf) >= 8 {
+		id := string(fs.buf[:4])
+		length := int(binary.LittleEndian.Uint32(fs.buf[4:8]))
+		if id == syncDONE {
+			// The argument of the DONE that ends a SEND is the mtime.
+			length = 0
+		}
+		if len(fs.buf) < 8+length {
+			return
+		}
+		arg := fs.buf[8 : 8+length]
+		fs.buf = fs.buf[8+length:]
+		fh.syncRequest(fs, id, arg)
+	}
+}
+
+func (fh *fakeHandle) syncRequest(fs *fakeService, id string, arg []byte) {
+	reply := func(id string, fields ...uint32) []byte {
+		b := []byte(id)
+		for _, f := range fields {
+			b = binary.LittleEndian.AppendUint32(b, f)
+		}
+		return b
+	}
+	fail := func(msg string) {
+		fh.write(fs, append(reply(syncFAIL, uint32(len(msg))), msg...))
+	}
+	switch id {
+	case syncSTAT:
+		file, ok := fh.files[string(arg)]
+		if !ok {
+			fh.write(fs, reply(syncSTAT, 0, 0, 0))
+			return
+		}
+		fh.write(fs, reply(syncSTAT, unixRegular|0o644, uint32(len(file)), testMTime))
+	case syncLIST:
+		var names []string
+		for path := range fh.files {
+			if name, ok := strings.CutPrefix(path, string(arg)+"/"); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var b []byte
+		for _, name := range names {
+			size := uint32(len(fh.files[string(arg)+"/"+name]))
+			b = append(b, reply(syncDENT, unixRegular|0o644, size, testMTime, uint32(len(name)))...)
+			b = append(b, name...)
+		}
+		fh.write(fs, append(b, reply(syncDONE, 0, 0, 0, 0)...))
+	case syncRECV:
+		file, ok := fh.files[string(arg)]
+		if !ok {
+			fail("No such file or directory")
+			return
+		}
+		var b []byte
+		for len(file) > 0 {
+			n := min(len(file), syncMaxData)
+			b = append(append(b, reply(syncDATA, uint32(n))...), file[:n]...)
+			file = file[n:]
+		}
+		fh.write(fs, append(b, reply(syncDONE, 0)...))
+	case syncSEND:
+		path, mode, _ := strings.Cut(string(arg), ",")
+		if m, err := strconv.Atoi(mode); err != nil || m&unixTypeMask != unixRegular {
+			fail("bad mode " + mode)
+			return
+		}
+		fs.name, fs.file = path, []byte{}
+	case syncDATA:
+		fs.file = append(fs.file, arg...)
+	case syncDONE:
+		if strings.HasPrefix(fs.name, "/readonly/") {
+			fail("Read-only file system")
+			return
+		}
+		fh.files[fs.name] = fs.file
+		fh.write(fs, reply(syncOKAY, 0))
+	case syncQUIT:
+		fh.closeService(fs)
+	}
+}
+
+func openTestSync(t *testing.T, fh *fakeHandle) (*Device, *Sync) {
+	t.Helper()
+	dev := connectTestDevice(t, fh)
+	sc, err := dev.Sync()
+	if err != nil {
+		dev.Close()
+		t.Fatalf("Sync: unexpected error %v", err)
+	}
+	return dev, sc
+}
+
+func TestSyncPushPull(t *testing.T) {
+	fh := newFakeHandle()
+	dev, sc := openTestSync(t, fh)
+	defer dev.Close()
+
+	// More than one DATA chunk.
+	data := bytes.Repeat([]byte("adb sync "), 10000)
+	mtime := time.Unix(testMTime, 0)
+	if err := sc.Push(bytes.NewReader(data), "/sdcard/test.txt", 0, mtime); err != nil {
+		t.Fatalf("Push: unexpected error %v", err)
+	}
+	fh.mu.Lock()
+	pushed := fh.files["/sdcard/test.txt"]
+	fh.mu.Unlock()
+	if !bytes.Equal(pushed, data) {
+		t.Fatalf("pushed %d bytes, want %d", len(pushed), len(data))
+	}
+
+	var buf bytes.Buffer
+	n, err := sc.Pull("/sdcard/test.txt", &buf)
+	if err != nil {
+		t.Fatalf("Pull: unexpected error %v", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Pull copied %d bytes, want %d", n, len(data))
+	}
+
+	if _, err := sc.Pull("/sdcard/missing", &buf); err == nil {
+		t.Error("Pull of a missing file: expected error, got nil")
+	}
+	err = sc.Push(bytes.NewReader(data), "/readonly/test.txt", 0o600, mtime)
+	if err == nil || !strings.Contains(err.Error(), "Read-only file system") {
+		t.Errorf("Push to a read-only file system: got %v", err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Errorf("Close: unexpected error %v", err)
+	}
+}
+
+func TestSyncStatList(t *testing.T) {
+	fh := newFakeHandle()
+	fh.files["/sdcard/a.txt"] = []byte("aaa")
+	fh.files["/sdcard/b.txt"] = []byte("bb")
+	dev, sc := openTestSync(t, fh)
+	defer dev.Close()
+	defer sc.Close()
+
+	info, err := sc.Stat("/sdcard/a.txt")
+	if err != nil {
+		t.Fatalf("Stat: unexpected error %v", err)
+	}
+	want := FileInfo{Name: "/sdcard/a.txt", Mode: 0o644, Size: 3, ModTime: time.Unix(testMTime, 0)}
+	if *info != want {
+		t.Errorf("Stat = %+v, want %+v", *info, want)
+	}
+	if _, err := sc.Stat("/sdcard/missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing file: got %v, want os.ErrNotExist", err)
+	}
+
+	entries, err := sc.List("/sdcard")
+	if err != nil {
+		t.Fatalf("List: unexpected error %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	if want := []string{"a.txt", "b.txt"}; !slices.Equal(names, want) {
+		t.Errorf("List = %q, want %q", names, want)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	testCases := []struct {
+		mode uint32
+		want os.FileMode
+	}{
+		{unixRegular | 0o644, 0o644},
+		{unixDir | 0o755, os.ModeDir | 0o755},
+		{unixSymlink | 0o777, os.ModeSymlink | 0o777},
+		{unixChar | 0o666, os.ModeDevice | os.ModeCharDevice | 0o666},
+		{unixRegular | unixSetuid | 0o755, os.ModeSetuid | 0o755},
+		{unixDir | unixSticky | 0o777, os.ModeDir | os.ModeSticky | 0o777},
+	}
+	for _, tc := range testCases {
+		if got := fileMode(tc.mode); got != tc.want {
+			t.Errorf("fileMode(%#o) = %v, want %v", tc.mode, got, tc.want)
+		}
+	}
+	if got := unixPermissions(os.ModeSetgid | 0o750); got != unixSetgid|0o750 {
+		t.Errorf("unixPermissions = %#o, want %#o", got, unixSetgid|0o750)
+	}
+}
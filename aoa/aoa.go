@@ -0,0 +1,274 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package aoa implements the host side of the Android Open Accessory
+protocol on top of libusb, for accessories that talk to Android phones.
+
+A phone enters accessory mode on a sequence of vendor requests: the host
+reads the protocol version, sends strings that identify the accessory, and
+asks the phone to start. The phone then disconnects and enumerates again
+with Google's vendor ID and one of the accessory product IDs, with a
+vendor-specific interface whose bulk endpoint pair carries the
+accessory's data.
+
+Start sends the requests to a phone. SwitchToAccessory does the same, and
+uses the hotplug callbacks of a libusb context to wait for the phone to
+come back as an accessory. FindInterface and Open turn the accessory's
+interface into a Conn, an io.ReadWriteCloser over its bulk endpoints.
+Connect runs all of the steps on a *libusb.Context.
+
+Version 2 of the protocol adds audio output, which Start requests when
+Config.Audio is set, and HID devices the accessory registers with the
+phone: RegisterHID, SendHIDEvent and UnregisterHID.
+*/
+package aoa
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the timeout in milliseconds used for control requests.
+const DefaultTimeout = 1000
+
+// VendorGoogle is the vendor ID of a phone in accessory mode.
+const VendorGoogle = 0x18D1
+
+// Product IDs of a phone in accessory mode, which tell whether its
+// accessory interface, ADB interface and audio interfaces are present.
+const (
+	ProductAccessory         = 0x2D00
+	ProductAccessoryADB      = 0x2D01
+	ProductAudio             = 0x2D02
+	ProductAudioADB          = 0x2D03
+	ProductAccessoryAudio    = 0x2D04
+	ProductAccessoryAudioADB = 0x2D05
+)
+
+// Accessory vendor requests.
+const (
+	requestGetProtocol      = 51
+	requestSendString       = 52
+	requestStart            = 53
+	requestRegisterHID      = 54
+	requestUnregisterHID    = 55
+	requestSetHIDReportDesc = 56
+	requestSendHIDEvent     = 57
+	requestSetAudioMode     = 58
+)
+
+// Indexes of the identifying strings.
+const (
+	stringManufacturer = 0
+	stringModel        = 1
+	stringDescription  = 2
+	stringVersion      = 3
+	stringURI          = 4
+	stringSerial       = 5
+)
+
+// audioMode16Bit44K selects two channel 16-bit PCM at 44100 Hz, the only
+// audio mode.
+const audioMode16Bit44K = 1
+
+// hidChunkSize is the largest piece of a HID report descriptor sent in one
+// request.
+const hidChunkSize = 64
+
+// Handle is the subset of *libusb.DeviceHandle used to switch a phone to
+// accessory mode and to talk to it once it's there.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+}
+
+// Config identifies the accessory to the phone. The phone uses
+// Manufacturer, Model and Version to pick the app that handles the
+// accessory, and offers URI to the user when no app does. Empty strings
+// aren't sent.
+type Config struct {
+	Manufacturer string
+	Model        string
+	Description  string
+	Version      string
+	URI          string
+	Serial       string
+	// Audio asks the phone to route its audio output to the accessory. It
+	// needs protocol version 2.
+	Audio bool
+}
+
+// IsAccessory reports whether a vendor and product ID are those of a
+// phone in accessory mode.
+func IsAccessory(vendorID, productID uint16) bool {
+	return vendorID == VendorGoogle &&
+		productID >= ProductAccessory && productID <= ProductAccessoryAudioADB
+}
+
+// Protocol returns the version of the accessory protocol a phone
+// supports, zero if it doesn't support it.
+func Protocol(handle Handle) (uint16, error) {
+	var buf [2]byte
+	n, err := handle.ControlIn(
+		libusb.Vendor,
+		libusb.DeviceRecipient,
+		requestGetProtocol,
+		0,
+		0,
+		buf[:],
+		len(buf),
+		DefaultTimeout,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("aoa: reading protocol version: %w", err)
+	}
+	if n != len(buf) {
+		return 0, fmt.Errorf("aoa: protocol version is %d bytes, want 2", n)
+	}
+	return binary.LittleEndian.Uint16(buf[:]), nil
+}
+
+// Start identifies the accessory to a phone and asks the phone to switch
+// to accessory mode, returning the protocol version the phone supports.
+// The phone then leaves the bus, so handle is of no further use.
+func Start(handle Handle, cfg *Config) (uint16, error) {
+	if handle == nil || cfg == nil {
+		return 0, fmt.Errorf("aoa: nil handle or config")
+	}
+	version, err := Protocol(handle)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case version == 0:
+		return 0, fmt.Errorf("aoa: device doesn't support accessory mode")
+	case cfg.Audio && version < 2:
+		return version, fmt.Errorf("aoa: audio needs protocol version 2, device has %d", version)
+	}
+	strs := []struct {
+		index uint16
+		value string
+	}{
+		{stringManufacturer, cfg.Manufacturer},
+		{stringModel, cfg.Model},
+		{stringDescription, cfg.Description},
+		{stringVersion, cfg.Version},
+		{stringURI, cfg.URI},
+		{stringSerial, cfg.Serial},
+	}
+	for _, s := range strs {
+		if s.value == "" {
+			continue
+		}
+		data := []byte(s.value + "\x00")
+		if err := vendorOut(handle, requestSendString, 0, s.index, data); err != nil {
+			return version, err
+		}
+	}
+	if cfg.Audio {
+		if err := vendorOut(handle, requestSetAudioMode, audioMode16Bit44K, 0, nil); err != nil {
+			return version, err
+		}
+	}
+	return version, vendorOut(handle, requestStart, 0, 0, nil)
+}
+
+// RegisterHID registers a HID device with a phone under an ID the
+// accessory picks, and sends the device's report descriptor. It needs
+// protocol version 2.
+func RegisterHID(handle Handle, id uint16, reportDescriptor []byte) error {
+	if len(reportDescriptor) == 0 || len(reportDescriptor) > 0xFFFF {
+		return fmt.Errorf(
+			"aoa: HID report descriptor is %d bytes, want 1 to 65535",
+			len(reportDescriptor),
+		)
+	}
+	size := uint16(len(reportDescriptor))
+	if err := vendorOut(handle, requestRegisterHID, id, size, nil); err != nil {
+		return err
+	}
+	for offset := 0; offset < len(reportDescriptor); offset += hidChunkSize {
+		end := min(offset+hidChunkSize, len(reportDescriptor))
+		err := vendorOut(
+			handle,
+			requestSetHIDReportDesc,
+			id,
+			uint16(offset),
+			reportDescriptor[offset:end],
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnregisterHID removes a HID device RegisterHID registered.
+func UnregisterHID(handle Handle, id uint16) error {
+	return vendorOut(handle, requestUnregisterHID, id, 0, nil)
+}
+
+// SendHIDEvent sends an input report of a registered HID device.
+func SendHIDEvent(handle Handle, id uint16, report []byte) error {
+	return vendorOut(handle, requestSendHIDEvent, id, 0, report)
+}
+
+var requestNames = map[byte]string{
+	requestSendString:       "SEND_STRING",
+	requestStart:            "START",
+	requestRegisterHID:      "REGISTER_HID",
+	requestUnregisterHID:    "UNREGISTER_HID",
+	requestSetHIDReportDesc: "SET_HID_REPORT_DESC",
+	requestSendHIDEvent:     "SEND_HID_EVENT",
+	requestSetAudioMode:     "SET_AUDIO_MODE",
+}
+
+// vendorOut sends an accessory request.
+func vendorOut(handle Handle, request byte, value, index uint16, data []byte) error {
+	n, err := handle.ControlOut(
+		libusb.Vendor,
+		libusb.DeviceRecipient,
+		request,
+		value,
+		index,
+		data,
+		DefaultTimeout,
+	)
+	if err != nil {
+		return fmt.Errorf("aoa: %s request: %w", requestNames[request], err)
+	}
+	if n != len(data) {
+		return fmt.Errorf(
+			"aoa: %s request sent %d of %d bytes",
+			requestNames[request],
+			n,
+			len(data),
+		)
+	}
+	return nil
+}
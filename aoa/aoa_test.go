@@ -0,0 +1,192 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package aoa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// protocol is returned by GET_PROTOCOL.
+	protocol uint16
+	// controlData collects the data of each control OUT request.
+	controlData [][]byte
+	// onStart, if set, is called on the START request.
+	onStart func()
+	// replies are returned one per bulk IN transfer; once they run out,
+	// reads time out.
+	replies [][]byte
+	// written collects each bulk OUT transfer.
+	written [][]byte
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.Record("control in %d", request)
+	return copy(data[:maxReceiveLength], binary.LittleEndian.AppendUint16(nil, fh.protocol)), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.Record("control out %d %d %d", request, value, index)
+	fh.mu.Lock()
+	fh.controlData = append(fh.controlData, slices.Clone(data))
+	fh.mu.Unlock()
+	if request == requestStart && fh.onStart != nil {
+		fh.onStart()
+	}
+	return len(data), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, slices.Clone(data[:length]))
+		return length, nil
+	}
+	if len(fh.replies) == 0 {
+		fh.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		fh.mu.Lock()
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.replies[0]
+	fh.replies = fh.replies[1:]
+	return copy(data[:length], p), nil
+}
+
+func TestIsAccessory(t *testing.T) {
+	testCases := []struct {
+		vendorID, productID uint16
+		want                bool
+	}{
+		{VendorGoogle, ProductAccessory, true},
+		{VendorGoogle, ProductAccessoryAudioADB, true},
+		{VendorGoogle, 0x2D06, false},
+		{VendorGoogle, 0x4EE7, false},
+		{0x04E8, ProductAccessory, false},
+	}
+	for _, tc := range testCases {
+		if got := IsAccessory(tc.vendorID, tc.productID); got != tc.want {
+			t.Errorf("IsAccessory(%04x, %04x) = %t, want %t",
+				tc.vendorID, tc.productID, got, tc.want)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	fh := &fakeHandle{protocol: 2}
+	cfg := &Config{
+		Manufacturer: "Example",
+		Model:        "Dock",
+		Version:      "1.0",
+		URI:          "https://example.com/dock",
+		Audio:        true,
+	}
+	version, err := Start(fh, cfg)
+	if err != nil || version != 2 {
+		t.Fatalf("Start = %d, %v", version, err)
+	}
+	wantCalls := []string{
+		"control in 51",
+		"control out 52 0 0",
+		"control out 52 0 1",
+		"control out 52 0 3",
+		"control out 52 0 4",
+		"control out 58 1 0",
+		"control out 53 0 0",
+	}
+	if calls := fh.Calls(); !slices.Equal(calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", calls, wantCalls)
+	}
+	if got := fh.controlData[0]; !bytes.Equal(got, []byte("Example\x00")) {
+		t.Errorf("manufacturer = %q", got)
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		protocol uint16
+		cfg      *Config
+	}{
+		{"no accessory support", 0, &Config{Model: "Dock"}},
+		{"audio on version 1", 1, &Config{Model: "Dock", Audio: true}},
+		{"nil config", 2, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := &fakeHandle{protocol: tc.protocol}
+			if _, err := Start(fh, tc.cfg); err == nil {
+				t.Error("expected error, got nil")
+			}
+			if slices.Contains(fh.Calls(), "control out 53 0 0") {
+				t.Error("START sent after an error")
+			}
+		})
+	}
+}
+
+func TestHID(t *testing.T) {
+	fh := &fakeHandle{}
+	desc := bytes.Repeat([]byte{0x05}, 100)
+	if err := RegisterHID(fh, 7, desc); err != nil {
+		t.Fatalf("RegisterHID: unexpected error %v", err)
+	}
+	if err := SendHIDEvent(fh, 7, []byte{0x01, 0x00}); err != nil {
+		t.Fatalf("SendHIDEvent: unexpected error %v", err)
+	}
+	if err := UnregisterHID(fh, 7); err != nil {
+		t.Fatalf("UnregisterHID: unexpected error %v", err)
+	}
+	wantCalls := []string{
+		"control out 54 7 100",
+		"control out 56 7 0",
+		"control out 56 7 64",
+		"control out 57 7 0",
+		"control out 55 7 0",
+	}
+	if calls := fh.Calls(); !slices.Equal(calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", calls, wantCalls)
+	}
+	if got := bytes.Join(fh.controlData[1:3], nil); !bytes.Equal(got, desc) {
+		t.Errorf("report descriptor = % x, want % x", got, desc)
+	}
+	if err := RegisterHID(fh, 8, nil); err == nil {
+		t.Error("empty report descriptor: expected error, got nil")
+	}
+}
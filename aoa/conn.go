@@ -0,0 +1,178 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package aoa
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// SubclassAccessory is the interface subclass of the accessory interface.
+const SubclassAccessory = 0xFF
+
+// readPoll is the timeout in milliseconds of each transfer Read makes, and
+// so the longest Close waits for a Read to return.
+const readPoll = 250
+
+// Interface is the accessory interface of a phone in accessory mode and
+// its endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	BulkIn     *libusb.EndpointDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+}
+
+// FindInterface returns the accessory interface in the configuration of a
+// phone in accessory mode. The audio-only product IDs have none.
+func FindInterface(config *libusb.ConfigDescriptor) (*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("aoa: nil configuration descriptor")
+	}
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassVendorSpec,
+	) {
+		if desc.InterfaceSubClass != SubclassAccessory || desc.AlternateSetting != 0 {
+			continue
+		}
+		iface := &Interface{Descriptor: desc}
+		for _, ep := range desc.EndpointDescriptors {
+			if ep.TransferType() != libusb.BulkTransfer {
+				continue
+			}
+			if ep.Direction() == libusb.EndpointIn && iface.BulkIn == nil {
+				iface.BulkIn = ep
+			} else if ep.Direction() == libusb.EndpointOut && iface.BulkOut == nil {
+				iface.BulkOut = ep
+			}
+		}
+		if iface.BulkIn == nil || iface.BulkOut == nil {
+			return nil, fmt.Errorf(
+				"aoa: interface %d has no bulk endpoint pair",
+				desc.InterfaceNumber,
+			)
+		}
+		return iface, nil
+	}
+	return nil, fmt.Errorf("aoa: configuration has no accessory interface")
+}
+
+// Conn is a claimed accessory interface. Read and Write may be called
+// concurrently with each other, but not with themselves.
+type Conn struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds. Zero, the default,
+	// waits forever, so Read waits until the phone writes.
+	Timeout int
+
+	readMu sync.Mutex
+	reader *usbif.Reader
+
+	// owned and device are closed with the Conn when Connect opened them.
+	owned  *libusb.DeviceHandle
+	device *libusb.Device
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims an accessory interface. Phones in accessory mode have no
+// kernel driver of their own, but one that is bound is detached.
+func Open(handle Handle, iface *Interface) (*Conn, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("aoa: nil handle or interface")
+	}
+	conn := &Conn{
+		handle:    handle,
+		Interface: iface,
+		reader: usbif.NewReader(
+			handle,
+			iface.BulkIn.EndpointAddress,
+			int(iface.BulkIn.MaxPacketSize),
+		),
+	}
+	claims, err := usbif.Claim(handle, "aoa", iface.Descriptor.InterfaceNumber)
+	if err != nil {
+		return nil, err
+	}
+	conn.claims = claims
+	return conn, nil
+}
+
+// Read returns what the accessory app on the phone wrote. It waits in short
+// transfers so that Close can stop a waiting Read. If nothing arrives
+// within Timeout, Read returns a libusb.ErrorCode whose Timeout method
+// reports true.
+func (conn *Conn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
+	var waited int
+	for {
+		if conn.isClosed() {
+			return 0, os.ErrClosed
+		}
+		poll := readPoll
+		if conn.Timeout > 0 {
+			poll = min(poll, conn.Timeout-waited)
+		}
+		n, err := conn.reader.ReadPacket(p, poll)
+		if usbif.IsTimeout(err) {
+			waited += poll
+			if conn.Timeout == 0 || waited < conn.Timeout {
+				continue
+			}
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Write hands p to the accessory app on the phone.
+func (conn *Conn) Write(p []byte) (int, error) {
+	if conn.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return usbif.Write(conn.handle, conn.Interface.BulkOut.EndpointAddress, p, conn.Timeout)
+}
+
+// Close waits for a Read to return and releases the interface; the app on
+// the phone sees its accessory stream end. A Conn from Connect also closes
+// the accessory's device handle. Calling Close again returns os.ErrClosed.
+func (conn *Conn) Close() error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return os.ErrClosed
+	}
+	conn.closed = true
+	conn.mu.Unlock()
+	// A Read in progress sees closed within one poll.
+	conn.readMu.Lock()
+	conn.readMu.Unlock()
+
+	errs := []error{conn.claims.Release()}
+	if conn.owned != nil {
+		errs = append(errs, conn.owned.Close())
+		conn.device.Close()
+	}
+	return errors.Join(errs...)
+}
+
+func (conn *Conn) isClosed() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.closed
+}
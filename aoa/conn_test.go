@@ -0,0 +1,148 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package aoa
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+// testConfig returns the configuration of a phone in accessory mode with
+// ADB enabled: the accessory interface 0 with the bulk endpoints 0x81 and
+// 0x01, and the ADB interface 1.
+func testConfig() *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: SubclassAccessory,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:   1,
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: 0x42,
+				InterfaceProtocol: 0x01,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+		},
+	}
+}
+
+func openTestConn(t *testing.T, fh *fakeHandle) *Conn {
+	t.Helper()
+	iface, err := FindInterface(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterface: unexpected error %v", err)
+	}
+	conn, err := Open(fh, iface)
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return conn
+}
+
+func TestFindInterface(t *testing.T) {
+	iface, err := FindInterface(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterface: unexpected error %v", err)
+	}
+	if iface.Descriptor.InterfaceNumber != 0 || iface.BulkIn.EndpointAddress != 0x81 ||
+		iface.BulkOut.EndpointAddress != 0x01 {
+		t.Errorf("interface = %+v", iface)
+	}
+	audioOnly := testConfig()
+	audioOnly.SupportedInterfaces = audioOnly.SupportedInterfaces[1:]
+	if _, err := FindInterface(audioOnly); err == nil {
+		t.Error("no accessory interface: expected error, got nil")
+	}
+	if _, err := FindInterface(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	conn := openTestConn(t, fh)
+	var _ io.ReadWriteCloser = conn
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := conn.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := conn.Write([]byte{1}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{[]byte("hello, accessory")}}
+	conn := openTestConn(t, fh)
+	defer conn.Close()
+
+	if n, err := conn.Write([]byte("ping")); err != nil || n != 4 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if len(fh.written) != 1 || !bytes.Equal(fh.written[0], []byte("ping")) {
+		t.Errorf("written = %q", fh.written)
+	}
+	got := make([]byte, 16)
+	if _, err := io.ReadFull(conn, got[:5]); err != nil {
+		t.Fatalf("Read: unexpected error %v", err)
+	}
+	if _, err := io.ReadFull(conn, got[5:]); err != nil {
+		t.Fatalf("Read: unexpected error %v", err)
+	}
+	if string(got) != "hello, accessory" {
+		t.Errorf("Read = %q", got)
+	}
+
+	conn.Timeout = 20
+	if _, err := conn.Read(got); !usbif.IsTimeout(err) {
+		t.Errorf("Read with nothing to read: got %v, want a timeout", err)
+	}
+}
+
+func TestCloseStopsRead(t *testing.T) {
+	conn := openTestConn(t, &fakeHandle{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 8))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read after Close: got %v, want os.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read didn't return after Close")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package aoa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// openRetry is how often Connect tries to open the accessory while the
+// system sets up the new device.
+const openRetry = 100 * time.Millisecond
+
+// Hotplug is the subset of *libusb.Context used to wait for a phone to
+// come back in accessory mode.
+type Hotplug interface {
+	HotplugRegisterCallbackEvent(
+		vendorID, productID uint16,
+		eventType libusb.HotPlugEventType,
+		cb libusb.HotPlugCbFunc,
+	) error
+	HotplugDeregisterCallback(vendorID, productID uint16) error
+}
+
+// SwitchToAccessory switches a phone to accessory mode with Start and
+// waits until it arrives again as an accessory, returning the product ID
+// it arrived with. The hotplug callbacks for the accessory product IDs
+// are registered before the phone is started, so its return can't be
+// missed, and are deregistered before SwitchToAccessory returns; they
+// replace any callbacks usb already has for those IDs.
+func SwitchToAccessory(
+	ctx context.Context,
+	usb Hotplug,
+	phone Handle,
+	cfg *Config,
+) (productID uint16, err error) {
+	arrived := make(chan uint16, 1)
+	notify := func(vendorID, productID uint16, event libusb.HotPlugEventType) {
+		if event != libusb.HotplugArrived || !IsAccessory(vendorID, productID) {
+			return
+		}
+		select {
+		case arrived <- productID:
+		default:
+		}
+	}
+	var registered []uint16
+	defer func() {
+		for _, pid := range registered {
+			if derr := usb.HotplugDeregisterCallback(VendorGoogle, pid); derr != nil {
+				err = errors.Join(err, fmt.Errorf("aoa: deregistering hotplug callback: %w", derr))
+			}
+		}
+	}()
+	for pid := uint16(ProductAccessory); pid <= ProductAccessoryAudioADB; pid++ {
+		if err := usb.HotplugRegisterCallbackEvent(
+			VendorGoogle,
+			pid,
+			libusb.HotplugArrived,
+			notify,
+		); err != nil {
+			return 0, fmt.Errorf("aoa: registering hotplug callback: %w", err)
+		}
+		registered = append(registered, pid)
+	}
+	if _, err := Start(phone, cfg); err != nil {
+		return 0, err
+	}
+	select {
+	case productID = <-arrived:
+		return productID, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("aoa: waiting for the accessory: %w", ctx.Err())
+	}
+}
+
+// Connect switches a phone to accessory mode with SwitchToAccessory, then
+// opens the accessory and its accessory interface. Closing the Conn
+// closes the accessory's device handle. The phone's handle is of no
+// further use once the phone has switched.
+func Connect(ctx context.Context, usb *libusb.Context, phone Handle, cfg *Config) (*Conn, error) {
+	productID, err := SwitchToAccessory(ctx, usb, phone, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		dev, handle, err := usb.OpenDeviceWithVendorProduct(VendorGoogle, productID)
+		if err == nil {
+			return openAccessory(dev, handle)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf(
+				"aoa: opening accessory %04x:%04x: %w",
+				VendorGoogle,
+				productID,
+				errors.Join(err, ctx.Err()),
+			)
+		case <-time.After(openRetry):
+		}
+	}
+}
+
+// openAccessory opens the accessory interface of a phone in accessory
+// mode, handing the device and its handle to the Conn.
+func openAccessory(dev *libusb.Device, handle *libusb.DeviceHandle) (*Conn, error) {
+	conn, err := func() (*Conn, error) {
+		config, err := dev.ActiveConfigDescriptor()
+		if err != nil {
+			return nil, fmt.Errorf("aoa: reading accessory configuration: %w", err)
+		}
+		iface, err := FindInterface(config)
+		if err != nil {
+			return nil, err
+		}
+		return Open(handle, iface)
+	}()
+	if err != nil {
+		err = errors.Join(err, handle.Close())
+		dev.Close()
+		return nil, err
+	}
+	conn.owned, conn.device = handle, dev
+	return conn, nil
+}
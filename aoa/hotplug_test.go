@@ -0,0 +1,103 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package aoa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+type fakeHotplug struct {
+	mu        sync.Mutex
+	callbacks map[uint32]libusb.HotPlugCbFunc
+	calls     []string
+}
+
+func (fhp *fakeHotplug) HotplugRegisterCallbackEvent(
+	vendorID, productID uint16,
+	eventType libusb.HotPlugEventType,
+	cb libusb.HotPlugCbFunc,
+) error {
+	fhp.mu.Lock()
+	defer fhp.mu.Unlock()
+	if fhp.callbacks == nil {
+		fhp.callbacks = map[uint32]libusb.HotPlugCbFunc{}
+	}
+	fhp.callbacks[uint32(vendorID)<<16|uint32(productID)] = cb
+	fhp.calls = append(fhp.calls, fmt.Sprintf("register %04x:%04x", vendorID, productID))
+	return nil
+}
+
+func (fhp *fakeHotplug) HotplugDeregisterCallback(vendorID, productID uint16) error {
+	fhp.mu.Lock()
+	defer fhp.mu.Unlock()
+	delete(fhp.callbacks, uint32(vendorID)<<16|uint32(productID))
+	fhp.calls = append(fhp.calls, fmt.Sprintf("deregister %04x:%04x", vendorID, productID))
+	return nil
+}
+
+// arrive runs the callback registered for a device, as libusb does when
+// the device arrives.
+func (fhp *fakeHotplug) arrive(vendorID, productID uint16) {
+	fhp.mu.Lock()
+	cb := fhp.callbacks[uint32(vendorID)<<16|uint32(productID)]
+	fhp.mu.Unlock()
+	if cb != nil {
+		cb(vendorID, productID, libusb.HotplugArrived)
+	}
+}
+
+func TestSwitchToAccessory(t *testing.T) {
+	hp := &fakeHotplug{}
+	fh := &fakeHandle{protocol: 2}
+	fh.onStart = func() {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			hp.arrive(VendorGoogle, ProductAccessoryADB)
+		}()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := &Config{Manufacturer: "Example", Model: "Dock"}
+	productID, err := SwitchToAccessory(ctx, hp, fh, cfg)
+	if err != nil || productID != ProductAccessoryADB {
+		t.Fatalf("SwitchToAccessory = %04x, %v", productID, err)
+	}
+	if len(hp.calls) != 12 || hp.calls[0] != "register 18d1:2d00" ||
+		hp.calls[11] != "deregister 18d1:2d05" {
+		t.Errorf("hotplug calls = %q", hp.calls)
+	}
+	if len(hp.callbacks) != 0 {
+		t.Errorf("%d callbacks left registered", len(hp.callbacks))
+	}
+}
+
+func TestSwitchToAccessoryErrors(t *testing.T) {
+	hp := &fakeHotplug{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := SwitchToAccessory(ctx, hp, &fakeHandle{protocol: 1}, &Config{Model: "Dock"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("phone that never returns: got %v, want context.DeadlineExceeded", err)
+	}
+
+	hp = &fakeHotplug{}
+	fh := &fakeHandle{}
+	if _, err := SwitchToAccessory(ctx, hp, fh, &Config{Model: "Dock"}); err == nil {
+		t.Error("phone without accessory support: expected error, got nil")
+	}
+	if len(hp.callbacks) != 0 || !slices.Contains(hp.calls, "deregister 18d1:2d00") {
+		t.Errorf("hotplug calls = %q", hp.calls)
+	}
+}
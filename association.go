@@ -0,0 +1,309 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+// #cgo pkg-config: libusb-1.0
+// #include <libusb.h>
+//
+// // go_interface_associations copies up to max interface association
+// // descriptors into buf as raw 8-byte descriptors and returns how many the
+// // configuration has, or a libusb error code. The IAD functions were only
+// // added in libusb 1.0.27 (API version 0x0100010A).
+// static int go_interface_associations(
+// 	libusb_device *dev,
+// 	int active,
+// 	uint8_t config_index,
+// 	uint8_t *buf,
+// 	int max
+// ) {
+// #if defined(LIBUSB_API_VERSION) && (LIBUSB_API_VERSION >= 0x0100010A)
+// 	struct libusb_interface_association_descriptor_array *array;
+// 	int err;
+// 	int i;
+// 	if (active) {
+// 		err = libusb_get_active_interface_association_descriptors(dev, &array);
+// 	} else {
+// 		err = libusb_get_interface_association_descriptors(dev, config_index, &array);
+// 	}
+// 	if (err != LIBUSB_SUCCESS) {
+// 		return err;
+// 	}
+// 	for (i = 0; i < array->length && i < max; i++) {
+// 		const struct libusb_interface_association_descriptor *iad = &array->iad[i];
+// 		uint8_t *out = buf + 8 * i;
+// 		out[0] = iad->bLength;
+// 		out[1] = iad->bDescriptorType;
+// 		out[2] = iad->bFirstInterface;
+// 		out[3] = iad->bInterfaceCount;
+// 		out[4] = iad->bFunctionClass;
+// 		out[5] = iad->bFunctionSubClass;
+// 		out[6] = iad->bFunctionProtocol;
+// 		out[7] = iad->iFunction;
+// 	}
+// 	err = array->length;
+// 	libusb_free_interface_association_descriptors(array);
+// 	return err;
+// #else
+// 	return LIBUSB_ERROR_NOT_SUPPORTED;
+// #endif
+// }
+import "C"
+import (
+	"fmt"
+	"slices"
+	"unsafe"
+)
+
+const (
+	interfaceAssociationSize = 8
+	// A configuration holds at most 255 interfaces, so it can't usefully
+	// have more associations than that.
+	maxInterfaceAssociations = 255
+)
+
+// InterfaceAssociationDescriptor models an interface association descriptor
+// (IAD), which groups consecutive interfaces into a single function.
+type InterfaceAssociationDescriptor struct {
+	Length           int
+	DescriptorType   descriptorType
+	FirstInterface   int
+	InterfaceCount   int
+	FunctionClass    uint8
+	FunctionSubClass uint8
+	FunctionProtocol uint8
+	FunctionIndex    int
+}
+
+// InterfaceAssociationDescriptors contains a slice of pointers to the
+// interface association descriptors of a configuration.
+type InterfaceAssociationDescriptors []*InterfaceAssociationDescriptor
+
+// Function models a device function: either the interfaces grouped by an
+// interface association descriptor or a single interface that isn't part of
+// any association. The class triple of an ungrouped interface comes from its
+// first alternate setting.
+type Function struct {
+	FirstInterface int
+	InterfaceCount int
+	Class          uint8
+	SubClass       uint8
+	Protocol       uint8
+	// Association is the IAD that defines the function, or nil for a
+	// standalone interface.
+	Association *InterfaceAssociationDescriptor
+	// Interfaces holds the supported interfaces that make up the function.
+	Interfaces SupportedInterfaces
+}
+
+// InterfaceNumbers returns the interface numbers in the function's range.
+func (fn *Function) InterfaceNumbers() []int {
+	numbers := make([]int, fn.InterfaceCount)
+	for i := range numbers {
+		numbers[i] = fn.FirstInterface + i
+	}
+	return numbers
+}
+
+// Functions groups the configuration's interfaces into functions using its
+// interface association descriptors. Interfaces not covered by an IAD are
+// returned as single-interface functions. The functions are ordered by their
+// first interface number.
+func (cd *ConfigDescriptor) Functions() []*Function {
+	if cd == nil {
+		return nil
+	}
+	byNumber := make(map[int]*SupportedInterface, len(cd.SupportedInterfaces))
+	for _, supportedIface := range cd.SupportedInterfaces {
+		if len(supportedIface.InterfaceDescriptors) > 0 {
+			byNumber[supportedIface.InterfaceDescriptors[0].InterfaceNumber] = supportedIface
+		}
+	}
+	grouped := make(map[int]bool)
+	var functions []*Function
+	for _, iad := range cd.InterfaceAssociations {
+		fn := &Function{
+			FirstInterface: iad.FirstInterface,
+			InterfaceCount: iad.InterfaceCount,
+			Class:          iad.FunctionClass,
+			SubClass:       iad.FunctionSubClass,
+			Protocol:       iad.FunctionProtocol,
+			Association:    iad,
+		}
+		for _, number := range fn.InterfaceNumbers() {
+			grouped[number] = true
+			if supportedIface, ok := byNumber[number]; ok {
+				fn.Interfaces = append(fn.Interfaces, supportedIface)
+			}
+		}
+		functions = append(functions, fn)
+	}
+	for number, supportedIface := range byNumber {
+		if grouped[number] {
+			continue
+		}
+		alt := supportedIface.InterfaceDescriptors[0]
+		functions = append(functions, &Function{
+			FirstInterface: number,
+			InterfaceCount: 1,
+			Class:          alt.InterfaceClass,
+			SubClass:       alt.InterfaceSubClass,
+			Protocol:       alt.InterfaceProtocol,
+			Interfaces:     SupportedInterfaces{supportedIface},
+		})
+	}
+	slices.SortStableFunc(functions, func(a, b *Function) int {
+		return a.FirstInterface - b.FirstInterface
+	})
+	return functions
+}
+
+// ClaimFunction claims every interface of the function. If any claim fails,
+// the interfaces already claimed are released before the error is returned.
+func (dh *DeviceHandle) ClaimFunction(fn *Function) error {
+	if dh == nil || dh.libusbDeviceHandle == nil || fn == nil {
+		return ErrorCode(errorInvalidParam)
+	}
+	numbers := fn.InterfaceNumbers()
+	for i, number := range numbers {
+		if err := dh.ClaimInterface(number); err != nil {
+			for _, claimed := range numbers[:i] {
+				_ = dh.ReleaseInterface(claimed)
+			}
+			return fmt.Errorf("claiming interface %d: %w", number, err)
+		}
+	}
+	return nil
+}
+
+// ReleaseFunction releases every interface of the function, returning the
+// first error encountered.
+func (dh *DeviceHandle) ReleaseFunction(fn *Function) error {
+	if dh == nil || dh.libusbDeviceHandle == nil || fn == nil {
+		return ErrorCode(errorInvalidParam)
+	}
+	var firstErr error
+	for _, number := range fn.InterfaceNumbers() {
+		err := dh.ReleaseInterface(number)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("releasing interface %d: %w", number, err)
+		}
+	}
+	return firstErr
+}
+
+// interfaceAssociations asks libusb for the IADs of a configuration. If
+// libusb predates IAD support or can't provide them, the IADs are recovered
+// from the configuration's extra descriptors instead.
+func (dev *Device) interfaceAssociations(
+	cd *ConfigDescriptor,
+	active bool,
+	configIndex uint8,
+) InterfaceAssociationDescriptors {
+	buf := make([]byte, maxInterfaceAssociations*interfaceAssociationSize)
+	var cActive C.int
+	if active {
+		cActive = 1
+	}
+	n := C.go_interface_associations(
+		dev.libusbDevice,
+		cActive,
+		C.uint8_t(configIndex),
+		(*C.uint8_t)(unsafe.Pointer(&buf[0])),
+		maxInterfaceAssociations,
+	)
+	if n < 0 {
+		return cd.associationsFromExtra()
+	}
+	count := min(int(n), maxInterfaceAssociations)
+	iads := make(InterfaceAssociationDescriptors, 0, count)
+	for i := 0; i < count; i++ {
+		iad, err := parseInterfaceAssociation(buf[i*interfaceAssociationSize:])
+		if err == nil {
+			iads = append(iads, iad)
+		}
+	}
+	return iads
+}
+
+// associationsFromExtra finds the IADs in the configuration's extra
+// descriptors. libusb stores each IAD in the extra bytes of whichever
+// configuration, interface, or endpoint descriptor precedes it, so all of
+// them are searched in the order they appear on the bus.
+func (cd *ConfigDescriptor) associationsFromExtra() InterfaceAssociationDescriptors {
+	extras := [][]byte{cd.Extra}
+	for _, supportedIface := range cd.SupportedInterfaces {
+		for _, ifaceDesc := range supportedIface.InterfaceDescriptors {
+			extras = append(extras, ifaceDesc.Extra)
+			for _, epDesc := range ifaceDesc.EndpointDescriptors {
+				extras = append(extras, epDesc.Extra)
+			}
+		}
+	}
+	var iads InterfaceAssociationDescriptors
+	for _, extra := range extras {
+		descs, _ := SplitDescriptors(extra)
+		for _, desc := range descs {
+			if descriptorType(desc[1]) != descInterfaceAssociation {
+				continue
+			}
+			if iad, err := parseInterfaceAssociation(desc); err == nil {
+				iads = append(iads, iad)
+			}
+		}
+	}
+	return iads
+}
+
+// parseInterfaceAssociation converts a raw interface association descriptor
+// into an InterfaceAssociationDescriptor.
+func parseInterfaceAssociation(data []byte) (*InterfaceAssociationDescriptor, error) {
+	if len(data) < interfaceAssociationSize {
+		return nil, fmt.Errorf(
+			"interface association descriptor is %d bytes; want %d",
+			len(data),
+			interfaceAssociationSize,
+		)
+	}
+	if descriptorType(data[1]) != descInterfaceAssociation {
+		return nil, fmt.Errorf(
+			"descriptor type %#02x is not an interface association",
+			data[1],
+		)
+	}
+	return &InterfaceAssociationDescriptor{
+		Length:           int(data[0]),
+		DescriptorType:   descriptorType(data[1]),
+		FirstInterface:   int(data[2]),
+		InterfaceCount:   int(data[3]),
+		FunctionClass:    data[4],
+		FunctionSubClass: data[5],
+		FunctionProtocol: data[6],
+		FunctionIndex:    int(data[7]),
+	}, nil
+}
+
+// SplitDescriptors splits a run of concatenated descriptors, such as the
+// Extra bytes of a configuration, interface, or endpoint descriptor, into
+// the individual descriptors. Each returned descriptor is at least two bytes
+// long, so desc[0] is its bLength and desc[1] its bDescriptorType. If a
+// descriptor's bLength is too short or runs past the end of the data, the
+// descriptors before it are returned along with an error.
+func SplitDescriptors(data []byte) ([][]byte, error) {
+	var descs [][]byte
+	for offset := 0; offset < len(data); {
+		length := int(data[offset])
+		if length < 2 || offset+length > len(data) {
+			return descs, fmt.Errorf(
+				"descriptor at offset %d has invalid bLength %d",
+				offset,
+				length,
+			)
+		}
+		descs = append(descs, data[offset:offset+length])
+		offset += length
+	}
+	return descs, nil
+}
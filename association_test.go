@@ -0,0 +1,184 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"slices"
+	"testing"
+)
+
+func iadBytes(first, count, class, subClass, protocol byte) []byte {
+	return []byte{
+		interfaceAssociationSize, byte(descInterfaceAssociation),
+		first, count, class, subClass, protocol, 0,
+	}
+}
+
+// compositeConfig models a webcam-style configuration: a video function on
+// interfaces 0-1 and an audio function on interfaces 2-3, each introduced by
+// an IAD, followed by a standalone HID interface 4. As libusb does, the
+// first IAD is in the configuration's extra bytes and the second trails the
+// last descriptor of interface 1.
+func compositeConfig() *ConfigDescriptor {
+	iface := func(number int, class, subClass uint8, extra []byte, eps ...*EndpointDescriptor) *SupportedInterface {
+		return &SupportedInterface{
+			NumAltSettings: 1,
+			InterfaceDescriptors: InterfaceDescriptors{{
+				InterfaceNumber:     number,
+				InterfaceClass:      class,
+				InterfaceSubClass:   subClass,
+				Extra:               extra,
+				EndpointDescriptors: eps,
+			}},
+		}
+	}
+	// A class-specific VS descriptor precedes the second IAD.
+	vsHeader := []byte{0x05, 0x25, 0x01, 0x00, 0x00}
+	return &ConfigDescriptor{
+		Extra: iadBytes(0, 2, InterfaceClassVideo, 0x03, 0x00),
+		SupportedInterfaces: SupportedInterfaces{
+			iface(0, InterfaceClassVideo, 0x01, []byte{0x05, 0x24, 0x01, 0x00, 0x01}),
+			iface(1, InterfaceClassVideo, 0x02, nil, &EndpointDescriptor{
+				EndpointAddress: 0x81,
+				Extra:           append(vsHeader, iadBytes(2, 2, InterfaceClassAudio, 0x00, 0x20)...),
+			}),
+			iface(2, InterfaceClassAudio, 0x01, nil),
+			iface(3, InterfaceClassAudio, 0x02, nil),
+			iface(4, InterfaceClassHID, 0x00, nil),
+		},
+	}
+}
+
+func TestAssociationsFromExtra(t *testing.T) {
+	iads := compositeConfig().associationsFromExtra()
+	if len(iads) != 2 {
+		t.Fatalf("got %d IADs, want 2", len(iads))
+	}
+	want := []InterfaceAssociationDescriptor{
+		{
+			Length:           interfaceAssociationSize,
+			DescriptorType:   descInterfaceAssociation,
+			FirstInterface:   0,
+			InterfaceCount:   2,
+			FunctionClass:    InterfaceClassVideo,
+			FunctionSubClass: 0x03,
+		},
+		{
+			Length:           interfaceAssociationSize,
+			DescriptorType:   descInterfaceAssociation,
+			FirstInterface:   2,
+			InterfaceCount:   2,
+			FunctionClass:    InterfaceClassAudio,
+			FunctionProtocol: 0x20,
+		},
+	}
+	for i, iad := range iads {
+		if *iad != want[i] {
+			t.Errorf("IAD %d = %+v, want %+v", i, *iad, want[i])
+		}
+	}
+}
+
+func TestConfigDescriptorFunctions(t *testing.T) {
+	cd := compositeConfig()
+	cd.InterfaceAssociations = cd.associationsFromExtra()
+	functions := cd.Functions()
+	if len(functions) != 3 {
+		t.Fatalf("got %d functions, want 3", len(functions))
+	}
+	testCases := []struct {
+		class      uint8
+		interfaces []int
+		grouped    bool
+	}{
+		{InterfaceClassVideo, []int{0, 1}, true},
+		{InterfaceClassAudio, []int{2, 3}, true},
+		{InterfaceClassHID, []int{4}, false},
+	}
+	for i, tc := range testCases {
+		fn := functions[i]
+		if fn.Class != tc.class {
+			t.Errorf("function %d Class = %#02x, want %#02x", i, fn.Class, tc.class)
+		}
+		if got := fn.InterfaceNumbers(); !slices.Equal(got, tc.interfaces) {
+			t.Errorf("function %d InterfaceNumbers = %v, want %v", i, got, tc.interfaces)
+		}
+		if (fn.Association != nil) != tc.grouped {
+			t.Errorf("function %d Association = %v, want grouped %v", i, fn.Association, tc.grouped)
+		}
+		if len(fn.Interfaces) != len(tc.interfaces) {
+			t.Errorf("function %d has %d interfaces, want %d", i, len(fn.Interfaces), len(tc.interfaces))
+		}
+	}
+}
+
+func TestConfigDescriptorFunctionsWithoutAssociations(t *testing.T) {
+	cd := compositeConfig()
+	functions := cd.Functions()
+	if len(functions) != 5 {
+		t.Fatalf("got %d functions, want one per interface", len(functions))
+	}
+	for i, fn := range functions {
+		if fn.FirstInterface != i || fn.InterfaceCount != 1 {
+			t.Errorf("function %d covers %v, want [%d]", i, fn.InterfaceNumbers(), i)
+		}
+	}
+	var nilConfig *ConfigDescriptor
+	if functions := nilConfig.Functions(); functions != nil {
+		t.Errorf("nil ConfigDescriptor Functions = %v, want nil", functions)
+	}
+}
+
+func TestParseInterfaceAssociationErrors(t *testing.T) {
+	if _, err := parseInterfaceAssociation(iadBytes(0, 2, 0x0E, 3, 0)[:7]); err == nil {
+		t.Error("short IAD: expected error, got nil")
+	}
+	wrongType := iadBytes(0, 2, 0x0E, 3, 0)
+	wrongType[1] = byte(descInterface)
+	if _, err := parseInterfaceAssociation(wrongType); err == nil {
+		t.Error("wrong descriptor type: expected error, got nil")
+	}
+}
+
+func TestSplitDescriptors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		lengths []int
+		wantErr bool
+	}{
+		{"empty", nil, nil, false},
+		{"two", []byte{0x03, 0x24, 0x00, 0x02, 0x25}, []int{3, 2}, false},
+		{"zero bLength", []byte{0x03, 0x24, 0x00, 0x00, 0x25}, []int{3}, true},
+		{"overrun", []byte{0x03, 0x24, 0x00, 0x05, 0x25}, []int{3}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			descs, err := SplitDescriptors(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SplitDescriptors error = %v, wantErr %v", err, tc.wantErr)
+			}
+			var lengths []int
+			for _, desc := range descs {
+				lengths = append(lengths, len(desc))
+			}
+			if !slices.Equal(lengths, tc.lengths) {
+				t.Errorf("descriptor lengths = %v, want %v", lengths, tc.lengths)
+			}
+		})
+	}
+}
+
+func TestFunctionNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	fn := &Function{FirstInterface: 0, InterfaceCount: 2}
+	if err := dh.ClaimFunction(fn); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("ClaimFunction: got %v, want errorInvalidParam", err)
+	}
+	if err := dh.ReleaseFunction(fn); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("ReleaseFunction: got %v, want errorInvalidParam", err)
+	}
+}
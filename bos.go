@@ -0,0 +1,205 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+// #cgo pkg-config: libusb-1.0
+// #include <libusb.h>
+import "C"
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	bosDescriptorSize      = C.LIBUSB_DT_BOS_SIZE
+	deviceCapabilityHeader = C.LIBUSB_DT_DEVICE_CAPABILITY_SIZE
+)
+
+// DeviceCapabilityType is the bDevCapabilityType of a BOS device capability
+// descriptor.
+type DeviceCapabilityType byte
+
+// Device capability types http://bit.ly/enum_libusb_bos_type
+const (
+	CapabilityWirelessUSB    DeviceCapabilityType = C.LIBUSB_BT_WIRELESS_USB_DEVICE_CAPABILITY
+	CapabilityUSB20Extension DeviceCapabilityType = C.LIBUSB_BT_USB_2_0_EXTENSION
+	CapabilitySuperSpeedUSB  DeviceCapabilityType = C.LIBUSB_BT_SS_USB_DEVICE_CAPABILITY
+	CapabilityContainerID    DeviceCapabilityType = C.LIBUSB_BT_CONTAINER_ID
+	// LIBUSB_BT_PLATFORM_DESCRIPTOR was only added in libusb 1.0.27.
+	CapabilityPlatform DeviceCapabilityType = 0x05
+)
+
+var deviceCapabilityTypes = map[DeviceCapabilityType]string{
+	CapabilityWirelessUSB:    "Wireless USB device capability.",
+	CapabilityUSB20Extension: "USB 2.0 extensions.",
+	CapabilitySuperSpeedUSB:  "SuperSpeed USB device capability.",
+	CapabilityContainerID:    "Container ID.",
+	CapabilityPlatform:       "Platform capability.",
+}
+
+// String implements the Stringer interface for DeviceCapabilityType.
+func (capType DeviceCapabilityType) String() string {
+	return deviceCapabilityTypes[capType]
+}
+
+// BOSDescriptor models the Binary Device Object Store (BOS) descriptor and
+// the device capability descriptors that follow it.
+type BOSDescriptor struct {
+	Length             uint8
+	DescriptorType     descriptorType
+	TotalLength        uint16
+	NumDeviceCaps      uint8
+	DeviceCapabilities []*DeviceCapability
+}
+
+// DeviceCapability models a BOS device capability descriptor. Data holds the
+// capability-specific bytes that follow bDevCapabilityType.
+type DeviceCapability struct {
+	Length         uint8
+	DescriptorType descriptorType
+	CapabilityType DeviceCapabilityType
+	Data           []byte
+}
+
+// PlatformUUID is the 128-bit UUID identifying a platform capability, stored
+// in the little-endian byte order used on the wire.
+type PlatformUUID [16]byte
+
+// String implements the Stringer interface for PlatformUUID, formatting the
+// UUID in its canonical textual form.
+func (uuid PlatformUUID) String() string {
+	return fmt.Sprintf(
+		"%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(uuid[0:4]),
+		binary.LittleEndian.Uint16(uuid[4:6]),
+		binary.LittleEndian.Uint16(uuid[6:8]),
+		uuid[8:10],
+		uuid[10:16],
+	)
+}
+
+// PlatformCapability models a platform device capability descriptor. Data
+// holds the CapabilityData that follows the UUID.
+type PlatformCapability struct {
+	UUID PlatformUUID
+	Data []byte
+}
+
+// Platform returns the platform capability described by a DeviceCapability
+// of type CapabilityPlatform. The second return value is false for other
+// capability types or a malformed descriptor.
+func (devCap *DeviceCapability) Platform() (*PlatformCapability, bool) {
+	// bReserved precedes the 16-byte UUID.
+	const uuidOffset = 1
+	if devCap.CapabilityType != CapabilityPlatform ||
+		len(devCap.Data) < uuidOffset+len(PlatformUUID{}) {
+		return nil, false
+	}
+	platform := &PlatformCapability{
+		Data: devCap.Data[uuidOffset+len(PlatformUUID{}):],
+	}
+	copy(platform.UUID[:], devCap.Data[uuidOffset:])
+	return platform, true
+}
+
+// PlatformCapabilities returns all of the platform capabilities in the BOS
+// descriptor, optionally filtered to those matching the given UUID.
+func (bos *BOSDescriptor) PlatformCapabilities(uuid ...PlatformUUID) []*PlatformCapability {
+	var platforms []*PlatformCapability
+	for _, devCap := range bos.DeviceCapabilities {
+		platform, ok := devCap.Platform()
+		if !ok {
+			continue
+		}
+		if len(uuid) > 0 && !bytes.Equal(platform.UUID[:], uuid[0][:]) {
+			continue
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms
+}
+
+// BOSDescriptor reads the BOS descriptor and its device capabilities from the
+// device. Devices with a bcdUSB below 0x0201 generally stall this request.
+func (dh *DeviceHandle) BOSDescriptor() (*BOSDescriptor, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	header, err := dh.GetDescriptor(descBos, 0, 0, bosDescriptorSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < bosDescriptorSize {
+		return nil, fmt.Errorf(
+			"BOS descriptor header is %d bytes; want %d",
+			len(header),
+			bosDescriptorSize,
+		)
+	}
+	totalLength := int(binary.LittleEndian.Uint16(header[2:4]))
+	if totalLength < bosDescriptorSize {
+		return nil, fmt.Errorf("invalid BOS wTotalLength %d", totalLength)
+	}
+	data, err := dh.GetDescriptor(descBos, 0, 0, totalLength)
+	if err != nil {
+		return nil, err
+	}
+	return parseBOSDescriptor(data)
+}
+
+// parseBOSDescriptor converts the raw BOS descriptor set into a
+// BOSDescriptor.
+func parseBOSDescriptor(data []byte) (*BOSDescriptor, error) {
+	if len(data) < bosDescriptorSize {
+		return nil, fmt.Errorf(
+			"BOS descriptor is %d bytes; want at least %d",
+			len(data),
+			bosDescriptorSize,
+		)
+	}
+	if descriptorType(data[1]) != descBos {
+		return nil, fmt.Errorf("descriptor type %#02x is not a BOS descriptor", data[1])
+	}
+	bos := &BOSDescriptor{
+		Length:         data[0],
+		DescriptorType: descriptorType(data[1]),
+		TotalLength:    binary.LittleEndian.Uint16(data[2:4]),
+		NumDeviceCaps:  data[4],
+	}
+	end := int(bos.TotalLength)
+	if end > len(data) {
+		return nil, fmt.Errorf(
+			"BOS wTotalLength %d exceeds the %d bytes read",
+			end,
+			len(data),
+		)
+	}
+	offset := int(bos.Length)
+	for i := 0; i < int(bos.NumDeviceCaps); i++ {
+		if offset+deviceCapabilityHeader > end {
+			return nil, fmt.Errorf("BOS descriptor truncated at device capability %d", i)
+		}
+		length := int(data[offset])
+		if length < deviceCapabilityHeader || offset+length > end {
+			return nil, fmt.Errorf("device capability %d has invalid bLength %d", i, length)
+		}
+		if descriptorType(data[offset+1]) != descDeviceCapability {
+			return nil, fmt.Errorf(
+				"descriptor type %#02x is not a device capability",
+				data[offset+1],
+			)
+		}
+		bos.DeviceCapabilities = append(bos.DeviceCapabilities, &DeviceCapability{
+			Length:         data[offset],
+			DescriptorType: descriptorType(data[offset+1]),
+			CapabilityType: DeviceCapabilityType(data[offset+2]),
+			Data:           bytes.Clone(data[offset+deviceCapabilityHeader : offset+length]),
+		})
+		offset += length
+	}
+	return bos, nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildBOS assembles a BOS descriptor from raw device capability
+// descriptors.
+func buildBOS(caps ...[]byte) []byte {
+	bos := []byte{bosDescriptorSize, byte(descBos), 0, 0, byte(len(caps))}
+	for _, devCap := range caps {
+		bos = append(bos, devCap...)
+	}
+	bos[2] = byte(len(bos))
+	bos[3] = byte(len(bos) >> 8)
+	return bos
+}
+
+// buildPlatformCapability assembles a platform device capability descriptor.
+func buildPlatformCapability(uuid PlatformUUID, data []byte) []byte {
+	devCap := []byte{0, byte(descDeviceCapability), byte(CapabilityPlatform), 0}
+	devCap = append(devCap, uuid[:]...)
+	devCap = append(devCap, data...)
+	devCap[0] = byte(len(devCap))
+	return devCap
+}
+
+// fakeBOSDevice answers GET_DESCRIPTOR(BOS) requests with bos.
+func fakeBOSDevice(bos []byte) func(setup SetupPacket, data []byte) (int, error) {
+	return func(setup SetupPacket, data []byte) (int, error) {
+		if setup.Request != byte(RequestGetDescriptor) || setup.Value>>8 != uint16(descBos) {
+			return 0, ErrorCode(errorPipe)
+		}
+		return copy(data, bos), nil
+	}
+}
+
+var usb2Extension = []byte{0x07, byte(descDeviceCapability), byte(CapabilityUSB20Extension), 0x06, 0, 0, 0}
+
+func TestParseBOSDescriptor(t *testing.T) {
+	uuid := PlatformUUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	data := buildBOS(usb2Extension, buildPlatformCapability(uuid, []byte{0xAA, 0xBB}))
+	bos, err := parseBOSDescriptor(data)
+	if err != nil {
+		t.Fatalf("parseBOSDescriptor: unexpected error %v", err)
+	}
+	if int(bos.TotalLength) != len(data) {
+		t.Errorf("TotalLength = %d, want %d", bos.TotalLength, len(data))
+	}
+	if bos.NumDeviceCaps != 2 || len(bos.DeviceCapabilities) != 2 {
+		t.Fatalf(
+			"got %d/%d device capabilities, want 2",
+			bos.NumDeviceCaps,
+			len(bos.DeviceCapabilities),
+		)
+	}
+	usb2 := bos.DeviceCapabilities[0]
+	if usb2.CapabilityType != CapabilityUSB20Extension {
+		t.Errorf("CapabilityType = %v, want %v", usb2.CapabilityType, CapabilityUSB20Extension)
+	}
+	if !bytes.Equal(usb2.Data, []byte{0x06, 0, 0, 0}) {
+		t.Errorf("Data = % x, want 06 00 00 00", usb2.Data)
+	}
+	if _, ok := usb2.Platform(); ok {
+		t.Error("Platform: got ok for a USB 2.0 extension")
+	}
+	platform, ok := bos.DeviceCapabilities[1].Platform()
+	if !ok {
+		t.Fatal("Platform: got !ok for a platform capability")
+	}
+	if platform.UUID != uuid {
+		t.Errorf("UUID = %v, want %v", platform.UUID, uuid)
+	}
+	if !bytes.Equal(platform.Data, []byte{0xAA, 0xBB}) {
+		t.Errorf("Data = % x, want aa bb", platform.Data)
+	}
+}
+
+func TestParseBOSDescriptorErrors(t *testing.T) {
+	badType := buildBOS(usb2Extension)
+	badType[6] = byte(descConfig)
+	shortCap := buildBOS([]byte{0x02, byte(descDeviceCapability)})
+	overrun := buildBOS(usb2Extension)
+	overrun[5] = 0x20
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short header", []byte{0x05, 0x0F, 0x05}},
+		{"not BOS", []byte{0x05, 0x02, 0x05, 0x00, 0x00}},
+		{"total exceeds data", []byte{0x05, 0x0F, 0x20, 0x00, 0x00}},
+		{"missing capabilities", []byte{0x05, 0x0F, 0x05, 0x00, 0x02}},
+		{"wrong capability type", badType},
+		{"capability too short", shortCap},
+		{"capability overruns", overrun},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseBOSDescriptor(tc.data); err == nil {
+				t.Error("parseBOSDescriptor: expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestBOSDescriptorReadsTotalLength(t *testing.T) {
+	data := buildBOS(usb2Extension)
+	ft := &fakeTransferer{controlFunc: fakeBOSDevice(data)}
+	bos, err := newFakeDeviceHandle(ft).BOSDescriptor()
+	if err != nil {
+		t.Fatalf("BOSDescriptor: unexpected error %v", err)
+	}
+	if len(bos.DeviceCapabilities) != 1 {
+		t.Errorf("got %d device capabilities, want 1", len(bos.DeviceCapabilities))
+	}
+	if len(ft.transfers) != 2 {
+		t.Fatalf("got %d transfers, want 2", len(ft.transfers))
+	}
+	if got := ft.transfers[0].setup.Length; got != bosDescriptorSize {
+		t.Errorf("header wLength = %d, want %d", got, bosDescriptorSize)
+	}
+	if got := ft.transfers[1].setup.Length; int(got) != len(data) {
+		t.Errorf("full wLength = %d, want %d", got, len(data))
+	}
+}
+
+func TestPlatformCapabilitiesFilter(t *testing.T) {
+	other := PlatformUUID{0xFF}
+	bos, err := parseBOSDescriptor(buildBOS(
+		buildPlatformCapability(other, nil),
+		usb2Extension,
+		buildPlatformCapability(MSOS20PlatformUUID, []byte{1}),
+	))
+	if err != nil {
+		t.Fatalf("parseBOSDescriptor: unexpected error %v", err)
+	}
+	if got := len(bos.PlatformCapabilities()); got != 2 {
+		t.Errorf("PlatformCapabilities() returned %d, want 2", got)
+	}
+	platforms := bos.PlatformCapabilities(MSOS20PlatformUUID)
+	if len(platforms) != 1 || !bytes.Equal(platforms[0].Data, []byte{1}) {
+		t.Errorf("PlatformCapabilities(MSOS20PlatformUUID) = %v", platforms)
+	}
+}
+
+func TestPlatformUUIDString(t *testing.T) {
+	want := "d8dd60df-4589-4cc7-9cd2-659d9e648a9f"
+	if got := MSOS20PlatformUUID.String(); got != want {
+		t.Errorf("MSOS20PlatformUUID.String() = %q, want %q", got, want)
+	}
+}
+
+func TestDeviceCapabilityTypeString(t *testing.T) {
+	testCases := []struct {
+		capType  DeviceCapabilityType
+		expected string
+	}{
+		{CapabilityWirelessUSB, "Wireless USB device capability."},
+		{CapabilityUSB20Extension, "USB 2.0 extensions."},
+		{CapabilitySuperSpeedUSB, "SuperSpeed USB device capability."},
+		{CapabilityContainerID, "Container ID."},
+		{CapabilityPlatform, "Platform capability."},
+	}
+	for _, tc := range testCases {
+		if got := tc.capType.String(); got != tc.expected {
+			t.Errorf("DeviceCapabilityType(%d).String() = %q, want %q", tc.capType, got, tc.expected)
+		}
+	}
+}
+
+func TestBOSDescriptorNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	if _, err := dh.BOSDescriptor(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("BOSDescriptor: got %v, want errorInvalidParam", err)
+	}
+}
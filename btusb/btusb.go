@@ -0,0 +1,185 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package btusb implements the USB transport of the Bluetooth Host Controller
+Interface on top of libusb, so that a Bluetooth stack written in Go can
+drive a USB controller without the kernel's Bluetooth stack.
+
+A Bluetooth controller's first interface has three endpoints: HCI commands
+go to the controller as class requests on the control endpoint, HCI events
+come back on the interrupt endpoint, and ACL data moves on the bulk
+endpoint pair. FindInterfaces returns such interfaces, and Open claims one,
+detaching the kernel's btusb driver, and starts reading events and ACL data
+in the background.
+
+Device is a Channel of HCI packets: ReadPacket returns events and ACL data
+in the order they arrive, and WritePacket sends commands and ACL data. For
+stacks built on an HCI UART or socket, Device's Read and Write carry the
+same packets framed as on a UART, each led by its packet type byte.
+Synchronous SCO data, which uses the isochronous endpoints of the second
+interface, isn't supported.
+*/
+package btusb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds.
+// Only commands and outgoing ACL data are bound by it; events and incoming
+// data are read in the background without a timeout of their own.
+const DefaultTimeout = 1000
+
+// Interface subclass and protocol of Bluetooth controllers.
+const (
+	SubclassRFController = 0x01
+	ProtocolBluetooth    = 0x01
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: HCI commands go
+// out as class requests, events come in on the interrupt endpoint, and ACL
+// data moves over the bulk pair.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Interface is the HCI interface of a Bluetooth controller and its
+// endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	Events     *libusb.EndpointDescriptor
+	ACLIn      *libusb.EndpointDescriptor
+	ACLOut     *libusb.EndpointDescriptor
+}
+
+// FindInterfaces returns the HCI interfaces of the Bluetooth controllers
+// in a configuration. The interfaces that carry SCO data, which have the
+// same class but only isochronous endpoints, are left out.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("btusb: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassWireless,
+	) {
+		if desc.InterfaceSubClass != SubclassRFController ||
+			desc.InterfaceProtocol != ProtocolBluetooth || desc.AlternateSetting != 0 {
+			continue
+		}
+		iface := &Interface{Descriptor: desc}
+		for _, ep := range desc.EndpointDescriptors {
+			in := ep.Direction() == libusb.EndpointIn
+			switch t := ep.TransferType(); {
+			case t == libusb.InterruptTransfer && in && iface.Events == nil:
+				iface.Events = ep
+			case t == libusb.BulkTransfer && in && iface.ACLIn == nil:
+				iface.ACLIn = ep
+			case t == libusb.BulkTransfer && !in && iface.ACLOut == nil:
+				iface.ACLOut = ep
+			}
+		}
+		if iface.Events != nil && iface.ACLIn != nil && iface.ACLOut != nil {
+			found = append(found, iface)
+		}
+	}
+	return found, nil
+}
+
+// Device is a claimed HCI interface. Reads and writes may run
+// concurrently.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the write timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	packets  chan Packet
+	done     chan struct{}
+	readers  sync.WaitGroup
+	failOnce sync.Once
+	failed   chan struct{}
+	readErr  error
+
+	readMu  sync.Mutex
+	pending []byte
+
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open takes an HCI interface from the kernel's btusb driver, if it has
+// it, and starts reading events and ACL data. While the interface is
+// claimed, the controller is gone from the kernel's Bluetooth stack.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("btusb: nil handle or interface")
+	}
+	dev := &Device{
+		handle:    handle,
+		Interface: iface,
+		Timeout:   DefaultTimeout,
+		packets:   make(chan Packet, packetQueue),
+		done:      make(chan struct{}),
+		failed:    make(chan struct{}),
+	}
+	num := iface.Descriptor.InterfaceNumber
+	claims, err := usbif.Claim(handle, "btusb", num)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	dev.readers.Add(2)
+	go dev.pump(PacketEvent, iface.Events)
+	go dev.pump(PacketACL, iface.ACLIn)
+	return dev, nil
+}
+
+// Close stops the background reads and releases the interface, handing
+// the controller back to the kernel's btusb driver if Open took it.
+// Calling Close again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	close(dev.done)
+	dev.mu.Unlock()
+	dev.readers.Wait()
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package btusb
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// events are returned one per interrupt transfer and replies one per
+	// bulk IN transfer; once they run out, reads time out, or fail with
+	// readErr if it's set.
+	events  [][]byte
+	replies [][]byte
+	readErr error
+	// written collects each bulk OUT transfer and commands the data of
+	// each control OUT request.
+	written  [][]byte
+	commands [][]byte
+	// onCommand, if set, is called with each command and returns the
+	// events to queue.
+	onCommand func(cmd []byte) [][]byte
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.Record("control out %#02x %d", request, index)
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	fh.commands = append(fh.commands, slices.Clone(data))
+	if fh.onCommand != nil {
+		fh.events = append(fh.events, fh.onCommand(data)...)
+	}
+	return len(data), nil
+}
+
+// next returns the next of queue, sleeping a little before timing out so
+// the background reads don't spin.
+func (fh *fakeHandle) next(queue *[][]byte, data []byte) (int, error) {
+	fh.mu.Lock()
+	if len(*queue) == 0 {
+		err := fh.readErr
+		fh.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		time.Sleep(time.Millisecond)
+		return 0, libusb.ErrTimeout
+	}
+	defer fh.mu.Unlock()
+	p := (*queue)[0]
+	*queue = (*queue)[1:]
+	return copy(data, p), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.mu.Lock()
+		defer fh.mu.Unlock()
+		fh.written = append(fh.written, slices.Clone(data[:length]))
+		return length, nil
+	}
+	return fh.next(&fh.replies, data[:length])
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	return fh.next(&fh.events, data[:length])
+}
+
+// testConfig returns a configuration whose interface 0 is an HCI
+// interface with the interrupt endpoint 0x81 and the bulk endpoints 0x82
+// and 0x02, and whose interface 1 carries SCO data on isochronous
+// endpoints.
+func testConfig() *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:    libusb.InterfaceClassWireless,
+				InterfaceSubClass: SubclassRFController,
+				InterfaceProtocol: ProtocolBluetooth,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x81, Attributes: 0x03, MaxPacketSize: 16},
+					{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 64},
+					{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 64},
+				},
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:   1,
+				InterfaceClass:    libusb.InterfaceClassWireless,
+				InterfaceSubClass: SubclassRFController,
+				InterfaceProtocol: ProtocolBluetooth,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x83, Attributes: 0x01, MaxPacketSize: 0},
+					{EndpointAddress: 0x03, Attributes: 0x01, MaxPacketSize: 0},
+				},
+			}}},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil || len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.Events.EndpointAddress != 0x81 || iface.ACLIn.EndpointAddress != 0x82 ||
+		iface.ACLOut.EndpointAddress != 0x02 {
+		t.Errorf("interface = %+v", iface)
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	dev := openTestDevice(t, fh)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.ReadPacket(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ReadPacket after Close: got %v, want os.ErrClosed", err)
+	}
+	p := Packet{Type: PacketCommand, Data: []byte{0x03, 0x0C, 0x00}}
+	if err := dev.WritePacket(p); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WritePacket after Close: got %v, want os.ErrClosed", err)
+	}
+}
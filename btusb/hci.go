@@ -0,0 +1,159 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package btusb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Opcodes of common HCI commands.
+const (
+	OpReset            uint16 = 0x0C03
+	OpReadLocalVersion uint16 = 0x1001
+	OpReadBDAddr       uint16 = 0x1009
+)
+
+// Common HCI event codes.
+const (
+	EventDisconnectionComplete    = 0x05
+	EventCommandComplete          = 0x0E
+	EventCommandStatus            = 0x0F
+	EventHardwareError            = 0x10
+	EventNumberOfCompletedPackets = 0x13
+	EventLEMeta                   = 0x3E
+)
+
+// Opcode returns the opcode of the HCI command with an opcode group field
+// and opcode command field.
+func Opcode(ogf uint8, ocf uint16) uint16 {
+	return uint16(ogf)<<10 | ocf&0x3FF
+}
+
+// CommandPacket returns the packet of an HCI command.
+func CommandPacket(opcode uint16, params []byte) (Packet, error) {
+	if len(params) > 0xFF {
+		return Packet{}, fmt.Errorf(
+			"btusb: command %#04x has %d bytes of parameters, the limit is 255",
+			opcode,
+			len(params),
+		)
+	}
+	data := binary.LittleEndian.AppendUint16(nil, opcode)
+	data = append(data, byte(len(params)))
+	return Packet{Type: PacketCommand, Data: append(data, params...)}, nil
+}
+
+// Event is an HCI event.
+type Event struct {
+	Code   uint8
+	Params []byte
+}
+
+// ParseEvent parses an event packet.
+func ParseEvent(p Packet) (*Event, error) {
+	if p.Type != PacketEvent {
+		return nil, fmt.Errorf("btusb: got %s packet, want event", p.Type)
+	}
+	size, ok := packetSize(PacketEvent, p.Data)
+	if !ok || size != len(p.Data) {
+		return nil, fmt.Errorf("btusb: malformed event packet % x", p.Data)
+	}
+	return &Event{Code: p.Data[0], Params: p.Data[eventHeaderSize:]}, nil
+}
+
+// CommandComplete returns the opcode and return parameters of a Command
+// Complete event, or false if ev is another event.
+func (ev *Event) CommandComplete() (uint16, []byte, bool) {
+	if ev.Code != EventCommandComplete || len(ev.Params) < 3 {
+		return 0, nil, false
+	}
+	return binary.LittleEndian.Uint16(ev.Params[1:3]), ev.Params[3:], true
+}
+
+// CommandStatus returns the opcode and status of a Command Status event,
+// or false if ev is another event.
+func (ev *Event) CommandStatus() (uint16, uint8, bool) {
+	if ev.Code != EventCommandStatus || len(ev.Params) < 4 {
+		return 0, 0, false
+	}
+	return binary.LittleEndian.Uint16(ev.Params[2:4]), ev.Params[0], true
+}
+
+var statusNames = map[uint8]string{
+	0x01: "unknown HCI command",
+	0x02: "unknown connection identifier",
+	0x03: "hardware failure",
+	0x07: "memory capacity exceeded",
+	0x0C: "command disallowed",
+	0x11: "unsupported feature or parameter value",
+	0x12: "invalid HCI command parameters",
+	0x1F: "unspecified error",
+}
+
+// StatusError is the error status a controller returned for a command.
+type StatusError struct {
+	Opcode uint16
+	Status uint8
+}
+
+// Error implements the error interface for StatusError.
+func (e *StatusError) Error() string {
+	name, ok := statusNames[e.Status]
+	if !ok {
+		name = fmt.Sprintf("status %#02x", e.Status)
+	}
+	return fmt.Sprintf("btusb: command %#04x failed: %s", e.Opcode, name)
+}
+
+// Command sends an HCI command and waits for the controller to complete
+// it, returning the return parameters of its Command Complete event
+// after the status byte. A command the controller only acknowledges with
+// a Command Status event returns no parameters. The packets read while
+// waiting are discarded, so Command is meant for setting up a controller
+// before a host stack reads from the device. It waits for Timeout
+// milliseconds, or forever if Timeout is zero.
+func (dev *Device) Command(opcode uint16, params []byte) ([]byte, error) {
+	p, err := CommandPacket(opcode, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := dev.WritePacket(p); err != nil {
+		return nil, err
+	}
+	var deadline <-chan time.Time
+	if dev.Timeout > 0 {
+		timer := time.NewTimer(time.Duration(dev.Timeout) * time.Millisecond)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		p, err := dev.readPacket(deadline)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := ParseEvent(p)
+		if err != nil {
+			continue
+		}
+		if op, ret, ok := ev.CommandComplete(); ok && op == opcode {
+			if len(ret) > 0 && ret[0] != 0 {
+				return nil, &StatusError{Opcode: opcode, Status: ret[0]}
+			}
+			if len(ret) == 0 {
+				return nil, nil
+			}
+			return ret[1:], nil
+		}
+		if op, status, ok := ev.CommandStatus(); ok && op == opcode {
+			if status != 0 {
+				return nil, &StatusError{Opcode: opcode, Status: status}
+			}
+			return nil, nil
+		}
+	}
+}
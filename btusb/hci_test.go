@@ -0,0 +1,123 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package btusb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// complete returns a Command Complete event for opcode with return
+// parameters.
+func complete(opcode uint16, ret ...byte) []byte {
+	params := binary.LittleEndian.AppendUint16([]byte{0x01}, opcode)
+	params = append(params, ret...)
+	return append([]byte{EventCommandComplete, byte(len(params))}, params...)
+}
+
+func TestOpcode(t *testing.T) {
+	if got := Opcode(0x03, 0x003); got != OpReset {
+		t.Errorf("Opcode(0x03, 0x003) = %#04x, want %#04x", got, OpReset)
+	}
+	if got := Opcode(0x08, 0x00C); got != 0x200C {
+		t.Errorf("Opcode(0x08, 0x00C) = %#04x, want 0x200c", got)
+	}
+}
+
+func TestCommandPacket(t *testing.T) {
+	p, err := CommandPacket(0x200C, []byte{0x01, 0x00})
+	if err != nil {
+		t.Fatalf("CommandPacket: unexpected error %v", err)
+	}
+	want := []byte{0x0C, 0x20, 0x02, 0x01, 0x00}
+	if p.Type != PacketCommand || !bytes.Equal(p.Data, want) {
+		t.Errorf("CommandPacket = %v % x, want % x", p.Type, p.Data, want)
+	}
+	if _, err := CommandPacket(0x200C, make([]byte, 256)); err == nil {
+		t.Error("256 bytes of parameters: expected error, got nil")
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	ev, err := ParseEvent(Packet{Type: PacketEvent, Data: complete(OpReset, 0x00)})
+	if err != nil {
+		t.Fatalf("ParseEvent: unexpected error %v", err)
+	}
+	op, ret, ok := ev.CommandComplete()
+	if !ok || op != OpReset || !bytes.Equal(ret, []byte{0x00}) {
+		t.Errorf("CommandComplete = %#04x, % x, %t", op, ret, ok)
+	}
+	if _, _, ok := ev.CommandStatus(); ok {
+		t.Error("CommandStatus of a Command Complete event: got ok")
+	}
+	status := []byte{EventCommandStatus, 0x04, 0x0C, 0x01, 0x0D, 0x20}
+	ev, err = ParseEvent(Packet{Type: PacketEvent, Data: status})
+	if err != nil {
+		t.Fatalf("ParseEvent: unexpected error %v", err)
+	}
+	if op, st, ok := ev.CommandStatus(); !ok || op != 0x200D || st != 0x0C {
+		t.Errorf("CommandStatus = %#04x, %#02x, %t", op, st, ok)
+	}
+
+	testCases := []struct {
+		name string
+		p    Packet
+	}{
+		{"ACL data", Packet{Type: PacketACL, Data: []byte{0, 0, 0, 0}}},
+		{"no header", Packet{Type: PacketEvent, Data: []byte{0x0E}}},
+		{"length mismatch", Packet{Type: PacketEvent, Data: []byte{0x0E, 0x02, 0x01}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseEvent(tc.p); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	addr := []byte{0x66, 0x55, 0x44, 0x33, 0x22, 0x11}
+	fh := &fakeHandle{onCommand: func(cmd []byte) [][]byte {
+		switch binary.LittleEndian.Uint16(cmd) {
+		case OpReadBDAddr:
+			// An unrelated event comes first and is skipped.
+			return [][]byte{
+				{EventNumberOfCompletedPackets, 0x01, 0x00},
+				complete(OpReadBDAddr, append([]byte{0x00}, addr...)...),
+			}
+		case OpReset:
+			return [][]byte{complete(OpReset, 0x0C)}
+		case 0x200D:
+			return [][]byte{{EventCommandStatus, 0x04, 0x00, 0x01, 0x0D, 0x20}}
+		}
+		return nil
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	dev.Timeout = 100
+
+	ret, err := dev.Command(OpReadBDAddr, nil)
+	if err != nil || !bytes.Equal(ret, addr) {
+		t.Errorf("Command(ReadBDAddr) = % x, %v", ret, err)
+	}
+	var statusErr *StatusError
+	_, err = dev.Command(OpReset, nil)
+	if !errors.As(err, &statusErr) || statusErr.Status != 0x0C {
+		t.Errorf("Command(Reset): got %v, want a *StatusError", err)
+	}
+	if got, want := err.Error(), "btusb: command 0x0c03 failed: command disallowed"; got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+	if ret, err := dev.Command(0x200D, make([]byte, 25)); err != nil || ret != nil {
+		t.Errorf("Command(LE Create Connection) = % x, %v", ret, err)
+	}
+	if _, err := dev.Command(OpReadLocalVersion, nil); err == nil {
+		t.Error("Command with no reply: expected error, got nil")
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package btusb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// requestCommand is the class request that carries an HCI command.
+const requestCommand = 0x00
+
+const (
+	// readPoll is the timeout in milliseconds of each background read, and
+	// so the longest Close waits for the readers to stop.
+	readPoll = 250
+	// packetQueue is the number of packets read ahead of ReadPacket.
+	packetQueue = 64
+
+	commandHeaderSize = 3
+	aclHeaderSize     = 4
+	eventHeaderSize   = 2
+)
+
+// PacketType is the HCI packet type, the byte that leads each packet on an
+// HCI UART.
+type PacketType uint8
+
+// HCI packet types.
+const (
+	PacketCommand PacketType = 0x01
+	PacketACL     PacketType = 0x02
+	PacketSCO     PacketType = 0x03
+	PacketEvent   PacketType = 0x04
+)
+
+var packetTypes = map[PacketType]string{
+	PacketCommand: "command",
+	PacketACL:     "ACL data",
+	PacketSCO:     "SCO data",
+	PacketEvent:   "event",
+}
+
+// String implements the Stringer interface for PacketType.
+func (t PacketType) String() string {
+	if name, ok := packetTypes[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("packet type %#02x", uint8(t))
+}
+
+// Packet is an HCI packet. Data holds the packet from its header on,
+// without the packet type byte.
+type Packet struct {
+	Type PacketType
+	Data []byte
+}
+
+// Channel is a bidirectional channel of HCI packets, the interface a host
+// stack needs from a controller's transport.
+type Channel interface {
+	// ReadPacket returns the next event or ACL data packet from the
+	// controller, waiting until one arrives.
+	ReadPacket() (Packet, error)
+	// WritePacket sends a command or ACL data packet to the controller.
+	WritePacket(p Packet) error
+	Close() error
+}
+
+// packetSize returns the size of the packet of a type at the start of
+// data, or false if data doesn't hold the packet's header.
+func packetSize(kind PacketType, data []byte) (int, bool) {
+	switch kind {
+	case PacketCommand:
+		if len(data) >= commandHeaderSize {
+			return commandHeaderSize + int(data[2]), true
+		}
+	case PacketACL:
+		if len(data) >= aclHeaderSize {
+			return aclHeaderSize + int(binary.LittleEndian.Uint16(data[2:4])), true
+		}
+	case PacketEvent:
+		if len(data) >= eventHeaderSize {
+			return eventHeaderSize + int(data[1]), true
+		}
+	}
+	return 0, false
+}
+
+// pump reads packets of a type from an endpoint and queues them for
+// ReadPacket until the device is closed or a read fails. The endpoint is
+// read a packet at a time and the packets reassembled from their headers,
+// since the controller doesn't end each HCI packet with a short packet.
+func (dev *Device) pump(kind PacketType, ep *libusb.EndpointDescriptor) {
+	defer dev.readers.Done()
+	transfer := dev.handle.BulkTransfer
+	if kind == PacketEvent {
+		transfer = dev.handle.InterruptTransfer
+	}
+	buf := make([]byte, max(int(ep.MaxPacketSize), 1))
+	var stream []byte
+	for {
+		select {
+		case <-dev.done:
+			return
+		default:
+		}
+		n, err := transfer(ep.EndpointAddress, buf, len(buf), readPoll)
+		stream = append(stream, buf[:n]...)
+		for {
+			size, ok := packetSize(kind, stream)
+			if !ok || len(stream) < size {
+				break
+			}
+			p := Packet{Type: kind, Data: slices.Clone(stream[:size])}
+			stream = stream[size:]
+			select {
+			case dev.packets <- p:
+			case <-dev.done:
+				return
+			}
+		}
+		if err != nil && !usbif.IsTimeout(err) {
+			dev.fail(fmt.Errorf("btusb: reading %s: %w", kind, err))
+			return
+		}
+	}
+}
+
+// fail records the first error of the background reads.
+func (dev *Device) fail(err error) {
+	dev.failOnce.Do(func() {
+		dev.readErr = err
+		close(dev.failed)
+	})
+}
+
+// ReadPacket returns the next event or ACL data packet from the
+// controller, waiting until one arrives. Once a background read fails,
+// ReadPacket returns the queued packets and then the error.
+func (dev *Device) ReadPacket() (Packet, error) {
+	return dev.readPacket(nil)
+}
+
+// readPacket is ReadPacket that gives up when deadline fires.
+func (dev *Device) readPacket(deadline <-chan time.Time) (Packet, error) {
+	select {
+	case p := <-dev.packets:
+		return p, nil
+	default:
+	}
+	select {
+	case p := <-dev.packets:
+		return p, nil
+	case <-dev.failed:
+		return Packet{}, dev.readErr
+	case <-dev.done:
+		return Packet{}, os.ErrClosed
+	case <-deadline:
+		return Packet{}, fmt.Errorf("btusb: timed out waiting for a packet")
+	}
+}
+
+// WritePacket sends a command or ACL data packet to the controller.
+func (dev *Device) WritePacket(p Packet) error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	size, ok := packetSize(p.Type, p.Data)
+	switch {
+	case p.Type != PacketCommand && p.Type != PacketACL:
+		return fmt.Errorf("btusb: can't write %s packets", p.Type)
+	case !ok || size != len(p.Data):
+		return fmt.Errorf(
+			"btusb: %s packet is %d bytes, its header gives %d",
+			p.Type,
+			len(p.Data),
+			size,
+		)
+	}
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+	var n int
+	var err error
+	if p.Type == PacketCommand {
+		n, err = dev.handle.ControlOut(
+			libusb.Class,
+			libusb.DeviceRecipient,
+			requestCommand,
+			0,
+			0,
+			p.Data,
+			dev.Timeout,
+		)
+	} else {
+		n, err = dev.handle.BulkTransfer(
+			dev.Interface.ACLOut.EndpointAddress,
+			p.Data,
+			len(p.Data),
+			dev.Timeout,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("btusb: writing %s: %w", p.Type, err)
+	}
+	if n != len(p.Data) {
+		return fmt.Errorf("btusb: wrote %d of %d bytes of %s", n, len(p.Data), p.Type)
+	}
+	return nil
+}
+
+// Read implements the io.Reader interface, returning the packets of
+// ReadPacket framed as on an HCI UART, each led by its packet type byte.
+// A packet larger than b is returned over several calls.
+func (dev *Device) Read(b []byte) (int, error) {
+	dev.readMu.Lock()
+	defer dev.readMu.Unlock()
+	if len(dev.pending) == 0 {
+		p, err := dev.ReadPacket()
+		if err != nil {
+			return 0, err
+		}
+		dev.pending = append([]byte{byte(p.Type)}, p.Data...)
+	}
+	n := copy(b, dev.pending)
+	dev.pending = dev.pending[n:]
+	return n, nil
+}
+
+// Write implements the io.Writer interface for command and ACL data
+// packets framed as on an HCI UART, each led by its packet type byte. b
+// must hold whole packets.
+func (dev *Device) Write(b []byte) (int, error) {
+	var written int
+	for written < len(b) {
+		kind := PacketType(b[written])
+		if kind != PacketCommand && kind != PacketACL {
+			return written, fmt.Errorf("btusb: can't write %s packets", kind)
+		}
+		size, ok := packetSize(kind, b[written+1:])
+		if !ok || written+1+size > len(b) {
+			return written, fmt.Errorf(
+				"btusb: incomplete %s packet at byte %d of %d",
+				kind,
+				written,
+				len(b),
+			)
+		}
+		p := Packet{Type: kind, Data: b[written+1 : written+1+size]}
+		if err := dev.WritePacket(p); err != nil {
+			return written, err
+		}
+		written += 1 + size
+	}
+	return written, nil
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package btusb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestReadPacket(t *testing.T) {
+	long := append([]byte{0x3E, 20}, bytes.Repeat([]byte{0xEE}, 20)...)
+	acl := append([]byte{0x40, 0x20, 100, 0}, bytes.Repeat([]byte{0xAA}, 100)...)
+	fh := &fakeHandle{
+		// The first event spans two interrupt packets, and the next two
+		// share one.
+		events: [][]byte{
+			long[:16],
+			long[16:],
+			{0x13, 0x01, 0x00, 0x0F, 0x04, 0x00, 0x01, 0x03, 0x0C},
+		},
+		replies: [][]byte{acl[:64], acl[64:]},
+	}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	var events, data [][]byte
+	for i := 0; i < 4; i++ {
+		p, err := dev.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket: unexpected error %v", err)
+		}
+		switch p.Type {
+		case PacketEvent:
+			events = append(events, p.Data)
+		case PacketACL:
+			data = append(data, p.Data)
+		default:
+			t.Fatalf("ReadPacket returned a %s packet", p.Type)
+		}
+	}
+	wantEvents := [][]byte{long, {0x13, 0x01, 0x00}, {0x0F, 0x04, 0x00, 0x01, 0x03, 0x0C}}
+	if !slices.EqualFunc(events, wantEvents, bytes.Equal) {
+		t.Errorf("events = % x, want % x", events, wantEvents)
+	}
+	if len(data) != 1 || !bytes.Equal(data[0], acl) {
+		t.Errorf("ACL data = % x, want % x", data, acl)
+	}
+}
+
+func TestReadPacketError(t *testing.T) {
+	readErr := errors.New("no device")
+	dev := openTestDevice(t, &fakeHandle{readErr: readErr})
+	defer dev.Close()
+	if _, err := dev.ReadPacket(); !errors.Is(err, readErr) {
+		t.Errorf("ReadPacket: got %v, want %v", err, readErr)
+	}
+}
+
+func TestWritePacket(t *testing.T) {
+	fh := &fakeHandle{}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	cmd := []byte{0x03, 0x0C, 0x00}
+	if err := dev.WritePacket(Packet{Type: PacketCommand, Data: cmd}); err != nil {
+		t.Fatalf("WritePacket(command): unexpected error %v", err)
+	}
+	acl := []byte{0x40, 0x00, 0x02, 0x00, 0x01, 0x02}
+	if err := dev.WritePacket(Packet{Type: PacketACL, Data: acl}); err != nil {
+		t.Fatalf("WritePacket(ACL data): unexpected error %v", err)
+	}
+	if len(fh.commands) != 1 || !bytes.Equal(fh.commands[0], cmd) ||
+		!slices.Contains(fh.Calls(), "control out 0x00 0") {
+		t.Errorf("commands = % x, calls %q", fh.commands, fh.Calls())
+	}
+	if len(fh.written) != 1 || !bytes.Equal(fh.written[0], acl) {
+		t.Errorf("written = % x, want % x", fh.written, acl)
+	}
+
+	testCases := []struct {
+		name string
+		p    Packet
+	}{
+		{"event", Packet{Type: PacketEvent, Data: []byte{0x0E, 0x00}}},
+		{"SCO data", Packet{Type: PacketSCO, Data: []byte{0x01, 0x00, 0x00}}},
+		{"short command", Packet{Type: PacketCommand, Data: []byte{0x03, 0x0C, 0x01}}},
+		{"no header", Packet{Type: PacketACL, Data: []byte{0x40}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := dev.WritePacket(tc.p); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	fh := &fakeHandle{events: [][]byte{{0x0E, 0x04, 0x01, 0x03, 0x0C, 0x00}}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	uart := []byte{0x01, 0x03, 0x0C, 0x00, 0x02, 0x40, 0x00, 0x01, 0x00, 0xFF}
+	if n, err := dev.Write(uart); err != nil || n != len(uart) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if len(fh.commands) != 1 || len(fh.written) != 1 {
+		t.Errorf("wrote %d commands and %d ACL packets, want 1 and 1",
+			len(fh.commands), len(fh.written))
+	}
+	if n, err := dev.Write([]byte{0x01, 0x03, 0x0C, 0x01}); err == nil || n != 0 {
+		t.Errorf("Write of a partial command = %d, %v", n, err)
+	}
+	if _, err := dev.Write([]byte{0x04, 0x0E, 0x00}); err == nil {
+		t.Error("Write of an event: expected error, got nil")
+	}
+
+	got := make([]byte, 7)
+	if _, err := io.ReadFull(dev, got[:4]); err != nil {
+		t.Fatalf("Read: unexpected error %v", err)
+	}
+	if _, err := io.ReadFull(dev, got[4:]); err != nil {
+		t.Fatalf("Read: unexpected error %v", err)
+	}
+	want := []byte{0x04, 0x0E, 0x04, 0x01, 0x03, 0x0C, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Read = % x, want % x", got, want)
+	}
+}
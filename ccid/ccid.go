@@ -0,0 +1,167 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package ccid implements USB smart card readers, the Chip/Smart Card
+Interface Devices class, on top of libusb.
+
+A CCID interface carries a class descriptor that tells how many slots the
+reader has, which protocols and voltages it supports, and whether it
+exchanges characters, TPDUs or whole APDUs with the card. FindInterfaces
+parses it, and Open claims the interface, detaching a kernel driver if one
+is bound.
+
+Commands go to the reader as PC_to_RDR messages on the bulk OUT endpoint and
+come back as RDR_to_PC messages on the bulk IN endpoint, matched by a
+sequence number. Reader numbers the messages, waits through time extension
+requests from the reader, and returns a *SlotError when a command fails.
+PowerOn and PowerOff send IccPowerOn and IccPowerOff, SlotStatus sends
+GetSlotStatus, and XfrBlock, SetParameters and GetParameters send the
+commands of the same names. Transmit sends an APDU with XfrBlock, split
+into the chained blocks an extended APDU level reader needs.
+
+Readers with an interrupt endpoint report cards being inserted and removed
+with NotifySlotChange messages, which WatchSlots passes to a callback.
+*/
+package ccid
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Reader.Timeout that Open sets, in milliseconds. A
+// reader that needs longer asks for time extensions, each of which
+// restarts the timeout.
+const DefaultTimeout = 5000
+
+// Handle is what a Reader needs of a *libusb.DeviceHandle: the bulk pair
+// carrying CCID messages and the interrupt endpoint for slot changes.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Interface is a CCID interface with its class descriptor and endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	CCID       *Descriptor
+	BulkIn     *libusb.EndpointDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+	// Interrupt is nil if the reader has no interrupt endpoint, in which
+	// case it doesn't report slot changes.
+	Interrupt *libusb.EndpointDescriptor
+}
+
+// FindInterfaces returns the CCID interfaces of a configuration. Some
+// readers made before the class was final put the class descriptor after
+// the endpoint descriptors, so the endpoints' extra bytes are searched too.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("ccid: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassSmartCard,
+	) {
+		extra := desc.Extra
+		for _, ep := range desc.EndpointDescriptors {
+			extra = append(extra[:len(extra):len(extra)], ep.Extra...)
+		}
+		ccid, err := ParseDescriptor(extra)
+		if err != nil {
+			return nil, err
+		}
+		iface := &Interface{Descriptor: desc, CCID: ccid}
+		for _, ep := range desc.EndpointDescriptors {
+			in := ep.Direction() == libusb.EndpointIn
+			switch t := ep.TransferType(); {
+			case t == libusb.BulkTransfer && in:
+				iface.BulkIn = ep
+			case t == libusb.BulkTransfer && !in:
+				iface.BulkOut = ep
+			case t == libusb.InterruptTransfer && in:
+				iface.Interrupt = ep
+			}
+		}
+		if iface.BulkIn == nil || iface.BulkOut == nil {
+			return nil, fmt.Errorf(
+				"ccid: interface %d lacks a bulk endpoint pair",
+				desc.InterfaceNumber,
+			)
+		}
+		found = append(found, iface)
+	}
+	return found, nil
+}
+
+// Reader is a claimed CCID interface. Commands are serialized, so a Reader
+// may be used from several goroutines, and WatchSlots may run alongside
+// them.
+type Reader struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	cmdMu sync.Mutex
+	seq   uint8
+
+	mu       sync.Mutex
+	closed   bool
+	claims   *usbif.Claims
+	watchers sync.WaitGroup
+}
+
+// Open claims a CCID interface, detaching a kernel driver if one is bound.
+// Usually none is, as pcscd drives readers through libusb too.
+func Open(handle Handle, iface *Interface) (*Reader, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil || iface.CCID == nil {
+		return nil, fmt.Errorf("ccid: nil handle or interface")
+	}
+	r := &Reader{handle: handle, Interface: iface, Timeout: DefaultTimeout}
+	num := iface.Descriptor.InterfaceNumber
+	claims, err := usbif.Claim(handle, "ccid", num)
+	if err != nil {
+		return nil, err
+	}
+	r.claims = claims
+	return r, nil
+}
+
+// Close waits for WatchSlots to return and releases the interface. Cards
+// stay powered as they are. Calling Close again returns os.ErrClosed.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return os.ErrClosed
+	}
+	r.closed = true
+	r.mu.Unlock()
+	r.watchers.Wait()
+	return r.claims.Release()
+}
+
+func (r *Reader) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Slots returns the number of slots of the reader.
+func (r *Reader) Slots() int {
+	return int(r.Interface.CCID.MaxSlotIndex) + 1
+}
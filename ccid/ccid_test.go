@@ -0,0 +1,166 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// replies are returned one per bulk IN transfer; once they run out,
+	// reads time out.
+	replies [][]byte
+	// written collects each bulk OUT transfer.
+	written [][]byte
+	// interrupts are returned one per interrupt transfer; once they run
+	// out, reads time out.
+	interrupts [][]byte
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, slices.Clone(data[:length]))
+		return length, nil
+	}
+	if len(fh.replies) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.replies[0]
+	fh.replies = fh.replies[1:]
+	return copy(data[:length], p), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if len(fh.interrupts) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.interrupts[0]
+	fh.interrupts = fh.interrupts[1:]
+	return copy(data[:length], p), nil
+}
+
+// reply returns an RDR_to_PC message.
+func reply(msgType, slot, seq, status, code, param byte, data ...byte) []byte {
+	msg := make([]byte, headerSize, headerSize+len(data))
+	msg[0] = msgType
+	binary.LittleEndian.PutUint32(msg[1:5], uint32(len(data)))
+	msg[5], msg[6], msg[7], msg[8], msg[9] = slot, seq, status, code, param
+	return append(msg, data...)
+}
+
+// testConfig returns a configuration whose interface 0 is a two-slot
+// reader with the bulk endpoints 0x02 and 0x82 and the interrupt endpoint
+// 0x83.
+func testConfig(features Features, maxMessage uint32) *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass: libusb.InterfaceClassSmartCard,
+				Extra:          testDescriptor(features, maxMessage),
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 64},
+					{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 64},
+					{EndpointAddress: 0x83, Attributes: 0x03, MaxPacketSize: 8},
+				},
+			}}},
+		},
+	}
+}
+
+func openTestReader(t *testing.T, fh *fakeHandle, features Features, maxMessage uint32) *Reader {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig(features, maxMessage))
+	if err != nil || len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+	r, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return r
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig(FeatureShortAPDU, 271))
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.BulkIn.EndpointAddress != 0x82 || iface.BulkOut.EndpointAddress != 0x02 ||
+		iface.Interrupt.EndpointAddress != 0x83 || iface.CCID.MaxSlotIndex != 1 {
+		t.Errorf("interface = %+v", iface)
+	}
+
+	// A descriptor after the endpoint descriptors is found too.
+	config := testConfig(FeatureShortAPDU, 271)
+	desc := config.SupportedInterfaces[0].InterfaceDescriptors[0]
+	desc.EndpointDescriptors[2].Extra, desc.Extra = desc.Extra, nil
+	if ifaces, err := FindInterfaces(config); err != nil || len(ifaces) != 1 {
+		t.Errorf("descriptor after endpoints: FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+
+	config = testConfig(FeatureShortAPDU, 271)
+	desc = config.SupportedInterfaces[0].InterfaceDescriptors[0]
+	desc.EndpointDescriptors = desc.EndpointDescriptors[1:]
+	if _, err := FindInterfaces(config); err == nil {
+		t.Error("no bulk OUT endpoint: expected error, got nil")
+	}
+	config.SupportedInterfaces[0].InterfaceDescriptors[0].Extra = nil
+	if _, err := FindInterfaces(config); err == nil {
+		t.Error("no class descriptor: expected error, got nil")
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+	if r.Slots() != 2 {
+		t.Errorf("Slots = %d, want 2", r.Slots())
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := r.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := r.PowerOn(0, VoltageAuto); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("PowerOn after Close: got %v, want os.ErrClosed", err)
+	}
+}
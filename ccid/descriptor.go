@@ -0,0 +1,171 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// descriptorTypeCCID is the type of the smart card class descriptor.
+const descriptorTypeCCID = 0x21
+
+// descriptorLength is the length of the smart card class descriptor.
+const descriptorLength = 54
+
+// Features is the dwFeatures bitmap of the class descriptor, which tells
+// what the reader does on its own and at which level it exchanges data
+// with the card.
+type Features uint32
+
+// Feature bits.
+const (
+	FeatureAutoConfiguration Features = 0x00000002
+	FeatureAutoActivation    Features = 0x00000004
+	FeatureAutoVoltage       Features = 0x00000008
+	FeatureAutoClock         Features = 0x00000010
+	FeatureAutoBaudRate      Features = 0x00000020
+	FeatureAutoParameters    Features = 0x00000040
+	FeatureAutoPPS           Features = 0x00000080
+	FeatureClockStop         Features = 0x00000100
+	FeatureNADNotZero        Features = 0x00000200
+	FeatureAutoIFSD          Features = 0x00000400
+	FeatureTPDU              Features = 0x00010000
+	FeatureShortAPDU         Features = 0x00020000
+	FeatureExtendedAPDU      Features = 0x00040000
+	FeatureUSBWakeUp         Features = 0x00100000
+)
+
+var featureNames = []struct {
+	bit  Features
+	name string
+}{
+	{FeatureAutoConfiguration, "AutoConfiguration"},
+	{FeatureAutoActivation, "AutoActivation"},
+	{FeatureAutoVoltage, "AutoVoltage"},
+	{FeatureAutoClock, "AutoClock"},
+	{FeatureAutoBaudRate, "AutoBaudRate"},
+	{FeatureAutoParameters, "AutoParameters"},
+	{FeatureAutoPPS, "AutoPPS"},
+	{FeatureClockStop, "ClockStop"},
+	{FeatureNADNotZero, "NADNotZero"},
+	{FeatureAutoIFSD, "AutoIFSD"},
+	{FeatureTPDU, "TPDU"},
+	{FeatureShortAPDU, "ShortAPDU"},
+	{FeatureExtendedAPDU, "ExtendedAPDU"},
+	{FeatureUSBWakeUp, "USBWakeUp"},
+}
+
+// String lists the set feature bits, such as "AutoVoltage|ShortAPDU".
+func (f Features) String() string {
+	var names []string
+	for _, fn := range featureNames {
+		if f&fn.bit != 0 {
+			names = append(names, fn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// APDULevel reports whether the reader exchanges whole APDUs with XfrBlock,
+// short or extended, rather than TPDUs or characters.
+func (f Features) APDULevel() bool {
+	return f&(FeatureShortAPDU|FeatureExtendedAPDU) != 0
+}
+
+// Protocols is the dwProtocols bitmap of the class descriptor.
+type Protocols uint32
+
+// SupportsT0 reports whether the reader supports the T=0 protocol.
+func (p Protocols) SupportsT0() bool {
+	return p&0x01 != 0
+}
+
+// SupportsT1 reports whether the reader supports the T=1 protocol.
+func (p Protocols) SupportsT1() bool {
+	return p&0x02 != 0
+}
+
+// Descriptor is the smart card device class descriptor of a CCID
+// interface.
+type Descriptor struct {
+	CCIDVersion uint16
+	// MaxSlotIndex is the highest slot number; a single-slot reader has 0.
+	MaxSlotIndex uint8
+	// VoltageSupport has bit 0 set for 5 V, bit 1 for 3 V, and bit 2 for
+	// 1.8 V.
+	VoltageSupport       uint8
+	Protocols            Protocols
+	DefaultClock         uint32
+	MaximumClock         uint32
+	NumClockSupported    uint8
+	DataRate             uint32
+	MaxDataRate          uint32
+	NumDataRateSupported uint8
+	MaxIFSD              uint32
+	SynchProtocols       uint32
+	Mechanical           uint32
+	Features             Features
+	// MaxMessageLength is the largest message, header included, the reader
+	// takes or sends on the bulk endpoints.
+	MaxMessageLength uint32
+	ClassGetResponse uint8
+	ClassEnvelope    uint8
+	LCDLayout        uint16
+	PINSupport       uint8
+	MaxBusySlots     uint8
+}
+
+// ParseDescriptor parses the smart card class descriptor out of the
+// extra bytes of an interface descriptor.
+func ParseDescriptor(extra []byte) (*Descriptor, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("ccid: %w", err)
+	}
+	for _, desc := range descs {
+		if desc[1] != descriptorTypeCCID {
+			continue
+		}
+		if len(desc) < descriptorLength {
+			return nil, fmt.Errorf(
+				"ccid: class descriptor is %d bytes; want %d",
+				len(desc),
+				descriptorLength,
+			)
+		}
+		le := binary.LittleEndian
+		return &Descriptor{
+			CCIDVersion:          le.Uint16(desc[2:4]),
+			MaxSlotIndex:         desc[4],
+			VoltageSupport:       desc[5],
+			Protocols:            Protocols(le.Uint32(desc[6:10])),
+			DefaultClock:         le.Uint32(desc[10:14]),
+			MaximumClock:         le.Uint32(desc[14:18]),
+			NumClockSupported:    desc[18],
+			DataRate:             le.Uint32(desc[19:23]),
+			MaxDataRate:          le.Uint32(desc[23:27]),
+			NumDataRateSupported: desc[27],
+			MaxIFSD:              le.Uint32(desc[28:32]),
+			SynchProtocols:       le.Uint32(desc[32:36]),
+			Mechanical:           le.Uint32(desc[36:40]),
+			Features:             Features(le.Uint32(desc[40:44])),
+			MaxMessageLength:     le.Uint32(desc[44:48]),
+			ClassGetResponse:     desc[48],
+			ClassEnvelope:        desc[49],
+			LCDLayout:            le.Uint16(desc[50:52]),
+			PINSupport:           desc[52],
+			MaxBusySlots:         desc[53],
+		}, nil
+	}
+	return nil, fmt.Errorf("ccid: no smart card class descriptor")
+}
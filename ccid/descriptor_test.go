@@ -0,0 +1,87 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testDescriptor returns the class descriptor of a two-slot reader
+// supporting T=0 and T=1 at the given features and message length.
+func testDescriptor(features Features, maxMessage uint32) []byte {
+	d := make([]byte, descriptorLength)
+	d[0], d[1] = descriptorLength, descriptorTypeCCID
+	le := binary.LittleEndian
+	le.PutUint16(d[2:4], 0x0110)
+	d[4] = 1
+	d[5] = 0x07
+	le.PutUint32(d[6:10], 0x03)
+	le.PutUint32(d[10:14], 4000)
+	le.PutUint32(d[14:18], 12000)
+	le.PutUint32(d[19:23], 10752)
+	le.PutUint32(d[23:27], 344086)
+	le.PutUint32(d[28:32], 254)
+	le.PutUint32(d[40:44], uint32(features))
+	le.PutUint32(d[44:48], maxMessage)
+	d[48], d[49] = 0xFF, 0xFF
+	d[53] = 1
+	return d
+}
+
+func TestParseDescriptor(t *testing.T) {
+	extra := append([]byte{0x05, 0x24, 0x00, 0x10, 0x01}, testDescriptor(0x000404BE, 271)...)
+	desc, err := ParseDescriptor(extra)
+	if err != nil {
+		t.Fatalf("ParseDescriptor: unexpected error %v", err)
+	}
+	if desc.CCIDVersion != 0x0110 || desc.MaxSlotIndex != 1 || desc.VoltageSupport != 0x07 ||
+		desc.DefaultClock != 4000 || desc.MaxDataRate != 344086 || desc.MaxIFSD != 254 ||
+		desc.MaxMessageLength != 271 || desc.ClassEnvelope != 0xFF || desc.MaxBusySlots != 1 {
+		t.Errorf("ParseDescriptor = %+v", desc)
+	}
+	if !desc.Protocols.SupportsT0() || !desc.Protocols.SupportsT1() {
+		t.Errorf("Protocols = %#x, want T=0 and T=1", uint32(desc.Protocols))
+	}
+	if !desc.Features.APDULevel() {
+		t.Errorf("Features %v: not APDU level", desc.Features)
+	}
+
+	testCases := []struct {
+		name  string
+		extra []byte
+	}{
+		{"missing", []byte{0x05, 0x24, 0x00, 0x10, 0x01}},
+		{"short", append([]byte{10, descriptorTypeCCID}, make([]byte, 8)...)},
+		{"truncated", testDescriptor(0, 271)[:20]},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseDescriptor(tc.extra); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestFeaturesString(t *testing.T) {
+	testCases := []struct {
+		features Features
+		want     string
+	}{
+		{0, "none"},
+		{FeatureAutoVoltage | FeatureShortAPDU, "AutoVoltage|ShortAPDU"},
+		{FeatureTPDU | 0x01, "TPDU"},
+	}
+	for _, tc := range testCases {
+		if got := tc.features.String(); got != tc.want {
+			t.Errorf("Features(%#x).String() = %q, want %q", uint32(tc.features), got, tc.want)
+		}
+	}
+	if FeatureTPDU.APDULevel() {
+		t.Error("TPDU level reader reported as APDU level")
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Message types of the bulk endpoints.
+const (
+	msgSetParameters = 0x61
+	msgIccPowerOn    = 0x62
+	msgIccPowerOff   = 0x63
+	msgGetSlotStatus = 0x65
+	msgGetParameters = 0x6C
+	msgXfrBlock      = 0x6F
+	msgDataBlock     = 0x80
+	msgSlotStatus    = 0x81
+	msgParameters    = 0x82
+)
+
+// responseTypes maps each command to the message type of its response.
+var responseTypes = map[byte]byte{
+	msgSetParameters: msgParameters,
+	msgIccPowerOn:    msgDataBlock,
+	msgIccPowerOff:   msgSlotStatus,
+	msgGetSlotStatus: msgSlotStatus,
+	msgGetParameters: msgParameters,
+	msgXfrBlock:      msgDataBlock,
+}
+
+// headerSize is the size of the header of every bulk message.
+const headerSize = 10
+
+// minMessageSize is the smallest dwMaxCCIDMessageLength the class allows:
+// a header and a short APDU with its data and Le.
+const minMessageSize = headerSize + 261
+
+// Command status values in bits 6 and 7 of bStatus.
+const (
+	commandFailed        = 1
+	commandTimeExtension = 2
+)
+
+// Chain values of the level parameter of XfrBlock and of the chain
+// parameter of DataBlock, used by extended APDU level readers.
+const (
+	ChainNone         = 0x00
+	ChainBegin        = 0x01
+	ChainEnd          = 0x02
+	ChainContinue     = 0x03
+	ChainNextResponse = 0x10
+)
+
+// Voltage selects the voltage PowerOn applies to the card.
+type Voltage uint8
+
+// Voltages for PowerOn.
+const (
+	VoltageAuto Voltage = 0x00
+	Voltage5V   Voltage = 0x01
+	Voltage3V   Voltage = 0x02
+	Voltage1V8  Voltage = 0x03
+)
+
+var voltageNames = map[Voltage]string{
+	VoltageAuto: "automatic",
+	Voltage5V:   "5.0 V",
+	Voltage3V:   "3.0 V",
+	Voltage1V8:  "1.8 V",
+}
+
+func (v Voltage) String() string {
+	if name, ok := voltageNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("voltage %#02x", uint8(v))
+}
+
+// ICCStatus is the state of the card in a slot, from bits 0 and 1 of the
+// bStatus field of every response.
+type ICCStatus uint8
+
+// ICC states.
+const (
+	ICCActive   ICCStatus = 0
+	ICCInactive ICCStatus = 1
+	ICCAbsent   ICCStatus = 2
+)
+
+var iccStatusNames = map[ICCStatus]string{
+	ICCActive:   "active",
+	ICCInactive: "inactive",
+	ICCAbsent:   "absent",
+}
+
+func (s ICCStatus) String() string {
+	if name, ok := iccStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ICC status %d", uint8(s))
+}
+
+// SlotStatus is the state of a slot as reported by a SlotStatus response.
+type SlotStatus struct {
+	ICC ICCStatus
+	// ClockStatus is 0 if the clock is running, 1, 2 or 3 if it is stopped
+	// in the low, high or an unknown state.
+	ClockStatus uint8
+}
+
+// slotErrorNames names the bError values of a failed command. Values from
+// 1 to 127 give the offset of a bad field in the command instead.
+var slotErrorNames = map[uint8]string{
+	0x00: "command not supported",
+	0xE0: "slot busy",
+	0xEF: "PIN entry cancelled",
+	0xF0: "PIN entry timed out",
+	0xF2: "busy with automatic sequence",
+	0xF3: "deactivated protocol",
+	0xF4: "procedure byte conflict",
+	0xF5: "ICC class not supported",
+	0xF6: "ICC protocol not supported",
+	0xF7: "bad ATR TCK",
+	0xF8: "bad ATR TS",
+	0xFB: "hardware error",
+	0xFC: "transfer overrun",
+	0xFD: "transfer parity error",
+	0xFE: "ICC mute",
+	0xFF: "command aborted",
+}
+
+// SlotError is returned when the reader fails a command.
+type SlotError struct {
+	// Command is the message type of the failed command.
+	Command byte
+	Slot    uint8
+	ICC     ICCStatus
+	// Code is the bError field of the response.
+	Code uint8
+}
+
+func (err *SlotError) Error() string {
+	reason, ok := slotErrorNames[err.Code]
+	if !ok && err.Code >= 1 && err.Code <= 127 {
+		reason = fmt.Sprintf("bad parameter at offset %d", err.Code)
+	} else if !ok {
+		reason = fmt.Sprintf("error %#02x", err.Code)
+	}
+	return fmt.Sprintf(
+		"ccid: command %#02x on slot %d failed: %s (ICC %v)",
+		err.Command,
+		err.Slot,
+		reason,
+		err.ICC,
+	)
+}
+
+// response is an RDR_to_PC message.
+type response struct {
+	status uint8
+	// param is bChainParameter, bClockStatus or bProtocolNum, depending on
+	// the message type.
+	param uint8
+	data  []byte
+}
+
+func (resp *response) icc() ICCStatus {
+	return ICCStatus(resp.status & 0x03)
+}
+
+// maxMessageSize returns the size of the largest message the reader
+// handles.
+func (r *Reader) maxMessageSize() int {
+	return max(int(r.Interface.CCID.MaxMessageLength), minMessageSize)
+}
+
+// transact sends a command to a slot and returns its response. Responses
+// carrying an earlier sequence number, left over from a command that timed
+// out, are skipped, and time extension requests are waited through.
+func (r *Reader) transact(
+	msgType byte,
+	slot uint8,
+	params [3]byte,
+	data []byte,
+) (*response, error) {
+	if r.isClosed() {
+		return nil, os.ErrClosed
+	}
+	if int(slot) > int(r.Interface.CCID.MaxSlotIndex) {
+		return nil, fmt.Errorf("ccid: slot %d out of range", slot)
+	}
+	if headerSize+len(data) > r.maxMessageSize() {
+		return nil, fmt.Errorf(
+			"ccid: %d byte command exceeds the reader's %d byte messages",
+			headerSize+len(data),
+			r.maxMessageSize(),
+		)
+	}
+	r.cmdMu.Lock()
+	defer r.cmdMu.Unlock()
+	seq := r.seq
+	r.seq++
+
+	msg := make([]byte, headerSize+len(data))
+	msg[0] = msgType
+	binary.LittleEndian.PutUint32(msg[1:5], uint32(len(data)))
+	msg[5] = slot
+	msg[6] = seq
+	copy(msg[7:10], params[:])
+	copy(msg[headerSize:], data)
+	n, err := r.handle.BulkTransfer(r.Interface.BulkOut.EndpointAddress, msg, len(msg), r.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ccid: sending command %#02x: %w", msgType, err)
+	}
+	if n != len(msg) {
+		return nil, io.ErrShortWrite
+	}
+
+	// Round the buffer up to whole packets, so that a reader sending full
+	// packets can't overflow the transfer.
+	in := r.Interface.BulkIn
+	packetSize := max(int(in.MaxPacketSize), 1)
+	buf := make([]byte, (r.maxMessageSize()+packetSize-1)/packetSize*packetSize)
+	for {
+		n, err := r.handle.BulkTransfer(in.EndpointAddress, buf, len(buf), r.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ccid: reading response to command %#02x: %w",
+				msgType,
+				err,
+			)
+		}
+		if n < headerSize {
+			return nil, fmt.Errorf("ccid: %d byte response is shorter than its header", n)
+		}
+		if buf[6] != seq {
+			continue
+		}
+		length := binary.LittleEndian.Uint32(buf[1:5])
+		if uint64(length) > uint64(n-headerSize) {
+			return nil, fmt.Errorf(
+				"ccid: response holds %d of %d data bytes",
+				n-headerSize,
+				length,
+			)
+		}
+		if buf[5] != slot {
+			return nil, fmt.Errorf("ccid: response for slot %d, want %d", buf[5], slot)
+		}
+		resp := &response{
+			status: buf[7],
+			param:  buf[9],
+			data:   append([]byte(nil), buf[headerSize:headerSize+int(length)]...),
+		}
+		switch resp.status >> 6 {
+		case commandTimeExtension:
+			continue
+		case commandFailed:
+			return nil, &SlotError{Command: msgType, Slot: slot, ICC: resp.icc(), Code: buf[8]}
+		}
+		if want := responseTypes[msgType]; buf[0] != want {
+			return nil, fmt.Errorf(
+				"ccid: response type %#02x to command %#02x, want %#02x",
+				buf[0],
+				msgType,
+				want,
+			)
+		}
+		return resp, nil
+	}
+}
+
+// PowerOn activates the card in a slot and returns its answer to reset.
+func (r *Reader) PowerOn(slot uint8, voltage Voltage) ([]byte, error) {
+	resp, err := r.transact(msgIccPowerOn, slot, [3]byte{byte(voltage)}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.data, nil
+}
+
+// PowerOff deactivates the card in a slot.
+func (r *Reader) PowerOff(slot uint8) (SlotStatus, error) {
+	resp, err := r.transact(msgIccPowerOff, slot, [3]byte{}, nil)
+	if err != nil {
+		return SlotStatus{}, err
+	}
+	return SlotStatus{ICC: resp.icc(), ClockStatus: resp.param}, nil
+}
+
+// SlotStatus issues GetSlotStatus and returns the state of a slot.
+func (r *Reader) SlotStatus(slot uint8) (SlotStatus, error) {
+	resp, err := r.transact(msgGetSlotStatus, slot, [3]byte{}, nil)
+	if err != nil {
+		return SlotStatus{}, err
+	}
+	return SlotStatus{ICC: resp.icc(), ClockStatus: resp.param}, nil
+}
+
+// XfrBlock sends a block to the card in a slot and returns the block that
+// comes back. What a block holds depends on the exchange level of the
+// reader: characters, a TPDU, or an APDU. The level parameter and the
+// returned chain parameter are one of the Chain values for extended APDU
+// level readers and zero otherwise.
+func (r *Reader) XfrBlock(slot uint8, block []byte, level uint16) ([]byte, uint8, error) {
+	resp, err := r.transact(
+		msgXfrBlock,
+		slot,
+		[3]byte{0, byte(level), byte(level >> 8)},
+		block,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.data, resp.param, nil
+}
+
+// Transmit sends a command APDU to the card in a slot and returns the
+// response APDU. On an extended APDU level reader, APDUs too large for
+// one message are split over several XfrBlock commands, and responses are
+// collected from as many as the reader needs.
+func (r *Reader) Transmit(slot uint8, apdu []byte) ([]byte, error) {
+	if r.Interface.CCID.Features&FeatureExtendedAPDU == 0 {
+		resp, _, err := r.XfrBlock(slot, apdu, ChainNone)
+		return resp, err
+	}
+	chunk := r.maxMessageSize() - headerSize
+	level := uint16(ChainNone)
+	for len(apdu) > chunk {
+		if level == ChainNone {
+			level = ChainBegin
+		} else {
+			level = ChainContinue
+		}
+		_, chain, err := r.XfrBlock(slot, apdu[:chunk], level)
+		if err != nil {
+			return nil, err
+		}
+		if chain != ChainNextResponse {
+			return nil, fmt.Errorf("ccid: chain parameter %#02x in the middle of a command", chain)
+		}
+		apdu = apdu[chunk:]
+	}
+	if level != ChainNone {
+		level = ChainEnd
+	}
+	resp, chain, err := r.XfrBlock(slot, apdu, level)
+	if err != nil {
+		return nil, err
+	}
+	for chain == ChainBegin || chain == ChainContinue {
+		var more []byte
+		more, chain, err = r.XfrBlock(slot, nil, ChainNextResponse)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, more...)
+	}
+	return resp, nil
+}
+
+// Protocol numbers of Parameters.
+const (
+	ProtocolT0 = 0
+	ProtocolT1 = 1
+)
+
+// Parameters are the protocol parameters of a slot, as set with
+// SetParameters. IFSC and NADValue apply to T=1 only.
+type Parameters struct {
+	Protocol uint8
+	// FindexDindex holds Fi in the high nibble and Di in the low nibble.
+	FindexDindex uint8
+	// TCCKS holds the convention, and for T=1 the checksum type.
+	TCCKS           uint8
+	GuardTime       uint8
+	WaitingIntegers uint8
+	ClockStop       uint8
+	IFSC            uint8
+	NADValue        uint8
+}
+
+func (p Parameters) marshal() ([]byte, error) {
+	switch p.Protocol {
+	case ProtocolT0:
+		return []byte{p.FindexDindex, p.TCCKS, p.GuardTime, p.WaitingIntegers, p.ClockStop}, nil
+	case ProtocolT1:
+		return []byte{
+			p.FindexDindex,
+			p.TCCKS,
+			p.GuardTime,
+			p.WaitingIntegers,
+			p.ClockStop,
+			p.IFSC,
+			p.NADValue,
+		}, nil
+	}
+	return nil, fmt.Errorf("ccid: protocol T=%d not supported", p.Protocol)
+}
+
+func parseParameters(protocol uint8, data []byte) (Parameters, error) {
+	want := map[uint8]int{ProtocolT0: 5, ProtocolT1: 7}[protocol]
+	if want == 0 {
+		return Parameters{}, fmt.Errorf("ccid: protocol T=%d not supported", protocol)
+	}
+	if len(data) < want {
+		return Parameters{}, fmt.Errorf(
+			"ccid: T=%d parameters are %d bytes; want %d",
+			protocol,
+			len(data),
+			want,
+		)
+	}
+	p := Parameters{
+		Protocol:        protocol,
+		FindexDindex:    data[0],
+		TCCKS:           data[1],
+		GuardTime:       data[2],
+		WaitingIntegers: data[3],
+		ClockStop:       data[4],
+	}
+	if protocol == ProtocolT1 {
+		p.IFSC, p.NADValue = data[5], data[6]
+	}
+	return p, nil
+}
+
+// SetParameters sets the protocol parameters of a slot and returns those
+// the reader settled on.
+func (r *Reader) SetParameters(slot uint8, p Parameters) (Parameters, error) {
+	data, err := p.marshal()
+	if err != nil {
+		return Parameters{}, err
+	}
+	resp, err := r.transact(msgSetParameters, slot, [3]byte{p.Protocol}, data)
+	if err != nil {
+		return Parameters{}, err
+	}
+	return parseParameters(resp.param, resp.data)
+}
+
+// GetParameters returns the protocol parameters of a slot.
+func (r *Reader) GetParameters(slot uint8) (Parameters, error) {
+	resp, err := r.transact(msgGetParameters, slot, [3]byte{}, nil)
+	if err != nil {
+		return Parameters{}, err
+	}
+	return parseParameters(resp.param, resp.data)
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPowerOn(t *testing.T) {
+	atr := []byte{0x3B, 0x8A, 0x80, 0x01}
+	fh := &fakeHandle{replies: [][]byte{
+		// Left over from a command that timed out.
+		reply(msgSlotStatus, 1, 0xFF, 0x01, 0, 0),
+		// Time extension.
+		reply(msgDataBlock, 1, 0, 0x80, 0x01, 0),
+		reply(msgDataBlock, 1, 0, 0x00, 0, 0, atr...),
+	}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+	defer r.Close()
+
+	got, err := r.PowerOn(1, Voltage3V)
+	if err != nil {
+		t.Fatalf("PowerOn: unexpected error %v", err)
+	}
+	if !bytes.Equal(got, atr) {
+		t.Errorf("PowerOn = % x, want % x", got, atr)
+	}
+	want := [][]byte{{msgIccPowerOn, 0, 0, 0, 0, 1, 0, 0x02, 0, 0}}
+	if !slices.EqualFunc(fh.written, want, bytes.Equal) {
+		t.Errorf("written = % x, want % x", fh.written, want)
+	}
+	if _, err := r.PowerOn(2, VoltageAuto); err == nil {
+		t.Error("PowerOn on slot 2: expected error, got nil")
+	}
+}
+
+func TestSlotStatus(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{
+		reply(msgSlotStatus, 0, 0, 0x01, 0, 0x02),
+		reply(msgSlotStatus, 0, 1, 0x01, 0, 0),
+		reply(msgSlotStatus, 0, 2, 0x42, 0xFE, 0),
+		reply(msgDataBlock, 0, 3, 0x00, 0, 0),
+	}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+	defer r.Close()
+
+	status, err := r.SlotStatus(0)
+	if err != nil || status != (SlotStatus{ICC: ICCInactive, ClockStatus: 2}) {
+		t.Errorf("SlotStatus = %+v, %v", status, err)
+	}
+	if status, err := r.PowerOff(0); err != nil || status.ICC != ICCInactive {
+		t.Errorf("PowerOff = %+v, %v", status, err)
+	}
+	_, err = r.SlotStatus(0)
+	var slotErr *SlotError
+	if !errors.As(err, &slotErr) || slotErr.Code != 0xFE || slotErr.ICC != ICCAbsent {
+		t.Fatalf("SlotStatus with a failed command: got %v, want a *SlotError", err)
+	}
+	want := "ccid: command 0x65 on slot 0 failed: ICC mute (ICC absent)"
+	if got := slotErr.Error(); got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+	if _, err := r.SlotStatus(0); err == nil {
+		t.Error("mismatched response type: expected error, got nil")
+	}
+	if seqs := []byte{fh.written[0][6], fh.written[3][6]}; !bytes.Equal(seqs, []byte{0, 3}) {
+		t.Errorf("sequence numbers = % x, want 00 03", seqs)
+	}
+}
+
+func TestSlotErrorString(t *testing.T) {
+	testCases := []struct {
+		code uint8
+		want string
+	}{
+		{0x00, "ccid: command 0x6f on slot 0 failed: command not supported (ICC active)"},
+		{0x07, "ccid: command 0x6f on slot 0 failed: bad parameter at offset 7 (ICC active)"},
+		{0xA0, "ccid: command 0x6f on slot 0 failed: error 0xa0 (ICC active)"},
+	}
+	for _, tc := range testCases {
+		err := &SlotError{Command: msgXfrBlock, Code: tc.code}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("Error() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestTransmit(t *testing.T) {
+	apdu := []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00}
+	fh := &fakeHandle{replies: [][]byte{reply(msgDataBlock, 0, 0, 0, 0, 0, 0x90, 0x00)}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+	defer r.Close()
+	resp, err := r.Transmit(0, apdu)
+	if err != nil || !bytes.Equal(resp, []byte{0x90, 0x00}) {
+		t.Errorf("Transmit = % x, %v", resp, err)
+	}
+	if got := fh.written[0]; got[0] != msgXfrBlock || !bytes.Equal(got[headerSize:], apdu) {
+		t.Errorf("written = % x", got)
+	}
+	if _, err := r.Transmit(0, make([]byte, 300)); err == nil {
+		t.Error("oversized APDU on a short APDU reader: expected error, got nil")
+	}
+}
+
+func TestTransmitChaining(t *testing.T) {
+	// With 271 byte messages, a 600 byte APDU takes three blocks.
+	apdu := make([]byte, 600)
+	for i := range apdu {
+		apdu[i] = byte(i)
+	}
+	fh := &fakeHandle{replies: [][]byte{
+		reply(msgDataBlock, 0, 0, 0, 0, ChainNextResponse),
+		reply(msgDataBlock, 0, 1, 0, 0, ChainNextResponse),
+		reply(msgDataBlock, 0, 2, 0, 0, ChainBegin, 0x01, 0x02),
+		reply(msgDataBlock, 0, 3, 0, 0, ChainContinue, 0x03),
+		reply(msgDataBlock, 0, 4, 0, 0, ChainEnd, 0x90, 0x00),
+	}}
+	r := openTestReader(t, fh, FeatureExtendedAPDU, 271)
+	defer r.Close()
+
+	resp, err := r.Transmit(0, apdu)
+	if err != nil {
+		t.Fatalf("Transmit: unexpected error %v", err)
+	}
+	if want := []byte{0x01, 0x02, 0x03, 0x90, 0x00}; !bytes.Equal(resp, want) {
+		t.Errorf("Transmit = % x, want % x", resp, want)
+	}
+	var levels []byte
+	var sent []byte
+	for _, msg := range fh.written {
+		levels = append(levels, msg[8])
+		sent = append(sent, msg[headerSize:]...)
+	}
+	want := []byte{ChainBegin, ChainContinue, ChainEnd, ChainNextResponse, ChainNextResponse}
+	if !bytes.Equal(levels, want) {
+		t.Errorf("level parameters = % x, want % x", levels, want)
+	}
+	if !bytes.Equal(sent, apdu) {
+		t.Error("blocks sent don't add up to the APDU")
+	}
+}
+
+func TestParameters(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{
+		reply(msgParameters, 0, 0, 0, 0, ProtocolT1, 0x11, 0x10, 0xFE, 0x45, 0x00, 0xFE, 0x00),
+		reply(msgParameters, 0, 1, 0, 0, ProtocolT0, 0x11, 0x00, 0x00, 0x0A, 0x00),
+	}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+	defer r.Close()
+
+	want := Parameters{
+		Protocol:        ProtocolT1,
+		FindexDindex:    0x11,
+		TCCKS:           0x10,
+		GuardTime:       0xFE,
+		WaitingIntegers: 0x45,
+		IFSC:            0xFE,
+	}
+	got, err := r.SetParameters(0, want)
+	if err != nil || got != want {
+		t.Errorf("SetParameters = %+v, %v", got, err)
+	}
+	msg := fh.written[0]
+	if msg[0] != msgSetParameters || msg[7] != ProtocolT1 || len(msg) != headerSize+7 {
+		t.Errorf("written = % x", msg)
+	}
+	got, err = r.GetParameters(0)
+	want = Parameters{Protocol: ProtocolT0, FindexDindex: 0x11, WaitingIntegers: 0x0A}
+	if err != nil || got != want {
+		t.Errorf("GetParameters = %+v, %v", got, err)
+	}
+	if _, err := r.SetParameters(0, Parameters{Protocol: 2}); err == nil {
+		t.Error("SetParameters with T=2: expected error, got nil")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// notificationPoll is the interrupt transfer timeout in milliseconds used by
+// WatchSlots, which bounds how long Close waits for it to return.
+const notificationPoll = 250
+
+// Message types of the interrupt endpoint.
+const (
+	msgNotifySlotChange = 0x50
+	msgHardwareError    = 0x51
+)
+
+// SlotChange is the state of one slot reported by a NotifySlotChange
+// message.
+type SlotChange struct {
+	Slot    int
+	Present bool
+	// Changed is set if a card was inserted or removed since the previous
+	// NotifySlotChange message.
+	Changed bool
+}
+
+func (sc SlotChange) String() string {
+	state := "empty"
+	if sc.Present {
+		state = "card present"
+	}
+	if sc.Changed {
+		return fmt.Sprintf("slot %d: %s (changed)", sc.Slot, state)
+	}
+	return fmt.Sprintf("slot %d: %s", sc.Slot, state)
+}
+
+// HardwareError reports a fault in a slot, such as an overcurrent, from
+// a HardwareError message.
+type HardwareError struct {
+	Slot uint8
+	// Seq is the sequence number of the command that was running, if any.
+	Seq  uint8
+	Code uint8
+}
+
+func (err *HardwareError) Error() string {
+	if err.Code == 0x01 {
+		return fmt.Sprintf("ccid: overcurrent on slot %d", err.Slot)
+	}
+	return fmt.Sprintf("ccid: hardware error %#02x on slot %d", err.Code, err.Slot)
+}
+
+// Notification is a message from the interrupt endpoint. Exactly one of
+// Slots and HardwareError is set.
+type Notification struct {
+	// Slots holds the state of every slot for a NotifySlotChange message.
+	Slots         []SlotChange
+	HardwareError *HardwareError
+}
+
+func parseNotification(data []byte, slots int) (*Notification, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("ccid: empty notification")
+	}
+	switch data[0] {
+	case msgNotifySlotChange:
+		if want := 1 + (2*slots+7)/8; len(data) < want {
+			return nil, fmt.Errorf(
+				"ccid: slot change notification is %d bytes; want %d",
+				len(data),
+				want,
+			)
+		}
+		n := &Notification{Slots: make([]SlotChange, slots)}
+		for i := range n.Slots {
+			bits := data[1+i/4] >> (2 * (i % 4))
+			n.Slots[i] = SlotChange{Slot: i, Present: bits&0x01 != 0, Changed: bits&0x02 != 0}
+		}
+		return n, nil
+	case msgHardwareError:
+		if len(data) < 4 {
+			return nil, fmt.Errorf(
+				"ccid: hardware error notification is %d bytes; want 4",
+				len(data),
+			)
+		}
+		return &Notification{
+			HardwareError: &HardwareError{Slot: data[1], Seq: data[2], Code: data[3]},
+		}, nil
+	}
+	return nil, fmt.Errorf("ccid: unknown notification type %#02x", data[0])
+}
+
+// ReadNotification reads one message from the interrupt endpoint, waiting
+// up to Timeout. It shouldn't be used while WatchSlots is running.
+func (r *Reader) ReadNotification() (*Notification, error) {
+	if r.isClosed() {
+		return nil, os.ErrClosed
+	}
+	return r.readNotification(r.Timeout)
+}
+
+func (r *Reader) readNotification(timeout int) (*Notification, error) {
+	ep := r.Interface.Interrupt
+	if ep == nil {
+		return nil, fmt.Errorf("ccid: reader has no interrupt endpoint")
+	}
+	data := make([]byte, max(int(ep.MaxPacketSize), 1+(2*r.Slots()+7)/8))
+	n, err := r.handle.InterruptTransfer(ep.EndpointAddress, data, len(data), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ccid: reading notification: %w", err)
+	}
+	return parseNotification(data[:n], r.Slots())
+}
+
+// WatchSlots reads the interrupt endpoint until the context is done or the
+// reader is closed, calling fn for each slot that a card was inserted into
+// or removed from. A slot holding a card when WatchSlots starts is
+// reported once the reader next sends a NotifySlotChange message, which
+// most readers do when they are first polled. Hardware errors are skipped.
+// WatchSlots returns nil once the reader is closed, the context's error if
+// it is canceled, or the first transfer error other than a timeout.
+func (r *Reader) WatchSlots(ctx context.Context, fn func(SlotChange)) error {
+	if r.Interface.Interrupt == nil {
+		return fmt.Errorf("ccid: reader has no interrupt endpoint")
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return os.ErrClosed
+	}
+	r.watchers.Add(1)
+	r.mu.Unlock()
+	defer r.watchers.Done()
+
+	present := make([]bool, r.Slots())
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if r.isClosed() {
+			return nil
+		}
+		notification, err := r.readNotification(notificationPoll)
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, sc := range notification.Slots {
+			if sc.Changed || sc.Present != present[sc.Slot] {
+				present[sc.Slot] = sc.Present
+				fn(sc)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ccid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseNotification(t *testing.T) {
+	n, err := parseNotification([]byte{msgNotifySlotChange, 0x0E}, 2)
+	if err != nil {
+		t.Fatalf("parseNotification: unexpected error %v", err)
+	}
+	want := []SlotChange{{0, false, true}, {1, true, true}}
+	if !slices.Equal(n.Slots, want) || n.HardwareError != nil {
+		t.Errorf("parseNotification = %+v, want slots %v", n, want)
+	}
+
+	n, err = parseNotification([]byte{msgHardwareError, 1, 7, 0x01}, 2)
+	if err != nil || n.HardwareError == nil || n.HardwareError.Seq != 7 {
+		t.Fatalf("parseNotification = %+v, %v", n, err)
+	}
+	if got, want := n.HardwareError.Error(), "ccid: overcurrent on slot 1"; got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short slot change", []byte{msgNotifySlotChange}},
+		{"short hardware error", []byte{msgHardwareError, 0, 0}},
+		{"unknown type", []byte{0x52, 0x00}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseNotification(tc.data, 2); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSlotChangeString(t *testing.T) {
+	got, want := (SlotChange{1, true, true}).String(), "slot 1: card present (changed)"
+	if got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	if got, want := (SlotChange{Slot: 0}).String(), "slot 0: empty"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
+
+func TestWatchSlots(t *testing.T) {
+	fh := &fakeHandle{interrupts: [][]byte{
+		// A card already in slot 1.
+		{msgNotifySlotChange, 0x04},
+		{msgHardwareError, 1, 0, 0x01},
+		// Inserted into slot 0.
+		{msgNotifySlotChange, 0x07},
+		// Removed from slot 1.
+		{msgNotifySlotChange, 0x09},
+	}}
+	r := openTestReader(t, fh, FeatureShortAPDU, 271)
+
+	changes := make(chan SlotChange, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchSlots(ctx, func(sc SlotChange) { changes <- sc })
+	}()
+
+	want := []SlotChange{{1, true, false}, {0, true, true}, {1, false, true}}
+	for _, w := range want {
+		select {
+		case got := <-changes:
+			if got != w {
+				t.Errorf("change = %v, want %v", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", w)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("WatchSlots after Close: got %v, want nil", err)
+	}
+	if err := r.WatchSlots(ctx, func(SlotChange) {}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WatchSlots on a closed reader: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestWatchSlotsCanceled(t *testing.T) {
+	r := openTestReader(t, &fakeHandle{}, FeatureShortAPDU, 271)
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.WatchSlots(ctx, func(SlotChange) {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchSlots with a canceled context: got %v, want context.Canceled", err)
+	}
+	r.Interface.Interrupt = nil
+	if err := r.WatchSlots(context.Background(), func(SlotChange) {}); err == nil {
+		t.Error("WatchSlots without an interrupt endpoint: expected error, got nil")
+	}
+}
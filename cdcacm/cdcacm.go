@@ -0,0 +1,515 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package cdcacm implements the USB Communications Device Class Abstract Control
+Model (CDC-ACM), the class used by virtual serial ports, on top of libusb.
+
+An ACM function is a communication interface, which carries the class
+requests and an optional interrupt endpoint for notifications, paired with a
+data interface holding a bulk IN and bulk OUT endpoint. FindFunctions pairs
+them using the union functional descriptor, and Open claims both interfaces,
+detaching any kernel driver such as cdc_acm on Linux, and returns a Port that
+reads and writes the bulk pair.
+*/
+package cdcacm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Port.Timeout that Open sets: one second, in
+// milliseconds, for the bulk data and the class requests alike.
+const DefaultTimeout = 1000
+
+// subclassACM is the communication interface subclass of an Abstract Control
+// Model function.
+const subclassACM = 0x02
+
+// ACM class-specific requests.
+const (
+	requestSendEncapsulatedCommand = 0x00
+	requestGetEncapsulatedResponse = 0x01
+	requestSetLineCoding           = 0x20
+	requestGetLineCoding           = 0x21
+	requestSetControlLineState     = 0x22
+	requestSendBreak               = 0x23
+)
+
+// lineCodingSize is the length of the line coding structure.
+const lineCodingSize = 7
+
+// Control line state bits for SET_CONTROL_LINE_STATE.
+const (
+	controlLineDTR = 0x01
+	controlLineRTS = 0x02
+)
+
+// BreakUntilCleared is the SendBreak duration that holds the break condition
+// until SendBreak is called again with a duration of zero.
+const BreakUntilCleared = 0xFFFF
+
+// StopBits is the bCharFormat field of the line coding.
+type StopBits byte
+
+// Stop bit settings.
+const (
+	OneStopBit           StopBits = 0
+	OnePointFiveStopBits StopBits = 1
+	TwoStopBits          StopBits = 2
+)
+
+var stopBits = map[StopBits]string{
+	OneStopBit:           "1",
+	OnePointFiveStopBits: "1.5",
+	TwoStopBits:          "2",
+}
+
+// String implements the Stringer interface for StopBits.
+func (bits StopBits) String() string {
+	return stopBits[bits]
+}
+
+// Parity is the bParityType field of the line coding.
+type Parity byte
+
+// Parity settings.
+const (
+	ParityNone  Parity = 0
+	ParityOdd   Parity = 1
+	ParityEven  Parity = 2
+	ParityMark  Parity = 3
+	ParitySpace Parity = 4
+)
+
+var parities = map[Parity]string{
+	ParityNone:  "None",
+	ParityOdd:   "Odd",
+	ParityEven:  "Even",
+	ParityMark:  "Mark",
+	ParitySpace: "Space",
+}
+
+// String implements the Stringer interface for Parity.
+func (parity Parity) String() string {
+	return parities[parity]
+}
+
+// LineCoding holds the asynchronous character format of the serial line.
+type LineCoding struct {
+	BaudRate uint32
+	StopBits StopBits
+	Parity   Parity
+	// DataBits is 5, 6, 7, 8, or 16.
+	DataBits uint8
+}
+
+// String formats the line coding in the usual 9600 8N1 notation.
+func (lc LineCoding) String() string {
+	parity := "?"
+	if name, ok := parities[lc.Parity]; ok {
+		parity = name[:1]
+	}
+	return fmt.Sprintf("%d %d%s%s", lc.BaudRate, lc.DataBits, parity, lc.StopBits)
+}
+
+func (lc LineCoding) validate() error {
+	switch {
+	case lc.BaudRate == 0:
+		return fmt.Errorf("cdcacm: baud rate must be nonzero")
+	case lc.StopBits > TwoStopBits:
+		return fmt.Errorf("cdcacm: invalid stop bits %d", lc.StopBits)
+	case lc.Parity > ParitySpace:
+		return fmt.Errorf("cdcacm: invalid parity %d", lc.Parity)
+	}
+	switch lc.DataBits {
+	case 5, 6, 7, 8, 16:
+		return nil
+	}
+	return fmt.Errorf("cdcacm: invalid data bits %d", lc.DataBits)
+}
+
+func (lc LineCoding) marshal() []byte {
+	data := make([]byte, lineCodingSize)
+	binary.LittleEndian.PutUint32(data[0:4], lc.BaudRate)
+	data[4] = byte(lc.StopBits)
+	data[5] = byte(lc.Parity)
+	data[6] = lc.DataBits
+	return data
+}
+
+func parseLineCoding(data []byte) (LineCoding, error) {
+	if len(data) < lineCodingSize {
+		return LineCoding{}, fmt.Errorf(
+			"cdcacm: line coding is %d bytes; want %d",
+			len(data),
+			lineCodingSize,
+		)
+	}
+	return LineCoding{
+		BaudRate: binary.LittleEndian.Uint32(data[0:4]),
+		StopBits: StopBits(data[4]),
+		Parity:   Parity(data[5]),
+		DataBits: data[6],
+	}, nil
+}
+
+// Handle is what a Port needs of a *libusb.DeviceHandle: claiming the
+// communication and data interfaces, the ACM class requests, and the bulk
+// and notification transfers.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Function is an ACM communication interface paired with its data
+// interface.
+type Function struct {
+	Control *libusb.InterfaceDescriptor
+	// Data is the alternate setting of the data interface that has the bulk
+	// endpoints.
+	Data        *libusb.InterfaceDescriptor
+	Descriptors *FunctionalDescriptors
+}
+
+// FindFunctions returns the ACM functions in a configuration. The data
+// interface is taken from the union functional descriptor; for devices that
+// omit it, the call management descriptor or the interface following the
+// communication interface is used instead.
+func FindFunctions(config *libusb.ConfigDescriptor) ([]*Function, error) {
+	if config == nil {
+		return nil, fmt.Errorf("cdcacm: nil configuration descriptor")
+	}
+	var functions []*Function
+	for _, si := range config.SupportedInterfaces {
+		if si == nil || len(si.InterfaceDescriptors) == 0 {
+			continue
+		}
+		control := si.InterfaceDescriptors[0]
+		if control.InterfaceClass != libusb.InterfaceClassComm ||
+			control.InterfaceSubClass != subclassACM {
+			continue
+		}
+		fd, err := ParseFunctionalDescriptors(control.Extra)
+		if err != nil {
+			return nil, err
+		}
+		dataNum := control.InterfaceNumber + 1
+		switch {
+		case fd.Union != nil && len(fd.Union.SubordinateInterfaces) > 0:
+			dataNum = fd.Union.SubordinateInterfaces[0]
+		case fd.CallManagement != nil:
+			dataNum = fd.CallManagement.DataInterface
+		}
+		data := dataInterface(config, dataNum)
+		if data == nil {
+			continue
+		}
+		functions = append(functions, &Function{
+			Control:     control,
+			Data:        data,
+			Descriptors: fd,
+		})
+	}
+	return functions, nil
+}
+
+// dataInterface returns the first alternate setting of a data class
+// interface that has both a bulk IN and a bulk OUT endpoint.
+func dataInterface(config *libusb.ConfigDescriptor, number int) *libusb.InterfaceDescriptor {
+	for _, si := range config.SupportedInterfaces {
+		if si == nil {
+			continue
+		}
+		for _, alt := range si.InterfaceDescriptors {
+			if alt.InterfaceNumber != number || alt.InterfaceClass != libusb.InterfaceClassData {
+				continue
+			}
+			in, out := bulkEndpoints(alt)
+			if in != nil && out != nil {
+				return alt
+			}
+		}
+	}
+	return nil
+}
+
+func bulkEndpoints(iface *libusb.InterfaceDescriptor) (in, out *libusb.EndpointDescriptor) {
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.BulkTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && in == nil {
+			in = ep
+		} else if ep.Direction() == libusb.EndpointOut && out == nil {
+			out = ep
+		}
+	}
+	return in, out
+}
+
+// Port is an opened ACM function. Read and Write may be called concurrently
+// with each other, but not with themselves.
+type Port struct {
+	handle           Handle
+	ControlInterface int
+	DataInterface    int
+	InEndpoint       libusb.EndpointAddress
+	OutEndpoint      libusb.EndpointAddress
+	// NotifyEndpoint is zero if the function has no notification endpoint.
+	NotifyEndpoint libusb.EndpointAddress
+	// Capabilities is zero if the function has no ACM functional descriptor.
+	Capabilities ACMCapabilities
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	reader *usbif.Reader
+
+	lineMu    sync.Mutex
+	lineState uint16
+
+	mu         sync.Mutex
+	closed     bool
+	claims     *usbif.Claims
+	watchers   sync.WaitGroup
+	notifySize int
+	state      SerialState
+}
+
+// Open claims the communication and data interfaces of an ACM function,
+// detaching kernel drivers that are bound to them, and selects the data
+// interface's alternate setting if it isn't the default.
+func Open(handle Handle, fn *Function) (*Port, error) {
+	if handle == nil || fn == nil || fn.Control == nil || fn.Data == nil {
+		return nil, fmt.Errorf("cdcacm: nil handle or function")
+	}
+	in, out := bulkEndpoints(fn.Data)
+	if in == nil || out == nil {
+		return nil, fmt.Errorf(
+			"cdcacm: data interface %d has no bulk endpoint pair",
+			fn.Data.InterfaceNumber,
+		)
+	}
+	port := &Port{
+		handle:           handle,
+		ControlInterface: fn.Control.InterfaceNumber,
+		DataInterface:    fn.Data.InterfaceNumber,
+		InEndpoint:       in.EndpointAddress,
+		OutEndpoint:      out.EndpointAddress,
+		Timeout:          DefaultTimeout,
+		reader:           usbif.NewReader(handle, in.EndpointAddress, int(in.MaxPacketSize)),
+		claims:           usbif.NewClaims(handle, "cdcacm"),
+	}
+	if fn.Descriptors != nil && fn.Descriptors.ACMCapabilities != nil {
+		port.Capabilities = *fn.Descriptors.ACMCapabilities
+	}
+	for _, ep := range fn.Control.EndpointDescriptors {
+		if ep.TransferType() == libusb.InterruptTransfer && ep.Direction() == libusb.EndpointIn {
+			port.NotifyEndpoint = ep.EndpointAddress
+			port.notifySize = notificationBufferSize(int(ep.MaxPacketSize))
+			break
+		}
+	}
+
+	for _, iface := range []int{port.ControlInterface, port.DataInterface} {
+		if err := port.claims.Claim(iface); err != nil {
+			return nil, errors.Join(err, port.claims.Release())
+		}
+	}
+	if fn.Data.AlternateSetting != 0 {
+		err := handle.SetInterfaceAltSetting(port.DataInterface, fn.Data.AlternateSetting)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("cdcacm: selecting data interface alternate setting: %w", err),
+				port.claims.Release(),
+			)
+		}
+	}
+	return port, nil
+}
+
+// Read returns data the function sent on the data interface, reading it a
+// packet at a time so that a timeout never loses bytes already sent. If
+// nothing arrives within Timeout, the error is a libusb.ErrorCode whose
+// Timeout method reports true.
+func (port *Port) Read(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return port.reader.Read(p, port.Timeout)
+}
+
+// Write writes p to the bulk OUT endpoint.
+func (port *Port) Write(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return usbif.Write(port.handle, port.OutEndpoint, p, port.Timeout)
+}
+
+// Close stops any WatchSerialState loops, releases both interfaces, and
+// reattaches the kernel drivers that Open detached.
+func (port *Port) Close() error {
+	port.mu.Lock()
+	if port.closed {
+		port.mu.Unlock()
+		return os.ErrClosed
+	}
+	port.closed = true
+	port.mu.Unlock()
+	port.watchers.Wait()
+	return port.claims.Release()
+}
+
+func (port *Port) isClosed() bool {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.closed
+}
+
+// SetLineCoding issues SET_LINE_CODING to configure the baud rate and
+// character format.
+func (port *Port) SetLineCoding(lc LineCoding) error {
+	if err := lc.validate(); err != nil {
+		return err
+	}
+	return port.classOut(requestSetLineCoding, 0, lc.marshal())
+}
+
+// LineCoding issues GET_LINE_CODING and returns the current line coding.
+func (port *Port) LineCoding() (LineCoding, error) {
+	data := make([]byte, lineCodingSize)
+	n, err := port.classIn(requestGetLineCoding, 0, data)
+	if err != nil {
+		return LineCoding{}, err
+	}
+	return parseLineCoding(data[:n])
+}
+
+// SetControlLineState issues SET_CONTROL_LINE_STATE to drive the DTR and
+// RTS signals.
+func (port *Port) SetControlLineState(dtr, rts bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	return port.setControlLineState(dtr, rts)
+}
+
+// SetDTR changes DTR, leaving RTS as last set.
+func (port *Port) SetDTR(dtr bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	return port.setControlLineState(dtr, port.lineState&controlLineRTS != 0)
+}
+
+// SetRTS changes RTS, leaving DTR as last set.
+func (port *Port) SetRTS(rts bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	return port.setControlLineState(port.lineState&controlLineDTR != 0, rts)
+}
+
+func (port *Port) setControlLineState(dtr, rts bool) error {
+	var state uint16
+	if dtr {
+		state |= controlLineDTR
+	}
+	if rts {
+		state |= controlLineRTS
+	}
+	if err := port.classOut(requestSetControlLineState, state, nil); err != nil {
+		return err
+	}
+	port.lineState = state
+	return nil
+}
+
+// SendBreak issues SEND_BREAK to hold the line in the break state for
+// durationMS milliseconds. A duration of BreakUntilCleared holds it until
+// SendBreak is called with zero.
+func (port *Port) SendBreak(durationMS uint16) error {
+	return port.classOut(requestSendBreak, durationMS, nil)
+}
+
+// SendEncapsulatedCommand issues SEND_ENCAPSULATED_COMMAND, used by modems
+// that carry AT commands on the control interface.
+func (port *Port) SendEncapsulatedCommand(command []byte) error {
+	return port.classOut(requestSendEncapsulatedCommand, 0, command)
+}
+
+// GetEncapsulatedResponse issues GET_ENCAPSULATED_RESPONSE, normally after a
+// RESPONSE_AVAILABLE notification.
+func (port *Port) GetEncapsulatedResponse(length int) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := port.classIn(requestGetEncapsulatedResponse, 0, data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (port *Port) classIn(request byte, value uint16, data []byte) (int, error) {
+	return port.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(port.ControlInterface),
+		data,
+		len(data),
+		port.Timeout,
+	)
+}
+
+func (port *Port) classOut(request byte, value uint16, data []byte) error {
+	n, err := port.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(port.ControlInterface),
+		data,
+		port.Timeout,
+	)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("cdcacm: sent %d of %d bytes", n, len(data))
+	}
+	return nil
+}
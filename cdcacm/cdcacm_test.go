@@ -0,0 +1,359 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cdcacm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type controlRequest struct {
+	in      bool
+	request byte
+	value   uint16
+	index   uint16
+	data    []byte
+}
+
+// fakeHandle logs interface management calls, records control requests,
+// and serves bulk and interrupt IN data from queues.
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu          sync.Mutex
+	requests    []controlRequest
+	controlData []byte
+	bulkIn      [][]byte
+	bulkOut     []byte
+	interrupt   [][]byte
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests, controlRequest{true, request, value, index, nil})
+	return copy(data[:maxReceiveLength], fh.controlData), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	if reqType != libusb.Class || recipient != libusb.InterfaceRecipient {
+		return 0, fmt.Errorf("unexpected request type %v to %v", reqType, recipient)
+	}
+	fh.requests = append(fh.requests, controlRequest{false, request, value, index, bytes.Clone(data)})
+	return len(data), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.bulkOut = append(fh.bulkOut, data[:length]...)
+		return length, nil
+	}
+	if len(fh.bulkIn) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	packet := fh.bulkIn[0]
+	if len(packet) > length {
+		return 0, libusb.ErrOverflow
+	}
+	fh.bulkIn = fh.bulkIn[1:]
+	return copy(data, packet), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if len(fh.interrupt) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	packet := fh.interrupt[0]
+	fh.interrupt = fh.interrupt[1:]
+	return copy(data[:length], packet), nil
+}
+
+func bulkEndpoint(address libusb.EndpointAddress, size int) *libusb.EndpointDescriptor {
+	return &libusb.EndpointDescriptor{
+		EndpointAddress: address,
+		Attributes:      0x02,
+		MaxPacketSize:   uint16(size),
+	}
+}
+
+func interruptEndpoint(address libusb.EndpointAddress, size int) *libusb.EndpointDescriptor {
+	return &libusb.EndpointDescriptor{
+		EndpointAddress: address,
+		Attributes:      0x03,
+		MaxPacketSize:   uint16(size),
+	}
+}
+
+// acmConfig models a composite device with a HID interface 0 ahead of an
+// ACM function on interfaces 1 and 2. The data interface's bulk endpoints
+// are only in alternate setting 1.
+func acmConfig(extra []byte) *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber: 0,
+				InterfaceClass:  libusb.InterfaceClassHID,
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:     1,
+				InterfaceClass:      libusb.InterfaceClassComm,
+				InterfaceSubClass:   subclassACM,
+				Extra:               extra,
+				EndpointDescriptors: libusb.EndpointDescriptors{interruptEndpoint(0x83, 8)},
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{
+				{InterfaceNumber: 2, InterfaceClass: libusb.InterfaceClassData},
+				{
+					InterfaceNumber:  2,
+					AlternateSetting: 1,
+					InterfaceClass:   libusb.InterfaceClassData,
+					EndpointDescriptors: libusb.EndpointDescriptors{
+						bulkEndpoint(0x02, 64),
+						bulkEndpoint(0x81, 64),
+					},
+				},
+			}},
+		},
+	}
+}
+
+var acmExtra = []byte{
+	0x05, 0x24, 0x00, 0x10, 0x01, // Header, CDC 1.10
+	0x05, 0x24, 0x01, 0x00, 0x02, // Call Management, data interface 2
+	0x04, 0x24, 0x02, 0x06, // ACM, line coding and send break
+	0x05, 0x24, 0x06, 0x01, 0x02, // Union, control 1, subordinate 2
+}
+
+func openPort(t *testing.T, fh *fakeHandle) *Port {
+	t.Helper()
+	functions, err := FindFunctions(acmConfig(acmExtra))
+	if err != nil || len(functions) != 1 {
+		t.Fatalf("FindFunctions = %v, %v; want one function", functions, err)
+	}
+	port, err := Open(fh, functions[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return port
+}
+
+func TestFindFunctions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		extra []byte
+	}{
+		{"union", acmExtra},
+		{"call management only", acmExtra[5:14]},
+		{"no functional descriptors", nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			functions, err := FindFunctions(acmConfig(tc.extra))
+			if err != nil {
+				t.Fatalf("FindFunctions: unexpected error %v", err)
+			}
+			if len(functions) != 1 {
+				t.Fatalf("got %d functions, want 1", len(functions))
+			}
+			fn := functions[0]
+			if fn.Control.InterfaceNumber != 1 {
+				t.Errorf("control interface = %d, want 1", fn.Control.InterfaceNumber)
+			}
+			if fn.Data.InterfaceNumber != 2 || fn.Data.AlternateSetting != 1 {
+				t.Errorf("data interface = %d alt %d, want 2 alt 1",
+					fn.Data.InterfaceNumber, fn.Data.AlternateSetting)
+			}
+		})
+	}
+	if _, err := FindFunctions(nil); err == nil {
+		t.Error("FindFunctions(nil): expected error, got nil")
+	}
+	broken := acmConfig([]byte{0x05, 0x24, 0x00})
+	if _, err := FindFunctions(broken); err == nil {
+		t.Error("FindFunctions with truncated descriptors: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	port := openPort(t, fh)
+	if port.InEndpoint != 0x81 || port.OutEndpoint != 0x02 || port.NotifyEndpoint != 0x83 {
+		t.Errorf("endpoints = %#02x/%#02x/%#02x, want 0x81/0x02/0x83",
+			port.InEndpoint, port.OutEndpoint, port.NotifyEndpoint)
+	}
+	if !port.Capabilities.LineCoding() || !port.Capabilities.SendBreak() {
+		t.Errorf("Capabilities = %#02x, want line coding and send break", port.Capabilities)
+	}
+	if err := port.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{
+		"detach 1", "claim 1", "detach 2", "claim 2", "alt 2 1",
+		"release 2", "release 1", "attach 2", "attach 1",
+	}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := port.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := port.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestOpenReleasesOnClaimFailure(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{
+		Active: true,
+		Fail:   map[string]error{"claim 2": libusb.ErrBusy},
+	}}
+	functions, _ := FindFunctions(acmConfig(acmExtra))
+	if _, err := Open(fh, functions[0]); !errors.Is(err, libusb.ErrBusy) {
+		t.Fatalf("Open: got %v, want the claim error", err)
+	}
+	want := []string{
+		"detach 1", "claim 1", "detach 2", "claim 2", "attach 2", "release 1", "attach 1",
+	}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestLineRequests(t *testing.T) {
+	fh := &fakeHandle{controlData: []byte{0x00, 0xC2, 0x01, 0x00, 0x00, 0x00, 0x08}}
+	port := openPort(t, fh)
+
+	lc := LineCoding{BaudRate: 115200, StopBits: TwoStopBits, Parity: ParityEven, DataBits: 7}
+	if err := port.SetLineCoding(lc); err != nil {
+		t.Fatalf("SetLineCoding: unexpected error %v", err)
+	}
+	got, err := port.LineCoding()
+	if err != nil {
+		t.Fatalf("LineCoding: unexpected error %v", err)
+	}
+	if want := (LineCoding{BaudRate: 115200, DataBits: 8}); got != want {
+		t.Errorf("LineCoding = %v, want %v", got, want)
+	}
+	if got.String() != "115200 8N1" {
+		t.Errorf("LineCoding.String = %q, want 115200 8N1", got.String())
+	}
+	if err := port.SetDTR(true); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	if err := port.SetRTS(true); err != nil {
+		t.Fatalf("SetRTS: unexpected error %v", err)
+	}
+	if err := port.SetDTR(false); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	if err := port.SendBreak(250); err != nil {
+		t.Fatalf("SendBreak: unexpected error %v", err)
+	}
+
+	want := []controlRequest{
+		{false, requestSetLineCoding, 0, 1, []byte{0x00, 0xC2, 0x01, 0x00, 0x02, 0x02, 0x07}},
+		{true, requestGetLineCoding, 0, 1, nil},
+		{false, requestSetControlLineState, 0x01, 1, nil},
+		{false, requestSetControlLineState, 0x03, 1, nil},
+		{false, requestSetControlLineState, 0x02, 1, nil},
+		{false, requestSendBreak, 250, 1, nil},
+	}
+	if len(fh.requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(fh.requests), len(want))
+	}
+	for i, req := range fh.requests {
+		w := want[i]
+		if req.in != w.in || req.request != w.request || req.value != w.value ||
+			req.index != w.index || !bytes.Equal(req.data, w.data) {
+			t.Errorf("request %d = %+v, want %+v", i, req, w)
+		}
+	}
+}
+
+func TestSetLineCodingValidation(t *testing.T) {
+	port := openPort(t, &fakeHandle{})
+	testCases := []LineCoding{
+		{BaudRate: 0, DataBits: 8},
+		{BaudRate: 9600, DataBits: 9},
+		{BaudRate: 9600, DataBits: 8, Parity: 5},
+		{BaudRate: 9600, DataBits: 8, StopBits: 3},
+	}
+	for _, lc := range testCases {
+		if err := port.SetLineCoding(lc); err == nil {
+			t.Errorf("SetLineCoding(%+v): expected error, got nil", lc)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	fh := &fakeHandle{bulkIn: [][]byte{[]byte("hello"), {}, []byte(" world")}}
+	port := openPort(t, fh)
+
+	buf := make([]byte, 3)
+	var got []byte
+	for len(got) < len("hello world") {
+		n, err := port.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: unexpected error %v after %q", err, got)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("Read = %q, want %q", got, "hello world")
+	}
+	_, err := port.Read(buf)
+	var code libusb.ErrorCode
+	if !errors.As(err, &code) || !code.Timeout() {
+		t.Errorf("Read with no data: got %v, want a timeout", err)
+	}
+
+	if _, err := io.WriteString(port, "*IDN?\n"); err != nil {
+		t.Fatalf("Write: unexpected error %v", err)
+	}
+	if string(fh.bulkOut) != "*IDN?\n" {
+		t.Errorf("bulk OUT = %q, want *IDN?\\n", fh.bulkOut)
+	}
+}
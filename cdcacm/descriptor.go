@@ -0,0 +1,140 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cdcacm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// descriptorTypeCSInterface is the CS_INTERFACE descriptor type shared by all
+// CDC functional descriptors.
+const descriptorTypeCSInterface = 0x24
+
+// FunctionalSubtype is the bDescriptorSubtype of a CDC functional descriptor.
+type FunctionalSubtype byte
+
+// CDC functional descriptor subtypes used by ACM functions.
+const (
+	SubtypeHeader         FunctionalSubtype = 0x00
+	SubtypeCallManagement FunctionalSubtype = 0x01
+	SubtypeACM            FunctionalSubtype = 0x02
+	SubtypeUnion          FunctionalSubtype = 0x06
+)
+
+var functionalSubtypes = map[FunctionalSubtype]string{
+	SubtypeHeader:         "Header",
+	SubtypeCallManagement: "Call Management",
+	SubtypeACM:            "Abstract Control Management",
+	SubtypeUnion:          "Union",
+}
+
+// String implements the Stringer interface for FunctionalSubtype.
+func (subtype FunctionalSubtype) String() string {
+	return functionalSubtypes[subtype]
+}
+
+// ACMCapabilities is the bmCapabilities field of the Abstract Control
+// Management functional descriptor.
+type ACMCapabilities byte
+
+// CommFeature reports support for SET/GET/CLEAR_COMM_FEATURE.
+func (caps ACMCapabilities) CommFeature() bool { return caps&0x01 != 0 }
+
+// LineCoding reports support for SET/GET_LINE_CODING,
+// SET_CONTROL_LINE_STATE, and the SERIAL_STATE notification.
+func (caps ACMCapabilities) LineCoding() bool { return caps&0x02 != 0 }
+
+// SendBreak reports support for SEND_BREAK.
+func (caps ACMCapabilities) SendBreak() bool { return caps&0x04 != 0 }
+
+// NetworkConnection reports support for the NETWORK_CONNECTION
+// notification.
+func (caps ACMCapabilities) NetworkConnection() bool { return caps&0x08 != 0 }
+
+// CallManagementDescriptor models the Call Management functional
+// descriptor.
+type CallManagementDescriptor struct {
+	Capabilities  byte
+	DataInterface int
+}
+
+// UnionDescriptor models the Union functional descriptor, which groups the
+// communication interface with its data interfaces.
+type UnionDescriptor struct {
+	ControlInterface      int
+	SubordinateInterfaces []int
+}
+
+// FunctionalDescriptors holds the CDC functional descriptors found in the
+// extra bytes of a communication interface. Descriptors the interface
+// doesn't provide are nil.
+type FunctionalDescriptors struct {
+	// CDCVersion is the bcdCDC field of the header descriptor.
+	CDCVersion      uint16
+	CallManagement  *CallManagementDescriptor
+	ACMCapabilities *ACMCapabilities
+	Union           *UnionDescriptor
+}
+
+// ParseFunctionalDescriptors walks the class-specific descriptors following
+// a communication interface descriptor. Subtypes other than header, call
+// management, ACM, and union are skipped.
+func ParseFunctionalDescriptors(extra []byte) (*FunctionalDescriptors, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("cdcacm: %w", err)
+	}
+	fd := &FunctionalDescriptors{}
+	for _, desc := range descs {
+		if len(desc) < 3 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		subtype := FunctionalSubtype(desc[2])
+		minLength, ok := functionalLengths[subtype]
+		if !ok {
+			continue
+		}
+		if len(desc) < minLength {
+			return nil, fmt.Errorf(
+				"cdcacm: %s functional descriptor is %d bytes; want at least %d",
+				subtype,
+				len(desc),
+				minLength,
+			)
+		}
+		switch subtype {
+		case SubtypeHeader:
+			fd.CDCVersion = binary.LittleEndian.Uint16(desc[3:5])
+		case SubtypeCallManagement:
+			fd.CallManagement = &CallManagementDescriptor{
+				Capabilities:  desc[3],
+				DataInterface: int(desc[4]),
+			}
+		case SubtypeACM:
+			caps := ACMCapabilities(desc[3])
+			fd.ACMCapabilities = &caps
+		case SubtypeUnion:
+			union := &UnionDescriptor{ControlInterface: int(desc[3])}
+			for _, sub := range desc[4:] {
+				union.SubordinateInterfaces = append(union.SubordinateInterfaces, int(sub))
+			}
+			fd.Union = union
+		}
+	}
+	return fd, nil
+}
+
+// functionalLengths is the minimum bLength of each functional descriptor
+// this package decodes.
+var functionalLengths = map[FunctionalSubtype]int{
+	SubtypeHeader:         5,
+	SubtypeCallManagement: 5,
+	SubtypeACM:            4,
+	SubtypeUnion:          5,
+}
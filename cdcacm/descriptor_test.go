@@ -0,0 +1,61 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cdcacm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFunctionalDescriptors(t *testing.T) {
+	// A vendor descriptor and an unknown CDC subtype are skipped.
+	extra := append([]byte{0x03, 0xFF, 0x00, 0x04, 0x24, 0x0A, 0x00}, acmExtra...)
+	extra = append(extra, 0x06, 0x24, 0x06, 0x00, 0x01, 0x02)
+	fd, err := ParseFunctionalDescriptors(extra)
+	if err != nil {
+		t.Fatalf("ParseFunctionalDescriptors: unexpected error %v", err)
+	}
+	if fd.CDCVersion != 0x0110 {
+		t.Errorf("CDCVersion = %#04x, want 0x0110", fd.CDCVersion)
+	}
+	if fd.CallManagement == nil || fd.CallManagement.DataInterface != 2 {
+		t.Errorf("CallManagement = %+v, want data interface 2", fd.CallManagement)
+	}
+	if fd.ACMCapabilities == nil || *fd.ACMCapabilities != 0x06 {
+		t.Errorf("ACMCapabilities = %v, want 0x06", fd.ACMCapabilities)
+	}
+	caps := *fd.ACMCapabilities
+	if caps.CommFeature() || !caps.LineCoding() || !caps.SendBreak() || caps.NetworkConnection() {
+		t.Errorf("ACMCapabilities %#02x decoded incorrectly", caps)
+	}
+	// The last union descriptor wins.
+	if fd.Union == nil || fd.Union.ControlInterface != 0 ||
+		!slices.Equal(fd.Union.SubordinateInterfaces, []int{1, 2}) {
+		t.Errorf("Union = %+v, want control 0 with subordinates [1 2]", fd.Union)
+	}
+}
+
+func TestParseFunctionalDescriptorsErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		extra []byte
+	}{
+		{"overrun", []byte{0x05, 0x24, 0x00, 0x10}},
+		{"short union", []byte{0x04, 0x24, 0x06, 0x00}},
+		{"short ACM", []byte{0x03, 0x24, 0x02}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseFunctionalDescriptors(tc.extra); err == nil {
+				t.Error("ParseFunctionalDescriptors: expected error, got nil")
+			}
+		})
+	}
+	fd, err := ParseFunctionalDescriptors(nil)
+	if err != nil || fd.Union != nil || fd.ACMCapabilities != nil {
+		t.Errorf("ParseFunctionalDescriptors(nil) = %+v, %v; want empty", fd, err)
+	}
+}
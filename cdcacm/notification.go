@@ -0,0 +1,211 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cdcacm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// notificationHeaderSize is the length of the setup-packet-like header that
+// starts every notification.
+const notificationHeaderSize = 8
+
+// notificationPoll is the interrupt transfer timeout in milliseconds used by
+// WatchSerialState, which bounds how long Close waits for it to return.
+const notificationPoll = 250
+
+// NotificationCode is the bNotification field of a CDC notification.
+type NotificationCode byte
+
+// Notifications sent by ACM functions.
+const (
+	NetworkConnection       NotificationCode = 0x00
+	ResponseAvailable       NotificationCode = 0x01
+	SerialStateNotification NotificationCode = 0x20
+)
+
+var notificationCodes = map[NotificationCode]string{
+	NetworkConnection:       "NETWORK_CONNECTION",
+	ResponseAvailable:       "RESPONSE_AVAILABLE",
+	SerialStateNotification: "SERIAL_STATE",
+}
+
+// String implements the Stringer interface for NotificationCode.
+func (code NotificationCode) String() string {
+	return notificationCodes[code]
+}
+
+// Notification is a notification read from the interrupt endpoint of the
+// communication interface.
+type Notification struct {
+	Code      NotificationCode
+	Value     uint16
+	Interface int
+	Data      []byte
+}
+
+// SerialState returns the UART state bitmap carried by a SERIAL_STATE
+// notification.
+func (n *Notification) SerialState() (SerialState, bool) {
+	if n.Code != SerialStateNotification || len(n.Data) < 2 {
+		return 0, false
+	}
+	return SerialState(binary.LittleEndian.Uint16(n.Data)), true
+}
+
+// SerialState is the UART state bitmap of the SERIAL_STATE notification.
+// The error and break bits are set once per event rather than held.
+type SerialState uint16
+
+// DCD reports the receiver carrier detect signal (bRxCarrier).
+func (state SerialState) DCD() bool { return state&0x01 != 0 }
+
+// DSR reports the transmission carrier signal (bTxCarrier).
+func (state SerialState) DSR() bool { return state&0x02 != 0 }
+
+// Break reports that a break was detected.
+func (state SerialState) Break() bool { return state&0x04 != 0 }
+
+// Ring reports the ring signal.
+func (state SerialState) Ring() bool { return state&0x08 != 0 }
+
+// FramingError reports that a framing error occurred.
+func (state SerialState) FramingError() bool { return state&0x10 != 0 }
+
+// ParityError reports that a parity error occurred.
+func (state SerialState) ParityError() bool { return state&0x20 != 0 }
+
+// Overrun reports that received data was lost to an overrun.
+func (state SerialState) Overrun() bool { return state&0x40 != 0 }
+
+var serialStateBits = []string{"DCD", "DSR", "Break", "Ring", "Framing", "Parity", "Overrun"}
+
+// String lists the set bits, such as "DCD|DSR".
+func (state SerialState) String() string {
+	var names []string
+	for i, name := range serialStateBits {
+		if state&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+func parseNotification(data []byte) (*Notification, error) {
+	if len(data) < notificationHeaderSize {
+		return nil, fmt.Errorf(
+			"cdcacm: notification is %d bytes; want at least %d",
+			len(data),
+			notificationHeaderSize,
+		)
+	}
+	length := int(binary.LittleEndian.Uint16(data[6:8]))
+	if len(data) < notificationHeaderSize+length {
+		return nil, fmt.Errorf(
+			"cdcacm: %s notification has %d data bytes; want %d",
+			NotificationCode(data[1]),
+			len(data)-notificationHeaderSize,
+			length,
+		)
+	}
+	return &Notification{
+		Code:      NotificationCode(data[1]),
+		Value:     binary.LittleEndian.Uint16(data[2:4]),
+		Interface: int(binary.LittleEndian.Uint16(data[4:6])),
+		Data:      data[notificationHeaderSize : notificationHeaderSize+length],
+	}, nil
+}
+
+// notificationBufferSize rounds the room for a SERIAL_STATE notification up
+// to whole packets, so that a device sending full packets can't overflow the
+// transfer.
+func notificationBufferSize(packetSize int) int {
+	const minSize = 16
+	packetSize = max(packetSize, 1)
+	return (minSize + packetSize - 1) / packetSize * packetSize
+}
+
+// ReadNotification reads one notification from the interrupt endpoint,
+// waiting up to Timeout. It shouldn't be used while WatchSerialState is
+// running.
+func (port *Port) ReadNotification() (*Notification, error) {
+	return port.readNotification(port.Timeout)
+}
+
+func (port *Port) readNotification(timeout int) (*Notification, error) {
+	if port.NotifyEndpoint == 0 {
+		return nil, fmt.Errorf("cdcacm: function has no notification endpoint")
+	}
+	data := make([]byte, port.notifySize)
+	n, err := port.handle.InterruptTransfer(port.NotifyEndpoint, data, len(data), timeout)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := parseNotification(data[:n])
+	if err != nil {
+		return nil, err
+	}
+	if state, ok := notification.SerialState(); ok {
+		port.mu.Lock()
+		port.state = state
+		port.mu.Unlock()
+	}
+	return notification, nil
+}
+
+// SerialState returns the state from the most recent SERIAL_STATE
+// notification.
+func (port *Port) SerialState() SerialState {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.state
+}
+
+// WatchSerialState reads notifications until the context is done or the
+// port is closed, calling fn with the state from each SERIAL_STATE
+// notification. It returns nil once the port is closed, the context's error
+// if it is canceled, or the first transfer error other than a timeout.
+func (port *Port) WatchSerialState(ctx context.Context, fn func(SerialState)) error {
+	if port.NotifyEndpoint == 0 {
+		return fmt.Errorf("cdcacm: function has no notification endpoint")
+	}
+	port.mu.Lock()
+	if port.closed {
+		port.mu.Unlock()
+		return os.ErrClosed
+	}
+	port.watchers.Add(1)
+	port.mu.Unlock()
+	defer port.watchers.Done()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if port.isClosed() {
+			return nil
+		}
+		notification, err := port.readNotification(notificationPoll)
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if state, ok := notification.SerialState(); ok {
+			fn(state)
+		}
+	}
+}
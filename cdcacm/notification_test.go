@@ -0,0 +1,131 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cdcacm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func serialStateNotification(iface byte, state uint16) []byte {
+	return []byte{0xA1, 0x20, 0x00, 0x00, iface, 0x00, 0x02, 0x00, byte(state), byte(state >> 8)}
+}
+
+func TestReadNotification(t *testing.T) {
+	fh := &fakeHandle{interrupt: [][]byte{serialStateNotification(1, 0x0043)}}
+	port := openPort(t, fh)
+	n, err := port.ReadNotification()
+	if err != nil {
+		t.Fatalf("ReadNotification: unexpected error %v", err)
+	}
+	if n.Code != SerialStateNotification || n.Interface != 1 {
+		t.Errorf("notification = %+v, want SERIAL_STATE for interface 1", n)
+	}
+	state, ok := n.SerialState()
+	if !ok {
+		t.Fatal("SerialState: ok = false, want true")
+	}
+	if !state.DCD() || !state.DSR() || !state.Overrun() || state.Ring() || state.Break() {
+		t.Errorf("state = %v, want DCD|DSR|Overrun", state)
+	}
+	if state.String() != "DCD|DSR|Overrun" {
+		t.Errorf("state.String = %q, want DCD|DSR|Overrun", state.String())
+	}
+	if port.SerialState() != state {
+		t.Errorf("Port.SerialState = %v, want %v", port.SerialState(), state)
+	}
+}
+
+func TestParseNotificationErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"short header", []byte{0xA1, 0x20, 0x00}},
+		{"missing data", serialStateNotification(1, 0)[:9]},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseNotification(tc.data); err == nil {
+				t.Error("parseNotification: expected error, got nil")
+			}
+		})
+	}
+	response, err := parseNotification([]byte{0xA1, 0x01, 0, 0, 1, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("parseNotification: unexpected error %v", err)
+	}
+	if _, ok := response.SerialState(); ok || response.Code != ResponseAvailable {
+		t.Errorf("notification = %+v, want RESPONSE_AVAILABLE without a serial state", response)
+	}
+}
+
+func TestNotificationBufferSize(t *testing.T) {
+	testCases := []struct {
+		packetSize int
+		expected   int
+	}{
+		{8, 16},
+		{10, 20},
+		{16, 16},
+		{64, 64},
+		{0, 16},
+	}
+	for _, tc := range testCases {
+		if got := notificationBufferSize(tc.packetSize); got != tc.expected {
+			t.Errorf("notificationBufferSize(%d) = %d, want %d", tc.packetSize, got, tc.expected)
+		}
+	}
+}
+
+func TestWatchSerialState(t *testing.T) {
+	fh := &fakeHandle{interrupt: [][]byte{
+		serialStateNotification(1, 0x0003),
+		{0xA1, 0x01, 0, 0, 1, 0, 0, 0},
+		serialStateNotification(1, 0x0001),
+	}}
+	port := openPort(t, fh)
+
+	states := make(chan SerialState, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- port.WatchSerialState(context.Background(), func(state SerialState) {
+			states <- state
+		})
+	}()
+	for _, want := range []SerialState{0x0003, 0x0001} {
+		select {
+		case got := <-states:
+			if got != want {
+				t.Errorf("state = %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a serial state")
+		}
+	}
+	if err := port.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("WatchSerialState after Close: got %v, want nil", err)
+	}
+}
+
+func TestWatchSerialStateCanceled(t *testing.T) {
+	port := openPort(t, &fakeHandle{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := port.WatchSerialState(ctx, func(SerialState) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchSerialState: got %v, want context.Canceled", err)
+	}
+	port.NotifyEndpoint = 0
+	if err := port.WatchSerialState(context.Background(), func(SerialState) {}); err == nil {
+		t.Error("WatchSerialState without an endpoint: expected error, got nil")
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ADIv5 debug port registers. DPIDR is read and DPAbort written at the
+// same address.
+const (
+	DPIDR      = 0x00
+	DPAbort    = 0x00
+	DPCtrlStat = 0x04
+	DPSelect   = 0x08
+	DPRdBuff   = 0x0C
+)
+
+// ADIv5 MEM-AP registers.
+const (
+	APCSW  = 0x00
+	APTAR  = 0x04
+	APDRW  = 0x0C
+	APCFG  = 0xF4
+	APBase = 0xF8
+	APIDR  = 0xFC
+)
+
+// DP CTRL/STAT power-up request and acknowledge bits.
+const (
+	CtrlCSysPwrUpAck = 1 << 31
+	CtrlCSysPwrUpReq = 1 << 30
+	CtrlCDbgPwrUpAck = 1 << 29
+	CtrlCDbgPwrUpReq = 1 << 28
+)
+
+// DP ABORT bits.
+const (
+	AbortDAPAbort   = 1 << 0
+	AbortStkCmpClr  = 1 << 1
+	AbortStkErrClr  = 1 << 2
+	AbortWdErrClr   = 1 << 3
+	AbortOrunErrClr = 1 << 4
+)
+
+const (
+	// cswWord is the MEM-AP CSW value for word accesses that increment
+	// TAR, with the privileged data access and debugger master type bits
+	// Cortex-M targets expect.
+	cswWord = 0x23000012
+	// tarWrap is the boundary at which TAR auto-increment is only
+	// guaranteed to wrap, so block accesses are split there.
+	tarWrap = 0x400
+	// powerUpPolls is the number of times PowerUp reads CTRL/STAT for the
+	// acknowledgements.
+	powerUpPolls = 100
+)
+
+// ReadDP reads a debug port register.
+func (dev *Device) ReadDP(addr uint8) (uint32, error) {
+	values, err := dev.Transfer(TransferRequest{Read: true, Addr: addr})
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// WriteDP writes a debug port register.
+func (dev *Device) WriteDP(addr uint8, value uint32) error {
+	if addr == DPSelect {
+		dev.invalidateSelect()
+	}
+	_, err := dev.Transfer(TransferRequest{Addr: addr, Value: value})
+	return err
+}
+
+// ReadAP reads a register of an access port, selecting the AP and the
+// register's bank first if needed.
+func (dev *Device) ReadAP(ap uint8, addr uint8) (uint32, error) {
+	dev.apMu.Lock()
+	defer dev.apMu.Unlock()
+	values, err := dev.transferAP(ap, addr, TransferRequest{AP: true, Read: true, Addr: addr})
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// WriteAP writes a register of an access port, selecting the AP and the
+// register's bank first if needed.
+func (dev *Device) WriteAP(ap uint8, addr uint8, value uint32) error {
+	dev.apMu.Lock()
+	defer dev.apMu.Unlock()
+	_, err := dev.transferAP(ap, addr, TransferRequest{AP: true, Addr: addr, Value: value})
+	return err
+}
+
+// transferAP runs accesses to the registers of one AP bank, writing
+// SELECT ahead of them unless it already holds the AP and bank. The
+// caller holds apMu.
+func (dev *Device) transferAP(ap uint8, bank uint8, reqs ...TransferRequest) ([]uint32, error) {
+	sel := uint32(ap)<<24 | uint32(bank&0xF0)
+	selecting := !dev.selectValid || dev.selected != sel
+	if selecting {
+		reqs = append([]TransferRequest{{Addr: DPSelect, Value: sel}}, reqs...)
+	}
+	values, err := dev.Transfer(reqs...)
+	if err != nil {
+		dev.selectValid = false
+		var transferErr *TransferError
+		if selecting && errors.As(err, &transferErr) && transferErr.Index > 0 {
+			transferErr.Index--
+		}
+		return nil, err
+	}
+	dev.selected, dev.selectValid = sel, true
+	return values, nil
+}
+
+// invalidateSelect forgets the SELECT value, so the next AP access
+// writes it.
+func (dev *Device) invalidateSelect() {
+	dev.apMu.Lock()
+	defer dev.apMu.Unlock()
+	dev.selectValid = false
+}
+
+// ClearErrors clears the sticky error flags of the debug port, as needed
+// after a FAULT.
+func (dev *Device) ClearErrors() error {
+	return dev.WriteDP(DPAbort, AbortStkCmpClr|AbortStkErrClr|AbortWdErrClr|AbortOrunErrClr)
+}
+
+// PowerUp requests the system and debug power domains and waits for the
+// debug port to acknowledge them.
+func (dev *Device) PowerUp() error {
+	if err := dev.WriteDP(DPCtrlStat, CtrlCSysPwrUpReq|CtrlCDbgPwrUpReq); err != nil {
+		return err
+	}
+	const acks = CtrlCSysPwrUpAck | CtrlCDbgPwrUpAck
+	for i := 0; i < powerUpPolls; i++ {
+		stat, err := dev.ReadDP(DPCtrlStat)
+		if err != nil {
+			return err
+		}
+		if stat&acks == acks {
+			return nil
+		}
+	}
+	return fmt.Errorf("cmsisdap: debug port didn't acknowledge power-up")
+}
+
+// ReadMemory reads n words of target memory from a word-aligned address
+// through a MEM-AP.
+func (dev *Device) ReadMemory(ap uint8, addr uint32, n int) ([]uint32, error) {
+	if addr%4 != 0 {
+		return nil, fmt.Errorf("cmsisdap: address %#08x isn't word aligned", addr)
+	}
+	dev.apMu.Lock()
+	defer dev.apMu.Unlock()
+	values := make([]uint32, 0, n)
+	for len(values) < n {
+		count := min(n-len(values), int(tarWrap-addr%tarWrap)/4)
+		if err := dev.setupMemAP(ap, addr, len(values) == 0); err != nil {
+			return nil, err
+		}
+		block, err := dev.ReadBlock(true, APDRW, count)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, block...)
+		addr += uint32(4 * count)
+	}
+	return values, nil
+}
+
+// WriteMemory writes words of target memory from a word-aligned address
+// through a MEM-AP.
+func (dev *Device) WriteMemory(ap uint8, addr uint32, values []uint32) error {
+	if addr%4 != 0 {
+		return fmt.Errorf("cmsisdap: address %#08x isn't word aligned", addr)
+	}
+	dev.apMu.Lock()
+	defer dev.apMu.Unlock()
+	for start := 0; start < len(values); {
+		count := min(len(values)-start, int(tarWrap-addr%tarWrap)/4)
+		if err := dev.setupMemAP(ap, addr, start == 0); err != nil {
+			return err
+		}
+		if err := dev.WriteBlock(true, APDRW, values[start:start+count]); err != nil {
+			return err
+		}
+		start += count
+		addr += uint32(4 * count)
+	}
+	return nil
+}
+
+// setupMemAP points a MEM-AP's TAR at an address, first setting CSW for
+// incrementing word accesses if csw is true. The caller holds apMu.
+func (dev *Device) setupMemAP(ap uint8, addr uint32, csw bool) error {
+	var reqs []TransferRequest
+	if csw {
+		reqs = append(reqs, TransferRequest{AP: true, Addr: APCSW, Value: cswWord})
+	}
+	reqs = append(reqs, TransferRequest{AP: true, Addr: APTAR, Value: addr})
+	_, err := dev.transferAP(ap, APCSW, reqs...)
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// selectWrites returns the values written to SELECT by DAP_Transfer
+// commands.
+func selectWrites(written [][]byte) []uint32 {
+	var sels []uint32
+	for _, cmd := range written {
+		if cmd[0] != cmdTransfer {
+			continue
+		}
+		rest := cmd[3:]
+		for i := 0; i < int(cmd[2]); i++ {
+			req := rest[0]
+			rest = rest[1:]
+			if req&requestRead != 0 {
+				continue
+			}
+			if req&(requestAP|requestAddr) == DPSelect {
+				sels = append(sels, binary.LittleEndian.Uint32(rest))
+			}
+			rest = rest[4:]
+		}
+	}
+	return sels
+}
+
+func TestRegisters(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	if id, err := dev.ReadDP(DPIDR); err != nil || id != testDPIDR {
+		t.Errorf("ReadDP(DPIDR) = %#x, %v", id, err)
+	}
+	if err := dev.PowerUp(); err != nil {
+		t.Fatalf("PowerUp: unexpected error %v", err)
+	}
+	if id, err := dev.ReadAP(1, APIDR); err != nil || id != testAPIDR {
+		t.Errorf("ReadAP(1, APIDR) = %#x, %v", id, err)
+	}
+	if err := dev.WriteAP(1, APTAR, 0x1000); err != nil {
+		t.Errorf("WriteAP(1, APTAR): unexpected error %v", err)
+	}
+	if tar, err := dev.ReadAP(1, APTAR); err != nil || tar != 0x1000 {
+		t.Errorf("ReadAP(1, APTAR) = %#x, %v", tar, err)
+	}
+	if err := dev.ClearErrors(); err != nil || fh.probe.target.abort != 0x1E {
+		t.Errorf("ClearErrors = %v, ABORT %#x", err, fh.probe.target.abort)
+	}
+	// The AP bank is selected once for IDR and once for TAR, and again
+	// after SELECT is written directly.
+	if err := dev.WriteDP(DPSelect, 0); err != nil {
+		t.Fatalf("WriteDP(DPSelect): unexpected error %v", err)
+	}
+	if _, err := dev.ReadAP(1, APTAR); err != nil {
+		t.Fatalf("ReadAP(1, APTAR): unexpected error %v", err)
+	}
+	want := []uint32{0x010000F0, 0x01000000, 0, 0x01000000}
+	if sels := selectWrites(fh.written); !slices.Equal(sels, want) {
+		t.Errorf("SELECT writes = %#x, want %#x", sels, want)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	fh := newFakeHandle(64, 4)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	// The words straddle a 1KB boundary, where TAR is written again.
+	addr := uint32(0x20000400 - 4*10)
+	values := make([]uint32, 30)
+	for i := range values {
+		values[i] = 0xC0DE0000 | uint32(i)
+	}
+	if err := dev.WriteMemory(0, addr, values); err != nil {
+		t.Fatalf("WriteMemory: unexpected error %v", err)
+	}
+	mem := fh.probe.target.mem
+	if mem[addr] != values[0] || mem[0x20000400] != values[10] || mem[addr+4*29] != values[29] {
+		t.Errorf("memory = %#x", mem)
+	}
+	got, err := dev.ReadMemory(0, addr, len(values))
+	if err != nil || !slices.Equal(got, values) {
+		t.Errorf("ReadMemory = %#x, %v", got, err)
+	}
+	if fh.probe.target.csw != cswWord {
+		t.Errorf("CSW = %#x, want %#x", fh.probe.target.csw, cswWord)
+	}
+
+	if _, err := dev.ReadMemory(0, 0x20000002, 1); err == nil {
+		t.Error("unaligned ReadMemory: expected error, got nil")
+	}
+	if err := dev.WriteMemory(0, 0x20000002, values); err == nil {
+		t.Error("unaligned WriteMemory: expected error, got nil")
+	}
+	fh.probe.target.faults[addr] = true
+	var transferErr *TransferError
+	if _, err := dev.ReadMemory(0, addr, 1); !errors.As(err, &transferErr) {
+		t.Errorf("ReadMemory of a faulting address: got %v, want a *TransferError", err)
+	}
+}
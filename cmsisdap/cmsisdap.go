@@ -0,0 +1,302 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package cmsisdap implements the CMSIS-DAP v2 debug probe protocol on top of
+libusb, for flashing and testing microcontrollers through their Arm Debug
+Interface.
+
+A CMSIS-DAP v2 probe has a vendor-specific interface whose string contains
+"CMSIS-DAP", with a bulk endpoint pair that carries commands and their
+responses and an optional bulk IN endpoint for SWO trace data.
+FindInterfaces returns such interfaces, reading the interface strings
+through the handle. Open claims one and reads the probe's packet size and
+packet count.
+
+Device runs the DAP commands as methods: Info, Connect, Disconnect,
+SWJClock, SWJSequence, TransferConfigure, Transfer and the block
+transfers ReadBlock and WriteBlock. Transfers larger than a packet are
+split, and up to the probe's packet count of commands are queued in the
+probe at once so the transfers overlap the USB round trips. A transfer
+the target doesn't acknowledge returns a *TransferError.
+
+On top of the transfers, ReadDP, WriteDP, ReadAP and WriteAP access the
+ADIv5 debug port and access port registers, selecting the AP register
+bank as needed, and PowerUp, ReadMemory and WriteMemory use a MEM-AP to
+reach the target's memory.
+*/
+package cmsisdap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds, for
+// sending each command and reading its response.
+const DefaultTimeout = 1000
+
+// interfaceName is the text the interface string of a CMSIS-DAP v2 probe
+// contains.
+const interfaceName = "CMSIS-DAP"
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: the bulk pair
+// carrying DAP commands, and string descriptors to find the interface.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	StringDescriptorASCII(descIndex uint8) (string, error)
+}
+
+// Interface is the CMSIS-DAP v2 interface of a probe and its endpoints.
+// SWO is nil if the probe doesn't stream trace data.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+	BulkIn     *libusb.EndpointDescriptor
+	SWO        *libusb.EndpointDescriptor
+}
+
+// FindInterfaces returns the CMSIS-DAP v2 interfaces in a configuration,
+// reading the interface strings of its vendor-specific interfaces.
+func FindInterfaces(handle Handle, config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if handle == nil || config == nil {
+		return nil, fmt.Errorf("cmsisdap: nil handle or configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassVendorSpec,
+	) {
+		if desc.AlternateSetting != 0 || desc.InterfaceIndex == 0 {
+			continue
+		}
+		name, err := handle.StringDescriptorASCII(uint8(desc.InterfaceIndex))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"cmsisdap: reading string of interface %d: %w",
+				desc.InterfaceNumber,
+				err,
+			)
+		}
+		if !strings.Contains(name, interfaceName) {
+			continue
+		}
+		iface := &Interface{Descriptor: desc}
+		for _, ep := range desc.EndpointDescriptors {
+			if ep.TransferType() != libusb.BulkTransfer {
+				continue
+			}
+			switch in := ep.Direction() == libusb.EndpointIn; {
+			case !in && iface.BulkOut == nil:
+				iface.BulkOut = ep
+			case in && iface.BulkIn == nil:
+				iface.BulkIn = ep
+			case in && iface.SWO == nil:
+				iface.SWO = ep
+			}
+		}
+		if iface.BulkOut == nil || iface.BulkIn == nil {
+			return nil, fmt.Errorf(
+				"cmsisdap: interface %d lacks a bulk endpoint pair",
+				desc.InterfaceNumber,
+			)
+		}
+		found = append(found, iface)
+	}
+	return found, nil
+}
+
+// Device is a claimed CMSIS-DAP v2 interface. Its methods may be called
+// concurrently; the commands are run one at a time.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+	// Index is the index of the target in the JTAG chain. It's ignored
+	// for SWD.
+	Index uint8
+
+	cmdMu       sync.Mutex
+	packetSize  int
+	packetCount int
+	buf         []byte
+
+	apMu        sync.Mutex
+	selected    uint32
+	selectValid bool
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims a CMSIS-DAP v2 interface and reads the probe's packet size
+// and packet count. The interface is vendor-specific, so a kernel driver
+// is rarely bound, but one that is gets detached.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("cmsisdap: nil handle or interface")
+	}
+	dev := &Device{
+		handle:      handle,
+		Interface:   iface,
+		Timeout:     DefaultTimeout,
+		packetSize:  defaultPacketSize,
+		packetCount: 1,
+	}
+	dev.buf = make([]byte, dev.bufferSize())
+	num := iface.Descriptor.InterfaceNumber
+	claims, err := usbif.Claim(handle, "cmsisdap", num)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	if err := dev.readPacketInfo(); err != nil {
+		return nil, errors.Join(err, dev.Close())
+	}
+	return dev, nil
+}
+
+// Close releases the interface. The debug port stays as the last commands
+// left it; call Disconnect first to let go of the target. Calling Close
+// again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	dev.mu.Unlock()
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
+
+// PacketSize returns the largest command and response the probe takes.
+func (dev *Device) PacketSize() int {
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	return dev.packetSize
+}
+
+// PacketCount returns the number of commands the probe can queue.
+func (dev *Device) PacketCount() int {
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	return dev.packetCount
+}
+
+// bufferSize returns the size of the response buffer, the packet size
+// rounded up to whole USB packets.
+func (dev *Device) bufferSize() int {
+	size := dev.packetSize
+	if mps := int(dev.Interface.BulkIn.MaxPacketSize); mps > 0 {
+		size = (size + mps - 1) / mps * mps
+	}
+	return size
+}
+
+// exchange sends commands to the probe and returns their responses in
+// order. Up to the packet count of commands are sent ahead of their
+// responses. If a transfer fails, the responses to the commands already
+// sent are read and dropped so the next exchange starts in step.
+func (dev *Device) exchange(cmds ...[]byte) ([][]byte, error) {
+	if dev.isClosed() {
+		return nil, os.ErrClosed
+	}
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	resps := make([][]byte, 0, len(cmds))
+	sent := 0
+	for len(resps) < len(cmds) {
+		for sent < len(cmds) && sent-len(resps) < dev.packetCount {
+			if err := dev.write(cmds[sent]); err != nil {
+				dev.drain(sent - len(resps))
+				return nil, err
+			}
+			sent++
+		}
+		cmd := cmds[len(resps)]
+		resp, err := dev.read(cmd[0])
+		if err != nil {
+			dev.drain(sent - len(resps) - 1)
+			return nil, err
+		}
+		resps = append(resps, resp)
+	}
+	return resps, nil
+}
+
+func (dev *Device) write(cmd []byte) error {
+	if len(cmd) > dev.packetSize {
+		return fmt.Errorf(
+			"cmsisdap: command %#02x is %d bytes, the packet size is %d",
+			cmd[0],
+			len(cmd),
+			dev.packetSize,
+		)
+	}
+	n, err := dev.handle.BulkTransfer(
+		dev.Interface.BulkOut.EndpointAddress,
+		cmd,
+		len(cmd),
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("cmsisdap: sending command %#02x: %w", cmd[0], err)
+	}
+	if n != len(cmd) {
+		return fmt.Errorf("cmsisdap: sent %d of %d bytes of command %#02x", n, len(cmd), cmd[0])
+	}
+	return nil
+}
+
+// read reads the response to a command.
+func (dev *Device) read(id byte) ([]byte, error) {
+	n, err := dev.handle.BulkTransfer(
+		dev.Interface.BulkIn.EndpointAddress,
+		dev.buf,
+		len(dev.buf),
+		dev.Timeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cmsisdap: reading response to command %#02x: %w", id, err)
+	}
+	resp := dev.buf[:n]
+	switch {
+	case n > 0 && resp[0] == cmdInvalid && id != cmdInvalid:
+		return nil, fmt.Errorf("cmsisdap: probe doesn't support command %#02x", id)
+	case n == 0 || resp[0] != id:
+		return nil, fmt.Errorf("cmsisdap: got response % x to command %#02x", resp, id)
+	}
+	return slices.Clone(resp), nil
+}
+
+// drain reads and drops the responses to n commands.
+func (dev *Device) drain(n int) {
+	for i := 0; i < n; i++ {
+		if _, err := dev.handle.BulkTransfer(
+			dev.Interface.BulkIn.EndpointAddress,
+			dev.buf,
+			len(dev.buf),
+			dev.Timeout,
+		); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+// target simulates the debug port of a target with one MEM-AP.
+type target struct {
+	ctrlStat uint32
+	sel      uint32
+	abort    uint32
+	csw      uint32
+	tar      uint32
+	mem      map[uint32]uint32
+	// faults are addresses whose accesses the target answers with FAULT.
+	faults map[uint32]bool
+}
+
+const (
+	testDPIDR = 0x2BA01477
+	testAPIDR = 0x24770011
+)
+
+// access runs one register access, returning the value read and the
+// acknowledgement.
+func (tg *target) access(ap, read bool, addr uint8, value uint32) (uint32, uint8) {
+	if !ap {
+		switch {
+		case addr == DPIDR && read:
+			return testDPIDR, AckOK
+		case addr == DPAbort:
+			tg.abort = value
+		case addr == DPCtrlStat && read:
+			return tg.ctrlStat, AckOK
+		case addr == DPCtrlStat:
+			// The power-up acknowledgements follow their requests.
+			tg.ctrlStat = value | value&(CtrlCSysPwrUpReq|CtrlCDbgPwrUpReq)<<1
+		case addr == DPSelect && !read:
+			tg.sel = value
+		}
+		return 0, AckOK
+	}
+	switch reg := uint8(tg.sel&0xF0) | addr&0x0C; reg {
+	case APCSW:
+		if !read {
+			tg.csw = value
+		}
+		return tg.csw, AckOK
+	case APTAR:
+		if !read {
+			tg.tar = value
+		}
+		return tg.tar, AckOK
+	case APDRW:
+		if tg.faults[tg.tar] {
+			return 0, AckFault
+		}
+		v := tg.mem[tg.tar]
+		if !read {
+			tg.mem[tg.tar] = value
+		}
+		if tg.csw&0x30 == 0x10 {
+			tg.tar = tg.tar&^(tarWrap-1) | (tg.tar+4)&(tarWrap-1)
+		}
+		return v, AckOK
+	case APIDR:
+		return testAPIDR, AckOK
+	}
+	return 0, AckOK
+}
+
+// probe simulates a CMSIS-DAP probe in front of a target.
+type probe struct {
+	packetSize  int
+	packetCount int
+	target      *target
+}
+
+func (p *probe) respond(cmd []byte) []byte {
+	switch cmd[0] {
+	case cmdInfo:
+		switch InfoID(cmd[1]) {
+		case InfoPacketCount:
+			return []byte{cmdInfo, 1, byte(p.packetCount)}
+		case InfoPacketSize:
+			return binary.LittleEndian.AppendUint16([]byte{cmdInfo, 2}, uint16(p.packetSize))
+		case InfoProduct:
+			return append([]byte{cmdInfo, 12}, "Test Probe\x00\x00"...)
+		case InfoCapabilities:
+			return []byte{cmdInfo, 2, 0x13, 0x01}
+		}
+		return []byte{cmdInfo, 0}
+	case cmdConnect:
+		if cmd[1] == byte(PortDefault) {
+			return []byte{cmdConnect, byte(PortSWD)}
+		}
+		return []byte{cmdConnect, cmd[1]}
+	case cmdDisconnect, cmdTransferConfigure, cmdSWJClock, cmdSWJSequence:
+		return []byte{cmd[0], statusOK}
+	case cmdTransfer:
+		resp := []byte{cmdTransfer, 0, AckOK}
+		rest := cmd[3:]
+		for i := 0; i < int(cmd[2]); i++ {
+			req := rest[0]
+			rest = rest[1:]
+			var value uint32
+			read := req&requestRead != 0
+			if !read {
+				value = binary.LittleEndian.Uint32(rest)
+				rest = rest[4:]
+			}
+			v, ack := p.target.access(req&requestAP != 0, read, req&requestAddr, value)
+			resp[2] = ack
+			if ack != AckOK {
+				break
+			}
+			resp[1]++
+			if read {
+				resp = binary.LittleEndian.AppendUint32(resp, v)
+			}
+		}
+		return resp
+	case cmdTransferBlock:
+		count := int(binary.LittleEndian.Uint16(cmd[2:4]))
+		req := cmd[4]
+		read := req&requestRead != 0
+		resp := []byte{cmdTransferBlock, 0, 0, AckOK}
+		for i := 0; i < count; i++ {
+			var value uint32
+			if !read {
+				value = binary.LittleEndian.Uint32(cmd[5+4*i:])
+			}
+			v, ack := p.target.access(req&requestAP != 0, read, req&requestAddr, value)
+			resp[3] = ack
+			if ack != AckOK {
+				break
+			}
+			binary.LittleEndian.PutUint16(resp[1:3], uint16(i+1))
+			if read {
+				resp = binary.LittleEndian.AppendUint32(resp, v)
+			}
+		}
+		return resp
+	}
+	return []byte{cmdInvalid}
+}
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu      sync.Mutex
+	strings map[uint8]string
+	probe   *probe
+	// queue holds the responses not yet read, and maxQueued the most
+	// there were at once.
+	queue     [][]byte
+	maxQueued int
+	written   [][]byte
+}
+
+func (fh *fakeHandle) StringDescriptorASCII(descIndex uint8) (string, error) {
+	str, ok := fh.strings[descIndex]
+	if !ok {
+		return "", libusb.ErrPipe
+	}
+	return str, nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		cmd := slices.Clone(data[:length])
+		fh.written = append(fh.written, cmd)
+		fh.queue = append(fh.queue, fh.probe.respond(cmd))
+		fh.maxQueued = max(fh.maxQueued, len(fh.queue))
+		return length, nil
+	}
+	if len(fh.queue) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.queue[0]
+	fh.queue = fh.queue[1:]
+	return copy(data[:length], p), nil
+}
+
+func newFakeHandle(packetSize, packetCount int) *fakeHandle {
+	return &fakeHandle{
+		strings: map[uint8]string{4: "Test Probe CMSIS-DAP", 5: "Test Probe UART"},
+		probe: &probe{
+			packetSize:  packetSize,
+			packetCount: packetCount,
+			target:      &target{mem: map[uint32]uint32{}, faults: map[uint32]bool{}},
+		},
+	}
+}
+
+// testConfig returns a configuration whose interface 0 is a CMSIS-DAP v2
+// interface with the bulk endpoints 0x01, 0x82 and the SWO endpoint 0x83,
+// and whose interface 1 is another vendor-specific interface.
+func testConfig() *libusb.ConfigDescriptor {
+	endpoints := libusb.EndpointDescriptors{
+		{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 512},
+		{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 512},
+		{EndpointAddress: 0x83, Attributes: 0x02, MaxPacketSize: 512},
+	}
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:      libusb.InterfaceClassVendorSpec,
+				InterfaceIndex:      4,
+				EndpointDescriptors: endpoints,
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:     1,
+				InterfaceClass:      libusb.InterfaceClassVendorSpec,
+				InterfaceIndex:      5,
+				EndpointDescriptors: endpoints[:2],
+			}}},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(fh, testConfig())
+	if err != nil || len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	fh := newFakeHandle(512, 4)
+	ifaces, err := FindInterfaces(fh, testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.BulkOut.EndpointAddress != 0x01 || iface.BulkIn.EndpointAddress != 0x82 ||
+		iface.SWO.EndpointAddress != 0x83 {
+		t.Errorf("interface = %+v", iface)
+	}
+
+	delete(fh.strings, 5)
+	if _, err := FindInterfaces(fh, testConfig()); err == nil {
+		t.Error("unreadable interface string: expected error, got nil")
+	}
+	if _, err := FindInterfaces(fh, nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := newFakeHandle(512, 4)
+	fh.Active = true
+	dev := openTestDevice(t, fh)
+	if dev.PacketSize() != 512 || dev.PacketCount() != 4 || len(dev.buf) != 512 {
+		t.Errorf("packet size %d, count %d, buffer %d",
+			dev.PacketSize(), dev.PacketCount(), len(dev.buf))
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.Info(InfoProduct); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Info after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestOpenBadPacketSize(t *testing.T) {
+	fh := newFakeHandle(16, 1)
+	ifaces, err := FindInterfaces(fh, testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if _, err := Open(fh, ifaces[0]); err == nil {
+		t.Fatal("Open with a 16 byte packet size: expected error, got nil")
+	}
+	want := []string{"claim 0", "release 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	if _, err := dev.command(0x7F); err == nil {
+		t.Error("unsupported command: expected error, got nil")
+	}
+	if _, err := dev.command(make([]byte, 65)...); err == nil {
+		t.Error("command larger than the packet size: expected error, got nil")
+	}
+	fh.queue = append(fh.queue, []byte{cmdConnect, 1})
+	if _, err := dev.Info(InfoProduct); err == nil {
+		t.Error("response to another command: expected error, got nil")
+	}
+}
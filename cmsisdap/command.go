@@ -0,0 +1,268 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// DAP command IDs.
+const (
+	cmdInfo              = 0x00
+	cmdConnect           = 0x02
+	cmdDisconnect        = 0x03
+	cmdTransferConfigure = 0x04
+	cmdTransfer          = 0x05
+	cmdTransferBlock     = 0x06
+	cmdSWJClock          = 0x11
+	cmdSWJSequence       = 0x12
+	cmdInvalid           = 0xFF
+)
+
+// statusOK is the status byte of a command the probe completed.
+const statusOK = 0x00
+
+// defaultPacketSize is the packet size assumed until the probe reports
+// its own, the packet size of full-speed probes.
+const defaultPacketSize = 64
+
+// InfoID identifies the information DAP_Info returns.
+type InfoID uint8
+
+// DAP_Info IDs.
+const (
+	InfoVendor           InfoID = 0x01
+	InfoProduct          InfoID = 0x02
+	InfoSerialNumber     InfoID = 0x03
+	InfoProtocolVersion  InfoID = 0x04
+	InfoTargetVendor     InfoID = 0x05
+	InfoTargetName       InfoID = 0x06
+	InfoBoardVendor      InfoID = 0x07
+	InfoBoardName        InfoID = 0x08
+	InfoFirmwareVersion  InfoID = 0x09
+	InfoCapabilities     InfoID = 0xF0
+	InfoTestDomainTimer  InfoID = 0xF1
+	InfoUARTReceiveSize  InfoID = 0xFB
+	InfoUARTTransmitSize InfoID = 0xFC
+	InfoSWOBufferSize    InfoID = 0xFD
+	InfoPacketCount      InfoID = 0xFE
+	InfoPacketSize       InfoID = 0xFF
+)
+
+// Capabilities is the set of features a probe reports in DAP_Info.
+type Capabilities uint16
+
+// Probe capabilities.
+const (
+	CapSWD Capabilities = 1 << iota
+	CapJTAG
+	CapSWOUART
+	CapSWOManchester
+	CapAtomicCommands
+	CapTestDomainTimer
+	CapSWOStreaming
+	CapUART
+	CapUSBCOMPort
+)
+
+var capabilityNames = []string{
+	"SWD",
+	"JTAG",
+	"SWO UART",
+	"SWO Manchester",
+	"atomic commands",
+	"test domain timer",
+	"SWO streaming",
+	"UART",
+	"USB COM port",
+}
+
+// String implements the Stringer interface for Capabilities.
+func (c Capabilities) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// Port is the debug port a probe connects with.
+type Port uint8
+
+// Debug ports. PortDefault lets the probe pick its configured port.
+const (
+	PortDefault Port = 0x00
+	PortSWD     Port = 0x01
+	PortJTAG    Port = 0x02
+)
+
+var portNames = map[Port]string{
+	PortDefault: "default",
+	PortSWD:     "SWD",
+	PortJTAG:    "JTAG",
+}
+
+// String implements the Stringer interface for Port.
+func (p Port) String() string {
+	if name, ok := portNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("port %#02x", uint8(p))
+}
+
+// command runs a single command and returns its response.
+func (dev *Device) command(cmd ...byte) ([]byte, error) {
+	resps, err := dev.exchange(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return resps[0], nil
+}
+
+// statusCommand runs a command whose response is a status byte.
+func (dev *Device) statusCommand(cmd ...byte) error {
+	resp, err := dev.command(cmd...)
+	if err != nil {
+		return err
+	}
+	if len(resp) < 2 || resp[1] != statusOK {
+		return fmt.Errorf("cmsisdap: probe failed command %#02x: % x", cmd[0], resp)
+	}
+	return nil
+}
+
+// Info returns the information DAP_Info reports for an ID, empty if the
+// probe has none.
+func (dev *Device) Info(id InfoID) ([]byte, error) {
+	resp, err := dev.command(cmdInfo, byte(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 || len(resp) < 2+int(resp[1]) {
+		return nil, fmt.Errorf("cmsisdap: malformed info response % x", resp)
+	}
+	return resp[2 : 2+int(resp[1])], nil
+}
+
+// InfoString returns one of the string IDs of DAP_Info, such as
+// InfoProduct or InfoFirmwareVersion.
+func (dev *Device) InfoString(id InfoID) (string, error) {
+	info, err := dev.Info(id)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(info), "\x00"), nil
+}
+
+// Capabilities returns the probe's capabilities.
+func (dev *Device) Capabilities() (Capabilities, error) {
+	info, err := dev.Info(InfoCapabilities)
+	if err != nil {
+		return 0, err
+	}
+	var caps Capabilities
+	for i, b := range info[:min(len(info), 2)] {
+		caps |= Capabilities(b) << (8 * i)
+	}
+	return caps, nil
+}
+
+// readPacketInfo reads the probe's packet count and packet size.
+func (dev *Device) readPacketInfo() error {
+	count, err := dev.Info(InfoPacketCount)
+	if err != nil {
+		return err
+	}
+	size, err := dev.Info(InfoPacketSize)
+	if err != nil {
+		return err
+	}
+	if len(count) != 1 || count[0] == 0 || len(size) != 2 {
+		return fmt.Errorf("cmsisdap: bad packet count % x or packet size % x", count, size)
+	}
+	packetSize := int(binary.LittleEndian.Uint16(size))
+	if packetSize < defaultPacketSize {
+		return fmt.Errorf("cmsisdap: packet size %d is too small", packetSize)
+	}
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	dev.packetCount = int(count[0])
+	dev.packetSize = packetSize
+	dev.buf = make([]byte, dev.bufferSize())
+	return nil
+}
+
+// Connect connects the probe to the target's debug port, returning the
+// port it connected with.
+func (dev *Device) Connect(port Port) (Port, error) {
+	resp, err := dev.command(cmdConnect, byte(port))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) < 2 || resp[1] == 0 {
+		return 0, fmt.Errorf("cmsisdap: probe failed to connect with %s port", port)
+	}
+	dev.invalidateSelect()
+	return Port(resp[1]), nil
+}
+
+// Disconnect disconnects the probe from the target.
+func (dev *Device) Disconnect() error {
+	dev.invalidateSelect()
+	return dev.statusCommand(cmdDisconnect)
+}
+
+// SWJClock sets the clock frequency of SWD and JTAG in Hz.
+func (dev *Device) SWJClock(hz uint32) error {
+	return dev.statusCommand(binary.LittleEndian.AppendUint32([]byte{cmdSWJClock}, hz)...)
+}
+
+// SWJSequence clocks out a sequence of 1 to 256 bits on SWDIO/TMS, least
+// significant bit of data first.
+func (dev *Device) SWJSequence(bits int, data []byte) error {
+	if bits < 1 || bits > 256 {
+		return fmt.Errorf("cmsisdap: SWJ sequence of %d bits, want 1 to 256", bits)
+	}
+	if n := (bits + 7) / 8; len(data) < n {
+		return fmt.Errorf("cmsisdap: %d bits need %d bytes of data, got %d", bits, n, len(data))
+	}
+	// A count of 0 means 256 bits.
+	cmd := append([]byte{cmdSWJSequence, byte(bits)}, data[:(bits+7)/8]...)
+	return dev.statusCommand(cmd...)
+}
+
+// swdSwitch is the line reset, JTAG-to-SWD sequence, line reset and idle
+// cycles that switch an SWJ-DP from JTAG to SWD.
+var swdSwitch = []byte{
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	0x9E, 0xE7,
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	0x00,
+}
+
+// SwitchToSWD sends the sequence that switches an SWJ debug port from
+// JTAG to SWD and leaves the line reset. The DP's IDR must be read next.
+func (dev *Device) SwitchToSWD() error {
+	dev.invalidateSelect()
+	return dev.SWJSequence(8*len(swdSwitch), swdSwitch)
+}
+
+// TransferConfigure sets the idle cycles after each transfer, the number
+// of retries after a WAIT response, and the number of retries of a
+// value match.
+func (dev *Device) TransferConfigure(idleCycles uint8, waitRetry, matchRetry uint16) error {
+	cmd := []byte{cmdTransferConfigure, idleCycles}
+	cmd = binary.LittleEndian.AppendUint16(cmd, waitRetry)
+	cmd = binary.LittleEndian.AppendUint16(cmd, matchRetry)
+	return dev.statusCommand(cmd...)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	dev := openTestDevice(t, newFakeHandle(64, 1))
+	defer dev.Close()
+
+	if name, err := dev.InfoString(InfoProduct); err != nil || name != "Test Probe" {
+		t.Errorf("InfoString(InfoProduct) = %q, %v", name, err)
+	}
+	if info, err := dev.Info(InfoTargetName); err != nil || len(info) != 0 {
+		t.Errorf("Info(InfoTargetName) = % x, %v", info, err)
+	}
+	caps, err := dev.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities: unexpected error %v", err)
+	}
+	want := CapSWD | CapJTAG | CapAtomicCommands | CapUSBCOMPort
+	if caps != want {
+		t.Errorf("Capabilities = %v, want %v", caps, want)
+	}
+	if got := caps.String(); got != "SWD|JTAG|atomic commands|USB COM port" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	fh.written = nil
+
+	if port, err := dev.Connect(PortDefault); err != nil || port != PortSWD {
+		t.Errorf("Connect = %v, %v", port, err)
+	}
+	if err := dev.SWJClock(4000000); err != nil {
+		t.Errorf("SWJClock: unexpected error %v", err)
+	}
+	if err := dev.SwitchToSWD(); err != nil {
+		t.Errorf("SwitchToSWD: unexpected error %v", err)
+	}
+	if err := dev.TransferConfigure(2, 80, 0); err != nil {
+		t.Errorf("TransferConfigure: unexpected error %v", err)
+	}
+	if err := dev.Disconnect(); err != nil {
+		t.Errorf("Disconnect: unexpected error %v", err)
+	}
+	want := [][]byte{
+		{cmdConnect, 0x00},
+		{cmdSWJClock, 0x00, 0x09, 0x3D, 0x00},
+		append([]byte{cmdSWJSequence, 136}, swdSwitch...),
+		{cmdTransferConfigure, 2, 80, 0, 0, 0},
+		{cmdDisconnect},
+	}
+	if len(fh.written) != len(want) {
+		t.Fatalf("wrote %d commands, want %d", len(fh.written), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(fh.written[i], want[i]) {
+			t.Errorf("command %d = % x, want % x", i, fh.written[i], want[i])
+		}
+	}
+}
+
+func TestSWJSequence(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	testCases := []struct {
+		name string
+		bits int
+		data []byte
+		want []byte
+	}{
+		{"partial byte", 12, []byte{0xFF, 0x0F, 0xAA}, []byte{cmdSWJSequence, 12, 0xFF, 0x0F}},
+		{"256 bits", 256, make([]byte, 32), append([]byte{cmdSWJSequence, 0}, make([]byte, 32)...)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh.written = nil
+			if err := dev.SWJSequence(tc.bits, tc.data); err != nil {
+				t.Fatalf("SWJSequence: unexpected error %v", err)
+			}
+			if !bytes.Equal(fh.written[0], tc.want) {
+				t.Errorf("command = % x, want % x", fh.written[0], tc.want)
+			}
+		})
+	}
+	if err := dev.SWJSequence(0, nil); err == nil {
+		t.Error("zero bits: expected error, got nil")
+	}
+	if err := dev.SWJSequence(9, []byte{0xFF}); err == nil {
+		t.Error("too little data: expected error, got nil")
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Transfer request bits.
+const (
+	requestAP   = 0x01
+	requestRead = 0x02
+	requestAddr = 0x0C
+)
+
+// Transfer acknowledgements, the low three bits of a transfer response.
+const (
+	AckOK    = 0x01
+	AckWait  = 0x02
+	AckFault = 0x04
+	AckNoAck = 0x07
+)
+
+// Transfer response bits beyond the acknowledgement.
+const (
+	ackMask          = 0x07
+	ackProtocolError = 0x08
+	ackMismatch      = 0x10
+)
+
+var ackNames = map[uint8]string{
+	AckWait:  "WAIT",
+	AckFault: "FAULT",
+	AckNoAck: "no ACK",
+}
+
+// TransferRequest is one DP or AP register access of a transfer.
+type TransferRequest struct {
+	AP   bool
+	Read bool
+	// Addr is the register address. Only its bits 2 and 3 are sent; the
+	// AP register bank is chosen by the DP SELECT register.
+	Addr uint8
+	// Value is the value written, unused for reads.
+	Value uint32
+}
+
+func (r TransferRequest) request() byte {
+	req := r.Addr & requestAddr
+	if r.AP {
+		req |= requestAP
+	}
+	if r.Read {
+		req |= requestRead
+	}
+	return req
+}
+
+// TransferError is a register access the target didn't acknowledge.
+type TransferError struct {
+	// Index is the index of the failed access among those requested.
+	Index int
+	// Response is the transfer response of the failed access.
+	Response uint8
+}
+
+// Error implements the error interface for TransferError.
+func (e *TransferError) Error() string {
+	var reason string
+	switch {
+	case e.Response&ackProtocolError != 0:
+		reason = "SWD protocol error"
+	case e.Response&ackMismatch != 0:
+		reason = "value mismatch"
+	default:
+		var ok bool
+		if reason, ok = ackNames[e.Response&ackMask]; !ok {
+			reason = fmt.Sprintf("response %#02x", e.Response)
+		}
+	}
+	return fmt.Sprintf("cmsisdap: transfer %d failed: %s", e.Index, reason)
+}
+
+// failed reports whether a transfer response isn't a plain OK.
+func failed(response uint8) bool {
+	return response&ackMask != AckOK || response&(ackProtocolError|ackMismatch) != 0
+}
+
+// Transfer runs DP and AP register accesses with DAP_Transfer and
+// returns the values read, in order. Requests that don't fit one packet
+// are split over several commands, which are pipelined. An access the
+// target doesn't acknowledge returns a *TransferError; the commands
+// already queued behind it still run, but a FAULT's sticky error makes
+// the target ignore their AP accesses.
+func (dev *Device) Transfer(reqs ...TransferRequest) ([]uint32, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	packetSize := dev.PacketSize()
+	var cmds [][]byte
+	var counts []int
+	var cmd []byte
+	var respSize int
+	for _, r := range reqs {
+		size, rsize := 1, 0
+		if r.Read {
+			rsize = 4
+		} else {
+			size = 5
+		}
+		if cmd == nil || len(cmd)+size > packetSize || respSize+rsize > packetSize ||
+			cmd[2] == 0xFF {
+			cmd = []byte{cmdTransfer, dev.Index, 0}
+			respSize = 3
+			cmds = append(cmds, cmd)
+			counts = append(counts, 0)
+		}
+		cmd = append(cmd, r.request())
+		if !r.Read {
+			cmd = binary.LittleEndian.AppendUint32(cmd, r.Value)
+		}
+		cmd[2]++
+		respSize += rsize
+		cmds[len(cmds)-1] = cmd
+		counts[len(counts)-1]++
+	}
+	resps, err := dev.exchange(cmds...)
+	if err != nil {
+		return nil, err
+	}
+	var values []uint32
+	index := 0
+	for i, resp := range resps {
+		if len(resp) < 3 {
+			return nil, fmt.Errorf("cmsisdap: malformed transfer response % x", resp)
+		}
+		done, response := int(resp[1]), resp[2]
+		if done < counts[i] || failed(response) {
+			return nil, &TransferError{Index: index + done, Response: response}
+		}
+		data := resp[3:]
+		for _, r := range reqs[index : index+counts[i]] {
+			if !r.Read {
+				continue
+			}
+			if len(data) < 4 {
+				return nil, fmt.Errorf("cmsisdap: transfer response % x is short", resp)
+			}
+			values = append(values, binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		}
+		index += counts[i]
+	}
+	return values, nil
+}
+
+// blockRequest returns the request byte of a block transfer.
+func blockRequest(ap, read bool, addr uint8) byte {
+	return TransferRequest{AP: ap, Read: read, Addr: addr}.request()
+}
+
+// ReadBlock reads a DP or AP register n times with DAP_TransferBlock, as
+// when reading memory through a MEM-AP's DRW with auto-increment. Reads
+// that don't fit one packet are split over several pipelined commands.
+func (dev *Device) ReadBlock(ap bool, addr uint8, n int) ([]uint32, error) {
+	perCommand := (dev.PacketSize() - 4) / 4
+	var cmds [][]byte
+	for left := n; left > 0; left -= perCommand {
+		cmd := []byte{cmdTransferBlock, dev.Index}
+		cmd = binary.LittleEndian.AppendUint16(cmd, uint16(min(left, perCommand)))
+		cmds = append(cmds, append(cmd, blockRequest(ap, true, addr)))
+	}
+	resps, err := dev.exchange(cmds...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint32, 0, n)
+	for i, resp := range resps {
+		want := int(binary.LittleEndian.Uint16(cmds[i][2:4]))
+		if err := checkBlock(resp, len(values), want); err != nil {
+			return nil, err
+		}
+		if len(resp) < 4+4*want {
+			return nil, fmt.Errorf("cmsisdap: block transfer response is short")
+		}
+		for j := 0; j < want; j++ {
+			values = append(values, binary.LittleEndian.Uint32(resp[4+4*j:]))
+		}
+	}
+	return values, nil
+}
+
+// WriteBlock writes values to a DP or AP register with
+// DAP_TransferBlock. Writes that don't fit one packet are split over
+// several pipelined commands.
+func (dev *Device) WriteBlock(ap bool, addr uint8, values []uint32) error {
+	perCommand := (dev.PacketSize() - 5) / 4
+	var cmds [][]byte
+	for start := 0; start < len(values); start += perCommand {
+		chunk := values[start:min(start+perCommand, len(values))]
+		cmd := []byte{cmdTransferBlock, dev.Index}
+		cmd = binary.LittleEndian.AppendUint16(cmd, uint16(len(chunk)))
+		cmd = append(cmd, blockRequest(ap, false, addr))
+		for _, v := range chunk {
+			cmd = binary.LittleEndian.AppendUint32(cmd, v)
+		}
+		cmds = append(cmds, cmd)
+	}
+	resps, err := dev.exchange(cmds...)
+	if err != nil {
+		return err
+	}
+	for i, resp := range resps {
+		want := int(binary.LittleEndian.Uint16(cmds[i][2:4]))
+		if err := checkBlock(resp, i*perCommand, want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlock checks that a block transfer response reports all its
+// accesses done. index is the index of its first access.
+func checkBlock(resp []byte, index, want int) error {
+	if len(resp) < 4 {
+		return fmt.Errorf("cmsisdap: malformed block transfer response % x", resp)
+	}
+	done, response := int(binary.LittleEndian.Uint16(resp[1:3])), resp[3]
+	if done < want || failed(response) {
+		return &TransferError{Index: index + done, Response: response}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package cmsisdap
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestTransfer(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	values, err := dev.Transfer(
+		TransferRequest{Read: true, Addr: DPIDR},
+		TransferRequest{Addr: DPSelect, Value: 0xF0},
+		TransferRequest{AP: true, Read: true, Addr: APIDR},
+	)
+	if err != nil {
+		t.Fatalf("Transfer: unexpected error %v", err)
+	}
+	if !slices.Equal(values, []uint32{testDPIDR, testAPIDR}) {
+		t.Errorf("Transfer = %#x", values)
+	}
+	if values, err := dev.Transfer(); err != nil || values != nil {
+		t.Errorf("Transfer() = %v, %v", values, err)
+	}
+}
+
+func TestTransferPipelining(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packetCount int
+		maxQueued   int
+	}{
+		{"one packet", 1, 1},
+		{"four packets", 4, 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := newFakeHandle(64, tc.packetCount)
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			fh.written, fh.maxQueued = nil, 0
+
+			// 15 reads fill a 64 byte response, so 40 take three commands.
+			reqs := make([]TransferRequest, 40)
+			for i := range reqs {
+				reqs[i] = TransferRequest{Read: true, Addr: DPIDR}
+			}
+			values, err := dev.Transfer(reqs...)
+			if err != nil {
+				t.Fatalf("Transfer: unexpected error %v", err)
+			}
+			if len(values) != 40 || values[39] != testDPIDR {
+				t.Errorf("Transfer returned %d values", len(values))
+			}
+			if len(fh.written) != 3 || fh.maxQueued != tc.maxQueued {
+				t.Errorf("sent %d commands with up to %d queued, want 3 with %d",
+					len(fh.written), fh.maxQueued, tc.maxQueued)
+			}
+		})
+	}
+}
+
+func TestTransferFault(t *testing.T) {
+	fh := newFakeHandle(64, 1)
+	fh.probe.target.faults[0x20000000] = true
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	_, err := dev.Transfer(
+		TransferRequest{Addr: DPSelect, Value: 0},
+		TransferRequest{AP: true, Addr: APCSW, Value: cswWord},
+		TransferRequest{AP: true, Addr: APTAR, Value: 0x20000000},
+		TransferRequest{AP: true, Read: true, Addr: APDRW},
+	)
+	var transferErr *TransferError
+	if !errors.As(err, &transferErr) || transferErr.Index != 3 ||
+		transferErr.Response != AckFault {
+		t.Fatalf("Transfer: got %v, want a FAULT *TransferError", err)
+	}
+	if got, want := err.Error(), "cmsisdap: transfer 3 failed: FAULT"; got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+}
+
+func TestTransferErrorString(t *testing.T) {
+	testCases := []struct {
+		response uint8
+		want     string
+	}{
+		{AckWait, "cmsisdap: transfer 0 failed: WAIT"},
+		{AckNoAck, "cmsisdap: transfer 0 failed: no ACK"},
+		{AckOK | ackProtocolError, "cmsisdap: transfer 0 failed: SWD protocol error"},
+		{AckOK | ackMismatch, "cmsisdap: transfer 0 failed: value mismatch"},
+		{0x05, "cmsisdap: transfer 0 failed: response 0x05"},
+	}
+	for _, tc := range testCases {
+		if got := (&TransferError{Response: tc.response}).Error(); got != tc.want {
+			t.Errorf("Error() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestBlockTransfers(t *testing.T) {
+	fh := newFakeHandle(64, 4)
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	if _, err := dev.Transfer(
+		TransferRequest{Addr: DPSelect, Value: 0},
+		TransferRequest{AP: true, Addr: APCSW, Value: cswWord},
+		TransferRequest{AP: true, Addr: APTAR, Value: 0x20000000},
+	); err != nil {
+		t.Fatalf("Transfer: unexpected error %v", err)
+	}
+	values := make([]uint32, 40)
+	for i := range values {
+		values[i] = uint32(i) * 0x01010101
+	}
+	fh.written, fh.maxQueued = nil, 0
+	if err := dev.WriteBlock(true, APDRW, values); err != nil {
+		t.Fatalf("WriteBlock: unexpected error %v", err)
+	}
+	// 14 words fit a 64 byte command, so 40 take three.
+	if len(fh.written) != 3 || fh.maxQueued != 3 {
+		t.Errorf("sent %d commands with up to %d queued, want 3 with 3",
+			len(fh.written), fh.maxQueued)
+	}
+	if got := fh.probe.target.mem[0x20000000+4*39]; got != values[39] {
+		t.Errorf("last word written = %#x, want %#x", got, values[39])
+	}
+
+	if err := dev.WriteAP(0, APTAR, 0x20000000); err != nil {
+		t.Fatalf("WriteAP: unexpected error %v", err)
+	}
+	got, err := dev.ReadBlock(true, APDRW, len(values))
+	if err != nil || !slices.Equal(got, values) {
+		t.Errorf("ReadBlock = %#x, %v", got, err)
+	}
+
+	fh.probe.target.faults[0x20000000+4*20] = true
+	if err := dev.WriteAP(0, APTAR, 0x20000000); err != nil {
+		t.Fatalf("WriteAP: unexpected error %v", err)
+	}
+	var transferErr *TransferError
+	_, err = dev.ReadBlock(true, APDRW, len(values))
+	if !errors.As(err, &transferErr) || transferErr.Index != 20 {
+		t.Errorf("ReadBlock past a fault: got %v, want a *TransferError at 20", err)
+	}
+	if len(fh.queue) != 0 {
+		t.Errorf("%d responses left after the fault", len(fh.queue))
+	}
+}
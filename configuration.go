@@ -21,5 +21,11 @@ type ConfigDescriptor struct {
 	ConfigurationIndex   uint8
 	Attributes           uint8
 	MaxPowerMilliAmperes uint
+	// Extra holds any class-specific or vendor-specific descriptors that
+	// follow the configuration descriptor.
+	Extra []byte
+	// InterfaceAssociations holds the interface association descriptors
+	// (IADs) that group interfaces into functions.
+	InterfaceAssociations InterfaceAssociationDescriptors
 	SupportedInterfaces
 }
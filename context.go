@@ -107,7 +107,7 @@ func (ctx *Context) DeviceList() ([]*Device, error) {
 	for _, thisLibusbDevice := range libusbDevices {
 		// Increment reference count to keep device valid after list is freed
 		C.libusb_ref_device(thisLibusbDevice)
-		thisDevice := newDevice(thisLibusbDevice)
+		thisDevice := newDevice(ctx.libusbContext, thisLibusbDevice)
 		devices = append(devices, thisDevice)
 	}
 	return devices, nil
@@ -127,9 +127,9 @@ func (ctx *Context) OpenDeviceWithVendorProduct(
 			productID,
 		)
 	}
-	deviceHandle := newDeviceHandle(libusbDeviceHandle)
+	deviceHandle := newDeviceHandle(ctx.libusbContext, libusbDeviceHandle)
 	libusbDevice := C.libusb_get_device(libusbDeviceHandle)
-	device := newDevice(libusbDevice)
+	device := newDevice(ctx.libusbContext, libusbDevice)
 	// Need to increment reference count since we're creating a new Device object
 	C.libusb_ref_device(libusbDevice)
 	return device, deviceHandle, nil
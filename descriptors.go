@@ -92,22 +92,31 @@ const (
 	descHub               descriptorType = C.LIBUSB_DT_HUB
 	descSuperspeedHub     descriptorType = C.LIBUSB_DT_SUPERSPEED_HUB
 	descEndpointCompanion descriptorType = C.LIBUSB_DT_SS_ENDPOINT_COMPANION
+	// libusb doesn't enumerate the device qualifier and other speed
+	// configuration descriptor types. See USB 2.0 Table 9-5.
+	descDeviceQualifier  descriptorType = 0x06
+	descOtherSpeedConfig descriptorType = 0x07
+	// LIBUSB_DT_INTERFACE_ASSOCIATION was only added in libusb 1.0.27.
+	descInterfaceAssociation descriptorType = 0x0B
 )
 
 var descriptorTypes = map[descriptorType]string{
-	descDevice:            "Device descriptor.",
-	descConfig:            "Configuration descriptor.",
-	descString:            "String descriptor.",
-	descInterface:         "Interface descriptor.",
-	descEndpoint:          "Endpoint descriptor.",
-	descBos:               "BOS descriptor.",
-	descDeviceCapability:  "Device Capability descriptor.",
-	descHid:               "HID descriptor.",
-	descReport:            "HID report descriptor.",
-	descPhysical:          "Physical descriptor.",
-	descHub:               "Hub descriptor.",
-	descSuperspeedHub:     "SuperSpeed Hub descriptor.",
-	descEndpointCompanion: "SuperSpeed Endpoint Companion descriptor.",
+	descDevice:               "Device descriptor.",
+	descConfig:               "Configuration descriptor.",
+	descString:               "String descriptor.",
+	descInterface:            "Interface descriptor.",
+	descEndpoint:             "Endpoint descriptor.",
+	descBos:                  "BOS descriptor.",
+	descDeviceCapability:     "Device Capability descriptor.",
+	descHid:                  "HID descriptor.",
+	descReport:               "HID report descriptor.",
+	descPhysical:             "Physical descriptor.",
+	descHub:                  "Hub descriptor.",
+	descSuperspeedHub:        "SuperSpeed Hub descriptor.",
+	descEndpointCompanion:    "SuperSpeed Endpoint Companion descriptor.",
+	descDeviceQualifier:      "Device Qualifier descriptor.",
+	descOtherSpeedConfig:     "Other Speed Configuration descriptor.",
+	descInterfaceAssociation: "Interface Association descriptor.",
 }
 
 func (descriptorType descriptorType) String() string {
@@ -127,6 +136,14 @@ const (
 	directionBit                    = 7
 )
 
+// EndpointOut and EndpointIn are the directions Direction returns. They
+// are exported so that class packages can tell the endpoints of an
+// interface apart.
+const (
+	EndpointOut = endpointOut
+	EndpointIn  = endpointIn
+)
+
 var endpointDirections = map[EndpointDirection]string{
 	endpointOut: "Out: host-to-device.",
 	endpointIn:  "In: device-to-host.",
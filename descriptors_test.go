@@ -109,6 +109,9 @@ func TestDescriptortypeStringMethod(t *testing.T) {
 		{descHub, "Hub descriptor."},
 		{descSuperspeedHub, "SuperSpeed Hub descriptor."},
 		{descEndpointCompanion, "SuperSpeed Endpoint Companion descriptor."},
+		{descDeviceQualifier, "Device Qualifier descriptor."},
+		{descOtherSpeedConfig, "Other Speed Configuration descriptor."},
+		{descInterfaceAssociation, "Interface Association descriptor."},
 	}
 	t.Log("Given the need to test the descriptorType.String() method")
 	{
@@ -16,7 +16,11 @@ import (
 
 // Device represents a USB device including the opaque libusb_device struct.
 type Device struct {
-	libusbDevice        *C.libusb_device
+	libusbDevice *C.libusb_device
+	// libusbContext is the context the device was found in, which handles
+	// the events of asynchronous transfers on its handles. Nil means the
+	// libusb default context.
+	libusbContext       *C.libusb_context
 	ActiveConfiguration *ConfigDescriptor
 }
 
@@ -30,9 +34,10 @@ func deviceFinalizer(dev *Device) {
 }
 
 // newDevice creates a new Device with proper finalizer setup.
-func newDevice(libusbDevice *C.libusb_device) *Device {
+func newDevice(libusbContext *C.libusb_context, libusbDevice *C.libusb_device) *Device {
 	dev := &Device{
-		libusbDevice: libusbDevice,
+		libusbDevice:  libusbDevice,
+		libusbContext: libusbContext,
 	}
 	runtime.SetFinalizer(dev, deviceFinalizer)
 	return dev
@@ -107,7 +112,7 @@ func (dev *Device) PortNumber() (int, error) {
 // instead. It simply returns the wMaxPacketSize value without considering its
 // contents. If you're dealing with isochronous transfers, you probably want
 // libusb_get_max_iso_packet_size() instead." (Source: libusb docs)
-func (dev *Device) MaxPacketSize(ep endpointAddress) (int, error) {
+func (dev *Device) MaxPacketSize(ep EndpointAddress) (int, error) {
 	if dev == nil || dev.libusbDevice == nil {
 		return 0, ErrorCode(errorInvalidParam)
 	}
@@ -159,7 +164,7 @@ func (dev *Device) Open() (*DeviceHandle, error) {
 	if err != 0 {
 		return nil, ErrorCode(err)
 	}
-	deviceHandle := newDeviceHandle(handle)
+	deviceHandle := newDeviceHandle(dev.libusbContext, handle)
 	return deviceHandle, nil
 }
 
@@ -212,6 +217,7 @@ func parseConfigDescriptor(
 		ConfigurationIndex:   uint8(config.iConfiguration),
 		Attributes:           uint8(config.bmAttributes),
 		MaxPowerMilliAmperes: 2 * uint(config.MaxPower),
+		Extra:                extraBytes(config.extra, config.extra_length),
 	}
 	numInterfaces := cd.NumInterfaces
 	if numInterfaces == 0 {
@@ -243,6 +249,7 @@ func parseConfigDescriptor(
 				InterfaceSubClass: uint8(lid.bInterfaceSubClass),
 				InterfaceProtocol: uint8(lid.bInterfaceProtocol),
 				InterfaceIndex:    int(lid.iInterface),
+				Extra:             extraBytes(lid.extra, lid.extra_length),
 			}
 			numEP := int(lid.bNumEndpoints)
 			if numEP > 0 {
@@ -252,10 +259,13 @@ func parseConfigDescriptor(
 					epDescs = append(epDescs, &EndpointDescriptor{
 						Length:          int(lep.bLength),
 						DescriptorType:  descriptorType(lep.bDescriptorType),
-						EndpointAddress: endpointAddress(lep.bEndpointAddress),
+						EndpointAddress: EndpointAddress(lep.bEndpointAddress),
 						Attributes:      endpointAttributes(lep.bmAttributes),
 						MaxPacketSize:   uint16(lep.wMaxPacketSize),
 						Interval:        uint8(lep.bInterval),
+						Refresh:         uint8(lep.bRefresh),
+						SynchAddress:    uint8(lep.bSynchAddress),
+						Extra:           extraBytes(lep.extra, lep.extra_length),
 					})
 				}
 				ifaceDesc.EndpointDescriptors = epDescs
@@ -269,6 +279,15 @@ func parseConfigDescriptor(
 	return cd
 }
 
+// extraBytes copies the extra descriptors libusb attaches to a configuration,
+// interface, or endpoint descriptor into a Go slice.
+func extraBytes(extra *C.uchar, length C.int) []byte {
+	if extra == nil || length <= 0 {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(extra), length)
+}
+
 // ActiveConfigDescriptor "gets the USB configuration descriptor for the
 // currently active configuration. This is a non-blocking function which does
 // not involve any requests being sent to the device." (Source: libusb docs)
@@ -282,7 +301,9 @@ func (dev *Device) ActiveConfigDescriptor() (*ConfigDescriptor, error) {
 		return nil, ErrorCode(err)
 	}
 	defer C.libusb_free_config_descriptor(config)
-	return parseConfigDescriptor(config), nil
+	cd := parseConfigDescriptor(config)
+	cd.InterfaceAssociations = dev.interfaceAssociations(cd, true, 0)
+	return cd, nil
 }
 
 // ConfigDescriptor "gets a USB configuration descriptor based on its index.
@@ -300,7 +321,9 @@ func (dev *Device) ConfigDescriptor(configIndex int) (*ConfigDescriptor, error)
 		return nil, ErrorCode(err)
 	}
 	defer C.libusb_free_config_descriptor(cConfig)
-	return parseConfigDescriptor(cConfig), nil
+	cd := parseConfigDescriptor(cConfig)
+	cd.InterfaceAssociations = dev.interfaceAssociations(cd, false, uint8(configIndex))
+	return cd, nil
 }
 
 // ConfigDescriptorByValue gets "a USB configuration descriptor with a
@@ -318,7 +341,11 @@ func (dev *Device) ConfigDescriptorByValue(configValue int) (*ConfigDescriptor,
 		return nil, ErrorCode(err)
 	}
 	defer C.libusb_free_config_descriptor(cConfig)
-	return parseConfigDescriptor(cConfig), nil
+	// libusb can only look up IADs by configuration index, so recover them
+	// from the extra descriptors instead.
+	cd := parseConfigDescriptor(cConfig)
+	cd.InterfaceAssociations = cd.associationsFromExtra()
+	return cd, nil
 }
 
 // FindInterfacesByClass finds all interfaces that match the given USB class code.
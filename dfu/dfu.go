@@ -0,0 +1,513 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package dfu implements the USB Device Firmware Upgrade 1.1 class on top of
+libusb, along with the DfuSe extensions STMicroelectronics uses in the STM32
+system bootloader.
+
+A device in run-time mode exposes a DFU interface with protocol 1; Detach
+asks it to re-enumerate in DFU mode, where the interface has protocol 2.
+FindInterfaces lists the DFU interfaces of a configuration, one per
+alternate setting, and Open claims one and reads its functional descriptor.
+
+Device has a method for each class request, and Download and Upload drive
+the state machine through a whole firmware image, polling GETSTATUS as the
+device asks. For DfuSe devices, SetAddress, ErasePage, MassErase, and Leave
+send the extension commands, and WriteMemory and Flash download to an
+address, using the memory layout the device describes in its interface
+string.
+
+ReadFile reads a firmware file and its DFU suffix, checking the CRC, and
+ParseDfuSeImage decodes the DfuSe container format inside it.
+*/
+package dfu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds. It
+// bounds each class request; the bwPollTimeout waits a device asks for
+// between requests come on top of it.
+const DefaultTimeout = 1000
+
+// Interface subclass and protocols from the DFU 1.1 specification.
+const (
+	SubclassDFU     = 0x01
+	ProtocolRuntime = 0x01
+	ProtocolDFUMode = 0x02
+)
+
+// DFU class requests.
+const (
+	requestDetach    = 0x00
+	requestDnload    = 0x01
+	requestUpload    = 0x02
+	requestGetStatus = 0x03
+	requestClrStatus = 0x04
+	requestGetState  = 0x05
+	requestAbort     = 0x06
+)
+
+const (
+	functionalDescriptorType = 0x21
+	statusLength             = 6
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: the DFU class
+// requests, and ResetDevice for Detach, so tests and other transports can
+// stand in for a real device.
+type Handle interface {
+	usbif.Claimer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ResetDevice() error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+}
+
+// State is the state of the DFU state machine reported by GETSTATUS and
+// GETSTATE.
+type State byte
+
+// DFU states.
+const (
+	StateAppIdle           State = 0
+	StateAppDetach         State = 1
+	StateIdle              State = 2
+	StateDnloadSync        State = 3
+	StateDnBusy            State = 4
+	StateDnloadIdle        State = 5
+	StateManifestSync      State = 6
+	StateManifest          State = 7
+	StateManifestWaitReset State = 8
+	StateUploadIdle        State = 9
+	StateError             State = 10
+)
+
+var states = map[State]string{
+	StateAppIdle:           "appIDLE",
+	StateAppDetach:         "appDETACH",
+	StateIdle:              "dfuIDLE",
+	StateDnloadSync:        "dfuDNLOAD-SYNC",
+	StateDnBusy:            "dfuDNBUSY",
+	StateDnloadIdle:        "dfuDNLOAD-IDLE",
+	StateManifestSync:      "dfuMANIFEST-SYNC",
+	StateManifest:          "dfuMANIFEST",
+	StateManifestWaitReset: "dfuMANIFEST-WAIT-RESET",
+	StateUploadIdle:        "dfuUPLOAD-IDLE",
+	StateError:             "dfuERROR",
+}
+
+// String implements the Stringer interface for State.
+func (state State) String() string {
+	if s, ok := states[state]; ok {
+		return s
+	}
+	return fmt.Sprintf("state %d", byte(state))
+}
+
+// StatusCode is the bStatus field of a GETSTATUS response.
+type StatusCode byte
+
+// DFU status codes.
+const (
+	StatusOK             StatusCode = 0x00
+	StatusErrTarget      StatusCode = 0x01
+	StatusErrFile        StatusCode = 0x02
+	StatusErrWrite       StatusCode = 0x03
+	StatusErrErase       StatusCode = 0x04
+	StatusErrCheckErased StatusCode = 0x05
+	StatusErrProg        StatusCode = 0x06
+	StatusErrVerify      StatusCode = 0x07
+	StatusErrAddress     StatusCode = 0x08
+	StatusErrNotDone     StatusCode = 0x09
+	StatusErrFirmware    StatusCode = 0x0A
+	StatusErrVendor      StatusCode = 0x0B
+	StatusErrUSBR        StatusCode = 0x0C
+	StatusErrPOR         StatusCode = 0x0D
+	StatusErrUnknown     StatusCode = 0x0E
+	StatusErrStalledPkt  StatusCode = 0x0F
+)
+
+var statusCodes = map[StatusCode]string{
+	StatusOK:             "no error",
+	StatusErrTarget:      "file is not targeted for this device",
+	StatusErrFile:        "file fails a vendor-specific verification",
+	StatusErrWrite:       "unable to write memory",
+	StatusErrErase:       "memory erase failed",
+	StatusErrCheckErased: "memory erase check failed",
+	StatusErrProg:        "program memory failed",
+	StatusErrVerify:      "programmed memory failed verification",
+	StatusErrAddress:     "address out of range",
+	StatusErrNotDone:     "download ended before the firmware was complete",
+	StatusErrFirmware:    "firmware is corrupt",
+	StatusErrVendor:      "vendor-specific error",
+	StatusErrUSBR:        "unexpected USB reset",
+	StatusErrPOR:         "unexpected power on reset",
+	StatusErrUnknown:     "unknown error",
+	StatusErrStalledPkt:  "device stalled an unexpected request",
+}
+
+// String implements the Stringer interface for StatusCode.
+func (code StatusCode) String() string {
+	if s, ok := statusCodes[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("status %#02x", byte(code))
+}
+
+// Status is a GETSTATUS response.
+type Status struct {
+	Code StatusCode
+	// PollTimeout is how long the host must wait before the next GETSTATUS.
+	PollTimeout time.Duration
+	State       State
+	// StringIndex is the index of a string descriptor describing the status,
+	// or zero.
+	StringIndex uint8
+}
+
+func parseStatus(buf []byte) (Status, error) {
+	if len(buf) != statusLength {
+		return Status{}, fmt.Errorf(
+			"dfu: GETSTATUS returned %d bytes, want %d",
+			len(buf),
+			statusLength,
+		)
+	}
+	poll := uint32(buf[1]) | uint32(buf[2])<<8 | uint32(buf[3])<<16
+	return Status{
+		Code:        StatusCode(buf[0]),
+		PollTimeout: time.Duration(poll) * time.Millisecond,
+		State:       State(buf[4]),
+		StringIndex: buf[5],
+	}, nil
+}
+
+// StatusError is returned when the device reports an error status.
+type StatusError struct {
+	Status Status
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("dfu: %v in state %v", err.Status.Code, err.Status.State)
+}
+
+// Attributes is the bmAttributes field of the DFU functional descriptor.
+type Attributes byte
+
+// CanDownload reports whether the device accepts firmware downloads.
+func (attrs Attributes) CanDownload() bool {
+	return attrs&0x01 != 0
+}
+
+// CanUpload reports whether the device can upload its firmware to the host.
+func (attrs Attributes) CanUpload() bool {
+	return attrs&0x02 != 0
+}
+
+// ManifestationTolerant reports whether the device still answers requests
+// after the manifestation phase instead of needing a reset.
+func (attrs Attributes) ManifestationTolerant() bool {
+	return attrs&0x04 != 0
+}
+
+// WillDetach reports whether the device detaches and re-enumerates on its
+// own after DFU_DETACH instead of waiting for a USB reset.
+func (attrs Attributes) WillDetach() bool {
+	return attrs&0x08 != 0
+}
+
+// FunctionalDescriptor is the DFU functional descriptor that follows a DFU
+// interface descriptor.
+type FunctionalDescriptor struct {
+	Attributes Attributes
+	// DetachTimeout is the longest time the device waits for a USB reset
+	// after DFU_DETACH, in milliseconds.
+	DetachTimeout uint16
+	// TransferSize is the largest block a DNLOAD or UPLOAD request carries.
+	TransferSize uint16
+	// DFUVersion is the binary coded version of the DFU specification, 0x0110
+	// for DFU 1.1 and 0x011A for DfuSe. DFU 1.0 descriptors don't have the
+	// field and report 0x0100.
+	DFUVersion uint16
+}
+
+// IsDfuSe reports whether the descriptor announces the DfuSe extensions.
+func (desc *FunctionalDescriptor) IsDfuSe() bool {
+	return desc.DFUVersion == 0x011A
+}
+
+// ParseFunctionalDescriptor finds the DFU functional descriptor in the extra
+// bytes of an interface descriptor.
+func ParseFunctionalDescriptor(extra []byte) (*FunctionalDescriptor, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("dfu: %w", err)
+	}
+	for _, desc := range descs {
+		if desc[1] != functionalDescriptorType {
+			continue
+		}
+		if len(desc) < 7 {
+			return nil, fmt.Errorf("dfu: functional descriptor is %d bytes", len(desc))
+		}
+		fd := &FunctionalDescriptor{
+			Attributes:    Attributes(desc[2]),
+			DetachTimeout: binary.LittleEndian.Uint16(desc[3:]),
+			TransferSize:  binary.LittleEndian.Uint16(desc[5:]),
+			DFUVersion:    0x0100,
+		}
+		if len(desc) >= 9 {
+			fd.DFUVersion = binary.LittleEndian.Uint16(desc[7:])
+		}
+		return fd, nil
+	}
+	return nil, fmt.Errorf("dfu: no functional descriptor")
+}
+
+// FindInterfaces returns the DFU interfaces of a configuration, including
+// each alternate setting. DfuSe devices use the alternate settings for
+// different memory regions.
+func FindInterfaces(config *libusb.ConfigDescriptor) libusb.InterfaceDescriptors {
+	if config == nil {
+		return nil
+	}
+	var found libusb.InterfaceDescriptors
+	for _, iface := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassApplication,
+	) {
+		if iface.InterfaceSubClass == SubclassDFU {
+			found = append(found, iface)
+		}
+	}
+	return found
+}
+
+// Device is a claimed DFU interface.
+type Device struct {
+	handle           Handle
+	Interface        int
+	AlternateSetting int
+	// Runtime is true when the device is in run-time mode, where only
+	// Detach, GetStatus, and GetState are available.
+	Runtime    bool
+	Descriptor FunctionalDescriptor
+	// Timeout is the timeout in milliseconds for each class request.
+	Timeout int
+
+	// sleep waits out a poll timeout; tests replace it.
+	sleep func(time.Duration)
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims a DFU interface, selects its alternate setting, and reads its
+// functional descriptor.
+func Open(handle Handle, iface *libusb.InterfaceDescriptor) (*Device, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("dfu: nil handle or interface descriptor")
+	}
+	desc, err := ParseFunctionalDescriptor(iface.Extra)
+	if err != nil {
+		return nil, err
+	}
+	if desc.TransferSize == 0 {
+		return nil, fmt.Errorf("dfu: functional descriptor has a transfer size of zero")
+	}
+	dev := &Device{
+		handle:           handle,
+		Interface:        iface.InterfaceNumber,
+		AlternateSetting: iface.AlternateSetting,
+		Runtime:          iface.InterfaceProtocol == ProtocolRuntime,
+		Descriptor:       *desc,
+		Timeout:          DefaultTimeout,
+		sleep:            time.Sleep,
+	}
+
+	claims, err := usbif.Claim(handle, "dfu", dev.Interface)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	if dev.AlternateSetting != 0 {
+		if err := handle.SetInterfaceAltSetting(dev.Interface, dev.AlternateSetting); err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("dfu: selecting alternate setting %d: %w", dev.AlternateSetting, err),
+				dev.Close(),
+			)
+		}
+	}
+	return dev, nil
+}
+
+// Close releases the DFU interface, reattaching the kernel driver Open took
+// it from, if any. It doesn't leave DFU mode; the manifestation that ends
+// Download, or Leave on DfuSe devices, does. Calling Close again returns
+// os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	dev.closed = true
+	return dev.claims.Release()
+}
+
+// DownloadBlock sends one DFU_DNLOAD request. A zero-length block ends the
+// download and starts manifestation.
+func (dev *Device) DownloadBlock(block uint16, data []byte) error {
+	n, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestDnload,
+		block,
+		uint16(dev.Interface),
+		data,
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("dfu: DNLOAD block %d: %w", block, err)
+	}
+	if n != len(data) {
+		return fmt.Errorf("dfu: DNLOAD block %d sent %d of %d bytes", block, n, len(data))
+	}
+	return nil
+}
+
+// UploadBlock sends one DFU_UPLOAD request and returns the number of bytes
+// read into buf. A block shorter than requested ends the upload.
+func (dev *Device) UploadBlock(block uint16, buf []byte) (int, error) {
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestUpload,
+		block,
+		uint16(dev.Interface),
+		buf,
+		len(buf),
+		dev.Timeout,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("dfu: UPLOAD block %d: %w", block, err)
+	}
+	return n, nil
+}
+
+// GetStatus sends DFU_GETSTATUS. Besides reporting the status, the request
+// moves the device on from the sync states.
+func (dev *Device) GetStatus() (Status, error) {
+	buf := make([]byte, statusLength)
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetStatus,
+		0,
+		uint16(dev.Interface),
+		buf,
+		len(buf),
+		dev.Timeout,
+	)
+	if err != nil {
+		return Status{}, fmt.Errorf("dfu: GETSTATUS: %w", err)
+	}
+	return parseStatus(buf[:n])
+}
+
+// ClearStatus sends DFU_CLRSTATUS, which takes the device from dfuERROR back
+// to dfuIDLE.
+func (dev *Device) ClearStatus() error {
+	return dev.request(requestClrStatus, 0)
+}
+
+// GetState sends DFU_GETSTATE, which reports the state without changing it.
+func (dev *Device) GetState() (State, error) {
+	buf := make([]byte, 1)
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetState,
+		0,
+		uint16(dev.Interface),
+		buf,
+		len(buf),
+		dev.Timeout,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("dfu: GETSTATE: %w", err)
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("dfu: GETSTATE returned %d bytes", n)
+	}
+	return State(buf[0]), nil
+}
+
+// Abort sends DFU_ABORT, which returns the device to dfuIDLE from the
+// download and upload idle states.
+func (dev *Device) Abort() error {
+	return dev.request(requestAbort, 0)
+}
+
+// Detach asks a device in run-time mode to switch to DFU mode. Unless the
+// device detaches by itself, Detach then resets it so it re-enumerates
+// within its detach timeout. Either way the device disappears, and the
+// caller has to find it again in DFU mode.
+func (dev *Device) Detach() error {
+	if err := dev.request(requestDetach, dev.Descriptor.DetachTimeout); err != nil {
+		return err
+	}
+	if dev.Descriptor.Attributes.WillDetach() {
+		return nil
+	}
+	if err := dev.handle.ResetDevice(); err != nil {
+		return fmt.Errorf("dfu: resetting device after DETACH: %w", err)
+	}
+	return nil
+}
+
+func (dev *Device) request(request byte, value uint16) error {
+	_, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(dev.Interface),
+		nil,
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("dfu: request %#02x: %w", request, err)
+	}
+	return nil
+}
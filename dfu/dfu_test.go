@@ -0,0 +1,362 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+const dfuseBase = 0x08000000
+
+// fakeDFU runs the DFU state machine over a firmware buffer, or over a flash
+// memory when dfuSe is set.
+type fakeDFU struct {
+	usbiftest.Claimer
+	state        State
+	code         StatusCode
+	transferSize int
+	dfuSe        bool
+	tolerant     bool
+
+	firmware []byte
+	memory   []byte
+	pointer  uint32
+	// pending is the block that the next busy period completes.
+	pending func() StatusCode
+	// failBlock makes the download of this block fail with errWRITE.
+	failBlock int
+	left      bool
+}
+
+func newFakeDFU(transferSize int) *fakeDFU {
+	return &fakeDFU{state: StateIdle, transferSize: transferSize, failBlock: -1}
+}
+
+func (fd *fakeDFU) SetInterfaceAltSetting(iface int, alt int) error {
+	fd.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fd *fakeDFU) ResetDevice() error {
+	fd.Record("reset")
+	return nil
+}
+
+func (fd *fakeDFU) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	switch request {
+	case requestGetStatus:
+		if fd.left {
+			return 0, libusb.ErrNoDevice
+		}
+		poll := 0
+		switch fd.state {
+		case StateDnloadSync:
+			fd.state, poll = StateDnBusy, 5
+		case StateDnBusy:
+			fd.code = fd.pending()
+			fd.state = StateDnloadIdle
+			if fd.code != StatusOK {
+				fd.state = StateError
+			}
+		case StateManifestSync:
+			fd.state, poll = StateManifest, 10
+		case StateManifest:
+			fd.state = StateManifestWaitReset
+			if fd.tolerant {
+				fd.state = StateIdle
+			}
+		}
+		copy(data, []byte{byte(fd.code), byte(poll), 0, 0, byte(fd.state), 0})
+		return statusLength, nil
+	case requestGetState:
+		data[0] = byte(fd.state)
+		return 1, nil
+	case requestUpload:
+		if fd.state != StateIdle && fd.state != StateUploadIdle {
+			return 0, libusb.ErrPipe
+		}
+		var n int
+		if fd.dfuSe {
+			offset := int(fd.pointer-dfuseBase) + (int(value)-2)*fd.transferSize
+			n = copy(data[:maxReceiveLength], fd.memory[offset:])
+		} else {
+			offset := min(int(value)*fd.transferSize, len(fd.firmware))
+			n = copy(data[:maxReceiveLength], fd.firmware[offset:])
+		}
+		fd.state = StateUploadIdle
+		if n < maxReceiveLength {
+			fd.state = StateIdle
+		}
+		return n, nil
+	}
+	return 0, libusb.ErrPipe
+}
+
+func (fd *fakeDFU) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	switch request {
+	case requestDetach:
+		fd.Record("detach %d", value)
+	case requestClrStatus:
+		fd.state, fd.code = StateIdle, StatusOK
+	case requestAbort:
+		fd.state = StateIdle
+	case requestDnload:
+		if fd.state != StateIdle && fd.state != StateDnloadIdle {
+			return 0, libusb.ErrPipe
+		}
+		fd.download(int(value), slices.Clone(data))
+	default:
+		return 0, libusb.ErrPipe
+	}
+	return len(data), nil
+}
+
+func (fd *fakeDFU) download(block int, data []byte) {
+	switch {
+	case len(data) == 0 && fd.dfuSe:
+		fd.left = true
+		return
+	case len(data) == 0:
+		fd.state = StateManifestSync
+		return
+	case fd.dfuSe && block == 0:
+		fd.pending = func() StatusCode { return fd.command(data) }
+	case fd.dfuSe:
+		offset := int(fd.pointer-dfuseBase) + (block-2)*fd.transferSize
+		fd.pending = func() StatusCode {
+			for i, b := range data {
+				if fd.memory[offset+i] != 0xFF {
+					return StatusErrCheckErased
+				}
+				fd.memory[offset+i] = b
+			}
+			return StatusOK
+		}
+	default:
+		fd.pending = func() StatusCode {
+			if block == fd.failBlock {
+				return StatusErrWrite
+			}
+			fd.firmware = append(fd.firmware, data...)
+			return StatusOK
+		}
+	}
+	fd.state = StateDnloadSync
+}
+
+// command runs a DfuSe command on a flash of 1 KiB pages.
+func (fd *fakeDFU) command(cmd []byte) StatusCode {
+	if len(cmd) == 1 && cmd[0] == dfuseErase {
+		fd.Record("mass erase")
+		for i := range fd.memory {
+			fd.memory[i] = 0xFF
+		}
+		return StatusOK
+	}
+	address := binary.LittleEndian.Uint32(cmd[1:])
+	if address < dfuseBase || int(address-dfuseBase) >= len(fd.memory) {
+		return StatusErrAddress
+	}
+	switch cmd[0] {
+	case dfuseSetAddress:
+		fd.pointer = address
+	case dfuseErase:
+		fd.Record("erase %#x", address)
+		page := int(address-dfuseBase) / 1024 * 1024
+		for i := page; i < page+1024; i++ {
+			fd.memory[i] = 0xFF
+		}
+	default:
+		return StatusErrStalledPkt
+	}
+	return StatusOK
+}
+
+// dfuInterface is a DFU mode interface with a DFU 1.1 functional
+// descriptor.
+func dfuInterface(
+	attributes byte,
+	transferSize uint16,
+	version uint16,
+) *libusb.InterfaceDescriptor {
+	extra := []byte{9, functionalDescriptorType, attributes, 0xFF, 0x00, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(extra[5:], transferSize)
+	binary.LittleEndian.PutUint16(extra[7:], version)
+	return &libusb.InterfaceDescriptor{
+		InterfaceClass:    libusb.InterfaceClassApplication,
+		InterfaceSubClass: SubclassDFU,
+		InterfaceProtocol: ProtocolDFUMode,
+		Extra:             extra,
+	}
+}
+
+func openDFU(
+	t *testing.T,
+	fd *fakeDFU,
+	iface *libusb.InterfaceDescriptor,
+) (*Device, *[]time.Duration) {
+	t.Helper()
+	dev, err := Open(fd, iface)
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	sleeps := &[]time.Duration{}
+	dev.sleep = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+	return dev, sleeps
+}
+
+func TestParseFunctionalDescriptor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		extra    []byte
+		expected FunctionalDescriptor
+	}{
+		{
+			"DFU 1.1",
+			[]byte{9, 0x21, 0x0B, 0xFF, 0x00, 0x00, 0x08, 0x10, 0x01},
+			FunctionalDescriptor{0x0B, 255, 2048, 0x0110},
+		},
+		{
+			"DfuSe after another descriptor",
+			[]byte{3, 0x24, 0x00, 9, 0x21, 0x0B, 0xFF, 0x00, 0x00, 0x08, 0x1A, 0x01},
+			FunctionalDescriptor{0x0B, 255, 2048, 0x011A},
+		},
+		{
+			"DFU 1.0",
+			[]byte{7, 0x21, 0x03, 0x64, 0x00, 0x40, 0x00},
+			FunctionalDescriptor{0x03, 100, 64, 0x0100},
+		},
+	}
+	for _, tc := range testCases {
+		desc, err := ParseFunctionalDescriptor(tc.extra)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if *desc != tc.expected {
+			t.Errorf("%s: got %+v, want %+v", tc.name, *desc, tc.expected)
+		}
+	}
+	if _, err := ParseFunctionalDescriptor([]byte{3, 0x24, 0x00}); err == nil {
+		t.Error("ParseFunctionalDescriptor without one: expected error, got nil")
+	}
+
+	attrs := Attributes(0x0B)
+	if !attrs.CanDownload() || !attrs.CanUpload() || attrs.ManifestationTolerant() ||
+		!attrs.WillDetach() {
+		t.Errorf("Attributes(0x0b) decoded wrong")
+	}
+}
+
+func TestFindInterfaces(t *testing.T) {
+	alt1 := dfuInterface(0x0B, 2048, 0x011A)
+	alt1.AlternateSetting = 1
+	config := &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{
+				{InterfaceClass: libusb.InterfaceClassApplication, InterfaceSubClass: 0x02},
+			}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{
+				dfuInterface(0x0B, 2048, 0x011A),
+				alt1,
+			}},
+		},
+	}
+	found := FindInterfaces(config)
+	if len(found) != 2 || found[1].AlternateSetting != 1 {
+		t.Errorf("FindInterfaces = %v, want both alternate settings", found)
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fd := newFakeDFU(64)
+	iface := dfuInterface(0x07, 64, 0x0110)
+	iface.InterfaceNumber = 1
+	iface.AlternateSetting = 2
+	dev, _ := openDFU(t, fd, iface)
+	if dev.Runtime || dev.Descriptor.TransferSize != 64 {
+		t.Errorf("Open = %+v, want DFU mode with 64-byte transfers", dev)
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"claim 1", "alt 1 2", "release 1"}
+	if calls := fd.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := Open(fd, &libusb.InterfaceDescriptor{}); err == nil {
+		t.Error("Open without a functional descriptor: expected error, got nil")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	testCases := []struct {
+		attributes byte
+		expected   []string
+	}{
+		{0x0F, []string{"detach 255"}},
+		{0x07, []string{"detach 255", "reset"}},
+	}
+	for _, tc := range testCases {
+		fd := newFakeDFU(64)
+		iface := dfuInterface(tc.attributes, 64, 0x0110)
+		iface.InterfaceProtocol = ProtocolRuntime
+		dev, _ := openDFU(t, fd, iface)
+		fd.Reset()
+		if err := dev.Detach(); err != nil {
+			t.Fatalf("Detach: unexpected error %v", err)
+		}
+		if calls := fd.Calls(); !slices.Equal(calls, tc.expected) {
+			t.Errorf("attributes %#02x: calls = %q, want %q", tc.attributes, calls, tc.expected)
+		}
+		if err := dev.Download([]byte{1}, nil); err == nil {
+			t.Error("Download in run-time mode: expected error, got nil")
+		}
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	status, err := parseStatus([]byte{0x03, 0x10, 0x27, 0x00, 0x0A, 0x04})
+	if err != nil {
+		t.Fatalf("parseStatus: unexpected error %v", err)
+	}
+	want := Status{StatusErrWrite, 10 * time.Second, StateError, 4}
+	if status != want {
+		t.Errorf("parseStatus = %+v, want %+v", status, want)
+	}
+	err = &StatusError{Status: status}
+	if got := err.Error(); got != "dfu: unable to write memory in state dfuERROR" {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DfuSe commands from ST application note AN3156. They are sent as DNLOAD
+// block 0; blocks 2 and up carry data for the address pointer.
+const (
+	dfuseSetAddress = 0x21
+	dfuseErase      = 0x41
+	dfuseDataBlock  = 2
+)
+
+// SetAddress sets the DfuSe address pointer used by the following data
+// blocks.
+func (dev *Device) SetAddress(address uint32) error {
+	return dev.dfuseCommand(dfuseSetAddress, address)
+}
+
+// ErasePage erases the flash page containing address.
+func (dev *Device) ErasePage(address uint32) error {
+	return dev.dfuseCommand(dfuseErase, address)
+}
+
+// MassErase erases the whole flash. It can take tens of seconds; the device
+// says how long to wait through its poll timeout.
+func (dev *Device) MassErase() error {
+	if err := dev.checkMode(true); err != nil {
+		return err
+	}
+	return dev.dfuseCommandBytes([]byte{dfuseErase})
+}
+
+func (dev *Device) dfuseCommand(command byte, address uint32) error {
+	if err := dev.checkMode(true); err != nil {
+		return err
+	}
+	cmd := make([]byte, 5)
+	cmd[0] = command
+	binary.LittleEndian.PutUint32(cmd[1:], address)
+	return dev.dfuseCommandBytes(cmd)
+}
+
+func (dev *Device) dfuseCommandBytes(cmd []byte) error {
+	if err := dev.DownloadBlock(0, cmd); err != nil {
+		return err
+	}
+	status, err := dev.pollStatus()
+	if err != nil {
+		return fmt.Errorf("dfu: DfuSe command %#02x: %w", cmd[0], err)
+	}
+	if status.State != StateDnloadIdle {
+		return fmt.Errorf("dfu: device is in state %v after DfuSe command %#02x",
+			status.State, cmd[0])
+	}
+	return nil
+}
+
+// WriteMemory downloads data to address, which must already be erased.
+// progress, if not nil, is called after each block.
+func (dev *Device) WriteMemory(address uint32, data []byte, progress ProgressFunc) error {
+	if err := dev.checkMode(true); err != nil {
+		return err
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return err
+	}
+	size := int(dev.Descriptor.TransferSize)
+	for done := 0; done < len(data); {
+		chunk := data[done:min(done+size, len(data))]
+		// Setting the pointer for every block, as dfu-util does, keeps the
+		// address right whatever the device thinks the block size is.
+		if err := dev.SetAddress(address + uint32(done)); err != nil {
+			return err
+		}
+		if err := dev.DownloadBlock(dfuseDataBlock, chunk); err != nil {
+			return err
+		}
+		status, err := dev.pollStatus()
+		if err != nil {
+			return fmt.Errorf("dfu: writing %#08x: %w", address+uint32(done), err)
+		}
+		if status.State != StateDnloadIdle {
+			return fmt.Errorf("dfu: device is in state %v after writing %#08x",
+				status.State, address+uint32(done))
+		}
+		done += len(chunk)
+		if progress != nil {
+			progress(done, len(data))
+		}
+	}
+	return nil
+}
+
+// ReadMemory uploads length bytes from address.
+func (dev *Device) ReadMemory(address uint32, length int) ([]byte, error) {
+	if err := dev.checkMode(true); err != nil {
+		return nil, err
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return nil, err
+	}
+	if err := dev.SetAddress(address); err != nil {
+		return nil, err
+	}
+	// Uploads start from dfuIDLE, so the download the command left open has
+	// to be aborted first.
+	if err := dev.Abort(); err != nil {
+		return nil, err
+	}
+	size := int(dev.Descriptor.TransferSize)
+	data := make([]byte, 0, length)
+	for block := uint16(dfuseDataBlock); len(data) < length; block++ {
+		buf := make([]byte, min(size, length-len(data)))
+		n, err := dev.UploadBlock(block, buf)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, buf[:n]...)
+		if n < len(buf) {
+			return data, fmt.Errorf("dfu: read ended at %#08x", address+uint32(len(data)))
+		}
+	}
+	return data, dev.Abort()
+}
+
+// Leave makes the device leave DFU mode and jump to the application at
+// address. The device resets, so the handle is unusable afterwards.
+func (dev *Device) Leave(address uint32) error {
+	if err := dev.checkMode(true); err != nil {
+		return err
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return err
+	}
+	if err := dev.SetAddress(address); err != nil {
+		return err
+	}
+	if err := dev.DownloadBlock(dfuseDataBlock, nil); err != nil {
+		return err
+	}
+	// The status request is what starts the jump. The device may drop off
+	// the bus before it answers, so the reply doesn't matter.
+	dev.GetStatus()
+	return nil
+}
+
+// Flash erases the pages that data covers at address and then writes it,
+// using the device's memory layout to find the pages. progress, if not nil,
+// is called as the data is written.
+func (dev *Device) Flash(
+	layout *MemoryLayout,
+	address uint32,
+	data []byte,
+	progress ProgressFunc,
+) error {
+	if layout == nil {
+		return fmt.Errorf("dfu: nil memory layout")
+	}
+	pages, err := layout.pagesToErase(address, len(data))
+	if err != nil {
+		return err
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := dev.ErasePage(page); err != nil {
+			return fmt.Errorf("dfu: erasing page at %#08x: %w", page, err)
+		}
+	}
+	return dev.WriteMemory(address, data, progress)
+}
+
+// MemorySegment is a run of equally sized pages in a DfuSe memory layout.
+type MemorySegment struct {
+	Start    uint32
+	PageSize uint32
+	Pages    int
+	Readable bool
+	Erasable bool
+	Writable bool
+}
+
+// End returns the address just past the segment.
+func (segment MemorySegment) End() uint32 {
+	return segment.Start + segment.PageSize*uint32(segment.Pages)
+}
+
+// MemoryLayout describes the memory behind a DfuSe alternate setting, as
+// given in the setting's interface string.
+type MemoryLayout struct {
+	Name     string
+	Segments []MemorySegment
+}
+
+var segmentPattern = regexp.MustCompile(`^\s*(\d+)\s*\*\s*(\d+)\s*([KMB]?)\s*([a-g])\s*$`)
+
+// ParseMemoryLayout parses a DfuSe interface string such as
+//
+//	@Internal Flash  /0x08000000/04*016Kg,01*064Kg,07*128Kg
+//
+// which names the memory and lists, for each start address, page counts and
+// sizes followed by a letter from 'a' to 'g' giving their access. One plus
+// the letter's offset from 'a' has bit 0 set for readable pages, bit 1 for
+// erasable, and bit 2 for writable, so 'g' pages allow all three.
+func ParseMemoryLayout(s string) (*MemoryLayout, error) {
+	if !strings.HasPrefix(s, "@") {
+		return nil, fmt.Errorf("dfu: memory layout %q doesn't start with @", s)
+	}
+	parts := strings.Split(s[1:], "/")
+	if len(parts) < 3 || len(parts)%2 == 0 {
+		return nil, fmt.Errorf("dfu: malformed memory layout %q", s)
+	}
+	layout := &MemoryLayout{Name: strings.TrimSpace(parts[0])}
+	for i := 1; i < len(parts); i += 2 {
+		start, err := strconv.ParseUint(strings.TrimSpace(parts[i]), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("dfu: memory layout address %q: %w", parts[i], err)
+		}
+		address := uint32(start)
+		for _, item := range strings.Split(parts[i+1], ",") {
+			m := segmentPattern.FindStringSubmatch(item)
+			if m == nil {
+				return nil, fmt.Errorf("dfu: malformed memory segment %q", item)
+			}
+			pages, _ := strconv.Atoi(m[1])
+			size, _ := strconv.ParseUint(m[2], 10, 32)
+			switch m[3] {
+			case "K":
+				size *= 1024
+			case "M":
+				size *= 1024 * 1024
+			}
+			access := m[4][0] - 'a' + 1
+			segment := MemorySegment{
+				Start:    address,
+				PageSize: uint32(size),
+				Pages:    pages,
+				Readable: access&0x01 != 0,
+				Erasable: access&0x02 != 0,
+				Writable: access&0x04 != 0,
+			}
+			layout.Segments = append(layout.Segments, segment)
+			address = segment.End()
+		}
+	}
+	return layout, nil
+}
+
+// Segment returns the segment containing address.
+func (layout *MemoryLayout) Segment(address uint32) (MemorySegment, bool) {
+	for _, segment := range layout.Segments {
+		if address >= segment.Start && address < segment.End() {
+			return segment, true
+		}
+	}
+	return MemorySegment{}, false
+}
+
+// pagesToErase returns the start of every erasable page that overlaps the
+// length bytes at address, checking that all of them are writable.
+func (layout *MemoryLayout) pagesToErase(address uint32, length int) ([]uint32, error) {
+	var pages []uint32
+	end := address + uint32(length)
+	for pos := address; pos < end; {
+		segment, ok := layout.Segment(pos)
+		if !ok {
+			return nil, fmt.Errorf("dfu: address %#08x is outside %s", pos, layout.Name)
+		}
+		if !segment.Writable {
+			return nil, fmt.Errorf("dfu: address %#08x in %s isn't writable", pos, layout.Name)
+		}
+		page := segment.Start + (pos-segment.Start)/segment.PageSize*segment.PageSize
+		if segment.Erasable {
+			pages = append(pages, page)
+		}
+		pos = page + segment.PageSize
+	}
+	return pages, nil
+}
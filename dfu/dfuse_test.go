@@ -0,0 +1,163 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseMemoryLayout(t *testing.T) {
+	testCases := []struct {
+		given    string
+		expected MemoryLayout
+	}{
+		{
+			"@Internal Flash  /0x08000000/04*016Kg,01*064Kg,07*128Kg",
+			MemoryLayout{"Internal Flash", []MemorySegment{
+				{0x08000000, 16 * 1024, 4, true, true, true},
+				{0x08010000, 64 * 1024, 1, true, true, true},
+				{0x08020000, 128 * 1024, 7, true, true, true},
+			}},
+		},
+		{
+			"@Option Bytes  /0x1FFFC000/01*016 e",
+			MemoryLayout{"Option Bytes", []MemorySegment{
+				{0x1FFFC000, 16, 1, true, false, true},
+			}},
+		},
+		{
+			"@Flash/0x08000000/2*001Ka,6*001Kg/0x20000000/1*128 a",
+			MemoryLayout{"Flash", []MemorySegment{
+				{0x08000000, 1024, 2, true, false, false},
+				{0x08000800, 1024, 6, true, true, true},
+				{0x20000000, 128, 1, true, false, false},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		layout, err := ParseMemoryLayout(tc.given)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.given, err)
+			continue
+		}
+		if !reflect.DeepEqual(*layout, tc.expected) {
+			t.Errorf("%q: got %+v, want %+v", tc.given, *layout, tc.expected)
+		}
+	}
+	bad := []string{"Flash/0x0/1*1Kg", "@Flash/0x0", "@Flash/0x0/1*1Kz", "@Flash/zz/1*1Kg"}
+	for _, bad := range bad {
+		if _, err := ParseMemoryLayout(bad); err == nil {
+			t.Errorf("%q: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestPagesToErase(t *testing.T) {
+	layout, err := ParseMemoryLayout("@Flash/0x08000000/2*001Ka,6*001Kg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		address  uint32
+		length   int
+		expected []uint32
+		hasError bool
+	}{
+		{0x08000800, 1024, []uint32{0x08000800}, false},
+		{0x08000900, 1024, []uint32{0x08000800, 0x08000C00}, false},
+		{0x08001C00, 1, []uint32{0x08001C00}, false},
+		{0x08000400, 16, nil, true},
+		{0x08001C00, 2048, nil, true},
+	}
+	for _, tc := range testCases {
+		pages, err := layout.pagesToErase(tc.address, tc.length)
+		if (err != nil) != tc.hasError {
+			t.Errorf("%#x+%d: error = %v, want error %t", tc.address, tc.length, err, tc.hasError)
+			continue
+		}
+		if !slices.Equal(pages, tc.expected) {
+			t.Errorf("%#x+%d: pages = %#x, want %#x", tc.address, tc.length, pages, tc.expected)
+		}
+	}
+}
+
+func newFakeDfuSe(t *testing.T) (*fakeDFU, *Device) {
+	t.Helper()
+	fd := newFakeDFU(256)
+	fd.dfuSe = true
+	fd.memory = bytes.Repeat([]byte{0x00}, 4096)
+	dev, _ := openDFU(t, fd, dfuInterface(0x0B, 256, 0x011A))
+	return fd, dev
+}
+
+func TestFlashAndReadMemory(t *testing.T) {
+	fd, dev := newFakeDfuSe(t)
+	layout, err := ParseMemoryLayout("@Flash/0x08000000/4*001Kg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte{0x12, 0x34, 0x56}, 400)
+	var progress []int
+	err = dev.Flash(layout, dfuseBase+0x200, data, func(done, total int) {
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatalf("Flash: unexpected error %v", err)
+	}
+	want := []string{"claim 0", "erase 0x8000000", "erase 0x8000400"}
+	if calls := fd.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if want := []int{256, 512, 768, 1024, 1200}; !slices.Equal(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+	if !bytes.Equal(fd.memory[0x200:0x200+len(data)], data) {
+		t.Error("flash doesn't hold the data written")
+	}
+
+	got, err := dev.ReadMemory(dfuseBase+0x200, len(data))
+	if err != nil {
+		t.Fatalf("ReadMemory: unexpected error %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadMemory returned %d bytes that differ from the data written", len(got))
+	}
+	if fd.state != StateIdle {
+		t.Errorf("state after ReadMemory = %v, want %v", fd.state, StateIdle)
+	}
+}
+
+func TestDfuSeCommands(t *testing.T) {
+	fd, dev := newFakeDfuSe(t)
+	if err := dev.MassErase(); err != nil {
+		t.Fatalf("MassErase: unexpected error %v", err)
+	}
+	if fd.memory[100] != 0xFF {
+		t.Error("MassErase didn't erase the flash")
+	}
+	if err := dev.SetAddress(0x20000000); err == nil {
+		t.Error("SetAddress outside the flash: expected error, got nil")
+	}
+	// Writing over data that isn't erased fails.
+	if err := dev.WriteMemory(dfuseBase, []byte{1}, nil); err != nil {
+		t.Fatalf("WriteMemory: unexpected error %v", err)
+	}
+	if err := dev.WriteMemory(dfuseBase, []byte{2}, nil); err == nil {
+		t.Error("WriteMemory over written flash: expected error, got nil")
+	}
+	if err := dev.Leave(dfuseBase); err != nil {
+		t.Fatalf("Leave: unexpected error %v", err)
+	}
+	if !fd.left || fd.pointer != dfuseBase {
+		t.Errorf("Leave: left = %t at %#x", fd.left, fd.pointer)
+	}
+	if err := dev.Download([]byte{1}, nil); err == nil {
+		t.Error("Download on a DfuSe device: expected error, got nil")
+	}
+}
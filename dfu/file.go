@@ -0,0 +1,189 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// suffixLength is the length of the DFU 1.1 suffix. Files may carry a
+// longer suffix whose extra fields come first.
+const suffixLength = 16
+
+// AnyID in a suffix field matches any device.
+const AnyID = 0xFFFF
+
+// Suffix is the DFU suffix at the end of a firmware file, which says what
+// device the firmware is for.
+type Suffix struct {
+	DeviceRelease uint16
+	ProductID     uint16
+	VendorID      uint16
+	// DFUVersion is 0x0100 for plain DFU files and 0x011A for DfuSe files.
+	DFUVersion uint16
+}
+
+// Matches reports whether the suffix allows the firmware on a device.
+// Fields set to AnyID match every device.
+func (suffix Suffix) Matches(desc *libusb.Descriptor) bool {
+	return (suffix.VendorID == AnyID || suffix.VendorID == desc.VendorID) &&
+		(suffix.ProductID == AnyID || suffix.ProductID == desc.ProductID) &&
+		(suffix.DeviceRelease == AnyID || suffix.DeviceRelease == uint16(desc.DeviceReleaseNumber))
+}
+
+// File is a firmware file split into the firmware and its suffix.
+type File struct {
+	Data   []byte
+	Suffix Suffix
+}
+
+// ReadFile reads a firmware file with a DFU suffix.
+func ReadFile(name string) (*File, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("dfu: %w", err)
+	}
+	return ParseFile(b)
+}
+
+// ParseFile splits a firmware file into the firmware and its suffix,
+// checking the suffix's signature and CRC.
+func ParseFile(b []byte) (*File, error) {
+	if len(b) < suffixLength {
+		return nil, fmt.Errorf("dfu: file of %d bytes is too short for a DFU suffix", len(b))
+	}
+	suffix := b[len(b)-suffixLength:]
+	if !bytes.Equal(suffix[8:11], []byte("UFD")) {
+		return nil, fmt.Errorf("dfu: file has no DFU suffix")
+	}
+	length := int(suffix[11])
+	if length < suffixLength || length > len(b) {
+		return nil, fmt.Errorf("dfu: invalid DFU suffix length %d", length)
+	}
+	want := binary.LittleEndian.Uint32(suffix[12:])
+	if got := fileCRC(b[:len(b)-4]); got != want {
+		return nil, fmt.Errorf("dfu: file CRC is %#08x, suffix says %#08x", got, want)
+	}
+	return &File{
+		Data: b[:len(b)-length],
+		Suffix: Suffix{
+			DeviceRelease: binary.LittleEndian.Uint16(suffix[0:]),
+			ProductID:     binary.LittleEndian.Uint16(suffix[2:]),
+			VendorID:      binary.LittleEndian.Uint16(suffix[4:]),
+			DFUVersion:    binary.LittleEndian.Uint16(suffix[6:]),
+		},
+	}, nil
+}
+
+// Bytes returns the firmware followed by its suffix, as written to a .dfu
+// file.
+func (file *File) Bytes() []byte {
+	b := make([]byte, len(file.Data)+suffixLength)
+	copy(b, file.Data)
+	suffix := b[len(file.Data):]
+	binary.LittleEndian.PutUint16(suffix[0:], file.Suffix.DeviceRelease)
+	binary.LittleEndian.PutUint16(suffix[2:], file.Suffix.ProductID)
+	binary.LittleEndian.PutUint16(suffix[4:], file.Suffix.VendorID)
+	binary.LittleEndian.PutUint16(suffix[6:], file.Suffix.DFUVersion)
+	copy(suffix[8:], "UFD")
+	suffix[11] = suffixLength
+	binary.LittleEndian.PutUint32(suffix[12:], fileCRC(b[:len(b)-4]))
+	return b
+}
+
+// fileCRC is the suffix CRC: the IEEE CRC-32 without the final inversion,
+// as dfu-util computes it.
+func fileCRC(b []byte) uint32 {
+	return ^crc32.ChecksumIEEE(b)
+}
+
+// DfuSe image layout from ST document UM0391.
+const (
+	dfusePrefixLength       = 11
+	dfuseTargetPrefixLength = 274
+	dfuseElementHeader      = 8
+)
+
+// DfuSeImage is the content of a DfuSe file: firmware for one or more
+// alternate settings, each made of elements placed at addresses.
+type DfuSeImage struct {
+	Targets []DfuSeTarget
+}
+
+// DfuSeTarget is the firmware for one alternate setting.
+type DfuSeTarget struct {
+	AlternateSetting uint8
+	Name             string
+	Elements         []DfuSeElement
+}
+
+// DfuSeElement is a contiguous piece of firmware.
+type DfuSeElement struct {
+	Address uint32
+	Data    []byte
+}
+
+// ParseDfuSeImage decodes the DfuSe image in the data of a file whose suffix
+// has DFU version 0x011A.
+func ParseDfuSeImage(data []byte) (*DfuSeImage, error) {
+	if len(data) < dfusePrefixLength || !bytes.Equal(data[:5], []byte("DfuSe")) {
+		return nil, fmt.Errorf("dfu: no DfuSe prefix")
+	}
+	if data[5] != 0x01 {
+		return nil, fmt.Errorf("dfu: unsupported DfuSe version %d", data[5])
+	}
+	if size := binary.LittleEndian.Uint32(data[6:]); int(size) > len(data) {
+		return nil, fmt.Errorf(
+			"dfu: DfuSe image size %d is larger than the file's %d",
+			size,
+			len(data),
+		)
+	}
+	image := &DfuSeImage{}
+	rest := data[dfusePrefixLength:]
+	for i := 0; i < int(data[10]); i++ {
+		if len(rest) < dfuseTargetPrefixLength || !bytes.Equal(rest[:6], []byte("Target")) {
+			return nil, fmt.Errorf("dfu: DfuSe target %d has no prefix", i)
+		}
+		target := DfuSeTarget{AlternateSetting: rest[6]}
+		if binary.LittleEndian.Uint32(rest[7:]) != 0 {
+			name := rest[11 : 11+255]
+			if end := bytes.IndexByte(name, 0); end >= 0 {
+				name = name[:end]
+			}
+			target.Name = string(name)
+		}
+		size := int(binary.LittleEndian.Uint32(rest[266:]))
+		elements := int(binary.LittleEndian.Uint32(rest[270:]))
+		rest = rest[dfuseTargetPrefixLength:]
+		if size > len(rest) {
+			return nil, fmt.Errorf("dfu: DfuSe target %d runs past the end of the file", i)
+		}
+		body := rest[:size]
+		rest = rest[size:]
+		for j := 0; j < elements; j++ {
+			if len(body) < dfuseElementHeader {
+				return nil, fmt.Errorf("dfu: DfuSe target %d element %d is truncated", i, j)
+			}
+			address := binary.LittleEndian.Uint32(body)
+			length := int(binary.LittleEndian.Uint32(body[4:]))
+			body = body[dfuseElementHeader:]
+			if length > len(body) {
+				return nil, fmt.Errorf("dfu: DfuSe target %d element %d is truncated", i, j)
+			}
+			target.Elements = append(target.Elements, DfuSeElement{address, body[:length]})
+			body = body[length:]
+		}
+		image.Targets = append(image.Targets, target)
+	}
+	return image, nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	file := &File{
+		Data: []byte("firmware image"),
+		Suffix: Suffix{
+			DeviceRelease: AnyID,
+			ProductID:     0xDF11,
+			VendorID:      0x0483,
+			DFUVersion:    0x0100,
+		},
+	}
+	b := file.Bytes()
+	if len(b) != len(file.Data)+suffixLength {
+		t.Fatalf("Bytes returned %d bytes, want %d", len(b), len(file.Data)+suffixLength)
+	}
+	name := filepath.Join(t.TempDir(), "fw.dfu")
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error %v", err)
+	}
+	if !bytes.Equal(got.Data, file.Data) || got.Suffix != file.Suffix {
+		t.Errorf("ReadFile = %+v, want %+v", got, file)
+	}
+
+	b[0] ^= 0xFF
+	if _, err := ParseFile(b); err == nil {
+		t.Error("ParseFile with a bad CRC: expected error, got nil")
+	}
+	if _, err := ParseFile([]byte("firmware without a suffix")); err == nil {
+		t.Error("ParseFile without a suffix: expected error, got nil")
+	}
+}
+
+func TestFileCRC(t *testing.T) {
+	// The suffix CRC dfu-util writes for an empty file with an all-0xFFFF
+	// suffix.
+	file := &File{Suffix: Suffix{AnyID, AnyID, AnyID, 0x0100}}
+	b := file.Bytes()
+	want := ^crc32Reference(b[:12])
+	if got := binary.LittleEndian.Uint32(b[12:]); got != want {
+		t.Errorf("CRC = %#08x, want %#08x", got, want)
+	}
+}
+
+// crc32Reference is a bitwise IEEE CRC-32, to check fileCRC against.
+func crc32Reference(b []byte) uint32 {
+	crc := ^uint32(0)
+	for _, c := range b {
+		crc ^= uint32(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xEDB88320
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+func TestSuffixMatches(t *testing.T) {
+	desc := &libusb.Descriptor{VendorID: 0x0483, ProductID: 0xDF11, DeviceReleaseNumber: 0x2200}
+	testCases := []struct {
+		suffix   Suffix
+		expected bool
+	}{
+		{Suffix{AnyID, AnyID, AnyID, 0x011A}, true},
+		{Suffix{0x2200, 0xDF11, 0x0483, 0x011A}, true},
+		{Suffix{AnyID, 0xDF11, 0x1209, 0x011A}, false},
+		{Suffix{0x2100, AnyID, AnyID, 0x011A}, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.suffix.Matches(desc); got != tc.expected {
+			t.Errorf("%+v.Matches = %t, want %t", tc.suffix, got, tc.expected)
+		}
+	}
+}
+
+// dfuseImage builds a DfuSe image with one named target.
+func dfuseImage(alt byte, name string, elements ...DfuSeElement) []byte {
+	var body []byte
+	for _, element := range elements {
+		body = binary.LittleEndian.AppendUint32(body, element.Address)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(element.Data)))
+		body = append(body, element.Data...)
+	}
+	target := make([]byte, dfuseTargetPrefixLength)
+	copy(target, "Target")
+	target[6] = alt
+	target[7] = 1
+	copy(target[11:], name)
+	binary.LittleEndian.PutUint32(target[266:], uint32(len(body)))
+	binary.LittleEndian.PutUint32(target[270:], uint32(len(elements)))
+	image := []byte("DfuSe\x01\x00\x00\x00\x00\x01")
+	image = append(image, target...)
+	image = append(image, body...)
+	binary.LittleEndian.PutUint32(image[6:], uint32(len(image)))
+	return image
+}
+
+func TestParseDfuSeImage(t *testing.T) {
+	elements := []DfuSeElement{
+		{0x08000000, []byte("vector table")},
+		{0x08004000, []byte("application")},
+	}
+	image, err := ParseDfuSeImage(dfuseImage(0, "ST...", elements...))
+	if err != nil {
+		t.Fatalf("ParseDfuSeImage: unexpected error %v", err)
+	}
+	if len(image.Targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(image.Targets))
+	}
+	target := image.Targets[0]
+	if target.Name != "ST..." || target.AlternateSetting != 0 || len(target.Elements) != 2 {
+		t.Fatalf("target = %+v", target)
+	}
+	for i, element := range target.Elements {
+		if element.Address != elements[i].Address || !bytes.Equal(element.Data, elements[i].Data) {
+			t.Errorf("element %d = %+v, want %+v", i, element, elements[i])
+		}
+	}
+
+	truncated := dfuseImage(1, "", elements...)
+	truncated = truncated[:len(truncated)-4]
+	if _, err := ParseDfuSeImage(truncated); err == nil {
+		t.Error("ParseDfuSeImage of a truncated image: expected error, got nil")
+	}
+	if _, err := ParseDfuSeImage([]byte("not a DfuSe image")); err == nil {
+		t.Error("ParseDfuSeImage without a prefix: expected error, got nil")
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ProgressFunc is called as a transfer proceeds with the number of bytes
+// done so far and the total, which is -1 when it isn't known in advance.
+type ProgressFunc func(done int, total int)
+
+// pollStatus sends GETSTATUS until the device leaves the busy and sync
+// states, waiting the poll timeout it asks for between requests. A status
+// other than OK is returned as a *StatusError.
+func (dev *Device) pollStatus() (Status, error) {
+	for {
+		status, err := dev.GetStatus()
+		if err != nil {
+			return status, err
+		}
+		if status.Code != StatusOK || status.State == StateError {
+			return status, &StatusError{Status: status}
+		}
+		switch status.State {
+		case StateDnloadSync, StateDnBusy, StateManifestSync, StateManifest:
+			dev.sleep(status.PollTimeout)
+		default:
+			return status, nil
+		}
+	}
+}
+
+// EnsureIdle brings the device to dfuIDLE: an error status is cleared and
+// an unfinished download or upload is aborted.
+func (dev *Device) EnsureIdle() error {
+	status, err := dev.GetStatus()
+	if err != nil {
+		return err
+	}
+	switch status.State {
+	case StateIdle:
+		return nil
+	case StateError:
+		err = dev.ClearStatus()
+	case StateDnloadIdle, StateUploadIdle:
+		err = dev.Abort()
+	case StateAppIdle, StateAppDetach:
+		return fmt.Errorf("dfu: device is in run-time mode; detach it first")
+	default:
+		return fmt.Errorf("dfu: device is busy in state %v", status.State)
+	}
+	if err != nil {
+		return err
+	}
+	state, err := dev.GetState()
+	if err != nil {
+		return err
+	}
+	if state != StateIdle {
+		return fmt.Errorf("dfu: device is in state %v, want %v", state, StateIdle)
+	}
+	return nil
+}
+
+func (dev *Device) checkMode(dfuSe bool) error {
+	if dev.Runtime {
+		return fmt.Errorf("dfu: device is in run-time mode; detach it first")
+	}
+	if dev.Descriptor.IsDfuSe() != dfuSe {
+		if dfuSe {
+			return fmt.Errorf("dfu: device doesn't support the DfuSe extensions")
+		}
+		return fmt.Errorf("dfu: DfuSe device needs an address; use WriteMemory or ReadMemory")
+	}
+	return nil
+}
+
+// Download writes a firmware image to the device in blocks of the
+// descriptor's transfer size and then has the device manifest it. progress,
+// if not nil, is called after each block.
+//
+// A device that is manifestation tolerant ends in dfuIDLE. Otherwise it ends
+// in dfuMANIFEST-WAIT-RESET, or resets itself, and runs the new firmware
+// once reset; the caller should close the handle and reset the device if it
+// is still there.
+func (dev *Device) Download(data []byte, progress ProgressFunc) error {
+	if err := dev.checkMode(false); err != nil {
+		return err
+	}
+	if !dev.Descriptor.Attributes.CanDownload() {
+		return fmt.Errorf("dfu: device doesn't support download")
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return err
+	}
+	size := int(dev.Descriptor.TransferSize)
+	block := uint16(0)
+	for done := 0; done < len(data); block++ {
+		chunk := data[done:min(done+size, len(data))]
+		if err := dev.DownloadBlock(block, chunk); err != nil {
+			return err
+		}
+		status, err := dev.pollStatus()
+		if err != nil {
+			return err
+		}
+		if status.State != StateDnloadIdle {
+			return fmt.Errorf("dfu: device is in state %v after block %d", status.State, block)
+		}
+		done += len(chunk)
+		if progress != nil {
+			progress(done, len(data))
+		}
+	}
+	return dev.manifest(block)
+}
+
+// manifest ends a download with a zero-length block and waits for the
+// device to apply the firmware.
+func (dev *Device) manifest(block uint16) error {
+	if err := dev.DownloadBlock(block, nil); err != nil {
+		return err
+	}
+	status, err := dev.pollStatus()
+	if err != nil {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) && !dev.Descriptor.Attributes.ManifestationTolerant() {
+			// The device reset itself to run the new firmware.
+			return nil
+		}
+		return err
+	}
+	switch status.State {
+	case StateIdle, StateManifestWaitReset:
+		return nil
+	}
+	return fmt.Errorf("dfu: device is in state %v after manifestation", status.State)
+}
+
+// Upload reads the firmware image from the device. The device ends the
+// upload with a block shorter than the transfer size. progress, if not nil,
+// is called after each block with a total of -1.
+func (dev *Device) Upload(progress ProgressFunc) ([]byte, error) {
+	if err := dev.checkMode(false); err != nil {
+		return nil, err
+	}
+	if !dev.Descriptor.Attributes.CanUpload() {
+		return nil, fmt.Errorf("dfu: device doesn't support upload")
+	}
+	if err := dev.EnsureIdle(); err != nil {
+		return nil, err
+	}
+	size := int(dev.Descriptor.TransferSize)
+	var data []byte
+	for block := uint16(0); ; block++ {
+		buf := make([]byte, size)
+		n, err := dev.UploadBlock(block, buf)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, buf[:n]...)
+		if progress != nil {
+			progress(len(data), -1)
+		}
+		if n < size {
+			return data, nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package dfu
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tolerant bool
+		state    State
+	}{
+		{"tolerant", true, StateIdle},
+		{"not tolerant", false, StateManifestWaitReset},
+	}
+	firmware := bytes.Repeat([]byte("firmware"), 20)
+	for _, tc := range testCases {
+		fd := newFakeDFU(64)
+		fd.tolerant = tc.tolerant
+		attributes := byte(0x03)
+		if tc.tolerant {
+			attributes |= 0x04
+		}
+		dev, sleeps := openDFU(t, fd, dfuInterface(attributes, 64, 0x0110))
+		var progress []int
+		err := dev.Download(firmware, func(done, total int) {
+			if total != len(firmware) {
+				t.Errorf("%s: progress total = %d, want %d", tc.name, total, len(firmware))
+			}
+			progress = append(progress, done)
+		})
+		if err != nil {
+			t.Fatalf("%s: Download: unexpected error %v", tc.name, err)
+		}
+		if !bytes.Equal(fd.firmware, firmware) {
+			t.Errorf("%s: device got %d bytes, want %d", tc.name, len(fd.firmware), len(firmware))
+		}
+		if want := []int{64, 128, 160}; !slices.Equal(progress, want) {
+			t.Errorf("%s: progress = %v, want %v", tc.name, progress, want)
+		}
+		if fd.state != tc.state {
+			t.Errorf("%s: final state = %v, want %v", tc.name, fd.state, tc.state)
+		}
+		// Three busy periods for the blocks and one for manifestation.
+		want := []time.Duration{5 * time.Millisecond, 5 * time.Millisecond,
+			5 * time.Millisecond, 10 * time.Millisecond}
+		if !slices.Equal(*sleeps, want) {
+			t.Errorf("%s: sleeps = %v, want %v", tc.name, *sleeps, want)
+		}
+	}
+}
+
+func TestDownloadError(t *testing.T) {
+	fd := newFakeDFU(64)
+	fd.failBlock = 1
+	dev, _ := openDFU(t, fd, dfuInterface(0x07, 64, 0x0110))
+	err := dev.Download(make([]byte, 200), nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status.Code != StatusErrWrite {
+		t.Fatalf("Download: got %v, want errWRITE status error", err)
+	}
+	if fd.state != StateError {
+		t.Fatalf("state = %v, want %v", fd.state, StateError)
+	}
+
+	// The next transfer clears the error first.
+	fd.failBlock = -1
+	fd.firmware = nil
+	if err := dev.Download([]byte("again"), nil); err != nil {
+		t.Fatalf("Download after error: unexpected error %v", err)
+	}
+	if string(fd.firmware) != "again" {
+		t.Errorf("device got %q, want %q", fd.firmware, "again")
+	}
+}
+
+func TestEnsureIdle(t *testing.T) {
+	testCases := []struct {
+		state    State
+		hasError bool
+	}{
+		{StateIdle, false},
+		{StateError, false},
+		{StateDnloadIdle, false},
+		{StateUploadIdle, false},
+		{StateAppIdle, true},
+		{StateManifestWaitReset, true},
+	}
+	for _, tc := range testCases {
+		fd := newFakeDFU(64)
+		dev, _ := openDFU(t, fd, dfuInterface(0x07, 64, 0x0110))
+		fd.state = tc.state
+		err := dev.EnsureIdle()
+		if (err != nil) != tc.hasError {
+			t.Errorf("state %v: EnsureIdle error = %v, want error %t", tc.state, err, tc.hasError)
+		}
+		if !tc.hasError && fd.state != StateIdle {
+			t.Errorf("state %v: ended in %v", tc.state, fd.state)
+		}
+	}
+}
+
+func TestUpload(t *testing.T) {
+	testCases := []struct {
+		name   string
+		length int
+	}{
+		{"short last block", 150},
+		{"empty last block", 128},
+	}
+	for _, tc := range testCases {
+		fd := newFakeDFU(64)
+		fd.firmware = bytes.Repeat([]byte{0xA5}, tc.length)
+		dev, _ := openDFU(t, fd, dfuInterface(0x07, 64, 0x0110))
+		calls := 0
+		data, err := dev.Upload(func(done, total int) {
+			calls++
+			if total != -1 {
+				t.Errorf("%s: progress total = %d, want -1", tc.name, total)
+			}
+		})
+		if err != nil {
+			t.Fatalf("%s: Upload: unexpected error %v", tc.name, err)
+		}
+		if !bytes.Equal(data, fd.firmware) {
+			t.Errorf("%s: got %d bytes, want %d", tc.name, len(data), tc.length)
+		}
+		if calls != 3 {
+			t.Errorf("%s: progress called %d times, want 3", tc.name, calls)
+		}
+		if fd.state != StateIdle {
+			t.Errorf("%s: final state = %v, want %v", tc.name, fd.state, StateIdle)
+		}
+	}
+	fd := newFakeDFU(64)
+	dev, _ := openDFU(t, fd, dfuInterface(0x01, 64, 0x0110))
+	if _, err := dev.Upload(nil); err == nil {
+		t.Error("Upload without upload support: expected error, got nil")
+	}
+}
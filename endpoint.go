@@ -16,6 +16,13 @@ type Endpoint struct {
 }
 
 type endpointAddress byte
+
+// EndpointAddress is the bEndpointAddress of an endpoint. Bits 0..3 are the
+// endpoint number and bit 7 is the direction. It names endpointAddress so
+// that packages outside libusb can declare interfaces, such as a class
+// driver's Handle, that *DeviceHandle satisfies.
+type EndpointAddress = endpointAddress
+
 type endpointAttributes byte
 
 // EndpointDescriptor models the descriptor for a given endpoint.
@@ -28,6 +35,9 @@ type EndpointDescriptor struct {
 	Interval        uint8
 	Refresh         uint8
 	SynchAddress    uint8
+	// Extra holds any class-specific or vendor-specific descriptors that
+	// follow the endpoint descriptor.
+	Extra []byte
 }
 
 // EndpointDescriptors contains the available endpoint descriptors.
@@ -155,33 +155,46 @@ func showInfo(ctx *libusb.Context, name string, vendorID, productID uint16) {
 	}
 
 	// Initiate clear
+	const vendorRequest = 0x0C
 	packets := []struct {
-		bmRequestType byte
-		bRequest      byte
-		value         uint16
-		index         uint16
-		data          []byte
-		length        int
+		setup libusb.SetupPacket
+		data  []byte
 	}{
-		{0xC0, 0x0C, 0x0000, 0x047E, make([]byte, 0x01), 0x01},
-		{0xC0, 0x0C, 0x0000, 0x047D, make([]byte, 0x06), 0x06},
-		{0xC0, 0x0C, 0x0000, 0x0484, make([]byte, 0x05), 0x05},
-		{0xC0, 0x0C, 0x0000, 0x0472, make([]byte, 0x0C), 0x0C},
-		{0xC0, 0x0C, 0x0000, 0x047A, make([]byte, 0x01), 0x01},
-		{0x40, 0x0C, 0x0000, 0x0475, []byte{0x00, 0x00, 0x01, 0x01, 0x00, 0x00, 0x08, 0x01}, 0x08},
+		{
+			libusb.NewSetupPacket(libusb.DeviceToHost, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x047E, 0x01),
+			make([]byte, 0x01),
+		},
+		{
+			libusb.NewSetupPacket(libusb.DeviceToHost, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x047D, 0x06),
+			make([]byte, 0x06),
+		},
+		{
+			libusb.NewSetupPacket(libusb.DeviceToHost, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x0484, 0x05),
+			make([]byte, 0x05),
+		},
+		{
+			libusb.NewSetupPacket(libusb.DeviceToHost, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x0472, 0x0C),
+			make([]byte, 0x0C),
+		},
+		{
+			libusb.NewSetupPacket(libusb.DeviceToHost, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x047A, 0x01),
+			make([]byte, 0x01),
+		},
+		{
+			libusb.NewSetupPacket(libusb.HostToDevice, libusb.Vendor, libusb.DeviceRecipient,
+				vendorRequest, 0x0000, 0x0475, 0x08),
+			[]byte{0x00, 0x00, 0x01, 0x01, 0x00, 0x00, 0x08, 0x01},
+		},
 	}
 	for i, packet := range packets {
-		_, err = usbDeviceHandle.ControlTransfer(
-			packet.bmRequestType,
-			packet.bRequest,
-			packet.value,
-			packet.index,
-			packet.data,
-			packet.length,
-			2000,
-		)
+		_, err = usbDeviceHandle.ControlTimeout(packet.setup, packet.data, 2000)
 		if err != nil {
-			log.Printf("Error sending control transfer #%d: %s", i+1, err)
+			log.Printf("Error sending control transfer #%d (%s): %s", i+1, packet.setup, err)
 		}
 	}
 }
@@ -0,0 +1,287 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package fastboot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// Response types.
+const (
+	responseInfo = "INFO"
+	responseText = "TEXT"
+	responseOkay = "OKAY"
+	responseFail = "FAIL"
+	responseData = "DATA"
+)
+
+const (
+	// maxCommandSize is the longest command every device takes.
+	maxCommandSize = 64
+	// maxResponseSize is the longest response a device sends.
+	maxResponseSize = 256
+	// downloadChunk is the most data sent in one transfer of a download.
+	downloadChunk = 1 << 20
+)
+
+// ProgressFunc is called as a download proceeds with the number of bytes
+// sent so far and the total.
+type ProgressFunc func(done int, total int)
+
+// CommandError is returned when the device answers a command with FAIL.
+type CommandError struct {
+	Command string
+	Message string
+}
+
+// Error implements the error interface for CommandError.
+func (err *CommandError) Error() string {
+	return fmt.Sprintf("fastboot: %s failed: %s", err.Command, err.Message)
+}
+
+// Command sends a command, such as "getvar:product" or "set_active:a",
+// and returns the text of the device's OKAY.
+func (dev *Device) Command(cmd string) (string, error) {
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	return dev.command(cmd)
+}
+
+// GetVar returns the value of a bootloader variable, such as product,
+// serialno or max-download-size.
+func (dev *Device) GetVar(name string) (string, error) {
+	return dev.Command("getvar:" + name)
+}
+
+// Erase erases a partition.
+func (dev *Device) Erase(partition string) error {
+	_, err := dev.Command("erase:" + partition)
+	return err
+}
+
+// Reboot reboots the device, into a target such as "bootloader",
+// "recovery" or "fastboot" if it isn't empty.
+func (dev *Device) Reboot(target string) error {
+	cmd := "reboot"
+	if target != "" {
+		cmd += "-" + target
+	}
+	_, err := dev.Command(cmd)
+	return err
+}
+
+// OEM sends a vendor-specific "oem" command, returning the text of the
+// device's OKAY. Most devices answer with INFO responses, which are
+// passed to Info.
+func (dev *Device) OEM(command string) (string, error) {
+	return dev.Command("oem " + command)
+}
+
+// Download sends data to the device's download buffer. It fails without
+// sending anything if data is larger than the device's
+// max-download-size. progress, if not nil, is called after each
+// transfer.
+func (dev *Device) Download(data []byte, progress ProgressFunc) error {
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	return dev.download(data, progress)
+}
+
+// Flash downloads an image and writes it to a partition. progress, if not
+// nil, is called as the image is downloaded.
+func (dev *Device) Flash(partition string, data []byte, progress ProgressFunc) error {
+	dev.cmdMu.Lock()
+	defer dev.cmdMu.Unlock()
+	if err := dev.download(data, progress); err != nil {
+		return err
+	}
+	_, err := dev.command("flash:" + partition)
+	return err
+}
+
+// download runs the download command. The caller holds cmdMu.
+func (dev *Device) download(data []byte, progress ProgressFunc) error {
+	if len(data) == 0 || int64(len(data)) > 0xFFFFFFFF {
+		return fmt.Errorf("fastboot: download of %d bytes, want 1 byte to 4GiB", len(data))
+	}
+	limit, err := dev.maxDownloadSize()
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(data) > limit {
+		return fmt.Errorf(
+			"fastboot: download of %d bytes exceeds the device's max-download-size of %d",
+			len(data),
+			limit,
+		)
+	}
+	cmd := fmt.Sprintf("download:%08x", len(data))
+	if err := dev.send(cmd); err != nil {
+		return err
+	}
+	kind, text, err := dev.result(cmd)
+	if err != nil {
+		return err
+	}
+	if kind != responseData {
+		return fmt.Errorf("fastboot: %s answered with %s, want DATA", cmd, kind)
+	}
+	size, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return fmt.Errorf("fastboot: malformed DATA response %q", text)
+	}
+	if int(size) != len(data) {
+		return fmt.Errorf("fastboot: device takes %d bytes of a %d byte download", size, len(data))
+	}
+	for done := 0; done < len(data); {
+		if dev.isClosed() {
+			return os.ErrClosed
+		}
+		chunk := data[done:min(done+downloadChunk, len(data))]
+		n, err := dev.handle.BulkTransfer(
+			dev.Interface.BulkOut.EndpointAddress,
+			chunk,
+			len(chunk),
+			dev.Timeout,
+		)
+		done += n
+		if err != nil {
+			return fmt.Errorf("fastboot: sending download data: %w", err)
+		}
+		if progress != nil {
+			progress(done, len(data))
+		}
+	}
+	_, err = dev.finish(cmd)
+	return err
+}
+
+// maxDownloadSize returns the device's max-download-size, or 0 if it
+// doesn't report one. The caller holds cmdMu.
+func (dev *Device) maxDownloadSize() (int, error) {
+	if dev.maxDownload != 0 {
+		return max(dev.maxDownload, 0), nil
+	}
+	value, err := dev.command("getvar:max-download-size")
+	var cmdErr *CommandError
+	switch {
+	case errors.As(err, &cmdErr):
+		dev.maxDownload = -1
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	// Devices report the size in hex with a 0x prefix, or in decimal.
+	size, err := strconv.ParseUint(value, 0, 32)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("fastboot: malformed max-download-size %q", value)
+	}
+	dev.maxDownload = int(size)
+	return dev.maxDownload, nil
+}
+
+// command sends a command and waits for its OKAY. The caller holds cmdMu.
+func (dev *Device) command(cmd string) (string, error) {
+	if err := dev.send(cmd); err != nil {
+		return "", err
+	}
+	return dev.finish(cmd)
+}
+
+// finish waits for the OKAY that ends a command.
+func (dev *Device) finish(cmd string) (string, error) {
+	kind, text, err := dev.result(cmd)
+	if err != nil {
+		return "", err
+	}
+	if kind != responseOkay {
+		return "", fmt.Errorf("fastboot: %s answered with %s, want OKAY", cmd, kind)
+	}
+	return text, nil
+}
+
+func (dev *Device) send(cmd string) error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	if len(cmd) > maxCommandSize {
+		return fmt.Errorf(
+			"fastboot: command is %d bytes, the limit is %d",
+			len(cmd),
+			maxCommandSize,
+		)
+	}
+	ep := dev.Interface.BulkOut.EndpointAddress
+	if _, err := usbif.Write(dev.handle, ep, []byte(cmd), dev.Timeout); err != nil {
+		return fmt.Errorf("fastboot: sending %s: %w", cmd, err)
+	}
+	return nil
+}
+
+// result reads responses to a command until one that ends it, passing
+// INFO and TEXT responses to Info. A FAIL is returned as a *CommandError.
+func (dev *Device) result(cmd string) (string, string, error) {
+	for {
+		resp, err := dev.readResponse(cmd)
+		if err != nil {
+			return "", "", err
+		}
+		if len(resp) < 4 {
+			return "", "", fmt.Errorf("fastboot: malformed response %q to %s", resp, cmd)
+		}
+		kind, text := resp[:4], resp[4:]
+		switch kind {
+		case responseInfo, responseText:
+			if dev.Info != nil {
+				dev.Info(text)
+			}
+		case responseFail:
+			return "", "", &CommandError{Command: cmd, Message: text}
+		case responseOkay, responseData:
+			return kind, text, nil
+		default:
+			return "", "", fmt.Errorf("fastboot: unknown response %q to %s", resp, cmd)
+		}
+	}
+}
+
+// readResponse reads a response, waiting up to ResponseTimeout in short
+// transfers so that Close can stop the wait.
+func (dev *Device) readResponse(cmd string) (string, error) {
+	buf := make([]byte, maxResponseSize)
+	var waited int
+	for {
+		if dev.isClosed() {
+			return "", os.ErrClosed
+		}
+		poll := readPoll
+		if dev.ResponseTimeout > 0 {
+			poll = min(poll, dev.ResponseTimeout-waited)
+		}
+		n, err := dev.handle.BulkTransfer(
+			dev.Interface.BulkIn.EndpointAddress,
+			buf,
+			len(buf),
+			poll,
+		)
+		if n == 0 && usbif.IsTimeout(err) {
+			waited += poll
+			if dev.ResponseTimeout == 0 || waited < dev.ResponseTimeout {
+				continue
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("fastboot: reading response to %s: %w", cmd, err)
+		}
+		return strings.TrimRight(string(buf[:n]), "\x00"), nil
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package fastboot
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	testCases := []struct {
+		name string
+		run  func(dev *Device) (string, error)
+		want string
+		sent string
+	}{
+		{
+			"getvar",
+			func(dev *Device) (string, error) { return dev.GetVar("product") },
+			"sunfish",
+			"getvar:product",
+		},
+		{
+			"erase",
+			func(dev *Device) (string, error) { return "", dev.Erase("userdata") },
+			"",
+			"erase:userdata",
+		},
+		{
+			"reboot",
+			func(dev *Device) (string, error) { return "", dev.Reboot("") },
+			"",
+			"reboot",
+		},
+		{
+			"reboot bootloader",
+			func(dev *Device) (string, error) { return "", dev.Reboot("bootloader") },
+			"",
+			"reboot-bootloader",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := newFakeHandle()
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			got, err := tc.run(dev)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if !slices.Equal(fh.commands, []string{tc.sent}) {
+				t.Errorf("sent %q, want %q", fh.commands, tc.sent)
+			}
+		})
+	}
+}
+
+func TestCommandFail(t *testing.T) {
+	dev := openTestDevice(t, newFakeHandle())
+	defer dev.Close()
+	_, err := dev.GetVar("nonexistent")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("got %v, want a *CommandError", err)
+	}
+	want := CommandError{Command: "getvar:nonexistent", Message: "GetVar Variable Not found"}
+	if *cmdErr != want {
+		t.Errorf("error = %+v, want %+v", *cmdErr, want)
+	}
+	if _, err := dev.Command(strings.Repeat("x", 65)); err == nil {
+		t.Error("65 byte command: expected error, got nil")
+	}
+}
+
+func TestOEMInfo(t *testing.T) {
+	dev := openTestDevice(t, newFakeHandle())
+	defer dev.Close()
+	var info []string
+	dev.Info = func(text string) { info = append(info, text) }
+	if _, err := dev.OEM("device-info"); err != nil {
+		t.Fatalf("OEM: unexpected error %v", err)
+	}
+	want := []string{"Device unlocked: true", "Device critical unlocked: false"}
+	if !slices.Equal(info, want) {
+		t.Errorf("INFO = %q, want %q", info, want)
+	}
+}
+
+func TestFlash(t *testing.T) {
+	fh := newFakeHandle()
+	fh.vars["max-download-size"] = "0x400000"
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	var info []string
+	dev.Info = func(text string) { info = append(info, text) }
+	var progress []int
+	image := bytes.Repeat([]byte{0xA5}, downloadChunk+100)
+	err := dev.Flash("boot_a", image, func(done, total int) {
+		if total != len(image) {
+			t.Errorf("progress total = %d, want %d", total, len(image))
+		}
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatalf("Flash: unexpected error %v", err)
+	}
+	if !bytes.Equal(fh.partitions["boot_a"], image) {
+		t.Errorf("flashed %d bytes, want %d", len(fh.partitions["boot_a"]), len(image))
+	}
+	if want := []int{downloadChunk, len(image)}; !slices.Equal(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+	if fh.transfers != 2 {
+		t.Errorf("download took %d transfers, want 2", fh.transfers)
+	}
+	want := []string{"getvar:max-download-size", "download:00100064", "flash:boot_a"}
+	if !slices.Equal(fh.commands, want) {
+		t.Errorf("sent %q, want %q", fh.commands, want)
+	}
+	if len(info) != 1 || info[0] != "writing 'boot_a'..." {
+		t.Errorf("INFO = %q", info)
+	}
+
+	// max-download-size is read once.
+	fh.commands = nil
+	if err := dev.Download([]byte("data"), nil); err != nil {
+		t.Fatalf("Download: unexpected error %v", err)
+	}
+	if want := []string{"download:00000004"}; !slices.Equal(fh.commands, want) {
+		t.Errorf("sent %q, want %q", fh.commands, want)
+	}
+}
+
+func TestDownloadErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		setup    func(fh *fakeHandle)
+		size     int
+		commands []string
+	}{
+		{
+			"larger than max-download-size",
+			func(*fakeHandle) {},
+			0x10001,
+			[]string{"getvar:max-download-size"},
+		},
+		{
+			"device takes less",
+			func(fh *fakeHandle) { fh.dataSize = 16 },
+			32,
+			[]string{"getvar:max-download-size", "download:00000020"},
+		},
+		{
+			"malformed max-download-size",
+			func(fh *fakeHandle) { fh.vars["max-download-size"] = "lots" },
+			32,
+			[]string{"getvar:max-download-size"},
+		},
+		{"empty", func(*fakeHandle) {}, 0, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := newFakeHandle()
+			tc.setup(fh)
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			if err := dev.Download(make([]byte, tc.size), nil); err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !slices.Equal(fh.commands, tc.commands) {
+				t.Errorf("sent %q, want %q", fh.commands, tc.commands)
+			}
+		})
+	}
+}
+
+func TestDownloadWithoutMaxDownloadSize(t *testing.T) {
+	fh := newFakeHandle()
+	delete(fh.vars, "max-download-size")
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	if err := dev.Download(make([]byte, 0x20000), nil); err != nil {
+		t.Fatalf("Download: unexpected error %v", err)
+	}
+	if len(fh.download) != 0x20000 {
+		t.Errorf("downloaded %d bytes, want %d", len(fh.download), 0x20000)
+	}
+}
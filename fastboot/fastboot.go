@@ -0,0 +1,170 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package fastboot implements the host side of the fastboot protocol on top
+of libusb, for provisioning Android devices in their bootloader.
+
+A fastboot interface is a vendor-specific interface with subclass 0x42 and
+protocol 3, whose bulk endpoint pair carries the protocol. FindInterfaces
+returns such interfaces and Open claims one.
+
+The host sends a command as a short ASCII string, and the device answers
+with one or more responses, each a four letter type followed by text:
+INFO responses carry messages for the user and are passed to Device.Info
+as they arrive, and the command ends with OKAY, FAIL, or, for a download,
+DATA and the number of bytes the device takes. A FAIL is returned as a
+*CommandError.
+
+GetVar, Erase, Reboot and OEM send single commands. Download sends data to
+the device's download buffer, checked first against the device's
+max-download-size, and Flash downloads an image and writes it to a
+partition. Both report their progress through a ProgressFunc.
+*/
+package fastboot
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is how long in milliseconds sending a command, or one
+// chunk of a download, may take unless Device.Timeout is changed. Waiting
+// for the response is bounded by ResponseTimeout instead.
+const DefaultTimeout = 5000
+
+// Interface subclass and protocol of fastboot interfaces.
+const (
+	SubclassFastboot = 0x42
+	ProtocolFastboot = 0x03
+)
+
+// readPoll is the timeout in milliseconds of each transfer made while
+// waiting for a response, and so the longest Close waits for a command to
+// return.
+const readPoll = 250
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: claiming the
+// fastboot interface and sending commands and data over its bulk
+// endpoints.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+}
+
+// Interface is the fastboot interface of a device and its endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	BulkIn     *libusb.EndpointDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+}
+
+// FindInterfaces returns the fastboot interfaces in a configuration.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("fastboot: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassVendorSpec,
+	) {
+		if desc.InterfaceSubClass != SubclassFastboot ||
+			desc.InterfaceProtocol != ProtocolFastboot || desc.AlternateSetting != 0 {
+			continue
+		}
+		iface := &Interface{Descriptor: desc}
+		for _, ep := range desc.EndpointDescriptors {
+			if ep.TransferType() != libusb.BulkTransfer {
+				continue
+			}
+			if ep.Direction() == libusb.EndpointIn && iface.BulkIn == nil {
+				iface.BulkIn = ep
+			} else if ep.Direction() == libusb.EndpointOut && iface.BulkOut == nil {
+				iface.BulkOut = ep
+			}
+		}
+		if iface.BulkIn == nil || iface.BulkOut == nil {
+			return nil, fmt.Errorf(
+				"fastboot: interface %d has no bulk endpoint pair",
+				desc.InterfaceNumber,
+			)
+		}
+		found = append(found, iface)
+	}
+	return found, nil
+}
+
+// Device is a claimed fastboot interface. Its methods may be called
+// concurrently; commands run one at a time.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds.
+	Timeout int
+	// ResponseTimeout is how long in milliseconds the device has to
+	// answer a command, counted afresh after each INFO. Zero, the
+	// default, waits forever, as erasing or flashing a large partition
+	// may need; Close stops the wait.
+	ResponseTimeout int
+	// Info, if not nil, is called with the text of each INFO response as
+	// it arrives.
+	Info func(text string)
+
+	cmdMu sync.Mutex
+	// maxDownload is the device's max-download-size, read by the first
+	// Download; -1 if the device doesn't report it.
+	maxDownload int
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims a fastboot interface of a device in its bootloader. Open
+// sends nothing, so the device stays in fastboot until a command such as
+// "continue" or "reboot" moves it on.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("fastboot: nil handle or interface")
+	}
+	dev := &Device{
+		handle:    handle,
+		Interface: iface,
+		Timeout:   DefaultTimeout,
+	}
+	claims, err := usbif.Claim(handle, "fastboot", iface.Descriptor.InterfaceNumber)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	return dev, nil
+}
+
+// Close stops a command that is waiting for its response and releases the
+// interface. The bootloader may still finish that command, such as a
+// flash, on its own. Calling Close again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	dev.mu.Unlock()
+	// A command waiting on a response sees closed within one poll.
+	dev.cmdMu.Lock()
+	dev.cmdMu.Unlock()
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package fastboot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+// fakeHandle is a bootloader: it answers the commands the host writes,
+// queuing its responses for the host to read.
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+
+	// vars are the variables getvar reports.
+	vars map[string]string
+	// dataSize, if not zero, is the size the device answers a download
+	// with instead of the size asked for.
+	dataSize int
+	// remaining is the number of bytes of a download still to come, and
+	// download the data of the last download.
+	remaining int
+	download  []byte
+	// transfers counts the data transfers of downloads.
+	transfers int
+	// partitions are the flashed images.
+	partitions map[string][]byte
+	// commands collects the commands the host sent, and replies the
+	// responses the host is yet to read.
+	commands []string
+	replies  []string
+}
+
+func newFakeHandle() *fakeHandle {
+	return &fakeHandle{
+		vars: map[string]string{
+			"product":           "sunfish",
+			"max-download-size": "0x10000",
+		},
+		partitions: make(map[string][]byte),
+	}
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		if fh.remaining > 0 {
+			fh.transfers++
+			fh.download = append(fh.download, data[:length]...)
+			fh.remaining -= length
+			if fh.remaining <= 0 {
+				fh.replies = append(fh.replies, "OKAY")
+			}
+			return length, nil
+		}
+		fh.handle(string(data[:length]))
+		return length, nil
+	}
+	if len(fh.replies) == 0 {
+		fh.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		fh.mu.Lock()
+		return 0, libusb.ErrTimeout
+	}
+	resp := fh.replies[0]
+	fh.replies = fh.replies[1:]
+	return copy(data[:length], resp), nil
+}
+
+func (fh *fakeHandle) handle(cmd string) {
+	fh.commands = append(fh.commands, cmd)
+	reply := func(resps ...string) {
+		fh.replies = append(fh.replies, resps...)
+	}
+	verb, arg, _ := strings.Cut(cmd, ":")
+	switch {
+	case verb == "getvar":
+		if value, ok := fh.vars[arg]; ok {
+			reply("OKAY" + value)
+		} else {
+			reply("FAILGetVar Variable Not found")
+		}
+	case verb == "download":
+		size, _ := strconv.ParseUint(arg, 16, 32)
+		if fh.dataSize != 0 {
+			size = uint64(fh.dataSize)
+		}
+		fh.remaining, fh.download = int(size), nil
+		reply(fmt.Sprintf("DATA%08x", size))
+	case verb == "flash":
+		fh.partitions[arg] = fh.download
+		reply("INFOwriting '"+arg+"'...", "OKAY")
+	case verb == "erase":
+		delete(fh.partitions, arg)
+		reply("OKAY")
+	case cmd == "reboot" || cmd == "reboot-bootloader":
+		reply("OKAY")
+	case cmd == "oem device-info":
+		reply("INFODevice unlocked: true", "INFODevice critical unlocked: false", "OKAY")
+	case cmd == "oem hang":
+	default:
+		reply("FAILunknown command")
+	}
+}
+
+// testConfig returns a configuration with the ADB interface 0, and the
+// fastboot interface 1 with the bulk endpoints 0x81 and 0x01.
+func testConfig() *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: 0x42,
+				InterfaceProtocol: 0x01,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:   1,
+				InterfaceClass:    libusb.InterfaceClassVendorSpec,
+				InterfaceSubClass: SubclassFastboot,
+				InterfaceProtocol: ProtocolFastboot,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 512},
+					{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 512},
+				},
+			}}},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	dev.ResponseTimeout = 1000
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.Descriptor.InterfaceNumber != 1 || iface.BulkIn.EndpointAddress != 0x81 ||
+		iface.BulkOut.EndpointAddress != 0x01 {
+		t.Errorf("interface = %+v", iface)
+	}
+
+	config := testConfig()
+	config.SupportedInterfaces[1].InterfaceDescriptors[0].EndpointDescriptors =
+		config.SupportedInterfaces[1].InterfaceDescriptors[0].EndpointDescriptors[1:]
+	if _, err := FindInterfaces(config); err == nil {
+		t.Error("interface without bulk IN endpoint: expected error, got nil")
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := newFakeHandle()
+	fh.Active = true
+	dev := openTestDevice(t, fh)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 1", "claim 1", "release 1", "attach 1"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.GetVar("product"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("GetVar after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestCloseStopsCommand(t *testing.T) {
+	dev := openTestDevice(t, newFakeHandle())
+	dev.ResponseTimeout = 0
+	done := make(chan error, 1)
+	go func() {
+		_, err := dev.OEM("hang")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrClosed) {
+			t.Errorf("OEM: got %v, want os.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OEM didn't return after Close")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ftdi
+
+import "fmt"
+
+// Baud rate generator clocks.
+const (
+	clock48MHz  = 48000000
+	clock120MHz = 120000000
+)
+
+// highSpeedDivisor selects the 120 MHz clock divided by 10 on the H series.
+const highSpeedDivisor = 0x20000
+
+// maxBaudError is the largest deviation from the requested baud rate, in
+// percent, that SetBaudRate accepts.
+const maxBaudError = 5
+
+// fracCode maps the eighths of a divisor to the chip's sub-integer divisor
+// encoding.
+var fracCode = [8]int{0, 3, 2, 4, 1, 5, 6, 7}
+
+// The AM series only supports divisor fractions of 0, 1/8, 1/4, and 1/2, so
+// other fractions are rounded to one of those.
+var (
+	amAdjustUp   = [8]int{0, 0, 0, 1, 0, 3, 2, 1}
+	amAdjustDown = [8]int{0, 0, 0, 1, 0, 1, 2, 3}
+)
+
+// BaudDivisor returns the encoded divisor for a baud rate on a chip, split
+// into the wValue and the high bits of the wIndex of SET_BAUDRATE, along
+// with the baud rate the chip will actually produce. The algorithm follows
+// FTDI application note AN232B-05 and libftdi.
+func BaudDivisor(chip Chip, baudRate int) (value uint16, index uint16, actual int, err error) {
+	if baudRate <= 0 {
+		return 0, 0, 0, fmt.Errorf("ftdi: baud rate %d must be positive", baudRate)
+	}
+	var encoded int
+	switch {
+	case chip.HighSpeed() && baudRate*10 > clock120MHz/0x3FFF:
+		actual, encoded = clockBits(baudRate, clock120MHz, 10)
+		encoded |= highSpeedDivisor
+	case chip == ChipAM:
+		actual, encoded = clockBitsAM(baudRate)
+	default:
+		actual, encoded = clockBits(baudRate, clock48MHz, 16)
+	}
+	value = uint16(encoded)
+	if chip.shiftsDivisorIndex() {
+		index = uint16(encoded>>8) & 0xFF00
+	} else {
+		index = uint16(encoded >> 16)
+	}
+	return value, index, actual, nil
+}
+
+// clockBits computes the divisor for the BM and later series, which support
+// all eighths of a divisor.
+func clockBits(baudRate, clock, clockDivider int) (actual int, encoded int) {
+	switch {
+	case baudRate >= clock/clockDivider:
+		return clock / clockDivider, 0
+	case baudRate >= clock/(clockDivider+clockDivider/2):
+		return clock / (clockDivider + clockDivider/2), 1
+	case baudRate >= clock/(2*clockDivider):
+		return clock / (2 * clockDivider), 2
+	}
+	// Dividing by clockDivider/16 leaves three fractional bits and one bit
+	// for rounding.
+	divisor := clock * 16 / clockDivider / baudRate
+	best := divisor/2 + divisor&1
+	if best > 0x20000 {
+		best = 0x1FFFF
+	}
+	actual = clock * 16 / clockDivider / best
+	actual = actual/2 + actual&1
+	return actual, best>>3 | fracCode[best&7]<<14
+}
+
+// clockBitsAM computes the divisor for the AM series, trying the rounded
+// down divisor and the one above it and keeping the closer of the two.
+func clockBitsAM(baudRate int) (actual int, encoded int) {
+	divisor := 24000000 / baudRate
+	divisor -= amAdjustDown[divisor&7]
+	var best, bestDiff int
+	for i := 0; i < 2; i++ {
+		try := divisor + i
+		switch {
+		case try <= 8:
+			try = 8
+		case divisor < 16:
+			// Divisors 9 through 15 aren't supported.
+			try = 16
+		default:
+			try += amAdjustUp[try&7]
+			try = min(try, 0x1FFF8)
+		}
+		estimate := (24000000 + try/2) / try
+		diff := estimate - baudRate
+		if diff < 0 {
+			diff = -diff
+		}
+		if i == 0 || diff < bestDiff {
+			best, actual, bestDiff = try, estimate, diff
+			if diff == 0 {
+				break
+			}
+		}
+	}
+	encoded = best>>3 | fracCode[best&7]<<14
+	switch encoded {
+	case 1:
+		// 3,000,000 baud.
+		encoded = 0
+	case 0x4001:
+		// 2,000,000 baud, which only the BM series reaches.
+		encoded = 1
+	}
+	return actual, encoded
+}
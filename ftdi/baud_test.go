@@ -0,0 +1,50 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ftdi
+
+import "testing"
+
+func TestBaudDivisor(t *testing.T) {
+	// Expected divisors are the values in FTDI AN232B-05 and those libftdi
+	// sends for the same chip and rate.
+	testCases := []struct {
+		chip   Chip
+		baud   int
+		value  uint16
+		index  uint16
+		actual int
+	}{
+		{ChipBM, 300, 0x2710, 0x0000, 300},
+		{ChipBM, 9600, 0x4138, 0x0000, 9600},
+		{ChipR, 115200, 0x001A, 0x0000, 115385},
+		{ChipR, 1500000, 0x0002, 0x0000, 1500000},
+		{ChipR, 2000000, 0x0001, 0x0000, 2000000},
+		{ChipR, 3000000, 0x0000, 0x0000, 3000000},
+		{ChipR, 100, 0xFFFF, 0x0001, 183},
+		{ChipAM, 9600, 0x4138, 0x0000, 9600},
+		{ChipAM, 3000000, 0x0000, 0x0000, 3000000},
+		{Chip2232C, 300, 0x2710, 0x0000, 300},
+		{Chip232H, 12000000, 0x0000, 0x0200, 12000000},
+		{Chip2232H, 115200, 0xC068, 0x0200, 115246},
+		{Chip2232H, 300, 0x2710, 0x0000, 300},
+	}
+	for _, tc := range testCases {
+		value, index, actual, err := BaudDivisor(tc.chip, tc.baud)
+		if err != nil {
+			t.Errorf("%s %d: unexpected error %v", tc.chip, tc.baud, err)
+			continue
+		}
+		if value != tc.value || index != tc.index || actual != tc.actual {
+			t.Errorf(
+				"%s %d baud = %#04x/%#04x (%d), want %#04x/%#04x (%d)",
+				tc.chip, tc.baud, value, index, actual, tc.value, tc.index, tc.actual,
+			)
+		}
+	}
+	if _, _, _, err := BaudDivisor(ChipR, 0); err == nil {
+		t.Error("BaudDivisor(0): expected error, got nil")
+	}
+}
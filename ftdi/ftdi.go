@@ -0,0 +1,780 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package ftdi drives FTDI USB UART and MPSSE chips, such as the FT232R and
+FT2232H, on top of libusb using FTDI's vendor control requests.
+
+Each port of a chip is a separate vendor-specific interface with a bulk IN and
+bulk OUT endpoint. Every packet the chip sends on the bulk IN endpoint starts
+with two status bytes, which Read strips and records, so the data read from
+a Port is only what the serial line or MPSSE engine produced.
+*/
+package ftdi
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Port.Timeout that Open sets, in milliseconds. Read
+// spends it across however many transfers of status packets pass before
+// data arrives.
+const DefaultTimeout = 1000
+
+// VendorID is FTDI's USB vendor ID.
+const VendorID = 0x0403
+
+// FTDI vendor requests.
+const (
+	requestReset           = 0x00
+	requestSetModemCtrl    = 0x01
+	requestSetFlowCtrl     = 0x02
+	requestSetBaudRate     = 0x03
+	requestSetData         = 0x04
+	requestPollModemStatus = 0x05
+	requestSetEventChar    = 0x06
+	requestSetErrorChar    = 0x07
+	requestSetLatencyTimer = 0x09
+	requestGetLatencyTimer = 0x0A
+	requestSetBitMode      = 0x0B
+	requestReadPins        = 0x0C
+)
+
+// Values of the reset request.
+const (
+	resetSIO     = 0
+	resetPurgeRX = 1
+	resetPurgeTX = 2
+)
+
+// Values of the modem control request. The high byte selects which outputs
+// the low byte changes.
+const (
+	modemCtrlDTR     = 0x0101
+	modemCtrlRTS     = 0x0202
+	modemCtrlDTRMask = 0x0100
+	modemCtrlRTSMask = 0x0200
+)
+
+// setDataBreak is the break bit of the SET_DATA request.
+const setDataBreak = 0x4000
+
+// Standard software flow control characters.
+const (
+	xon  = 0x11
+	xoff = 0x13
+)
+
+// statusHeaderSize is the number of status bytes at the start of each bulk
+// IN packet.
+const statusHeaderSize = 2
+
+// Chip identifies an FTDI chip family, which determines how baud rates are
+// encoded.
+type Chip int
+
+// FTDI chip families.
+const (
+	ChipAM Chip = iota
+	ChipBM
+	Chip2232C
+	ChipR
+	Chip2232H
+	Chip4232H
+	Chip232H
+	Chip230X
+)
+
+var chips = map[Chip]string{
+	ChipAM:    "FT8U232AM",
+	ChipBM:    "FT232BM",
+	Chip2232C: "FT2232C",
+	ChipR:     "FT232R",
+	Chip2232H: "FT2232H",
+	Chip4232H: "FT4232H",
+	Chip232H:  "FT232H",
+	Chip230X:  "FT-X",
+}
+
+// String implements the Stringer interface for Chip.
+func (chip Chip) String() string {
+	return chips[chip]
+}
+
+// HighSpeed reports whether the chip is one of the high-speed H series,
+// which have a 120 MHz baud rate clock and MPSSE engines.
+func (chip Chip) HighSpeed() bool {
+	return chip == Chip2232H || chip == Chip4232H || chip == Chip232H
+}
+
+// MultiPort reports whether the chip has more than one port.
+func (chip Chip) MultiPort() bool {
+	return chip == Chip2232C || chip == Chip2232H || chip == Chip4232H
+}
+
+// shiftsDivisorIndex reports whether the chip expects the high bits of the
+// baud rate divisor in the high byte of wIndex, leaving the low byte for
+// the port.
+func (chip Chip) shiftsDivisorIndex() bool {
+	return chip.HighSpeed() || chip == Chip2232C || chip == Chip230X
+}
+
+// DetectChip identifies the chip family from the bcdDevice field of the
+// device descriptor. Early BM chips report the AM release number but have
+// no serial number string.
+func DetectChip(desc *libusb.Descriptor) (Chip, error) {
+	if desc == nil {
+		return 0, fmt.Errorf("ftdi: nil device descriptor")
+	}
+	switch uint16(desc.DeviceReleaseNumber) {
+	case 0x0200:
+		if desc.SerialNumberIndex == 0 {
+			return ChipBM, nil
+		}
+		return ChipAM, nil
+	case 0x0400:
+		return ChipBM, nil
+	case 0x0500:
+		return Chip2232C, nil
+	case 0x0600:
+		return ChipR, nil
+	case 0x0700:
+		return Chip2232H, nil
+	case 0x0800:
+		return Chip4232H, nil
+	case 0x0900:
+		return Chip232H, nil
+	case 0x1000:
+		return Chip230X, nil
+	}
+	return 0, fmt.Errorf("ftdi: unknown chip release %#04x", uint16(desc.DeviceReleaseNumber))
+}
+
+// DataBits is the number of data bits per character.
+type DataBits byte
+
+// Data bit settings.
+const (
+	SevenDataBits DataBits = 7
+	EightDataBits DataBits = 8
+)
+
+// StopBits is the number of stop bits per character.
+type StopBits byte
+
+// Stop bit settings.
+const (
+	OneStopBit           StopBits = 0
+	OnePointFiveStopBits StopBits = 1
+	TwoStopBits          StopBits = 2
+)
+
+var stopBits = map[StopBits]string{
+	OneStopBit:           "1",
+	OnePointFiveStopBits: "1.5",
+	TwoStopBits:          "2",
+}
+
+// String implements the Stringer interface for StopBits.
+func (bits StopBits) String() string {
+	return stopBits[bits]
+}
+
+// Parity is the parity bit setting.
+type Parity byte
+
+// Parity settings.
+const (
+	ParityNone  Parity = 0
+	ParityOdd   Parity = 1
+	ParityEven  Parity = 2
+	ParityMark  Parity = 3
+	ParitySpace Parity = 4
+)
+
+var parities = map[Parity]string{
+	ParityNone:  "None",
+	ParityOdd:   "Odd",
+	ParityEven:  "Even",
+	ParityMark:  "Mark",
+	ParitySpace: "Space",
+}
+
+// String implements the Stringer interface for Parity.
+func (parity Parity) String() string {
+	return parities[parity]
+}
+
+// FlowControl selects the handshake the chip uses.
+type FlowControl uint16
+
+// Flow control settings, as placed in the high byte of wIndex.
+const (
+	FlowNone    FlowControl = 0x0000
+	FlowRTSCTS  FlowControl = 0x0100
+	FlowDTRDSR  FlowControl = 0x0200
+	FlowXonXoff FlowControl = 0x0400
+)
+
+var flowControls = map[FlowControl]string{
+	FlowNone:    "None",
+	FlowRTSCTS:  "RTS/CTS",
+	FlowDTRDSR:  "DTR/DSR",
+	FlowXonXoff: "XON/XOFF",
+}
+
+// String implements the Stringer interface for FlowControl.
+func (flow FlowControl) String() string {
+	return flowControls[flow]
+}
+
+// BitMode selects between the UART and the chip's bit-bang and MPSSE modes.
+type BitMode byte
+
+// Bit modes. Which ones a chip supports depends on its family.
+const (
+	BitModeReset       BitMode = 0x00
+	BitModeBitBang     BitMode = 0x01
+	BitModeMPSSE       BitMode = 0x02
+	BitModeSyncBitBang BitMode = 0x04
+	BitModeMCU         BitMode = 0x08
+	BitModeOpto        BitMode = 0x10
+	BitModeCBUS        BitMode = 0x20
+	BitModeSyncFIFO    BitMode = 0x40
+	BitModeFT1284      BitMode = 0x80
+)
+
+var bitModes = map[BitMode]string{
+	BitModeReset:       "Reset",
+	BitModeBitBang:     "Asynchronous Bit-Bang",
+	BitModeMPSSE:       "MPSSE",
+	BitModeSyncBitBang: "Synchronous Bit-Bang",
+	BitModeMCU:         "MCU Host Bus Emulation",
+	BitModeOpto:        "Fast Opto-Isolated Serial",
+	BitModeCBUS:        "CBUS Bit-Bang",
+	BitModeSyncFIFO:    "Synchronous FIFO",
+	BitModeFT1284:      "FT1284",
+}
+
+// String implements the Stringer interface for BitMode.
+func (mode BitMode) String() string {
+	return bitModes[mode]
+}
+
+// ModemStatus is the first status byte of each bulk IN packet and of
+// POLL_MODEM_STATUS.
+type ModemStatus byte
+
+// CTS reports the Clear To Send input.
+func (status ModemStatus) CTS() bool { return status&0x10 != 0 }
+
+// DSR reports the Data Set Ready input.
+func (status ModemStatus) DSR() bool { return status&0x20 != 0 }
+
+// RI reports the Ring Indicator input.
+func (status ModemStatus) RI() bool { return status&0x40 != 0 }
+
+// DCD reports the Data Carrier Detect input.
+func (status ModemStatus) DCD() bool { return status&0x80 != 0 }
+
+// LineStatus is the second status byte of each bulk IN packet and of
+// POLL_MODEM_STATUS.
+type LineStatus byte
+
+// Overrun reports that received data was lost.
+func (status LineStatus) Overrun() bool { return status&0x02 != 0 }
+
+// ParityError reports a parity error.
+func (status LineStatus) ParityError() bool { return status&0x04 != 0 }
+
+// FramingError reports a framing error.
+func (status LineStatus) FramingError() bool { return status&0x08 != 0 }
+
+// Break reports a break condition.
+func (status LineStatus) Break() bool { return status&0x10 != 0 }
+
+// TransmitterEmpty reports that the transmit shift register and holding
+// register are empty.
+func (status LineStatus) TransmitterEmpty() bool { return status&0x40 != 0 }
+
+// Errors reports whether any of the overrun, parity, framing, or break bits
+// is set.
+func (status LineStatus) Errors() bool { return status&0x1E != 0 }
+
+// Handle is what a Port needs of a *libusb.DeviceHandle: claiming the
+// port's interface, FTDI's vendor requests, and bulk transfers.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+}
+
+// Port is an opened port of an FTDI chip. Read and Write may be called
+// concurrently with each other, but not with themselves.
+type Port struct {
+	handle      Handle
+	Chip        Chip
+	Interface   int
+	InEndpoint  libusb.EndpointAddress
+	OutEndpoint libusb.EndpointAddress
+	// PacketSize is the bulk IN packet size, each packet of which starts
+	// with the two status bytes.
+	PacketSize int
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	// channel is the port's wIndex for vendor requests: 1 for port A, 2 for
+	// port B, and so on, or 0 on single-port chips.
+	channel uint16
+
+	readMu     sync.Mutex
+	readBuf    []byte
+	pending    []byte
+	modem      ModemStatus
+	line       LineStatus
+	lineErrors LineStatus
+
+	mu        sync.Mutex
+	closed    bool
+	claims    *usbif.Claims
+	bitMode   BitMode
+	lineProps uint16
+}
+
+// Open claims a port's interface, detaching the ftdi_sio kernel driver if
+// it is bound. The chip family comes from DetectChip.
+func Open(handle Handle, chip Chip, iface *libusb.InterfaceDescriptor) (*Port, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("ftdi: nil handle or interface descriptor")
+	}
+	port := &Port{
+		handle:    handle,
+		Chip:      chip,
+		Interface: iface.InterfaceNumber,
+		Timeout:   DefaultTimeout,
+		lineProps: uint16(EightDataBits),
+	}
+	if chip.MultiPort() {
+		port.channel = uint16(iface.InterfaceNumber + 1)
+	}
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.BulkTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && port.InEndpoint == 0 {
+			port.InEndpoint = ep.EndpointAddress
+			port.PacketSize = int(ep.MaxPacketSize)
+		} else if ep.Direction() == libusb.EndpointOut && port.OutEndpoint == 0 {
+			port.OutEndpoint = ep.EndpointAddress
+		}
+	}
+	if port.InEndpoint == 0 || port.OutEndpoint == 0 {
+		return nil, fmt.Errorf("ftdi: interface %d has no bulk endpoint pair", port.Interface)
+	}
+	if port.PacketSize <= statusHeaderSize {
+		return nil, fmt.Errorf("ftdi: bulk IN packet size %d is too small", port.PacketSize)
+	}
+	// A transfer that times out returns nothing of what it received, so
+	// Read asks for a single packet at a time and never has a whole packet
+	// to lose when the deadline cuts a transfer short.
+	port.readBuf = make([]byte, port.PacketSize)
+
+	claims, err := usbif.Claim(handle, "ftdi", port.Interface)
+	if err != nil {
+		return nil, err
+	}
+	port.claims = claims
+	return port, nil
+}
+
+// Close gives the port's interface back, to ftdi_sio if Open took it from
+// that driver. The chip keeps its line settings and bit mode. Calling Close
+// again returns os.ErrClosed.
+func (port *Port) Close() error {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if port.closed {
+		return os.ErrClosed
+	}
+	port.closed = true
+	return port.claims.Release()
+}
+
+func (port *Port) isClosed() bool {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.closed
+}
+
+// Read reads data from the bulk IN endpoint a packet at a time, stripping
+// the status bytes from the start of each. The chip sends a status-only packet each
+// latency timer period while idle, so Read keeps reading until data arrives
+// or Timeout passes, in which case it returns os.ErrDeadlineExceeded. Each
+// transfer waits only for what is left of Timeout.
+func (port *Port) Read(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	port.readMu.Lock()
+	defer port.readMu.Unlock()
+	var deadline time.Time
+	if port.Timeout > 0 {
+		deadline = time.Now().Add(time.Duration(port.Timeout) * time.Millisecond)
+	}
+	for len(port.pending) == 0 {
+		timeout := port.Timeout
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			// Round up so that less than a millisecond left isn't zero,
+			// which would wait forever.
+			timeout = int((remaining + time.Millisecond - 1) / time.Millisecond)
+		}
+		n, err := port.handle.BulkTransfer(
+			port.InEndpoint,
+			port.readBuf,
+			len(port.readBuf),
+			timeout,
+		)
+		if usbif.IsTimeout(err) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		if err != nil {
+			return 0, err
+		}
+		port.pending = port.stripStatus(port.readBuf[:n])
+	}
+	n := copy(p, port.pending)
+	port.pending = port.pending[n:]
+	return n, nil
+}
+
+// stripStatus removes the status bytes from each packet of a transfer in
+// place, recording the latest status, and returns the remaining data.
+func (port *Port) stripStatus(transfer []byte) []byte {
+	data := transfer[:0]
+	for len(transfer) > 0 {
+		packet := transfer[:min(len(transfer), port.PacketSize)]
+		transfer = transfer[len(packet):]
+		if len(packet) < statusHeaderSize {
+			continue
+		}
+		port.modem = ModemStatus(packet[0] & 0xF0)
+		port.line = LineStatus(packet[1])
+		port.lineErrors |= port.line & 0x1E
+		data = append(data, packet[statusHeaderSize:]...)
+	}
+	return data
+}
+
+// Status returns the modem and line status from the most recent bulk IN
+// packet, along with the line errors seen since the last call to Status.
+func (port *Port) Status() (ModemStatus, LineStatus, LineStatus) {
+	port.readMu.Lock()
+	defer port.readMu.Unlock()
+	errs := port.lineErrors
+	port.lineErrors = 0
+	return port.modem, port.line, errs
+}
+
+// Write writes p to the bulk OUT endpoint.
+func (port *Port) Write(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return usbif.Write(port.handle, port.OutEndpoint, p, port.Timeout)
+}
+
+// Reset issues the SIO reset request, which clears the chip's buffers and
+// returns the port to its power-on line settings.
+func (port *Port) Reset() error {
+	return port.vendorOut(requestReset, resetSIO, port.channel)
+}
+
+// PurgeBuffers discards data in the chip's receive and transmit buffers,
+// along with any received data not yet returned by Read.
+func (port *Port) PurgeBuffers(rx, tx bool) error {
+	if rx {
+		if err := port.vendorOut(requestReset, resetPurgeRX, port.channel); err != nil {
+			return err
+		}
+		port.readMu.Lock()
+		port.pending = nil
+		port.readMu.Unlock()
+	}
+	if tx {
+		return port.vendorOut(requestReset, resetPurgeTX, port.channel)
+	}
+	return nil
+}
+
+// SetBaudRate programs the baud rate divisor and returns the rate the chip
+// actually produces. Rates the chip can't produce within 5% are rejected.
+// In bit-bang modes the pins update at a multiple of the baud rate, which
+// is accounted for as libftdi does.
+func (port *Port) SetBaudRate(baudRate int) (int, error) {
+	port.mu.Lock()
+	bitBang := port.bitMode != BitModeReset
+	port.mu.Unlock()
+	requested := baudRate
+	if bitBang {
+		requested *= 4
+	}
+	value, index, actual, err := BaudDivisor(port.Chip, requested)
+	if err != nil {
+		return 0, err
+	}
+	diff := actual - requested
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff*100 > requested*maxBaudError {
+		return 0, fmt.Errorf(
+			"ftdi: %s can't produce %d baud; the nearest rate is %d",
+			port.Chip,
+			baudRate,
+			actual,
+		)
+	}
+	if port.Chip.shiftsDivisorIndex() {
+		index |= port.channel
+	}
+	if err := port.vendorOut(requestSetBaudRate, value, index); err != nil {
+		return 0, err
+	}
+	if bitBang {
+		actual /= 4
+	}
+	return actual, nil
+}
+
+// SetLineProperties sets the character format.
+func (port *Port) SetLineProperties(dataBits DataBits, stopBits StopBits, parity Parity) error {
+	if dataBits != SevenDataBits && dataBits != EightDataBits {
+		return fmt.Errorf("ftdi: invalid data bits %d", dataBits)
+	}
+	if stopBits > TwoStopBits {
+		return fmt.Errorf("ftdi: invalid stop bits %d", stopBits)
+	}
+	if parity > ParitySpace {
+		return fmt.Errorf("ftdi: invalid parity %d", parity)
+	}
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	props := uint16(dataBits) | uint16(parity)<<8 | uint16(stopBits)<<11
+	props |= port.lineProps & setDataBreak
+	if err := port.vendorOut(requestSetData, props, port.channel); err != nil {
+		return err
+	}
+	port.lineProps = props
+	return nil
+}
+
+// SetBreak turns the break condition on or off.
+func (port *Port) SetBreak(on bool) error {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	props := port.lineProps &^ setDataBreak
+	if on {
+		props |= setDataBreak
+	}
+	if err := port.vendorOut(requestSetData, props, port.channel); err != nil {
+		return err
+	}
+	port.lineProps = props
+	return nil
+}
+
+// SetFlowControl selects the handshake. For FlowXonXoff the chip uses the
+// standard XON (0x11) and XOFF (0x13) characters.
+func (port *Port) SetFlowControl(flow FlowControl) error {
+	if _, ok := flowControls[flow]; !ok {
+		return fmt.Errorf("ftdi: invalid flow control %#04x", uint16(flow))
+	}
+	var value uint16
+	if flow == FlowXonXoff {
+		value = xon | xoff<<8
+	}
+	return port.vendorOut(requestSetFlowCtrl, value, uint16(flow)|port.channel)
+}
+
+// SetDTR drives the DTR output.
+func (port *Port) SetDTR(dtr bool) error {
+	value := uint16(modemCtrlDTRMask)
+	if dtr {
+		value = modemCtrlDTR
+	}
+	return port.vendorOut(requestSetModemCtrl, value, port.channel)
+}
+
+// SetRTS drives the RTS output.
+func (port *Port) SetRTS(rts bool) error {
+	value := uint16(modemCtrlRTSMask)
+	if rts {
+		value = modemCtrlRTS
+	}
+	return port.vendorOut(requestSetModemCtrl, value, port.channel)
+}
+
+// SetDTRRTS drives both outputs with one request.
+func (port *Port) SetDTRRTS(dtr, rts bool) error {
+	value := uint16(modemCtrlDTRMask | modemCtrlRTSMask)
+	if dtr {
+		value |= modemCtrlDTR
+	}
+	if rts {
+		value |= modemCtrlRTS
+	}
+	return port.vendorOut(requestSetModemCtrl, value, port.channel)
+}
+
+// PollModemStatus asks the chip for its current modem and line status.
+func (port *Port) PollModemStatus() (ModemStatus, LineStatus, error) {
+	data := make([]byte, 2)
+	n, err := port.vendorIn(requestPollModemStatus, 0, data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if n != 2 {
+		return 0, 0, fmt.Errorf("ftdi: POLL_MODEM_STATUS returned %d bytes; want 2", n)
+	}
+	return ModemStatus(data[0] & 0xF0), LineStatus(data[1]), nil
+}
+
+// SetLatencyTimer sets how long in milliseconds the chip waits before
+// sending a partially filled packet, from 1 to 255. Lower values reduce
+// latency for short replies at the cost of more status-only packets.
+func (port *Port) SetLatencyTimer(ms int) error {
+	if ms < 1 || ms > 255 {
+		return fmt.Errorf("ftdi: latency timer %d ms out of range", ms)
+	}
+	return port.vendorOut(requestSetLatencyTimer, uint16(ms), port.channel)
+}
+
+// LatencyTimer reads the latency timer in milliseconds.
+func (port *Port) LatencyTimer() (int, error) {
+	data := make([]byte, 1)
+	n, err := port.vendorIn(requestGetLatencyTimer, 0, data)
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("ftdi: GET_LATENCY_TIMER returned %d bytes; want 1", n)
+	}
+	return int(data[0]), nil
+}
+
+// SetEventChar makes the chip send its buffer as soon as char is received,
+// without waiting for the latency timer.
+func (port *Port) SetEventChar(char byte, enable bool) error {
+	value := uint16(char)
+	if enable {
+		value |= 0x0100
+	}
+	return port.vendorOut(requestSetEventChar, value, port.channel)
+}
+
+// SetErrorChar makes the chip insert char into the data when it detects a
+// parity or framing error.
+func (port *Port) SetErrorChar(char byte, enable bool) error {
+	value := uint16(char)
+	if enable {
+		value |= 0x0100
+	}
+	return port.vendorOut(requestSetErrorChar, value, port.channel)
+}
+
+// SetBitMode switches the port between the UART (BitModeReset) and a
+// bit-bang or MPSSE mode. For the bit-bang modes, mask selects which pins
+// are outputs. MPSSE commands are then sent with Write and their replies
+// read with Read.
+func (port *Port) SetBitMode(mask byte, mode BitMode) error {
+	if mode == BitModeMPSSE && !port.Chip.HighSpeed() && port.Chip != Chip2232C {
+		return fmt.Errorf("ftdi: %s has no MPSSE engine", port.Chip)
+	}
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	value := uint16(mode)<<8 | uint16(mask)
+	if err := port.vendorOut(requestSetBitMode, value, port.channel); err != nil {
+		return err
+	}
+	port.bitMode = mode
+	return nil
+}
+
+// BitMode returns the mode last set with SetBitMode.
+func (port *Port) BitMode() BitMode {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.bitMode
+}
+
+// ReadPins reads the instantaneous state of the data bus pins.
+func (port *Port) ReadPins() (byte, error) {
+	data := make([]byte, 1)
+	n, err := port.vendorIn(requestReadPins, 0, data)
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("ftdi: READ_PINS returned %d bytes; want 1", n)
+	}
+	return data[0], nil
+}
+
+func (port *Port) vendorOut(request byte, value uint16, index uint16) error {
+	_, err := port.handle.ControlOut(
+		libusb.Vendor,
+		libusb.DeviceRecipient,
+		request,
+		value,
+		index,
+		nil,
+		port.Timeout,
+	)
+	return err
+}
+
+func (port *Port) vendorIn(request byte, value uint16, data []byte) (int, error) {
+	return port.handle.ControlIn(
+		libusb.Vendor,
+		libusb.DeviceRecipient,
+		request,
+		value,
+		port.channel,
+		data,
+		len(data),
+		port.Timeout,
+	)
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ftdi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type vendorRequest struct {
+	request byte
+	value   uint16
+	index   uint16
+}
+
+// fakeHandle records vendor requests and serves bulk IN transfers from a
+// queue, returning a status-only packet once the queue is empty as an idle
+// chip does, or idleErr if it is set.
+type fakeHandle struct {
+	usbiftest.Claimer
+	requests    []vendorRequest
+	controlData []byte
+	bulkIn      [][]byte
+	bulkOut     []byte
+	idleErr     error
+	inTimeouts  []int
+	inLengths   []int
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests, vendorRequest{request, value, index})
+	return copy(data[:maxReceiveLength], fh.controlData), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	if reqType != libusb.Vendor || recipient != libusb.DeviceRecipient {
+		return 0, fmt.Errorf("unexpected request type %v to %v", reqType, recipient)
+	}
+	fh.requests = append(fh.requests, vendorRequest{request, value, index})
+	return len(data), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.bulkOut = append(fh.bulkOut, data[:length]...)
+		return length, nil
+	}
+	fh.inTimeouts = append(fh.inTimeouts, timeout)
+	fh.inLengths = append(fh.inLengths, length)
+	if len(fh.bulkIn) == 0 {
+		if fh.idleErr != nil {
+			return 0, fh.idleErr
+		}
+		return copy(data, []byte{0x31, 0x60}), nil
+	}
+	transfer := fh.bulkIn[0]
+	fh.bulkIn = fh.bulkIn[1:]
+	return copy(data[:length], transfer), nil
+}
+
+func portInterface(number int, packetSize int) *libusb.InterfaceDescriptor {
+	return &libusb.InterfaceDescriptor{
+		InterfaceNumber: number,
+		InterfaceClass:  libusb.InterfaceClassVendorSpec,
+		EndpointDescriptors: libusb.EndpointDescriptors{
+			{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: uint16(packetSize)},
+			{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: uint16(packetSize)},
+		},
+	}
+}
+
+func openPort(t *testing.T, fh *fakeHandle, chip Chip, iface int, packetSize int) *Port {
+	t.Helper()
+	port, err := Open(fh, chip, portInterface(iface, packetSize))
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return port
+}
+
+func TestDetectChip(t *testing.T) {
+	testCases := []struct {
+		desc     *libusb.Descriptor
+		expected Chip
+	}{
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0200, SerialNumberIndex: 3}, ChipAM},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0200}, ChipBM},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0400, SerialNumberIndex: 3}, ChipBM},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0500}, Chip2232C},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0600}, ChipR},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0700}, Chip2232H},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0800}, Chip4232H},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x0900}, Chip232H},
+		{&libusb.Descriptor{DeviceReleaseNumber: 0x1000}, Chip230X},
+	}
+	for _, tc := range testCases {
+		chip, err := DetectChip(tc.desc)
+		if err != nil || chip != tc.expected {
+			t.Errorf("DetectChip(%v) = %v, %v; want %v",
+				tc.desc.DeviceReleaseNumber, chip, err, tc.expected)
+		}
+	}
+	if _, err := DetectChip(&libusb.Descriptor{}); err == nil {
+		t.Error("DetectChip with release 0: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	port := openPort(t, fh, Chip2232H, 1, 512)
+	if port.channel != 2 {
+		t.Errorf("channel = %d, want 2 for the second port", port.channel)
+	}
+	if err := port.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 1", "claim 1", "release 1", "attach 1"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if _, err := port.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := Open(fh, ChipR, &libusb.InterfaceDescriptor{}); err == nil {
+		t.Error("Open without endpoints: expected error, got nil")
+	}
+}
+
+func TestReadStripsStatus(t *testing.T) {
+	// A full 8-byte packet and a short one, followed by an idle packet and
+	// then a packet with a parity error. Each transfer carries one packet.
+	fh := &fakeHandle{bulkIn: [][]byte{
+		{0x31, 0x60, 'h', 'e', 'l', 'l', 'o', ' '},
+		{0x31, 0x60, 'w', 'o'},
+		{0x31, 0x60},
+		{0x11, 0x64, 'r', 'l', 'd'},
+	}}
+	port := openPort(t, fh, ChipR, 0, 8)
+	var got []byte
+	buf := make([]byte, 4)
+	for len(got) < len("hello world") {
+		n, err := port.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: unexpected error %v after %q", err, got)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("Read = %q, want %q", got, "hello world")
+	}
+	for i, length := range fh.inLengths {
+		if length != 8 {
+			t.Errorf("transfer %d asked for %d bytes, want one 8-byte packet", i, length)
+		}
+	}
+	modem, line, lineErrors := port.Status()
+	if !modem.CTS() || modem.DSR() || !line.ParityError() || !lineErrors.ParityError() {
+		t.Errorf("Status = %#02x, %#02x, %#02x; want CTS and a parity error",
+			modem, line, lineErrors)
+	}
+	if _, _, lineErrors = port.Status(); lineErrors != 0 {
+		t.Errorf("line errors after Status = %#02x, want them cleared", lineErrors)
+	}
+
+	port.Timeout = 20
+	if _, err := port.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read while idle: got %v, want os.ErrDeadlineExceeded", err)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	fh := &fakeHandle{idleErr: libusb.ErrTimeout}
+	port := openPort(t, fh, ChipR, 0, 64)
+	port.Timeout = 50
+	buf := make([]byte, 4)
+	if _, err := port.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read with a timed out transfer: got %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	fh = &fakeHandle{}
+	port = openPort(t, fh, ChipR, 0, 64)
+	port.Timeout = 20
+	if _, err := port.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read while idle: got %v, want os.ErrDeadlineExceeded", err)
+	}
+	for i, timeout := range fh.inTimeouts {
+		if timeout < 1 || timeout > port.Timeout {
+			t.Fatalf("transfer %d timeout = %d ms, want 1 to %d", i, timeout, port.Timeout)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	fh := &fakeHandle{}
+	port := openPort(t, fh, ChipR, 0, 64)
+	if _, err := port.Write([]byte{0x80, 0x08, 0x0B}); err != nil {
+		t.Fatalf("Write: unexpected error %v", err)
+	}
+	if !bytes.Equal(fh.bulkOut, []byte{0x80, 0x08, 0x0B}) {
+		t.Errorf("bulk OUT = % x, want 80 08 0b", fh.bulkOut)
+	}
+}
+
+func TestVendorRequests(t *testing.T) {
+	fh := &fakeHandle{controlData: []byte{0x10}}
+	port := openPort(t, fh, Chip2232H, 1, 512)
+
+	steps := []struct {
+		name string
+		call func() error
+	}{
+		{"SetBaudRate", func() error {
+			_, err := port.SetBaudRate(115200)
+			return err
+		}},
+		{"SetLineProperties", func() error {
+			return port.SetLineProperties(SevenDataBits, TwoStopBits, ParityEven)
+		}},
+		{"SetBreak", func() error { return port.SetBreak(true) }},
+		{"SetFlowControl", func() error { return port.SetFlowControl(FlowXonXoff) }},
+		{"SetDTR", func() error { return port.SetDTR(true) }},
+		{"SetRTS", func() error { return port.SetRTS(false) }},
+		{"SetLatencyTimer", func() error { return port.SetLatencyTimer(2) }},
+		{"LatencyTimer", func() error {
+			ms, err := port.LatencyTimer()
+			if err == nil && ms != 16 {
+				err = fmt.Errorf("latency = %d, want 16", ms)
+			}
+			return err
+		}},
+		{"PurgeBuffers", func() error { return port.PurgeBuffers(true, true) }},
+		{"SetBitMode", func() error { return port.SetBitMode(0xFB, BitModeMPSSE) }},
+	}
+	for _, step := range steps {
+		if err := step.call(); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+	}
+	want := []vendorRequest{
+		{requestSetBaudRate, 0xC068, 0x0202},
+		{requestSetData, 0x1207, 2},
+		{requestSetData, 0x5207, 2},
+		{requestSetFlowCtrl, 0x1311, 0x0402},
+		{requestSetModemCtrl, 0x0101, 2},
+		{requestSetModemCtrl, 0x0200, 2},
+		{requestSetLatencyTimer, 2, 2},
+		{requestGetLatencyTimer, 0, 2},
+		{requestReset, resetPurgeRX, 2},
+		{requestReset, resetPurgeTX, 2},
+		{requestSetBitMode, 0x02FB, 2},
+	}
+	if !slices.Equal(fh.requests, want) {
+		t.Errorf("requests =\n%x\nwant\n%x", fh.requests, want)
+	}
+}
+
+func TestSetBaudRate(t *testing.T) {
+	fh := &fakeHandle{}
+	port := openPort(t, fh, ChipR, 0, 64)
+	actual, err := port.SetBaudRate(115200)
+	if err != nil || actual != 115385 {
+		t.Errorf("SetBaudRate(115200) = %d, %v; want 115385", actual, err)
+	}
+	if _, err := port.SetBaudRate(100); err == nil {
+		t.Error("SetBaudRate(100): expected error for an unreachable rate, got nil")
+	}
+	if err := port.SetBitMode(0xFF, BitModeBitBang); err != nil {
+		t.Fatalf("SetBitMode: unexpected error %v", err)
+	}
+	fh.requests = nil
+	if actual, err := port.SetBaudRate(9600); err != nil || actual != 9600 {
+		t.Errorf("SetBaudRate(9600) in bit-bang mode = %d, %v; want 9600", actual, err)
+	}
+	// The divisor is for four times the requested rate.
+	if len(fh.requests) != 1 || fh.requests[0].value != 0xC04E {
+		t.Errorf("requests = %x, want the 38400 baud divisor 0xc04e", fh.requests)
+	}
+	if err := port.SetBitMode(0, BitModeMPSSE); err == nil {
+		t.Error("SetBitMode(MPSSE) on an FT232R: expected error, got nil")
+	}
+}
+
+func TestPortValidation(t *testing.T) {
+	port := openPort(t, &fakeHandle{}, ChipR, 0, 64)
+	if err := port.SetLineProperties(6, OneStopBit, ParityNone); err == nil {
+		t.Error("SetLineProperties(6 data bits): expected error, got nil")
+	}
+	if err := port.SetLatencyTimer(0); err == nil {
+		t.Error("SetLatencyTimer(0): expected error, got nil")
+	}
+	if err := port.SetFlowControl(0x0300); err == nil {
+		t.Error("SetFlowControl(0x0300): expected error, got nil")
+	}
+}
@@ -10,14 +10,20 @@ package libusb
 import "C"
 import (
 	"encoding/binary"
+	"fmt"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
 	"unicode/utf16"
-	"unsafe"
 )
 
 // DeviceHandle represents the libusb device handle.
 type DeviceHandle struct {
 	libusbDeviceHandle *C.libusb_device_handle
+	transferer         transferer
+	stringCache        stringCache
 }
 
 // deviceHandleFinalizer is called by the garbage collector to clean up
@@ -26,93 +32,249 @@ func deviceHandleFinalizer(dh *DeviceHandle) {
 	if dh.libusbDeviceHandle != nil {
 		C.libusb_close(dh.libusbDeviceHandle)
 		dh.libusbDeviceHandle = nil
+		dh.transferer = nil
 	}
 }
 
 // newDeviceHandle creates a new DeviceHandle with proper finalizer setup.
-func newDeviceHandle(libusbDeviceHandle *C.libusb_device_handle) *DeviceHandle {
+func newDeviceHandle(
+	libusbContext *C.libusb_context,
+	libusbDeviceHandle *C.libusb_device_handle,
+) *DeviceHandle {
 	dh := &DeviceHandle{
 		libusbDeviceHandle: libusbDeviceHandle,
+		transferer: libusbTransferer{
+			context: libusbContext,
+			handle:  libusbDeviceHandle,
+		},
 	}
 	runtime.SetFinalizer(dh, deviceHandleFinalizer)
 	return dh
 }
 
-// StringDescriptor retrieves a descriptor from a device.
+// LangIDEnglishUS is the LANGID for English (United States), the language
+// supported by most devices.
+const LangIDEnglishUS uint16 = 0x0409
+
+// maxStringDescriptorLength is the largest possible string descriptor. The
+// bLength field is a single byte, so it can never exceed 255 bytes.
+const maxStringDescriptorLength = 255
+
+// stringCache holds the string descriptors already read through a
+// DeviceHandle, so that repeated reads don't re-query the device.
+type stringCache struct {
+	mu        sync.Mutex
+	languages []uint16
+	strings   map[stringKey]string
+}
+
+type stringKey struct {
+	index  uint8
+	langID uint16
+}
+
+func (cache *stringCache) lookup(key stringKey) (string, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	str, ok := cache.strings[key]
+	return str, ok
+}
+
+func (cache *stringCache) store(key stringKey, str string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.strings == nil {
+		cache.strings = make(map[stringKey]string)
+	}
+	cache.strings[key] = str
+}
+
+func (cache *stringCache) cachedLanguages() []uint16 {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return slices.Clone(cache.languages)
+}
+
+func (cache *stringCache) storeLanguages(languages []uint16) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.languages = slices.Clone(languages)
+}
+
+func (cache *stringCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.languages = nil
+	cache.strings = nil
+}
+
+// Languages reads the LANGID table in string descriptor zero and returns the
+// language IDs the device supports, in the order the device lists them. The
+// table is cached for the life of the handle.
+func (dh *DeviceHandle) Languages() ([]uint16, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	if languages := dh.stringCache.cachedLanguages(); languages != nil {
+		return languages, nil
+	}
+	data, err := dh.GetDescriptor(descString, 0, 0, maxStringDescriptorLength)
+	if err != nil {
+		return nil, err
+	}
+	languages, err := parseLanguages(data)
+	if err != nil {
+		return nil, err
+	}
+	dh.stringCache.storeLanguages(languages)
+	return languages, nil
+}
+
+// StringDescriptor retrieves a descriptor from a device. Successful reads are
+// cached for the life of the handle.
 func (dh *DeviceHandle) StringDescriptor(
 	descIndex uint8,
 	langID uint16,
 ) (string, error) {
-	if dh == nil || dh.libusbDeviceHandle == nil {
+	if dh == nil || dh.transferer == nil {
 		return "", ErrorCode(errorInvalidParam)
 	}
-
-	// Allocate buffer for data
-	length := 512
-	cData := make([]C.uchar, length)
-
-	var dataPtr *C.uchar
-	if len(cData) > 0 {
-		dataPtr = &cData[0]
+	key := stringKey{index: descIndex, langID: langID}
+	if str, ok := dh.stringCache.lookup(key); ok {
+		return str, nil
 	}
 
-	usberr := C.libusb_get_string_descriptor(
-		dh.libusbDeviceHandle,
-		C.uint8_t(descIndex),
-		C.uint16_t(langID),
-		dataPtr,
-		C.int(length),
+	data, err := dh.GetDescriptor(
+		descString,
+		descIndex,
+		langID,
+		maxStringDescriptorLength,
 	)
-	if usberr < 0 {
-		return "", ErrorCode(usberr)
-	}
-
-	data := C.GoBytes(unsafe.Pointer(dataPtr), C.int(usberr))
-	if len(data) <= 2 {
-		return "", nil
-	}
-
-	utf16Data := data[2:]
-	if len(utf16Data)%2 != 0 {
-		utf16Data = utf16Data[:len(utf16Data)-1]
+	if err != nil {
+		return "", err
 	}
+	str := decodeStringDescriptor(data)
+	dh.stringCache.store(key, str)
+	return str, nil
+}
 
-	codeUnits := make([]uint16, len(utf16Data)/2)
-	for i := range codeUnits {
-		codeUnits[i] = binary.LittleEndian.Uint16(utf16Data[i*2:])
-	}
-	return string(utf16.Decode(codeUnits)), nil
+// StringDescriptorAll retrieves the string descriptor at the given index in
+// every language the device supports, keyed by LANGID.
+func (dh *DeviceHandle) StringDescriptorAll(
+	descIndex uint8,
+) (map[uint16]string, error) {
+	languages, err := dh.Languages()
+	if err != nil {
+		return nil, err
+	}
+	strs := make(map[uint16]string, len(languages))
+	for _, langID := range languages {
+		str, err := dh.StringDescriptor(descIndex, langID)
+		if err != nil {
+			return nil, err
+		}
+		strs[langID] = str
+	}
+	return strs, nil
 }
 
 // StringDescriptorASCII retrieve(s) a string descriptor in C style ASCII.
-// Wrapper around libusb_get_string_descriptor(). Uses the first language
-// supported by the device. (Source: libusb docs)
+// Uses the first language supported by the device, and replaces any
+// non-ASCII characters with a question mark, like
+// libusb_get_string_descriptor_ascii().
 func (dh *DeviceHandle) StringDescriptorASCII(
 	descIndex uint8,
 ) (string, error) {
-	if dh == nil || dh.libusbDeviceHandle == nil {
+	if dh == nil || dh.transferer == nil {
+		return "", ErrorCode(errorInvalidParam)
+	}
+	// Index zero is the LANGID table rather than a string. Use Languages.
+	if descIndex == 0 {
 		return "", ErrorCode(errorInvalidParam)
 	}
+	languages, err := dh.Languages()
+	if err != nil {
+		return "", err
+	}
+	if len(languages) == 0 {
+		return "", fmt.Errorf(
+			"device reports no string languages: %w", ErrorCode(errorIo),
+		)
+	}
+	str, err := dh.StringDescriptor(descIndex, languages[0])
+	if err != nil {
+		return "", err
+	}
+	return asciiOnly(str), nil
+}
 
-	// TODO(mdr): Should the length be a constant? Why did I pick 256 bytes?
-	length := 256
-	data := make([]byte, length)
-	var dataPtr *C.uchar
-	if len(data) > 0 {
-		dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+// ClearStringCache discards the cached LANGID table and string descriptors,
+// so that they are read from the device again on the next request.
+func (dh *DeviceHandle) ClearStringCache() {
+	if dh == nil {
+		return
 	}
-	bytesRead := C.libusb_get_string_descriptor_ascii(
-		dh.libusbDeviceHandle,
-		C.uint8_t(descIndex),
-		// Unsafe pointer -> https://stackoverflow.com/a/16376039/95592
-		dataPtr,
-		C.int(length),
-	)
+	dh.stringCache.clear()
+}
+
+// parseLanguages decodes the LANGID table from string descriptor zero.
+func parseLanguages(data []byte) ([]uint16, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf(
+			"LANGID table is %d bytes; want at least 2: %w",
+			len(data),
+			ErrorCode(errorIo),
+		)
+	}
+	if descriptorType(data[1]) != descString {
+		return nil, fmt.Errorf(
+			"descriptor type %#02x is not a string descriptor: %w",
+			data[1],
+			ErrorCode(errorIo),
+		)
+	}
+	if bLength := int(data[0]); bLength < 2 || bLength%2 != 0 {
+		return nil, fmt.Errorf(
+			"LANGID table bLength %d isn't an even number of at least 2: %w",
+			bLength,
+			ErrorCode(errorIo),
+		)
+	}
+	length := min(int(data[0]), len(data))
+	languages := make([]uint16, 0, (length-2)/2)
+	for i := 2; i+1 < length; i += 2 {
+		languages = append(languages, binary.LittleEndian.Uint16(data[i:]))
+	}
+	return languages, nil
+}
+
+// decodeStringDescriptor decodes the UTF-16LE string that follows the
+// bLength and bDescriptorType bytes of a string descriptor.
+func decodeStringDescriptor(data []byte) string {
+	if len(data) <= 2 {
+		return ""
+	}
+	return decodeUTF16(data[2:])
+}
 
-	if bytesRead < 0 {
-		return "", ErrorCode(bytesRead)
+// decodeUTF16 decodes little-endian UTF-16 code units, ignoring a trailing odd
+// byte.
+func decodeUTF16(data []byte) string {
+	codeUnits := make([]uint16, len(data)/2)
+	for i := range codeUnits {
+		codeUnits[i] = binary.LittleEndian.Uint16(data[i*2:])
 	}
-	return string(data[0:bytesRead]), nil
+	return string(utf16.Decode(codeUnits))
+}
+
+// asciiOnly replaces every non-ASCII character in str with a question mark.
+func asciiOnly(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return '?'
+		}
+		return r
+	}, str)
 }
 
 // Close implements libusb_close to close the device handle.
@@ -122,6 +284,8 @@ func (dh *DeviceHandle) Close() error {
 	}
 	C.libusb_close(dh.libusbDeviceHandle)
 	dh.libusbDeviceHandle = nil
+	dh.transferer = nil
+	dh.stringCache.clear()
 	// Clear finalizer since we've explicitly closed the device handle
 	runtime.SetFinalizer(dh, nil)
 	return nil
@@ -209,11 +373,19 @@ func (dh *DeviceHandle) SetInterfaceAltSetting(
 	return nil
 }
 
-// FIXME(mdr): libusb_clear_halt takes an endpoint as an unsigned char. Need to
-// determine, what I should pass into this function as the endpoint.
-// func (dh *DeviceHandle) ClearHalt(endpoint int) error {
-// return nil
-// }
+// ClearHalt implements libusb_clear_halt to "clear the halt/stall condition
+// for an endpoint. Endpoints with halt status are unable to receive or
+// transmit data until the halt condition is stalled." (Source: libusb docs)
+func (dh *DeviceHandle) ClearHalt(endpoint EndpointAddress) error {
+	if dh == nil || dh.libusbDeviceHandle == nil {
+		return ErrorCode(errorInvalidParam)
+	}
+	err := C.libusb_clear_halt(dh.libusbDeviceHandle, C.uchar(endpoint))
+	if err != 0 {
+		return ErrorCode(err)
+	}
+	return nil
+}
 
 // ResetDevice implements libusb_reset_device to perform a USB port reset to
 // reinitialize a device.
@@ -6,7 +6,11 @@
 package libusb
 
 import (
+	"errors"
+	"maps"
+	"slices"
 	"testing"
+	"unicode/utf16"
 )
 
 func TestDeviceHandleNilChecks(t *testing.T) {
@@ -195,3 +199,215 @@ func TestDeviceHandleCloseIdempotent(t *testing.T) {
 		t.Errorf("Second Close should return errorInvalidParam, got %v", err)
 	}
 }
+
+func TestStringDescriptorDecodesUTF16(t *testing.T) {
+	ft := &fakeTransferer{
+		// bLength, bDescriptorType, then "Hé" in UTF-16LE.
+		response: []byte{0x06, 0x03, 'H', 0x00, 0xE9, 0x00},
+	}
+	dh := newFakeDeviceHandle(ft)
+	got, err := dh.StringDescriptor(2, 0x0409)
+	if err != nil {
+		t.Fatalf("StringDescriptor: unexpected error %v", err)
+	}
+	if got != "Hé" {
+		t.Errorf("StringDescriptor = %q, want %q", got, "Hé")
+	}
+	setup := ft.transfers[0].setup
+	want := NewSetupPacket(DeviceToHost, Standard, DeviceRecipient, 0x06, 0x0302, 0x0409, 255)
+	if setup != want {
+		t.Errorf("setup packet = %v, want %v", setup, want)
+	}
+}
+
+// fakeStringDevice answers GET_DESCRIPTOR requests for string descriptors
+// from a table keyed by language and index.
+func fakeStringDevice(
+	languages []uint16,
+	strs map[uint16]map[uint8]string,
+) func(setup SetupPacket, data []byte) (int, error) {
+	return func(setup SetupPacket, data []byte) (int, error) {
+		if setup.Request != byte(RequestGetDescriptor) || setup.Value>>8 != uint16(descString) {
+			return 0, ErrorCode(errorPipe)
+		}
+		var desc []byte
+		index := uint8(setup.Value)
+		if index == 0 {
+			desc = []byte{byte(2 + 2*len(languages)), byte(descString)}
+			for _, lang := range languages {
+				desc = append(desc, byte(lang), byte(lang>>8))
+			}
+		} else {
+			str, ok := strs[setup.Index][index]
+			if !ok {
+				return 0, ErrorCode(errorPipe)
+			}
+			units := utf16.Encode([]rune(str))
+			desc = []byte{byte(2 + 2*len(units)), byte(descString)}
+			for _, unit := range units {
+				desc = append(desc, byte(unit), byte(unit>>8))
+			}
+		}
+		return copy(data, desc), nil
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	ft := &fakeTransferer{
+		controlFunc: fakeStringDevice([]uint16{0x0409, 0x0407}, nil),
+	}
+	dh := newFakeDeviceHandle(ft)
+	for i := 0; i < 3; i++ {
+		languages, err := dh.Languages()
+		if err != nil {
+			t.Fatalf("Languages: unexpected error %v", err)
+		}
+		if !slices.Equal(languages, []uint16{0x0409, 0x0407}) {
+			t.Errorf("Languages = %#04x, want [0x0409 0x0407]", languages)
+		}
+	}
+	if len(ft.transfers) != 1 {
+		t.Errorf("Languages queried the device %d times, want 1", len(ft.transfers))
+	}
+}
+
+func TestStringDescriptorCache(t *testing.T) {
+	ft := &fakeTransferer{
+		controlFunc: fakeStringDevice(
+			[]uint16{LangIDEnglishUS},
+			map[uint16]map[uint8]string{
+				LangIDEnglishUS: {1: "Keysight", 2: "U2751A", 3: "MY12345678"},
+			},
+		),
+	}
+	dh := newFakeDeviceHandle(ft)
+	for i := 0; i < 3; i++ {
+		for index, want := range map[uint8]string{1: "Keysight", 2: "U2751A", 3: "MY12345678"} {
+			got, err := dh.StringDescriptor(index, LangIDEnglishUS)
+			if err != nil {
+				t.Fatalf("StringDescriptor(%d): unexpected error %v", index, err)
+			}
+			if got != want {
+				t.Errorf("StringDescriptor(%d) = %q, want %q", index, got, want)
+			}
+		}
+	}
+	if len(ft.transfers) != 3 {
+		t.Errorf("queried the device %d times, want 3", len(ft.transfers))
+	}
+
+	dh.ClearStringCache()
+	if _, err := dh.StringDescriptor(1, LangIDEnglishUS); err != nil {
+		t.Fatalf("StringDescriptor: unexpected error %v", err)
+	}
+	if len(ft.transfers) != 4 {
+		t.Errorf("ClearStringCache didn't force a new query")
+	}
+}
+
+func TestStringDescriptorErrorNotCached(t *testing.T) {
+	ft := &fakeTransferer{controlFunc: fakeStringDevice([]uint16{LangIDEnglishUS}, nil)}
+	dh := newFakeDeviceHandle(ft)
+	for i := 0; i < 2; i++ {
+		if _, err := dh.StringDescriptor(4, LangIDEnglishUS); err != ErrorCode(errorPipe) {
+			t.Errorf("StringDescriptor: got %v, want errorPipe", err)
+		}
+	}
+	if len(ft.transfers) != 2 {
+		t.Errorf("failed reads were cached: %d queries, want 2", len(ft.transfers))
+	}
+}
+
+func TestStringDescriptorAll(t *testing.T) {
+	ft := &fakeTransferer{
+		controlFunc: fakeStringDevice(
+			[]uint16{0x0409, 0x0407},
+			map[uint16]map[uint8]string{
+				0x0409: {2: "Power Supply"},
+				0x0407: {2: "Netzgerät"},
+			},
+		),
+	}
+	dh := newFakeDeviceHandle(ft)
+	got, err := dh.StringDescriptorAll(2)
+	if err != nil {
+		t.Fatalf("StringDescriptorAll: unexpected error %v", err)
+	}
+	want := map[uint16]string{0x0409: "Power Supply", 0x0407: "Netzgerät"}
+	if !maps.Equal(got, want) {
+		t.Errorf("StringDescriptorAll = %v, want %v", got, want)
+	}
+}
+
+func TestStringDescriptorASCII(t *testing.T) {
+	ft := &fakeTransferer{
+		controlFunc: fakeStringDevice(
+			[]uint16{0x0407, 0x0409},
+			map[uint16]map[uint8]string{
+				0x0407: {1: "Netzgerät"},
+				0x0409: {1: "Power Supply"},
+			},
+		),
+	}
+	dh := newFakeDeviceHandle(ft)
+	got, err := dh.StringDescriptorASCII(1)
+	if err != nil {
+		t.Fatalf("StringDescriptorASCII: unexpected error %v", err)
+	}
+	if got != "Netzger?t" {
+		t.Errorf("StringDescriptorASCII = %q, want %q", got, "Netzger?t")
+	}
+	if _, err := dh.StringDescriptorASCII(0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("StringDescriptorASCII(0): got %v, want errorInvalidParam", err)
+	}
+}
+
+func TestStringDescriptorASCIINoLanguages(t *testing.T) {
+	ft := &fakeTransferer{controlFunc: fakeStringDevice([]uint16{}, nil)}
+	dh := newFakeDeviceHandle(ft)
+	if _, err := dh.StringDescriptorASCII(1); !errors.Is(err, ErrorCode(errorIo)) {
+		t.Errorf("StringDescriptorASCII: got %v, want errorIo", err)
+	}
+}
+
+func TestParseLanguages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected []uint16
+		wantErr  bool
+	}{
+		{"single", []byte{0x04, 0x03, 0x09, 0x04}, []uint16{0x0409}, false},
+		{"two", []byte{0x06, 0x03, 0x09, 0x04, 0x07, 0x04}, []uint16{0x0409, 0x0407}, false},
+		{"empty table", []byte{0x02, 0x03}, []uint16{}, false},
+		{"odd bLength", []byte{0x05, 0x03, 0x09, 0x04, 0x07}, nil, true},
+		{"zero bLength", []byte{0x00, 0x03, 0x09, 0x04}, nil, true},
+		{"bLength of 1", []byte{0x01, 0x03}, nil, true},
+		{"data shorter than bLength", []byte{0x06, 0x03, 0x09, 0x04}, []uint16{0x0409}, false},
+		{"bLength shorter than data", []byte{0x04, 0x03, 0x09, 0x04, 0x07, 0x04}, []uint16{0x0409}, false},
+		{"too short", []byte{0x04}, nil, true},
+		{"wrong type", []byte{0x04, 0x01, 0x09, 0x04}, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseLanguages(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseLanguages error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("parseLanguages = %#04x, want %#04x", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLanguagesNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	if _, err := dh.Languages(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("Languages: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.StringDescriptorAll(1); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("StringDescriptorAll: got %v, want errorInvalidParam", err)
+	}
+	dh.ClearStringCache()
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package hid
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+const (
+	descriptorSize        = 9
+	classDescriptorOffset = 6
+	classDescriptorSize   = 3
+)
+
+// Descriptor models the HID class descriptor.
+type Descriptor struct {
+	Length         uint8
+	DescriptorType uint8
+	HIDVersion     uint16
+	CountryCode    uint8
+	// ClassDescriptors lists the report and physical descriptors that
+	// can be read with GetClassDescriptor.
+	ClassDescriptors []ClassDescriptor
+}
+
+// ClassDescriptor is an entry in the HID descriptor's list of class
+// descriptors.
+type ClassDescriptor struct {
+	DescriptorType uint8
+	Length         uint16
+}
+
+// ReportDescriptorLength returns the length of the first report descriptor
+// listed in the HID descriptor, or zero if none is listed.
+func (desc *Descriptor) ReportDescriptorLength() int {
+	for _, classDesc := range desc.ClassDescriptors {
+		if classDesc.DescriptorType == DescriptorTypeReport {
+			return int(classDesc.Length)
+		}
+	}
+	return 0
+}
+
+// ParseDescriptor converts a raw HID descriptor into a Descriptor.
+func ParseDescriptor(data []byte) (*Descriptor, error) {
+	if len(data) < descriptorSize {
+		return nil, fmt.Errorf(
+			"hid: HID descriptor is %d bytes; want at least %d",
+			len(data),
+			descriptorSize,
+		)
+	}
+	if data[1] != DescriptorTypeHID {
+		return nil, fmt.Errorf("hid: descriptor type %#02x is not a HID descriptor", data[1])
+	}
+	numDescriptors := int(data[5])
+	length := int(data[0])
+	if want := classDescriptorOffset + numDescriptors*classDescriptorSize; length < want ||
+		length > len(data) {
+		return nil, fmt.Errorf(
+			"hid: HID descriptor bLength %d doesn't fit %d class descriptors",
+			length,
+			numDescriptors,
+		)
+	}
+	desc := &Descriptor{
+		Length:           data[0],
+		DescriptorType:   data[1],
+		HIDVersion:       binary.LittleEndian.Uint16(data[2:4]),
+		CountryCode:      data[4],
+		ClassDescriptors: make([]ClassDescriptor, numDescriptors),
+	}
+	for i := range desc.ClassDescriptors {
+		entry := data[classDescriptorOffset+i*classDescriptorSize:]
+		desc.ClassDescriptors[i] = ClassDescriptor{
+			DescriptorType: entry[0],
+			Length:         binary.LittleEndian.Uint16(entry[1:3]),
+		}
+	}
+	return desc, nil
+}
+
+// findDescriptor locates the HID descriptor in an interface's extra bytes.
+func findDescriptor(extra []byte) (*Descriptor, error) {
+	descs, _ := libusb.SplitDescriptors(extra)
+	for _, desc := range descs {
+		if desc[1] == DescriptorTypeHID {
+			return ParseDescriptor(desc)
+		}
+	}
+	return nil, fmt.Errorf("hid: no HID descriptor in the interface's extra bytes")
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package hid
+
+import "testing"
+
+func TestParseDescriptor(t *testing.T) {
+	desc, err := ParseDescriptor(hidDescriptorBytes(0x1234))
+	if err != nil {
+		t.Fatalf("ParseDescriptor: unexpected error %v", err)
+	}
+	if desc.ReportDescriptorLength() != 0x1234 {
+		t.Errorf("ReportDescriptorLength = %#x, want 0x1234", desc.ReportDescriptorLength())
+	}
+	if desc.CountryCode != 0 || len(desc.ClassDescriptors) != 1 {
+		t.Errorf("descriptor = %+v", desc)
+	}
+
+	tooMany := hidDescriptorBytes(10)
+	tooMany[5] = 2
+	wrongType := hidDescriptorBytes(10)
+	wrongType[1] = 0x22
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"short", hidDescriptorBytes(10)[:8]},
+		{"wrong type", wrongType},
+		{"too many class descriptors", tooMany},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseDescriptor(tc.data); err == nil {
+				t.Error("ParseDescriptor: expected error, got nil")
+			}
+		})
+	}
+}
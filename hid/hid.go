@@ -0,0 +1,316 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package hid implements the USB Human Interface Device (HID) class on top of
+libusb: fetching and parsing the HID and report descriptors, the class
+control requests, and reading input reports from the interrupt IN endpoint.
+
+The caller opens the device and claims the HID interface. On Linux the usbhid
+driver usually owns the interface, so enable SetAutoDetachKernelDriver or call
+DetachKernelDriver before claiming it.
+*/
+package hid
+
+import (
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// DefaultTimeout is the timeout in milliseconds used for control and
+// interrupt transfers unless Device.Timeout is changed.
+const DefaultTimeout = 1000
+
+// Class descriptor types.
+const (
+	DescriptorTypeHID      = 0x21
+	DescriptorTypeReport   = 0x22
+	DescriptorTypePhysical = 0x23
+)
+
+// HID class-specific requests.
+const (
+	requestGetReport   = 0x01
+	requestGetIdle     = 0x02
+	requestGetProtocol = 0x03
+	requestSetReport   = 0x09
+	requestSetIdle     = 0x0A
+	requestSetProtocol = 0x0B
+)
+
+// Protocol selects between the boot protocol and the report protocol.
+type Protocol byte
+
+// HID protocols.
+const (
+	BootProtocol   Protocol = 0
+	ReportProtocol Protocol = 1
+)
+
+var protocols = map[Protocol]string{
+	BootProtocol:   "Boot",
+	ReportProtocol: "Report",
+}
+
+// String implements the Stringer interface for Protocol.
+func (protocol Protocol) String() string {
+	return protocols[protocol]
+}
+
+// Handle is the subset of *libusb.DeviceHandle used by a HID Device.
+type Handle interface {
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Device is an opened HID interface.
+type Device struct {
+	handle              Handle
+	Interface           int
+	Descriptor          *Descriptor
+	RawReportDescriptor []byte
+	ReportDescriptor    *ReportDescriptor
+	InEndpoint          libusb.EndpointAddress
+	InPacketSize        int
+	// OutEndpoint is zero if the interface has no interrupt OUT endpoint, in
+	// which case output reports are sent with SET_REPORT.
+	OutEndpoint libusb.EndpointAddress
+	// Timeout is the transfer timeout in milliseconds.
+	Timeout int
+}
+
+// Open reads the HID and report descriptors of a HID interface and locates
+// its interrupt endpoints. The interface should already be claimed.
+func Open(handle Handle, iface *libusb.InterfaceDescriptor) (*Device, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("hid: nil handle or interface descriptor")
+	}
+	if iface.InterfaceClass != libusb.InterfaceClassHID {
+		return nil, fmt.Errorf(
+			"hid: interface %d has class %#02x, not HID",
+			iface.InterfaceNumber,
+			iface.InterfaceClass,
+		)
+	}
+	dev := &Device{
+		handle:    handle,
+		Interface: iface.InterfaceNumber,
+		Timeout:   DefaultTimeout,
+	}
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.InterruptTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && dev.InEndpoint == 0 {
+			dev.InEndpoint = ep.EndpointAddress
+			dev.InPacketSize = int(ep.MaxPacketSize)
+		} else if ep.Direction() == libusb.EndpointOut && dev.OutEndpoint == 0 {
+			dev.OutEndpoint = ep.EndpointAddress
+		}
+	}
+	if dev.InEndpoint == 0 {
+		return nil, fmt.Errorf("hid: interface %d has no interrupt IN endpoint", dev.Interface)
+	}
+
+	desc, err := findDescriptor(iface.Extra)
+	if err != nil {
+		data, err := dev.GetClassDescriptor(DescriptorTypeHID, 0, descriptorSize)
+		if err != nil {
+			return nil, fmt.Errorf("hid: reading HID descriptor: %w", err)
+		}
+		if desc, err = ParseDescriptor(data); err != nil {
+			return nil, err
+		}
+	}
+	dev.Descriptor = desc
+
+	length := desc.ReportDescriptorLength()
+	if length == 0 {
+		return nil, fmt.Errorf("hid: HID descriptor doesn't list a report descriptor")
+	}
+	raw, err := dev.GetClassDescriptor(DescriptorTypeReport, 0, length)
+	if err != nil {
+		return nil, fmt.Errorf("hid: reading report descriptor: %w", err)
+	}
+	dev.RawReportDescriptor = raw
+	if dev.ReportDescriptor, err = ParseReportDescriptor(raw); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// GetClassDescriptor issues a standard GET_DESCRIPTOR request to the HID
+// interface for a class descriptor, such as the report descriptor.
+func (dev *Device) GetClassDescriptor(descType byte, index byte, length int) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := dev.handle.ControlIn(
+		libusb.Standard,
+		libusb.InterfaceRecipient,
+		byte(libusb.RequestGetDescriptor),
+		uint16(descType)<<8|uint16(index),
+		uint16(dev.Interface),
+		data,
+		length,
+		dev.Timeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// GetReport issues a GET_REPORT request, returning at most length bytes. If
+// the device uses report IDs, the first byte of the returned report is the
+// report ID.
+func (dev *Device) GetReport(reportType ReportType, reportID byte, length int) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := dev.classIn(requestGetReport, uint16(reportType)<<8|uint16(reportID), data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// SetReport issues a SET_REPORT request. If the device uses report IDs, the
+// first byte of data must be the report ID.
+func (dev *Device) SetReport(reportType ReportType, reportID byte, data []byte) error {
+	return dev.classOut(requestSetReport, uint16(reportType)<<8|uint16(reportID), data)
+}
+
+// GetIdle issues a GET_IDLE request and returns the idle rate in
+// milliseconds for the report ID. Zero means reports are only sent when the
+// data changes.
+func (dev *Device) GetIdle(reportID byte) (int, error) {
+	data := make([]byte, 1)
+	n, err := dev.classIn(requestGetIdle, uint16(reportID), data)
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("hid: GET_IDLE returned %d bytes; want 1", n)
+	}
+	return int(data[0]) * 4, nil
+}
+
+// SetIdle issues a SET_IDLE request. The duration is in milliseconds with a
+// resolution of 4 ms and a maximum of 1020 ms; zero silences the report until
+// its data changes. A report ID of zero applies to all reports.
+func (dev *Device) SetIdle(durationMS int, reportID byte) error {
+	if durationMS < 0 || durationMS > 1020 {
+		return fmt.Errorf("hid: idle duration %d ms out of range", durationMS)
+	}
+	return dev.classOut(requestSetIdle, uint16(durationMS/4)<<8|uint16(reportID), nil)
+}
+
+// GetProtocol issues a GET_PROTOCOL request.
+func (dev *Device) GetProtocol() (Protocol, error) {
+	data := make([]byte, 1)
+	n, err := dev.classIn(requestGetProtocol, 0, data)
+	if err != nil {
+		return 0, err
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("hid: GET_PROTOCOL returned %d bytes; want 1", n)
+	}
+	return Protocol(data[0]), nil
+}
+
+// SetProtocol issues a SET_PROTOCOL request. Only boot interfaces support
+// it.
+func (dev *Device) SetProtocol(protocol Protocol) error {
+	return dev.classOut(requestSetProtocol, uint16(protocol), nil)
+}
+
+// ReadInputReport reads one input report from the interrupt IN endpoint. If
+// the device uses report IDs, the first byte is the report ID.
+func (dev *Device) ReadInputReport() ([]byte, error) {
+	size := dev.InPacketSize
+	if dev.ReportDescriptor != nil {
+		size = max(size, dev.ReportDescriptor.MaxLength(Input))
+	}
+	data := make([]byte, size)
+	n, err := dev.handle.InterruptTransfer(dev.InEndpoint, data, len(data), dev.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// WriteOutputReport sends an output report on the interrupt OUT endpoint, or
+// with SET_REPORT if the interface doesn't have one. If the device uses
+// report IDs, the first byte of data must be the report ID.
+func (dev *Device) WriteOutputReport(data []byte) error {
+	if dev.OutEndpoint == 0 {
+		var reportID byte
+		if dev.ReportDescriptor != nil && dev.ReportDescriptor.UsesReportIDs() && len(data) > 0 {
+			reportID = data[0]
+		}
+		return dev.SetReport(Output, reportID, data)
+	}
+	n, err := dev.handle.InterruptTransfer(dev.OutEndpoint, data, len(data), dev.Timeout)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("hid: wrote %d of %d bytes", n, len(data))
+	}
+	return nil
+}
+
+func (dev *Device) classIn(request byte, value uint16, data []byte) (int, error) {
+	return dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(dev.Interface),
+		data,
+		len(data),
+		dev.Timeout,
+	)
+}
+
+func (dev *Device) classOut(request byte, value uint16, data []byte) error {
+	n, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(dev.Interface),
+		data,
+		dev.Timeout,
+	)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("hid: sent %d of %d bytes", n, len(data))
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package hid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+type fakeRequest struct {
+	in        bool
+	reqType   libusb.RequestType
+	recipient libusb.RequestRecipient
+	request   byte
+	value     uint16
+	index     uint16
+	data      []byte
+}
+
+// fakeHandle records control requests and answers IN requests from
+// responses, keyed by bRequest and wValue.
+type fakeHandle struct {
+	requests  []fakeRequest
+	responses map[[2]uint16][]byte
+	interrupt [][]byte
+	written   [][]byte
+	err       error
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests, fakeRequest{true, reqType, recipient, request, value, index, nil})
+	if fh.err != nil {
+		return 0, fh.err
+	}
+	response, ok := fh.responses[[2]uint16{uint16(request), value}]
+	if !ok {
+		return 0, libusb.ErrPipe
+	}
+	return copy(data[:maxReceiveLength], response), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests, fakeRequest{
+		false, reqType, recipient, request, value, index, bytes.Clone(data),
+	})
+	if fh.err != nil {
+		return 0, fh.err
+	}
+	return len(data), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if fh.err != nil {
+		return 0, fh.err
+	}
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, bytes.Clone(data[:length]))
+		return length, nil
+	}
+	if len(fh.interrupt) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	report := fh.interrupt[0]
+	fh.interrupt = fh.interrupt[1:]
+	return copy(data[:length], report), nil
+}
+
+func hidDescriptorBytes(reportLength int) []byte {
+	return []byte{
+		0x09, DescriptorTypeHID, 0x11, 0x01, 0x00, 0x01,
+		DescriptorTypeReport, byte(reportLength), byte(reportLength >> 8),
+	}
+}
+
+func hidInterface(extra []byte, endpoints ...libusb.EndpointAddress) *libusb.InterfaceDescriptor {
+	iface := &libusb.InterfaceDescriptor{
+		InterfaceNumber: 2,
+		InterfaceClass:  libusb.InterfaceClassHID,
+		Extra:           extra,
+	}
+	for _, address := range endpoints {
+		iface.EndpointDescriptors = append(iface.EndpointDescriptors, &libusb.EndpointDescriptor{
+			EndpointAddress: address,
+			Attributes:      0x03,
+			MaxPacketSize:   8,
+		})
+	}
+	return iface
+}
+
+func openRelayBoard(t *testing.T, endpoints ...libusb.EndpointAddress) (*Device, *fakeHandle) {
+	t.Helper()
+	fh := &fakeHandle{responses: map[[2]uint16][]byte{
+		{uint16(libusb.RequestGetDescriptor), DescriptorTypeReport << 8}: relayBoard,
+	}}
+	dev, err := Open(fh, hidInterface(hidDescriptorBytes(len(relayBoard)), endpoints...))
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev, fh
+}
+
+func TestOpen(t *testing.T) {
+	dev, fh := openRelayBoard(t, 0x81)
+	if dev.InEndpoint != 0x81 || dev.OutEndpoint != 0 {
+		t.Errorf("endpoints = %#02x/%#02x, want 0x81/0", dev.InEndpoint, dev.OutEndpoint)
+	}
+	if dev.Descriptor.HIDVersion != 0x0111 {
+		t.Errorf("HIDVersion = %#04x, want 0x0111", dev.Descriptor.HIDVersion)
+	}
+	if !bytes.Equal(dev.RawReportDescriptor, relayBoard) {
+		t.Error("RawReportDescriptor doesn't match the device's report descriptor")
+	}
+	if len(fh.requests) != 1 {
+		t.Fatalf("got %d requests, want only the report descriptor", len(fh.requests))
+	}
+	req := fh.requests[0]
+	if req.recipient != libusb.InterfaceRecipient || req.index != 2 || req.reqType != libusb.Standard {
+		t.Errorf("report descriptor request = %+v, want standard interface request to 2", req)
+	}
+}
+
+func TestOpenReadsHIDDescriptor(t *testing.T) {
+	fh := &fakeHandle{responses: map[[2]uint16][]byte{
+		{uint16(libusb.RequestGetDescriptor), DescriptorTypeHID << 8}:    hidDescriptorBytes(len(bootMouse)),
+		{uint16(libusb.RequestGetDescriptor), DescriptorTypeReport << 8}: bootMouse,
+	}}
+	dev, err := Open(fh, hidInterface(nil, 0x81))
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	if len(dev.ReportDescriptor.Fields) != 3 {
+		t.Errorf("got %d fields, want 3", len(dev.ReportDescriptor.Fields))
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	fh := &fakeHandle{}
+	notHID := hidInterface(hidDescriptorBytes(10), 0x81)
+	notHID.InterfaceClass = libusb.InterfaceClassVendorSpec
+	testCases := []struct {
+		name  string
+		iface *libusb.InterfaceDescriptor
+	}{
+		{"nil interface", nil},
+		{"not HID", notHID},
+		{"no IN endpoint", hidInterface(hidDescriptorBytes(10), 0x01)},
+		{"no report descriptor", hidInterface(hidDescriptorBytes(10), 0x81)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Open(fh, tc.iface); err == nil {
+				t.Error("Open: expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestClassRequests(t *testing.T) {
+	dev, fh := openRelayBoard(t, 0x81)
+	fh.responses[[2]uint16{requestGetReport, 0x0302}] = []byte{0x02, 0x34, 0x12, 0x07}
+	fh.responses[[2]uint16{requestGetIdle, 0x0001}] = []byte{0x7D}
+	fh.responses[[2]uint16{requestGetProtocol, 0}] = []byte{byte(ReportProtocol)}
+	fh.requests = nil
+
+	report, err := dev.GetReport(Feature, 2, 4)
+	if err != nil || !bytes.Equal(report, []byte{0x02, 0x34, 0x12, 0x07}) {
+		t.Errorf("GetReport = % x, %v", report, err)
+	}
+	value, err := dev.ReportDescriptor.Report(Feature, 2).Fields[0].Value(report, 0)
+	if err != nil || value != 0x1234 {
+		t.Errorf("feature value = %#x, %v; want 0x1234", value, err)
+	}
+	if err := dev.SetReport(Output, 1, []byte{0x01, 0xFF, 0x00}); err != nil {
+		t.Errorf("SetReport: unexpected error %v", err)
+	}
+	if err := dev.SetIdle(500, 0); err != nil {
+		t.Errorf("SetIdle: unexpected error %v", err)
+	}
+	if idle, err := dev.GetIdle(1); err != nil || idle != 500 {
+		t.Errorf("GetIdle = %d, %v; want 500", idle, err)
+	}
+	if err := dev.SetProtocol(BootProtocol); err != nil {
+		t.Errorf("SetProtocol: unexpected error %v", err)
+	}
+	if protocol, err := dev.GetProtocol(); err != nil || protocol != ReportProtocol {
+		t.Errorf("GetProtocol = %v, %v; want Report", protocol, err)
+	}
+
+	want := []fakeRequest{
+		{true, libusb.Class, libusb.InterfaceRecipient, requestGetReport, 0x0302, 2, nil},
+		{false, libusb.Class, libusb.InterfaceRecipient, requestSetReport, 0x0201, 2, []byte{0x01, 0xFF, 0x00}},
+		{false, libusb.Class, libusb.InterfaceRecipient, requestSetIdle, 0x7D00, 2, nil},
+		{true, libusb.Class, libusb.InterfaceRecipient, requestGetIdle, 0x0001, 2, nil},
+		{false, libusb.Class, libusb.InterfaceRecipient, requestSetProtocol, 0, 2, nil},
+		{true, libusb.Class, libusb.InterfaceRecipient, requestGetProtocol, 0, 2, nil},
+	}
+	if len(fh.requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(fh.requests), len(want))
+	}
+	for i, req := range fh.requests {
+		w := want[i]
+		if req.in != w.in || req.reqType != w.reqType || req.recipient != w.recipient ||
+			req.request != w.request || req.value != w.value || req.index != w.index ||
+			!bytes.Equal(req.data, w.data) {
+			t.Errorf("request %d = %+v, want %+v", i, req, w)
+		}
+	}
+	if err := dev.SetIdle(2000, 0); err == nil {
+		t.Error("SetIdle(2000): expected error, got nil")
+	}
+}
+
+func TestReadInputReport(t *testing.T) {
+	dev, fh := openRelayBoard(t, 0x81)
+	fh.interrupt = [][]byte{{0x01, 0x0A, 0x0B}}
+	report, err := dev.ReadInputReport()
+	if err != nil {
+		t.Fatalf("ReadInputReport: unexpected error %v", err)
+	}
+	if !bytes.Equal(report, []byte{0x01, 0x0A, 0x0B}) {
+		t.Errorf("report = % x, want 01 0a 0b", report)
+	}
+	if _, err := dev.ReadInputReport(); !errors.Is(err, libusb.ErrTimeout) {
+		t.Errorf("ReadInputReport with no data: got %v, want a timeout", err)
+	}
+}
+
+func TestWriteOutputReport(t *testing.T) {
+	dev, fh := openRelayBoard(t, 0x81, 0x02)
+	if err := dev.WriteOutputReport([]byte{0x01, 0xFF}); err != nil {
+		t.Fatalf("WriteOutputReport: unexpected error %v", err)
+	}
+	if len(fh.written) != 1 || !bytes.Equal(fh.written[0], []byte{0x01, 0xFF}) {
+		t.Errorf("interrupt OUT writes = %x, want [01 ff]", fh.written)
+	}
+
+	dev, fh = openRelayBoard(t, 0x81)
+	fh.requests = nil
+	if err := dev.WriteOutputReport([]byte{0x01, 0xFF}); err != nil {
+		t.Fatalf("WriteOutputReport: unexpected error %v", err)
+	}
+	if len(fh.requests) != 1 || fh.requests[0].request != requestSetReport ||
+		fh.requests[0].value != 0x0201 {
+		t.Errorf("requests = %+v, want SET_REPORT(Output, 1)", fh.requests)
+	}
+}
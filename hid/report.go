@@ -0,0 +1,606 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package hid
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ReportType identifies input, output, and feature reports.
+type ReportType byte
+
+// Report types, as used in the high byte of wValue for GET_REPORT and
+// SET_REPORT.
+const (
+	Input   ReportType = 1
+	Output  ReportType = 2
+	Feature ReportType = 3
+)
+
+var reportTypes = map[ReportType]string{
+	Input:   "Input",
+	Output:  "Output",
+	Feature: "Feature",
+}
+
+// String implements the Stringer interface for ReportType.
+func (reportType ReportType) String() string {
+	return reportTypes[reportType]
+}
+
+// Usage is an extended usage: the usage page in the high 16 bits and the
+// usage ID in the low 16 bits.
+type Usage uint32
+
+// Page returns the usage page.
+func (usage Usage) Page() uint16 {
+	return uint16(usage >> 16)
+}
+
+// ID returns the usage ID within its page.
+func (usage Usage) ID() uint16 {
+	return uint16(usage)
+}
+
+// String implements the Stringer interface for Usage.
+func (usage Usage) String() string {
+	return fmt.Sprintf("%04x:%04x", usage.Page(), usage.ID())
+}
+
+// MainFlags holds the data bits of an Input, Output, or Feature main item.
+type MainFlags uint32
+
+// Constant reports whether the field is constant padding.
+func (flags MainFlags) Constant() bool { return flags&0x001 != 0 }
+
+// Variable reports whether each element of the field is a separate variable,
+// as opposed to an array of usage selectors.
+func (flags MainFlags) Variable() bool { return flags&0x002 != 0 }
+
+// Relative reports whether the values are relative to the previous report.
+func (flags MainFlags) Relative() bool { return flags&0x004 != 0 }
+
+// Wrap reports whether the values roll over at the logical extremes.
+func (flags MainFlags) Wrap() bool { return flags&0x008 != 0 }
+
+// NonLinear reports whether the values are non-linear.
+func (flags MainFlags) NonLinear() bool { return flags&0x010 != 0 }
+
+// NoPreferredState reports whether the control has no preferred state.
+func (flags MainFlags) NoPreferredState() bool { return flags&0x020 != 0 }
+
+// NullState reports whether the control has a null state outside the
+// logical range.
+func (flags MainFlags) NullState() bool { return flags&0x040 != 0 }
+
+// Volatile reports whether the device may change an output or feature value
+// on its own.
+func (flags MainFlags) Volatile() bool { return flags&0x080 != 0 }
+
+// BufferedBytes reports whether the field is a fixed-size byte stream.
+func (flags MainFlags) BufferedBytes() bool { return flags&0x100 != 0 }
+
+// CollectionType is the data of a Collection main item.
+type CollectionType byte
+
+// Collection types.
+const (
+	CollectionPhysical      CollectionType = 0x00
+	CollectionApplication   CollectionType = 0x01
+	CollectionLogical       CollectionType = 0x02
+	CollectionReport        CollectionType = 0x03
+	CollectionNamedArray    CollectionType = 0x04
+	CollectionUsageSwitch   CollectionType = 0x05
+	CollectionUsageModifier CollectionType = 0x06
+)
+
+var collectionTypes = map[CollectionType]string{
+	CollectionPhysical:      "Physical",
+	CollectionApplication:   "Application",
+	CollectionLogical:       "Logical",
+	CollectionReport:        "Report",
+	CollectionNamedArray:    "Named Array",
+	CollectionUsageSwitch:   "Usage Switch",
+	CollectionUsageModifier: "Usage Modifier",
+}
+
+// String implements the Stringer interface for CollectionType.
+func (collectionType CollectionType) String() string {
+	return collectionTypes[collectionType]
+}
+
+// Collection models a Collection main item. Parent is nil for a top-level
+// collection.
+type Collection struct {
+	Type   CollectionType
+	Usage  Usage
+	Parent *Collection
+}
+
+// Field models one Input, Output, or Feature main item: Count elements of
+// BitSize bits each, starting at BitOffset. BitOffset indexes into the report
+// as sent on the bus, so when the device uses report IDs it includes the
+// 8-bit report ID prefix.
+//
+// For variable fields, element i has usage Usages[i], with the last usage
+// repeating if there are fewer usages than elements. For array fields, each
+// element holds a selector v, which stands for Usages[v-LogicalMinimum].
+type Field struct {
+	Type            ReportType
+	ReportID        uint8
+	Flags           MainFlags
+	BitOffset       int
+	BitSize         int
+	Count           int
+	Usages          []Usage
+	LogicalMinimum  int32
+	LogicalMaximum  int32
+	PhysicalMinimum int32
+	PhysicalMaximum int32
+	Unit            uint32
+	UnitExponent    int8
+	// Collection is the innermost collection containing the field.
+	Collection *Collection
+}
+
+// Usage returns the usage of element i of a variable field, or zero if the
+// field has no usages.
+func (field *Field) Usage(i int) Usage {
+	if len(field.Usages) == 0 {
+		return 0
+	}
+	return field.Usages[min(i, len(field.Usages)-1)]
+}
+
+// Value extracts element i of the field from a report. The value is sign
+// extended if the field's logical minimum is negative.
+func (field *Field) Value(report []byte, i int) (int64, error) {
+	offset, err := field.elementOffset(report, i)
+	if err != nil {
+		return 0, err
+	}
+	var raw uint64
+	for bit := 0; bit < field.BitSize; bit++ {
+		pos := offset + bit
+		if report[pos/8]&(1<<(pos%8)) != 0 {
+			raw |= 1 << bit
+		}
+	}
+	if field.LogicalMinimum < 0 && field.BitSize < 64 && raw&(1<<(field.BitSize-1)) != 0 {
+		return int64(raw) - 1<<field.BitSize, nil
+	}
+	return int64(raw), nil
+}
+
+// SetValue stores value in element i of the field in a report. Bits of
+// value beyond BitSize are discarded.
+func (field *Field) SetValue(report []byte, i int, value int64) error {
+	offset, err := field.elementOffset(report, i)
+	if err != nil {
+		return err
+	}
+	for bit := 0; bit < field.BitSize; bit++ {
+		pos := offset + bit
+		if uint64(value)&(1<<bit) != 0 {
+			report[pos/8] |= 1 << (pos % 8)
+		} else {
+			report[pos/8] &^= 1 << (pos % 8)
+		}
+	}
+	return nil
+}
+
+func (field *Field) elementOffset(report []byte, i int) (int, error) {
+	if i < 0 || i >= field.Count {
+		return 0, fmt.Errorf("hid: element %d out of range for a %d element field", i, field.Count)
+	}
+	if field.BitSize <= 0 || field.BitSize > 32 {
+		return 0, fmt.Errorf("hid: unsupported field size of %d bits", field.BitSize)
+	}
+	offset := field.BitOffset + i*field.BitSize
+	if end := offset + field.BitSize; end > len(report)*8 {
+		return 0, fmt.Errorf("hid: %d byte report is too short for bit %d", len(report), end)
+	}
+	return offset, nil
+}
+
+// ReportLayout describes one report: its type, report ID, fields, and total
+// length in bits including any report ID prefix.
+type ReportLayout struct {
+	Type   ReportType
+	ID     uint8
+	Fields []*Field
+	Bits   int
+}
+
+// Length returns the length of the report in bytes.
+func (layout *ReportLayout) Length() int {
+	return (layout.Bits + 7) / 8
+}
+
+// ReportDescriptor is a parsed report descriptor. Fields are listed in
+// descriptor order and Reports in the order each report first appears.
+type ReportDescriptor struct {
+	Fields      []*Field
+	Reports     []*ReportLayout
+	Collections []*Collection
+}
+
+// Report returns the layout of the report with the given type and ID, or
+// nil if the descriptor doesn't define it.
+func (rd *ReportDescriptor) Report(reportType ReportType, reportID uint8) *ReportLayout {
+	for _, layout := range rd.Reports {
+		if layout.Type == reportType && layout.ID == reportID {
+			return layout
+		}
+	}
+	return nil
+}
+
+// UsesReportIDs reports whether the descriptor assigns report IDs, in which
+// case every report is prefixed with its ID.
+func (rd *ReportDescriptor) UsesReportIDs() bool {
+	for _, layout := range rd.Reports {
+		if layout.ID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxLength returns the length in bytes of the longest report of the given
+// type.
+func (rd *ReportDescriptor) MaxLength(reportType ReportType) int {
+	length := 0
+	for _, layout := range rd.Reports {
+		if layout.Type == reportType {
+			length = max(length, layout.Length())
+		}
+	}
+	return length
+}
+
+// Item types.
+const (
+	itemMain   = 0
+	itemGlobal = 1
+	itemLocal  = 2
+	itemLong   = 0xFE
+)
+
+// Main item tags.
+const (
+	tagInput         = 0x8
+	tagOutput        = 0x9
+	tagCollection    = 0xA
+	tagFeature       = 0xB
+	tagEndCollection = 0xC
+)
+
+// Global item tags.
+const (
+	tagUsagePage       = 0x0
+	tagLogicalMinimum  = 0x1
+	tagLogicalMaximum  = 0x2
+	tagPhysicalMinimum = 0x3
+	tagPhysicalMaximum = 0x4
+	tagUnitExponent    = 0x5
+	tagUnit            = 0x6
+	tagReportSize      = 0x7
+	tagReportID        = 0x8
+	tagReportCount     = 0x9
+	tagPush            = 0xA
+	tagPop             = 0xB
+)
+
+// Local item tags.
+const (
+	tagUsage        = 0x0
+	tagUsageMinimum = 0x1
+	tagUsageMaximum = 0x2
+)
+
+var mainItemReportTypes = map[byte]ReportType{
+	tagInput:   Input,
+	tagOutput:  Output,
+	tagFeature: Feature,
+}
+
+// maxUsageRange bounds how many usages a Usage Minimum/Maximum pair may
+// expand to, and maxUsages how many usages a main item may have, so a
+// corrupt descriptor can't exhaust memory.
+const (
+	maxUsageRange = 0x10000
+	maxUsages     = 0x10000
+)
+
+// maxReportBits bounds the length of a report, since a control transfer
+// can't carry more than 65535 bytes.
+const maxReportBits = 0xFFFF * 8
+
+type globalState struct {
+	usagePage       uint16
+	logicalMinimum  int32
+	logicalMaximum  int32
+	physicalMinimum int32
+	physicalMaximum int32
+	// The unsigned readings of the maximums, for descriptors that encode
+	// an unsigned maximum such as 255 in a single byte.
+	logicalMaximumUnsigned  uint32
+	physicalMaximumUnsigned uint32
+	unitExponent            int8
+	unit                    uint32
+	reportSize              int
+	reportID                uint8
+	reportCount             int
+}
+
+// localUsage is a Usage, Usage Minimum, or Usage Maximum item. Usages given
+// with fewer than four bytes take their page from the global state when the
+// main item is reached.
+type localUsage struct {
+	value    uint32
+	extended bool
+}
+
+func (usage localUsage) resolve(page uint16) Usage {
+	if usage.extended {
+		return Usage(usage.value)
+	}
+	return Usage(uint32(page)<<16 | usage.value)
+}
+
+type localState struct {
+	usages       []localUsage
+	usageMinimum *localUsage
+}
+
+// ParseReportDescriptor parses a raw report descriptor into its fields and
+// report layouts.
+func ParseReportDescriptor(data []byte) (*ReportDescriptor, error) {
+	rd := &ReportDescriptor{}
+	var (
+		global     globalState
+		stack      []globalState
+		local      localState
+		collection *Collection
+		bits       = make(map[[2]byte]int)
+	)
+	for offset := 0; offset < len(data); {
+		prefix := data[offset]
+		if prefix == itemLong {
+			if offset+2 >= len(data) {
+				return nil, fmt.Errorf("hid: truncated long item at offset %d", offset)
+			}
+			offset += 3 + int(data[offset+1])
+			continue
+		}
+		size := int(prefix & 0x03)
+		if size == 3 {
+			size = 4
+		}
+		if offset+1+size > len(data) {
+			return nil, fmt.Errorf("hid: truncated item at offset %d", offset)
+		}
+		itemData := data[offset+1 : offset+1+size]
+		unsigned := itemUnsigned(itemData)
+		signed := itemSigned(itemData)
+		itemType := (prefix >> 2) & 0x03
+		tag := prefix >> 4
+
+		switch itemType {
+		case itemMain:
+			switch tag {
+			case tagInput, tagOutput, tagFeature:
+				reportType := mainItemReportTypes[tag]
+				field, err := newField(reportType, MainFlags(unsigned), global, local, collection)
+				if err != nil {
+					return nil, fmt.Errorf("hid: item at offset %d: %w", offset, err)
+				}
+				key := [2]byte{byte(reportType), global.reportID}
+				layout := rd.Report(reportType, global.reportID)
+				if layout == nil {
+					layout = &ReportLayout{Type: reportType, ID: global.reportID}
+					if global.reportID != 0 {
+						bits[key] = 8
+					}
+					rd.Reports = append(rd.Reports, layout)
+				}
+				field.BitOffset = bits[key]
+				bits[key] += field.BitSize * field.Count
+				if bits[key] > maxReportBits {
+					return nil, fmt.Errorf(
+						"hid: %s report %d exceeds %d bits at offset %d",
+						reportType,
+						global.reportID,
+						maxReportBits,
+						offset,
+					)
+				}
+				layout.Bits = bits[key]
+				layout.Fields = append(layout.Fields, field)
+				rd.Fields = append(rd.Fields, field)
+			case tagCollection:
+				var usage Usage
+				if len(local.usages) > 0 {
+					usage = local.usages[0].resolve(global.usagePage)
+				}
+				collection = &Collection{
+					Type:   CollectionType(unsigned),
+					Usage:  usage,
+					Parent: collection,
+				}
+				rd.Collections = append(rd.Collections, collection)
+			case tagEndCollection:
+				if collection == nil {
+					return nil, fmt.Errorf("hid: unbalanced End Collection at offset %d", offset)
+				}
+				collection = collection.Parent
+			}
+			local = localState{}
+
+		case itemGlobal:
+			switch tag {
+			case tagUsagePage:
+				global.usagePage = uint16(unsigned)
+			case tagLogicalMinimum:
+				global.logicalMinimum = signed
+			case tagLogicalMaximum:
+				global.logicalMaximum = signed
+				global.logicalMaximumUnsigned = unsigned
+			case tagPhysicalMinimum:
+				global.physicalMinimum = signed
+			case tagPhysicalMaximum:
+				global.physicalMaximum = signed
+				global.physicalMaximumUnsigned = unsigned
+			case tagUnitExponent:
+				// The exponent is a 4-bit two's complement nibble.
+				exponent := int8(unsigned & 0x0F)
+				if exponent > 7 {
+					exponent -= 16
+				}
+				global.unitExponent = exponent
+			case tagUnit:
+				global.unit = unsigned
+			case tagReportSize:
+				global.reportSize = int(unsigned)
+			case tagReportID:
+				if unsigned == 0 || unsigned > 0xFF {
+					return nil, fmt.Errorf("hid: invalid report ID %d at offset %d", unsigned, offset)
+				}
+				global.reportID = uint8(unsigned)
+			case tagReportCount:
+				global.reportCount = int(unsigned)
+			case tagPush:
+				stack = append(stack, global)
+			case tagPop:
+				if len(stack) == 0 {
+					return nil, fmt.Errorf("hid: Pop without Push at offset %d", offset)
+				}
+				global = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+
+		case itemLocal:
+			usage := localUsage{value: unsigned, extended: size == 4}
+			switch tag {
+			case tagUsage:
+				if len(local.usages) >= maxUsages {
+					return nil, fmt.Errorf("hid: too many usages at offset %d", offset)
+				}
+				local.usages = append(local.usages, usage)
+			case tagUsageMinimum:
+				local.usageMinimum = &usage
+			case tagUsageMaximum:
+				if local.usageMinimum == nil {
+					return nil, fmt.Errorf(
+						"hid: Usage Maximum without Usage Minimum at offset %d",
+						offset,
+					)
+				}
+				usageMin := *local.usageMinimum
+				if !usageMin.extended && usage.extended {
+					usageMin.value |= usage.value &^ 0xFFFF
+					usageMin.extended = true
+				}
+				if usage.value < usageMin.value || usage.value-usageMin.value >= maxUsageRange {
+					return nil, fmt.Errorf(
+						"hid: invalid usage range %#x-%#x at offset %d",
+						usageMin.value,
+						usage.value,
+						offset,
+					)
+				}
+				count := usage.value - usageMin.value + 1
+				if len(local.usages)+int(count) > maxUsages {
+					return nil, fmt.Errorf("hid: too many usages at offset %d", offset)
+				}
+				// Counting rather than comparing against the maximum keeps a
+				// range that ends at 0xFFFFFFFF from wrapping.
+				for i := uint32(0); i < count; i++ {
+					value := usageMin.value + i
+					local.usages = append(local.usages, localUsage{value, usage.extended})
+				}
+				local.usageMinimum = nil
+			}
+		}
+		offset += 1 + size
+	}
+	if collection != nil {
+		return nil, fmt.Errorf("hid: report descriptor ends inside a collection")
+	}
+	return rd, nil
+}
+
+func newField(
+	reportType ReportType,
+	flags MainFlags,
+	global globalState,
+	local localState,
+	collection *Collection,
+) (*Field, error) {
+	if global.reportSize > 32 && !flags.BufferedBytes() {
+		return nil, fmt.Errorf("report size %d exceeds 32 bits", global.reportSize)
+	}
+	if global.reportSize > maxReportBits || global.reportCount > maxReportBits {
+		return nil, fmt.Errorf(
+			"report size %d and count %d are too large",
+			global.reportSize,
+			global.reportCount,
+		)
+	}
+	field := &Field{
+		Type:            reportType,
+		ReportID:        global.reportID,
+		Flags:           flags,
+		BitSize:         global.reportSize,
+		Count:           global.reportCount,
+		LogicalMinimum:  global.logicalMinimum,
+		LogicalMaximum:  global.logicalMaximum,
+		PhysicalMinimum: global.physicalMinimum,
+		PhysicalMaximum: global.physicalMaximum,
+		Unit:            global.unit,
+		UnitExponent:    global.unitExponent,
+		Collection:      collection,
+	}
+	// Descriptors commonly encode an unsigned maximum such as 255 in a
+	// single byte, which reads as -1 when sign extended.
+	if field.LogicalMinimum >= 0 && field.LogicalMaximum < field.LogicalMinimum {
+		field.LogicalMaximum = int32(global.logicalMaximumUnsigned)
+	}
+	if field.PhysicalMinimum >= 0 && field.PhysicalMaximum < field.PhysicalMinimum {
+		field.PhysicalMaximum = int32(global.physicalMaximumUnsigned)
+	}
+	for _, usage := range local.usages {
+		field.Usages = append(field.Usages, usage.resolve(global.usagePage))
+	}
+	return field, nil
+}
+
+func itemUnsigned(data []byte) uint32 {
+	switch len(data) {
+	case 1:
+		return uint32(data[0])
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(data))
+	case 4:
+		return binary.LittleEndian.Uint32(data)
+	}
+	return 0
+}
+
+func itemSigned(data []byte) int32 {
+	switch len(data) {
+	case 1:
+		return int32(int8(data[0]))
+	case 2:
+		return int32(int16(binary.LittleEndian.Uint16(data)))
+	case 4:
+		return int32(binary.LittleEndian.Uint32(data))
+	}
+	return 0
+}
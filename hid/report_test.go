@@ -0,0 +1,295 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package hid
+
+import (
+	"slices"
+	"testing"
+)
+
+// bootMouse is the boot protocol mouse report descriptor from Appendix E.10
+// of the HID 1.11 specification.
+var bootMouse = []byte{
+	0x05, 0x01, // Usage Page (Generic Desktop)
+	0x09, 0x02, // Usage (Mouse)
+	0xA1, 0x01, // Collection (Application)
+	0x09, 0x01, //   Usage (Pointer)
+	0xA1, 0x00, //   Collection (Physical)
+	0x05, 0x09, //     Usage Page (Buttons)
+	0x19, 0x01, //     Usage Minimum (1)
+	0x29, 0x03, //     Usage Maximum (3)
+	0x15, 0x00, //     Logical Minimum (0)
+	0x25, 0x01, //     Logical Maximum (1)
+	0x95, 0x03, //     Report Count (3)
+	0x75, 0x01, //     Report Size (1)
+	0x81, 0x02, //     Input (Data, Variable, Absolute)
+	0x95, 0x01, //     Report Count (1)
+	0x75, 0x05, //     Report Size (5)
+	0x81, 0x01, //     Input (Constant)
+	0x05, 0x01, //     Usage Page (Generic Desktop)
+	0x09, 0x30, //     Usage (X)
+	0x09, 0x31, //     Usage (Y)
+	0x15, 0x81, //     Logical Minimum (-127)
+	0x25, 0x7F, //     Logical Maximum (127)
+	0x75, 0x08, //     Report Size (8)
+	0x95, 0x02, //     Report Count (2)
+	0x81, 0x06, //     Input (Data, Variable, Relative)
+	0xC0, //   End Collection
+	0xC0, // End Collection
+}
+
+// relayBoard uses report IDs, a one-byte unsigned logical maximum, and a
+// feature report.
+var relayBoard = []byte{
+	0x06, 0x00, 0xFF, // Usage Page (Vendor Defined 0xFF00)
+	0x09, 0x01, // Usage (1)
+	0xA1, 0x01, // Collection (Application)
+	0x85, 0x01, //   Report ID (1)
+	0x15, 0x00, //   Logical Minimum (0)
+	0x25, 0xFF, //   Logical Maximum (255, encoded as -1)
+	0x75, 0x08, //   Report Size (8)
+	0x95, 0x02, //   Report Count (2)
+	0x09, 0x02, //   Usage (2)
+	0x09, 0x03, //   Usage (3)
+	0x81, 0x02, //   Input (Data, Variable, Absolute)
+	0x09, 0x04, //   Usage (4)
+	0x91, 0x02, //   Output (Data, Variable, Absolute)
+	0x85, 0x02, //   Report ID (2)
+	0xA4,                         //   Push
+	0x27, 0xFF, 0xFF, 0x00, 0x00, //   Logical Maximum (65535)
+	0x75, 0x10, //   Report Size (16)
+	0x95, 0x01, //   Report Count (1)
+	0x0B, 0x30, 0x00, 0x01, 0x00, //   Usage (Generic Desktop X, extended)
+	0xB1, 0x02, //   Feature (Data, Variable, Absolute)
+	0xB4,       //   Pop
+	0x09, 0x05, //   Usage (5)
+	0xB1, 0x02, //   Feature (Data, Variable, Absolute)
+	0xC0, // End Collection
+}
+
+func TestParseBootMouse(t *testing.T) {
+	rd, err := ParseReportDescriptor(bootMouse)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: unexpected error %v", err)
+	}
+	if rd.UsesReportIDs() {
+		t.Error("UsesReportIDs = true, want false")
+	}
+	if len(rd.Fields) != 3 {
+		t.Fatalf("got %d fields, want 3", len(rd.Fields))
+	}
+	buttons, padding, axes := rd.Fields[0], rd.Fields[1], rd.Fields[2]
+	if buttons.BitOffset != 0 || buttons.BitSize != 1 || buttons.Count != 3 {
+		t.Errorf("buttons at %d, %dx%d bits; want 0, 3x1", buttons.BitOffset, buttons.Count, buttons.BitSize)
+	}
+	wantButtons := []Usage{0x00090001, 0x00090002, 0x00090003}
+	if !slices.Equal(buttons.Usages, wantButtons) {
+		t.Errorf("button usages = %v, want %v", buttons.Usages, wantButtons)
+	}
+	if !padding.Flags.Constant() || padding.BitOffset != 3 || padding.BitSize != 5 {
+		t.Errorf("padding = %+v, want constant 5 bits at 3", padding)
+	}
+	if axes.BitOffset != 8 || !axes.Flags.Relative() || !axes.Flags.Variable() {
+		t.Errorf("axes = %+v, want relative variable at bit 8", axes)
+	}
+	if axes.LogicalMinimum != -127 || axes.LogicalMaximum != 127 {
+		t.Errorf("axes logical range = %d..%d, want -127..127", axes.LogicalMinimum, axes.LogicalMaximum)
+	}
+	if axes.Usage(0) != 0x00010030 || axes.Usage(1) != 0x00010031 {
+		t.Errorf("axes usages = %v, want X and Y", axes.Usages)
+	}
+	if axes.Collection == nil || axes.Collection.Type != CollectionPhysical {
+		t.Fatalf("axes collection = %v, want physical", axes.Collection)
+	}
+	app := axes.Collection.Parent
+	if app == nil || app.Type != CollectionApplication || app.Usage != 0x00010002 {
+		t.Errorf("application collection = %+v, want Generic Desktop Mouse", app)
+	}
+	if got := rd.MaxLength(Input); got != 3 {
+		t.Errorf("MaxLength(Input) = %d, want 3", got)
+	}
+
+	report := []byte{0x05, 0xFE, 0x10}
+	testCases := []struct {
+		field    *Field
+		index    int
+		expected int64
+	}{
+		{buttons, 0, 1},
+		{buttons, 1, 0},
+		{buttons, 2, 1},
+		{axes, 0, -2},
+		{axes, 1, 16},
+	}
+	for _, tc := range testCases {
+		got, err := tc.field.Value(report, tc.index)
+		if err != nil {
+			t.Errorf("Value(%d): unexpected error %v", tc.index, err)
+		}
+		if got != tc.expected {
+			t.Errorf("Value(%v, %d) = %d, want %d", tc.field.Usage(tc.index), tc.index, got, tc.expected)
+		}
+	}
+}
+
+func TestParseReportIDs(t *testing.T) {
+	rd, err := ParseReportDescriptor(relayBoard)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: unexpected error %v", err)
+	}
+	if !rd.UsesReportIDs() {
+		t.Error("UsesReportIDs = false, want true")
+	}
+	input := rd.Report(Input, 1)
+	if input == nil || input.Length() != 3 {
+		t.Fatalf("input report 1 = %+v, want 3 bytes", input)
+	}
+	field := input.Fields[0]
+	if field.BitOffset != 8 {
+		t.Errorf("BitOffset = %d, want 8 after the report ID", field.BitOffset)
+	}
+	if field.LogicalMaximum != 255 {
+		t.Errorf("LogicalMaximum = %d, want 255", field.LogicalMaximum)
+	}
+	output := rd.Report(Output, 1)
+	if output == nil || output.Length() != 3 || output.Fields[0].BitOffset != 8 {
+		t.Errorf("output report 1 = %+v, want its own layout starting at bit 8", output)
+	}
+	feature := rd.Report(Feature, 2)
+	if feature == nil || len(feature.Fields) != 2 {
+		t.Fatalf("feature report 2 = %+v, want 2 fields", feature)
+	}
+	extended := feature.Fields[0]
+	if extended.Usage(0) != 0x00010030 {
+		t.Errorf("extended usage = %v, want 0001:0030", extended.Usage(0))
+	}
+	if extended.LogicalMaximum != 0xFFFF || extended.BitSize != 16 {
+		t.Errorf("extended field = %+v, want 16 bits up to 65535", extended)
+	}
+	popped := feature.Fields[1]
+	if popped.BitSize != 8 || popped.LogicalMaximum != 255 || popped.BitOffset != 24 {
+		t.Errorf("field after Pop = %+v, want 8 bits up to 255 at bit 24", popped)
+	}
+	if popped.Usage(0) != 0xFF000005 {
+		t.Errorf("usage after Pop = %v, want ff00:0005", popped.Usage(0))
+	}
+	if rd.Report(Input, 2) != nil {
+		t.Error("Report(Input, 2) = non-nil, want nil")
+	}
+}
+
+func TestFieldSetValue(t *testing.T) {
+	field := &Field{BitOffset: 3, BitSize: 10, Count: 2, LogicalMinimum: -512}
+	report := make([]byte, 3)
+	if err := field.SetValue(report, 0, -3); err != nil {
+		t.Fatalf("SetValue: unexpected error %v", err)
+	}
+	if err := field.SetValue(report, 1, 300); err != nil {
+		t.Fatalf("SetValue: unexpected error %v", err)
+	}
+	for i, want := range []int64{-3, 300} {
+		got, err := field.Value(report, i)
+		if err != nil || got != want {
+			t.Errorf("Value(%d) = %d, %v; want %d", i, got, err, want)
+		}
+	}
+	if report[0]&0x07 != 0 {
+		t.Errorf("SetValue touched the bits below the field: %08b", report[0])
+	}
+	if _, err := field.Value(report, 2); err == nil {
+		t.Error("Value(2): expected out of range error, got nil")
+	}
+	if _, err := field.Value(report[:2], 1); err == nil {
+		t.Error("Value on a short report: expected error, got nil")
+	}
+}
+
+func TestParseReportDescriptorErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated item", []byte{0x05}},
+		{"truncated 4-byte item", []byte{0x07, 0x01, 0x00}},
+		{"unbalanced end collection", []byte{0xC0}},
+		{"unclosed collection", []byte{0xA1, 0x01}},
+		{"pop without push", []byte{0xB4}},
+		{"report ID zero", []byte{0x85, 0x00}},
+		{"usage maximum without minimum", []byte{0x29, 0x03}},
+		{"inverted usage range", []byte{0x19, 0x05, 0x29, 0x01}},
+		{
+			"too many usages",
+			[]byte{
+				0x1B, 0x00, 0x00, 0x00, 0x00, 0x2B, 0xFF, 0xFF, 0x00, 0x00,
+				0x1B, 0x00, 0x00, 0x01, 0x00, 0x2B, 0xFF, 0xFF, 0x01, 0x00,
+			},
+		},
+		{"oversized field", []byte{0x75, 0x40, 0x95, 0x01, 0x81, 0x02}},
+		{"truncated long item", []byte{0xFE, 0x04}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseReportDescriptor(tc.data); err == nil {
+				t.Error("ParseReportDescriptor: expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseUsageRangeAtMaximum(t *testing.T) {
+	// A Usage Minimum/Maximum pair ending at 0xFFFFFFFF, which once
+	// wrapped the expansion loop and never finished.
+	data := []byte{
+		0x1B, 0xF0, 0xFF, 0xFF, 0xFF, // Usage Minimum (0xFFFFFFF0)
+		0x2B, 0xFF, 0xFF, 0xFF, 0xFF, // Usage Maximum (0xFFFFFFFF)
+		0x75, 0x01, // Report Size (1)
+		0x95, 0x10, // Report Count (16)
+		0x81, 0x02, // Input (Data, Variable, Absolute)
+	}
+	rd, err := ParseReportDescriptor(data)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: unexpected error %v", err)
+	}
+	usages := rd.Fields[0].Usages
+	if len(usages) != 16 || usages[0] != 0xFFFFFFF0 || usages[15] != 0xFFFFFFFF {
+		t.Errorf("usages = %v, want 0xFFFFFFF0 to 0xFFFFFFFF", usages)
+	}
+}
+
+func TestParseLongItemSkipped(t *testing.T) {
+	data := append([]byte{0xFE, 0x02, 0x10, 0xAA, 0xBB}, bootMouse...)
+	rd, err := ParseReportDescriptor(data)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: unexpected error %v", err)
+	}
+	if len(rd.Fields) != 3 {
+		t.Errorf("got %d fields, want 3", len(rd.Fields))
+	}
+}
+
+func FuzzParseReportDescriptor(f *testing.F) {
+	f.Add(bootMouse)
+	f.Add(relayBoard)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rd, err := ParseReportDescriptor(data)
+		if err != nil {
+			return
+		}
+		for _, layout := range rd.Reports {
+			report := make([]byte, layout.Length())
+			for _, field := range layout.Fields {
+				if field.BitSize == 0 || field.BitSize > 32 {
+					continue
+				}
+				for i := 0; i < min(field.Count, 8); i++ {
+					if _, err := field.Value(report, i); err != nil {
+						t.Fatalf("Value(%d) within the report layout: %v", i, err)
+					}
+				}
+			}
+		}
+	})
+}
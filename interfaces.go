@@ -69,6 +69,9 @@ type InterfaceDescriptor struct {
 	InterfaceSubClass uint8
 	InterfaceProtocol uint8
 	InterfaceIndex    int
+	// Extra holds any class-specific or vendor-specific descriptors that
+	// follow the interface descriptor, such as CDC functional descriptors.
+	Extra []byte
 	EndpointDescriptors
 }
 
@@ -0,0 +1,109 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbif
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// BulkTransferer is the part of *libusb.DeviceHandle that makes bulk
+// transfers.
+type BulkTransferer interface {
+	BulkTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Reader reads a bulk IN endpoint one packet at a time, so that a timeout
+// can't discard data the device already sent. Bytes that don't fit the
+// caller's buffer are kept for the next Read.
+type Reader struct {
+	handle   BulkTransferer
+	endpoint libusb.EndpointAddress
+
+	mu      sync.Mutex
+	buf     []byte
+	pending []byte
+}
+
+// NewReader returns a Reader of endpoint, whose wMaxPacketSize is
+// packetSize.
+func NewReader(
+	handle BulkTransferer,
+	endpoint libusb.EndpointAddress,
+	packetSize int,
+) *Reader {
+	return &Reader{
+		handle:   handle,
+		endpoint: endpoint,
+		buf:      make([]byte, max(packetSize, 1)),
+	}
+}
+
+// Read copies kept bytes into p. If none are left it first reads packets,
+// each with the timeout in milliseconds, until one isn't empty. Transfer
+// errors are returned as they are. Transfers are made one at a time.
+func (r *Reader) Read(p []byte, timeout int) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		n, err := r.ReadPacket(p, timeout)
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// ReadPacket is Read without the retry, for callers that poll: if no bytes
+// are kept it makes a single transfer, and it returns 0 with no error if
+// that brought a zero-length packet.
+func (r *Reader) ReadPacket(p []byte, timeout int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		n, err := r.handle.BulkTransfer(r.endpoint, r.buf, len(r.buf), timeout)
+		if err != nil {
+			return 0, err
+		}
+		r.pending = r.buf[:n]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Write writes p to a bulk OUT endpoint in one transfer, returning
+// io.ErrShortWrite if the device took less.
+func Write(
+	handle BulkTransferer,
+	endpoint libusb.EndpointAddress,
+	p []byte,
+	timeout int,
+) (int, error) {
+	n, err := handle.BulkTransfer(endpoint, p, len(p), timeout)
+	if err != nil {
+		return n, err
+	}
+	if n != len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// IsTimeout reports whether err is, or wraps, an error whose Timeout method
+// reports true, such as a timed out libusb.ErrorCode.
+func IsTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbif
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// fakeBulk serves IN transfers from a queue of packets, timing out once
+// it is empty, and takes at most outLimit bytes of each OUT transfer.
+type fakeBulk struct {
+	in       [][]byte
+	lengths  []int
+	out      []byte
+	outLimit int
+}
+
+func (fb *fakeBulk) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		n := min(length, fb.outLimit)
+		fb.out = append(fb.out, data[:n]...)
+		return n, nil
+	}
+	fb.lengths = append(fb.lengths, length)
+	if len(fb.in) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	packet := fb.in[0]
+	fb.in = fb.in[1:]
+	return copy(data[:length], packet), nil
+}
+
+func TestReader(t *testing.T) {
+	fb := &fakeBulk{in: [][]byte{[]byte("hello"), []byte("!")}}
+	r := NewReader(fb, 0x81, 8)
+	buf := make([]byte, 3)
+	var got []byte
+	for len(got) < len("hello!") {
+		n, err := r.Read(buf, 100)
+		if err != nil {
+			t.Fatalf("Read: unexpected error %v after %q", err, got)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "hello!" {
+		t.Errorf("Read = %q, want %q", got, "hello!")
+	}
+	for i, length := range fb.lengths {
+		if length != 8 {
+			t.Errorf("transfer %d requested %d bytes, want one packet of 8", i, length)
+		}
+	}
+	if n, err := r.Read(nil, 100); n != 0 || err != nil {
+		t.Errorf("Read(nil) = %d, %v; want 0, nil", n, err)
+	}
+	if _, err := r.Read(buf, 100); !IsTimeout(err) {
+		t.Errorf("Read with nothing queued: got %v, want a timeout", err)
+	}
+}
+
+func TestReaderZeroLengthPackets(t *testing.T) {
+	fb := &fakeBulk{in: [][]byte{{}, {}, []byte("ok")}}
+	r := NewReader(fb, 0x81, 8)
+	buf := make([]byte, 8)
+	if n, err := r.ReadPacket(buf, 100); n != 0 || err != nil {
+		t.Errorf("ReadPacket of a zero-length packet = %d, %v; want 0, nil", n, err)
+	}
+	n, err := r.Read(buf, 100)
+	if err != nil || string(buf[:n]) != "ok" {
+		t.Errorf("Read = %q, %v; want %q, nil", buf[:n], err, "ok")
+	}
+	if len(fb.lengths) != 3 {
+		t.Errorf("made %d transfers, want 3", len(fb.lengths))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	fb := &fakeBulk{outLimit: 4}
+	if n, err := Write(fb, 0x02, []byte("abcd"), 100); n != 4 || err != nil {
+		t.Errorf("Write = %d, %v; want 4, nil", n, err)
+	}
+	if n, err := Write(fb, 0x02, []byte("abcdef"), 100); n != 4 || err != io.ErrShortWrite {
+		t.Errorf("short Write = %d, %v; want 4, io.ErrShortWrite", n, err)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{libusb.ErrTimeout, true},
+		{fmt.Errorf("wrapped: %w", libusb.ErrTimeout), true},
+		{os.ErrDeadlineExceeded, true},
+		{libusb.ErrIO, false},
+		{errors.New("other"), false},
+	}
+	for _, tc := range testCases {
+		if got := IsTimeout(tc.err); got != tc.want {
+			t.Errorf("IsTimeout(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package usbif holds the plumbing the class driver packages share: claiming
+interfaces away from kernel drivers and giving them back, and moving data
+over a bulk endpoint pair.
+*/
+package usbif
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Claimer is the part of *libusb.DeviceHandle that claims interfaces.
+type Claimer interface {
+	ClaimInterface(interfaceNum int) error
+	ReleaseInterface(interfaceNum int) error
+	KernelDriverActive(interfaceNum int) (bool, error)
+	DetachKernelDriver(interfaceNum int) error
+	AttachKernelDriver(interfaceNum int) error
+}
+
+// Claims records the interfaces claimed on a handle and the kernel drivers
+// detached from them, so that Release can give them all back.
+type Claims struct {
+	handle   Claimer
+	prefix   string
+	claimed  []int
+	detached []int
+}
+
+// NewClaims returns an empty set of claims on handle. Errors start with
+// prefix, the name of the class driver package.
+func NewClaims(handle Claimer, prefix string) *Claims {
+	return &Claims{handle: handle, prefix: prefix}
+}
+
+// Claim returns the claims after claiming a single interface, or the error
+// if it couldn't be claimed, in which case nothing is left claimed.
+func Claim(handle Claimer, prefix string, num int) (*Claims, error) {
+	claims := NewClaims(handle, prefix)
+	if err := claims.Claim(num); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Claim claims an interface, detaching its kernel driver first if one is
+// bound. If the claim fails, the driver is reattached.
+func (c *Claims) Claim(num int) error {
+	detached := false
+	if active, err := c.handle.KernelDriverActive(num); err == nil && active {
+		if err := c.handle.DetachKernelDriver(num); err != nil {
+			return fmt.Errorf(
+				"%s: detaching kernel driver from interface %d: %w",
+				c.prefix,
+				num,
+				err,
+			)
+		}
+		detached = true
+	}
+	if err := c.handle.ClaimInterface(num); err != nil {
+		err = fmt.Errorf("%s: claiming interface %d: %w", c.prefix, num, err)
+		if detached {
+			err = errors.Join(err, c.handle.AttachKernelDriver(num))
+		}
+		return err
+	}
+	c.claimed = append(c.claimed, num)
+	if detached {
+		c.detached = append(c.detached, num)
+	}
+	return nil
+}
+
+// Release releases the claimed interfaces and then reattaches the kernel
+// drivers that Claim detached, both last claimed first. Every step is
+// tried, and the errors are joined.
+func (c *Claims) Release() error {
+	var errs []error
+	for i := len(c.claimed) - 1; i >= 0; i-- {
+		if err := c.handle.ReleaseInterface(c.claimed[i]); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"%s: releasing interface %d: %w",
+				c.prefix,
+				c.claimed[i],
+				err,
+			))
+		}
+	}
+	c.claimed = nil
+	for i := len(c.detached) - 1; i >= 0; i-- {
+		if err := c.handle.AttachKernelDriver(c.detached[i]); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"%s: reattaching kernel driver to interface %d: %w",
+				c.prefix,
+				c.detached[i],
+				err,
+			))
+		}
+	}
+	c.detached = nil
+	return errors.Join(errs...)
+}
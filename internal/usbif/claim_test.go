@@ -0,0 +1,118 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbif
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+var errFailed = errors.New("failed")
+
+func TestClaims(t *testing.T) {
+	testCases := []struct {
+		name    string
+		active  bool
+		fail    map[string]error
+		claim   []int
+		release bool
+		wantErr bool
+		want    []string
+	}{
+		{
+			name:    "no kernel drivers",
+			claim:   []int{0, 1},
+			release: true,
+			want:    []string{"claim 0", "claim 1", "release 1", "release 0"},
+		},
+		{
+			name:    "drivers reattached after releasing",
+			active:  true,
+			claim:   []int{0, 1},
+			release: true,
+			want: []string{
+				"detach 0", "claim 0", "detach 1", "claim 1",
+				"release 1", "release 0", "attach 1", "attach 0",
+			},
+		},
+		{
+			name:    "failed claim reattaches its driver",
+			active:  true,
+			fail:    map[string]error{"claim 2": errFailed},
+			claim:   []int{2},
+			wantErr: true,
+			want:    []string{"detach 2", "claim 2", "attach 2"},
+		},
+		{
+			name:    "failed detach claims nothing",
+			active:  true,
+			fail:    map[string]error{"detach 2": errFailed},
+			claim:   []int{2},
+			wantErr: true,
+			want:    []string{"detach 2"},
+		},
+		{
+			name:    "release keeps going after an error",
+			active:  true,
+			fail:    map[string]error{"release 0": errFailed},
+			claim:   []int{0},
+			release: true,
+			wantErr: true,
+			want:    []string{"detach 0", "claim 0", "release 0", "attach 0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &usbiftest.Claimer{Active: tc.active, Fail: tc.fail}
+			claims := NewClaims(fc, "test")
+			var errs []error
+			for _, num := range tc.claim {
+				errs = append(errs, claims.Claim(num))
+			}
+			if tc.release {
+				errs = append(errs, claims.Release())
+			}
+			if err := errors.Join(errs...); (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, want error %t", err, tc.wantErr)
+			}
+			if calls := fc.Calls(); !slices.Equal(calls, tc.want) {
+				t.Errorf("calls = %q, want %q", calls, tc.want)
+			}
+		})
+	}
+}
+
+func TestClaimReleaseTwice(t *testing.T) {
+	fc := &usbiftest.Claimer{Active: true}
+	claims, err := Claim(fc, "test", 3)
+	if err != nil {
+		t.Fatalf("Claim: unexpected error %v", err)
+	}
+	if err := claims.Release(); err != nil {
+		t.Fatalf("Release: unexpected error %v", err)
+	}
+	if err := claims.Release(); err != nil {
+		t.Fatalf("second Release: unexpected error %v", err)
+	}
+	want := []string{"detach 3", "claim 3", "release 3", "attach 3"}
+	if calls := fc.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestClaimError(t *testing.T) {
+	fc := &usbiftest.Claimer{Fail: map[string]error{"claim 4": errFailed}}
+	claims, err := Claim(fc, "test", 4)
+	if err == nil || claims != nil {
+		t.Fatalf("Claim = %v, %v; want an error", claims, err)
+	}
+	if want := "test: claiming interface 4: failed"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+// Package usbiftest provides the fake interface claiming that the class
+// driver packages' fake handles embed.
+package usbiftest
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Claimer is a fake usbif.Claimer that records each call, such as
+// "claim 1" or "detach 1". A fake handle that embeds it records its own
+// calls with Record, so that a test sees them all in order.
+type Claimer struct {
+	// Active has KernelDriverActive report a kernel driver bound to every
+	// interface.
+	Active bool
+	// Fail makes the calls it holds, keyed like the recorded calls, fail
+	// with their error.
+	Fail map[string]error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// Record records a call. It is safe to call from several goroutines.
+func (c *Claimer) Record(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, fmt.Sprintf(format, args...))
+}
+
+// Calls returns the calls recorded so far.
+func (c *Claimer) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.calls)
+}
+
+// Reset forgets the calls recorded so far.
+func (c *Claimer) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+func (c *Claimer) call(name string, num int) error {
+	c.Record("%s %d", name, num)
+	return c.Fail[fmt.Sprintf("%s %d", name, num)]
+}
+
+// ClaimInterface records "claim num".
+func (c *Claimer) ClaimInterface(num int) error {
+	return c.call("claim", num)
+}
+
+// ReleaseInterface records "release num".
+func (c *Claimer) ReleaseInterface(num int) error {
+	return c.call("release", num)
+}
+
+// KernelDriverActive reports Active without recording a call.
+func (c *Claimer) KernelDriverActive(num int) (bool, error) {
+	return c.Active, nil
+}
+
+// DetachKernelDriver records "detach num".
+func (c *Claimer) DetachKernelDriver(num int) error {
+	return c.call("detach", num)
+}
+
+// AttachKernelDriver records "attach num".
+func (c *Claimer) AttachKernelDriver(num int) error {
+	return c.call("attach", num)
+}
@@ -0,0 +1,639 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+// #cgo pkg-config: libusb-1.0
+// #include <libusb.h>
+// #include <stdlib.h>
+// #include <string.h>
+//
+// static void LIBUSB_CALL libusb_iso_transfer_done(struct libusb_transfer *transfer) {
+// 	*(int *)transfer->user_data = 1;
+// }
+//
+// static int libusb_transfer_status_error(enum libusb_transfer_status status) {
+// 	switch (status) {
+// 	case LIBUSB_TRANSFER_COMPLETED:
+// 		return LIBUSB_SUCCESS;
+// 	case LIBUSB_TRANSFER_TIMED_OUT:
+// 		return LIBUSB_ERROR_TIMEOUT;
+// 	case LIBUSB_TRANSFER_STALL:
+// 		return LIBUSB_ERROR_PIPE;
+// 	case LIBUSB_TRANSFER_NO_DEVICE:
+// 		return LIBUSB_ERROR_NO_DEVICE;
+// 	case LIBUSB_TRANSFER_OVERFLOW:
+// 		return LIBUSB_ERROR_OVERFLOW;
+// 	default:
+// 		return LIBUSB_ERROR_IO;
+// 	}
+// }
+//
+// // libusb_iso_wait_completed handles events until *completed is set.
+// // Interrupted or timed out event handling is retried; any other error is
+// // returned with the transfer possibly still in flight.
+// static int libusb_iso_wait_completed(libusb_context *ctx, int *completed) {
+// 	int r;
+// 	while (!*completed) {
+// 		r = libusb_handle_events_completed(ctx, completed);
+// 		if (r < 0 && r != LIBUSB_ERROR_INTERRUPTED && r != LIBUSB_ERROR_TIMEOUT) {
+// 			return r;
+// 		}
+// 	}
+// 	return LIBUSB_SUCCESS;
+// }
+//
+// // libusb_iso_transfer_sync submits an isochronous transfer and handles
+// // events until it completes, the way libusb's own synchronous transfers
+// // wait. The transfer uses a copy of buffer, so that no Go memory is left
+// // with libusb if it has to be abandoned. The packet results are stored in
+// // actual_lengths and errors.
+// static int libusb_iso_transfer_sync(
+// 	libusb_context *ctx, libusb_device_handle *handle, unsigned char endpoint,
+// 	unsigned char *buffer, int length, int num_packets, const int *packet_lengths,
+// 	unsigned int timeout, int *actual_lengths, int *errors) {
+// 	int completed = 0;
+// 	int i, r;
+// 	unsigned char *copy;
+// 	struct libusb_transfer *transfer = libusb_alloc_transfer(num_packets);
+// 	if (transfer == NULL) {
+// 		return LIBUSB_ERROR_NO_MEM;
+// 	}
+// 	copy = malloc(length > 0 ? length : 1);
+// 	if (copy == NULL) {
+// 		libusb_free_transfer(transfer);
+// 		return LIBUSB_ERROR_NO_MEM;
+// 	}
+// 	if ((endpoint & LIBUSB_ENDPOINT_IN) == 0) {
+// 		memcpy(copy, buffer, length);
+// 	}
+// 	libusb_fill_iso_transfer(transfer, handle, endpoint, copy, length, num_packets,
+// 		libusb_iso_transfer_done, &completed, timeout);
+// 	for (i = 0; i < num_packets; i++) {
+// 		transfer->iso_packet_desc[i].length = packet_lengths[i];
+// 	}
+// 	r = libusb_submit_transfer(transfer);
+// 	if (r < 0) {
+// 		free(copy);
+// 		libusb_free_transfer(transfer);
+// 		return r;
+// 	}
+// 	r = libusb_iso_wait_completed(ctx, &completed);
+// 	if (r < 0) {
+// 		libusb_cancel_transfer(transfer);
+// 		if (libusb_iso_wait_completed(ctx, &completed) < 0) {
+// 			// The transfer may still be in flight, and freeing it would
+// 			// pull it out from under libusb, so it is leaked instead.
+// 			return r;
+// 		}
+// 		free(copy);
+// 		libusb_free_transfer(transfer);
+// 		return r;
+// 	}
+// 	if (endpoint & LIBUSB_ENDPOINT_IN) {
+// 		memcpy(buffer, copy, length);
+// 	}
+// 	for (i = 0; i < num_packets; i++) {
+// 		actual_lengths[i] = transfer->iso_packet_desc[i].actual_length;
+// 		errors[i] = libusb_transfer_status_error(transfer->iso_packet_desc[i].status);
+// 	}
+// 	r = libusb_transfer_status_error(transfer->status);
+// 	free(copy);
+// 	libusb_free_transfer(transfer);
+// 	return r;
+// }
+//
+// // libusb_iso_stream is a ring of isochronous transfers with their own
+// // buffers, so that the next transfer is already queued when one completes.
+// typedef struct {
+// 	libusb_context *ctx;
+// 	struct libusb_transfer **transfers;
+// 	int *completed;
+// 	int count;
+// } libusb_iso_stream;
+//
+// static void libusb_iso_stream_free(libusb_iso_stream *s) {
+// 	int i;
+// 	for (i = 0; i < s->count; i++) {
+// 		if (s->transfers[i] != NULL) {
+// 			free(s->transfers[i]->buffer);
+// 			libusb_free_transfer(s->transfers[i]);
+// 		}
+// 	}
+// 	free(s->transfers);
+// 	free(s->completed);
+// 	free(s);
+// }
+//
+// static libusb_iso_stream *libusb_iso_stream_alloc(
+// 	libusb_context *ctx, libusb_device_handle *handle, unsigned char endpoint,
+// 	int count, int num_packets, int buffer_length, unsigned int timeout) {
+// 	int i;
+// 	libusb_iso_stream *s = calloc(1, sizeof(libusb_iso_stream));
+// 	if (s == NULL) {
+// 		return NULL;
+// 	}
+// 	s->ctx = ctx;
+// 	s->count = count;
+// 	s->transfers = calloc(count, sizeof(struct libusb_transfer *));
+// 	s->completed = calloc(count, sizeof(int));
+// 	if (s->transfers == NULL || s->completed == NULL) {
+// 		libusb_iso_stream_free(s);
+// 		return NULL;
+// 	}
+// 	for (i = 0; i < count; i++) {
+// 		unsigned char *buffer = malloc(buffer_length);
+// 		s->transfers[i] = libusb_alloc_transfer(num_packets);
+// 		if (buffer == NULL || s->transfers[i] == NULL) {
+// 			if (s->transfers[i] == NULL) {
+// 				free(buffer);
+// 			} else {
+// 				s->transfers[i]->buffer = buffer;
+// 			}
+// 			libusb_iso_stream_free(s);
+// 			return NULL;
+// 		}
+// 		libusb_fill_iso_transfer(s->transfers[i], handle, endpoint, buffer, buffer_length,
+// 			num_packets, libusb_iso_transfer_done, &s->completed[i], timeout);
+// 		s->completed[i] = 1;
+// 	}
+// 	return s;
+// }
+//
+// // libusb_iso_stream_submit queues transfer i. A transfer that is still
+// // queued is refused before its completed flag is touched, so the flag
+// // only changes for a transfer this call queues.
+// static int libusb_iso_stream_submit(libusb_iso_stream *s, int i) {
+// 	int r;
+// 	if (!s->completed[i]) {
+// 		return LIBUSB_ERROR_BUSY;
+// 	}
+// 	s->completed[i] = 0;
+// 	r = libusb_submit_transfer(s->transfers[i]);
+// 	if (r < 0) {
+// 		s->completed[i] = 1;
+// 	}
+// 	return r;
+// }
+//
+// // libusb_iso_stream_wait waits for transfer i to complete and stores its
+// // outcome in status. It returns an error only if events could not be
+// // handled, in which case the transfer may still be queued.
+// static int libusb_iso_stream_wait(libusb_iso_stream *s, int i, int *status) {
+// 	int r = libusb_iso_wait_completed(s->ctx, &s->completed[i]);
+// 	if (r < 0) {
+// 		return r;
+// 	}
+// 	*status = libusb_transfer_status_error(s->transfers[i]->status);
+// 	return LIBUSB_SUCCESS;
+// }
+//
+// // libusb_iso_stream_cancel cancels the transfers still queued and waits
+// // for them to finish. The stream can be freed only if it returns success;
+// // otherwise a transfer may still be in flight.
+// static int libusb_iso_stream_cancel(libusb_iso_stream *s) {
+// 	int i, r;
+// 	for (i = 0; i < s->count; i++) {
+// 		if (!s->completed[i]) {
+// 			libusb_cancel_transfer(s->transfers[i]);
+// 		}
+// 	}
+// 	for (i = 0; i < s->count; i++) {
+// 		r = libusb_iso_wait_completed(s->ctx, &s->completed[i]);
+// 		if (r < 0) {
+// 			return r;
+// 		}
+// 	}
+// 	return LIBUSB_SUCCESS;
+// }
+//
+// static unsigned char *libusb_iso_stream_buffer(libusb_iso_stream *s, int i) {
+// 	return s->transfers[i]->buffer;
+// }
+//
+// static void libusb_iso_stream_set_packets(
+// 	libusb_iso_stream *s, int i, int num_packets, const int *packet_lengths) {
+// 	int j, length = 0;
+// 	struct libusb_transfer *transfer = s->transfers[i];
+// 	transfer->num_iso_packets = num_packets;
+// 	for (j = 0; j < num_packets; j++) {
+// 		transfer->iso_packet_desc[j].length = packet_lengths[j];
+// 		length += packet_lengths[j];
+// 	}
+// 	transfer->length = length;
+// }
+//
+// static void libusb_iso_stream_results(
+// 	libusb_iso_stream *s, int i, int *actual_lengths, int *errors) {
+// 	int j;
+// 	struct libusb_transfer *transfer = s->transfers[i];
+// 	for (j = 0; j < transfer->num_iso_packets; j++) {
+// 		actual_lengths[j] = transfer->iso_packet_desc[j].actual_length;
+// 		errors[j] = libusb_transfer_status_error(transfer->iso_packet_desc[j].status);
+// 	}
+// }
+import "C"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// maxIsoPackets bounds the packets of one isochronous transfer. Class
+// drivers queue a few milliseconds of packets at a time, so the limit is
+// only a guard against runaway allocations.
+const maxIsoPackets = 4096
+
+// IsoPacket is one packet of an isochronous transfer.
+type IsoPacket struct {
+	// Offset is where the packet starts in the transfer buffer. Packets are
+	// laid out back to back at their requested lengths.
+	Offset int
+	// Length is the number of bytes requested for the packet.
+	Length int
+	// ActualLength is the number of bytes transferred. For IN packets the
+	// data is at buffer[Offset:Offset+ActualLength].
+	ActualLength int
+	// Err is nil if the packet completed, or the ErrorCode it failed with.
+	// Isochronous packets are not retried, so a failed packet is lost.
+	Err error
+}
+
+// IsochronousTransfer performs a synchronous isochronous transfer of one
+// packet per entry in packetLengths, laid out back to back in data. It
+// returns when every packet has been scheduled, one per (micro)frame or as
+// the endpoint's interval says, or when the timeout in milliseconds
+// expires; zero waits forever.
+//
+// The outcome of each packet, including its length, is in the returned
+// IsoPacket slice. An error means the transfer as a whole failed or timed
+// out; the packets that completed before it did are still returned.
+func (dh *DeviceHandle) IsochronousTransfer(
+	endpoint EndpointAddress,
+	data []byte,
+	packetLengths []int,
+	timeout int,
+) ([]IsoPacket, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	if len(packetLengths) == 0 || len(packetLengths) > maxIsoPackets {
+		return nil, fmt.Errorf(
+			"isochronous transfer of %d packets: %w",
+			len(packetLengths),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	total := 0
+	for _, length := range packetLengths {
+		if err := checkLengthRange(length, maxTransferLength); err != nil {
+			return nil, err
+		}
+		total += length
+	}
+	if err := checkTransferLength(data, total, maxTransferLength); err != nil {
+		return nil, err
+	}
+	packets, err := dh.transferer.isochronous(endpoint, data[:total], packetLengths, timeout)
+	for _, packet := range packets {
+		if packet.ActualLength > packet.Length {
+			return nil, fmt.Errorf(
+				"isochronous packet reported %d bytes transferred of %d: %w",
+				packet.ActualLength,
+				packet.Length,
+				ErrorCode(errorOverflow),
+			)
+		}
+	}
+	return packets, err
+}
+
+func (lt libusbTransferer) isochronous(
+	endpoint EndpointAddress,
+	data []byte,
+	packetLengths []int,
+	timeout int,
+) ([]IsoPacket, error) {
+	n := len(packetLengths)
+	lengths := make([]C.int, n)
+	for i, length := range packetLengths {
+		lengths[i] = C.int(length)
+	}
+	actual := make([]C.int, n)
+	errs := make([]C.int, n)
+	ret := C.libusb_iso_transfer_sync(
+		lt.context,
+		lt.handle,
+		C.uchar(endpoint),
+		bufferPointer(data),
+		C.int(len(data)),
+		C.int(n),
+		&lengths[0],
+		C.uint(timeout),
+		&actual[0],
+		&errs[0],
+	)
+	packets := make([]IsoPacket, n)
+	offset := 0
+	for i, length := range packetLengths {
+		packets[i] = IsoPacket{
+			Offset:       offset,
+			Length:       length,
+			ActualLength: int(actual[i]),
+		}
+		if errs[i] != 0 {
+			packets[i].Err = ErrorCode(errs[i])
+		}
+		offset += length
+	}
+	if ret < 0 {
+		return packets, ErrorCode(ret)
+	}
+	return packets, nil
+}
+
+// IsoStream keeps a ring of isochronous transfers queued on an endpoint.
+// A synchronous transfer leaves the endpoint idle between one transfer and
+// the next, and the packets of those (micro)frames are lost; video and
+// audio streams instead need the next transfer queued before the current
+// one completes, which IsoStream does with buffers of its own.
+//
+// An IsoStream is used from one goroutine at a time and is either read
+// (IN endpoints) or written (OUT endpoints), never both.
+type IsoStream struct {
+	mu            sync.Mutex
+	stream        *C.libusb_iso_stream
+	endpoint      EndpointAddress
+	packetLengths []int
+	total         int
+	count         int
+	head          int
+	queued        int
+	started       bool
+	// submitted holds the packet lengths each transfer was queued with, and
+	// is nil for a transfer that isn't queued.
+	submitted [][]int
+}
+
+// IsochronousStream allocates transfers of one packet per entry in
+// packetLengths on the endpoint, each with the timeout in milliseconds. An
+// IN stream queues all transfers on the first Read; an OUT stream queues
+// each transfer as Write fills it. Close cancels what is still queued and
+// frees the transfers.
+func (dh *DeviceHandle) IsochronousStream(
+	endpoint EndpointAddress,
+	packetLengths []int,
+	transfers int,
+	timeout int,
+) (*IsoStream, error) {
+	if dh == nil || dh.libusbDeviceHandle == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	if len(packetLengths) == 0 || len(packetLengths) > maxIsoPackets || transfers < 1 {
+		return nil, fmt.Errorf(
+			"isochronous stream of %d transfers of %d packets: %w",
+			transfers,
+			len(packetLengths),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	total := 0
+	for _, length := range packetLengths {
+		if err := checkLengthRange(length, maxTransferLength); err != nil {
+			return nil, err
+		}
+		total += length
+	}
+	if err := checkLengthRange(total, maxTransferLength); err != nil {
+		return nil, err
+	}
+	lt, ok := dh.transferer.(libusbTransferer)
+	if !ok {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	stream := C.libusb_iso_stream_alloc(
+		lt.context,
+		dh.libusbDeviceHandle,
+		C.uchar(endpoint),
+		C.int(transfers),
+		C.int(len(packetLengths)),
+		C.int(total),
+		C.uint(timeout),
+	)
+	if stream == nil {
+		return nil, ErrorCode(errorNoMem)
+	}
+	return &IsoStream{
+		stream:        stream,
+		endpoint:      endpoint,
+		packetLengths: slices.Clone(packetLengths),
+		total:         total,
+		count:         transfers,
+		submitted:     make([][]int, transfers),
+	}, nil
+}
+
+// Read waits for the oldest queued transfer of an IN stream, copies its
+// packets into data, which must hold the sum of the packet lengths, and
+// queues the transfer again. The packets are laid out in data as for
+// IsochronousTransfer.
+func (s *IsoStream) Read(data []byte) ([]IsoPacket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return nil, os.ErrClosed
+	}
+	if s.endpoint.direction() != EndpointIn {
+		return nil, fmt.Errorf(
+			"read from OUT endpoint %#02x: %w",
+			uint8(s.endpoint),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	if err := checkTransferLength(data, s.total, maxTransferLength); err != nil {
+		return nil, err
+	}
+	if !s.started {
+		for i := 0; i < s.count; i++ {
+			if err := s.submit(i, s.packetLengths); err != nil {
+				return nil, errors.Join(err, s.cancel())
+			}
+		}
+		s.started = true
+	}
+	for s.submitted[s.head] == nil {
+		// An earlier Read failed to queue this transfer again. Queued now,
+		// it is behind the others, so they are read first.
+		if err := s.submit(s.head, s.packetLengths); err != nil {
+			return nil, err
+		}
+		s.head = (s.head + 1) % s.count
+	}
+	i := s.head
+	packets, err := s.wait(i)
+	if err != nil && packets == nil {
+		return nil, err
+	}
+	copy(data, unsafe.Slice((*byte)(C.libusb_iso_stream_buffer(s.stream, C.int(i))), s.total))
+	if serr := s.submit(i, s.packetLengths); serr != nil && err == nil {
+		err = serr
+	}
+	return packets, err
+}
+
+// Write queues data as one transfer of an OUT stream, one packet per entry
+// in packetLengths, which may differ from the lengths the stream was
+// allocated with but not outnumber them. Once every transfer is queued,
+// Write first waits for the oldest to complete and returns its error, if
+// any, after queuing data in its place. Rate-adaptive audio uses this to
+// vary the packet sizes with the feedback endpoint.
+func (s *IsoStream) Write(data []byte, packetLengths []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return os.ErrClosed
+	}
+	if s.endpoint.direction() == EndpointIn {
+		return fmt.Errorf(
+			"write to IN endpoint %#02x: %w",
+			uint8(s.endpoint),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	if len(packetLengths) == 0 || len(packetLengths) > len(s.packetLengths) {
+		return fmt.Errorf(
+			"write of %d packets to a stream of %d: %w",
+			len(packetLengths),
+			len(s.packetLengths),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	total := 0
+	for _, length := range packetLengths {
+		if length < 0 {
+			return ErrorCode(errorInvalidParam)
+		}
+		total += length
+	}
+	if total > s.total {
+		return fmt.Errorf(
+			"write of %d bytes to a stream of %d: %w",
+			total,
+			s.total,
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	if err := checkTransferLength(data, total, maxTransferLength); err != nil {
+		return err
+	}
+	var err error
+	i := (s.head + s.queued) % s.count
+	if s.queued == s.count {
+		var packets []IsoPacket
+		if packets, err = s.wait(i); packets == nil {
+			return err
+		}
+	}
+	copy(unsafe.Slice((*byte)(C.libusb_iso_stream_buffer(s.stream, C.int(i))), total), data)
+	if serr := s.submit(i, packetLengths); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// Close cancels the transfers still queued, waits for them, and frees the
+// stream. An OUT stream that should play out first is drained by the
+// caller's timing, not by Close. If events can't be handled while waiting,
+// the stream is left allocated, as a transfer may still be in flight, and
+// the error is returned.
+func (s *IsoStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return os.ErrClosed
+	}
+	ret := C.libusb_iso_stream_cancel(s.stream)
+	if ret == C.LIBUSB_SUCCESS {
+		C.libusb_iso_stream_free(s.stream)
+	}
+	s.stream = nil
+	if ret < 0 {
+		return fmt.Errorf("cancelling isochronous stream: %w", ErrorCode(ret))
+	}
+	return nil
+}
+
+// cancel cancels the queued transfers and waits for them, leaving the
+// stream as IsochronousStream allocated it. If events can't be handled
+// while waiting, the transfers are left as they are.
+func (s *IsoStream) cancel() error {
+	if ret := C.libusb_iso_stream_cancel(s.stream); ret < 0 {
+		return fmt.Errorf("cancelling isochronous stream: %w", ErrorCode(ret))
+	}
+	clear(s.submitted)
+	s.head = 0
+	s.queued = 0
+	return nil
+}
+
+// submit queues transfer i, which is the one after the last queued.
+func (s *IsoStream) submit(i int, packetLengths []int) error {
+	if s.submitted[i] != nil {
+		return fmt.Errorf("isochronous transfer %d is still queued: %w", i, ErrorCode(errorBusy))
+	}
+	lengths := make([]C.int, len(packetLengths))
+	for j, length := range packetLengths {
+		lengths[j] = C.int(length)
+	}
+	C.libusb_iso_stream_set_packets(s.stream, C.int(i), C.int(len(lengths)), &lengths[0])
+	if ret := C.libusb_iso_stream_submit(s.stream, C.int(i)); ret < 0 {
+		return ErrorCode(ret)
+	}
+	s.submitted[i] = slices.Clone(packetLengths)
+	s.queued++
+	return nil
+}
+
+// wait waits for transfer i, the oldest queued, and returns its packets
+// with the transfer's error, if any. The packets are nil if events could
+// not be handled, as the transfer may then still be queued; it is left to
+// Close.
+func (s *IsoStream) wait(i int) ([]IsoPacket, error) {
+	var status C.int
+	if ret := C.libusb_iso_stream_wait(s.stream, C.int(i), &status); ret < 0 {
+		return nil, ErrorCode(ret)
+	}
+	lengths := s.submitted[i]
+	s.submitted[i] = nil
+	s.head = (s.head + 1) % s.count
+	s.queued--
+	// Write may have queued fewer packets than the stream was allocated
+	// with, so only those are reported.
+	n := len(lengths)
+	actual := make([]C.int, n)
+	errs := make([]C.int, n)
+	C.libusb_iso_stream_results(s.stream, C.int(i), &actual[0], &errs[0])
+	packets := make([]IsoPacket, 0, n)
+	offset := 0
+	for j, length := range lengths {
+		packet := IsoPacket{
+			Offset:       offset,
+			Length:       length,
+			ActualLength: min(int(actual[j]), length),
+		}
+		if errs[j] != 0 {
+			packet.Err = ErrorCode(errs[j])
+		}
+		packets = append(packets, packet)
+		offset += length
+	}
+	if status < 0 {
+		return packets, ErrorCode(status)
+	}
+	return packets, nil
+}
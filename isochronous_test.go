@@ -0,0 +1,108 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestIsochronousTransfer(t *testing.T) {
+	ft := &fakeTransferer{response: []byte{1, 2, 3}}
+	dh := newFakeDeviceHandle(ft)
+	data := make([]byte, 20)
+	packets, err := dh.IsochronousTransfer(0x81, data, []int{8, 2, 8}, 100)
+	if err != nil {
+		t.Fatalf("IsochronousTransfer: unexpected error %v", err)
+	}
+	want := []IsoPacket{
+		{Offset: 0, Length: 8, ActualLength: 3},
+		{Offset: 8, Length: 2, ActualLength: 2},
+		{Offset: 10, Length: 8, ActualLength: 3},
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("got %d packets, want %d", len(packets), len(want))
+	}
+	for i, packet := range packets {
+		if packet != want[i] {
+			t.Errorf("packet %d = %+v, want %+v", i, packet, want[i])
+		}
+	}
+	if !bytes.Equal(data[10:13], []byte{1, 2, 3}) {
+		t.Errorf("packet 2 data = % x", data[10:13])
+	}
+	if got := len(ft.transfers[0].data); got != 18 {
+		t.Errorf("transfer buffer is %d bytes, want the 18 the packets use", got)
+	}
+}
+
+func TestIsochronousTransferValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		lengths []int
+	}{
+		{"no packets", make([]byte, 8), nil},
+		{"negative packet length", make([]byte, 8), []int{4, -1}},
+		{"packets exceed buffer", make([]byte, 8), []int{4, 8}},
+		{"too many packets", make([]byte, 8), make([]int, maxIsoPackets+1)},
+	}
+	for _, tc := range testCases {
+		ft := &fakeTransferer{}
+		dh := newFakeDeviceHandle(ft)
+		if _, err := dh.IsochronousTransfer(0x01, tc.data, tc.lengths, 0); err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+		if len(ft.transfers) != 0 {
+			t.Errorf("%s: transfer reached the transferer", tc.name)
+		}
+	}
+
+	dh := newFakeDeviceHandle(&fakeTransferer{overreport: 1})
+	if _, err := dh.IsochronousTransfer(0x01, make([]byte, 8), []int{4, 4}, 0); err == nil {
+		t.Error("over-reported packet: expected error, got nil")
+	}
+	var nilHandle *DeviceHandle
+	if _, err := nilHandle.IsochronousTransfer(0x01, make([]byte, 8), []int{8}, 0); err == nil {
+		t.Error("nil handle: expected error, got nil")
+	}
+}
+
+func TestIsochronousStreamValidation(t *testing.T) {
+	var nilHandle *DeviceHandle
+	if _, err := nilHandle.IsochronousStream(0x81, []int{8}, 2, 0); err == nil {
+		t.Error("nil handle: expected error, got nil")
+	}
+	// A handle without an open libusb device cannot queue transfers.
+	dh := newFakeDeviceHandle(&fakeTransferer{})
+	if _, err := dh.IsochronousStream(0x81, []int{8}, 2, 0); err == nil {
+		t.Error("unopened handle: expected error, got nil")
+	}
+	var closed IsoStream
+	if _, err := closed.Read(make([]byte, 8)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read on a closed stream: got %v, want os.ErrClosed", err)
+	}
+	if err := closed.Write(make([]byte, 8), []int{8}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write on a closed stream: got %v, want os.ErrClosed", err)
+	}
+	if err := closed.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Close on a closed stream: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestIsoStreamSubmitQueued(t *testing.T) {
+	// A transfer that is still queued must be refused before it reaches
+	// libusb, which would otherwise mark it completed while in flight.
+	s := &IsoStream{count: 2, submitted: [][]int{{8}, nil}}
+	if err := s.submit(0, []int{8}); !errors.Is(err, ErrorCode(errorBusy)) {
+		t.Errorf("submit of a queued transfer: got %v, want errorBusy", err)
+	}
+	if s.queued != 0 {
+		t.Errorf("queued = %d after a refused submit, want 0", s.queued)
+	}
+}
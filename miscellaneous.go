@@ -29,6 +29,19 @@ func (err ErrorCode) Error() string {
 	)
 }
 
+// Timeout reports whether the error is a transfer timeout, so that callers
+// outside this package can tell a timeout from a failed transfer. It matches
+// the interface net.Error uses for the same purpose.
+func (err ErrorCode) Timeout() bool {
+	return err == errorTimeout || err == errorTransferTimedOut
+}
+
+// Stall reports whether the error is an endpoint stall, which class drivers
+// outside this package recover from by clearing the halt.
+func (err ErrorCode) Stall() bool {
+	return err == errorPipe || err == errorTransferStall
+}
+
 // ErrorName implements the libusb_error_name function.
 func ErrorName(err ErrorCode) string {
 	// Convert directly to C.int to avoid potential type mismatches across platforms
@@ -87,6 +100,24 @@ const (
 	errorTransferOverflow ErrorCode = C.LIBUSB_TRANSFER_OVERFLOW
 )
 
+// The libusb_error codes, exported so that callers outside this package
+// can match them with errors.Is and fakes in tests can return them.
+const (
+	ErrIO           = errorIo
+	ErrInvalidParam = errorInvalidParam
+	ErrAccess       = errorAccess
+	ErrNoDevice     = errorNoDevice
+	ErrNotFound     = errorNotFound
+	ErrBusy         = errorBusy
+	ErrTimeout      = errorTimeout
+	ErrOverflow     = errorOverflow
+	ErrPipe         = errorPipe
+	ErrInterrupted  = errorInterrupted
+	ErrNoMem        = errorNoMem
+	ErrNotSupported = errorNotSupported
+	ErrOther        = errorOther
+)
+
 func bcdToDecimal(bcdValue uint16) float64 {
 	bcdPowersByPosition := []string{"hundreths", "tenths", "ones", "tens"}
 
@@ -36,6 +36,44 @@ func TestErrorCodeError(t *testing.T) {
 	}
 }
 
+func TestErrorCodeTimeout(t *testing.T) {
+	testCases := []struct {
+		code     ErrorCode
+		expected bool
+	}{
+		{errorTimeout, true},
+		{errorTransferTimedOut, true},
+		{ErrTimeout, true},
+		{errorPipe, false},
+		{errorIo, false},
+		{success, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.code.Timeout(); got != tc.expected {
+			t.Errorf("ErrorCode(%d).Timeout() = %v, want %v", tc.code, got, tc.expected)
+		}
+	}
+}
+
+func TestErrorCodeStall(t *testing.T) {
+	testCases := []struct {
+		code     ErrorCode
+		expected bool
+	}{
+		{errorPipe, true},
+		{errorTransferStall, true},
+		{ErrPipe, true},
+		{errorTimeout, false},
+		{errorIo, false},
+		{success, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.code.Stall(); got != tc.expected {
+			t.Errorf("ErrorCode(%d).Stall() = %v, want %v", tc.code, got, tc.expected)
+		}
+	}
+}
+
 func TestErrorName(t *testing.T) {
 	// Test that ErrorName returns non-empty strings for known error codes
 	testCodes := []ErrorCode{
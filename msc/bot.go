@@ -0,0 +1,197 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Command block and status wrapper layout from the Bulk-Only Transport
+// specification.
+const (
+	cbwSignature = 0x43425355 // "USBC"
+	cswSignature = 0x53425355 // "USBS"
+	cbwSize      = 31
+	cswSize      = 13
+	cbwFlagIn    = 0x80
+	maxCDBLength = 16
+)
+
+// Status is the bCSWStatus field of a command status wrapper.
+type Status byte
+
+// Command statuses.
+const (
+	StatusPassed     Status = 0x00
+	StatusFailed     Status = 0x01
+	StatusPhaseError Status = 0x02
+)
+
+var statuses = map[Status]string{
+	StatusPassed:     "command passed",
+	StatusFailed:     "command failed",
+	StatusPhaseError: "phase error",
+}
+
+// String implements the Stringer interface for Status.
+func (status Status) String() string {
+	if s, ok := statuses[status]; ok {
+		return s
+	}
+	return fmt.Sprintf("status %#02x", byte(status))
+}
+
+// commandBlockWrapper is the CBW sent on the bulk OUT endpoint to start a
+// command.
+type commandBlockWrapper struct {
+	Tag                uint32
+	DataTransferLength uint32
+	DataIn             bool
+	LUN                uint8
+	CDB                []byte
+}
+
+func (cbw commandBlockWrapper) marshal() []byte {
+	buf := make([]byte, cbwSize)
+	binary.LittleEndian.PutUint32(buf[0:], cbwSignature)
+	binary.LittleEndian.PutUint32(buf[4:], cbw.Tag)
+	binary.LittleEndian.PutUint32(buf[8:], cbw.DataTransferLength)
+	if cbw.DataIn {
+		buf[12] = cbwFlagIn
+	}
+	buf[13] = cbw.LUN & 0x0F
+	buf[14] = byte(len(cbw.CDB))
+	copy(buf[15:], cbw.CDB)
+	return buf
+}
+
+// commandStatusWrapper is the CSW the device returns on the bulk IN endpoint
+// when it finishes a command.
+type commandStatusWrapper struct {
+	Tag         uint32
+	DataResidue uint32
+	Status      Status
+}
+
+// parseCSW decodes a command status wrapper, reporting an error if it isn't
+// valid: the wrong size or signature. Whether the tag matches is left to the
+// caller.
+func parseCSW(buf []byte) (commandStatusWrapper, error) {
+	if len(buf) != cswSize {
+		return commandStatusWrapper{}, fmt.Errorf(
+			"msc: CSW is %d bytes, want %d",
+			len(buf),
+			cswSize,
+		)
+	}
+	if sig := binary.LittleEndian.Uint32(buf); sig != cswSignature {
+		return commandStatusWrapper{}, fmt.Errorf("msc: invalid CSW signature %#08x", sig)
+	}
+	return commandStatusWrapper{
+		Tag:         binary.LittleEndian.Uint32(buf[4:]),
+		DataResidue: binary.LittleEndian.Uint32(buf[8:]),
+		Status:      Status(buf[12]),
+	}, nil
+}
+
+// transport runs one command through the three bulk-only phases and returns
+// the number of data bytes transferred and the command status. The caller
+// must hold dev.mu.
+//
+// A stall in the data phase ends it early; the halt is cleared and the
+// status is read as usual. A stall on the status is cleared and the read
+// retried once. An invalid status or a phase error makes the device's state
+// unknown, so the reset recovery runs before the error is returned.
+func (dev *Device) transport(
+	lun int,
+	cdb []byte,
+	data []byte,
+	dataIn bool,
+) (int, Status, error) {
+	if len(cdb) == 0 || len(cdb) > maxCDBLength {
+		return 0, 0, fmt.Errorf("msc: command block length %d must be 1 to 16", len(cdb))
+	}
+	if lun < 0 || lun > 15 {
+		return 0, 0, fmt.Errorf("msc: invalid LUN %d", lun)
+	}
+	dev.tag++
+	cbw := commandBlockWrapper{
+		Tag:                dev.tag,
+		DataTransferLength: uint32(len(data)),
+		DataIn:             dataIn,
+		LUN:                uint8(lun),
+		CDB:                cdb,
+	}
+	buf := cbw.marshal()
+	n, err := dev.handle.BulkTransfer(dev.OutEndpoint, buf, len(buf), dev.Timeout)
+	if err == nil && n != len(buf) {
+		err = fmt.Errorf("sent %d of %d bytes", n, len(buf))
+	}
+	if err != nil {
+		return 0, 0, dev.resetAfter(fmt.Errorf("msc: sending command block: %w", err))
+	}
+
+	transferred := 0
+	if len(data) > 0 {
+		ep := dev.OutEndpoint
+		if dataIn {
+			ep = dev.InEndpoint
+		}
+		transferred, err = dev.handle.BulkTransfer(ep, data, len(data), dev.Timeout)
+		if isStall(err) {
+			if err := dev.handle.ClearHalt(ep); err != nil {
+				err = fmt.Errorf("msc: clearing halt after data stall: %w", err)
+				return 0, 0, dev.resetAfter(err)
+			}
+		} else if err != nil {
+			return transferred, 0, dev.resetAfter(fmt.Errorf("msc: data phase: %w", err))
+		}
+	}
+
+	csw, err := dev.readStatus()
+	if err != nil {
+		return transferred, 0, dev.resetAfter(err)
+	}
+	if csw.Tag != cbw.Tag {
+		return transferred, 0, dev.resetAfter(
+			fmt.Errorf("msc: CSW tag %#08x doesn't match CBW tag %#08x", csw.Tag, cbw.Tag),
+		)
+	}
+	if csw.Status == StatusPhaseError {
+		return transferred, csw.Status, dev.resetAfter(fmt.Errorf("msc: phase error"))
+	}
+	if csw.Status != StatusPassed && csw.Status != StatusFailed {
+		err := fmt.Errorf("msc: invalid CSW %v", csw.Status)
+		return transferred, csw.Status, dev.resetAfter(err)
+	}
+	if csw.DataResidue <= uint32(len(data)) {
+		transferred = min(transferred, len(data)-int(csw.DataResidue))
+	}
+	return transferred, csw.Status, nil
+}
+
+func (dev *Device) readStatus() (commandStatusWrapper, error) {
+	buf := make([]byte, cswSize)
+	n, err := dev.handle.BulkTransfer(dev.InEndpoint, buf, len(buf), dev.Timeout)
+	if isStall(err) {
+		if err := dev.handle.ClearHalt(dev.InEndpoint); err != nil {
+			return commandStatusWrapper{}, fmt.Errorf("msc: clearing halt before CSW: %w", err)
+		}
+		n, err = dev.handle.BulkTransfer(dev.InEndpoint, buf, len(buf), dev.Timeout)
+	}
+	if err != nil {
+		return commandStatusWrapper{}, fmt.Errorf("msc: reading CSW: %w", err)
+	}
+	return parseCSW(buf[:n])
+}
+
+// resetAfter runs the reset recovery after err and returns err, joined with any
+// error from the recovery itself.
+func (dev *Device) resetAfter(err error) error {
+	return errors.Join(err, dev.resetRecovery())
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCommandBlockWrapper(t *testing.T) {
+	cbw := commandBlockWrapper{
+		Tag:                0x01020304,
+		DataTransferLength: 36,
+		DataIn:             true,
+		LUN:                1,
+		CDB:                []byte{opInquiry, 0, 0, 0, 36, 0},
+	}
+	want := []byte{
+		0x55, 0x53, 0x42, 0x43, 0x04, 0x03, 0x02, 0x01, 0x24, 0x00, 0x00, 0x00,
+		0x80, 0x01, 0x06, 0x12, 0x00, 0x00, 0x00, 0x24, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if got := cbw.marshal(); !bytes.Equal(got, want) {
+		t.Errorf("marshal =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestParseCSW(t *testing.T) {
+	testCases := []struct {
+		name     string
+		buf      []byte
+		expected commandStatusWrapper
+		valid    bool
+	}{
+		{
+			"passed",
+			[]byte{0x55, 0x53, 0x42, 0x53, 7, 0, 0, 0, 0, 2, 0, 0, 0},
+			commandStatusWrapper{Tag: 7, DataResidue: 512, Status: StatusPassed},
+			true,
+		},
+		{
+			"phase error",
+			[]byte{0x55, 0x53, 0x42, 0x53, 1, 0, 0, 0, 0, 0, 0, 0, 2},
+			commandStatusWrapper{Tag: 1, Status: StatusPhaseError},
+			true,
+		},
+		{"short", []byte{0x55, 0x53, 0x42, 0x53, 1, 0, 0, 0}, commandStatusWrapper{}, false},
+		{
+			"bad signature",
+			[]byte{0x55, 0x53, 0x42, 0x43, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+			commandStatusWrapper{},
+			false,
+		},
+	}
+	for _, tc := range testCases {
+		csw, err := parseCSW(tc.buf)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: parseCSW error = %v, want valid %t", tc.name, err, tc.valid)
+			continue
+		}
+		if csw != tc.expected {
+			t.Errorf("%s: parseCSW = %+v, want %+v", tc.name, csw, tc.expected)
+		}
+	}
+}
+
+func TestPhaseErrorRecovery(t *testing.T) {
+	disk := newFakeDisk(512, 8)
+	dev := openDisk(t, disk)
+	disk.Reset()
+	disk.phaseError = true
+	if err := dev.TestUnitReady(0); err == nil {
+		t.Fatal("TestUnitReady with a phase error: expected error, got nil")
+	}
+	want := []string{"request 0xff 0", "clear 0x81", "clear 0x02"}
+	if calls := disk.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want the reset recovery %q", calls, want)
+	}
+	if err := dev.TestUnitReady(0); err != nil {
+		t.Errorf("TestUnitReady after recovery: unexpected error %v", err)
+	}
+}
+
+func TestDataStall(t *testing.T) {
+	disk := newFakeDisk(512, 8)
+	dev := openDisk(t, disk)
+	disk.Reset()
+	disk.stallData = true
+	_, err := dev.Inquiry(0)
+	var senseErr *SenseError
+	if !errors.As(err, &senseErr) || senseErr.Sense.Key != SenseIllegalRequest {
+		t.Fatalf("Inquiry with a stalled data phase: got %v, want an illegal request", err)
+	}
+	want := []string{"clear 0x81"}
+	if calls := disk.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if _, err := dev.Inquiry(0); err != nil {
+		t.Errorf("Inquiry after the stall: unexpected error %v", err)
+	}
+}
+
+func TestInvalidCommandBlock(t *testing.T) {
+	dev := openDisk(t, newFakeDisk(512, 8))
+	if _, err := dev.Command(0, make([]byte, 17), nil, false); err == nil {
+		t.Error("Command with a 17-byte CDB: expected error, got nil")
+	}
+	if _, err := dev.Command(16, make([]byte, 6), nil, false); err == nil {
+		t.Error("Command to LUN 16: expected error, got nil")
+	}
+}
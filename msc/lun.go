@@ -0,0 +1,154 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// unitAttentionRetries is how many UNIT ATTENTION conditions OpenLUN clears
+// before giving up. Devices report one after power on or reset and another
+// after a media change.
+const unitAttentionRetries = 3
+
+// LUN is a logical unit of a Device used as a block device. It implements
+// io.ReaderAt and io.WriterAt; offsets and lengths need not be multiples of
+// the block size, though aligned access avoids reading blocks back before
+// writing them.
+type LUN struct {
+	dev    *Device
+	Number int
+	Capacity
+}
+
+// OpenLUN waits for a logical unit to be ready and reads its capacity.
+func (dev *Device) OpenLUN(number int) (*LUN, error) {
+	var err error
+	for i := 0; i < unitAttentionRetries; i++ {
+		err = dev.TestUnitReady(number)
+		var senseErr *SenseError
+		if !errors.As(err, &senseErr) || senseErr.Sense.Key != SenseUnitAttention {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := dev.ReadCapacity(number)
+	if err != nil {
+		return nil, err
+	}
+	if capacity.BlockSize == 0 {
+		return nil, fmt.Errorf("msc: LUN %d reports a block size of zero", number)
+	}
+	return &LUN{dev: dev, Number: number, Capacity: capacity}, nil
+}
+
+// Size returns the capacity of the unit in bytes.
+func (lun *LUN) Size() int64 {
+	return int64(lun.Bytes())
+}
+
+// maxBlocks returns how many blocks fit in one READ(10) or WRITE(10).
+func (lun *LUN) maxBlocks() int {
+	return min(max(lun.dev.MaxTransferLength/int(lun.BlockSize), 1), math.MaxUint16)
+}
+
+// ReadAt implements io.ReaderAt. Reads that reach the end of the unit return
+// io.EOF with the bytes up to the end.
+func (lun *LUN) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("msc: negative offset %d", off)
+	}
+	size := lun.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	want := int(min(int64(len(p)), size-off))
+	blockSize := int64(lun.BlockSize)
+	var block []byte
+	n := 0
+	for n < want {
+		pos := off + int64(n)
+		lba := pos / blockSize
+		within := int(pos % blockSize)
+		if lba > math.MaxUint32 {
+			return n, fmt.Errorf("msc: block %d is beyond the reach of READ(10)", lba)
+		}
+		if within == 0 && want-n >= int(blockSize) {
+			blocks := min((want-n)/int(blockSize), lun.maxBlocks())
+			chunk := p[n : n+blocks*int(blockSize)]
+			if err := lun.dev.Read10(lun.Number, uint32(lba), uint16(blocks), chunk); err != nil {
+				return n, err
+			}
+			n += len(chunk)
+			continue
+		}
+		if block == nil {
+			block = make([]byte, blockSize)
+		}
+		if err := lun.dev.Read10(lun.Number, uint32(lba), 1, block); err != nil {
+			return n, err
+		}
+		n += copy(p[n:want], block[within:])
+	}
+	if want < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt. Writes that would run past the end of the
+// unit fail without writing anything.
+func (lun *LUN) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("msc: negative offset %d", off)
+	}
+	if off+int64(len(p)) > lun.Size() {
+		return 0, fmt.Errorf(
+			"msc: write of %d bytes at %d runs past the end of LUN %d",
+			len(p),
+			off,
+			lun.Number,
+		)
+	}
+	blockSize := int64(lun.BlockSize)
+	var block []byte
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		lba := pos / blockSize
+		within := int(pos % blockSize)
+		if lba > math.MaxUint32 {
+			return n, fmt.Errorf("msc: block %d is beyond the reach of WRITE(10)", lba)
+		}
+		if within == 0 && len(p)-n >= int(blockSize) {
+			blocks := min((len(p)-n)/int(blockSize), lun.maxBlocks())
+			chunk := p[n : n+blocks*int(blockSize)]
+			if err := lun.dev.Write10(lun.Number, uint32(lba), uint16(blocks), chunk); err != nil {
+				return n, err
+			}
+			n += len(chunk)
+			continue
+		}
+		// A partial block is read, patched, and written back.
+		if block == nil {
+			block = make([]byte, blockSize)
+		}
+		if err := lun.dev.Read10(lun.Number, uint32(lba), 1, block); err != nil {
+			return n, err
+		}
+		copied := copy(block[within:], p[n:])
+		if err := lun.dev.Write10(lun.Number, uint32(lba), 1, block); err != nil {
+			return n, err
+		}
+		n += copied
+	}
+	return n, nil
+}
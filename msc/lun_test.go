@@ -0,0 +1,117 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func openLUN(t *testing.T, disk *fakeDisk) *LUN {
+	t.Helper()
+	lun, err := openDisk(t, disk).OpenLUN(0)
+	if err != nil {
+		t.Fatalf("OpenLUN: unexpected error %v", err)
+	}
+	return lun
+}
+
+func TestOpenLUN(t *testing.T) {
+	disk := newFakeDisk(512, 16)
+	disk.unitAttentions = 2
+	lun := openLUN(t, disk)
+	if lun.Size() != 8192 || lun.BlockSize != 512 {
+		t.Errorf("LUN size = %d with %d-byte blocks, want 8192 with 512", lun.Size(), lun.BlockSize)
+	}
+
+	disk = newFakeDisk(512, 16)
+	disk.unitAttentions = unitAttentionRetries
+	_, err := openDisk(t, disk).OpenLUN(0)
+	var senseErr *SenseError
+	if !errors.As(err, &senseErr) || senseErr.Sense.Key != SenseUnitAttention {
+		t.Errorf("OpenLUN with persistent unit attention: got %v, want a *SenseError", err)
+	}
+}
+
+func TestLUNReadAt(t *testing.T) {
+	disk := newFakeDisk(512, 16)
+	lun := openLUN(t, disk)
+	lun.dev.MaxTransferLength = 1024
+
+	testCases := []struct {
+		name string
+		off  int64
+		size int
+	}{
+		{"aligned", 1024, 2048},
+		{"unaligned start", 700, 1000},
+		{"within a block", 513, 10},
+		{"unaligned end", 0, 1500},
+	}
+	for _, tc := range testCases {
+		p := make([]byte, tc.size)
+		n, err := lun.ReadAt(p, tc.off)
+		if err != nil || n != tc.size {
+			t.Errorf("%s: ReadAt = %d, %v; want %d", tc.name, n, err, tc.size)
+			continue
+		}
+		if want := disk.blocks[tc.off : tc.off+int64(tc.size)]; !bytes.Equal(p, want) {
+			t.Errorf("%s: ReadAt returned the wrong data", tc.name)
+		}
+	}
+
+	p := make([]byte, 1000)
+	n, err := lun.ReadAt(p, 8000)
+	if n != 192 || err != io.EOF {
+		t.Errorf("ReadAt across the end = %d, %v; want 192, io.EOF", n, err)
+	}
+	if n, err := lun.ReadAt(p, 8192); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt at the end = %d, %v; want 0, io.EOF", n, err)
+	}
+}
+
+func TestLUNReadAtSplitsTransfers(t *testing.T) {
+	disk := newFakeDisk(512, 16)
+	lun := openLUN(t, disk)
+	lun.dev.MaxTransferLength = 2048
+	disk.cdbs = nil
+	if _, err := lun.ReadAt(make([]byte, 8192), 0); err != nil {
+		t.Fatalf("ReadAt: unexpected error %v", err)
+	}
+	if len(disk.cdbs) != 4 {
+		t.Errorf("ReadAt of 16 blocks sent %d commands, want 4 of 4 blocks", len(disk.cdbs))
+	}
+}
+
+func TestLUNWriteAt(t *testing.T) {
+	disk := newFakeDisk(512, 16)
+	lun := openLUN(t, disk)
+	want := bytes.Clone(disk.blocks)
+
+	writes := []struct {
+		off  int64
+		data []byte
+	}{
+		{1024, bytes.Repeat([]byte{0x11}, 1024)},
+		{3000, bytes.Repeat([]byte{0x22}, 700)},
+		{5000, []byte("hello")},
+	}
+	for _, w := range writes {
+		n, err := lun.WriteAt(w.data, w.off)
+		if err != nil || n != len(w.data) {
+			t.Fatalf("WriteAt(%d bytes at %d) = %d, %v", len(w.data), w.off, n, err)
+		}
+		copy(want[w.off:], w.data)
+	}
+	if !bytes.Equal(disk.blocks, want) {
+		t.Error("WriteAt left the disk with the wrong contents")
+	}
+	if _, err := lun.WriteAt(make([]byte, 10), 8190); err == nil {
+		t.Error("WriteAt past the end: expected error, got nil")
+	}
+}
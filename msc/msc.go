@@ -0,0 +1,240 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package msc implements the USB Mass Storage Class Bulk-Only Transport and the
+core of the SCSI block command set on top of libusb, for talking to flash
+drives, card readers, and instruments that export their storage as a disk.
+
+FindInterface locates the bulk-only interface of a configuration, and Open
+claims it, detaching the kernel's usb-storage driver if it is bound. Each
+logical unit is then available through OpenLUN as an io.ReaderAt and
+io.WriterAt over its blocks, or through the individual SCSI commands on
+Device.
+
+Commands that fail with a CHECK CONDITION are followed by REQUEST SENSE, and
+the sense data is returned as a *SenseError. Stalled endpoints are cleared
+and phase errors are recovered from with a Bulk-Only Mass Storage Reset as
+the specification describes.
+*/
+package msc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the timeout in milliseconds used for each phase of a
+// command unless Device.Timeout is changed. It is longer than in the other
+// class packages because a drive may have to spin up before it answers.
+const DefaultTimeout = 5000
+
+// DefaultMaxTransferLength is the default limit in bytes on the data of a
+// single READ(10) or WRITE(10) command.
+const DefaultMaxTransferLength = 64 * 1024
+
+// Interface subclass and protocol codes from the Mass Storage Class
+// specification overview.
+const (
+	SubclassSCSI     = 0x06
+	ProtocolBulkOnly = 0x50
+)
+
+// Bulk-only class requests.
+const (
+	requestGetMaxLUN = 0xFE
+	requestReset     = 0xFF
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle for Bulk-Only
+// Transport, including ClearHalt for reset recovery, so tests and other
+// transports can stand in for a real device.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	ClearHalt(endpoint libusb.EndpointAddress) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+}
+
+// FindInterface returns the first bulk-only mass storage interface of a
+// configuration, or nil if it has none.
+func FindInterface(config *libusb.ConfigDescriptor) *libusb.InterfaceDescriptor {
+	if config == nil {
+		return nil
+	}
+	for _, si := range config.SupportedInterfaces {
+		if si == nil {
+			continue
+		}
+		for _, iface := range si.InterfaceDescriptors {
+			if iface.InterfaceClass == libusb.InterfaceClassMassStorage &&
+				iface.InterfaceProtocol == ProtocolBulkOnly {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+// Device is a claimed bulk-only mass storage interface. Its methods may be
+// called from multiple goroutines; commands are sent one at a time.
+type Device struct {
+	handle      Handle
+	Interface   int
+	InEndpoint  libusb.EndpointAddress
+	OutEndpoint libusb.EndpointAddress
+	// Timeout is the timeout in milliseconds for each phase of a command.
+	// Zero waits forever.
+	Timeout int
+	// MaxTransferLength caps the data of a single READ(10) or WRITE(10)
+	// command in bytes. Larger reads and writes are split.
+	MaxTransferLength int
+
+	mu     sync.Mutex
+	tag    uint32
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open finds the bulk endpoint pair of a bulk-only interface and claims the
+// interface from usb-storage or uas.
+func Open(handle Handle, iface *libusb.InterfaceDescriptor) (*Device, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("msc: nil handle or interface descriptor")
+	}
+	dev := &Device{
+		handle:            handle,
+		Interface:         iface.InterfaceNumber,
+		Timeout:           DefaultTimeout,
+		MaxTransferLength: DefaultMaxTransferLength,
+	}
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.BulkTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && dev.InEndpoint == 0 {
+			dev.InEndpoint = ep.EndpointAddress
+		} else if ep.Direction() == libusb.EndpointOut && dev.OutEndpoint == 0 {
+			dev.OutEndpoint = ep.EndpointAddress
+		}
+	}
+	if dev.InEndpoint == 0 || dev.OutEndpoint == 0 {
+		return nil, fmt.Errorf("msc: interface %d has no bulk endpoint pair", dev.Interface)
+	}
+
+	claims, err := usbif.Claim(handle, "msc", dev.Interface)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	return dev, nil
+}
+
+// Close waits for a command in progress, then gives the interface back to
+// the kernel's storage driver if Open took it, which probes the drive
+// afresh. Calling Close again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	dev.closed = true
+	return dev.claims.Release()
+}
+
+// MaxLUN returns the highest logical unit number of the device with the Get
+// Max LUN request. Devices with a single unit may stall the request, which
+// is reported as zero.
+func (dev *Device) MaxLUN() (int, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return 0, os.ErrClosed
+	}
+	buf := make([]byte, 1)
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetMaxLUN,
+		0,
+		uint16(dev.Interface),
+		buf,
+		len(buf),
+		dev.Timeout,
+	)
+	if isStall(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("msc: get max LUN: %w", err)
+	}
+	if n != 1 || buf[0] > 15 {
+		return 0, fmt.Errorf("msc: invalid get max LUN reply % x", buf[:n])
+	}
+	return int(buf[0]), nil
+}
+
+// Reset performs the bulk-only reset recovery: a Bulk-Only Mass Storage
+// Reset followed by clearing the halt on both bulk endpoints.
+func (dev *Device) Reset() error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	return dev.resetRecovery()
+}
+
+func (dev *Device) resetRecovery() error {
+	_, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestReset,
+		0,
+		uint16(dev.Interface),
+		nil,
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("msc: bulk-only reset: %w", err)
+	}
+	for _, ep := range []libusb.EndpointAddress{dev.InEndpoint, dev.OutEndpoint} {
+		if err := dev.handle.ClearHalt(ep); err != nil {
+			return fmt.Errorf("msc: clearing halt on endpoint %#02x: %w", ep, err)
+		}
+	}
+	return nil
+}
+
+// isStall reports whether err is a libusb stall, using the Stall method of
+// libusb.ErrorCode.
+func isStall(err error) bool {
+	var stall interface{ Stall() bool }
+	return errors.As(err, &stall) && stall.Stall()
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+const (
+	inEndpoint  = 0x81
+	outEndpoint = 0x02
+)
+
+// fakeDisk is a bulk-only device with a single RAM disk. It decodes each
+// CBW, runs the SCSI command against the disk, and then serves the data
+// phase, if any, and the CSW on the following bulk transfers.
+type fakeDisk struct {
+	usbiftest.Claimer
+	blockSize int
+	blocks    []byte
+	maxLUN    byte
+
+	cdbs  [][]byte
+	tag   uint32
+	sense Sense
+	csw   []byte
+
+	// dataPhase is set while the host owes the data phase of a command.
+	// dataIn holds the data of an IN phase; an OUT phase is written to the
+	// disk at writeAt.
+	dataPhase bool
+	dataIn    []byte
+	writeAt   int
+
+	// unitAttentions is the number of commands to fail with UNIT ATTENTION.
+	unitAttentions int
+	// stallData stalls the next data phase, as a device does when it can't
+	// transfer the data the host asked for.
+	stallData bool
+	// phaseError makes the next CSW report a phase error.
+	phaseError bool
+}
+
+func newFakeDisk(blockSize int, blocks int) *fakeDisk {
+	disk := &fakeDisk{
+		Claimer:   usbiftest.Claimer{Active: true},
+		blockSize: blockSize,
+		blocks:    make([]byte, blockSize*blocks),
+	}
+	for i := range disk.blocks {
+		disk.blocks[i] = byte(i / blockSize)
+	}
+	return disk
+}
+
+func (disk *fakeDisk) ClearHalt(endpoint libusb.EndpointAddress) error {
+	disk.Record("clear %#02x", endpoint)
+	return nil
+}
+
+func (disk *fakeDisk) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	if request != requestGetMaxLUN {
+		return 0, libusb.ErrPipe
+	}
+	data[0] = disk.maxLUN
+	return 1, nil
+}
+
+func (disk *fakeDisk) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	disk.Record("request %#02x %d", request, index)
+	disk.dataPhase, disk.stallData, disk.csw = false, false, nil
+	return 0, nil
+}
+
+func (disk *fakeDisk) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if disk.dataPhase {
+		disk.dataPhase = false
+		if disk.stallData {
+			disk.stallData = false
+			return 0, libusb.ErrPipe
+		}
+		if endpoint == outEndpoint {
+			return copy(disk.blocks[disk.writeAt:], data[:length]), nil
+		}
+		return copy(data[:length], disk.dataIn), nil
+	}
+	if endpoint == outEndpoint {
+		if length != cbwSize || binary.LittleEndian.Uint32(data) != cbwSignature {
+			return 0, fmt.Errorf("unexpected bulk OUT % x", data[:length])
+		}
+		disk.command(data[:length])
+		return length, nil
+	}
+	if disk.csw == nil {
+		return 0, libusb.ErrTimeout
+	}
+	n := copy(data[:length], disk.csw)
+	disk.csw = nil
+	return n, nil
+}
+
+func (disk *fakeDisk) status(status Status, residue int) {
+	if disk.phaseError {
+		disk.phaseError = false
+		status = StatusPhaseError
+	}
+	disk.csw = make([]byte, cswSize)
+	binary.LittleEndian.PutUint32(disk.csw, cswSignature)
+	binary.LittleEndian.PutUint32(disk.csw[4:], disk.tag)
+	binary.LittleEndian.PutUint32(disk.csw[8:], uint32(residue))
+	disk.csw[12] = byte(status)
+}
+
+// fail ends a command with a CHECK CONDITION, stalling its data phase.
+func (disk *fakeDisk) fail(key SenseKey, asc uint8, length int) {
+	disk.sense = Sense{Key: key, ASC: asc}
+	disk.stallData = disk.dataPhase
+	disk.status(StatusFailed, length)
+}
+
+func (disk *fakeDisk) command(cbw []byte) {
+	disk.tag = binary.LittleEndian.Uint32(cbw[4:])
+	length := int(binary.LittleEndian.Uint32(cbw[8:]))
+	cdb := slices.Clone(cbw[15 : 15+cbw[14]])
+	disk.cdbs = append(disk.cdbs, cdb)
+	disk.dataPhase = length > 0
+	disk.dataIn = nil
+	if disk.stallData {
+		disk.fail(SenseIllegalRequest, 0x24, length)
+		return
+	}
+	if disk.unitAttentions > 0 && cdb[0] != opRequestSense {
+		disk.unitAttentions--
+		disk.fail(SenseUnitAttention, 0x28, length)
+		return
+	}
+
+	reply := func(data []byte) {
+		disk.dataIn = data[:min(len(data), length)]
+		disk.status(StatusPassed, length-len(disk.dataIn))
+	}
+	blocks := len(disk.blocks) / disk.blockSize
+	switch cdb[0] {
+	case opTestUnitReady:
+		disk.status(StatusPassed, 0)
+	case opRequestSense:
+		sense := make([]byte, senseLength)
+		sense[0] = 0x70
+		sense[2] = byte(disk.sense.Key)
+		sense[12] = disk.sense.ASC
+		sense[13] = disk.sense.ASCQ
+		disk.sense = Sense{}
+		reply(sense)
+	case opInquiry:
+		inquiry := make([]byte, inquiryLength)
+		inquiry[1] = 0x80
+		inquiry[2] = 0x06
+		copy(inquiry[8:], "GOTMC   RAM DISK        1.00")
+		reply(inquiry)
+	case opReadCapacity10:
+		capacity := make([]byte, readCapacity10Length)
+		binary.BigEndian.PutUint32(capacity, uint32(blocks-1))
+		binary.BigEndian.PutUint32(capacity[4:], uint32(disk.blockSize))
+		reply(capacity)
+	case opRead10, opWrite10:
+		lba := int(binary.BigEndian.Uint32(cdb[2:]))
+		count := int(binary.BigEndian.Uint16(cdb[7:]))
+		if lba+count > blocks || count*disk.blockSize != length {
+			disk.fail(SenseIllegalRequest, 0x21, length)
+			return
+		}
+		start := lba * disk.blockSize
+		if cdb[0] == opRead10 {
+			reply(slices.Clone(disk.blocks[start : start+length]))
+		} else {
+			disk.writeAt = start
+			disk.status(StatusPassed, 0)
+		}
+	default:
+		disk.fail(SenseIllegalRequest, 0x20, length)
+	}
+}
+
+func diskInterface() *libusb.InterfaceDescriptor {
+	return &libusb.InterfaceDescriptor{
+		InterfaceNumber:   0,
+		InterfaceClass:    libusb.InterfaceClassMassStorage,
+		InterfaceSubClass: SubclassSCSI,
+		InterfaceProtocol: ProtocolBulkOnly,
+		EndpointDescriptors: libusb.EndpointDescriptors{
+			{EndpointAddress: inEndpoint, Attributes: 0x02, MaxPacketSize: 512},
+			{EndpointAddress: outEndpoint, Attributes: 0x02, MaxPacketSize: 512},
+		},
+	}
+}
+
+func openDisk(t *testing.T, disk *fakeDisk) *Device {
+	t.Helper()
+	dev, err := Open(disk, diskInterface())
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterface(t *testing.T) {
+	config := &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{
+				{InterfaceNumber: 0, InterfaceClass: libusb.InterfaceClassHID},
+			}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{diskInterface()}},
+		},
+	}
+	if iface := FindInterface(config); iface == nil || iface.InterfaceProtocol != ProtocolBulkOnly {
+		t.Errorf("FindInterface = %v, want the bulk-only interface", iface)
+	}
+	if iface := FindInterface(&libusb.ConfigDescriptor{}); iface != nil {
+		t.Errorf("FindInterface of an empty configuration = %v, want nil", iface)
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	disk := newFakeDisk(512, 8)
+	disk.maxLUN = 1
+	dev := openDisk(t, disk)
+	if maxLUN, err := dev.MaxLUN(); err != nil || maxLUN != 1 {
+		t.Errorf("MaxLUN = %d, %v; want 1", maxLUN, err)
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := disk.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if err := dev.TestUnitReady(0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("TestUnitReady after Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := Open(disk, &libusb.InterfaceDescriptor{}); err == nil {
+		t.Error("Open without endpoints: expected error, got nil")
+	}
+}
+
+func TestReset(t *testing.T) {
+	disk := newFakeDisk(512, 8)
+	dev := openDisk(t, disk)
+	disk.Reset()
+	if err := dev.Reset(); err != nil {
+		t.Fatalf("Reset: unexpected error %v", err)
+	}
+	want := []string{"request 0xff 0", "clear 0x81", "clear 0x02"}
+	if calls := disk.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SCSI operation codes from SPC-4 and SBC-3.
+const (
+	opTestUnitReady   = 0x00
+	opRequestSense    = 0x03
+	opInquiry         = 0x12
+	opReadCapacity10  = 0x25
+	opRead10          = 0x28
+	opWrite10         = 0x2A
+	opServiceActionIn = 0x9E
+
+	serviceActionReadCapacity16 = 0x10
+)
+
+// Allocation lengths of the commands' data.
+const (
+	inquiryLength        = 36
+	senseLength          = 18
+	readCapacity10Length = 8
+	readCapacity16Length = 32
+)
+
+// SenseKey is the sense key of a SCSI sense response.
+type SenseKey byte
+
+// Sense keys.
+const (
+	SenseNoSense        SenseKey = 0x0
+	SenseRecoveredError SenseKey = 0x1
+	SenseNotReady       SenseKey = 0x2
+	SenseMediumError    SenseKey = 0x3
+	SenseHardwareError  SenseKey = 0x4
+	SenseIllegalRequest SenseKey = 0x5
+	SenseUnitAttention  SenseKey = 0x6
+	SenseDataProtect    SenseKey = 0x7
+	SenseBlankCheck     SenseKey = 0x8
+	SenseVendorSpecific SenseKey = 0x9
+	SenseCopyAborted    SenseKey = 0xA
+	SenseAbortedCommand SenseKey = 0xB
+	SenseVolumeOverflow SenseKey = 0xD
+	SenseMiscompare     SenseKey = 0xE
+)
+
+var senseKeys = map[SenseKey]string{
+	SenseNoSense:        "no sense",
+	SenseRecoveredError: "recovered error",
+	SenseNotReady:       "not ready",
+	SenseMediumError:    "medium error",
+	SenseHardwareError:  "hardware error",
+	SenseIllegalRequest: "illegal request",
+	SenseUnitAttention:  "unit attention",
+	SenseDataProtect:    "data protect",
+	SenseBlankCheck:     "blank check",
+	SenseVendorSpecific: "vendor specific",
+	SenseCopyAborted:    "copy aborted",
+	SenseAbortedCommand: "aborted command",
+	SenseVolumeOverflow: "volume overflow",
+	SenseMiscompare:     "miscompare",
+}
+
+// String implements the Stringer interface for SenseKey.
+func (key SenseKey) String() string {
+	if s, ok := senseKeys[key]; ok {
+		return s
+	}
+	return fmt.Sprintf("sense key %#x", byte(key))
+}
+
+// Sense is the part of a REQUEST SENSE response that identifies an error:
+// the sense key and the additional sense code and qualifier.
+type Sense struct {
+	Key  SenseKey
+	ASC  uint8
+	ASCQ uint8
+}
+
+// String formats the sense as its key followed by the ASC and ASCQ.
+func (sense Sense) String() string {
+	return fmt.Sprintf("%v (ASC %#02x, ASCQ %#02x)", sense.Key, sense.ASC, sense.ASCQ)
+}
+
+// parseSense decodes fixed or descriptor format sense data.
+func parseSense(buf []byte) (Sense, error) {
+	if len(buf) < 1 {
+		return Sense{}, fmt.Errorf("msc: empty sense data")
+	}
+	switch code := buf[0] & 0x7F; code {
+	case 0x70, 0x71:
+		if len(buf) < 14 {
+			return Sense{}, fmt.Errorf("msc: fixed format sense data is %d bytes", len(buf))
+		}
+		return Sense{SenseKey(buf[2] & 0x0F), buf[12], buf[13]}, nil
+	case 0x72, 0x73:
+		if len(buf) < 4 {
+			return Sense{}, fmt.Errorf("msc: descriptor format sense data is %d bytes", len(buf))
+		}
+		return Sense{SenseKey(buf[1] & 0x0F), buf[2], buf[3]}, nil
+	default:
+		return Sense{}, fmt.Errorf("msc: unknown sense response code %#02x", code)
+	}
+}
+
+// SenseError is returned when a command ends with a CHECK CONDITION. It
+// holds the sense data read with REQUEST SENSE.
+type SenseError struct {
+	Op    byte
+	Sense Sense
+}
+
+func (err *SenseError) Error() string {
+	return fmt.Sprintf("msc: command %#02x failed: %v", err.Op, err.Sense)
+}
+
+// Command sends a SCSI command block to a logical unit and transfers data in
+// the direction given by dataIn. It returns the number of bytes transferred.
+// If the command fails, Command requests the sense data and returns it as a
+// *SenseError.
+func (dev *Device) Command(lun int, cdb []byte, data []byte, dataIn bool) (int, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return 0, os.ErrClosed
+	}
+	return dev.command(lun, cdb, data, dataIn)
+}
+
+func (dev *Device) command(lun int, cdb []byte, data []byte, dataIn bool) (int, error) {
+	n, status, err := dev.transport(lun, cdb, data, dataIn)
+	if err != nil || status == StatusPassed {
+		return n, err
+	}
+	sense, err := dev.requestSense(lun)
+	if err != nil {
+		return n, fmt.Errorf("msc: command %#02x failed and sense is unavailable: %w", cdb[0], err)
+	}
+	return n, &SenseError{Op: cdb[0], Sense: sense}
+}
+
+func (dev *Device) requestSense(lun int) (Sense, error) {
+	cdb := []byte{opRequestSense, 0, 0, 0, senseLength, 0}
+	buf := make([]byte, senseLength)
+	n, status, err := dev.transport(lun, cdb, buf, true)
+	if err != nil {
+		return Sense{}, err
+	}
+	if status != StatusPassed {
+		return Sense{}, fmt.Errorf("msc: request sense: %v", status)
+	}
+	return parseSense(buf[:n])
+}
+
+// RequestSense returns the sense data of a logical unit. Command already
+// does this when a command fails, so it is rarely needed directly.
+func (dev *Device) RequestSense(lun int) (Sense, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return Sense{}, os.ErrClosed
+	}
+	return dev.requestSense(lun)
+}
+
+// TestUnitReady reports whether a logical unit is ready for media access by
+// returning nil. A unit without media typically fails with a *SenseError
+// holding SenseNotReady.
+func (dev *Device) TestUnitReady(lun int) error {
+	_, err := dev.Command(lun, make([]byte, 6), nil, false)
+	return err
+}
+
+// InquiryData is the standard INQUIRY data of a logical unit.
+type InquiryData struct {
+	// PeripheralType is the peripheral device type; 0x00 is a direct access
+	// block device and 0x05 a CD/DVD drive.
+	PeripheralType uint8
+	Removable      bool
+	// Version is the SCSI standard the unit claims to conform to.
+	Version  uint8
+	Vendor   string
+	Product  string
+	Revision string
+}
+
+func parseInquiry(buf []byte) (*InquiryData, error) {
+	if len(buf) < inquiryLength {
+		return nil, fmt.Errorf("msc: INQUIRY data is %d bytes, want %d", len(buf), inquiryLength)
+	}
+	return &InquiryData{
+		PeripheralType: buf[0] & 0x1F,
+		Removable:      buf[1]&0x80 != 0,
+		Version:        buf[2],
+		Vendor:         strings.TrimSpace(string(buf[8:16])),
+		Product:        strings.TrimSpace(string(buf[16:32])),
+		Revision:       strings.TrimSpace(string(buf[32:36])),
+	}, nil
+}
+
+// Inquiry returns the standard INQUIRY data of a logical unit.
+func (dev *Device) Inquiry(lun int) (*InquiryData, error) {
+	buf := make([]byte, inquiryLength)
+	n, err := dev.Command(lun, []byte{opInquiry, 0, 0, 0, inquiryLength, 0}, buf, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseInquiry(buf[:n])
+}
+
+// Capacity is the size of a logical unit.
+type Capacity struct {
+	// Blocks is the number of logical blocks, one more than the last LBA.
+	Blocks    uint64
+	BlockSize uint32
+}
+
+// Bytes returns the capacity in bytes.
+func (capacity Capacity) Bytes() uint64 {
+	return capacity.Blocks * uint64(capacity.BlockSize)
+}
+
+// ReadCapacity returns the capacity of a logical unit with READ CAPACITY(10),
+// switching to READ CAPACITY(16) for units too large for the former.
+func (dev *Device) ReadCapacity(lun int) (Capacity, error) {
+	capacity, err := dev.ReadCapacity10(lun)
+	if err != nil || capacity.Blocks <= 0xFFFFFFFF {
+		return capacity, err
+	}
+	return dev.ReadCapacity16(lun)
+}
+
+// ReadCapacity10 returns the capacity of a logical unit with READ
+// CAPACITY(10). Units with 2^32 or more blocks report 2^32 blocks.
+func (dev *Device) ReadCapacity10(lun int) (Capacity, error) {
+	buf := make([]byte, readCapacity10Length)
+	n, err := dev.Command(lun, make10(opReadCapacity10, 0, 0), buf, true)
+	if err != nil {
+		return Capacity{}, err
+	}
+	if n < readCapacity10Length {
+		return Capacity{}, fmt.Errorf("msc: READ CAPACITY(10) returned %d bytes", n)
+	}
+	return Capacity{
+		Blocks:    uint64(binary.BigEndian.Uint32(buf)) + 1,
+		BlockSize: binary.BigEndian.Uint32(buf[4:]),
+	}, nil
+}
+
+// ReadCapacity16 returns the capacity of a logical unit with READ
+// CAPACITY(16).
+func (dev *Device) ReadCapacity16(lun int) (Capacity, error) {
+	cdb := make([]byte, 16)
+	cdb[0] = opServiceActionIn
+	cdb[1] = serviceActionReadCapacity16
+	binary.BigEndian.PutUint32(cdb[10:], readCapacity16Length)
+	buf := make([]byte, readCapacity16Length)
+	n, err := dev.Command(lun, cdb, buf, true)
+	if err != nil {
+		return Capacity{}, err
+	}
+	if n < 12 {
+		return Capacity{}, fmt.Errorf("msc: READ CAPACITY(16) returned %d bytes", n)
+	}
+	return Capacity{
+		Blocks:    binary.BigEndian.Uint64(buf) + 1,
+		BlockSize: binary.BigEndian.Uint32(buf[8:]),
+	}, nil
+}
+
+// Read10 reads blocks starting at lba into data with READ(10). The length of
+// data must be blocks times the unit's block size.
+func (dev *Device) Read10(lun int, lba uint32, blocks uint16, data []byte) error {
+	n, err := dev.Command(lun, make10(opRead10, lba, blocks), data, true)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("msc: READ(10) returned %d of %d bytes", n, len(data))
+	}
+	return nil
+}
+
+// Write10 writes data to blocks starting at lba with WRITE(10). The length of
+// data must be blocks times the unit's block size.
+func (dev *Device) Write10(lun int, lba uint32, blocks uint16, data []byte) error {
+	n, err := dev.Command(lun, make10(opWrite10, lba, blocks), data, false)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("msc: WRITE(10) accepted %d of %d bytes", n, len(data))
+	}
+	return nil
+}
+
+// make10 builds a 10-byte command block with a logical block address and a
+// transfer length.
+func make10(op byte, lba uint32, length uint16) []byte {
+	cdb := make([]byte, 10)
+	cdb[0] = op
+	binary.BigEndian.PutUint32(cdb[2:], lba)
+	binary.BigEndian.PutUint16(cdb[7:], length)
+	return cdb
+}
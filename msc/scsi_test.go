@@ -0,0 +1,106 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package msc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseSense(t *testing.T) {
+	fixed := make([]byte, senseLength)
+	fixed[0] = 0xF0
+	fixed[2] = 0x02
+	fixed[12] = 0x3A
+	fixed[13] = 0x01
+	testCases := []struct {
+		name     string
+		buf      []byte
+		expected Sense
+		valid    bool
+	}{
+		{"fixed", fixed, Sense{SenseNotReady, 0x3A, 0x01}, true},
+		{
+			"descriptor",
+			[]byte{0x72, 0x05, 0x24, 0x00, 0, 0, 0, 0},
+			Sense{SenseIllegalRequest, 0x24, 0},
+			true,
+		},
+		{"short fixed", fixed[:8], Sense{}, false},
+		{"unknown", []byte{0x7F, 0, 0, 0}, Sense{}, false},
+	}
+	for _, tc := range testCases {
+		sense, err := parseSense(tc.buf)
+		if (err == nil) != tc.valid || sense != tc.expected {
+			t.Errorf("%s: parseSense = %v, %v; want %v, valid %t",
+				tc.name, sense, err, tc.expected, tc.valid)
+		}
+	}
+	want := "not ready (ASC 0x3a, ASCQ 0x01)"
+	if got := (Sense{SenseNotReady, 0x3A, 0x01}).String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestInquiry(t *testing.T) {
+	dev := openDisk(t, newFakeDisk(512, 8))
+	inquiry, err := dev.Inquiry(0)
+	if err != nil {
+		t.Fatalf("Inquiry: unexpected error %v", err)
+	}
+	want := InquiryData{
+		Removable: true,
+		Version:   6,
+		Vendor:    "GOTMC",
+		Product:   "RAM DISK",
+		Revision:  "1.00",
+	}
+	if *inquiry != want {
+		t.Errorf("Inquiry = %+v, want %+v", *inquiry, want)
+	}
+}
+
+func TestReadCapacity(t *testing.T) {
+	dev := openDisk(t, newFakeDisk(512, 8))
+	capacity, err := dev.ReadCapacity(0)
+	if err != nil {
+		t.Fatalf("ReadCapacity: unexpected error %v", err)
+	}
+	if capacity != (Capacity{Blocks: 8, BlockSize: 512}) || capacity.Bytes() != 4096 {
+		t.Errorf("ReadCapacity = %+v, want 8 blocks of 512 bytes", capacity)
+	}
+}
+
+func TestReadWrite10(t *testing.T) {
+	disk := newFakeDisk(512, 8)
+	dev := openDisk(t, disk)
+	data := make([]byte, 1024)
+	if err := dev.Read10(0, 2, 2, data); err != nil {
+		t.Fatalf("Read10: unexpected error %v", err)
+	}
+	if data[0] != 2 || data[1023] != 3 {
+		t.Errorf("Read10 returned blocks %d and %d, want 2 and 3", data[0], data[1023])
+	}
+	data = bytes.Repeat([]byte{0xAA}, 512)
+	if err := dev.Write10(0, 7, 1, data); err != nil {
+		t.Fatalf("Write10: unexpected error %v", err)
+	}
+	if !bytes.Equal(disk.blocks[7*512:], data) {
+		t.Error("Write10 didn't change the last block")
+	}
+	wantCDB := []byte{opWrite10, 0, 0, 0, 0, 7, 0, 0, 1, 0}
+	if got := disk.cdbs[len(disk.cdbs)-1]; !bytes.Equal(got, wantCDB) {
+		t.Errorf("WRITE(10) CDB = % x, want % x", got, wantCDB)
+	}
+
+	err := dev.Read10(0, 8, 1, data)
+	var senseErr *SenseError
+	if !errors.As(err, &senseErr) || senseErr.Op != opRead10 ||
+		senseErr.Sense != (Sense{Key: SenseIllegalRequest, ASC: 0x21}) {
+		t.Errorf("Read10 past the end: got %v, want an LBA out of range error", err)
+	}
+}
@@ -0,0 +1,799 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Microsoft OS 1.0 descriptor constants.
+const (
+	msosStringIndex           = 0xEE
+	msosStringSignature       = "MSFT100"
+	msosStringDescriptorSize  = 18
+	msosCompatibleIDIndex     = 0x0004
+	msosExtendedPropsIndex    = 0x0005
+	msosCompatibleIDHeader    = 16
+	msosCompatibleIDFunction  = 24
+	msosExtendedPropsHeader   = 10
+	msosExtendedPropMinLength = 14
+)
+
+// Microsoft OS 2.0 descriptor constants.
+const (
+	msos20DescriptorIndex   = 0x07
+	msos20SetAltEnumeration = 0x08
+	msos20SetInfoSize       = 8
+	msos20SetHeaderSize     = 10
+	msos20SubsetHeaderSize  = 8
+	// msos20DescriptorHeaderSize covers wLength and wDescriptorType.
+	msos20DescriptorHeaderSize = 4
+	msos20RegPropertyMinLength = 10
+)
+
+// Microsoft OS 2.0 wDescriptorType values.
+const (
+	msos20SetHeaderDescriptor   = 0x00
+	msos20SubsetHeaderConfig    = 0x01
+	msos20SubsetHeaderFunction  = 0x02
+	msos20FeatureCompatibleID   = 0x03
+	msos20FeatureRegProperty    = 0x04
+	msos20FeatureMinResumeTime  = 0x05
+	msos20FeatureModelID        = 0x06
+	msos20FeatureCCGPDevice     = 0x07
+	msos20FeatureVendorRevision = 0x08
+)
+
+// msos20FeatureLengths holds the fixed wLength of each Microsoft OS 2.0
+// feature descriptor that has one.
+var msos20FeatureLengths = map[uint16]int{
+	msos20FeatureCompatibleID:   20,
+	msos20FeatureMinResumeTime:  6,
+	msos20FeatureModelID:        20,
+	msos20FeatureCCGPDevice:     msos20DescriptorHeaderSize,
+	msos20FeatureVendorRevision: 6,
+}
+
+// MSOS20PlatformUUID identifies the BOS platform capability that describes a
+// device's Microsoft OS 2.0 descriptor sets,
+// {D8DD60DF-4589-4CC7-9CD2-659D9E648A9F}.
+var MSOS20PlatformUUID = PlatformUUID{
+	0xDF, 0x60, 0xDD, 0xD8, 0x89, 0x45, 0xC7, 0x4C,
+	0x9C, 0xD2, 0x65, 0x9D, 0x9E, 0x64, 0x8A, 0x9F,
+}
+
+// WindowsVersion81 is the minimum dwWindowsVersion for Microsoft OS 2.0
+// descriptors.
+const WindowsVersion81 uint32 = 0x06030000
+
+// MSOSPropertyType is the registry data type of a Microsoft OS extended
+// property.
+type MSOSPropertyType uint32
+
+// Microsoft OS registry property data types.
+const (
+	PropertyTypeString       MSOSPropertyType = 1
+	PropertyTypeExpandString MSOSPropertyType = 2
+	PropertyTypeBinary       MSOSPropertyType = 3
+	PropertyTypeDWordLE      MSOSPropertyType = 4
+	PropertyTypeDWordBE      MSOSPropertyType = 5
+	PropertyTypeLink         MSOSPropertyType = 6
+	PropertyTypeMultiString  MSOSPropertyType = 7
+)
+
+var msosPropertyTypes = map[MSOSPropertyType]string{
+	PropertyTypeString:       "REG_SZ",
+	PropertyTypeExpandString: "REG_EXPAND_SZ",
+	PropertyTypeBinary:       "REG_BINARY",
+	PropertyTypeDWordLE:      "REG_DWORD_LITTLE_ENDIAN",
+	PropertyTypeDWordBE:      "REG_DWORD_BIG_ENDIAN",
+	PropertyTypeLink:         "REG_LINK",
+	PropertyTypeMultiString:  "REG_MULTI_SZ",
+}
+
+// String implements the Stringer interface for MSOSPropertyType.
+func (propType MSOSPropertyType) String() string {
+	return msosPropertyTypes[propType]
+}
+
+// MSOSStringDescriptor models the Microsoft OS 1.0 string descriptor stored
+// at string index 0xEE.
+type MSOSStringDescriptor struct {
+	Signature  string
+	VendorCode uint8
+}
+
+// MSOSCompatibleIDDescriptor models the Microsoft OS 1.0 Extended Compat ID
+// feature descriptor.
+type MSOSCompatibleIDDescriptor struct {
+	Version   bcd
+	Functions []MSOSCompatibleIDFunction
+}
+
+// MSOSCompatibleIDFunction is a function section of the Extended Compat ID
+// descriptor.
+type MSOSCompatibleIDFunction struct {
+	FirstInterface  uint8
+	CompatibleID    string
+	SubCompatibleID string
+}
+
+// MSOSExtendedProperties models the Microsoft OS 1.0 Extended Properties
+// feature descriptor.
+type MSOSExtendedProperties struct {
+	Version    bcd
+	Properties []MSOSProperty
+}
+
+// MSOSProperty is a registry property from either a Microsoft OS 1.0
+// Extended Properties descriptor or a Microsoft OS 2.0 registry property
+// descriptor. Data holds the raw property data.
+type MSOSProperty struct {
+	DataType MSOSPropertyType
+	Name     string
+	Data     []byte
+}
+
+// StringValue decodes a REG_SZ, REG_EXPAND_SZ, or REG_LINK property.
+func (prop MSOSProperty) StringValue() (string, error) {
+	switch prop.DataType {
+	case PropertyTypeString, PropertyTypeExpandString, PropertyTypeLink:
+		return decodeUTF16Z(prop.Data), nil
+	}
+	return "", fmt.Errorf("property %q is %s, not a string", prop.Name, prop.DataType)
+}
+
+// MultiStringValue decodes a REG_MULTI_SZ property.
+func (prop MSOSProperty) MultiStringValue() ([]string, error) {
+	if prop.DataType != PropertyTypeMultiString {
+		return nil, fmt.Errorf(
+			"property %q is %s, not a multi-string",
+			prop.Name,
+			prop.DataType,
+		)
+	}
+	var strs []string
+	for _, str := range strings.Split(decodeUTF16(prop.Data), "\x00") {
+		if str == "" {
+			break
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// Uint32Value decodes a REG_DWORD_LITTLE_ENDIAN or REG_DWORD_BIG_ENDIAN
+// property.
+func (prop MSOSProperty) Uint32Value() (uint32, error) {
+	if len(prop.Data) != 4 {
+		return 0, fmt.Errorf(
+			"property %q has %d bytes of data; want 4",
+			prop.Name,
+			len(prop.Data),
+		)
+	}
+	switch prop.DataType {
+	case PropertyTypeDWordLE:
+		return binary.LittleEndian.Uint32(prop.Data), nil
+	case PropertyTypeDWordBE:
+		return binary.BigEndian.Uint32(prop.Data), nil
+	}
+	return 0, fmt.Errorf("property %q is %s, not a DWORD", prop.Name, prop.DataType)
+}
+
+// MSOS20DescriptorSetInfo is one descriptor set information structure from
+// the Microsoft OS 2.0 platform capability.
+type MSOS20DescriptorSetInfo struct {
+	WindowsVersion uint32
+	TotalLength    uint16
+	VendorCode     uint8
+	AltEnumCode    uint8
+}
+
+// MSOS20DescriptorSet models a Microsoft OS 2.0 descriptor set. Features
+// holds the device-scope feature descriptors that precede any configuration
+// subsets, and Functions holds function subsets that appear outside of a
+// configuration subset.
+type MSOS20DescriptorSet struct {
+	WindowsVersion uint32
+	Features       MSOS20Features
+	Configurations []MSOS20ConfigurationSubset
+	Functions      []MSOS20FunctionSubset
+}
+
+// MSOS20ConfigurationSubset models a configuration subset and the features
+// and function subsets it contains.
+type MSOS20ConfigurationSubset struct {
+	ConfigurationValue uint8
+	Features           MSOS20Features
+	Functions          []MSOS20FunctionSubset
+}
+
+// MSOS20FunctionSubset models a function subset, which applies its features
+// to the function beginning at FirstInterface.
+type MSOS20FunctionSubset struct {
+	FirstInterface uint8
+	Features       MSOS20Features
+}
+
+// MSOS20Features collects the feature descriptors that apply to a device,
+// configuration, or function. A zero VendorRevision means the vendor revision
+// descriptor was absent.
+type MSOS20Features struct {
+	CompatibleID    string
+	SubCompatibleID string
+	Properties      []MSOSProperty
+	MinResumeTime   *MSOS20MinResumeTime
+	ModelID         []byte
+	CCGPDevice      bool
+	VendorRevision  uint16
+}
+
+// MSOS20MinResumeTime models the Microsoft OS 2.0 minimum USB resume time
+// descriptor. Both times are in milliseconds.
+type MSOS20MinResumeTime struct {
+	ResumeRecoveryTime  uint8
+	ResumeSignalingTime uint8
+}
+
+// MSOSStringDescriptor reads the Microsoft OS 1.0 string descriptor at index
+// 0xEE. Devices without Microsoft OS 1.0 descriptors stall the request or
+// return a string without the MSFT100 signature.
+func (dh *DeviceHandle) MSOSStringDescriptor() (*MSOSStringDescriptor, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	data, err := dh.GetDescriptor(descString, msosStringIndex, 0, msosStringDescriptorSize)
+	if err != nil {
+		return nil, err
+	}
+	return parseMSOSStringDescriptor(data)
+}
+
+// MSOSCompatibleID issues the vendor request for the Microsoft OS 1.0
+// Extended Compat ID descriptor, using the vendor code from the
+// MSOSStringDescriptor.
+func (dh *DeviceHandle) MSOSCompatibleID(
+	vendorCode uint8,
+) (*MSOSCompatibleIDDescriptor, error) {
+	data, err := dh.msosFeatureDescriptor(
+		DeviceRecipient,
+		vendorCode,
+		0,
+		msosCompatibleIDIndex,
+		msosCompatibleIDHeader,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseMSOSCompatibleID(data)
+}
+
+// MSOSExtendedProperties issues the vendor request for the Microsoft OS 1.0
+// Extended Properties descriptor of the given interface, using the vendor
+// code from the MSOSStringDescriptor.
+func (dh *DeviceHandle) MSOSExtendedProperties(
+	vendorCode uint8,
+	interfaceNum int,
+) (*MSOSExtendedProperties, error) {
+	if interfaceNum < 0 || interfaceNum > 0xFF {
+		return nil, fmt.Errorf(
+			"interface number %d out of range: %w",
+			interfaceNum,
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	// The high byte of wValue selects the interface and the low byte is the
+	// page number, which is always zero for descriptors up to 64 KB.
+	data, err := dh.msosFeatureDescriptor(
+		InterfaceRecipient,
+		vendorCode,
+		uint16(interfaceNum)<<8,
+		msosExtendedPropsIndex,
+		msosExtendedPropsHeader,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseMSOSExtendedProperties(data)
+}
+
+// msosFeatureDescriptor reads the header of a Microsoft OS 1.0 feature
+// descriptor to learn dwLength and then reads the whole descriptor.
+func (dh *DeviceHandle) msosFeatureDescriptor(
+	recipient RequestRecipient,
+	vendorCode uint8,
+	value uint16,
+	index uint16,
+	headerSize int,
+) ([]byte, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	header, err := dh.vendorIn(recipient, vendorCode, value, index, headerSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 4 {
+		return nil, fmt.Errorf(
+			"Microsoft OS descriptor header is %d bytes; want %d",
+			len(header),
+			headerSize,
+		)
+	}
+	length := binary.LittleEndian.Uint32(header)
+	if length < uint32(headerSize) || length > maxControlLength {
+		return nil, fmt.Errorf("invalid Microsoft OS descriptor dwLength %d", length)
+	}
+	return dh.vendorIn(recipient, vendorCode, value, index, int(length))
+}
+
+// MSOS20DescriptorSetInfo reads the BOS descriptor and returns the
+// descriptor set information from its Microsoft OS 2.0 platform capability.
+// It returns a wrapped errorNotFound if the device has no such capability.
+func (dh *DeviceHandle) MSOS20DescriptorSetInfo() ([]MSOS20DescriptorSetInfo, error) {
+	bos, err := dh.BOSDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	platforms := bos.PlatformCapabilities(MSOS20PlatformUUID)
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf(
+			"no Microsoft OS 2.0 platform capability: %w",
+			ErrorCode(errorNotFound),
+		)
+	}
+	return parseMSOS20Platform(platforms[0].Data)
+}
+
+// MSOS20DescriptorSet issues the MS_OS_20_DESCRIPTOR_INDEX vendor request
+// described by info and parses the returned descriptor set.
+func (dh *DeviceHandle) MSOS20DescriptorSet(
+	info MSOS20DescriptorSetInfo,
+) (*MSOS20DescriptorSet, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	data, err := dh.vendorIn(
+		DeviceRecipient,
+		info.VendorCode,
+		0,
+		msos20DescriptorIndex,
+		int(info.TotalLength),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseMSOS20DescriptorSet(data)
+}
+
+// MSOS20DescriptorSets locates the Microsoft OS 2.0 platform capability in
+// the BOS descriptor and reads every descriptor set it describes.
+func (dh *DeviceHandle) MSOS20DescriptorSets() ([]*MSOS20DescriptorSet, error) {
+	infos, err := dh.MSOS20DescriptorSetInfo()
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]*MSOS20DescriptorSet, 0, len(infos))
+	for _, info := range infos {
+		set, err := dh.MSOS20DescriptorSet(info)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// SetMSOS20AltEnumeration issues the MS_OS_20_SET_ALT_ENUMERATION vendor
+// request, asking the device to return its alternate USB descriptors. A
+// zero AltEnumCode in info restores the default descriptors.
+func (dh *DeviceHandle) SetMSOS20AltEnumeration(info MSOS20DescriptorSetInfo) error {
+	if dh == nil || dh.transferer == nil {
+		return ErrorCode(errorInvalidParam)
+	}
+	_, err := dh.ControlOut(
+		Vendor,
+		DeviceRecipient,
+		info.VendorCode,
+		uint16(info.AltEnumCode)<<8,
+		msos20SetAltEnumeration,
+		nil,
+		standardRequestTimeout,
+	)
+	return err
+}
+
+// vendorIn issues a device-to-host vendor request, returning at most length
+// bytes.
+func (dh *DeviceHandle) vendorIn(
+	recipient RequestRecipient,
+	request uint8,
+	value uint16,
+	index uint16,
+	length int,
+) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := dh.ControlIn(
+		Vendor,
+		recipient,
+		request,
+		value,
+		index,
+		data,
+		length,
+		standardRequestTimeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// parseMSOSStringDescriptor validates the MSFT100 signature and extracts the
+// vendor code from a Microsoft OS 1.0 string descriptor.
+func parseMSOSStringDescriptor(data []byte) (*MSOSStringDescriptor, error) {
+	if len(data) < msosStringDescriptorSize || descriptorType(data[1]) != descString {
+		return nil, fmt.Errorf(
+			"invalid Microsoft OS string descriptor: %w",
+			ErrorCode(errorNotSupported),
+		)
+	}
+	signature := decodeUTF16(data[2:16])
+	if signature != msosStringSignature {
+		return nil, fmt.Errorf(
+			"Microsoft OS string descriptor signature %q: %w",
+			signature,
+			ErrorCode(errorNotSupported),
+		)
+	}
+	return &MSOSStringDescriptor{
+		Signature:  signature,
+		VendorCode: data[16],
+	}, nil
+}
+
+// parseMSOSCompatibleID converts a raw Extended Compat ID descriptor into an
+// MSOSCompatibleIDDescriptor.
+func parseMSOSCompatibleID(data []byte) (*MSOSCompatibleIDDescriptor, error) {
+	if len(data) < msosCompatibleIDHeader {
+		return nil, fmt.Errorf(
+			"compat ID descriptor is %d bytes; want at least %d",
+			len(data),
+			msosCompatibleIDHeader,
+		)
+	}
+	if index := binary.LittleEndian.Uint16(data[6:8]); index != msosCompatibleIDIndex {
+		return nil, fmt.Errorf("compat ID descriptor has wIndex %#04x", index)
+	}
+	count := int(data[8])
+	if want := msosCompatibleIDHeader + count*msosCompatibleIDFunction; len(data) < want {
+		return nil, fmt.Errorf(
+			"compat ID descriptor is %d bytes; want %d for %d functions",
+			len(data),
+			want,
+			count,
+		)
+	}
+	desc := &MSOSCompatibleIDDescriptor{
+		Version:   bcd(binary.LittleEndian.Uint16(data[4:6])),
+		Functions: make([]MSOSCompatibleIDFunction, count),
+	}
+	for i := range desc.Functions {
+		function := data[msosCompatibleIDHeader+i*msosCompatibleIDFunction:]
+		desc.Functions[i] = MSOSCompatibleIDFunction{
+			FirstInterface:  function[0],
+			CompatibleID:    trimNUL(function[2:10]),
+			SubCompatibleID: trimNUL(function[10:18]),
+		}
+	}
+	return desc, nil
+}
+
+// parseMSOSExtendedProperties converts a raw Extended Properties descriptor
+// into an MSOSExtendedProperties.
+func parseMSOSExtendedProperties(data []byte) (*MSOSExtendedProperties, error) {
+	if len(data) < msosExtendedPropsHeader {
+		return nil, fmt.Errorf(
+			"extended properties descriptor is %d bytes; want at least %d",
+			len(data),
+			msosExtendedPropsHeader,
+		)
+	}
+	if index := binary.LittleEndian.Uint16(data[6:8]); index != msosExtendedPropsIndex {
+		return nil, fmt.Errorf("extended properties descriptor has wIndex %#04x", index)
+	}
+	props := &MSOSExtendedProperties{
+		Version: bcd(binary.LittleEndian.Uint16(data[4:6])),
+	}
+	count := int(binary.LittleEndian.Uint16(data[8:10]))
+	offset := msosExtendedPropsHeader
+	for i := 0; i < count; i++ {
+		section := data[offset:]
+		if len(section) < msosExtendedPropMinLength {
+			return nil, fmt.Errorf("extended properties truncated at property %d", i)
+		}
+		size := int(binary.LittleEndian.Uint32(section))
+		if size < msosExtendedPropMinLength || size > len(section) {
+			return nil, fmt.Errorf("property %d has invalid dwSize %d", i, size)
+		}
+		section = section[:size]
+		nameLength := int(binary.LittleEndian.Uint16(section[8:10]))
+		if 10+nameLength+4 > size {
+			return nil, fmt.Errorf(
+				"property %d has invalid wPropertyNameLength %d",
+				i,
+				nameLength,
+			)
+		}
+		dataOffset := 10 + nameLength + 4
+		dataLength := int(binary.LittleEndian.Uint32(section[10+nameLength:]))
+		if dataOffset+dataLength > size {
+			return nil, fmt.Errorf(
+				"property %d has invalid dwPropertyDataLength %d",
+				i,
+				dataLength,
+			)
+		}
+		props.Properties = append(props.Properties, MSOSProperty{
+			DataType: MSOSPropertyType(binary.LittleEndian.Uint32(section[4:8])),
+			Name:     decodeUTF16Z(section[10 : 10+nameLength]),
+			Data:     bytes.Clone(section[dataOffset : dataOffset+dataLength]),
+		})
+		offset += size
+	}
+	return props, nil
+}
+
+// parseMSOS20Platform converts the CapabilityData of a Microsoft OS 2.0
+// platform capability into its descriptor set information structures.
+func parseMSOS20Platform(data []byte) ([]MSOS20DescriptorSetInfo, error) {
+	if len(data) == 0 || len(data)%msos20SetInfoSize != 0 {
+		return nil, fmt.Errorf(
+			"Microsoft OS 2.0 platform capability data is %d bytes",
+			len(data),
+		)
+	}
+	infos := make([]MSOS20DescriptorSetInfo, len(data)/msos20SetInfoSize)
+	for i := range infos {
+		info := data[i*msos20SetInfoSize:]
+		infos[i] = MSOS20DescriptorSetInfo{
+			WindowsVersion: binary.LittleEndian.Uint32(info[0:4]),
+			TotalLength:    binary.LittleEndian.Uint16(info[4:6]),
+			VendorCode:     info[6],
+			AltEnumCode:    info[7],
+		}
+	}
+	return infos, nil
+}
+
+// parseMSOS20DescriptorSet converts a raw Microsoft OS 2.0 descriptor set
+// into an MSOS20DescriptorSet.
+func parseMSOS20DescriptorSet(data []byte) (*MSOS20DescriptorSet, error) {
+	if len(data) < msos20SetHeaderSize {
+		return nil, fmt.Errorf(
+			"Microsoft OS 2.0 descriptor set is %d bytes; want at least %d",
+			len(data),
+			msos20SetHeaderSize,
+		)
+	}
+	if binary.LittleEndian.Uint16(data[0:2]) != msos20SetHeaderSize ||
+		binary.LittleEndian.Uint16(data[2:4]) != msos20SetHeaderDescriptor {
+		return nil, fmt.Errorf("invalid Microsoft OS 2.0 descriptor set header")
+	}
+	totalLength := int(binary.LittleEndian.Uint16(data[8:10]))
+	if totalLength < msos20SetHeaderSize || totalLength > len(data) {
+		return nil, fmt.Errorf(
+			"Microsoft OS 2.0 wTotalLength %d doesn't fit the %d bytes read",
+			totalLength,
+			len(data),
+		)
+	}
+	set := &MSOS20DescriptorSet{
+		WindowsVersion: binary.LittleEndian.Uint32(data[4:8]),
+	}
+	visit := func(descType uint16, desc []byte) (int, error) {
+		switch descType {
+		case msos20SubsetHeaderConfig:
+			config, length, err := parseMSOS20ConfigurationSubset(desc)
+			if err != nil {
+				return 0, err
+			}
+			set.Configurations = append(set.Configurations, *config)
+			return length, nil
+		case msos20SubsetHeaderFunction:
+			function, length, err := parseMSOS20FunctionSubset(desc)
+			if err != nil {
+				return 0, err
+			}
+			set.Functions = append(set.Functions, *function)
+			return length, nil
+		}
+		return 0, set.Features.parse(descType, desc)
+	}
+	if err := walkMSOS20(data[msos20SetHeaderSize:totalLength], visit); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// parseMSOS20ConfigurationSubset parses a configuration subset header and
+// the descriptors it contains, returning the subset's wTotalLength.
+func parseMSOS20ConfigurationSubset(data []byte) (*MSOS20ConfigurationSubset, int, error) {
+	length, err := msos20SubsetLength(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	config := &MSOS20ConfigurationSubset{ConfigurationValue: data[4]}
+	visit := func(descType uint16, desc []byte) (int, error) {
+		switch descType {
+		case msos20SubsetHeaderConfig:
+			return 0, fmt.Errorf("nested Microsoft OS 2.0 configuration subset")
+		case msos20SubsetHeaderFunction:
+			function, length, err := parseMSOS20FunctionSubset(desc)
+			if err != nil {
+				return 0, err
+			}
+			config.Functions = append(config.Functions, *function)
+			return length, nil
+		}
+		return 0, config.Features.parse(descType, desc)
+	}
+	if err := walkMSOS20(data[msos20SubsetHeaderSize:length], visit); err != nil {
+		return nil, 0, err
+	}
+	return config, length, nil
+}
+
+// parseMSOS20FunctionSubset parses a function subset header and the feature
+// descriptors it contains, returning the subset's wSubsetLength.
+func parseMSOS20FunctionSubset(data []byte) (*MSOS20FunctionSubset, int, error) {
+	length, err := msos20SubsetLength(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	function := &MSOS20FunctionSubset{FirstInterface: data[4]}
+	visit := func(descType uint16, desc []byte) (int, error) {
+		switch descType {
+		case msos20SubsetHeaderConfig, msos20SubsetHeaderFunction:
+			return 0, fmt.Errorf("subset header %#04x inside a function subset", descType)
+		}
+		return 0, function.Features.parse(descType, desc)
+	}
+	if err := walkMSOS20(data[msos20SubsetHeaderSize:length], visit); err != nil {
+		return nil, 0, err
+	}
+	return function, length, nil
+}
+
+// msos20SubsetLength validates a subset header and returns the length of the
+// subset, header included.
+func msos20SubsetLength(data []byte) (int, error) {
+	if len(data) < msos20SubsetHeaderSize ||
+		binary.LittleEndian.Uint16(data[0:2]) != msos20SubsetHeaderSize {
+		return 0, fmt.Errorf("invalid Microsoft OS 2.0 subset header")
+	}
+	length := int(binary.LittleEndian.Uint16(data[6:8]))
+	if length < msos20SubsetHeaderSize || length > len(data) {
+		return 0, fmt.Errorf("invalid Microsoft OS 2.0 subset length %d", length)
+	}
+	return length, nil
+}
+
+// walkMSOS20 calls visit for each descriptor in data. The visit function is
+// given the remaining data starting at the descriptor and returns how many
+// bytes it consumed; zero means the descriptor's own wLength.
+func walkMSOS20(
+	data []byte,
+	visit func(descType uint16, desc []byte) (int, error),
+) error {
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < msos20DescriptorHeaderSize {
+			return fmt.Errorf("Microsoft OS 2.0 descriptor truncated at offset %d", offset)
+		}
+		length := int(binary.LittleEndian.Uint16(data[offset:]))
+		descType := binary.LittleEndian.Uint16(data[offset+2:])
+		if length < msos20DescriptorHeaderSize || offset+length > len(data) {
+			return fmt.Errorf(
+				"Microsoft OS 2.0 descriptor at offset %d has invalid wLength %d",
+				offset,
+				length,
+			)
+		}
+		consumed, err := visit(descType, data[offset:])
+		if err != nil {
+			return err
+		}
+		if consumed == 0 {
+			consumed = length
+		}
+		offset += consumed
+	}
+	return nil
+}
+
+// parse adds a single Microsoft OS 2.0 feature descriptor to the features.
+// Unknown descriptor types are skipped.
+func (features *MSOS20Features) parse(descType uint16, data []byte) error {
+	length := int(binary.LittleEndian.Uint16(data))
+	data = data[:length]
+	if want, ok := msos20FeatureLengths[descType]; ok && length != want {
+		return fmt.Errorf(
+			"Microsoft OS 2.0 feature %#04x has wLength %d; want %d",
+			descType,
+			length,
+			want,
+		)
+	}
+	switch descType {
+	case msos20SetHeaderDescriptor:
+		return fmt.Errorf("unexpected Microsoft OS 2.0 descriptor set header")
+	case msos20FeatureCompatibleID:
+		features.CompatibleID = trimNUL(data[4:12])
+		features.SubCompatibleID = trimNUL(data[12:20])
+	case msos20FeatureRegProperty:
+		prop, err := parseMSOS20RegProperty(data)
+		if err != nil {
+			return err
+		}
+		features.Properties = append(features.Properties, *prop)
+	case msos20FeatureMinResumeTime:
+		features.MinResumeTime = &MSOS20MinResumeTime{
+			ResumeRecoveryTime:  data[4],
+			ResumeSignalingTime: data[5],
+		}
+	case msos20FeatureModelID:
+		features.ModelID = bytes.Clone(data[4:20])
+	case msos20FeatureCCGPDevice:
+		features.CCGPDevice = true
+	case msos20FeatureVendorRevision:
+		features.VendorRevision = binary.LittleEndian.Uint16(data[4:6])
+	}
+	return nil
+}
+
+// parseMSOS20RegProperty converts a Microsoft OS 2.0 registry property
+// descriptor into an MSOSProperty.
+func parseMSOS20RegProperty(data []byte) (*MSOSProperty, error) {
+	if len(data) < msos20RegPropertyMinLength {
+		return nil, fmt.Errorf("Microsoft OS 2.0 registry property is %d bytes", len(data))
+	}
+	nameLength := int(binary.LittleEndian.Uint16(data[6:8]))
+	if 8+nameLength+2 > len(data) {
+		return nil, fmt.Errorf(
+			"registry property has invalid wPropertyNameLength %d",
+			nameLength,
+		)
+	}
+	dataOffset := 8 + nameLength + 2
+	dataLength := int(binary.LittleEndian.Uint16(data[8+nameLength:]))
+	if dataOffset+dataLength > len(data) {
+		return nil, fmt.Errorf(
+			"registry property has invalid wPropertyDataLength %d",
+			dataLength,
+		)
+	}
+	return &MSOSProperty{
+		DataType: MSOSPropertyType(binary.LittleEndian.Uint16(data[4:6])),
+		Name:     decodeUTF16Z(data[8 : 8+nameLength]),
+		Data:     bytes.Clone(data[dataOffset : dataOffset+dataLength]),
+	}, nil
+}
+
+// decodeUTF16Z decodes a NUL-terminated little-endian UTF-16 string.
+func decodeUTF16Z(data []byte) string {
+	str, _, _ := strings.Cut(decodeUTF16(data), "\x00")
+	return str
+}
+
+// trimNUL returns the ASCII string in data up to the first NUL byte.
+func trimNUL(data []byte) string {
+	str, _, _ := bytes.Cut(data, []byte{0})
+	return string(str)
+}
@@ -0,0 +1,464 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16z encodes str as NUL-terminated little-endian UTF-16.
+func utf16z(str string) []byte {
+	var data []byte
+	for _, unit := range utf16.Encode([]rune(str + "\x00")) {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func msosStringBytes(signature string, vendorCode byte) []byte {
+	data := []byte{msosStringDescriptorSize, byte(descString)}
+	for _, unit := range utf16.Encode([]rune(signature)) {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return append(data, vendorCode, 0)
+}
+
+func TestParseMSOSStringDescriptor(t *testing.T) {
+	desc, err := parseMSOSStringDescriptor(msosStringBytes("MSFT100", 0x20))
+	if err != nil {
+		t.Fatalf("parseMSOSStringDescriptor: unexpected error %v", err)
+	}
+	if desc.Signature != "MSFT100" || desc.VendorCode != 0x20 {
+		t.Errorf("got %+v, want MSFT100 with vendor code 0x20", desc)
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"short", []byte{0x04, 0x03, 'M', 0}},
+		{"wrong signature", msosStringBytes("MSFT200", 0x20)},
+		{"wrong type", append([]byte{18, 0x02}, msosStringBytes("MSFT100", 0x20)[2:]...)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseMSOSStringDescriptor(tc.data)
+			if !errors.Is(err, ErrorCode(errorNotSupported)) {
+				t.Errorf("got %v, want errorNotSupported", err)
+			}
+		})
+	}
+}
+
+func TestMSOSStringDescriptorRequest(t *testing.T) {
+	ft := &fakeTransferer{response: msosStringBytes("MSFT100", 0x42)}
+	desc, err := newFakeDeviceHandle(ft).MSOSStringDescriptor()
+	if err != nil {
+		t.Fatalf("MSOSStringDescriptor: unexpected error %v", err)
+	}
+	if desc.VendorCode != 0x42 {
+		t.Errorf("VendorCode = %#02x, want 0x42", desc.VendorCode)
+	}
+	want := SetupPacket{0x80, byte(RequestGetDescriptor), 0x03EE, 0, msosStringDescriptorSize}
+	if got := ft.transfers[0].setup; got != want {
+		t.Errorf("setup = %v, want %v", got, want)
+	}
+}
+
+func compatIDBytes() []byte {
+	data := []byte{
+		0, 0, 0, 0, // dwLength
+		0x00, 0x01, // bcdVersion
+		0x04, 0x00, // wIndex
+		2,                   // bCount
+		0, 0, 0, 0, 0, 0, 0, // reserved
+	}
+	functions := [][2]string{{"WINUSB", ""}, {"RNDIS", "5162001"}}
+	for i, ids := range functions {
+		function := make([]byte, msosCompatibleIDFunction)
+		function[0] = byte(i * 2)
+		function[1] = 0x01
+		copy(function[2:10], ids[0])
+		copy(function[10:18], ids[1])
+		data = append(data, function...)
+	}
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+func TestParseMSOSCompatibleID(t *testing.T) {
+	desc, err := parseMSOSCompatibleID(compatIDBytes())
+	if err != nil {
+		t.Fatalf("parseMSOSCompatibleID: unexpected error %v", err)
+	}
+	if desc.Version != 0x0100 {
+		t.Errorf("Version = %v, want 0x0100", desc.Version)
+	}
+	want := []MSOSCompatibleIDFunction{
+		{FirstInterface: 0, CompatibleID: "WINUSB"},
+		{FirstInterface: 2, CompatibleID: "RNDIS", SubCompatibleID: "5162001"},
+	}
+	if !slices.Equal(desc.Functions, want) {
+		t.Errorf("Functions = %+v, want %+v", desc.Functions, want)
+	}
+
+	truncated := compatIDBytes()[:msosCompatibleIDHeader+10]
+	if _, err := parseMSOSCompatibleID(truncated); err == nil {
+		t.Error("parseMSOSCompatibleID(truncated): expected error, got nil")
+	}
+	wrongIndex := compatIDBytes()
+	wrongIndex[6] = 0x05
+	if _, err := parseMSOSCompatibleID(wrongIndex); err == nil {
+		t.Error("parseMSOSCompatibleID(wrong wIndex): expected error, got nil")
+	}
+}
+
+func TestMSOSCompatibleIDRequest(t *testing.T) {
+	data := compatIDBytes()
+	ft := &fakeTransferer{controlFunc: func(setup SetupPacket, buf []byte) (int, error) {
+		return copy(buf, data), nil
+	}}
+	desc, err := newFakeDeviceHandle(ft).MSOSCompatibleID(0x20)
+	if err != nil {
+		t.Fatalf("MSOSCompatibleID: unexpected error %v", err)
+	}
+	if len(desc.Functions) != 2 {
+		t.Errorf("got %d functions, want 2", len(desc.Functions))
+	}
+	want := []SetupPacket{
+		{0xC0, 0x20, 0, msosCompatibleIDIndex, msosCompatibleIDHeader},
+		{0xC0, 0x20, 0, msosCompatibleIDIndex, uint16(len(data))},
+	}
+	if len(ft.transfers) != len(want) {
+		t.Fatalf("got %d transfers, want %d", len(ft.transfers), len(want))
+	}
+	for i, transfer := range ft.transfers {
+		if transfer.setup != want[i] {
+			t.Errorf("transfer %d setup = %v, want %v", i, transfer.setup, want[i])
+		}
+	}
+}
+
+func extendedPropertyBytes(dataType MSOSPropertyType, name string, value []byte) []byte {
+	nameBytes := utf16z(name)
+	prop := binary.LittleEndian.AppendUint32(nil, uint32(14+len(nameBytes)+len(value)))
+	prop = binary.LittleEndian.AppendUint32(prop, uint32(dataType))
+	prop = binary.LittleEndian.AppendUint16(prop, uint16(len(nameBytes)))
+	prop = append(prop, nameBytes...)
+	prop = binary.LittleEndian.AppendUint32(prop, uint32(len(value)))
+	return append(prop, value...)
+}
+
+func extendedPropertiesBytes(props ...[]byte) []byte {
+	data := []byte{0, 0, 0, 0, 0x00, 0x01, 0x05, 0x00, byte(len(props)), 0}
+	for _, prop := range props {
+		data = append(data, prop...)
+	}
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+func TestParseMSOSExtendedProperties(t *testing.T) {
+	guids := append(utf16z("{a}"), utf16z("{b}")...)
+	guids = append(guids, 0, 0)
+	data := extendedPropertiesBytes(
+		extendedPropertyBytes(PropertyTypeMultiString, "DeviceInterfaceGUIDs", guids),
+		extendedPropertyBytes(PropertyTypeString, "Label", utf16z("Widget")),
+		extendedPropertyBytes(PropertyTypeDWordLE, "Idle", []byte{0x10, 0x27, 0, 0}),
+	)
+	props, err := parseMSOSExtendedProperties(data)
+	if err != nil {
+		t.Fatalf("parseMSOSExtendedProperties: unexpected error %v", err)
+	}
+	if len(props.Properties) != 3 {
+		t.Fatalf("got %d properties, want 3", len(props.Properties))
+	}
+	guidProp := props.Properties[0]
+	if guidProp.Name != "DeviceInterfaceGUIDs" || guidProp.DataType != PropertyTypeMultiString {
+		t.Errorf("property 0 = %q %v", guidProp.Name, guidProp.DataType)
+	}
+	strs, err := guidProp.MultiStringValue()
+	if err != nil || !slices.Equal(strs, []string{"{a}", "{b}"}) {
+		t.Errorf("MultiStringValue = %q, %v; want [{a} {b}]", strs, err)
+	}
+	if str, err := props.Properties[1].StringValue(); err != nil || str != "Widget" {
+		t.Errorf("StringValue = %q, %v; want Widget", str, err)
+	}
+	if val, err := props.Properties[2].Uint32Value(); err != nil || val != 10000 {
+		t.Errorf("Uint32Value = %d, %v; want 10000", val, err)
+	}
+	if _, err := props.Properties[2].StringValue(); err == nil {
+		t.Error("StringValue of a DWORD: expected error, got nil")
+	}
+
+	truncated := data[:len(data)-3]
+	binary.LittleEndian.PutUint32(truncated, uint32(len(truncated)))
+	if _, err := parseMSOSExtendedProperties(truncated); err == nil {
+		t.Error("parseMSOSExtendedProperties(truncated): expected error, got nil")
+	}
+}
+
+func TestMSOSExtendedPropertiesRequest(t *testing.T) {
+	data := extendedPropertiesBytes(
+		extendedPropertyBytes(PropertyTypeString, "Label", utf16z("Widget")),
+	)
+	ft := &fakeTransferer{controlFunc: func(setup SetupPacket, buf []byte) (int, error) {
+		return copy(buf, data), nil
+	}}
+	dh := newFakeDeviceHandle(ft)
+	if _, err := dh.MSOSExtendedProperties(0x20, 2); err != nil {
+		t.Fatalf("MSOSExtendedProperties: unexpected error %v", err)
+	}
+	want := SetupPacket{0xC1, 0x20, 0x0200, msosExtendedPropsIndex, uint16(len(data))}
+	if got := ft.transfers[len(ft.transfers)-1].setup; got != want {
+		t.Errorf("setup = %v, want %v", got, want)
+	}
+	if _, err := dh.MSOSExtendedProperties(0x20, 256); !errors.Is(err, ErrorCode(errorInvalidParam)) {
+		t.Errorf("interface 256: got %v, want errorInvalidParam", err)
+	}
+}
+
+func msos20Descriptor(descType uint16, body ...byte) []byte {
+	desc := binary.LittleEndian.AppendUint16(nil, uint16(4+len(body)))
+	desc = binary.LittleEndian.AppendUint16(desc, descType)
+	return append(desc, body...)
+}
+
+func msos20Subset(descType uint16, value byte, children ...[]byte) []byte {
+	subset := []byte{msos20SubsetHeaderSize, 0, byte(descType), 0, value, 0, 0, 0}
+	for _, child := range children {
+		subset = append(subset, child...)
+	}
+	binary.LittleEndian.PutUint16(subset[6:], uint16(len(subset)))
+	return subset
+}
+
+func msos20Set(children ...[]byte) []byte {
+	set := []byte{msos20SetHeaderSize, 0, msos20SetHeaderDescriptor, 0}
+	set = binary.LittleEndian.AppendUint32(set, WindowsVersion81)
+	set = append(set, 0, 0)
+	for _, child := range children {
+		set = append(set, child...)
+	}
+	binary.LittleEndian.PutUint16(set[8:], uint16(len(set)))
+	return set
+}
+
+func msos20RegProperty(dataType MSOSPropertyType, name string, value []byte) []byte {
+	nameBytes := utf16z(name)
+	body := binary.LittleEndian.AppendUint16(nil, uint16(dataType))
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(nameBytes)))
+	body = append(body, nameBytes...)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(value)))
+	body = append(body, value...)
+	return msos20Descriptor(msos20FeatureRegProperty, body...)
+}
+
+func testMSOS20Set() []byte {
+	compatID := msos20Descriptor(msos20FeatureCompatibleID, append(
+		[]byte("WINUSB\x00\x00"), make([]byte, 8)...,
+	)...)
+	return msos20Set(
+		msos20Descriptor(msos20FeatureCCGPDevice),
+		msos20Descriptor(msos20FeatureMinResumeTime, 10, 20),
+		msos20Descriptor(msos20FeatureVendorRevision, 0x02, 0x00),
+		msos20Subset(msos20SubsetHeaderConfig, 0,
+			msos20Subset(msos20SubsetHeaderFunction, 1,
+				compatID,
+				msos20RegProperty(PropertyTypeMultiString, "DeviceInterfaceGUIDs",
+					append(utf16z("{guid}"), 0, 0)),
+			),
+			msos20Subset(msos20SubsetHeaderFunction, 3),
+		),
+	)
+}
+
+func TestParseMSOS20DescriptorSet(t *testing.T) {
+	set, err := parseMSOS20DescriptorSet(testMSOS20Set())
+	if err != nil {
+		t.Fatalf("parseMSOS20DescriptorSet: unexpected error %v", err)
+	}
+	if set.WindowsVersion != WindowsVersion81 {
+		t.Errorf("WindowsVersion = %#08x, want %#08x", set.WindowsVersion, WindowsVersion81)
+	}
+	if !set.Features.CCGPDevice {
+		t.Error("CCGPDevice = false, want true")
+	}
+	if set.Features.VendorRevision != 2 {
+		t.Errorf("VendorRevision = %d, want 2", set.Features.VendorRevision)
+	}
+	wantResume := MSOS20MinResumeTime{10, 20}
+	if set.Features.MinResumeTime == nil || *set.Features.MinResumeTime != wantResume {
+		t.Errorf("MinResumeTime = %v, want %v", set.Features.MinResumeTime, wantResume)
+	}
+	if len(set.Configurations) != 1 {
+		t.Fatalf("got %d configuration subsets, want 1", len(set.Configurations))
+	}
+	functions := set.Configurations[0].Functions
+	if len(functions) != 2 {
+		t.Fatalf("got %d function subsets, want 2", len(functions))
+	}
+	if functions[0].FirstInterface != 1 || functions[1].FirstInterface != 3 {
+		t.Errorf(
+			"FirstInterface = %d, %d; want 1, 3",
+			functions[0].FirstInterface,
+			functions[1].FirstInterface,
+		)
+	}
+	features := functions[0].Features
+	if features.CompatibleID != "WINUSB" || features.SubCompatibleID != "" {
+		t.Errorf("compatible ID = %q/%q, want WINUSB", features.CompatibleID, features.SubCompatibleID)
+	}
+	if len(features.Properties) != 1 {
+		t.Fatalf("got %d properties, want 1", len(features.Properties))
+	}
+	guids, err := features.Properties[0].MultiStringValue()
+	if err != nil || !slices.Equal(guids, []string{"{guid}"}) {
+		t.Errorf("DeviceInterfaceGUIDs = %q, %v", guids, err)
+	}
+}
+
+func TestParseMSOS20DescriptorSetErrors(t *testing.T) {
+	badLength := testMSOS20Set()
+	badLength[msos20SetHeaderSize] = 0x03
+	badFeature := msos20Set(msos20Descriptor(msos20FeatureCompatibleID, 'W'))
+	nested := msos20Set(msos20Subset(msos20SubsetHeaderConfig, 0,
+		msos20Subset(msos20SubsetHeaderConfig, 1)))
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", testMSOS20Set()[:20]},
+		{"bad header", msos20Descriptor(msos20FeatureCCGPDevice, 0, 0, 0, 0, 0, 0)},
+		{"descriptor wLength too small", badLength},
+		{"feature wrong length", badFeature},
+		{"nested configuration", nested},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseMSOS20DescriptorSet(tc.data); err == nil {
+				t.Error("parseMSOS20DescriptorSet: expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestMSOS20DescriptorSets(t *testing.T) {
+	set := testMSOS20Set()
+	info := binary.LittleEndian.AppendUint32(nil, WindowsVersion81)
+	info = binary.LittleEndian.AppendUint16(info, uint16(len(set)))
+	info = append(info, 0x21, 0x00)
+	bos := buildBOS(usb2Extension, buildPlatformCapability(MSOS20PlatformUUID, info))
+	bosDevice := fakeBOSDevice(bos)
+	ft := &fakeTransferer{controlFunc: func(setup SetupPacket, data []byte) (int, error) {
+		if setup.Type() == Vendor {
+			if setup.Request != 0x21 || setup.Index != msos20DescriptorIndex {
+				return 0, ErrorCode(errorPipe)
+			}
+			return copy(data, set), nil
+		}
+		return bosDevice(setup, data)
+	}}
+	sets, err := newFakeDeviceHandle(ft).MSOS20DescriptorSets()
+	if err != nil {
+		t.Fatalf("MSOS20DescriptorSets: unexpected error %v", err)
+	}
+	if len(sets) != 1 || len(sets[0].Configurations) != 1 {
+		t.Fatalf("got %+v, want one set with one configuration subset", sets)
+	}
+	last := ft.transfers[len(ft.transfers)-1].setup
+	want := SetupPacket{0xC0, 0x21, 0, msos20DescriptorIndex, uint16(len(set))}
+	if last != want {
+		t.Errorf("setup = %v, want %v", last, want)
+	}
+}
+
+func TestMSOS20DescriptorSetInfoNotFound(t *testing.T) {
+	ft := &fakeTransferer{controlFunc: fakeBOSDevice(buildBOS(usb2Extension))}
+	_, err := newFakeDeviceHandle(ft).MSOS20DescriptorSetInfo()
+	if !errors.Is(err, ErrorCode(errorNotFound)) {
+		t.Errorf("MSOS20DescriptorSetInfo: got %v, want errorNotFound", err)
+	}
+}
+
+func TestParseMSOS20Platform(t *testing.T) {
+	data := []byte{
+		0x00, 0x00, 0x03, 0x06, 0xB2, 0x00, 0x01, 0x00,
+		0x00, 0x00, 0x00, 0x0A, 0x40, 0x01, 0x02, 0x05,
+	}
+	infos, err := parseMSOS20Platform(data)
+	if err != nil {
+		t.Fatalf("parseMSOS20Platform: unexpected error %v", err)
+	}
+	want := []MSOS20DescriptorSetInfo{
+		{WindowsVersion: 0x06030000, TotalLength: 0xB2, VendorCode: 1, AltEnumCode: 0},
+		{WindowsVersion: 0x0A000000, TotalLength: 0x140, VendorCode: 2, AltEnumCode: 5},
+	}
+	if !slices.Equal(infos, want) {
+		t.Errorf("infos = %+v, want %+v", infos, want)
+	}
+	if _, err := parseMSOS20Platform(data[:7]); err == nil {
+		t.Error("parseMSOS20Platform(short): expected error, got nil")
+	}
+}
+
+func TestSetMSOS20AltEnumeration(t *testing.T) {
+	ft := &fakeTransferer{}
+	info := MSOS20DescriptorSetInfo{VendorCode: 0x21, AltEnumCode: 0x05}
+	if err := newFakeDeviceHandle(ft).SetMSOS20AltEnumeration(info); err != nil {
+		t.Fatalf("SetMSOS20AltEnumeration: unexpected error %v", err)
+	}
+	want := SetupPacket{0x40, 0x21, 0x0500, msos20SetAltEnumeration, 0}
+	if got := ft.transfers[0].setup; got != want {
+		t.Errorf("setup = %v, want %v", got, want)
+	}
+}
+
+func TestMSOSPropertyValueErrors(t *testing.T) {
+	prop := MSOSProperty{DataType: PropertyTypeBinary, Name: "Blob", Data: []byte{1, 2, 3, 4}}
+	if _, err := prop.Uint32Value(); err == nil {
+		t.Error("Uint32Value of REG_BINARY: expected error, got nil")
+	}
+	if _, err := prop.MultiStringValue(); err == nil {
+		t.Error("MultiStringValue of REG_BINARY: expected error, got nil")
+	}
+	be := MSOSProperty{DataType: PropertyTypeDWordBE, Data: []byte{0, 0, 0x27, 0x10}}
+	if val, err := be.Uint32Value(); err != nil || val != 10000 {
+		t.Errorf("Uint32Value(big endian) = %d, %v; want 10000", val, err)
+	}
+	if !bytes.Equal(prop.Data, []byte{1, 2, 3, 4}) {
+		t.Error("value accessors modified the property data")
+	}
+}
+
+func TestMSOSNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	if _, err := dh.MSOSStringDescriptor(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("MSOSStringDescriptor: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.MSOSCompatibleID(0x20); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("MSOSCompatibleID: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.MSOSExtendedProperties(0x20, 0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("MSOSExtendedProperties: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.MSOS20DescriptorSets(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("MSOS20DescriptorSets: got %v, want errorInvalidParam", err)
+	}
+	info := MSOS20DescriptorSetInfo{}
+	if _, err := dh.MSOS20DescriptorSet(info); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("MSOS20DescriptorSet: got %v, want errorInvalidParam", err)
+	}
+	if err := dh.SetMSOS20AltEnumeration(info); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("SetMSOS20AltEnumeration: got %v, want errorInvalidParam", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package printer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// maxDeviceIDLength is the size of the GET_DEVICE_ID buffer, which is what
+// the Linux usblp driver allows.
+const maxDeviceIDLength = 1024
+
+// DeviceID is a parsed IEEE 1284 device ID, a string of KEY:value; pairs
+// such as
+//
+//	MFG:Hewlett-Packard;MDL:HP LaserJet 4000;CMD:PJL,PCL,POSTSCRIPT;CLS:PRINTER;
+//
+// The fields every printer is required to send have their own members; all
+// pairs, those included, are in Fields under their keys as given.
+type DeviceID struct {
+	Manufacturer string
+	Model        string
+	// CommandSet lists the page description languages the printer accepts,
+	// such as PCL or POSTSCRIPT.
+	CommandSet  []string
+	Class       string
+	Description string
+	Fields      map[string]string
+	// Raw is the device ID string without its length prefix.
+	Raw string
+}
+
+// ParseDeviceID parses an IEEE 1284 device ID string. Keys are matched
+// without regard to case or surrounding space, and both the short and long
+// forms of the required keys are recognized.
+func ParseDeviceID(s string) *DeviceID {
+	id := &DeviceID{Fields: make(map[string]string), Raw: s}
+	for _, pair := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		id.Fields[key] = value
+		switch strings.ToUpper(key) {
+		case "MFG", "MANUFACTURER":
+			id.Manufacturer = value
+		case "MDL", "MODEL":
+			id.Model = value
+		case "CMD", "COMMAND SET":
+			id.CommandSet = nil
+			for _, cmd := range strings.Split(value, ",") {
+				if cmd = strings.TrimSpace(cmd); cmd != "" {
+					id.CommandSet = append(id.CommandSet, cmd)
+				}
+			}
+		case "CLS", "CLASS":
+			id.Class = value
+		case "DES", "DESCRIPTION":
+			id.Description = value
+		}
+	}
+	return id
+}
+
+// Supports reports whether the command set lists a language, ignoring case.
+func (id *DeviceID) Supports(language string) bool {
+	for _, cmd := range id.CommandSet {
+		if strings.EqualFold(cmd, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceIDString returns the printer's IEEE 1284 device ID string with the
+// GET_DEVICE_ID request.
+func (prn *Printer) DeviceIDString() (string, error) {
+	if prn.isClosed() {
+		return "", os.ErrClosed
+	}
+	buf := make([]byte, maxDeviceIDLength)
+	n, err := prn.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetDeviceID,
+		uint16(prn.ConfigIndex),
+		uint16(prn.Interface)<<8|uint16(prn.AlternateSetting),
+		buf,
+		len(buf),
+		prn.Timeout,
+	)
+	if err != nil {
+		return "", fmt.Errorf("printer: get device ID: %w", err)
+	}
+	return parseDeviceIDReply(buf[:n])
+}
+
+// DeviceID returns the printer's parsed IEEE 1284 device ID.
+func (prn *Printer) DeviceID() (*DeviceID, error) {
+	s, err := prn.DeviceIDString()
+	if err != nil {
+		return nil, err
+	}
+	return ParseDeviceID(s), nil
+}
+
+// parseDeviceIDReply strips the length prefix from a GET_DEVICE_ID reply.
+// The length is big-endian and counts itself, but some printers send it
+// little-endian or larger than the reply, so a length that doesn't fit is
+// tried the other way round and then ignored.
+func parseDeviceIDReply(reply []byte) (string, error) {
+	if len(reply) < 2 {
+		return "", fmt.Errorf("printer: device ID reply of %d bytes is too short", len(reply))
+	}
+	length := int(binary.BigEndian.Uint16(reply))
+	if length < 2 || length > len(reply) {
+		length = int(binary.LittleEndian.Uint16(reply))
+	}
+	if length < 2 || length > len(reply) {
+		length = len(reply)
+	}
+	return strings.TrimRight(string(reply[2:length]), "\x00"), nil
+}
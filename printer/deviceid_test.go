@@ -0,0 +1,92 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package printer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseDeviceID(t *testing.T) {
+	testCases := []struct {
+		given        string
+		manufacturer string
+		model        string
+		commands     []string
+		class        string
+	}{
+		{
+			"MFG:Hewlett-Packard;MDL:HP LaserJet 4000;CMD:PJL,PCL,POSTSCRIPT;CLS:PRINTER;",
+			"Hewlett-Packard", "HP LaserJet 4000", []string{"PJL", "PCL", "POSTSCRIPT"}, "PRINTER",
+		},
+		{
+			"MANUFACTURER:Brother; COMMAND SET: PJL, PCL ,PCLXL;MODEL:HL-L2350DW;",
+			"Brother", "HL-L2350DW", []string{"PJL", "PCL", "PCLXL"}, "",
+		},
+		{
+			"mfg:EPSON;mdl:ET-2750;cmd:ESCPL2,BDC,D4,D4PX,ESCPR7;cls:PRINTER;DES:EPSON ET-2750",
+			"EPSON", "ET-2750", []string{"ESCPL2", "BDC", "D4", "D4PX", "ESCPR7"}, "PRINTER",
+		},
+	}
+	for _, tc := range testCases {
+		id := ParseDeviceID(tc.given)
+		if id.Manufacturer != tc.manufacturer || id.Model != tc.model || id.Class != tc.class {
+			t.Errorf("%q: got %q %q %q", tc.given, id.Manufacturer, id.Model, id.Class)
+		}
+		if !slices.Equal(id.CommandSet, tc.commands) {
+			t.Errorf("%q: command set = %q, want %q", tc.given, id.CommandSet, tc.commands)
+		}
+		if id.Raw != tc.given {
+			t.Errorf("%q: Raw = %q", tc.given, id.Raw)
+		}
+	}
+	id := ParseDeviceID("MFG:Acme;CMD:PCL;VSTATUS:RDY")
+	if id.Fields["VSTATUS"] != "RDY" {
+		t.Errorf("Fields = %v, want VSTATUS", id.Fields)
+	}
+	if !id.Supports("pcl") || id.Supports("POSTSCRIPT") {
+		t.Errorf("Supports decoded %q wrong", id.CommandSet)
+	}
+}
+
+func TestDeviceID(t *testing.T) {
+	const s = "MFG:Acme;MDL:Laser 1;CMD:PCL;"
+	testCases := []struct {
+		name  string
+		reply []byte
+	}{
+		{"big-endian length", append([]byte{0x00, byte(len(s) + 2)}, s...)},
+		{"little-endian length", append([]byte{byte(len(s) + 2), 0x00}, s...)},
+		{"length too long", append([]byte{0x40, 0x00}, s...)},
+		{"padded", append(append([]byte{0x00, byte(len(s) + 2)}, s...), 0, 0, 0)},
+	}
+	for _, tc := range testCases {
+		fh := &fakeHandle{deviceID: tc.reply}
+		iface := printerInterface(1, ProtocolBidirectional)
+		iface.InterfaceNumber = 3
+		prn, err := Open(fh, iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := prn.DeviceID()
+		if err != nil {
+			t.Fatalf("%s: DeviceID: unexpected error %v", tc.name, err)
+		}
+		if id.Raw != s || id.Model != "Laser 1" {
+			t.Errorf("%s: DeviceID = %q", tc.name, id.Raw)
+		}
+		if fh.lastIndex != 0x0301 || fh.lastValue != 0 {
+			t.Errorf("%s: wValue = %#x, wIndex = %#x", tc.name, fh.lastValue, fh.lastIndex)
+		}
+	}
+	prn, err := Open(&fakeHandle{deviceID: []byte{0}}, printerInterface(0, ProtocolUnidirectional))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prn.DeviceID(); err == nil {
+		t.Error("DeviceID with a 1-byte reply: expected error, got nil")
+	}
+}
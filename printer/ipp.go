@@ -0,0 +1,406 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package printer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// pollInterval bounds each blocking bulk IN transfer of a Conn, so that
+// Close, deadlines, and cancelled requests are noticed while waiting.
+const pollInterval = 100 * time.Millisecond
+
+// FindIPPInterfaces returns the IPP over USB alternate setting of each
+// printer interface that has one. The IPP over USB specification requires
+// at least two, each carrying one HTTP connection at a time.
+func FindIPPInterfaces(config *libusb.ConfigDescriptor) libusb.InterfaceDescriptors {
+	var found libusb.InterfaceDescriptors
+	for _, iface := range FindInterfaces(config) {
+		if Protocol(iface.InterfaceProtocol) != ProtocolIPPOverUSB {
+			continue
+		}
+		if in, out := bulkEndpoints(iface); in != nil && out != nil {
+			found = append(found, iface)
+		}
+	}
+	return found
+}
+
+// Addr is the address of an IPP over USB interface.
+type Addr struct {
+	Interface int
+}
+
+// Network returns "usb".
+func (addr Addr) Network() string {
+	return "usb"
+}
+
+// String returns the address as "interface N".
+func (addr Addr) String() string {
+	return fmt.Sprintf("interface %d", addr.Interface)
+}
+
+// Conn is a net.Conn over the bulk endpoint pair of an IPP over USB
+// interface. Reads wait in short transfers, so Close and deadlines take
+// effect while a Read is blocked; a Write without a deadline waits until the
+// printer takes the data.
+type Conn struct {
+	handle           Handle
+	Interface        int
+	AlternateSetting int
+	InEndpoint       libusb.EndpointAddress
+	OutEndpoint      libusb.EndpointAddress
+
+	readMu sync.Mutex
+	reader *usbif.Reader
+
+	mu            sync.Mutex
+	closed        bool
+	claims        *usbif.Claims
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// OpenConn claims an IPP over USB interface, from usblp if that driver has
+// it, and selects the IPP over USB alternate setting.
+func OpenConn(handle Handle, iface *libusb.InterfaceDescriptor) (*Conn, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("printer: nil handle or interface descriptor")
+	}
+	in, out := bulkEndpoints(iface)
+	if in == nil || out == nil {
+		return nil, fmt.Errorf(
+			"printer: interface %d has no bulk endpoint pair",
+			iface.InterfaceNumber,
+		)
+	}
+	conn := &Conn{
+		handle:           handle,
+		Interface:        iface.InterfaceNumber,
+		AlternateSetting: iface.AlternateSetting,
+		InEndpoint:       in.EndpointAddress,
+		OutEndpoint:      out.EndpointAddress,
+		// Reading a packet at a time means a transfer that times out has
+		// received nothing, so no data is lost to the poll.
+		reader: usbif.NewReader(handle, in.EndpointAddress, int(in.MaxPacketSize)),
+	}
+	claims, err := usbif.Claim(handle, "printer", conn.Interface)
+	if err != nil {
+		return nil, err
+	}
+	conn.claims = claims
+	if err := handle.SetInterfaceAltSetting(conn.Interface, conn.AlternateSetting); err != nil {
+		return nil, errors.Join(
+			fmt.Errorf(
+				"printer: selecting alternate setting %d: %w",
+				conn.AlternateSetting,
+				err,
+			),
+			conn.Close(),
+		)
+	}
+	return conn, nil
+}
+
+// Read reads from the bulk IN endpoint. It returns os.ErrDeadlineExceeded
+// once the read deadline passes and net.ErrClosed after Close.
+func (conn *Conn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
+	for {
+		conn.mu.Lock()
+		closed, deadline := conn.closed, conn.readDeadline
+		conn.mu.Unlock()
+		if closed {
+			return 0, net.ErrClosed
+		}
+		timeout := pollInterval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timeout = min(timeout, remaining)
+		}
+		n, err := conn.reader.ReadPacket(p, max(int(timeout/time.Millisecond), 1))
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("printer: read: %w", err)
+		}
+		// Printers send zero-length packets while they have nothing to say.
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Write writes to the bulk OUT endpoint. It returns os.ErrDeadlineExceeded
+// if the printer doesn't take the data by the write deadline.
+func (conn *Conn) Write(p []byte) (int, error) {
+	conn.mu.Lock()
+	closed, deadline := conn.closed, conn.writeDeadline
+	conn.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	timeout := 0
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timeout = max(int(remaining/time.Millisecond), 1)
+	}
+	n, err := usbif.Write(conn.handle, conn.OutEndpoint, p, timeout)
+	if usbif.IsTimeout(err) {
+		return n, os.ErrDeadlineExceeded
+	}
+	if err != nil && err != io.ErrShortWrite {
+		return n, fmt.Errorf("printer: write: %w", err)
+	}
+	return n, err
+}
+
+// Close waits for a blocked Read to notice, within the poll interval, and
+// then releases the interface. Calling Close again returns net.ErrClosed.
+func (conn *Conn) Close() error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return net.ErrClosed
+	}
+	conn.closed = true
+	conn.mu.Unlock()
+	// Releasing the interface under a pending transfer fails, so wait for
+	// Read to notice.
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
+	return conn.claims.Release()
+}
+
+// LocalAddr returns the address of the interface.
+func (conn *Conn) LocalAddr() net.Addr {
+	return Addr{Interface: conn.Interface}
+}
+
+// RemoteAddr returns the address of the interface.
+func (conn *Conn) RemoteAddr() net.Addr {
+	return Addr{Interface: conn.Interface}
+}
+
+// SetDeadline sets both the read and write deadlines.
+func (conn *Conn) SetDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.readDeadline, conn.writeDeadline = t, t
+	return nil
+}
+
+// SetReadDeadline sets the deadline for Read. A zero time means no
+// deadline.
+func (conn *Conn) SetReadDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write. A zero time means no
+// deadline.
+func (conn *Conn) SetWriteDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.writeDeadline = t
+	return nil
+}
+
+// drain discards whatever the printer still has to send, after a request
+// that failed part way, so that the next request on the interface starts
+// clean.
+func (conn *Conn) drain() {
+	conn.SetReadDeadline(time.Now().Add(2 * pollInterval))
+	io.Copy(io.Discard, conn)
+	conn.SetReadDeadline(time.Time{})
+}
+
+// Transport is an http.RoundTripper that sends HTTP requests over the IPP
+// over USB interfaces of a printer, one request at a time on each. It is
+// safe for concurrent use; requests wait for a free interface.
+//
+// The printer answers on any URL, so requests are usually made to
+// http://localhost/ipp/print and the like.
+type Transport struct {
+	conns []*Conn
+	idle  chan *transportConn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type transportConn struct {
+	conn   *Conn
+	reader *bufio.Reader
+}
+
+// NewTransport opens every IPP over USB interface of a configuration.
+func NewTransport(handle Handle, config *libusb.ConfigDescriptor) (*Transport, error) {
+	ifaces := FindIPPInterfaces(config)
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("printer: configuration has no IPP over USB interface")
+	}
+	transport := &Transport{idle: make(chan *transportConn, len(ifaces))}
+	for _, iface := range ifaces {
+		conn, err := OpenConn(handle, iface)
+		if err != nil {
+			return nil, errors.Join(err, transport.Close())
+		}
+		transport.conns = append(transport.conns, conn)
+		transport.idle <- &transportConn{conn: conn, reader: bufio.NewReader(conn)}
+	}
+	return transport, nil
+}
+
+// Conns returns the number of interfaces, which is the number of requests
+// the transport can have in flight.
+func (transport *Transport) Conns() int {
+	return len(transport.conns)
+}
+
+// RoundTrip implements http.RoundTripper. The response body must be read or
+// closed to free the interface for the next request; closing it early reads
+// the rest of the body, since an IPP over USB connection can't be ended.
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	var tc *transportConn
+	select {
+	case tc = <-transport.idle:
+	case <-ctx.Done():
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if transport.isClosed() {
+		transport.idle <- tc
+		return nil, net.ErrClosed
+	}
+
+	deadline, _ := ctx.Deadline()
+	tc.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		// A past deadline wakes a blocked Read.
+		tc.conn.SetReadDeadline(time.Unix(1, 0))
+	})
+	fail := func(err error) (*http.Response, error) {
+		stop()
+		tc.conn.drain()
+		tc.reader.Reset(tc.conn)
+		transport.idle <- tc
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("printer: %s %s: %w", req.Method, req.URL, err)
+	}
+
+	if err := req.Write(tc.conn); err != nil {
+		return fail(err)
+	}
+	resp, err := http.ReadResponse(tc.reader, req)
+	if err != nil {
+		return fail(err)
+	}
+	release := func(broken bool) {
+		stop()
+		if broken {
+			tc.conn.drain()
+			tc.reader.Reset(tc.conn)
+		}
+		tc.conn.SetDeadline(time.Time{})
+		transport.idle <- tc
+	}
+	if resp.Body == http.NoBody {
+		release(false)
+		return resp, nil
+	}
+	resp.Body = &responseBody{body: resp.Body, release: release}
+	return resp, nil
+}
+
+// Close closes the interfaces. Requests in flight fail.
+func (transport *Transport) Close() error {
+	transport.mu.Lock()
+	if transport.closed {
+		transport.mu.Unlock()
+		return net.ErrClosed
+	}
+	transport.closed = true
+	transport.mu.Unlock()
+	var errs []error
+	for _, conn := range transport.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (transport *Transport) isClosed() bool {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return transport.closed
+}
+
+// responseBody returns the interface to the pool once the body has been
+// read to the end or closed. A body that fails part way leaves the rest of
+// the response on the interface, which is drained first.
+type responseBody struct {
+	body    io.ReadCloser
+	once    sync.Once
+	release func(broken bool)
+}
+
+func (rb *responseBody) Read(p []byte) (int, error) {
+	n, err := rb.body.Read(p)
+	if err != nil {
+		rb.done(err)
+	}
+	return n, err
+}
+
+func (rb *responseBody) Close() error {
+	// The rest of the response is still on its way and has to be consumed
+	// before the interface can carry another request.
+	_, err := io.Copy(io.Discard, rb.body)
+	rb.done(err)
+	if err != nil {
+		return err
+	}
+	return rb.body.Close()
+}
+
+func (rb *responseBody) done(err error) {
+	rb.once.Do(func() {
+		rb.release(err != nil && !errors.Is(err, io.EOF))
+	})
+}
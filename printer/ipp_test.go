@@ -0,0 +1,237 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package printer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// fakeIPP is a printer with an HTTP server behind each IPP over USB
+// interface. Interface i has endpoints 0x01+i and 0x81+i.
+type fakeIPP struct {
+	fakeHandle
+	requests map[libusb.EndpointAddress]*io.PipeWriter
+	packets  map[libusb.EndpointAddress]chan []byte
+}
+
+func newFakeIPP(interfaces int) *fakeIPP {
+	fi := &fakeIPP{
+		requests: make(map[libusb.EndpointAddress]*io.PipeWriter),
+		packets:  make(map[libusb.EndpointAddress]chan []byte),
+	}
+	for i := 0; i < interfaces; i++ {
+		r, w := io.Pipe()
+		packets := make(chan []byte, 1024)
+		fi.requests[libusb.EndpointAddress(0x01+i)] = w
+		fi.packets[libusb.EndpointAddress(0x81+i)] = packets
+		go serveIPP(i, r, packets)
+	}
+	return fi
+}
+
+func (fi *fakeIPP) stop() {
+	for _, w := range fi.requests {
+		w.Close()
+	}
+}
+
+// serveIPP answers each request with its interface, method, path, and body,
+// split into 64-byte packets. Requests for /hang get no answer.
+func serveIPP(iface int, r io.Reader, packets chan<- []byte) {
+	br := bufio.NewReader(r)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		if req.URL.Path == "/hang" {
+			continue
+		}
+		content := fmt.Sprintf("%d %s %s %s", iface, req.Method, req.URL.Path, body)
+		resp := fmt.Sprintf(
+			"HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: %d\r\n\r\n%s",
+			len(content),
+			content,
+		)
+		for len(resp) > 0 {
+			n := min(len(resp), 64)
+			packets <- []byte(resp[:n])
+			resp = resp[n:]
+		}
+	}
+}
+
+func (fi *fakeIPP) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		return fi.requests[endpoint].Write(data[:length])
+	}
+	select {
+	case packet := <-fi.packets[endpoint]:
+		return copy(data[:length], packet), nil
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return 0, libusb.ErrTimeout
+	}
+}
+
+func ippConfig(interfaces int) *libusb.ConfigDescriptor {
+	config := &libusb.ConfigDescriptor{}
+	for i := 0; i < interfaces; i++ {
+		bidi := printerInterface(0, ProtocolBidirectional)
+		out := libusb.EndpointAddress(0x01 + i)
+		in := libusb.EndpointAddress(0x81 + i)
+		ipp := &libusb.InterfaceDescriptor{
+			InterfaceNumber:   i,
+			AlternateSetting:  1,
+			InterfaceClass:    libusb.InterfaceClassPrinter,
+			InterfaceSubClass: SubclassPrinter,
+			InterfaceProtocol: uint8(ProtocolIPPOverUSB),
+			EndpointDescriptors: []*libusb.EndpointDescriptor{
+				{EndpointAddress: out, Attributes: 0x02, MaxPacketSize: 64},
+				{EndpointAddress: in, Attributes: 0x02, MaxPacketSize: 64},
+			},
+		}
+		bidi.InterfaceNumber = i
+		config.SupportedInterfaces = append(config.SupportedInterfaces, &libusb.SupportedInterface{
+			InterfaceDescriptors: libusb.InterfaceDescriptors{bidi, ipp},
+		})
+	}
+	return config
+}
+
+func TestFindIPPInterfaces(t *testing.T) {
+	found := FindIPPInterfaces(ippConfig(3))
+	if len(found) != 3 {
+		t.Fatalf("FindIPPInterfaces returned %d interfaces, want 3", len(found))
+	}
+	for i, iface := range found {
+		if iface.InterfaceNumber != i || iface.AlternateSetting != 1 {
+			t.Errorf("interface %d = %d/%d", i, iface.InterfaceNumber, iface.AlternateSetting)
+		}
+	}
+	if _, err := NewTransport(&fakeHandle{}, ippConfig(0)); err == nil {
+		t.Error("NewTransport without IPP interfaces: expected error, got nil")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	fi := newFakeIPP(2)
+	defer fi.stop()
+	transport, err := NewTransport(fi, ippConfig(2))
+	if err != nil {
+		t.Fatalf("NewTransport: unexpected error %v", err)
+	}
+	if transport.Conns() != 2 {
+		t.Errorf("Conns() = %d, want 2", transport.Conns())
+	}
+	client := &http.Client{Transport: transport}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := strings.Repeat("x", i*20)
+			resp, err := client.Post("http://localhost/ipp/print", "application/ipp",
+				strings.NewReader(body))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !strings.HasSuffix(string(got), " POST /ipp/print "+body) {
+				errs <- fmt.Errorf("request %d: response %q", i, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// A cancelled request gives its interface back to the pool.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/hang", nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request without an answer: got %v, want context.DeadlineExceeded", err)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://localhost/ipp/status")
+		if err != nil {
+			t.Fatalf("request after cancel: unexpected error %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if _, err := client.Get("http://localhost/"); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("request after Close: got %v, want net.ErrClosed", err)
+	}
+}
+
+func TestConnDeadlines(t *testing.T) {
+	fi := newFakeIPP(1)
+	defer fi.stop()
+	conn, err := OpenConn(fi, FindIPPInterfaces(ippConfig(1))[0])
+	if err != nil {
+		t.Fatalf("OpenConn: unexpected error %v", err)
+	}
+	var _ net.Conn = conn
+	if got := conn.RemoteAddr().String(); got != "interface 0" {
+		t.Errorf("RemoteAddr() = %q", got)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	buf := make([]byte, 16)
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read past the deadline: got %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	done := make(chan error)
+	go func() {
+		_, err := conn.Read(buf)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if err := <-done; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read during Close: got %v, want net.ErrClosed", err)
+	}
+	if err := conn.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("second Close: got %v, want net.ErrClosed", err)
+	}
+}
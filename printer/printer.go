@@ -0,0 +1,390 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package printer implements the USB Printer Class on top of libusb.
+
+A printer interface has up to three alternate settings, one for each of the
+unidirectional, bidirectional, and IEEE 1284.4 protocols. FindInterface picks
+the alternate setting for the protocols the caller can speak, and Open claims
+it, detaching the kernel's usblp driver if it is bound. The returned Printer
+is an io.Writer over the bulk OUT endpoint, and for the bidirectional
+protocols an io.Reader over the bulk IN endpoint too.
+
+The class requests are available as DeviceID, which fetches and parses the
+IEEE 1284 device ID string, PortStatus, and SoftReset.
+
+Printers that implement IPP over USB have several interfaces with an IPP
+protocol alternate setting, each carrying one HTTP connection. Transport
+opens all of them and serves as the http.RoundTripper of an http.Client, so
+IPP clients can talk to the printer directly; OpenConn gives a single
+interface as a net.Conn.
+*/
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Printer.Timeout that Open sets, in milliseconds.
+// Printers stop taking data while they are busy or out of paper, so writes
+// may need a longer timeout, or none.
+const DefaultTimeout = 5000
+
+// SubclassPrinter is the interface subclass of printers.
+const SubclassPrinter = 0x01
+
+// Printer class requests.
+const (
+	requestGetDeviceID   = 0x00
+	requestGetPortStatus = 0x01
+	requestSoftReset     = 0x02
+)
+
+// Protocol is the interface protocol of a printer alternate setting.
+type Protocol uint8
+
+// Printer interface protocols.
+const (
+	ProtocolUnidirectional Protocol = 0x01
+	ProtocolBidirectional  Protocol = 0x02
+	Protocol1284_4         Protocol = 0x03
+	ProtocolIPPOverUSB     Protocol = 0x04
+)
+
+var protocols = map[Protocol]string{
+	ProtocolUnidirectional: "unidirectional",
+	ProtocolBidirectional:  "bidirectional",
+	Protocol1284_4:         "IEEE 1284.4",
+	ProtocolIPPOverUSB:     "IPP over USB",
+}
+
+// String implements the Stringer interface for Protocol.
+func (protocol Protocol) String() string {
+	if s, ok := protocols[protocol]; ok {
+		return s
+	}
+	return fmt.Sprintf("protocol %#02x", uint8(protocol))
+}
+
+// DefaultProtocols is the order in which FindInterface prefers the
+// protocols when none are given. IEEE 1284.4 is left out because it needs a
+// packet protocol of its own on top of the bulk endpoints.
+var DefaultProtocols = []Protocol{ProtocolBidirectional, ProtocolUnidirectional}
+
+// Handle is what a Printer or Conn needs of a *libusb.DeviceHandle: claiming
+// the interface and selecting its protocol, the printer class requests,
+// and bulk transfers.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+}
+
+// FindInterfaces returns every alternate setting of every printer interface
+// in a configuration.
+func FindInterfaces(config *libusb.ConfigDescriptor) libusb.InterfaceDescriptors {
+	if config == nil {
+		return nil
+	}
+	var found libusb.InterfaceDescriptors
+	for _, iface := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassPrinter,
+	) {
+		if iface.InterfaceSubClass == SubclassPrinter {
+			found = append(found, iface)
+		}
+	}
+	return found
+}
+
+// FindInterface returns the printer alternate setting that speaks the
+// first of protocols the configuration offers, or nil if it offers none of
+// them. With no protocols, DefaultProtocols is used.
+func FindInterface(
+	config *libusb.ConfigDescriptor,
+	protocols ...Protocol,
+) *libusb.InterfaceDescriptor {
+	if len(protocols) == 0 {
+		protocols = DefaultProtocols
+	}
+	ifaces := FindInterfaces(config)
+	for _, protocol := range protocols {
+		for _, iface := range ifaces {
+			if Protocol(iface.InterfaceProtocol) != protocol {
+				continue
+			}
+			in, out := bulkEndpoints(iface)
+			if out != nil && (in != nil || protocol == ProtocolUnidirectional) {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+func bulkEndpoints(iface *libusb.InterfaceDescriptor) (in, out *libusb.EndpointDescriptor) {
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.BulkTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && in == nil {
+			in = ep
+		} else if ep.Direction() == libusb.EndpointOut && out == nil {
+			out = ep
+		}
+	}
+	return in, out
+}
+
+// Printer is a claimed printer interface. Read and Write may be called
+// concurrently with each other, but not with themselves.
+type Printer struct {
+	handle           Handle
+	Interface        int
+	AlternateSetting int
+	Protocol         Protocol
+	// InEndpoint is zero for the unidirectional protocol.
+	InEndpoint  libusb.EndpointAddress
+	OutEndpoint libusb.EndpointAddress
+	// ConfigIndex is the zero-based index of the active configuration, which
+	// GET_DEVICE_ID needs. It is zero for nearly every printer.
+	ConfigIndex int
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	// reader is nil for the unidirectional protocol.
+	reader *usbif.Reader
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims a printer interface from usblp, if that driver has it, and
+// selects the alternate setting of iface.
+func Open(handle Handle, iface *libusb.InterfaceDescriptor) (*Printer, error) {
+	if handle == nil || iface == nil {
+		return nil, fmt.Errorf("printer: nil handle or interface descriptor")
+	}
+	in, out := bulkEndpoints(iface)
+	if out == nil {
+		return nil, fmt.Errorf(
+			"printer: interface %d alternate setting %d has no bulk OUT endpoint",
+			iface.InterfaceNumber,
+			iface.AlternateSetting,
+		)
+	}
+	prn := &Printer{
+		handle:           handle,
+		Interface:        iface.InterfaceNumber,
+		AlternateSetting: iface.AlternateSetting,
+		Protocol:         Protocol(iface.InterfaceProtocol),
+		OutEndpoint:      out.EndpointAddress,
+		Timeout:          DefaultTimeout,
+	}
+	if in != nil {
+		prn.InEndpoint = in.EndpointAddress
+		prn.reader = usbif.NewReader(handle, in.EndpointAddress, int(in.MaxPacketSize))
+	}
+
+	claims, err := usbif.Claim(handle, "printer", prn.Interface)
+	if err != nil {
+		return nil, err
+	}
+	prn.claims = claims
+	// Printers with several protocols start in alternate setting 0, so the
+	// setting is selected even when it is the default.
+	if err := handle.SetInterfaceAltSetting(prn.Interface, prn.AlternateSetting); err != nil {
+		return nil, errors.Join(
+			fmt.Errorf(
+				"printer: selecting alternate setting %d: %w",
+				prn.AlternateSetting,
+				err,
+			),
+			prn.Close(),
+		)
+	}
+	return prn, nil
+}
+
+// Close releases the printer interface, handing it back to usblp if Open
+// took it from there. Data already written may still be printing. Calling
+// Close again returns os.ErrClosed.
+func (prn *Printer) Close() error {
+	prn.mu.Lock()
+	defer prn.mu.Unlock()
+	if prn.closed {
+		return os.ErrClosed
+	}
+	prn.closed = true
+	return prn.claims.Release()
+}
+
+func (prn *Printer) isClosed() bool {
+	prn.mu.Lock()
+	defer prn.mu.Unlock()
+	return prn.closed
+}
+
+// Write sends print data to the bulk OUT endpoint.
+func (prn *Printer) Write(p []byte) (int, error) {
+	if prn.isClosed() {
+		return 0, os.ErrClosed
+	}
+	n, err := usbif.Write(prn.handle, prn.OutEndpoint, p, prn.Timeout)
+	if err != nil && err != io.ErrShortWrite {
+		return n, fmt.Errorf("printer: write: %w", err)
+	}
+	return n, err
+}
+
+// Read reads status or scan data from the bulk IN endpoint of a
+// bidirectional printer.
+func (prn *Printer) Read(p []byte) (int, error) {
+	if prn.isClosed() {
+		return 0, os.ErrClosed
+	}
+	if prn.InEndpoint == 0 {
+		return 0, fmt.Errorf("printer: %v protocol has no IN endpoint", prn.Protocol)
+	}
+	n, err := prn.reader.Read(p, prn.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("printer: read: %w", err)
+	}
+	return n, nil
+}
+
+// PortStatus is the GET_PORT_STATUS reply, modelled on the status lines of
+// a parallel port.
+type PortStatus byte
+
+// Port status bits.
+const (
+	portStatusNotError   PortStatus = 0x08
+	portStatusSelect     PortStatus = 0x10
+	portStatusPaperEmpty PortStatus = 0x20
+)
+
+// PaperEmpty reports whether the printer is out of paper.
+func (status PortStatus) PaperEmpty() bool {
+	return status&portStatusPaperEmpty != 0
+}
+
+// Selected reports whether the printer is selected, that is online.
+func (status PortStatus) Selected() bool {
+	return status&portStatusSelect != 0
+}
+
+// HasError reports whether the printer signals an error.
+func (status PortStatus) HasError() bool {
+	return status&portStatusNotError == 0
+}
+
+// String lists the conditions the status reports.
+func (status PortStatus) String() string {
+	s := "not selected"
+	if status.Selected() {
+		s = "selected"
+	}
+	if status.PaperEmpty() {
+		s += ", paper empty"
+	}
+	if status.HasError() {
+		s += ", error"
+	}
+	return s
+}
+
+// PortStatus returns the printer's port status.
+func (prn *Printer) PortStatus() (PortStatus, error) {
+	if prn.isClosed() {
+		return 0, os.ErrClosed
+	}
+	buf := make([]byte, 1)
+	n, err := prn.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetPortStatus,
+		0,
+		uint16(prn.Interface),
+		buf,
+		len(buf),
+		prn.Timeout,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("printer: get port status: %w", err)
+	}
+	if n != 1 {
+		return 0, fmt.Errorf("printer: get port status returned %d bytes", n)
+	}
+	return PortStatus(buf[0]), nil
+}
+
+// SoftReset flushes the printer's buffers and resets its bulk endpoints
+// without changing its configuration.
+//
+// Version 1.0 of the class specification addressed the request to the
+// "other" recipient, which version 1.1 corrected to the interface. Printers
+// that stall the corrected form are sent the old one.
+func (prn *Printer) SoftReset() error {
+	if prn.isClosed() {
+		return os.ErrClosed
+	}
+	err := prn.softReset(libusb.InterfaceRecipient)
+	if isStall(err) {
+		err = prn.softReset(libusb.OtherRecipient)
+	}
+	if err != nil {
+		return fmt.Errorf("printer: soft reset: %w", err)
+	}
+	return nil
+}
+
+func (prn *Printer) softReset(recipient libusb.RequestRecipient) error {
+	_, err := prn.handle.ControlOut(
+		libusb.Class,
+		recipient,
+		requestSoftReset,
+		0,
+		uint16(prn.Interface),
+		nil,
+		prn.Timeout,
+	)
+	return err
+}
+
+// isStall reports whether err is a libusb stall, using the Stall method of
+// libusb.ErrorCode.
+func isStall(err error) bool {
+	var stall interface{ Stall() bool }
+	return errors.As(err, &stall) && stall.Stall()
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	deviceID []byte
+	status   byte
+	// stallInterfaceReset makes SOFT_RESET to the interface stall, as 1.0
+	// printers do.
+	stallInterfaceReset bool
+	written             []byte
+	toRead              [][]byte
+	lastValue           uint16
+	lastIndex           uint16
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.lastValue, fh.lastIndex = value, index
+	switch request {
+	case requestGetDeviceID:
+		return copy(data[:maxReceiveLength], fh.deviceID), nil
+	case requestGetPortStatus:
+		data[0] = fh.status
+		return 1, nil
+	}
+	return 0, libusb.ErrPipe
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	if request != requestSoftReset {
+		return 0, libusb.ErrPipe
+	}
+	if recipient == libusb.InterfaceRecipient && fh.stallInterfaceReset {
+		return 0, libusb.ErrPipe
+	}
+	fh.Record("soft reset %d", recipient)
+	return 0, nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, data[:length]...)
+		return length, nil
+	}
+	if len(fh.toRead) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	n := copy(data[:length], fh.toRead[0])
+	fh.toRead = fh.toRead[1:]
+	return n, nil
+}
+
+func printerInterface(alt int, protocol Protocol) *libusb.InterfaceDescriptor {
+	iface := &libusb.InterfaceDescriptor{
+		AlternateSetting:  alt,
+		InterfaceClass:    libusb.InterfaceClassPrinter,
+		InterfaceSubClass: SubclassPrinter,
+		InterfaceProtocol: uint8(protocol),
+		EndpointDescriptors: []*libusb.EndpointDescriptor{
+			{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 64},
+		},
+	}
+	if protocol != ProtocolUnidirectional {
+		iface.EndpointDescriptors = append(iface.EndpointDescriptors,
+			&libusb.EndpointDescriptor{EndpointAddress: 0x82, Attributes: 0x02, MaxPacketSize: 64})
+	}
+	return iface
+}
+
+func TestFindInterface(t *testing.T) {
+	config := &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{
+				printerInterface(0, ProtocolUnidirectional),
+				printerInterface(1, ProtocolBidirectional),
+				printerInterface(2, Protocol1284_4),
+			}},
+		},
+	}
+	testCases := []struct {
+		protocols []Protocol
+		expected  int
+	}{
+		{nil, 1},
+		{[]Protocol{ProtocolUnidirectional}, 0},
+		{[]Protocol{Protocol1284_4, ProtocolBidirectional}, 2},
+		{[]Protocol{ProtocolIPPOverUSB}, -1},
+	}
+	for _, tc := range testCases {
+		iface := FindInterface(config, tc.protocols...)
+		got := -1
+		if iface != nil {
+			got = iface.AlternateSetting
+		}
+		if got != tc.expected {
+			t.Errorf("FindInterface(%v) = alternate setting %d, want %d",
+				tc.protocols, got, tc.expected)
+		}
+	}
+	if n := len(FindInterfaces(config)); n != 3 {
+		t.Errorf("FindInterfaces returned %d interfaces, want 3", n)
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	iface := printerInterface(1, ProtocolBidirectional)
+	iface.InterfaceNumber = 2
+	prn, err := Open(fh, iface)
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	if prn.InEndpoint != 0x82 || prn.OutEndpoint != 0x01 || prn.Protocol != ProtocolBidirectional {
+		t.Errorf("Open = %+v", prn)
+	}
+	if err := prn.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 2", "claim 2", "alt 2 1", "release 2", "attach 2"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := prn.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := prn.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	fh := &fakeHandle{toRead: [][]byte{[]byte("@PJL INFO STATUS\r\n")}}
+	prn, err := Open(fh, printerInterface(1, ProtocolBidirectional))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := []byte("\x1b%-12345X@PJL\r\n")
+	if n, err := prn.Write(job); err != nil || n != len(job) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if string(fh.written) != string(job) {
+		t.Errorf("written = %q, want %q", fh.written, job)
+	}
+	buf := make([]byte, 5)
+	var got []byte
+	for {
+		n, err := prn.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if string(got) != "@PJL INFO STATUS\r\n" {
+		t.Errorf("Read = %q", got)
+	}
+
+	uni, err := Open(&fakeHandle{}, printerInterface(0, ProtocolUnidirectional))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uni.Read(buf); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Read on a unidirectional printer: got %v, want error", err)
+	}
+}
+
+func TestPortStatus(t *testing.T) {
+	testCases := []struct {
+		given      byte
+		paperEmpty bool
+		selected   bool
+		hasError   bool
+		str        string
+	}{
+		{0x18, false, true, false, "selected"},
+		{0x38, true, true, false, "selected, paper empty"},
+		{0x00, false, false, true, "not selected, error"},
+	}
+	for _, tc := range testCases {
+		fh := &fakeHandle{status: tc.given}
+		prn, err := Open(fh, printerInterface(0, ProtocolUnidirectional))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := prn.PortStatus()
+		if err != nil {
+			t.Fatalf("PortStatus: unexpected error %v", err)
+		}
+		if status.PaperEmpty() != tc.paperEmpty || status.Selected() != tc.selected ||
+			status.HasError() != tc.hasError {
+			t.Errorf("%#02x: decoded wrong", tc.given)
+		}
+		if got := status.String(); got != tc.str {
+			t.Errorf("%#02x: String() = %q, want %q", tc.given, got, tc.str)
+		}
+	}
+}
+
+func TestSoftReset(t *testing.T) {
+	testCases := []struct {
+		stall    bool
+		expected string
+	}{
+		{false, fmt.Sprintf("soft reset %d", libusb.InterfaceRecipient)},
+		{true, fmt.Sprintf("soft reset %d", libusb.OtherRecipient)},
+	}
+	for _, tc := range testCases {
+		fh := &fakeHandle{stallInterfaceReset: tc.stall}
+		prn, err := Open(fh, printerInterface(0, ProtocolUnidirectional))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fh.Reset()
+		if err := prn.SoftReset(); err != nil {
+			t.Fatalf("SoftReset: unexpected error %v", err)
+		}
+		if calls := fh.Calls(); !slices.Equal(calls, []string{tc.expected}) {
+			t.Errorf("stall %t: calls = %q, want %q", tc.stall, calls, tc.expected)
+		}
+	}
+}
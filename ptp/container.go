@@ -0,0 +1,458 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Container types.
+const (
+	containerCommand  = 1
+	containerData     = 2
+	containerResponse = 3
+	containerEvent    = 4
+)
+
+// headerSize is the size of the header of every container.
+const headerSize = 12
+
+// maxParams is the most parameters an operation or response carries.
+const maxParams = 5
+
+// unknownLength is the container length of a data phase too large for
+// its length field, which then ends with a short packet.
+const unknownLength = 0xFFFFFFFF
+
+// transferSize is the size of the bulk transfers a data phase is moved in.
+const transferSize = 256 * 1024
+
+// transferBufferSize rounds transferSize to whole packets.
+func transferBufferSize(packetSize int) int {
+	packetSize = max(packetSize, 1)
+	return max(transferSize/packetSize, 1) * packetSize
+}
+
+// OperationCode identifies a PTP or MTP operation.
+type OperationCode uint16
+
+// Operation codes.
+const (
+	OpGetDeviceInfo           OperationCode = 0x1001
+	OpOpenSession             OperationCode = 0x1002
+	OpCloseSession            OperationCode = 0x1003
+	OpGetStorageIDs           OperationCode = 0x1004
+	OpGetStorageInfo          OperationCode = 0x1005
+	OpGetNumObjects           OperationCode = 0x1006
+	OpGetObjectHandles        OperationCode = 0x1007
+	OpGetObjectInfo           OperationCode = 0x1008
+	OpGetObject               OperationCode = 0x1009
+	OpGetThumb                OperationCode = 0x100A
+	OpDeleteObject            OperationCode = 0x100B
+	OpSendObjectInfo          OperationCode = 0x100C
+	OpSendObject              OperationCode = 0x100D
+	OpInitiateCapture         OperationCode = 0x100E
+	OpFormatStore             OperationCode = 0x100F
+	OpResetDevice             OperationCode = 0x1010
+	OpGetDevicePropDesc       OperationCode = 0x1014
+	OpGetDevicePropValue      OperationCode = 0x1015
+	OpSetDevicePropValue      OperationCode = 0x1016
+	OpGetPartialObject        OperationCode = 0x101B
+	OpGetObjectPropsSupported OperationCode = 0x9801
+	OpGetObjectPropDesc       OperationCode = 0x9802
+	OpGetObjectPropValue      OperationCode = 0x9803
+	OpSetObjectPropValue      OperationCode = 0x9804
+	OpGetObjectPropList       OperationCode = 0x9805
+	OpSetObjectPropList       OperationCode = 0x9806
+	OpSendObjectPropList      OperationCode = 0x9808
+)
+
+var operationNames = map[OperationCode]string{
+	OpGetDeviceInfo:           "GetDeviceInfo",
+	OpOpenSession:             "OpenSession",
+	OpCloseSession:            "CloseSession",
+	OpGetStorageIDs:           "GetStorageIDs",
+	OpGetStorageInfo:          "GetStorageInfo",
+	OpGetNumObjects:           "GetNumObjects",
+	OpGetObjectHandles:        "GetObjectHandles",
+	OpGetObjectInfo:           "GetObjectInfo",
+	OpGetObject:               "GetObject",
+	OpGetThumb:                "GetThumb",
+	OpDeleteObject:            "DeleteObject",
+	OpSendObjectInfo:          "SendObjectInfo",
+	OpSendObject:              "SendObject",
+	OpInitiateCapture:         "InitiateCapture",
+	OpFormatStore:             "FormatStore",
+	OpResetDevice:             "ResetDevice",
+	OpGetDevicePropDesc:       "GetDevicePropDesc",
+	OpGetDevicePropValue:      "GetDevicePropValue",
+	OpSetDevicePropValue:      "SetDevicePropValue",
+	OpGetPartialObject:        "GetPartialObject",
+	OpGetObjectPropsSupported: "GetObjectPropsSupported",
+	OpGetObjectPropDesc:       "GetObjectPropDesc",
+	OpGetObjectPropValue:      "GetObjectPropValue",
+	OpSetObjectPropValue:      "SetObjectPropValue",
+	OpGetObjectPropList:       "GetObjectPropList",
+	OpSetObjectPropList:       "SetObjectPropList",
+	OpSendObjectPropList:      "SendObjectPropList",
+}
+
+// String implements the Stringer interface for OperationCode.
+func (op OperationCode) String() string {
+	if name, ok := operationNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("operation %#04x", uint16(op))
+}
+
+// ResponseCode is the code of a response container.
+type ResponseCode uint16
+
+// Response codes.
+const (
+	ResponseOK                      ResponseCode = 0x2001
+	ResponseGeneralError            ResponseCode = 0x2002
+	ResponseSessionNotOpen          ResponseCode = 0x2003
+	ResponseInvalidTransactionID    ResponseCode = 0x2004
+	ResponseOperationNotSupported   ResponseCode = 0x2005
+	ResponseParameterNotSupported   ResponseCode = 0x2006
+	ResponseIncompleteTransfer      ResponseCode = 0x2007
+	ResponseInvalidStorageID        ResponseCode = 0x2008
+	ResponseInvalidObjectHandle     ResponseCode = 0x2009
+	ResponseInvalidObjectFormatCode ResponseCode = 0x200B
+	ResponseStoreFull               ResponseCode = 0x200C
+	ResponseObjectWriteProtected    ResponseCode = 0x200D
+	ResponseStoreReadOnly           ResponseCode = 0x200E
+	ResponseAccessDenied            ResponseCode = 0x200F
+	ResponseNoThumbnailPresent      ResponseCode = 0x2010
+	ResponseStoreNotAvailable       ResponseCode = 0x2013
+	ResponseDeviceBusy              ResponseCode = 0x2019
+	ResponseInvalidParentObject     ResponseCode = 0x201A
+	ResponseInvalidParameter        ResponseCode = 0x201D
+	ResponseSessionAlreadyOpen      ResponseCode = 0x201E
+	ResponseTransactionCancelled    ResponseCode = 0x201F
+	ResponseInvalidObjectPropCode   ResponseCode = 0xA801
+	ResponseInvalidObjectPropFormat ResponseCode = 0xA802
+	ResponseInvalidObjectPropValue  ResponseCode = 0xA803
+	ResponseGroupNotSupported       ResponseCode = 0xA805
+	ResponseSpecByGroupUnsupported  ResponseCode = 0xA807
+	ResponseSpecByDepthUnsupported  ResponseCode = 0xA808
+	ResponseObjectTooLarge          ResponseCode = 0xA809
+	ResponseObjectPropNotSupported  ResponseCode = 0xA80A
+)
+
+var responseNames = map[ResponseCode]string{
+	ResponseOK:                      "OK",
+	ResponseGeneralError:            "general error",
+	ResponseSessionNotOpen:          "session not open",
+	ResponseInvalidTransactionID:    "invalid transaction ID",
+	ResponseOperationNotSupported:   "operation not supported",
+	ResponseParameterNotSupported:   "parameter not supported",
+	ResponseIncompleteTransfer:      "incomplete transfer",
+	ResponseInvalidStorageID:        "invalid storage ID",
+	ResponseInvalidObjectHandle:     "invalid object handle",
+	ResponseInvalidObjectFormatCode: "invalid object format code",
+	ResponseStoreFull:               "store full",
+	ResponseObjectWriteProtected:    "object write-protected",
+	ResponseStoreReadOnly:           "store read-only",
+	ResponseAccessDenied:            "access denied",
+	ResponseNoThumbnailPresent:      "no thumbnail present",
+	ResponseStoreNotAvailable:       "store not available",
+	ResponseDeviceBusy:              "device busy",
+	ResponseInvalidParentObject:     "invalid parent object",
+	ResponseInvalidParameter:        "invalid parameter",
+	ResponseSessionAlreadyOpen:      "session already open",
+	ResponseTransactionCancelled:    "transaction cancelled",
+	ResponseInvalidObjectPropCode:   "invalid object property code",
+	ResponseInvalidObjectPropFormat: "invalid object property format",
+	ResponseInvalidObjectPropValue:  "invalid object property value",
+	ResponseGroupNotSupported:       "group not supported",
+	ResponseSpecByGroupUnsupported:  "specification by group unsupported",
+	ResponseSpecByDepthUnsupported:  "specification by depth unsupported",
+	ResponseObjectTooLarge:          "object too large",
+	ResponseObjectPropNotSupported:  "object property not supported",
+}
+
+// String implements the Stringer interface for ResponseCode.
+func (code ResponseCode) String() string {
+	if name, ok := responseNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("response %#04x", uint16(code))
+}
+
+// ResponseError is returned when the device ends an operation with a
+// response code other than ResponseOK.
+type ResponseError struct {
+	Op     OperationCode
+	Code   ResponseCode
+	Params []uint32
+}
+
+func (err *ResponseError) Error() string {
+	return fmt.Sprintf("ptp: %v failed: %v", err.Op, err.Code)
+}
+
+// header is the header shared by every container.
+type header struct {
+	length        uint32
+	kind          uint16
+	code          uint16
+	transactionID uint32
+}
+
+func (h header) marshal(payload int) []byte {
+	data := make([]byte, headerSize, headerSize+payload)
+	binary.LittleEndian.PutUint32(data[0:4], h.length)
+	binary.LittleEndian.PutUint16(data[4:6], h.kind)
+	binary.LittleEndian.PutUint16(data[6:8], h.code)
+	binary.LittleEndian.PutUint32(data[8:12], h.transactionID)
+	return data
+}
+
+func parseHeader(data []byte) header {
+	return header{
+		length:        binary.LittleEndian.Uint32(data[0:4]),
+		kind:          binary.LittleEndian.Uint16(data[4:6]),
+		code:          binary.LittleEndian.Uint16(data[6:8]),
+		transactionID: binary.LittleEndian.Uint32(data[8:12]),
+	}
+}
+
+// parseParams returns the parameters that follow a header.
+func parseParams(data []byte) []uint32 {
+	var params []uint32
+	for i := 0; i+4 <= len(data); i += 4 {
+		params = append(params, binary.LittleEndian.Uint32(data[i:i+4]))
+	}
+	return params
+}
+
+// Transact runs an operation with up to five parameters. If out is not nil,
+// it is sent to the device as the data phase; otherwise a data phase from
+// the device is written to in, or discarded if in is nil. Transact returns
+// the parameters of the response, or a *ResponseError if the response code
+// isn't ResponseOK.
+func (dev *Device) Transact(
+	op OperationCode,
+	params []uint32,
+	out []byte,
+	in io.Writer,
+) ([]uint32, error) {
+	if dev.isClosed() {
+		return nil, os.ErrClosed
+	}
+	dev.txMu.Lock()
+	defer dev.txMu.Unlock()
+	return dev.transact(op, params, out, in)
+}
+
+func (dev *Device) transact(
+	op OperationCode,
+	params []uint32,
+	out []byte,
+	in io.Writer,
+) ([]uint32, error) {
+	if len(params) > maxParams {
+		return nil, fmt.Errorf(
+			"ptp: %v has %d parameters; at most %d fit",
+			op,
+			len(params),
+			maxParams,
+		)
+	}
+	id := dev.nextTransactionID(op)
+	cmd := header{
+		length:        uint32(headerSize + 4*len(params)),
+		kind:          containerCommand,
+		code:          uint16(op),
+		transactionID: id,
+	}.marshal(4 * len(params))
+	for _, p := range params {
+		cmd = binary.LittleEndian.AppendUint32(cmd, p)
+	}
+	if err := dev.write(cmd); err != nil {
+		return nil, fmt.Errorf("ptp: sending %v: %w", op, err)
+	}
+	if out != nil {
+		if err := dev.writeData(op, id, out); err != nil {
+			return nil, fmt.Errorf("ptp: sending data of %v: %w", op, err)
+		}
+	}
+	if in == nil {
+		in = io.Discard
+	}
+	resp, respParams, err := dev.readResponse(op, id, in)
+	if err != nil {
+		return nil, err
+	}
+	if resp != ResponseOK {
+		return nil, &ResponseError{Op: op, Code: resp, Params: respParams}
+	}
+	return respParams, nil
+}
+
+// nextTransactionID returns the ID of the next transaction. Operations
+// outside a session, and OpenSession itself, use ID 0; inside a session IDs
+// count up from 1 and skip 0 and 0xFFFFFFFF when they wrap.
+func (dev *Device) nextTransactionID(op OperationCode) uint32 {
+	if dev.sessionID == 0 || op == OpOpenSession {
+		return 0
+	}
+	id := dev.transactionID
+	dev.transactionID++
+	if dev.transactionID == 0xFFFFFFFF {
+		dev.transactionID = 1
+	}
+	return id
+}
+
+func (dev *Device) write(data []byte) error {
+	n, err := dev.handle.BulkTransfer(
+		dev.Interface.BulkOut.EndpointAddress,
+		data,
+		len(data),
+		dev.Timeout,
+	)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// writeData sends a data container in transfers of up to transferSize
+// bytes, ending it with a zero-length packet if it fills its last packet.
+func (dev *Device) writeData(op OperationCode, id uint32, data []byte) error {
+	length := uint32(unknownLength)
+	if headerSize+int64(len(data)) < unknownLength {
+		length = uint32(headerSize + len(data))
+	}
+	first := min(len(data), transferSize-headerSize)
+	msg := header{
+		length:        length,
+		kind:          containerData,
+		code:          uint16(op),
+		transactionID: id,
+	}.marshal(first)
+	if err := dev.write(append(msg, data[:first]...)); err != nil {
+		return err
+	}
+	for rest := data[first:]; len(rest) > 0; {
+		n := min(len(rest), transferSize)
+		if err := dev.write(rest[:n]); err != nil {
+			return err
+		}
+		rest = rest[n:]
+	}
+	packetSize := max(int(dev.Interface.BulkOut.MaxPacketSize), 1)
+	if (headerSize+len(data))%packetSize == 0 {
+		return dev.write(nil)
+	}
+	return nil
+}
+
+// readResponse reads containers for a transaction until its response,
+// writing the data phase, if any, to in.
+func (dev *Device) readResponse(
+	op OperationCode,
+	id uint32,
+	in io.Writer,
+) (ResponseCode, []uint32, error) {
+	for {
+		n, err := dev.read(dev.buf)
+		if err != nil {
+			return 0, nil, fmt.Errorf("ptp: reading response to %v: %w", op, err)
+		}
+		// A zero-length packet ends a data phase that filled its last
+		// packet, when the transfer reading it didn't take it.
+		if n == 0 {
+			continue
+		}
+		if n < headerSize {
+			return 0, nil, fmt.Errorf("ptp: %d byte container is shorter than its header", n)
+		}
+		h := parseHeader(dev.buf)
+		if h.transactionID != id {
+			return 0, nil, fmt.Errorf(
+				"ptp: container for transaction %d during transaction %d",
+				h.transactionID,
+				id,
+			)
+		}
+		switch h.kind {
+		case containerData:
+			if err := dev.readData(h, n, in); err != nil {
+				return 0, nil, fmt.Errorf("ptp: reading data of %v: %w", op, err)
+			}
+		case containerResponse:
+			end := n
+			if h.length >= headerSize && int64(h.length) < int64(n) {
+				end = int(h.length)
+			}
+			return ResponseCode(h.code), parseParams(dev.buf[headerSize:end]), nil
+		default:
+			return 0, nil, fmt.Errorf("ptp: unexpected container type %d during %v", h.kind, op)
+		}
+	}
+}
+
+// readData writes a data container, whose first n bytes are in the
+// buffer, to w.
+func (dev *Device) readData(h header, n int, w io.Writer) error {
+	if h.length == unknownLength {
+		// The data phase ends with the first short transfer.
+		chunk := dev.buf[headerSize:n]
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return err
+			}
+			if n < len(dev.buf) {
+				return nil
+			}
+			var err error
+			if n, err = dev.read(dev.buf); err != nil {
+				return err
+			}
+			chunk = dev.buf[:n]
+		}
+	}
+	if h.length < headerSize {
+		return fmt.Errorf("container length %d is shorter than its header", h.length)
+	}
+	remaining := int64(h.length) - int64(n)
+	if _, err := w.Write(dev.buf[headerSize:min(n, int(h.length))]); err != nil {
+		return err
+	}
+	for remaining > 0 {
+		n, err := dev.read(dev.buf[:min(int64(len(dev.buf)), remaining)])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if _, err := w.Write(dev.buf[:n]); err != nil {
+			return err
+		}
+		remaining -= int64(n)
+	}
+	return nil
+}
+
+func (dev *Device) read(buf []byte) (int, error) {
+	return dev.handle.BulkTransfer(
+		dev.Interface.BulkIn.EndpointAddress,
+		buf,
+		len(buf),
+		dev.Timeout,
+	)
+}
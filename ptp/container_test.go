@@ -0,0 +1,167 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestTransact(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{
+		container(containerData, 0x9999, 0, []byte{1, 2, 3}),
+		response(ResponseOK, 0, 42),
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	var buf bytes.Buffer
+	params, err := dev.Transact(0x9999, []uint32{5, 6}, nil, &buf)
+	if err != nil {
+		t.Fatalf("Transact: unexpected error %v", err)
+	}
+	if !slices.Equal(params, []uint32{42}) || !bytes.Equal(buf.Bytes(), []byte{1, 2, 3}) {
+		t.Errorf("Transact = %v, data % x", params, buf.Bytes())
+	}
+	want := container(containerCommand, 0x9999, 0, le(uint32(5), uint32(6)))
+	if len(fh.written) != 1 || !bytes.Equal(fh.written[0], want) {
+		t.Errorf("written = % x, want % x", fh.written, want)
+	}
+	if _, err := dev.Transact(0x9999, make([]uint32, 6), nil, nil); err == nil {
+		t.Error("six parameters: expected error, got nil")
+	}
+}
+
+func TestTransactDataOut(t *testing.T) {
+	// 500 bytes of data and the header fill a 512 byte packet, so a
+	// zero-length packet follows.
+	data := bytes.Repeat([]byte{0xAB}, 500)
+	fh := &fakeHandle{replies: [][]byte{response(ResponseOK, 0)}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	if _, err := dev.Transact(OpSendObject, nil, data, nil); err != nil {
+		t.Fatalf("Transact: unexpected error %v", err)
+	}
+	if len(fh.written) != 3 {
+		t.Fatalf("wrote %d transfers, want 3", len(fh.written))
+	}
+	wantData := container(containerData, uint16(OpSendObject), 0, data)
+	if !bytes.Equal(fh.written[1], wantData) || len(fh.written[2]) != 0 {
+		t.Errorf("data phase = % x ... %d bytes, then %d bytes",
+			fh.written[1][:headerSize], len(fh.written[1]), len(fh.written[2]))
+	}
+}
+
+func TestTransactResponseError(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{response(ResponseInvalidObjectHandle, 0, 9)}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	_, err := dev.Transact(OpGetObject, []uint32{9}, nil, nil)
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.Code != ResponseInvalidObjectHandle ||
+		!slices.Equal(respErr.Params, []uint32{9}) {
+		t.Fatalf("Transact: got %v, want a *ResponseError", err)
+	}
+	if got, want := err.Error(), "ptp: GetObject failed: invalid object handle"; got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+}
+
+func TestReadData(t *testing.T) {
+	payload := make([]byte, 150)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	full := container(containerData, uint16(OpGetObject), 0, payload)
+	unknown := slices.Clone(full)
+	copy(unknown[0:4], le(uint32(unknownLength)))
+
+	testCases := []struct {
+		name    string
+		replies [][]byte
+	}{
+		{
+			"several transfers",
+			[][]byte{full[:64], full[64:128], full[128:], response(ResponseOK, 0)},
+		},
+		{
+			"zero-length packet after the data",
+			[][]byte{full[:64], full[64:128], full[128:], {}, response(ResponseOK, 0)},
+		},
+		{
+			"unknown length",
+			[][]byte{unknown[:64], unknown[64:128], unknown[128:], response(ResponseOK, 0)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := &fakeHandle{replies: tc.replies}
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			dev.buf = make([]byte, 64)
+
+			var buf bytes.Buffer
+			if _, err := dev.Transact(OpGetObject, []uint32{1}, nil, &buf); err != nil {
+				t.Fatalf("Transact: unexpected error %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), payload) {
+				t.Errorf("data = % x, want % x", buf.Bytes(), payload)
+			}
+		})
+	}
+}
+
+func TestReadResponseErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		replies [][]byte
+	}{
+		{"short container", [][]byte{{0x0C, 0x00, 0x00}}},
+		{"wrong transaction", [][]byte{response(ResponseOK, 7)}},
+		{"event container", [][]byte{container(containerEvent, 0x4002, 0, nil)}},
+		{"timeout", nil},
+		{
+			"data cut short",
+			[][]byte{container(containerData, 0x1001, 0, make([]byte, 100))[:50], {}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := &fakeHandle{replies: tc.replies}
+			dev := openTestDevice(t, fh)
+			defer dev.Close()
+			dev.buf = make([]byte, 64)
+			if _, err := dev.Transact(OpGetDeviceInfo, nil, nil, nil); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCodeStrings(t *testing.T) {
+	testCases := []struct {
+		code interface{ String() string }
+		want string
+	}{
+		{OpGetObjectPropList, "GetObjectPropList"},
+		{OperationCode(0x9101), "operation 0x9101"},
+		{ResponseDeviceBusy, "device busy"},
+		{ResponseCode(0x2FFF), "response 0x2fff"},
+		{EventCaptureComplete, "CaptureComplete"},
+		{EventCode(0xC101), "event 0xc101"},
+		{PropObjectFileName, "ObjectFileName"},
+		{PropertyCode(0xD101), "property 0xd101"},
+	}
+	for _, tc := range testCases {
+		if got := tc.code.String(); got != tc.want {
+			t.Errorf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
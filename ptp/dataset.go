@@ -0,0 +1,287 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf16"
+)
+
+// decoder reads the little-endian fields of a dataset. After the first
+// field that runs past the end of the data, every read returns zero and
+// err is set.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if n > len(d.data) {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.next(8))
+}
+
+// count reads the element count of an array and checks that that many
+// elements of the size can follow.
+func (d *decoder) count(size int) int {
+	n := d.uint32()
+	if d.err == nil && uint64(n)*uint64(size) > uint64(len(d.data)) {
+		d.err = io.ErrUnexpectedEOF
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) uint16s() []uint16 {
+	vals := make([]uint16, d.count(2))
+	for i := range vals {
+		vals[i] = d.uint16()
+	}
+	return vals
+}
+
+func (d *decoder) uint32s() []uint32 {
+	vals := make([]uint32, d.count(4))
+	for i := range vals {
+		vals[i] = d.uint32()
+	}
+	return vals
+}
+
+// string reads a PTP string: a count of UTF-16 code units, including the
+// terminating null, followed by the code units.
+func (d *decoder) string() string {
+	n := int(d.uint8())
+	units := make([]uint16, n)
+	for i := range units {
+		units[i] = d.uint16()
+	}
+	if i := slices.Index(units, 0); i >= 0 {
+		units = units[:i]
+	}
+	return string(utf16.Decode(units))
+}
+
+// DeviceInfo is the dataset returned by GetDeviceInfo.
+type DeviceInfo struct {
+	StandardVersion           uint16
+	VendorExtensionID         uint32
+	VendorExtensionVersion    uint16
+	VendorExtensionDesc       string
+	FunctionalMode            uint16
+	OperationsSupported       []OperationCode
+	EventsSupported           []EventCode
+	DevicePropertiesSupported []uint16
+	CaptureFormats            []uint16
+	ImageFormats              []uint16
+	Manufacturer              string
+	Model                     string
+	DeviceVersion             string
+	SerialNumber              string
+}
+
+// Supports reports whether the device lists an operation as supported.
+func (info *DeviceInfo) Supports(op OperationCode) bool {
+	return slices.Contains(info.OperationsSupported, op)
+}
+
+func parseDeviceInfo(data []byte) (*DeviceInfo, error) {
+	d := &decoder{data: data}
+	info := &DeviceInfo{
+		StandardVersion:        d.uint16(),
+		VendorExtensionID:      d.uint32(),
+		VendorExtensionVersion: d.uint16(),
+		VendorExtensionDesc:    d.string(),
+		FunctionalMode:         d.uint16(),
+	}
+	for _, op := range d.uint16s() {
+		info.OperationsSupported = append(info.OperationsSupported, OperationCode(op))
+	}
+	for _, ev := range d.uint16s() {
+		info.EventsSupported = append(info.EventsSupported, EventCode(ev))
+	}
+	info.DevicePropertiesSupported = d.uint16s()
+	info.CaptureFormats = d.uint16s()
+	info.ImageFormats = d.uint16s()
+	info.Manufacturer = d.string()
+	info.Model = d.string()
+	info.DeviceVersion = d.string()
+	info.SerialNumber = d.string()
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: device info: %w", d.err)
+	}
+	return info, nil
+}
+
+// Object formats.
+const (
+	FormatUndefined   = 0x3000
+	FormatAssociation = 0x3001
+	FormatEXIFJPEG    = 0x3801
+	FormatPNG         = 0x380B
+	FormatTIFF        = 0x380D
+)
+
+// ObjectInfo is the dataset returned by GetObjectInfo.
+type ObjectInfo struct {
+	StorageID        uint32
+	ObjectFormat     uint16
+	ProtectionStatus uint16
+	// CompressedSize is the size of the object in bytes, or 0xFFFFFFFF if
+	// it is 4 GiB or larger.
+	CompressedSize      uint32
+	ThumbFormat         uint16
+	ThumbCompressedSize uint32
+	ThumbPixWidth       uint32
+	ThumbPixHeight      uint32
+	ImagePixWidth       uint32
+	ImagePixHeight      uint32
+	ImageBitDepth       uint32
+	ParentObject        uint32
+	AssociationType     uint16
+	AssociationDesc     uint32
+	SequenceNumber      uint32
+	Filename            string
+	// CaptureDate and ModificationDate are in the ISO 8601 form
+	// YYYYMMDDThhmmss, optionally with tenths of a second and a zone.
+	CaptureDate      string
+	ModificationDate string
+	Keywords         string
+}
+
+func parseObjectInfo(data []byte) (*ObjectInfo, error) {
+	d := &decoder{data: data}
+	info := &ObjectInfo{
+		StorageID:           d.uint32(),
+		ObjectFormat:        d.uint16(),
+		ProtectionStatus:    d.uint16(),
+		CompressedSize:      d.uint32(),
+		ThumbFormat:         d.uint16(),
+		ThumbCompressedSize: d.uint32(),
+		ThumbPixWidth:       d.uint32(),
+		ThumbPixHeight:      d.uint32(),
+		ImagePixWidth:       d.uint32(),
+		ImagePixHeight:      d.uint32(),
+		ImageBitDepth:       d.uint32(),
+		ParentObject:        d.uint32(),
+		AssociationType:     d.uint16(),
+		AssociationDesc:     d.uint32(),
+		SequenceNumber:      d.uint32(),
+		Filename:            d.string(),
+		CaptureDate:         d.string(),
+		ModificationDate:    d.string(),
+		Keywords:            d.string(),
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: object info: %w", d.err)
+	}
+	return info, nil
+}
+
+// DataType is the type code of a property value.
+type DataType uint16
+
+// Data types. An array type is its element type with bit 14 set.
+const (
+	TypeInt8    DataType = 0x0001
+	TypeUint8   DataType = 0x0002
+	TypeInt16   DataType = 0x0003
+	TypeUint16  DataType = 0x0004
+	TypeInt32   DataType = 0x0005
+	TypeUint32  DataType = 0x0006
+	TypeInt64   DataType = 0x0007
+	TypeUint64  DataType = 0x0008
+	TypeInt128  DataType = 0x0009
+	TypeUint128 DataType = 0x000A
+	TypeArray   DataType = 0x4000
+	TypeString  DataType = 0xFFFF
+)
+
+var dataTypeSizes = map[DataType]int{
+	TypeInt8:    1,
+	TypeUint8:   1,
+	TypeInt16:   2,
+	TypeUint16:  2,
+	TypeInt32:   4,
+	TypeUint32:  4,
+	TypeInt64:   8,
+	TypeUint64:  8,
+	TypeInt128:  16,
+	TypeUint128: 16,
+}
+
+// value reads a value of a data type. Integers come back as the Go type
+// of their size, 128-bit integers as little-endian [16]byte, strings as
+// string, and arrays as []any.
+func (d *decoder) value(dt DataType) (any, error) {
+	if dt == TypeString {
+		return d.string(), d.err
+	}
+	if dt&TypeArray != 0 {
+		elem := dt &^ TypeArray
+		size, ok := dataTypeSizes[elem]
+		if !ok {
+			return nil, fmt.Errorf("unknown data type %#04x", uint16(dt))
+		}
+		vals := make([]any, d.count(size))
+		for i := range vals {
+			vals[i], _ = d.value(elem)
+		}
+		return vals, d.err
+	}
+	var v any
+	switch dt {
+	case TypeInt8:
+		v = int8(d.uint8())
+	case TypeUint8:
+		v = d.uint8()
+	case TypeInt16:
+		v = int16(d.uint16())
+	case TypeUint16:
+		v = d.uint16()
+	case TypeInt32:
+		v = int32(d.uint32())
+	case TypeUint32:
+		v = d.uint32()
+	case TypeInt64:
+		v = int64(d.uint64())
+	case TypeUint64:
+		v = d.uint64()
+	case TypeInt128, TypeUint128:
+		v = [16]byte(d.next(16))
+	default:
+		return nil, fmt.Errorf("unknown data type %#04x", uint16(dt))
+	}
+	return v, d.err
+}
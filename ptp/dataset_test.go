@@ -0,0 +1,132 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// testDeviceInfo returns a device info dataset of a camera supporting
+// GetDeviceInfo, OpenSession and GetObject.
+func testDeviceInfo() []byte {
+	return le(
+		uint16(100), uint32(6), uint16(100), "microsoft.com: 1.0", uint16(0),
+		uint32(3), uint16(OpGetDeviceInfo), uint16(OpOpenSession), uint16(OpGetObject),
+		uint32(1), uint16(EventObjectAdded),
+		uint32(0),
+		uint32(1), uint16(FormatEXIFJPEG),
+		uint32(2), uint16(FormatEXIFJPEG), uint16(FormatAssociation),
+		"Acme", "Snap 3000", "1.2", "",
+	)
+}
+
+func TestParseDeviceInfo(t *testing.T) {
+	info, err := parseDeviceInfo(testDeviceInfo())
+	if err != nil {
+		t.Fatalf("parseDeviceInfo: unexpected error %v", err)
+	}
+	want := &DeviceInfo{
+		StandardVersion:           100,
+		VendorExtensionID:         6,
+		VendorExtensionVersion:    100,
+		VendorExtensionDesc:       "microsoft.com: 1.0",
+		OperationsSupported:       []OperationCode{OpGetDeviceInfo, OpOpenSession, OpGetObject},
+		EventsSupported:           []EventCode{EventObjectAdded},
+		DevicePropertiesSupported: []uint16{},
+		CaptureFormats:            []uint16{FormatEXIFJPEG},
+		ImageFormats:              []uint16{FormatEXIFJPEG, FormatAssociation},
+		Manufacturer:              "Acme",
+		Model:                     "Snap 3000",
+		DeviceVersion:             "1.2",
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("parseDeviceInfo = %+v, want %+v", info, want)
+	}
+	if !info.Supports(OpGetObject) || info.Supports(OpDeleteObject) {
+		t.Errorf("Supports is wrong for %v", info.OperationsSupported)
+	}
+
+	data := testDeviceInfo()
+	if _, err := parseDeviceInfo(data[:len(data)-10]); err == nil {
+		t.Error("truncated device info: expected error, got nil")
+	}
+	// An array count far past the end of the data.
+	bad := slices.Clone(data)
+	copy(bad[47:51], le(uint32(0x7FFFFFFF)))
+	if _, err := parseDeviceInfo(bad); err == nil {
+		t.Error("oversized array: expected error, got nil")
+	}
+}
+
+func TestParseObjectInfo(t *testing.T) {
+	data := le(
+		uint32(0x00010001), uint16(FormatEXIFJPEG), uint16(0), uint32(123456),
+		uint16(FormatEXIFJPEG), uint32(4096), uint32(160), uint32(120),
+		uint32(6000), uint32(4000), uint32(24), uint32(7),
+		uint16(0), uint32(0), uint32(0),
+		"IMG_0001.JPG", "20240102T030405", "", "",
+	)
+	info, err := parseObjectInfo(data)
+	if err != nil {
+		t.Fatalf("parseObjectInfo: unexpected error %v", err)
+	}
+	if info.StorageID != 0x00010001 || info.CompressedSize != 123456 ||
+		info.ImagePixWidth != 6000 || info.ParentObject != 7 ||
+		info.Filename != "IMG_0001.JPG" || info.CaptureDate != "20240102T030405" {
+		t.Errorf("parseObjectInfo = %+v", info)
+	}
+	if _, err := parseObjectInfo(data[:30]); err == nil {
+		t.Error("truncated object info: expected error, got nil")
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	var u128 [16]byte
+	u128[0] = 0x01
+	testCases := []struct {
+		dt   DataType
+		data []byte
+		want any
+	}{
+		{TypeInt8, []byte{0xFF}, int8(-1)},
+		{TypeUint8, []byte{0xFF}, uint8(0xFF)},
+		{TypeInt16, le(uint16(0xFFFE)), int16(-2)},
+		{TypeUint16, le(uint16(0x1234)), uint16(0x1234)},
+		{TypeInt32, le(uint32(0xFFFFFFFD)), int32(-3)},
+		{TypeUint32, le(uint32(0x12345678)), uint32(0x12345678)},
+		{TypeInt64, le(uint64(0xFFFFFFFFFFFFFFFC)), int64(-4)},
+		{TypeUint64, le(uint64(1 << 40)), uint64(1 << 40)},
+		{TypeUint128, u128[:], u128},
+		{TypeString, le("héllo"), "héllo"},
+		{TypeString, []byte{0}, ""},
+		{TypeArray | TypeUint16, le(uint32(2), uint16(1), uint16(2)), []any{uint16(1), uint16(2)}},
+	}
+	for _, tc := range testCases {
+		d := &decoder{data: tc.data}
+		got, err := d.value(tc.dt)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("value(%#04x) = %#v, %v, want %#v", uint16(tc.dt), got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		dt   DataType
+		data []byte
+	}{
+		{TypeUint32, []byte{1, 2}},
+		{TypeString, []byte{3, 'a', 0}},
+		{TypeArray | TypeUint32, le(uint32(3), uint32(1))},
+		{0x0010, []byte{1}},
+		{TypeArray | 0x0010, le(uint32(0))},
+	} {
+		d := &decoder{data: tc.data}
+		if _, err := d.value(tc.dt); err == nil {
+			t.Errorf("value(%#04x) of % x: expected error, got nil", uint16(tc.dt), tc.data)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// eventPoll is the interrupt transfer timeout in milliseconds used by
+// WatchEvents, which bounds how long Close waits for it to return.
+const eventPoll = 250
+
+// eventSize is the size of an event container with its three parameters.
+const eventSize = headerSize + 3*4
+
+// EventCode identifies an event.
+type EventCode uint16
+
+// Event codes.
+const (
+	EventCancelTransaction       EventCode = 0x4001
+	EventObjectAdded             EventCode = 0x4002
+	EventObjectRemoved           EventCode = 0x4003
+	EventStoreAdded              EventCode = 0x4004
+	EventStoreRemoved            EventCode = 0x4005
+	EventDevicePropChanged       EventCode = 0x4006
+	EventObjectInfoChanged       EventCode = 0x4007
+	EventDeviceInfoChanged       EventCode = 0x4008
+	EventRequestObjectTransfer   EventCode = 0x4009
+	EventStoreFull               EventCode = 0x400A
+	EventDeviceReset             EventCode = 0x400B
+	EventStorageInfoChanged      EventCode = 0x400C
+	EventCaptureComplete         EventCode = 0x400D
+	EventUnreportedStatus        EventCode = 0x400E
+	EventObjectPropChanged       EventCode = 0xC801
+	EventObjectPropDescChanged   EventCode = 0xC802
+	EventObjectReferencesChanged EventCode = 0xC803
+)
+
+var eventNames = map[EventCode]string{
+	EventCancelTransaction:       "CancelTransaction",
+	EventObjectAdded:             "ObjectAdded",
+	EventObjectRemoved:           "ObjectRemoved",
+	EventStoreAdded:              "StoreAdded",
+	EventStoreRemoved:            "StoreRemoved",
+	EventDevicePropChanged:       "DevicePropChanged",
+	EventObjectInfoChanged:       "ObjectInfoChanged",
+	EventDeviceInfoChanged:       "DeviceInfoChanged",
+	EventRequestObjectTransfer:   "RequestObjectTransfer",
+	EventStoreFull:               "StoreFull",
+	EventDeviceReset:             "DeviceReset",
+	EventStorageInfoChanged:      "StorageInfoChanged",
+	EventCaptureComplete:         "CaptureComplete",
+	EventUnreportedStatus:        "UnreportedStatus",
+	EventObjectPropChanged:       "ObjectPropChanged",
+	EventObjectPropDescChanged:   "ObjectPropDescChanged",
+	EventObjectReferencesChanged: "ObjectReferencesChanged",
+}
+
+// String implements the Stringer interface for EventCode.
+func (code EventCode) String() string {
+	if name, ok := eventNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("event %#04x", uint16(code))
+}
+
+// Event is an event container from the interrupt endpoint.
+type Event struct {
+	Code EventCode
+	// TransactionID is the transaction the event belongs to, if any.
+	TransactionID uint32
+	// Params holds up to three parameters; for ObjectAdded, the first is
+	// the handle of the new object.
+	Params []uint32
+}
+
+func (ev *Event) String() string {
+	return fmt.Sprintf("%v %v", ev.Code, ev.Params)
+}
+
+func parseEvent(data []byte) (*Event, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("ptp: %d byte event is shorter than its header", len(data))
+	}
+	h := parseHeader(data)
+	if h.kind != containerEvent {
+		return nil, fmt.Errorf("ptp: container type %d on the event endpoint", h.kind)
+	}
+	end := len(data)
+	if h.length >= headerSize && int64(h.length) < int64(end) {
+		end = int(h.length)
+	}
+	return &Event{
+		Code:          EventCode(h.code),
+		TransactionID: h.transactionID,
+		Params:        parseParams(data[headerSize:end]),
+	}, nil
+}
+
+// ReadEvent reads one event from the interrupt endpoint, waiting up to
+// Timeout. It shouldn't be used while WatchEvents is running.
+func (dev *Device) ReadEvent() (*Event, error) {
+	if dev.isClosed() {
+		return nil, os.ErrClosed
+	}
+	return dev.readEvent(dev.Timeout)
+}
+
+func (dev *Device) readEvent(timeout int) (*Event, error) {
+	ep := dev.Interface.Interrupt
+	if ep == nil {
+		return nil, fmt.Errorf("ptp: interface has no interrupt endpoint")
+	}
+	data := make([]byte, max(int(ep.MaxPacketSize), eventSize))
+	n, err := dev.handle.InterruptTransfer(ep.EndpointAddress, data, len(data), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ptp: reading event: %w", err)
+	}
+	return parseEvent(data[:n])
+}
+
+// WatchEvents polls the interrupt endpoint until the context is done or
+// the device is closed, calling fn with each event. It returns nil once
+// the device is closed, the context's error if it is canceled, or the
+// first transfer error other than a timeout.
+func (dev *Device) WatchEvents(ctx context.Context, fn func(*Event)) error {
+	if dev.Interface.Interrupt == nil {
+		return fmt.Errorf("ptp: interface has no interrupt endpoint")
+	}
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.watchers.Add(1)
+	dev.mu.Unlock()
+	defer dev.watchers.Done()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if dev.isClosed() {
+			return nil
+		}
+		ev, err := dev.readEvent(eventPoll)
+		if usbif.IsTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fn(ev)
+	}
+}
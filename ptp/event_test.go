@@ -0,0 +1,94 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+func TestParseEvent(t *testing.T) {
+	ev, err := parseEvent(container(containerEvent, uint16(EventObjectAdded), 4, le(uint32(17))))
+	if err != nil {
+		t.Fatalf("parseEvent: unexpected error %v", err)
+	}
+	if ev.Code != EventObjectAdded || ev.TransactionID != 4 ||
+		!slices.Equal(ev.Params, []uint32{17}) {
+		t.Errorf("parseEvent = %+v", ev)
+	}
+	if got, want := ev.String(), "ObjectAdded [17]"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	if _, err := parseEvent([]byte{1, 2, 3}); err == nil {
+		t.Error("short event: expected error, got nil")
+	}
+	if _, err := parseEvent(response(ResponseOK, 0)); err == nil {
+		t.Error("response on the event endpoint: expected error, got nil")
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	fh := &fakeHandle{events: [][]byte{container(containerEvent, uint16(EventStoreFull), 0, nil)}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	if ev, err := dev.ReadEvent(); err != nil || ev.Code != EventStoreFull {
+		t.Errorf("ReadEvent = %v, %v", ev, err)
+	}
+	if _, err := dev.ReadEvent(); !usbif.IsTimeout(err) {
+		t.Errorf("ReadEvent with nothing to read: got %v, want a timeout", err)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	fh := &fakeHandle{events: [][]byte{
+		container(containerEvent, uint16(EventObjectAdded), 0, le(uint32(9))),
+		container(containerEvent, uint16(EventCaptureComplete), 3, nil),
+	}}
+	dev := openTestDevice(t, fh)
+
+	events := make(chan *Event, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- dev.WatchEvents(ctx, func(ev *Event) { events <- ev })
+	}()
+	for _, want := range []EventCode{EventObjectAdded, EventCaptureComplete} {
+		select {
+		case ev := <-events:
+			if ev.Code != want {
+				t.Errorf("event = %v, want %v", ev, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("WatchEvents after Close: got %v, want nil", err)
+	}
+	if err := dev.WatchEvents(ctx, func(*Event) {}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WatchEvents on a closed device: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestWatchEventsCanceled(t *testing.T) {
+	dev := openTestDevice(t, &fakeHandle{})
+	defer dev.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := dev.WatchEvents(ctx, func(*Event) {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchEvents with a canceled context: got %v, want context.Canceled", err)
+	}
+}
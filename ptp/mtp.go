@@ -0,0 +1,145 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"fmt"
+)
+
+// PropertyCode identifies an MTP object property.
+type PropertyCode uint16
+
+// Object property codes.
+const (
+	PropStorageID        PropertyCode = 0xDC01
+	PropObjectFormat     PropertyCode = 0xDC02
+	PropProtectionStatus PropertyCode = 0xDC03
+	PropObjectSize       PropertyCode = 0xDC04
+	PropObjectFileName   PropertyCode = 0xDC07
+	PropDateCreated      PropertyCode = 0xDC08
+	PropDateModified     PropertyCode = 0xDC09
+	PropParentObject     PropertyCode = 0xDC0B
+	PropPersistentUID    PropertyCode = 0xDC41
+	PropName             PropertyCode = 0xDC44
+	PropWidth            PropertyCode = 0xDC87
+	PropHeight           PropertyCode = 0xDC88
+)
+
+var propertyNames = map[PropertyCode]string{
+	PropStorageID:        "StorageID",
+	PropObjectFormat:     "ObjectFormat",
+	PropProtectionStatus: "ProtectionStatus",
+	PropObjectSize:       "ObjectSize",
+	PropObjectFileName:   "ObjectFileName",
+	PropDateCreated:      "DateCreated",
+	PropDateModified:     "DateModified",
+	PropParentObject:     "ParentObject",
+	PropPersistentUID:    "PersistentUID",
+	PropName:             "Name",
+	PropWidth:            "Width",
+	PropHeight:           "Height",
+}
+
+// String implements the Stringer interface for PropertyCode.
+func (code PropertyCode) String() string {
+	if name, ok := propertyNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("property %#04x", uint16(code))
+}
+
+// AllProperties asks GetObjectPropList for every property of the objects.
+const AllProperties PropertyCode = 0xFFFF
+
+// ObjectProperty is one element of an object property list.
+type ObjectProperty struct {
+	Handle   uint32
+	Code     PropertyCode
+	DataType DataType
+	// Value holds the value as described for DataType: an integer of the
+	// Go type of its size, a [16]byte, a string, or a []any for arrays.
+	Value any
+}
+
+// GetObjectPropsSupported returns the properties the device keeps for
+// objects of a format.
+func (dev *Device) GetObjectPropsSupported(format uint16) ([]PropertyCode, error) {
+	data, err := dev.dataIn(OpGetObjectPropsSupported, uint32(format))
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{data: data}
+	codes := d.uint16s()
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: supported properties: %w", d.err)
+	}
+	props := make([]PropertyCode, len(codes))
+	for i, code := range codes {
+		props[i] = PropertyCode(code)
+	}
+	return props, nil
+}
+
+// GetObjectPropValue returns the value of one property of an object. The
+// data type is needed to decode it, and is listed in the property's
+// description.
+func (dev *Device) GetObjectPropValue(
+	handle uint32,
+	code PropertyCode,
+	dt DataType,
+) (any, error) {
+	data, err := dev.dataIn(OpGetObjectPropValue, handle, uint32(code))
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{data: data}
+	v, err := d.value(dt)
+	if err != nil {
+		return nil, fmt.Errorf("ptp: %v value: %w", code, err)
+	}
+	return v, nil
+}
+
+// GetObjectPropList returns properties of an object, or of every object
+// with handle 0xFFFFFFFF, in one transaction. It returns one property, or
+// all of them with AllProperties, of objects of a format, or any format
+// with AllFormats. Depth 0 selects the object itself, 1 its children as
+// well, and 0xFFFFFFFF everything below it.
+func (dev *Device) GetObjectPropList(
+	handle uint32,
+	format uint32,
+	code PropertyCode,
+	depth uint32,
+) ([]ObjectProperty, error) {
+	data, err := dev.dataIn(OpGetObjectPropList, handle, format, uint32(code), 0, depth)
+	if err != nil {
+		return nil, err
+	}
+	return parsePropList(data)
+}
+
+func parsePropList(data []byte) ([]ObjectProperty, error) {
+	d := &decoder{data: data}
+	// Each element has at least a handle, code, data type and a byte of
+	// value.
+	props := make([]ObjectProperty, d.count(9))
+	for i := range props {
+		props[i] = ObjectProperty{
+			Handle:   d.uint32(),
+			Code:     PropertyCode(d.uint16()),
+			DataType: DataType(d.uint16()),
+		}
+		v, err := d.value(props[i].DataType)
+		if err != nil {
+			return nil, fmt.Errorf("ptp: object property list element %d: %w", i, err)
+		}
+		props[i].Value = v
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: object property list: %w", d.err)
+	}
+	return props, nil
+}
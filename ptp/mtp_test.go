@@ -0,0 +1,98 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestGetObjectPropList(t *testing.T) {
+	list := le(
+		uint32(3),
+		uint32(5), uint16(PropObjectFileName), uint16(TypeString), "song.mp3",
+		uint32(5), uint16(PropObjectSize), uint16(TypeUint64), uint64(3<<20),
+		uint32(6), uint16(PropParentObject), uint16(TypeUint32), uint32(2),
+	)
+	fh := &fakeHandle{replies: [][]byte{
+		container(containerData, uint16(OpGetObjectPropList), 0, list),
+		response(ResponseOK, 0),
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	props, err := dev.GetObjectPropList(0xFFFFFFFF, AllFormats, AllProperties, 0)
+	if err != nil {
+		t.Fatalf("GetObjectPropList: unexpected error %v", err)
+	}
+	want := []ObjectProperty{
+		{5, PropObjectFileName, TypeString, "song.mp3"},
+		{5, PropObjectSize, TypeUint64, uint64(3 << 20)},
+		{6, PropParentObject, TypeUint32, uint32(2)},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("GetObjectPropList = %v, want %v", props, want)
+	}
+	wantParams := le(uint32(0xFFFFFFFF), uint32(0), uint32(0xFFFF), uint32(0), uint32(0))
+	if params := fh.written[0][headerSize:]; !bytes.Equal(params, wantParams) {
+		t.Errorf("parameters = % x, want % x", params, wantParams)
+	}
+}
+
+func TestParsePropListErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{
+			"count past the end",
+			le(uint32(2), uint32(1), uint16(PropName), uint16(TypeUint8), uint8(1)),
+		},
+		{
+			"unknown type",
+			le(uint32(1), uint32(1), uint16(PropName), uint16(0x0020), uint32(0), uint8(0)),
+		},
+		{
+			"truncated value",
+			le(uint32(1), uint32(1), uint16(PropName), uint16(TypeUint32), uint16(0)),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parsePropList(tc.data); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestGetObjectProps(t *testing.T) {
+	fh := &fakeHandle{replies: [][]byte{
+		container(containerData, uint16(OpGetObjectPropsSupported), 0,
+			le(uint32(2), uint16(PropObjectFileName), uint16(PropName))),
+		response(ResponseOK, 0),
+		container(containerData, uint16(OpGetObjectPropValue), 0, le("Track 1")),
+		response(ResponseOK, 0),
+		container(containerData, uint16(OpGetObjectPropValue), 0, []byte{1}),
+		response(ResponseOK, 0),
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	codes, err := dev.GetObjectPropsSupported(0x3009)
+	if err != nil || !slices.Equal(codes, []PropertyCode{PropObjectFileName, PropName}) {
+		t.Errorf("GetObjectPropsSupported = %v, %v", codes, err)
+	}
+	v, err := dev.GetObjectPropValue(5, PropName, TypeString)
+	if err != nil || v != "Track 1" {
+		t.Errorf("GetObjectPropValue = %v, %v", v, err)
+	}
+	if _, err := dev.GetObjectPropValue(5, PropObjectSize, TypeUint64); err == nil {
+		t.Error("short value: expected error, got nil")
+	}
+}
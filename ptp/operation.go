@@ -0,0 +1,159 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Special parameter values of GetObjectHandles.
+const (
+	// AllStorage selects the objects of every store.
+	AllStorage = 0xFFFFFFFF
+	// AllFormats selects objects of any format.
+	AllFormats = 0x00000000
+	// AllParents selects objects anywhere in the hierarchy.
+	AllParents = 0x00000000
+	// RootParent selects the objects at the root of a store.
+	RootParent = 0xFFFFFFFF
+)
+
+// dataIn runs an operation whose data phase comes from the device and
+// returns the data.
+func (dev *Device) dataIn(op OperationCode, params ...uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := dev.Transact(op, params, nil, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetDeviceInfo returns the device info dataset. It may be called with
+// or without a session open.
+func (dev *Device) GetDeviceInfo() (*DeviceInfo, error) {
+	data, err := dev.dataIn(OpGetDeviceInfo)
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceInfo(data)
+}
+
+// OpenSession opens a session, which every operation other than
+// GetDeviceInfo needs. The session ID must not be zero.
+func (dev *Device) OpenSession(sessionID uint32) error {
+	if sessionID == 0 {
+		return fmt.Errorf("ptp: session ID must not be zero")
+	}
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	dev.txMu.Lock()
+	defer dev.txMu.Unlock()
+	if _, err := dev.transact(OpOpenSession, []uint32{sessionID}, nil, nil); err != nil {
+		return err
+	}
+	dev.sessionID, dev.transactionID = sessionID, 1
+	return nil
+}
+
+// CloseSession closes the open session.
+func (dev *Device) CloseSession() error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	dev.txMu.Lock()
+	defer dev.txMu.Unlock()
+	if dev.sessionID == 0 {
+		return fmt.Errorf("ptp: no session open")
+	}
+	_, err := dev.transact(OpCloseSession, nil, nil, nil)
+	dev.sessionID, dev.transactionID = 0, 0
+	return err
+}
+
+// SessionID returns the ID of the open session, or zero if there is none.
+func (dev *Device) SessionID() uint32 {
+	dev.txMu.Lock()
+	defer dev.txMu.Unlock()
+	return dev.sessionID
+}
+
+// GetStorageIDs returns the IDs of the device's stores.
+func (dev *Device) GetStorageIDs() ([]uint32, error) {
+	data, err := dev.dataIn(OpGetStorageIDs)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{data: data}
+	ids := d.uint32s()
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: storage IDs: %w", d.err)
+	}
+	return ids, nil
+}
+
+// GetObjectHandles returns the handles of the objects in a store, or in
+// every store with AllStorage, of a format or AllFormats, and under a
+// parent association, at the root with RootParent, or anywhere with
+// AllParents.
+func (dev *Device) GetObjectHandles(storageID, format, parent uint32) ([]uint32, error) {
+	data, err := dev.dataIn(OpGetObjectHandles, storageID, format, parent)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{data: data}
+	handles := d.uint32s()
+	if d.err != nil {
+		return nil, fmt.Errorf("ptp: object handles: %w", d.err)
+	}
+	return handles, nil
+}
+
+// GetObjectInfo returns the object info dataset of an object.
+func (dev *Device) GetObjectInfo(handle uint32) (*ObjectInfo, error) {
+	data, err := dev.dataIn(OpGetObjectInfo, handle)
+	if err != nil {
+		return nil, err
+	}
+	return parseObjectInfo(data)
+}
+
+// GetObject copies an object to w as the device sends it and returns the
+// number of bytes written.
+func (dev *Device) GetObject(handle uint32, w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	_, err := dev.Transact(OpGetObject, []uint32{handle}, nil, cw)
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// InitiateCapture asks the device to capture a new object into a store,
+// zero for the device's choice, in a format, zero for the device's
+// choice. The objects it creates are announced with ObjectAdded events,
+// followed by CaptureComplete.
+func (dev *Device) InitiateCapture(storageID, format uint32) error {
+	_, err := dev.Transact(OpInitiateCapture, []uint32{storageID, format}, nil, nil)
+	return err
+}
+
+// DeleteObject deletes an object.
+func (dev *Device) DeleteObject(handle uint32) error {
+	_, err := dev.Transact(OpDeleteObject, []uint32{handle}, nil, nil)
+	return err
+}
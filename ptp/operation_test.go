@@ -0,0 +1,126 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// transactionIDs returns the transaction ID of each command written.
+func transactionIDs(written [][]byte) []uint32 {
+	var ids []uint32
+	for _, w := range written {
+		if len(w) >= headerSize && binary.LittleEndian.Uint16(w[4:6]) == containerCommand {
+			ids = append(ids, binary.LittleEndian.Uint32(w[8:12]))
+		}
+	}
+	return ids
+}
+
+func TestSession(t *testing.T) {
+	storageIDs := le(uint32(2), uint32(0x10001), uint32(0x20001))
+	handles := le(uint32(3), uint32(1), uint32(2), uint32(3))
+	fh := &fakeHandle{replies: [][]byte{
+		container(containerData, uint16(OpGetDeviceInfo), 0, testDeviceInfo()),
+		response(ResponseOK, 0),
+		response(ResponseOK, 0),
+		container(containerData, uint16(OpGetStorageIDs), 1, storageIDs),
+		response(ResponseOK, 1),
+		container(containerData, uint16(OpGetObjectHandles), 2, handles),
+		response(ResponseOK, 2),
+		response(ResponseOK, 3),
+		response(ResponseSessionNotOpen, 0),
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	info, err := dev.GetDeviceInfo()
+	if err != nil || info.Model != "Snap 3000" {
+		t.Fatalf("GetDeviceInfo = %+v, %v", info, err)
+	}
+	if err := dev.OpenSession(0); err == nil {
+		t.Error("OpenSession(0): expected error, got nil")
+	}
+	if err := dev.OpenSession(1); err != nil || dev.SessionID() != 1 {
+		t.Fatalf("OpenSession = %v, session %d", err, dev.SessionID())
+	}
+	ids, err := dev.GetStorageIDs()
+	if err != nil || !slices.Equal(ids, []uint32{0x10001, 0x20001}) {
+		t.Errorf("GetStorageIDs = %x, %v", ids, err)
+	}
+	got, err := dev.GetObjectHandles(AllStorage, AllFormats, RootParent)
+	if err != nil || !slices.Equal(got, []uint32{1, 2, 3}) {
+		t.Errorf("GetObjectHandles = %v, %v", got, err)
+	}
+	wantParams := le(uint32(AllStorage), uint32(0), uint32(RootParent))
+	if params := fh.written[3][headerSize:]; !bytes.Equal(params, wantParams) {
+		t.Errorf("GetObjectHandles parameters = % x, want % x", params, wantParams)
+	}
+	if err := dev.CloseSession(); err != nil || dev.SessionID() != 0 {
+		t.Errorf("CloseSession = %v, session %d", err, dev.SessionID())
+	}
+	var respErr *ResponseError
+	_, err = dev.GetStorageIDs()
+	if !errors.As(err, &respErr) || respErr.Code != ResponseSessionNotOpen {
+		t.Errorf("GetStorageIDs without a session: got %v", err)
+	}
+	if err := dev.CloseSession(); err == nil {
+		t.Error("CloseSession without a session: expected error, got nil")
+	}
+	if ids := transactionIDs(fh.written); !slices.Equal(ids, []uint32{0, 0, 1, 2, 3, 0}) {
+		t.Errorf("transaction IDs = %v", ids)
+	}
+}
+
+func TestTransactionIDWrap(t *testing.T) {
+	dev := &Device{sessionID: 1, transactionID: 0xFFFFFFFE}
+	var ids []uint32
+	for i := 0; i < 3; i++ {
+		ids = append(ids, dev.nextTransactionID(OpGetObject))
+	}
+	if !slices.Equal(ids, []uint32{0xFFFFFFFE, 1, 2}) {
+		t.Errorf("transaction IDs = %x", ids)
+	}
+}
+
+func TestGetObject(t *testing.T) {
+	jpeg := bytes.Repeat([]byte{0xFF, 0xD8}, 100)
+	info := le(
+		uint32(0x10001), uint16(FormatEXIFJPEG), uint16(0), uint32(len(jpeg)),
+		uint16(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0),
+		uint32(0), uint16(0), uint32(0), uint32(0),
+		"IMG_0002.JPG", "", "", "",
+	)
+	fh := &fakeHandle{replies: [][]byte{
+		response(ResponseOK, 0),
+		response(ResponseOK, 1),
+		container(containerData, uint16(OpGetObjectInfo), 2, info),
+		response(ResponseOK, 2),
+		container(containerData, uint16(OpGetObject), 3, jpeg),
+		response(ResponseOK, 3),
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	if err := dev.OpenSession(1); err != nil {
+		t.Fatalf("OpenSession: unexpected error %v", err)
+	}
+	if err := dev.InitiateCapture(0, 0); err != nil {
+		t.Fatalf("InitiateCapture: unexpected error %v", err)
+	}
+	oi, err := dev.GetObjectInfo(5)
+	if err != nil || oi.Filename != "IMG_0002.JPG" || oi.CompressedSize != uint32(len(jpeg)) {
+		t.Fatalf("GetObjectInfo = %+v, %v", oi, err)
+	}
+	var buf bytes.Buffer
+	n, err := dev.GetObject(5, &buf)
+	if err != nil || n != int64(len(jpeg)) || !bytes.Equal(buf.Bytes(), jpeg) {
+		t.Errorf("GetObject = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package ptp implements the Picture Transfer Protocol over USB, the Still
+Image Capture Device class, and the Media Transfer Protocol extensions to
+it, on top of libusb.
+
+A PTP interface has a bulk endpoint pair that carries transactions and an
+interrupt endpoint that carries events. Each transaction is an operation
+container sent to the device, an optional data phase in either direction,
+and a response container, all tagged with a transaction ID. FindInterfaces
+returns the still image interfaces of a configuration; MTP devices that use
+a vendor-specific interface instead can be wrapped with NewInterface. Open
+claims the interface, detaching a kernel driver if one is bound.
+
+Device runs the common operations as methods: OpenSession, GetDeviceInfo,
+GetStorageIDs, GetObjectHandles, GetObjectInfo, GetObject and
+InitiateCapture, plus the MTP object property operations. Transact runs
+any other operation. An operation the device fails returns a
+*ResponseError holding the response code.
+
+ReadEvent reads one event from the interrupt endpoint, and WatchEvents
+passes events to a callback until it is stopped.
+*/
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds, for
+// each phase of an operation. Cameras may take a while to answer while
+// they write a capture to their card.
+const DefaultTimeout = 5000
+
+// Interface subclass and protocol of still image capture devices.
+const (
+	SubclassStillImage = 0x01
+	ProtocolPTP        = 0x01
+)
+
+// Still image class requests.
+const (
+	requestCancel          = 0x64
+	requestDeviceReset     = 0x66
+	requestGetDeviceStatus = 0x67
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: the bulk pair
+// carrying operations, the interrupt endpoint carrying events, and the
+// still image class requests.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Interface is a PTP interface and its endpoints.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	BulkIn     *libusb.EndpointDescriptor
+	BulkOut    *libusb.EndpointDescriptor
+	// Interrupt is nil if the device has no event endpoint.
+	Interrupt *libusb.EndpointDescriptor
+}
+
+// NewInterface returns the PTP interface of an interface descriptor,
+// whatever its class. It is meant for MTP devices with a vendor-specific
+// interface, which are recognized by an interface string of "MTP".
+func NewInterface(desc *libusb.InterfaceDescriptor) (*Interface, error) {
+	if desc == nil {
+		return nil, fmt.Errorf("ptp: nil interface descriptor")
+	}
+	iface := &Interface{Descriptor: desc}
+	for _, ep := range desc.EndpointDescriptors {
+		in := ep.Direction() == libusb.EndpointIn
+		switch t := ep.TransferType(); {
+		case t == libusb.BulkTransfer && in && iface.BulkIn == nil:
+			iface.BulkIn = ep
+		case t == libusb.BulkTransfer && !in && iface.BulkOut == nil:
+			iface.BulkOut = ep
+		case t == libusb.InterruptTransfer && in && iface.Interrupt == nil:
+			iface.Interrupt = ep
+		}
+	}
+	if iface.BulkIn == nil || iface.BulkOut == nil {
+		return nil, fmt.Errorf(
+			"ptp: interface %d lacks a bulk endpoint pair",
+			desc.InterfaceNumber,
+		)
+	}
+	return iface, nil
+}
+
+// FindInterfaces returns the PTP interfaces of the still image class in a
+// configuration.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("ptp: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassImage,
+	) {
+		if desc.InterfaceSubClass != SubclassStillImage ||
+			desc.InterfaceProtocol != ProtocolPTP {
+			continue
+		}
+		iface, err := NewInterface(desc)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, iface)
+	}
+	return found, nil
+}
+
+// Device is a claimed PTP interface. Transactions are serialized, so a
+// Device may be used from several goroutines, and WatchEvents may run
+// alongside them.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	txMu          sync.Mutex
+	buf           []byte
+	sessionID     uint32
+	transactionID uint32
+
+	mu       sync.Mutex
+	closed   bool
+	claims   *usbif.Claims
+	watchers sync.WaitGroup
+}
+
+// Open claims a PTP interface, detaching a kernel driver if one is bound.
+// Desktop services such as gvfs often hold cameras through libusb
+// themselves, and the claim fails as busy until they let go.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("ptp: nil handle or interface")
+	}
+	dev := &Device{
+		handle:    handle,
+		Interface: iface,
+		Timeout:   DefaultTimeout,
+		buf:       make([]byte, transferBufferSize(int(iface.BulkIn.MaxPacketSize))),
+	}
+	num := iface.Descriptor.InterfaceNumber
+	claims, err := usbif.Claim(handle, "ptp", num)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	return dev, nil
+}
+
+// Close waits for WatchEvents to return and releases the interface. An
+// open session is left to the device, which ends it when it is next reset.
+// Calling Close again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	dev.mu.Unlock()
+	dev.watchers.Wait()
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
+
+// Cancel issues the Cancel class request for a transaction that is still
+// running, such as a GetObject stopped partway through.
+func (dev *Device) Cancel(transactionID uint32) error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	data := make([]byte, 6)
+	binary.LittleEndian.PutUint16(data[0:2], uint16(EventCancelTransaction))
+	binary.LittleEndian.PutUint32(data[2:6], transactionID)
+	_, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestCancel,
+		0,
+		uint16(dev.Interface.Descriptor.InterfaceNumber),
+		data,
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("ptp: cancel: %w", err)
+	}
+	return nil
+}
+
+// Reset issues the Device Reset class request, which ends the session and
+// returns the device to its idle state after a failed transaction.
+func (dev *Device) Reset() error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	_, err := dev.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestDeviceReset,
+		0,
+		uint16(dev.Interface.Descriptor.InterfaceNumber),
+		nil,
+		dev.Timeout,
+	)
+	if err != nil {
+		return fmt.Errorf("ptp: device reset: %w", err)
+	}
+	dev.txMu.Lock()
+	dev.sessionID, dev.transactionID = 0, 0
+	dev.txMu.Unlock()
+	return nil
+}
+
+// Status issues the Get Device Status class request. It returns
+// ResponseOK when the device is ready, ResponseDeviceBusy while it is
+// busy, and ResponseTransactionCancelled after a Cancel, with any
+// parameters the device adds, such as the endpoints it has stalled.
+func (dev *Device) Status() (ResponseCode, []uint32, error) {
+	if dev.isClosed() {
+		return 0, nil, os.ErrClosed
+	}
+	data := make([]byte, 64)
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestGetDeviceStatus,
+		0,
+		uint16(dev.Interface.Descriptor.InterfaceNumber),
+		data,
+		len(data),
+		dev.Timeout,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ptp: get device status: %w", err)
+	}
+	if n < 4 {
+		return 0, nil, fmt.Errorf("ptp: device status is %d bytes; want at least 4", n)
+	}
+	length := min(int(binary.LittleEndian.Uint16(data[0:2])), n)
+	var params []uint32
+	for i := 4; i+4 <= length; i += 4 {
+		params = append(params, binary.LittleEndian.Uint32(data[i:i+4]))
+	}
+	return ResponseCode(binary.LittleEndian.Uint16(data[2:4])), params, nil
+}
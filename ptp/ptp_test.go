@@ -0,0 +1,264 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package ptp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// replies are returned one per bulk IN transfer; once they run out,
+	// reads time out.
+	replies [][]byte
+	// written collects each bulk OUT transfer.
+	written [][]byte
+	// events are returned one per interrupt transfer; once they run out,
+	// reads time out.
+	events [][]byte
+	// status is returned by control IN requests.
+	status []byte
+	// controlData collects the data of each control OUT request.
+	controlData [][]byte
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.Record("control in %#02x %d", request, index)
+	return copy(data[:maxReceiveLength], fh.status), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.Record("control out %#02x %d", request, index)
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	fh.controlData = append(fh.controlData, slices.Clone(data))
+	return len(data), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, slices.Clone(data[:length]))
+		return length, nil
+	}
+	if len(fh.replies) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.replies[0]
+	fh.replies = fh.replies[1:]
+	return copy(data[:length], p), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if len(fh.events) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.events[0]
+	fh.events = fh.events[1:]
+	return copy(data[:length], p), nil
+}
+
+// le encodes values little-endian: uint8, uint16, uint32 and uint64 as
+// themselves, strings as PTP strings, and byte slices as they are.
+func le(vals ...any) []byte {
+	var data []byte
+	for _, v := range vals {
+		switch v := v.(type) {
+		case uint8:
+			data = append(data, v)
+		case uint16:
+			data = binary.LittleEndian.AppendUint16(data, v)
+		case uint32:
+			data = binary.LittleEndian.AppendUint32(data, v)
+		case uint64:
+			data = binary.LittleEndian.AppendUint64(data, v)
+		case string:
+			if v == "" {
+				data = append(data, 0)
+				continue
+			}
+			units := append(utf16.Encode([]rune(v)), 0)
+			data = append(data, byte(len(units)))
+			for _, u := range units {
+				data = binary.LittleEndian.AppendUint16(data, u)
+			}
+		case []byte:
+			data = append(data, v...)
+		default:
+			panic(fmt.Sprintf("le: unsupported type %T", v))
+		}
+	}
+	return data
+}
+
+// container returns a container of a type with a payload.
+func container(kind uint16, code uint16, id uint32, payload []byte) []byte {
+	return append(le(uint32(headerSize+len(payload)), kind, code, id), payload...)
+}
+
+// response returns a response container with parameters.
+func response(code ResponseCode, id uint32, params ...uint32) []byte {
+	var payload []byte
+	for _, p := range params {
+		payload = binary.LittleEndian.AppendUint32(payload, p)
+	}
+	return container(containerResponse, uint16(code), id, payload)
+}
+
+// testConfig returns a configuration whose interface 0 is a still image
+// interface with the bulk endpoints 0x01 and 0x81 and the interrupt
+// endpoint 0x82, and whose interface 1 is a vendor-specific interface.
+func testConfig() *libusb.ConfigDescriptor {
+	endpoints := libusb.EndpointDescriptors{
+		{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 512},
+		{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 512},
+		{EndpointAddress: 0x82, Attributes: 0x03, MaxPacketSize: 64},
+	}
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:      libusb.InterfaceClassImage,
+				InterfaceSubClass:   SubclassStillImage,
+				InterfaceProtocol:   ProtocolPTP,
+				EndpointDescriptors: endpoints,
+			}}},
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceNumber:     1,
+				InterfaceClass:      libusb.InterfaceClassVendorSpec,
+				EndpointDescriptors: endpoints,
+			}}},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil || len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig())
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.BulkIn.EndpointAddress != 0x81 || iface.BulkOut.EndpointAddress != 0x01 ||
+		iface.Interrupt.EndpointAddress != 0x82 {
+		t.Errorf("interface = %+v", iface)
+	}
+
+	vendor := testConfig().SupportedInterfaces[1].InterfaceDescriptors[0]
+	if iface, err := NewInterface(vendor); err != nil || iface.Descriptor.InterfaceNumber != 1 {
+		t.Errorf("NewInterface = %+v, %v", iface, err)
+	}
+	vendor.EndpointDescriptors = vendor.EndpointDescriptors[1:]
+	if _, err := NewInterface(vendor); err == nil {
+		t.Error("NewInterface without a bulk OUT endpoint: expected error, got nil")
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	dev := openTestDevice(t, fh)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 0", "claim 0", "release 0", "attach 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.GetDeviceInfo(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("GetDeviceInfo after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestClassRequests(t *testing.T) {
+	fh := &fakeHandle{status: le(uint16(8), uint16(ResponseTransactionCancelled), uint32(0x81))}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	code, params, err := dev.Status()
+	if err != nil || code != ResponseTransactionCancelled || !slices.Equal(params, []uint32{0x81}) {
+		t.Errorf("Status = %v, %v, %v", code, params, err)
+	}
+	if err := dev.Cancel(7); err != nil {
+		t.Fatalf("Cancel: unexpected error %v", err)
+	}
+	if want := le(uint16(0x4001), uint32(7)); !bytes.Equal(fh.controlData[0], want) {
+		t.Errorf("cancel data = % x, want % x", fh.controlData[0], want)
+	}
+	dev.sessionID = 1
+	if err := dev.Reset(); err != nil || dev.SessionID() != 0 {
+		t.Errorf("Reset = %v, session %d", err, dev.SessionID())
+	}
+	want := []string{"claim 0", "control in 0x67 0", "control out 0x64 0", "control out 0x66 0"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	fh.status = []byte{0x02}
+	if _, _, err := dev.Status(); err == nil {
+		t.Error("short device status: expected error, got nil")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// bulkPort holds what the bridge drivers have in common: a claimed interface
+// and its bulk endpoint pair carrying the raw serial data.
+type bulkPort struct {
+	handle      Handle
+	Interface   int
+	InEndpoint  libusb.EndpointAddress
+	OutEndpoint libusb.EndpointAddress
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	reader *usbif.Reader
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// open finds the bulk endpoint pair of iface and claims the interface from
+// the bridge's kernel driver.
+func (port *bulkPort) open(handle Handle, iface *libusb.InterfaceDescriptor) error {
+	if handle == nil || iface == nil {
+		return fmt.Errorf("serial: nil handle or interface descriptor")
+	}
+	port.handle = handle
+	port.Interface = iface.InterfaceNumber
+	port.Timeout = DefaultTimeout
+	packetSize := 0
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.BulkTransfer {
+			continue
+		}
+		if ep.Direction() == libusb.EndpointIn && port.InEndpoint == 0 {
+			port.InEndpoint = ep.EndpointAddress
+			packetSize = int(ep.MaxPacketSize)
+		} else if ep.Direction() == libusb.EndpointOut && port.OutEndpoint == 0 {
+			port.OutEndpoint = ep.EndpointAddress
+		}
+	}
+	if port.InEndpoint == 0 || port.OutEndpoint == 0 {
+		return fmt.Errorf("serial: interface %d has no bulk endpoint pair", port.Interface)
+	}
+	port.reader = usbif.NewReader(handle, port.InEndpoint, packetSize)
+	claims, err := usbif.Claim(handle, "serial", port.Interface)
+	if err != nil {
+		return err
+	}
+	port.claims = claims
+	return nil
+}
+
+// close marks the port closed, releases the interface, and reattaches the
+// kernel driver. shutdown, if not nil, runs first so a driver can disable
+// the UART while the interface is still claimed.
+func (port *bulkPort) close(shutdown func() error) error {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if port.closed {
+		return os.ErrClosed
+	}
+	port.closed = true
+	var errs []error
+	if shutdown != nil {
+		errs = append(errs, shutdown())
+	}
+	errs = append(errs, port.claims.Release())
+	return errors.Join(errs...)
+}
+
+// release undoes open after a driver's initialization fails.
+func (port *bulkPort) release(err error) error {
+	return errors.Join(err, port.close(nil))
+}
+
+func (port *bulkPort) isClosed() bool {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.closed
+}
+
+// Read returns the bytes the bridge received on its UART. The bridges send
+// them without framing, so nothing is stripped. If nothing arrives within
+// Timeout, Read returns a libusb.ErrorCode whose Timeout method reports
+// true.
+func (port *bulkPort) Read(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return port.reader.Read(p, port.Timeout)
+}
+
+// Write hands p to the bridge to send on its UART.
+func (port *bulkPort) Write(p []byte) (int, error) {
+	if port.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return usbif.Write(port.handle, port.OutEndpoint, p, port.Timeout)
+}
+
+func (port *bulkPort) controlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+) error {
+	n, err := port.handle.ControlOut(reqType, recipient, request, value, index, data, port.Timeout)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("serial: sent %d of %d bytes", n, len(data))
+	}
+	return nil
+}
+
+func (port *bulkPort) controlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+) error {
+	n, err := port.handle.ControlIn(
+		reqType,
+		recipient,
+		request,
+		value,
+		index,
+		data,
+		len(data),
+		port.Timeout,
+	)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("serial: request %#02x returned %d of %d bytes", request, n, len(data))
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// CH34x vendor requests. WCH doesn't document them; these follow the Linux
+// ch341 driver.
+const (
+	ch34xReadVersion = 0x5F
+	ch34xReadReg     = 0x95
+	ch34xWriteReg    = 0x9A
+	ch34xSerialInit  = 0xA1
+	ch34xModemCtrl   = 0xA4
+)
+
+// CH34x registers.
+const (
+	ch34xRegStatus    = 0x06
+	ch34xRegPrescaler = 0x12
+	ch34xRegDivisor   = 0x13
+	ch34xRegLCR       = 0x18
+	ch34xRegLCR2      = 0x25
+)
+
+// Line control register bits.
+const (
+	ch34xLCREnableRX  = 0x80
+	ch34xLCREnableTX  = 0x40
+	ch34xLCRMarkSpace = 0x20
+	ch34xLCRParEven   = 0x10
+	ch34xLCREnablePar = 0x08
+	ch34xLCRStopBits2 = 0x04
+)
+
+// Modem control bits. The chip's outputs are active low, so the request
+// carries their complement.
+const (
+	ch34xDTR = 0x20
+	ch34xRTS = 0x40
+)
+
+// The baud rate generator divides a 48 MHz clock by a prescaler, selected by
+// ps and fact, and by an 8-bit divisor.
+const (
+	ch34xClock   = 48000000
+	ch34xMinBaud = 46
+	ch34xMaxBaud = 2000000
+)
+
+// ch34xDivisorNoBuffer makes the chip send received data without waiting
+// for a full packet. At least one chip version 0x27 has it inverted, so it
+// is only set on later versions.
+const ch34xDivisorNoBuffer = 0x80
+
+// CH34x is a port of a WCH CH340 or CH341 bridge.
+type CH34x struct {
+	bulkPort
+	// Version is the chip version reported when the port was opened.
+	Version byte
+
+	lineMu    sync.Mutex
+	lineState uint16
+}
+
+// OpenCH34x claims the bridge's interface and initializes the UART at 9600
+// 8N1.
+func OpenCH34x(handle Handle, iface *libusb.InterfaceDescriptor) (*CH34x, error) {
+	port := &CH34x{}
+	if err := port.open(handle, iface); err != nil {
+		return nil, err
+	}
+	version := make([]byte, 2)
+	err := port.controlIn(libusb.Vendor, libusb.DeviceRecipient, ch34xReadVersion, 0, 0, version)
+	if err != nil {
+		return nil, port.release(fmt.Errorf("serial: reading CH34x version: %w", err))
+	}
+	port.Version = version[0]
+	if err := port.request(ch34xSerialInit, 0, 0); err != nil {
+		return nil, port.release(err)
+	}
+	if err := port.Configure(Config{BaudRate: 9600, DataBits: 8}); err != nil {
+		return nil, port.release(err)
+	}
+	if err := port.setModemControl(0); err != nil {
+		return nil, port.release(err)
+	}
+	return port, nil
+}
+
+// Close releases the interface.
+func (port *CH34x) Close() error {
+	return port.close(nil)
+}
+
+// Configure sets the baud rate and line control. Chips older than version
+// 0x30 have no line control register and stay at 8N1.
+func (port *CH34x) Configure(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	divisor, err := ch34xDivisor(config.BaudRate)
+	if err != nil {
+		return err
+	}
+	if port.Version > 0x27 {
+		divisor |= ch34xDivisorNoBuffer
+	}
+	err = port.request(ch34xWriteReg, ch34xRegDivisor<<8|ch34xRegPrescaler, divisor)
+	if err != nil {
+		return err
+	}
+	if port.Version < 0x30 {
+		return nil
+	}
+	lcr := uint16(ch34xLCREnableRX | ch34xLCREnableTX | (config.DataBits - 5))
+	if config.StopBits != OneStopBit {
+		lcr |= ch34xLCRStopBits2
+	}
+	switch config.Parity {
+	case ParityOdd:
+		lcr |= ch34xLCREnablePar
+	case ParityEven:
+		lcr |= ch34xLCREnablePar | ch34xLCRParEven
+	case ParityMark:
+		lcr |= ch34xLCREnablePar | ch34xLCRMarkSpace
+	case ParitySpace:
+		lcr |= ch34xLCREnablePar | ch34xLCRMarkSpace | ch34xLCRParEven
+	}
+	return port.request(ch34xWriteReg, ch34xRegLCR2<<8|ch34xRegLCR, lcr)
+}
+
+// ch34xDivisor computes the prescaler and divisor register pair for a baud
+// rate, as the Linux ch341 driver does: the highest prescaler clock that
+// keeps the divisor below 256, then the divisor whose rate is closest.
+func ch34xDivisor(baudRate int) (uint16, error) {
+	baudRate = min(max(baudRate, ch34xMinBaud), ch34xMaxBaud)
+	clockDiv := func(ps, fact int) int { return 1 << (12 - 3*ps - fact) }
+
+	fact := 1
+	ps := 3
+	for ; ps >= 0; ps-- {
+		if baudRate > ch34xClock/(clockDiv(ps, 1)*512) {
+			break
+		}
+	}
+	if ps < 0 {
+		return 0, fmt.Errorf("serial: CH34x can't produce %d baud", baudRate)
+	}
+	div := clockDiv(ps, fact)
+	divisor := ch34xClock / (div * baudRate)
+	if divisor < 9 || divisor > 255 {
+		divisor /= 2
+		div *= 2
+		fact = 0
+	}
+	if divisor < 2 {
+		return 0, fmt.Errorf("serial: CH34x can't produce %d baud", baudRate)
+	}
+	// Round to the closer divisor, scaled up to keep precision at low rates.
+	above := 16*ch34xClock/(div*divisor) - 16*baudRate
+	below := 16*baudRate - 16*ch34xClock/(div*(divisor+1))
+	if above >= below {
+		divisor++
+	}
+	// An even divisor works with the lower clock, which makes the receiver
+	// more tolerant of errors.
+	if fact == 1 && divisor%2 == 0 {
+		divisor /= 2
+		fact = 0
+	}
+	return uint16((0x100-divisor)<<8 | fact<<2 | ps), nil
+}
+
+// SetDTR drives the DTR output.
+func (port *CH34x) SetDTR(dtr bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	state := port.lineState &^ ch34xDTR
+	if dtr {
+		state |= ch34xDTR
+	}
+	return port.setModemControl(state)
+}
+
+// SetRTS drives the RTS output.
+func (port *CH34x) SetRTS(rts bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	state := port.lineState &^ ch34xRTS
+	if rts {
+		state |= ch34xRTS
+	}
+	return port.setModemControl(state)
+}
+
+func (port *CH34x) setModemControl(state uint16) error {
+	if err := port.request(ch34xModemCtrl, ^state, 0); err != nil {
+		return err
+	}
+	port.lineState = state
+	return nil
+}
+
+// ModemLines reads the modem control inputs.
+func (port *CH34x) ModemLines() (ModemLines, error) {
+	status := make([]byte, 2)
+	err := port.controlIn(
+		libusb.Vendor,
+		libusb.DeviceRecipient,
+		ch34xReadReg,
+		// Like the divisor write's 0x1312, the first register goes in the
+		// low byte, so status[0] holds register 0x06.
+		(ch34xRegStatus+1)<<8|ch34xRegStatus,
+		0,
+		status,
+	)
+	if err != nil {
+		return ModemLines{}, err
+	}
+	// Like the outputs, the inputs are active low.
+	bits := ^status[0]
+	return ModemLines{
+		CTS: bits&0x01 != 0,
+		DSR: bits&0x02 != 0,
+		RI:  bits&0x04 != 0,
+		DCD: bits&0x08 != 0,
+	}, nil
+}
+
+func (port *CH34x) request(request byte, value uint16, index uint16) error {
+	return port.controlOut(libusb.Vendor, libusb.DeviceRecipient, request, value, index, nil)
+}
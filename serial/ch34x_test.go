@@ -0,0 +1,106 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func TestCH34xDivisor(t *testing.T) {
+	// Expected values follow the Linux ch341 driver's algorithm.
+	testCases := []struct {
+		baudRate int
+		expected uint16
+	}{
+		{9600, 0xB202},
+		{115200, 0xCC03},
+		{2400, 0xD901},
+		{50, 0x1600},
+		{921600, 0xF307},
+		{10, 0x0100},
+	}
+	for _, tc := range testCases {
+		got, err := ch34xDivisor(tc.baudRate)
+		if err != nil {
+			t.Errorf("ch34xDivisor(%d): unexpected error %v", tc.baudRate, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("ch34xDivisor(%d) = %#04x, want %#04x", tc.baudRate, got, tc.expected)
+		}
+	}
+}
+
+func deviceOut(request byte, value uint16, index uint16) controlRequest {
+	return controlRequest{false, libusb.Vendor, libusb.DeviceRecipient, request, value, index, ""}
+}
+
+func TestCH34xRequests(t *testing.T) {
+	fh := &fakeHandle{controlIn: map[byte][]byte{
+		ch34xReadVersion: {0x31, 0x00},
+		ch34xReadReg:     {0xEE, 0xFF},
+	}}
+	port, err := OpenCH34x(fh, bridgeInterface(0))
+	if err != nil {
+		t.Fatalf("OpenCH34x: unexpected error %v", err)
+	}
+	if port.Version != 0x31 {
+		t.Errorf("Version = %#02x, want 0x31", port.Version)
+	}
+	config := Config{BaudRate: 115200, DataBits: 7, Parity: ParityOdd, StopBits: TwoStopBits}
+	if err := port.Configure(config); err != nil {
+		t.Fatalf("Configure: unexpected error %v", err)
+	}
+	if err := port.SetDTR(true); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	if err := port.SetRTS(true); err != nil {
+		t.Fatalf("SetRTS: unexpected error %v", err)
+	}
+	lines, err := port.ModemLines()
+	if err != nil {
+		t.Fatalf("ModemLines: unexpected error %v", err)
+	}
+	if want := (ModemLines{CTS: true}); lines != want {
+		t.Errorf("ModemLines = %+v, want %+v", lines, want)
+	}
+
+	want := []controlRequest{
+		{true, libusb.Vendor, libusb.DeviceRecipient, ch34xReadVersion, 0, 0, ""},
+		deviceOut(ch34xSerialInit, 0, 0),
+		deviceOut(ch34xWriteReg, 0x1312, 0xB282),
+		deviceOut(ch34xWriteReg, 0x2518, 0xC3),
+		deviceOut(ch34xModemCtrl, 0xFFFF, 0),
+		deviceOut(ch34xWriteReg, 0x1312, 0xCC83),
+		deviceOut(ch34xWriteReg, 0x2518, 0xCE),
+		deviceOut(ch34xModemCtrl, 0xFFDF, 0),
+		deviceOut(ch34xModemCtrl, 0xFF9F, 0),
+		{true, libusb.Vendor, libusb.DeviceRecipient, ch34xReadReg, 0x0706, 0, ""},
+	}
+	if !slices.Equal(fh.requests, want) {
+		t.Errorf("requests =\n%v\nwant\n%v", fh.requests, want)
+	}
+}
+
+func TestCH34xOldVersion(t *testing.T) {
+	fh := &fakeHandle{controlIn: map[byte][]byte{ch34xReadVersion: {0x27, 0x00}}}
+	port, err := OpenCH34x(fh, bridgeInterface(0))
+	if err != nil {
+		t.Fatalf("OpenCH34x: unexpected error %v", err)
+	}
+	fh.requests = nil
+	if err := port.Configure(Config{BaudRate: 9600, DataBits: 8}); err != nil {
+		t.Fatalf("Configure: unexpected error %v", err)
+	}
+	// No buffering bit, and no line control register to write.
+	want := []controlRequest{deviceOut(ch34xWriteReg, 0x1312, 0xB202)}
+	if !slices.Equal(fh.requests, want) {
+		t.Errorf("requests = %v, want %v", fh.requests, want)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"encoding/binary"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// CP210x vendor requests from Silicon Labs AN571. They are addressed to the
+// interface, so each port of a multi-port CP2105 or CP2108 is configured
+// separately.
+const (
+	cp210xIfcEnable   = 0x00
+	cp210xSetLineCtl  = 0x03
+	cp210xSetBreak    = 0x05
+	cp210xSetMHS      = 0x07
+	cp210xGetMdmSts   = 0x08
+	cp210xPurge       = 0x12
+	cp210xSetBaudRate = 0x1E
+)
+
+// Bits of the SET_MHS request. The high byte selects which outputs the low
+// byte changes.
+const (
+	cp210xDTR     = 0x0001
+	cp210xRTS     = 0x0002
+	cp210xDTRMask = 0x0100
+	cp210xRTSMask = 0x0200
+)
+
+// cp210xPurgeAll clears the transmit and receive queues.
+const cp210xPurgeAll = 0x000F
+
+// ModemLines holds the state of the modem control inputs.
+type ModemLines struct {
+	CTS bool
+	DSR bool
+	RI  bool
+	DCD bool
+}
+
+// CP210x is a port of a Silicon Labs CP210x bridge.
+type CP210x struct {
+	bulkPort
+}
+
+// OpenCP210x claims a CP210x port's interface and enables its UART.
+func OpenCP210x(handle Handle, iface *libusb.InterfaceDescriptor) (*CP210x, error) {
+	port := &CP210x{}
+	if err := port.open(handle, iface); err != nil {
+		return nil, err
+	}
+	if err := port.request(cp210xIfcEnable, 1, nil); err != nil {
+		return nil, port.release(err)
+	}
+	return port, nil
+}
+
+// Close disables the UART and releases the interface.
+func (port *CP210x) Close() error {
+	return port.close(func() error {
+		return port.request(cp210xIfcEnable, 0, nil)
+	})
+}
+
+// Configure sets the baud rate and line control.
+func (port *CP210x) Configure(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	baud := make([]byte, 4)
+	binary.LittleEndian.PutUint32(baud, uint32(config.BaudRate))
+	if err := port.request(cp210xSetBaudRate, 0, baud); err != nil {
+		return err
+	}
+	lineCtl := uint16(config.StopBits) | uint16(config.Parity)<<4 | uint16(config.DataBits)<<8
+	return port.request(cp210xSetLineCtl, lineCtl, nil)
+}
+
+// SetDTR drives the DTR output.
+func (port *CP210x) SetDTR(dtr bool) error {
+	value := uint16(cp210xDTRMask)
+	if dtr {
+		value |= cp210xDTR
+	}
+	return port.request(cp210xSetMHS, value, nil)
+}
+
+// SetRTS drives the RTS output.
+func (port *CP210x) SetRTS(rts bool) error {
+	value := uint16(cp210xRTSMask)
+	if rts {
+		value |= cp210xRTS
+	}
+	return port.request(cp210xSetMHS, value, nil)
+}
+
+// SetBreak turns the break condition on or off.
+func (port *CP210x) SetBreak(on bool) error {
+	var value uint16
+	if on {
+		value = 1
+	}
+	return port.request(cp210xSetBreak, value, nil)
+}
+
+// Purge discards the data queued in the bridge in both directions.
+func (port *CP210x) Purge() error {
+	return port.request(cp210xPurge, cp210xPurgeAll, nil)
+}
+
+// ModemLines reads the modem control inputs.
+func (port *CP210x) ModemLines() (ModemLines, error) {
+	status := make([]byte, 1)
+	err := port.controlIn(
+		libusb.Vendor,
+		libusb.InterfaceRecipient,
+		cp210xGetMdmSts,
+		0,
+		uint16(port.Interface),
+		status,
+	)
+	if err != nil {
+		return ModemLines{}, err
+	}
+	return ModemLines{
+		CTS: status[0]&0x10 != 0,
+		DSR: status[0]&0x20 != 0,
+		RI:  status[0]&0x40 != 0,
+		DCD: status[0]&0x80 != 0,
+	}, nil
+}
+
+func (port *CP210x) request(request byte, value uint16, data []byte) error {
+	return port.controlOut(
+		libusb.Vendor,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		uint16(port.Interface),
+		data,
+	)
+}
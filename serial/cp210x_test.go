@@ -0,0 +1,109 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func vendorOut(request byte, value uint16, index uint16, data string) controlRequest {
+	return controlRequest{
+		false, libusb.Vendor, libusb.InterfaceRecipient, request, value, index, data,
+	}
+}
+
+func TestCP210xRequests(t *testing.T) {
+	fh := &fakeHandle{controlIn: map[byte][]byte{cp210xGetMdmSts: {0x31}}}
+	port, err := OpenCP210x(fh, bridgeInterface(1))
+	if err != nil {
+		t.Fatalf("OpenCP210x: unexpected error %v", err)
+	}
+	config := Config{BaudRate: 115200, DataBits: 7, Parity: ParityEven, StopBits: TwoStopBits}
+	if err := port.Configure(config); err != nil {
+		t.Fatalf("Configure: unexpected error %v", err)
+	}
+	if err := port.SetDTR(true); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	if err := port.SetRTS(false); err != nil {
+		t.Fatalf("SetRTS: unexpected error %v", err)
+	}
+	if err := port.Purge(); err != nil {
+		t.Fatalf("Purge: unexpected error %v", err)
+	}
+	lines, err := port.ModemLines()
+	if err != nil {
+		t.Fatalf("ModemLines: unexpected error %v", err)
+	}
+	if want := (ModemLines{CTS: true, DSR: true}); lines != want {
+		t.Errorf("ModemLines = %+v, want %+v", lines, want)
+	}
+	if err := port.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+
+	want := []controlRequest{
+		vendorOut(cp210xIfcEnable, 1, 1, ""),
+		vendorOut(cp210xSetBaudRate, 0, 1, "\x00\xc2\x01\x00"),
+		vendorOut(cp210xSetLineCtl, 0x0722, 1, ""),
+		vendorOut(cp210xSetMHS, 0x0101, 1, ""),
+		vendorOut(cp210xSetMHS, 0x0200, 1, ""),
+		vendorOut(cp210xPurge, 0x000F, 1, ""),
+		{true, libusb.Vendor, libusb.InterfaceRecipient, cp210xGetMdmSts, 0, 1, ""},
+		vendorOut(cp210xIfcEnable, 0, 1, ""),
+	}
+	if !slices.Equal(fh.requests, want) {
+		t.Errorf("requests =\n%v\nwant\n%v", fh.requests, want)
+	}
+	wantCalls := []string{"claim 1", "release 1"}
+	if calls := fh.Calls(); !slices.Equal(calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", calls, wantCalls)
+	}
+	if err := port.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestCP210xReadWrite(t *testing.T) {
+	fh := &fakeHandle{bulkIn: [][]byte{[]byte("hello"), {}, []byte(" world")}}
+	port, err := OpenCP210x(fh, bridgeInterface(0))
+	if err != nil {
+		t.Fatalf("OpenCP210x: unexpected error %v", err)
+	}
+	var got []byte
+	buf := make([]byte, 4)
+	for len(got) < len("hello world") {
+		n, err := port.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: unexpected error %v after %q", err, got)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("Read = %q, want %q", got, "hello world")
+	}
+	var timeout interface{ Timeout() bool }
+	if _, err := port.Read(buf); !errors.As(err, &timeout) || !timeout.Timeout() {
+		t.Errorf("Read with no data: got %v, want a timeout", err)
+	}
+
+	if _, err := port.Write([]byte("AT\r")); err != nil {
+		t.Fatalf("Write: unexpected error %v", err)
+	}
+	if !bytes.Equal(fh.bulkOut, []byte("AT\r")) {
+		t.Errorf("bulk OUT = %q, want %q", fh.bulkOut, "AT\r")
+	}
+	port.Close()
+	if _, err := port.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after Close: got %v, want os.ErrClosed", err)
+	}
+}
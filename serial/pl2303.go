@@ -0,0 +1,236 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// PL2303 requests. The line setup is the CDC class request addressed to
+// interface 0; the vendor requests follow the Linux pl2303 driver.
+const (
+	pl2303VendorRequest = 0x01
+	pl2303SetLine       = 0x20
+	pl2303SetControl    = 0x22
+	pl2303Break         = 0x23
+)
+
+// Bits of the SET_CONTROL request.
+const (
+	pl2303DTR = 0x01
+	pl2303RTS = 0x02
+)
+
+// pl2303Type is the chip generation, which decides the startup sequence and
+// how baud rates are encoded.
+type pl2303Type int
+
+const (
+	// pl2303H is the original PL2303, which works as a CDC class device.
+	pl2303H pl2303Type = iota
+	// pl2303HX covers the HX, HXD, TA, and EA chips.
+	pl2303HX
+	// pl2303HXN covers the G-series chips with product IDs other than
+	// 0x2303. They need no startup sequence and take any baud rate directly.
+	pl2303HXN
+)
+
+var pl2303MaxBaud = map[pl2303Type]int{
+	pl2303H:   1228800,
+	pl2303HX:  6000000,
+	pl2303HXN: 12000000,
+}
+
+// pl2303BaudRates are the rates the chip can be set to directly. Other rates
+// are encoded as a divisor of its 384 MHz clock.
+var pl2303BaudRates = []int{
+	75, 150, 300, 600, 1200, 1800, 2400, 3600, 4800, 7200, 9600, 14400, 19200,
+	28800, 38400, 57600, 115200, 230400, 460800, 614400, 921600, 1228800,
+	2457600, 3000000, 6000000,
+}
+
+const pl2303Clock = 384000000
+
+// PL2303 is a port of a Prolific PL2303 bridge.
+type PL2303 struct {
+	bulkPort
+
+	chip      pl2303Type
+	lineMu    sync.Mutex
+	lineState uint16
+}
+
+// OpenPL2303 claims the bridge's interface and runs the startup sequence the
+// chip generation needs. desc identifies the generation.
+func OpenPL2303(
+	handle Handle,
+	desc *libusb.Descriptor,
+	iface *libusb.InterfaceDescriptor,
+) (*PL2303, error) {
+	if desc == nil {
+		return nil, fmt.Errorf("serial: nil device descriptor")
+	}
+	port := &PL2303{chip: detectPL2303(desc)}
+	if err := port.open(handle, iface); err != nil {
+		return nil, err
+	}
+	if err := port.startup(); err != nil {
+		return nil, port.release(err)
+	}
+	return port, nil
+}
+
+func detectPL2303(desc *libusb.Descriptor) pl2303Type {
+	switch {
+	case desc.ProductID != 0x2303:
+		return pl2303HXN
+	case uint8(desc.DeviceClass) == libusb.InterfaceClassComm || desc.MaxPacketSize0 != 64:
+		return pl2303H
+	}
+	return pl2303HX
+}
+
+// startup sends the magic sequence the vendor driver does. Its reads only
+// matter for their side effects.
+func (port *PL2303) startup() error {
+	if port.chip == pl2303HXN {
+		return nil
+	}
+	final := uint16(0x44)
+	if port.chip == pl2303H {
+		final = 0x24
+	}
+	steps := []struct {
+		read  bool
+		value uint16
+		index uint16
+	}{
+		{true, 0x8484, 0},
+		{false, 0x0404, 0},
+		{true, 0x8484, 0},
+		{true, 0x8383, 0},
+		{true, 0x8484, 0},
+		{false, 0x0404, 1},
+		{true, 0x8484, 0},
+		{true, 0x8383, 0},
+		{false, 0, 1},
+		{false, 1, 0},
+		{false, 2, final},
+	}
+	buf := make([]byte, 1)
+	for _, step := range steps {
+		var err error
+		if step.read {
+			err = port.controlIn(
+				libusb.Vendor,
+				libusb.DeviceRecipient,
+				pl2303VendorRequest,
+				step.value,
+				step.index,
+				buf,
+			)
+		} else {
+			err = port.controlOut(
+				libusb.Vendor,
+				libusb.DeviceRecipient,
+				pl2303VendorRequest,
+				step.value,
+				step.index,
+				nil,
+			)
+		}
+		if err != nil {
+			return fmt.Errorf("serial: PL2303 startup: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close releases the interface.
+func (port *PL2303) Close() error {
+	return port.close(nil)
+}
+
+// Configure sets the baud rate and character format. Rates above the chip's
+// maximum are lowered to it.
+func (port *PL2303) Configure(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	line := make([]byte, 7)
+	copy(line, port.encodeBaudRate(config.BaudRate))
+	line[4] = byte(config.StopBits)
+	line[5] = byte(config.Parity)
+	line[6] = byte(config.DataBits)
+	return port.classRequest(pl2303SetLine, 0, line)
+}
+
+// encodeBaudRate returns the four baud rate bytes of the line setup.
+func (port *PL2303) encodeBaudRate(baudRate int) []byte {
+	baudRate = min(baudRate, pl2303MaxBaud[port.chip])
+	buf := make([]byte, 4)
+	if port.chip == pl2303HXN || slices.Contains(pl2303BaudRates, baudRate) {
+		binary.LittleEndian.PutUint32(buf, uint32(baudRate))
+		return buf
+	}
+	// The divisor is a 9-bit mantissa scaled down by a power of four.
+	mantissa := max(pl2303Clock/baudRate, 1)
+	exponent := 0
+	for mantissa >= 512 {
+		if exponent == 7 {
+			mantissa = 511
+			break
+		}
+		mantissa >>= 2
+		exponent++
+	}
+	buf[0] = byte(mantissa)
+	buf[1] = byte(exponent<<1 | mantissa>>8)
+	buf[3] = 0x80
+	return buf
+}
+
+// SetDTR drives the DTR output.
+func (port *PL2303) SetDTR(dtr bool) error {
+	return port.setControl(pl2303DTR, dtr)
+}
+
+// SetRTS drives the RTS output.
+func (port *PL2303) SetRTS(rts bool) error {
+	return port.setControl(pl2303RTS, rts)
+}
+
+func (port *PL2303) setControl(bit uint16, on bool) error {
+	port.lineMu.Lock()
+	defer port.lineMu.Unlock()
+	state := port.lineState &^ bit
+	if on {
+		state |= bit
+	}
+	if err := port.classRequest(pl2303SetControl, state, nil); err != nil {
+		return err
+	}
+	port.lineState = state
+	return nil
+}
+
+// SetBreak turns the break condition on or off.
+func (port *PL2303) SetBreak(on bool) error {
+	var value uint16
+	if on {
+		value = 0xFFFF
+	}
+	return port.classRequest(pl2303Break, value, nil)
+}
+
+func (port *PL2303) classRequest(request byte, value uint16, data []byte) error {
+	return port.controlOut(libusb.Class, libusb.InterfaceRecipient, request, value, 0, data)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func TestDetectPL2303(t *testing.T) {
+	testCases := []struct {
+		desc     *libusb.Descriptor
+		expected pl2303Type
+	}{
+		{&libusb.Descriptor{ProductID: 0x2303, MaxPacketSize0: 64}, pl2303HX},
+		{&libusb.Descriptor{ProductID: 0x2303, MaxPacketSize0: 8}, pl2303H},
+		{&libusb.Descriptor{ProductID: 0x2303, MaxPacketSize0: 64, DeviceClass: 0x02}, pl2303H},
+		{&libusb.Descriptor{ProductID: 0x23A3, MaxPacketSize0: 64}, pl2303HXN},
+	}
+	for _, tc := range testCases {
+		if got := detectPL2303(tc.desc); got != tc.expected {
+			t.Errorf("detectPL2303(%+v) = %d, want %d", tc.desc, got, tc.expected)
+		}
+	}
+}
+
+func TestPL2303EncodeBaudRate(t *testing.T) {
+	testCases := []struct {
+		chip     pl2303Type
+		baudRate int
+		expected []byte
+	}{
+		{pl2303HX, 9600, []byte{0x80, 0x25, 0x00, 0x00}},
+		{pl2303HX, 100000, []byte{0xF0, 0x04, 0x00, 0x80}},
+		{pl2303HX, 250000, []byte{0x80, 0x03, 0x00, 0x80}},
+		{pl2303HX, 12000000, []byte{0x80, 0x8D, 0x5B, 0x00}},
+		{pl2303H, 3000000, []byte{0x00, 0xC0, 0x12, 0x00}},
+		{pl2303HXN, 100000, []byte{0xA0, 0x86, 0x01, 0x00}},
+	}
+	for _, tc := range testCases {
+		port := &PL2303{chip: tc.chip}
+		if got := port.encodeBaudRate(tc.baudRate); !bytes.Equal(got, tc.expected) {
+			t.Errorf("encodeBaudRate(%d) on type %d = % x, want % x",
+				tc.baudRate, tc.chip, got, tc.expected)
+		}
+	}
+}
+
+func TestPL2303Requests(t *testing.T) {
+	fh := &fakeHandle{}
+	desc := &libusb.Descriptor{VendorID: 0x067B, ProductID: 0x2303, MaxPacketSize0: 64}
+	port, err := OpenPL2303(fh, desc, bridgeInterface(0))
+	if err != nil {
+		t.Fatalf("OpenPL2303: unexpected error %v", err)
+	}
+	if len(fh.requests) != 11 || fh.requests[10] != deviceOut(pl2303VendorRequest, 2, 0x44) {
+		t.Fatalf("startup requests = %v, want 11 ending with a write of 0x44 to 2", fh.requests)
+	}
+	fh.requests = nil
+	if err := port.Configure(Config{BaudRate: 19200, DataBits: 8, Parity: ParityEven}); err != nil {
+		t.Fatalf("Configure: unexpected error %v", err)
+	}
+	if err := port.SetDTR(true); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	if err := port.SetRTS(true); err != nil {
+		t.Fatalf("SetRTS: unexpected error %v", err)
+	}
+	if err := port.SetDTR(false); err != nil {
+		t.Fatalf("SetDTR: unexpected error %v", err)
+	}
+	classOut := func(request byte, value uint16, data string) controlRequest {
+		return controlRequest{
+			false, libusb.Class, libusb.InterfaceRecipient, request, value, 0, data,
+		}
+	}
+	want := []controlRequest{
+		classOut(pl2303SetLine, 0, "\x00\x4b\x00\x00\x00\x02\x08"),
+		classOut(pl2303SetControl, 1, ""),
+		classOut(pl2303SetControl, 3, ""),
+		classOut(pl2303SetControl, 2, ""),
+	}
+	if !slices.Equal(fh.requests, want) {
+		t.Errorf("requests =\n%v\nwant\n%v", fh.requests, want)
+	}
+
+	// The G-series chips need no startup sequence.
+	fh = &fakeHandle{}
+	desc.ProductID = 0x23A3
+	if _, err := OpenPL2303(fh, desc, bridgeInterface(0)); err != nil {
+		t.Fatalf("OpenPL2303: unexpected error %v", err)
+	}
+	if len(fh.requests) != 0 {
+		t.Errorf("HXN startup requests = %v, want none", fh.requests)
+	}
+}
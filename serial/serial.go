@@ -0,0 +1,316 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package serial puts USB serial adapters behind a common Port interface, so
+code talking to an instrument doesn't depend on which bridge chip is on the
+other end of the cable.
+
+The package drives the Silicon Labs CP210x, WCH CH340/CH341, and Prolific
+PL2303 bridges itself, and wraps the cdcacm and ftdi packages for CDC-ACM
+devices and FTDI chips. Open picks the driver from the device's vendor and
+product IDs, falling back to CDC-ACM.
+*/
+package serial
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/cdcacm"
+	"github.com/gotmc/libusb/v2/ftdi"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Timeout, in milliseconds, each bridge's Open starts
+// its port with, for the UART data and the vendor requests alike.
+const DefaultTimeout = 1000
+
+// Parity is the parity bit setting.
+type Parity byte
+
+// Parity settings.
+const (
+	ParityNone  Parity = 0
+	ParityOdd   Parity = 1
+	ParityEven  Parity = 2
+	ParityMark  Parity = 3
+	ParitySpace Parity = 4
+)
+
+var parities = map[Parity]string{
+	ParityNone:  "None",
+	ParityOdd:   "Odd",
+	ParityEven:  "Even",
+	ParityMark:  "Mark",
+	ParitySpace: "Space",
+}
+
+// String implements the Stringer interface for Parity.
+func (parity Parity) String() string {
+	return parities[parity]
+}
+
+// StopBits is the number of stop bits per character.
+type StopBits byte
+
+// Stop bit settings.
+const (
+	OneStopBit           StopBits = 0
+	OnePointFiveStopBits StopBits = 1
+	TwoStopBits          StopBits = 2
+)
+
+var stopBits = map[StopBits]string{
+	OneStopBit:           "1",
+	OnePointFiveStopBits: "1.5",
+	TwoStopBits:          "2",
+}
+
+// String implements the Stringer interface for StopBits.
+func (bits StopBits) String() string {
+	return stopBits[bits]
+}
+
+// Config is the line configuration of a serial port.
+type Config struct {
+	BaudRate int
+	// DataBits is 5 through 8. Not every bridge supports fewer than 7.
+	DataBits int
+	Parity   Parity
+	StopBits StopBits
+}
+
+// String formats the configuration in the usual 9600 8N1 notation.
+func (config Config) String() string {
+	parity := "?"
+	if name, ok := parities[config.Parity]; ok {
+		parity = name[:1]
+	}
+	return fmt.Sprintf("%d %d%s%s", config.BaudRate, config.DataBits, parity, config.StopBits)
+}
+
+func (config Config) validate() error {
+	switch {
+	case config.BaudRate <= 0:
+		return fmt.Errorf("serial: baud rate %d must be positive", config.BaudRate)
+	case config.DataBits < 5 || config.DataBits > 8:
+		return fmt.Errorf("serial: invalid data bits %d", config.DataBits)
+	case config.Parity > ParitySpace:
+		return fmt.Errorf("serial: invalid parity %d", config.Parity)
+	case config.StopBits > TwoStopBits:
+		return fmt.Errorf("serial: invalid stop bits %d", config.StopBits)
+	}
+	return nil
+}
+
+// Port is a serial port on any supported USB adapter.
+type Port interface {
+	io.ReadWriteCloser
+	// Configure sets the baud rate and character format.
+	Configure(config Config) error
+	SetDTR(dtr bool) error
+	SetRTS(rts bool) error
+}
+
+// Handle is what the bridge drivers need of a *libusb.DeviceHandle. Open
+// may also hand it to the cdcacm or ftdi package, so it covers theirs too.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Bridge identifies the driver used for a device.
+type Bridge int
+
+// Supported bridges.
+const (
+	BridgeCDCACM Bridge = iota
+	BridgeFTDI
+	BridgeCP210x
+	BridgeCH34x
+	BridgePL2303
+)
+
+var bridges = map[Bridge]string{
+	BridgeCDCACM: "CDC-ACM",
+	BridgeFTDI:   "FTDI",
+	BridgeCP210x: "CP210x",
+	BridgeCH34x:  "CH34x",
+	BridgePL2303: "PL2303",
+}
+
+// String implements the Stringer interface for Bridge.
+func (bridge Bridge) String() string {
+	return bridges[bridge]
+}
+
+type deviceID struct {
+	vendor  uint16
+	product uint16
+}
+
+// knownBridges lists the vendor and product IDs of the vendor-specific
+// bridges. FTDI chips are matched on the vendor ID alone.
+var knownBridges = map[deviceID]Bridge{
+	{0x10C4, 0xEA60}: BridgeCP210x,
+	{0x10C4, 0xEA61}: BridgeCP210x,
+	{0x10C4, 0xEA63}: BridgeCP210x,
+	{0x10C4, 0xEA70}: BridgeCP210x,
+	{0x10C4, 0xEA71}: BridgeCP210x,
+	{0x10C4, 0xEA7A}: BridgeCP210x,
+	{0x10C4, 0xEA7B}: BridgeCP210x,
+	{0x1A86, 0x7522}: BridgeCH34x,
+	{0x1A86, 0x7523}: BridgeCH34x,
+	{0x1A86, 0x5523}: BridgeCH34x,
+	{0x067B, 0x2303}: BridgePL2303,
+	{0x067B, 0x23A3}: BridgePL2303,
+	{0x067B, 0x23B3}: BridgePL2303,
+	{0x067B, 0x23C3}: BridgePL2303,
+	{0x067B, 0x23D3}: BridgePL2303,
+	{0x067B, 0x23E3}: BridgePL2303,
+	{0x067B, 0x23F3}: BridgePL2303,
+}
+
+// DetectBridge returns the driver Open uses for a device.
+func DetectBridge(desc *libusb.Descriptor) Bridge {
+	if desc.VendorID == ftdi.VendorID {
+		return BridgeFTDI
+	}
+	if bridge, ok := knownBridges[deviceID{desc.VendorID, desc.ProductID}]; ok {
+		return bridge
+	}
+	return BridgeCDCACM
+}
+
+// Open opens the first serial port of a device with the driver chosen by
+// DetectBridge. To use another port of a multi-port adapter, call the
+// driver's own Open function with that port's interface.
+func Open(handle Handle, desc *libusb.Descriptor, config *libusb.ConfigDescriptor) (Port, error) {
+	if handle == nil || desc == nil || config == nil {
+		return nil, fmt.Errorf("serial: nil handle or descriptor")
+	}
+	bridge := DetectBridge(desc)
+	if bridge == BridgeCDCACM {
+		functions, err := cdcacm.FindFunctions(config)
+		if err != nil {
+			return nil, err
+		}
+		if len(functions) == 0 {
+			return nil, fmt.Errorf("serial: device %04x:%04x isn't a known serial adapter",
+				desc.VendorID, desc.ProductID)
+		}
+		port, err := cdcacm.Open(handle, functions[0])
+		if err != nil {
+			return nil, err
+		}
+		return FromACM(port), nil
+	}
+
+	iface := firstInterface(config)
+	if iface == nil {
+		return nil, fmt.Errorf("serial: configuration has no interfaces")
+	}
+	switch bridge {
+	case BridgeFTDI:
+		chip, err := ftdi.DetectChip(desc)
+		if err != nil {
+			return nil, err
+		}
+		port, err := ftdi.Open(handle, chip, iface)
+		if err != nil {
+			return nil, err
+		}
+		return FromFTDI(port), nil
+	case BridgeCP210x:
+		return OpenCP210x(handle, iface)
+	case BridgeCH34x:
+		return OpenCH34x(handle, iface)
+	default:
+		return OpenPL2303(handle, desc, iface)
+	}
+}
+
+func firstInterface(config *libusb.ConfigDescriptor) *libusb.InterfaceDescriptor {
+	for _, si := range config.SupportedInterfaces {
+		if si != nil && len(si.InterfaceDescriptors) > 0 {
+			return si.InterfaceDescriptors[0]
+		}
+	}
+	return nil
+}
+
+// acmPort adapts a cdcacm.Port to Port.
+type acmPort struct {
+	*cdcacm.Port
+}
+
+// FromACM returns a Port backed by a CDC-ACM port.
+func FromACM(port *cdcacm.Port) Port {
+	return acmPort{port}
+}
+
+func (port acmPort) Configure(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return port.SetLineCoding(cdcacm.LineCoding{
+		BaudRate: uint32(config.BaudRate),
+		StopBits: cdcacm.StopBits(config.StopBits),
+		Parity:   cdcacm.Parity(config.Parity),
+		DataBits: uint8(config.DataBits),
+	})
+}
+
+// ftdiPort adapts an ftdi.Port to Port.
+type ftdiPort struct {
+	*ftdi.Port
+}
+
+// FromFTDI returns a Port backed by an FTDI port.
+func FromFTDI(port *ftdi.Port) Port {
+	return ftdiPort{port}
+}
+
+func (port ftdiPort) Configure(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if _, err := port.SetBaudRate(config.BaudRate); err != nil {
+		return err
+	}
+	return port.SetLineProperties(
+		ftdi.DataBits(config.DataBits),
+		ftdi.StopBits(config.StopBits),
+		ftdi.Parity(config.Parity),
+	)
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package serial
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type controlRequest struct {
+	in        bool
+	reqType   libusb.RequestType
+	recipient libusb.RequestRecipient
+	request   byte
+	value     uint16
+	index     uint16
+	data      string
+}
+
+// fakeHandle records control requests and bulk OUT data, and serves
+// control IN replies by request number and bulk IN transfers from a queue.
+type fakeHandle struct {
+	usbiftest.Claimer
+	requests  []controlRequest
+	controlIn map[byte][]byte
+	bulkIn    [][]byte
+	bulkOut   []byte
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests,
+		controlRequest{true, reqType, recipient, request, value, index, ""})
+	reply := fh.controlIn[request]
+	if reply == nil {
+		reply = make([]byte, maxReceiveLength)
+	}
+	return copy(data[:maxReceiveLength], reply), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.requests = append(fh.requests,
+		controlRequest{false, reqType, recipient, request, value, index, string(data)})
+	return len(data), nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.bulkOut = append(fh.bulkOut, data[:length]...)
+		return length, nil
+	}
+	if len(fh.bulkIn) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	transfer := fh.bulkIn[0]
+	fh.bulkIn = fh.bulkIn[1:]
+	return copy(data[:length], transfer), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	return 0, libusb.ErrTimeout
+}
+
+// bridgeInterface is a vendor-specific interface with an interrupt endpoint
+// and a bulk pair, laid out like a PL2303's.
+func bridgeInterface(number int) *libusb.InterfaceDescriptor {
+	return &libusb.InterfaceDescriptor{
+		InterfaceNumber: number,
+		InterfaceClass:  libusb.InterfaceClassVendorSpec,
+		EndpointDescriptors: libusb.EndpointDescriptors{
+			{EndpointAddress: 0x81, Attributes: 0x03, MaxPacketSize: 10},
+			{EndpointAddress: 0x02, Attributes: 0x02, MaxPacketSize: 64},
+			{EndpointAddress: 0x83, Attributes: 0x02, MaxPacketSize: 64},
+		},
+	}
+}
+
+func bridgeConfig() *libusb.ConfigDescriptor {
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{bridgeInterface(0)}},
+		},
+	}
+}
+
+func TestDetectBridge(t *testing.T) {
+	testCases := []struct {
+		vendor   uint16
+		product  uint16
+		expected Bridge
+	}{
+		{0x0403, 0x6001, BridgeFTDI},
+		{0x0403, 0x6010, BridgeFTDI},
+		{0x10C4, 0xEA60, BridgeCP210x},
+		{0x1A86, 0x7523, BridgeCH34x},
+		{0x067B, 0x2303, BridgePL2303},
+		{0x067B, 0x23A3, BridgePL2303},
+		{0x2341, 0x0043, BridgeCDCACM},
+	}
+	for _, tc := range testCases {
+		desc := &libusb.Descriptor{VendorID: tc.vendor, ProductID: tc.product}
+		if got := DetectBridge(desc); got != tc.expected {
+			t.Errorf("DetectBridge(%04x:%04x) = %v, want %v",
+				tc.vendor, tc.product, got, tc.expected)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	testCases := []struct {
+		config   Config
+		expected string
+		valid    bool
+	}{
+		{Config{BaudRate: 9600, DataBits: 8}, "9600 8N1", true},
+		{Config{BaudRate: 115200, DataBits: 7, Parity: ParityEven, StopBits: TwoStopBits},
+			"115200 7E2", true},
+		{Config{BaudRate: 300, DataBits: 5, StopBits: OnePointFiveStopBits}, "300 5N1.5", true},
+		{Config{BaudRate: 0, DataBits: 8}, "0 8N1", false},
+		{Config{BaudRate: 9600, DataBits: 9}, "9600 9N1", false},
+		{Config{BaudRate: 9600, DataBits: 8, Parity: 5}, "9600 8?1", false},
+	}
+	for _, tc := range testCases {
+		if got := tc.config.String(); got != tc.expected {
+			t.Errorf("String() = %q, want %q", got, tc.expected)
+		}
+		if err := tc.config.validate(); (err == nil) != tc.valid {
+			t.Errorf("validate(%v) = %v, want valid %t", tc.config, err, tc.valid)
+		}
+	}
+}
+
+func TestOpenDispatch(t *testing.T) {
+	testCases := []struct {
+		desc     *libusb.Descriptor
+		expected string
+	}{
+		{&libusb.Descriptor{VendorID: 0x10C4, ProductID: 0xEA60}, "*serial.CP210x"},
+		{&libusb.Descriptor{VendorID: 0x1A86, ProductID: 0x7523}, "*serial.CH34x"},
+		{&libusb.Descriptor{VendorID: 0x067B, ProductID: 0x23A3}, "*serial.PL2303"},
+		{
+			&libusb.Descriptor{VendorID: 0x0403, ProductID: 0x6001, DeviceReleaseNumber: 0x0600},
+			"serial.ftdiPort",
+		},
+	}
+	for _, tc := range testCases {
+		fh := &fakeHandle{controlIn: map[byte][]byte{ch34xReadVersion: {0x31, 0x00}}}
+		port, err := Open(fh, tc.desc, bridgeConfig())
+		if err != nil {
+			t.Errorf("Open(%04x:%04x): unexpected error %v",
+				tc.desc.VendorID, tc.desc.ProductID, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", port); got != tc.expected {
+			t.Errorf("Open(%04x:%04x) = %s, want %s",
+				tc.desc.VendorID, tc.desc.ProductID, got, tc.expected)
+		}
+		if err := port.Close(); err != nil {
+			t.Errorf("Close: unexpected error %v", err)
+		}
+	}
+
+	desc := &libusb.Descriptor{VendorID: 0x2341, ProductID: 0x0043}
+	if _, err := Open(&fakeHandle{}, desc, bridgeConfig()); err == nil {
+		t.Error("Open of a device without a CDC-ACM function: expected error, got nil")
+	}
+}
@@ -8,6 +8,10 @@ package libusb
 // #cgo pkg-config: libusb-1.0
 // #include <libusb.h>
 import "C"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 // TransferDirection represents the direction of a control transfer.
 type TransferDirection byte
@@ -80,3 +84,114 @@ func BitmapRequestType(dir TransferDirection, reqType RequestType,
 	recipient RequestRecipient) byte {
 	return byte(dir) | byte(reqType) | byte(recipient)
 }
+
+// setupPacketSize is the size in bytes of a control transfer setup packet,
+// which is sizeof(struct libusb_control_setup).
+const setupPacketSize = 8
+
+// SetupPacket models the 8-byte setup packet that begins every control
+// transfer. See USB 2.0 section 9.3.
+type SetupPacket struct {
+	RequestType byte   // bmRequestType
+	Request     byte   // bRequest
+	Value       uint16 // wValue
+	Index       uint16 // wIndex
+	Length      uint16 // wLength
+}
+
+// NewSetupPacket creates a SetupPacket, building the bmRequestType from the
+// given direction, request type, and recipient.
+func NewSetupPacket(
+	dir TransferDirection,
+	reqType RequestType,
+	recipient RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	length uint16,
+) SetupPacket {
+	return SetupPacket{
+		RequestType: BitmapRequestType(dir, reqType, recipient),
+		Request:     request,
+		Value:       value,
+		Index:       index,
+		Length:      length,
+	}
+}
+
+// ParseSetupPacket decodes a SetupPacket from its 8-byte wire format.
+func ParseSetupPacket(data []byte) (SetupPacket, error) {
+	var setup SetupPacket
+	err := setup.UnmarshalBinary(data)
+	return setup, err
+}
+
+// Direction returns the data stage direction from bit 7 of bmRequestType.
+func (setup SetupPacket) Direction() TransferDirection {
+	const directionMask = 0x80
+	return TransferDirection(setup.RequestType & directionMask)
+}
+
+// Type returns the request type from bits 5:6 of bmRequestType.
+func (setup SetupPacket) Type() RequestType {
+	const typeMask = 0x60
+	return RequestType(setup.RequestType & typeMask)
+}
+
+// Recipient returns the recipient from bits 0:4 of bmRequestType.
+func (setup SetupPacket) Recipient() RequestRecipient {
+	const recipientMask = 0x1F
+	return RequestRecipient(setup.RequestType & recipientMask)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, encoding
+// the SetupPacket into the 8-byte little-endian wire format.
+func (setup SetupPacket) MarshalBinary() ([]byte, error) {
+	data := make([]byte, setupPacketSize)
+	data[0] = setup.RequestType
+	data[1] = setup.Request
+	binary.LittleEndian.PutUint16(data[2:4], setup.Value)
+	binary.LittleEndian.PutUint16(data[4:6], setup.Index)
+	binary.LittleEndian.PutUint16(data[6:8], setup.Length)
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface,
+// decoding the SetupPacket from the 8-byte little-endian wire format.
+func (setup *SetupPacket) UnmarshalBinary(data []byte) error {
+	if len(data) != setupPacketSize {
+		return fmt.Errorf(
+			"setup packet is %d bytes; want %d", len(data), setupPacketSize,
+		)
+	}
+	setup.RequestType = data[0]
+	setup.Request = data[1]
+	setup.Value = binary.LittleEndian.Uint16(data[2:4])
+	setup.Index = binary.LittleEndian.Uint16(data[4:6])
+	setup.Length = binary.LittleEndian.Uint16(data[6:8])
+	return nil
+}
+
+// String implements the Stringer interface for SetupPacket.
+func (setup SetupPacket) String() string {
+	recipient := setup.Recipient().String()
+	if recipient == "" {
+		recipient = fmt.Sprintf("Recipient(%d)", setup.Recipient())
+	}
+	request := fmt.Sprintf("0x%02x", setup.Request)
+	if setup.Type() == Standard {
+		if name := StandardRequest(setup.Request).String(); name != "" {
+			request = fmt.Sprintf("%s (0x%02x)", name, setup.Request)
+		}
+	}
+	return fmt.Sprintf(
+		"%s %s %s bRequest=%s wValue=0x%04x wIndex=0x%04x wLength=%d",
+		setup.Direction(),
+		setup.Type(),
+		recipient,
+		request,
+		setup.Value,
+		setup.Index,
+		setup.Length,
+	)
+}
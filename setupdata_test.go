@@ -158,3 +158,106 @@ func TestTransferDirectionConstants(t *testing.T) {
 		t.Errorf("DeviceToHost = 0x%02x, want 0x80", DeviceToHost)
 	}
 }
+
+func TestSetupPacketMarshalBinary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		setup    SetupPacket
+		expected []byte
+	}{
+		{
+			"get device descriptor",
+			NewSetupPacket(DeviceToHost, Standard, DeviceRecipient, 0x06, 0x0100, 0x0000, 18),
+			[]byte{0x80, 0x06, 0x00, 0x01, 0x00, 0x00, 0x12, 0x00},
+		},
+		{
+			"vendor out",
+			NewSetupPacket(HostToDevice, Vendor, DeviceRecipient, 0x0C, 0x0000, 0x0475, 8),
+			[]byte{0x40, 0x0C, 0x00, 0x00, 0x75, 0x04, 0x08, 0x00},
+		},
+		{
+			"class interface in",
+			NewSetupPacket(DeviceToHost, Class, InterfaceRecipient, 0x07, 0xABCD, 0x1234, 0x0118),
+			[]byte{0xA1, 0x07, 0xCD, 0xAB, 0x34, 0x12, 0x18, 0x01},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.setup.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: unexpected error %v", err)
+			}
+			if string(data) != string(tc.expected) {
+				t.Errorf("MarshalBinary = % x, want % x", data, tc.expected)
+			}
+			parsed, err := ParseSetupPacket(data)
+			if err != nil {
+				t.Fatalf("ParseSetupPacket: unexpected error %v", err)
+			}
+			if parsed != tc.setup {
+				t.Errorf("ParseSetupPacket = %+v, want %+v", parsed, tc.setup)
+			}
+		})
+	}
+}
+
+func TestSetupPacketUnmarshalBinaryLength(t *testing.T) {
+	for _, n := range []int{0, 7, 9} {
+		var setup SetupPacket
+		if err := setup.UnmarshalBinary(make([]byte, n)); err == nil {
+			t.Errorf("UnmarshalBinary(%d bytes): expected error, got nil", n)
+		}
+	}
+}
+
+func TestSetupPacketFields(t *testing.T) {
+	setup := SetupPacket{RequestType: 0xC2}
+	if setup.Direction() != DeviceToHost {
+		t.Errorf("Direction() = %v, want %v", setup.Direction(), DeviceToHost)
+	}
+	if setup.Type() != Vendor {
+		t.Errorf("Type() = %v, want %v", setup.Type(), Vendor)
+	}
+	if setup.Recipient() != EndpointRecipient {
+		t.Errorf("Recipient() = %v, want %v", setup.Recipient(), EndpointRecipient)
+	}
+}
+
+func TestSetupPacketString(t *testing.T) {
+	testCases := []struct {
+		setup    SetupPacket
+		expected string
+	}{
+		{
+			NewSetupPacket(DeviceToHost, Standard, DeviceRecipient, 0x06, 0x0100, 0, 18),
+			"Device-to-host Standard Device bRequest=GET_DESCRIPTOR (0x06) " +
+				"wValue=0x0100 wIndex=0x0000 wLength=18",
+		},
+		{
+			NewSetupPacket(HostToDevice, Vendor, InterfaceRecipient, 0x0C, 0, 0x0475, 8),
+			"Host-to-device Vendor Interface bRequest=0x0c " +
+				"wValue=0x0000 wIndex=0x0475 wLength=8",
+		},
+		{
+			// Small values are zero-padded to the width of their fields.
+			NewSetupPacket(HostToDevice, Standard, DeviceRecipient, 0x05, 0x0001, 0x0002, 0),
+			"Host-to-device Standard Device bRequest=SET_ADDRESS (0x05) " +
+				"wValue=0x0001 wIndex=0x0002 wLength=0",
+		},
+		{
+			NewSetupPacket(HostToDevice, Class, OtherRecipient, 0x03, 0x0010, 0x0001, 0),
+			"Host-to-device Class Other bRequest=0x03 " +
+				"wValue=0x0010 wIndex=0x0001 wLength=0",
+		},
+		{
+			SetupPacket{RequestType: 0x85, Request: 0x01},
+			"Device-to-host Standard Recipient(5) bRequest=CLEAR_FEATURE (0x01) " +
+				"wValue=0x0000 wIndex=0x0000 wLength=0",
+		},
+	}
+	for _, tc := range testCases {
+		if got := tc.setup.String(); got != tc.expected {
+			t.Errorf("String() = %q, want %q", got, tc.expected)
+		}
+	}
+}
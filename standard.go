@@ -0,0 +1,378 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+// #cgo pkg-config: libusb-1.0
+// #include <libusb.h>
+import "C"
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// standardRequestTimeout is the timeout in milliseconds used for the standard
+// requests. It matches the timeout libusb uses for
+// libusb_get_string_descriptor and libusb_get_descriptor.
+const standardRequestTimeout = 1000
+
+// StandardRequest is the bRequest value of a USB standard device request as
+// defined in chapter 9 of the USB specification.
+type StandardRequest byte
+
+// Standard request codes http://bit.ly/enum_libusb_standard_request
+const (
+	RequestGetStatus        StandardRequest = C.LIBUSB_REQUEST_GET_STATUS
+	RequestClearFeature     StandardRequest = C.LIBUSB_REQUEST_CLEAR_FEATURE
+	RequestSetFeature       StandardRequest = C.LIBUSB_REQUEST_SET_FEATURE
+	RequestSetAddress       StandardRequest = C.LIBUSB_REQUEST_SET_ADDRESS
+	RequestGetDescriptor    StandardRequest = C.LIBUSB_REQUEST_GET_DESCRIPTOR
+	RequestSetDescriptor    StandardRequest = C.LIBUSB_REQUEST_SET_DESCRIPTOR
+	RequestGetConfiguration StandardRequest = C.LIBUSB_REQUEST_GET_CONFIGURATION
+	RequestSetConfiguration StandardRequest = C.LIBUSB_REQUEST_SET_CONFIGURATION
+	RequestGetInterface     StandardRequest = C.LIBUSB_REQUEST_GET_INTERFACE
+	RequestSetInterface     StandardRequest = C.LIBUSB_REQUEST_SET_INTERFACE
+	RequestSynchFrame       StandardRequest = C.LIBUSB_REQUEST_SYNCH_FRAME
+	RequestSetSel           StandardRequest = C.LIBUSB_REQUEST_SET_SEL
+	RequestSetIsochDelay    StandardRequest = C.LIBUSB_SET_ISOCH_DELAY
+)
+
+var standardRequests = map[StandardRequest]string{
+	RequestGetStatus:        "GET_STATUS",
+	RequestClearFeature:     "CLEAR_FEATURE",
+	RequestSetFeature:       "SET_FEATURE",
+	RequestSetAddress:       "SET_ADDRESS",
+	RequestGetDescriptor:    "GET_DESCRIPTOR",
+	RequestSetDescriptor:    "SET_DESCRIPTOR",
+	RequestGetConfiguration: "GET_CONFIGURATION",
+	RequestSetConfiguration: "SET_CONFIGURATION",
+	RequestGetInterface:     "GET_INTERFACE",
+	RequestSetInterface:     "SET_INTERFACE",
+	RequestSynchFrame:       "SYNCH_FRAME",
+	RequestSetSel:           "SET_SEL",
+	RequestSetIsochDelay:    "SET_ISOCH_DELAY",
+}
+
+// String implements the Stringer interface for StandardRequest.
+func (req StandardRequest) String() string {
+	return standardRequests[req]
+}
+
+// FeatureSelector is the wValue of a SET_FEATURE or CLEAR_FEATURE request.
+type FeatureSelector uint16
+
+// Standard feature selectors from USB 2.0 Table 9-6 and USB 3.2 Table 9-7.
+const (
+	FeatureEndpointHalt       FeatureSelector = 0
+	FeatureDeviceRemoteWakeup FeatureSelector = 1
+	FeatureTestMode           FeatureSelector = 2
+	FeatureU1Enable           FeatureSelector = 48
+	FeatureU2Enable           FeatureSelector = 49
+	FeatureLTMEnable          FeatureSelector = 50
+)
+
+var featureSelectors = map[FeatureSelector]string{
+	FeatureEndpointHalt:       "ENDPOINT_HALT",
+	FeatureDeviceRemoteWakeup: "DEVICE_REMOTE_WAKEUP",
+	FeatureTestMode:           "TEST_MODE",
+	FeatureU1Enable:           "U1_ENABLE",
+	FeatureU2Enable:           "U2_ENABLE",
+	FeatureLTMEnable:          "LTM_ENABLE",
+}
+
+// String implements the Stringer interface for FeatureSelector.
+func (feature FeatureSelector) String() string {
+	return featureSelectors[feature]
+}
+
+// TestModeSelector selects the high-speed test mode entered with
+// SetTestMode.
+type TestModeSelector byte
+
+// Test mode selectors from USB 2.0 Table 9-7.
+const (
+	TestJ           TestModeSelector = 0x01
+	TestK           TestModeSelector = 0x02
+	TestSE0NAK      TestModeSelector = 0x03
+	TestPacket      TestModeSelector = 0x04
+	TestForceEnable TestModeSelector = 0x05
+)
+
+// Status bits returned by GET_STATUS. The device bits are defined in USB 2.0
+// Figure 9-4 and USB 3.2 Figure 9-4; the endpoint bit in USB 2.0 Figure 9-6.
+const (
+	StatusSelfPowered  uint16 = 1 << 0
+	StatusRemoteWakeup uint16 = 1 << 1
+	StatusU1Enable     uint16 = 1 << 2
+	StatusU2Enable     uint16 = 1 << 3
+	StatusLTMEnable    uint16 = 1 << 4
+	StatusEndpointHalt uint16 = 1 << 0
+)
+
+const (
+	deviceQualifierSize  = 10
+	configDescriptorSize = C.LIBUSB_DT_CONFIG_SIZE
+)
+
+// DeviceQualifierDescriptor models the device_qualifier descriptor, which
+// describes how a high-speed capable device would operate at the other
+// speed.
+type DeviceQualifierDescriptor struct {
+	Length            uint8
+	DescriptorType    descriptorType
+	USBSpecification  bcd
+	DeviceClass       classCode
+	DeviceSubClass    byte
+	DeviceProtocol    byte
+	MaxPacketSize0    uint8
+	NumConfigurations uint8
+}
+
+// GetStatus issues a GET_STATUS request to the given recipient. The index is
+// zero for the device, the interface number for an interface, or the
+// endpoint address for an endpoint.
+func (dh *DeviceHandle) GetStatus(
+	recipient RequestRecipient,
+	index uint16,
+) (uint16, error) {
+	if dh == nil || dh.transferer == nil {
+		return 0, ErrorCode(errorInvalidParam)
+	}
+	data := make([]byte, 2)
+	n, err := dh.ControlIn(
+		Standard,
+		recipient,
+		byte(RequestGetStatus),
+		0,
+		index,
+		data,
+		len(data),
+		standardRequestTimeout,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(data) {
+		return 0, fmt.Errorf("GET_STATUS returned %d bytes; want %d", n, len(data))
+	}
+	return binary.LittleEndian.Uint16(data), nil
+}
+
+// DeviceStatus returns the device status bits, such as StatusSelfPowered and
+// StatusRemoteWakeup.
+func (dh *DeviceHandle) DeviceStatus() (uint16, error) {
+	return dh.GetStatus(DeviceRecipient, 0)
+}
+
+// InterfaceStatus returns the status of the given interface.
+func (dh *DeviceHandle) InterfaceStatus(interfaceNum int) (uint16, error) {
+	return dh.GetStatus(InterfaceRecipient, uint16(interfaceNum))
+}
+
+// EndpointStatus returns the status of the given endpoint. StatusEndpointHalt
+// is set when the endpoint is halted.
+func (dh *DeviceHandle) EndpointStatus(endpoint EndpointAddress) (uint16, error) {
+	return dh.GetStatus(EndpointRecipient, uint16(endpoint))
+}
+
+// SetFeature issues a SET_FEATURE request to enable the given feature on the
+// recipient. The index follows the same rules as GetStatus.
+func (dh *DeviceHandle) SetFeature(
+	recipient RequestRecipient,
+	feature FeatureSelector,
+	index uint16,
+) error {
+	return dh.featureRequest(RequestSetFeature, recipient, feature, index)
+}
+
+// ClearFeature issues a CLEAR_FEATURE request to disable the given feature on
+// the recipient. The index follows the same rules as GetStatus.
+func (dh *DeviceHandle) ClearFeature(
+	recipient RequestRecipient,
+	feature FeatureSelector,
+	index uint16,
+) error {
+	return dh.featureRequest(RequestClearFeature, recipient, feature, index)
+}
+
+// SetTestMode places a high-speed device into the given test mode. The device
+// must be power cycled to exit test mode.
+func (dh *DeviceHandle) SetTestMode(selector TestModeSelector) error {
+	// Per USB 2.0 section 9.4.9, the test selector is in the upper byte of
+	// wIndex and the lower byte must be zero.
+	return dh.SetFeature(DeviceRecipient, FeatureTestMode, uint16(selector)<<8)
+}
+
+func (dh *DeviceHandle) featureRequest(
+	request StandardRequest,
+	recipient RequestRecipient,
+	feature FeatureSelector,
+	index uint16,
+) error {
+	if dh == nil || dh.transferer == nil {
+		return ErrorCode(errorInvalidParam)
+	}
+	_, err := dh.ControlOut(
+		Standard,
+		recipient,
+		byte(request),
+		uint16(feature),
+		index,
+		nil,
+		standardRequestTimeout,
+	)
+	return err
+}
+
+// GetDescriptor issues a GET_DESCRIPTOR request to the device for the given
+// descriptor type and index, returning at most length bytes. The langID is
+// only meaningful for string descriptors and should otherwise be zero.
+func (dh *DeviceHandle) GetDescriptor(
+	descType descriptorType,
+	descIndex uint8,
+	langID uint16,
+	length int,
+) ([]byte, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	data := make([]byte, length)
+	n, err := dh.ControlIn(
+		Standard,
+		DeviceRecipient,
+		byte(RequestGetDescriptor),
+		uint16(descType)<<8|uint16(descIndex),
+		langID,
+		data,
+		length,
+		standardRequestTimeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// DeviceQualifier gets the device_qualifier descriptor. Devices that aren't
+// high-speed capable stall this request.
+func (dh *DeviceHandle) DeviceQualifier() (*DeviceQualifierDescriptor, error) {
+	data, err := dh.GetDescriptor(descDeviceQualifier, 0, 0, deviceQualifierSize)
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceQualifier(data)
+}
+
+// RawConfigDescriptor reads the complete configuration descriptor for the
+// given index from the device, including all interface, endpoint, and
+// class-specific descriptors, as the raw bytes sent on the bus.
+func (dh *DeviceHandle) RawConfigDescriptor(configIndex uint8) ([]byte, error) {
+	return dh.fullConfigDescriptor(descConfig, configIndex)
+}
+
+// OtherSpeedConfigDescriptor reads the complete other_speed_configuration
+// descriptor for the given index as raw bytes. Its layout matches a
+// configuration descriptor.
+func (dh *DeviceHandle) OtherSpeedConfigDescriptor(configIndex uint8) ([]byte, error) {
+	return dh.fullConfigDescriptor(descOtherSpeedConfig, configIndex)
+}
+
+// fullConfigDescriptor reads the 9-byte header to learn wTotalLength and then
+// reads the whole descriptor set.
+func (dh *DeviceHandle) fullConfigDescriptor(
+	descType descriptorType,
+	configIndex uint8,
+) ([]byte, error) {
+	header, err := dh.GetDescriptor(descType, configIndex, 0, configDescriptorSize)
+	if err != nil {
+		return nil, err
+	}
+	totalLength, err := configTotalLength(header)
+	if err != nil {
+		return nil, err
+	}
+	data, err := dh.GetDescriptor(descType, configIndex, 0, totalLength)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != totalLength {
+		return nil, fmt.Errorf(
+			"configuration descriptor returned %d bytes; want %d",
+			len(data),
+			totalLength,
+		)
+	}
+	return data, nil
+}
+
+// SynchFrame issues a SYNCH_FRAME request to an isochronous endpoint and
+// returns the frame number in which the endpoint's repeating pattern begins.
+func (dh *DeviceHandle) SynchFrame(endpoint EndpointAddress) (uint16, error) {
+	if dh == nil || dh.transferer == nil {
+		return 0, ErrorCode(errorInvalidParam)
+	}
+	data := make([]byte, 2)
+	n, err := dh.ControlIn(
+		Standard,
+		EndpointRecipient,
+		byte(RequestSynchFrame),
+		0,
+		uint16(endpoint),
+		data,
+		len(data),
+		standardRequestTimeout,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(data) {
+		return 0, fmt.Errorf("SYNCH_FRAME returned %d bytes; want %d", n, len(data))
+	}
+	return binary.LittleEndian.Uint16(data), nil
+}
+
+// parseDeviceQualifier converts the raw device_qualifier descriptor into a
+// DeviceQualifierDescriptor.
+func parseDeviceQualifier(data []byte) (*DeviceQualifierDescriptor, error) {
+	if len(data) < deviceQualifierSize {
+		return nil, fmt.Errorf(
+			"device qualifier descriptor is %d bytes; want %d",
+			len(data),
+			deviceQualifierSize,
+		)
+	}
+	if descriptorType(data[1]) != descDeviceQualifier {
+		return nil, fmt.Errorf(
+			"descriptor type %#02x is not a device qualifier", data[1],
+		)
+	}
+	return &DeviceQualifierDescriptor{
+		Length:            data[0],
+		DescriptorType:    descriptorType(data[1]),
+		USBSpecification:  bcd(binary.LittleEndian.Uint16(data[2:4])),
+		DeviceClass:       classCode(data[4]),
+		DeviceSubClass:    data[5],
+		DeviceProtocol:    data[6],
+		MaxPacketSize0:    data[7],
+		NumConfigurations: data[8],
+	}, nil
+}
+
+// configTotalLength returns wTotalLength from a configuration (or
+// other-speed configuration) descriptor header.
+func configTotalLength(header []byte) (int, error) {
+	if len(header) < configDescriptorSize {
+		return 0, fmt.Errorf(
+			"configuration descriptor header is %d bytes; want %d",
+			len(header),
+			configDescriptorSize,
+		)
+	}
+	totalLength := int(binary.LittleEndian.Uint16(header[2:4]))
+	if totalLength < configDescriptorSize {
+		return 0, fmt.Errorf("invalid wTotalLength %d", totalLength)
+	}
+	return totalLength, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"testing"
+)
+
+func TestStandardRequestsNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+
+	if _, err := dh.GetStatus(DeviceRecipient, 0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("GetStatus: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.DeviceStatus(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("DeviceStatus: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.InterfaceStatus(0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("InterfaceStatus: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.EndpointStatus(0x81); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("EndpointStatus: got %v, want errorInvalidParam", err)
+	}
+	err := dh.SetFeature(DeviceRecipient, FeatureDeviceRemoteWakeup, 0)
+	if err != ErrorCode(errorInvalidParam) {
+		t.Errorf("SetFeature: got %v, want errorInvalidParam", err)
+	}
+	err = dh.ClearFeature(EndpointRecipient, FeatureEndpointHalt, 0x81)
+	if err != ErrorCode(errorInvalidParam) {
+		t.Errorf("ClearFeature: got %v, want errorInvalidParam", err)
+	}
+	if err := dh.SetTestMode(TestPacket); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("SetTestMode: got %v, want errorInvalidParam", err)
+	}
+	if err := dh.ClearHalt(0x81); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("ClearHalt: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.GetDescriptor(descDevice, 0, 0, 18); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("GetDescriptor: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.DeviceQualifier(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("DeviceQualifier: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.RawConfigDescriptor(0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("RawConfigDescriptor: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.OtherSpeedConfigDescriptor(0); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("OtherSpeedConfigDescriptor: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.SynchFrame(0x81); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("SynchFrame: got %v, want errorInvalidParam", err)
+	}
+}
+
+func TestStandardRequestString(t *testing.T) {
+	testCases := []struct {
+		request  StandardRequest
+		value    byte
+		expected string
+	}{
+		{RequestGetStatus, 0x00, "GET_STATUS"},
+		{RequestClearFeature, 0x01, "CLEAR_FEATURE"},
+		{RequestSetFeature, 0x03, "SET_FEATURE"},
+		{RequestSetAddress, 0x05, "SET_ADDRESS"},
+		{RequestGetDescriptor, 0x06, "GET_DESCRIPTOR"},
+		{RequestSetDescriptor, 0x07, "SET_DESCRIPTOR"},
+		{RequestGetConfiguration, 0x08, "GET_CONFIGURATION"},
+		{RequestSetConfiguration, 0x09, "SET_CONFIGURATION"},
+		{RequestGetInterface, 0x0A, "GET_INTERFACE"},
+		{RequestSetInterface, 0x0B, "SET_INTERFACE"},
+		{RequestSynchFrame, 0x0C, "SYNCH_FRAME"},
+		{RequestSetSel, 0x30, "SET_SEL"},
+		{RequestSetIsochDelay, 0x31, "SET_ISOCH_DELAY"},
+	}
+	for _, tc := range testCases {
+		if byte(tc.request) != tc.value {
+			t.Errorf("%s = %#02x, want %#02x", tc.expected, byte(tc.request), tc.value)
+		}
+		if got := tc.request.String(); got != tc.expected {
+			t.Errorf("StandardRequest(%d).String() = %q, want %q", tc.request, got, tc.expected)
+		}
+	}
+}
+
+func TestFeatureSelectorString(t *testing.T) {
+	testCases := []struct {
+		feature  FeatureSelector
+		expected string
+	}{
+		{FeatureEndpointHalt, "ENDPOINT_HALT"},
+		{FeatureDeviceRemoteWakeup, "DEVICE_REMOTE_WAKEUP"},
+		{FeatureTestMode, "TEST_MODE"},
+		{FeatureU1Enable, "U1_ENABLE"},
+		{FeatureU2Enable, "U2_ENABLE"},
+		{FeatureLTMEnable, "LTM_ENABLE"},
+		{FeatureSelector(99), ""},
+	}
+	for _, tc := range testCases {
+		if got := tc.feature.String(); got != tc.expected {
+			t.Errorf("FeatureSelector(%d).String() = %q, want %q", tc.feature, got, tc.expected)
+		}
+	}
+}
+
+func TestParseDeviceQualifier(t *testing.T) {
+	data := []byte{0x0A, 0x06, 0x00, 0x02, 0xEF, 0x02, 0x01, 0x40, 0x01, 0x00}
+	dq, err := parseDeviceQualifier(data)
+	if err != nil {
+		t.Fatalf("parseDeviceQualifier: unexpected error %v", err)
+	}
+	if dq.Length != 10 {
+		t.Errorf("Length = %d, want 10", dq.Length)
+	}
+	if dq.DescriptorType != descDeviceQualifier {
+		t.Errorf("DescriptorType = %d, want %d", dq.DescriptorType, descDeviceQualifier)
+	}
+	if dq.USBSpecification != 0x0200 {
+		t.Errorf("USBSpecification = %v, want 0x0200", dq.USBSpecification)
+	}
+	if dq.DeviceClass != 0xEF || dq.DeviceSubClass != 0x02 || dq.DeviceProtocol != 0x01 {
+		t.Errorf(
+			"class triple = %#02x/%#02x/%#02x, want 0xef/0x02/0x01",
+			byte(dq.DeviceClass), dq.DeviceSubClass, dq.DeviceProtocol,
+		)
+	}
+	if dq.MaxPacketSize0 != 64 {
+		t.Errorf("MaxPacketSize0 = %d, want 64", dq.MaxPacketSize0)
+	}
+	if dq.NumConfigurations != 1 {
+		t.Errorf("NumConfigurations = %d, want 1", dq.NumConfigurations)
+	}
+}
+
+func TestParseDeviceQualifierErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short", []byte{0x0A, 0x06, 0x00, 0x02}},
+		{"wrong type", []byte{0x0A, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x40, 0x01, 0x00}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseDeviceQualifier(tc.data); err == nil {
+				t.Error("parseDeviceQualifier: expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConfigTotalLength(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   []byte
+		expected int
+		wantErr  bool
+	}{
+		{"typical", []byte{0x09, 0x02, 0x20, 0x00, 0x01, 0x01, 0x00, 0x80, 0x32}, 32, false},
+		{"large", []byte{0x09, 0x02, 0x34, 0x12, 0x04, 0x01, 0x00, 0x80, 0xFA}, 0x1234, false},
+		{"short header", []byte{0x09, 0x02, 0x20}, 0, true},
+		{"too small total", []byte{0x09, 0x02, 0x05, 0x00, 0x01, 0x01, 0x00, 0x80, 0x32}, 0, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := configTotalLength(tc.header)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("configTotalLength error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.expected {
+				t.Errorf("configTotalLength = %d, want %d", got, tc.expected)
+			}
+		})
+	}
+}
@@ -8,28 +8,105 @@ package libusb
 // #cgo pkg-config: libusb-1.0
 // #include <libusb.h>
 import "C"
-import "unsafe"
+import (
+	"fmt"
+	"math"
+	"unsafe"
+)
 
-// BulkTransfer implements libusb_bulk_transfer to perform a USB bulk transfer.
-func (dh *DeviceHandle) BulkTransfer(
-	endpoint endpointAddress,
+const (
+	// controlTimeout is the timeout in milliseconds of Control. USB 2.0
+	// section 9.2.6.4 gives a device 5 seconds to complete a request.
+	controlTimeout = 5000
+	// maxControlLength is the largest wLength a setup packet can carry.
+	maxControlLength = math.MaxUint16
+	// maxTransferLength is the largest length libusb_bulk_transfer and
+	// libusb_interrupt_transfer accept, since the length is a C int.
+	maxTransferLength = math.MaxInt32
+)
+
+// transferer performs the synchronous transfers for a DeviceHandle. The
+// DeviceHandle validates every buffer before calling the transferer, so
+// len(data) is exactly the number of bytes that may be read or written.
+type transferer interface {
+	control(
+		requestType, request byte,
+		value, index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	bulk(endpoint EndpointAddress, data []byte, timeout int) (int, error)
+	interrupt(endpoint EndpointAddress, data []byte, timeout int) (int, error)
+	isochronous(
+		endpoint EndpointAddress,
+		data []byte,
+		packetLengths []int,
+		timeout int,
+	) ([]IsoPacket, error)
+}
+
+// libusbTransferer implements transferer using the libusb synchronous device
+// I/O functions. Isochronous transfers have no synchronous function, so they
+// are submitted asynchronously and waited for by handling the context's
+// events.
+type libusbTransferer struct {
+	context *C.libusb_context
+	handle  *C.libusb_device_handle
+}
+
+func (lt libusbTransferer) control(
+	requestType, request byte,
+	value, index uint16,
 	data []byte,
-	length int,
 	timeout int,
 ) (int, error) {
-	if dh == nil || dh.libusbDeviceHandle == nil {
-		return 0, ErrorCode(errorInvalidParam)
+	ret := C.libusb_control_transfer(
+		lt.handle,
+		C.uint8_t(requestType),
+		C.uint8_t(request),
+		C.uint16_t(value),
+		C.uint16_t(index),
+		bufferPointer(data),
+		C.uint16_t(len(data)),
+		C.uint(timeout),
+	)
+	if ret < 0 {
+		return 0, ErrorCode(ret)
 	}
+	return int(ret), nil
+}
+
+func (lt libusbTransferer) bulk(
+	endpoint EndpointAddress,
+	data []byte,
+	timeout int,
+) (int, error) {
 	var transferred C.int
-	var dataPtr *C.uchar
-	if len(data) > 0 {
-		dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
-	}
 	err := C.libusb_bulk_transfer(
-		dh.libusbDeviceHandle,
+		lt.handle,
+		C.uchar(endpoint),
+		bufferPointer(data),
+		C.int(len(data)),
+		&transferred,
+		C.uint(timeout),
+	)
+	if err != 0 {
+		return 0, ErrorCode(err)
+	}
+	return int(transferred), nil
+}
+
+func (lt libusbTransferer) interrupt(
+	endpoint EndpointAddress,
+	data []byte,
+	timeout int,
+) (int, error) {
+	var transferred C.int
+	err := C.libusb_interrupt_transfer(
+		lt.handle,
 		C.uchar(endpoint),
-		dataPtr,
-		C.int(length),
+		bufferPointer(data),
+		C.int(len(data)),
 		&transferred,
 		C.uint(timeout),
 	)
@@ -39,12 +116,148 @@ func (dh *DeviceHandle) BulkTransfer(
 	return int(transferred), nil
 }
 
+// bufferPointer returns a pointer to the first byte of data, or nil for an
+// empty slice.
+func bufferPointer(data []byte) *C.uchar {
+	if len(data) == 0 {
+		return nil
+	}
+	return (*C.uchar)(unsafe.Pointer(&data[0]))
+}
+
+// checkTransferLength verifies that a transfer of length bytes fits within
+// data and doesn't exceed maxLength, so that libusb never reads or writes
+// past the end of the Go slice.
+func checkTransferLength(data []byte, length int, maxLength int) error {
+	if err := checkLengthRange(length, maxLength); err != nil {
+		return err
+	}
+	if length > len(data) {
+		return fmt.Errorf(
+			"transfer length %d exceeds the buffer length %d: %w",
+			length,
+			len(data),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	return nil
+}
+
+// checkLengthRange verifies that a transfer length is between zero and
+// maxLength.
+func checkLengthRange(length int, maxLength int) error {
+	if length < 0 {
+		return fmt.Errorf(
+			"transfer length %d is negative: %w",
+			length,
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	if length > maxLength {
+		return fmt.Errorf(
+			"transfer length %d exceeds the maximum of %d: %w",
+			length,
+			maxLength,
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	return nil
+}
+
+// checkBufferDirection verifies that a buffer suits the direction of its
+// transfer. An IN transfer may receive into a buffer larger than length,
+// but an OUT transfer sends exactly length bytes, so bytes beyond them mean
+// a receive buffer was handed to an OUT transfer.
+func checkBufferDirection(dir EndpointDirection, data []byte, length int) error {
+	if dir == endpointOut && len(data) != length {
+		return fmt.Errorf(
+			"OUT transfer of %d bytes from a %d byte buffer: %w",
+			length,
+			len(data),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	return nil
+}
+
+// requestDirection returns the direction in bit 7 of a bmRequestType.
+func requestDirection(requestType byte) EndpointDirection {
+	return EndpointDirection(requestType >> directionBit)
+}
+
+// checkEndpointDirection verifies that bit 7 of the endpoint address matches
+// the direction of the transfer.
+func checkEndpointDirection(endpoint EndpointAddress, want EndpointDirection) error {
+	if endpoint.direction() != want {
+		return fmt.Errorf(
+			"endpoint %#02x is not an %s endpoint: %w",
+			byte(endpoint),
+			directionName(want),
+			ErrorCode(errorInvalidParam),
+		)
+	}
+	return nil
+}
+
+func directionName(dir EndpointDirection) string {
+	if dir == EndpointIn {
+		return "IN"
+	}
+	return "OUT"
+}
+
+// checkTransferred guards against a backend reporting more bytes than the
+// buffer it was given, which would make data[:n] panic for the caller.
+func checkTransferred(n int, length int) (int, error) {
+	if n < 0 || n > length {
+		return 0, fmt.Errorf(
+			"transferred %d bytes for a %d byte transfer: %w",
+			n,
+			length,
+			ErrorCode(errorOverflow),
+		)
+	}
+	return n, nil
+}
+
+// BulkTransfer implements libusb_bulk_transfer to perform a USB bulk
+// transfer. The length must not exceed len(data), and for an OUT endpoint
+// must equal it.
+func (dh *DeviceHandle) BulkTransfer(
+	endpoint EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if dh == nil || dh.transferer == nil {
+		return 0, ErrorCode(errorInvalidParam)
+	}
+	if err := checkTransferLength(data, length, maxTransferLength); err != nil {
+		return 0, err
+	}
+	if err := checkBufferDirection(endpoint.direction(), data, length); err != nil {
+		return 0, err
+	}
+	n, err := dh.transferer.bulk(endpoint, data[:length], timeout)
+	if err != nil {
+		return 0, err
+	}
+	return checkTransferred(n, length)
+}
+
 // BulkTransferOut is a helper method that performs a USB bulk output transfer.
+// The endpoint must be an OUT endpoint.
 func (dh *DeviceHandle) BulkTransferOut(
-	endpoint endpointAddress,
+	endpoint EndpointAddress,
 	data []byte,
 	timeout int,
 ) (int, error) {
+	if dh == nil || dh.transferer == nil {
+		return 0, ErrorCode(errorInvalidParam)
+	}
+	if err := checkEndpointDirection(endpoint, EndpointOut); err != nil {
+		return 0, err
+	}
 	return dh.BulkTransfer(
 		endpoint,
 		data,
@@ -54,11 +267,21 @@ func (dh *DeviceHandle) BulkTransferOut(
 }
 
 // BulkTransferIn is a helper method that performs a USB bulk input transfer.
+// The endpoint must be an IN endpoint.
 func (dh *DeviceHandle) BulkTransferIn(
-	endpoint endpointAddress,
+	endpoint EndpointAddress,
 	maxReceiveBytes int,
 	timeout int,
 ) ([]byte, int, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, 0, ErrorCode(errorInvalidParam)
+	}
+	if err := checkEndpointDirection(endpoint, EndpointIn); err != nil {
+		return nil, 0, err
+	}
+	if err := checkLengthRange(maxReceiveBytes, maxTransferLength); err != nil {
+		return nil, 0, err
+	}
 	data := make([]byte, maxReceiveBytes)
 	transferred, err := dh.BulkTransfer(
 		endpoint,
@@ -73,7 +296,8 @@ func (dh *DeviceHandle) BulkTransferIn(
 }
 
 // ControlTransfer sends a transfer using a control endpoint for the given
-// device handle.
+// device handle. The length is the wLength of the setup packet and must not
+// exceed len(data); for a host-to-device request it must equal it.
 func (dh *DeviceHandle) ControlTransfer(
 	requestType byte,
 	request byte,
@@ -83,28 +307,27 @@ func (dh *DeviceHandle) ControlTransfer(
 	length int,
 	timeout int,
 ) (int, error) {
-	if dh == nil || dh.libusbDeviceHandle == nil {
+	if dh == nil || dh.transferer == nil {
 		return 0, ErrorCode(errorInvalidParam)
 	}
-
-	var dataPtr *C.uchar
-	if len(data) > 0 {
-		dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+	if err := checkTransferLength(data, length, maxControlLength); err != nil {
+		return 0, err
 	}
-	ret := C.libusb_control_transfer(
-		dh.libusbDeviceHandle,
-		C.uint8_t(requestType),
-		C.uint8_t(request),
-		C.uint16_t(value),
-		C.uint16_t(index),
-		dataPtr,
-		C.uint16_t(length),
-		C.uint(timeout),
+	if err := checkBufferDirection(requestDirection(requestType), data, length); err != nil {
+		return 0, err
+	}
+	n, err := dh.transferer.control(
+		requestType,
+		request,
+		value,
+		index,
+		data[:length],
+		timeout,
 	)
-	if ret < 0 {
-		return 0, ErrorCode(ret)
+	if err != nil {
+		return 0, err
 	}
-	return int(ret), nil
+	return checkTransferred(n, length)
 }
 
 // ControlTransferWithTypes is a more type-safe version of ControlTransfer that accepts
@@ -133,6 +356,32 @@ func (dh *DeviceHandle) ControlTransferWithTypes(
 	)
 }
 
+// Control performs the control transfer described by the SetupPacket. For
+// device-to-host requests up to setup.Length bytes are read into data; for
+// host-to-device requests data holds the setup.Length bytes to send. It
+// waits up to controlTimeout for the device to complete the request.
+func (dh *DeviceHandle) Control(setup SetupPacket, data []byte) (int, error) {
+	return dh.ControlTimeout(setup, data, controlTimeout)
+}
+
+// ControlTimeout is Control with a timeout in milliseconds; zero waits
+// forever.
+func (dh *DeviceHandle) ControlTimeout(
+	setup SetupPacket,
+	data []byte,
+	timeout int,
+) (int, error) {
+	return dh.ControlTransfer(
+		setup.RequestType,
+		setup.Request,
+		setup.Value,
+		setup.Index,
+		data,
+		int(setup.Length),
+		timeout,
+	)
+}
+
 // ControlOut is a helper method for control OUT transfers (host to device)
 func (dh *DeviceHandle) ControlOut(
 	reqType RequestType,
@@ -180,31 +429,26 @@ func (dh *DeviceHandle) ControlIn(
 	)
 }
 
-// InterruptTransfer performs a USB interrupt transfer.
+// InterruptTransfer performs a USB interrupt transfer. The length must not
+// exceed len(data), and for an OUT endpoint must equal it.
 func (dh *DeviceHandle) InterruptTransfer(
-	endpoint endpointAddress,
+	endpoint EndpointAddress,
 	data []byte,
 	length int,
 	timeout int,
 ) (int, error) {
-	if dh == nil || dh.libusbDeviceHandle == nil {
+	if dh == nil || dh.transferer == nil {
 		return 0, ErrorCode(errorInvalidParam)
 	}
-	var transferred C.int
-	var dataPtr *C.uchar
-	if len(data) > 0 {
-		dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+	if err := checkTransferLength(data, length, maxTransferLength); err != nil {
+		return 0, err
 	}
-	err := C.libusb_interrupt_transfer(
-		dh.libusbDeviceHandle,
-		C.uchar(endpoint),
-		dataPtr,
-		C.int(length),
-		&transferred,
-		C.uint(timeout),
-	)
-	if err != 0 {
-		return 0, ErrorCode(err)
+	if err := checkBufferDirection(endpoint.direction(), data, length); err != nil {
+		return 0, err
 	}
-	return int(transferred), nil
+	n, err := dh.transferer.interrupt(endpoint, data[:length], timeout)
+	if err != nil {
+		return 0, err
+	}
+	return checkTransferred(n, length)
 }
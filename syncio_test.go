@@ -6,6 +6,7 @@
 package libusb
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -62,6 +63,19 @@ func TestControlTransferWithTypesNilHandle(t *testing.T) {
 	}
 }
 
+func TestControlNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	setup := NewSetupPacket(DeviceToHost, Standard, DeviceRecipient, 0x06, 0x0100, 0, 18)
+	_, err := dh.Control(setup, make([]byte, 18))
+	if err != ErrorCode(errorInvalidParam) {
+		t.Errorf("Control: got %v, want errorInvalidParam", err)
+	}
+	_, err = dh.ControlTimeout(setup, make([]byte, 18), 1000)
+	if err != ErrorCode(errorInvalidParam) {
+		t.Errorf("ControlTimeout: got %v, want errorInvalidParam", err)
+	}
+}
+
 func TestControlOutNilHandle(t *testing.T) {
 	var dh *DeviceHandle
 	_, err := dh.ControlOut(Standard, DeviceRecipient, 0, 0, 0, nil, 0)
@@ -177,3 +191,430 @@ func TestRequestRecipientString(t *testing.T) {
 		}
 	}
 }
+
+// fakeTransferer is a transferer that simulates a device without libusb. It
+// records every transfer and fills the whole of every IN buffer it's handed,
+// as a device sending a full-length response would.
+type fakeTransferer struct {
+	transfers  []fakeTransfer
+	response   []byte
+	err        error
+	overreport int
+	// controlFunc, if set, answers control transfers instead of response.
+	controlFunc func(setup SetupPacket, data []byte) (int, error)
+}
+
+type fakeTransfer struct {
+	kind     TransferType
+	setup    SetupPacket
+	endpoint EndpointAddress
+	data     []byte
+}
+
+func (ft *fakeTransferer) control(
+	requestType, request byte,
+	value, index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	setup := SetupPacket{requestType, request, value, index, uint16(len(data))}
+	ft.transfers = append(ft.transfers, fakeTransfer{
+		kind:  ControlTransfer,
+		setup: setup,
+		data:  append([]byte(nil), data...),
+	})
+	if ft.controlFunc != nil {
+		return ft.controlFunc(setup, data)
+	}
+	if setup.Direction() == DeviceToHost {
+		return ft.read(data)
+	}
+	return ft.write(data)
+}
+
+func (ft *fakeTransferer) bulk(
+	endpoint EndpointAddress,
+	data []byte,
+	timeout int,
+) (int, error) {
+	return ft.endpointTransfer(BulkTransfer, endpoint, data)
+}
+
+func (ft *fakeTransferer) interrupt(
+	endpoint EndpointAddress,
+	data []byte,
+	timeout int,
+) (int, error) {
+	return ft.endpointTransfer(InterruptTransfer, endpoint, data)
+}
+
+// isochronous answers each IN packet with the start of response and
+// completes each OUT packet in full.
+func (ft *fakeTransferer) isochronous(
+	endpoint EndpointAddress,
+	data []byte,
+	packetLengths []int,
+	timeout int,
+) ([]IsoPacket, error) {
+	ft.transfers = append(ft.transfers, fakeTransfer{
+		kind:     IsochronousTransfer,
+		endpoint: endpoint,
+		data:     append([]byte(nil), data...),
+	})
+	if ft.err != nil {
+		return nil, ft.err
+	}
+	packets := make([]IsoPacket, len(packetLengths))
+	offset := 0
+	for i, length := range packetLengths {
+		packets[i] = IsoPacket{Offset: offset, Length: length, ActualLength: length}
+		if endpoint.direction() == EndpointIn {
+			packets[i].ActualLength = copy(data[offset:offset+length], ft.response)
+		}
+		packets[i].ActualLength += ft.overreport
+		offset += length
+	}
+	return packets, nil
+}
+
+func (ft *fakeTransferer) endpointTransfer(
+	kind TransferType,
+	endpoint EndpointAddress,
+	data []byte,
+) (int, error) {
+	ft.transfers = append(ft.transfers, fakeTransfer{
+		kind:     kind,
+		endpoint: endpoint,
+		data:     append([]byte(nil), data...),
+	})
+	if endpoint.direction() == EndpointIn {
+		return ft.read(data)
+	}
+	return ft.write(data)
+}
+
+func (ft *fakeTransferer) read(data []byte) (int, error) {
+	if ft.err != nil {
+		return 0, ft.err
+	}
+	for i := range data {
+		data[i] = 0xA5
+	}
+	n := copy(data, ft.response)
+	return n + ft.overreport, nil
+}
+
+func (ft *fakeTransferer) write(data []byte) (int, error) {
+	if ft.err != nil {
+		return 0, ft.err
+	}
+	return len(data) + ft.overreport, nil
+}
+
+func newFakeDeviceHandle(ft *fakeTransferer) *DeviceHandle {
+	return &DeviceHandle{transferer: ft}
+}
+
+func TestTransferLengthValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		call    func(dh *DeviceHandle) (int, error)
+		wantErr bool
+	}{
+		{
+			"control length exceeds buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlTransfer(0x80, 0x06, 0x0100, 0, make([]byte, 8), 18, 0)
+			},
+			true,
+		},
+		{
+			"control negative length",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlTransfer(0x00, 0x09, 1, 0, nil, -1, 0)
+			},
+			true,
+		},
+		{
+			"control length exceeds wLength",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlTransfer(0xC0, 0x01, 0, 0, make([]byte, 70000), 70000, 0)
+			},
+			true,
+		},
+		{
+			"control in with nil buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlIn(Vendor, DeviceRecipient, 0x01, 0, 0, nil, 4, 0)
+			},
+			true,
+		},
+		{
+			"control setup packet wLength exceeds buffer",
+			func(dh *DeviceHandle) (int, error) {
+				setup := NewSetupPacket(HostToDevice, Vendor, DeviceRecipient, 1, 0, 0, 8)
+				return dh.Control(setup, make([]byte, 4))
+			},
+			true,
+		},
+		{
+			"control zero length",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlOut(Standard, DeviceRecipient, 0x09, 1, 0, nil, 0)
+			},
+			false,
+		},
+		{
+			"bulk length exceeds buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.BulkTransfer(0x81, make([]byte, 64), 512, 0)
+			},
+			true,
+		},
+		{
+			"bulk negative length",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.BulkTransfer(0x02, make([]byte, 64), -64, 0)
+			},
+			true,
+		},
+		{
+			"bulk in shorter than buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.BulkTransfer(0x82, make([]byte, 64), 32, 0)
+			},
+			false,
+		},
+		{
+			"bulk out shorter than buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.BulkTransfer(0x02, make([]byte, 64), 32, 0)
+			},
+			true,
+		},
+		{
+			"interrupt out shorter than buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.InterruptTransfer(0x03, make([]byte, 8), 4, 0)
+			},
+			true,
+		},
+		{
+			"control out shorter than buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlTransfer(0x40, 0x01, 0, 0, make([]byte, 8), 4, 0)
+			},
+			true,
+		},
+		{
+			"control in shorter than buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.ControlTransfer(0xC0, 0x01, 0, 0, make([]byte, 8), 4, 0)
+			},
+			false,
+		},
+		{
+			"bulk out to in endpoint",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.BulkTransferOut(0x81, make([]byte, 64), 0)
+			},
+			true,
+		},
+		{
+			"bulk in from out endpoint",
+			func(dh *DeviceHandle) (int, error) {
+				_, n, err := dh.BulkTransferIn(0x02, 64, 0)
+				return n, err
+			},
+			true,
+		},
+		{
+			"bulk in negative length",
+			func(dh *DeviceHandle) (int, error) {
+				_, n, err := dh.BulkTransferIn(0x81, -1, 0)
+				return n, err
+			},
+			true,
+		},
+		{
+			"interrupt length exceeds buffer",
+			func(dh *DeviceHandle) (int, error) {
+				return dh.InterruptTransfer(0x83, make([]byte, 8), 9, 0)
+			},
+			true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := &fakeTransferer{}
+			_, err := tc.call(newFakeDeviceHandle(ft))
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if len(ft.transfers) != 1 {
+					t.Errorf("got %d transfers, want 1", len(ft.transfers))
+				}
+				return
+			}
+			if !errors.Is(err, ErrorCode(errorInvalidParam)) {
+				t.Errorf("got %v, want errorInvalidParam", err)
+			}
+			if len(ft.transfers) != 0 {
+				t.Errorf("invalid transfer reached the backend: %+v", ft.transfers)
+			}
+		})
+	}
+}
+
+func TestTransferTrimsBufferToLength(t *testing.T) {
+	ft := &fakeTransferer{response: []byte{1, 2, 3, 4, 5, 6, 7, 8}}
+	dh := newFakeDeviceHandle(ft)
+	data := make([]byte, 16)
+	n, err := dh.ControlIn(Vendor, DeviceRecipient, 0x01, 0, 0, data, 4, 0)
+	if err != nil {
+		t.Fatalf("ControlIn: unexpected error %v", err)
+	}
+	if n != 4 {
+		t.Errorf("ControlIn transferred %d bytes, want 4", n)
+	}
+	if got := ft.transfers[0].setup.Length; got != 4 {
+		t.Errorf("wLength = %d, want 4", got)
+	}
+	for i, b := range data[4:] {
+		if b != 0 {
+			t.Fatalf("byte %d beyond wLength was overwritten with %#02x", i+4, b)
+		}
+	}
+}
+
+func TestTransferOverreportedLength(t *testing.T) {
+	ft := &fakeTransferer{response: make([]byte, 8), overreport: 1}
+	dh := newFakeDeviceHandle(ft)
+	if _, err := dh.BulkTransfer(0x81, make([]byte, 8), 8, 0); !errors.Is(
+		err, ErrorCode(errorOverflow),
+	) {
+		t.Errorf("BulkTransfer: got %v, want errorOverflow", err)
+	}
+	if _, err := dh.ControlTransfer(0x40, 1, 0, 0, make([]byte, 2), 2, 0); !errors.Is(
+		err, ErrorCode(errorOverflow),
+	) {
+		t.Errorf("ControlTransfer: got %v, want errorOverflow", err)
+	}
+}
+
+func TestTransferBackendError(t *testing.T) {
+	ft := &fakeTransferer{err: ErrorCode(errorPipe)}
+	dh := newFakeDeviceHandle(ft)
+	if _, err := dh.InterruptTransfer(0x81, make([]byte, 8), 8, 0); err != ErrorCode(errorPipe) {
+		t.Errorf("InterruptTransfer: got %v, want errorPipe", err)
+	}
+}
+
+// checkFuzzedTransfer verifies the invariants every transfer entry point must
+// uphold: invalid lengths, and OUT transfers that don't send their whole
+// buffer, never reach the backend, and valid ones hand the backend exactly
+// length bytes and report no more than that.
+func checkFuzzedTransfer(
+	t *testing.T,
+	ft *fakeTransferer,
+	dir EndpointDirection,
+	bufLen int,
+	length int,
+	maxLength int,
+	n int,
+	err error,
+) {
+	t.Helper()
+	valid := length >= 0 && length <= bufLen && length <= maxLength &&
+		(dir == endpointIn || length == bufLen)
+	if !valid {
+		if !errors.Is(err, ErrorCode(errorInvalidParam)) {
+			t.Fatalf("length %d, buffer %d: got %v, want errorInvalidParam", length, bufLen, err)
+		}
+		if len(ft.transfers) != 0 {
+			t.Fatalf("length %d, buffer %d: invalid transfer reached the backend", length, bufLen)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("length %d, buffer %d: unexpected error %v", length, bufLen, err)
+	}
+	if len(ft.transfers) != 1 {
+		t.Fatalf("got %d transfers, want 1", len(ft.transfers))
+	}
+	if got := len(ft.transfers[0].data); got != length {
+		t.Fatalf("backend received %d bytes, want %d", got, length)
+	}
+	if n < 0 || n > length {
+		t.Fatalf("transferred %d bytes for a %d byte transfer", n, length)
+	}
+}
+
+func FuzzControlTransfer(f *testing.F) {
+	f.Add(byte(0x80), uint16(18), 18, []byte{0x12, 0x01})
+	f.Add(byte(0x80), uint16(0), 255, []byte{})
+	f.Add(byte(0x40), uint16(8), 4, []byte{})
+	f.Add(byte(0xC1), uint16(0xFFFF), 0x10000, []byte{1, 2, 3})
+	f.Add(byte(0x00), uint16(1), -1, []byte{})
+	f.Add(byte(0x21), uint16(2), 2, []byte{})
+	f.Add(byte(0xA1), uint16(2), 64, []byte{1})
+	f.Fuzz(func(t *testing.T, requestType byte, wLength uint16, bufLen int, response []byte) {
+		if bufLen > 1<<17 {
+			bufLen = 1 << 17
+		}
+		data := make([]byte, max(bufLen, 0))
+		length := int(wLength)
+		ft := &fakeTransferer{response: response}
+		n, err := newFakeDeviceHandle(ft).ControlTransfer(
+			requestType, 0x01, 0, 0, data, length, 0,
+		)
+		dir := requestDirection(requestType)
+		checkFuzzedTransfer(t, ft, dir, len(data), length, maxControlLength, n, err)
+
+		ft = &fakeTransferer{response: response}
+		setup := SetupPacket{RequestType: requestType, Length: wLength}
+		n, err = newFakeDeviceHandle(ft).ControlTimeout(setup, data, 0)
+		checkFuzzedTransfer(t, ft, dir, len(data), length, maxControlLength, n, err)
+	})
+}
+
+func FuzzBulkTransfer(f *testing.F) {
+	f.Add(byte(0x81), 512, 512, []byte{1, 2, 3})
+	f.Add(byte(0x02), 64, 65, []byte{})
+	f.Add(byte(0x81), 0, 0, []byte{})
+	f.Add(byte(0x02), 8, -8, []byte{})
+	f.Add(byte(0x02), 64, 32, []byte{})
+	f.Add(byte(0x81), 64, 32, []byte{1, 2})
+	f.Fuzz(func(t *testing.T, endpoint byte, bufLen int, length int, response []byte) {
+		if bufLen > 1<<17 {
+			bufLen = 1 << 17
+		}
+		data := make([]byte, max(bufLen, 0))
+		ft := &fakeTransferer{response: response}
+		address := EndpointAddress(endpoint)
+		n, err := newFakeDeviceHandle(ft).BulkTransfer(address, data, length, 0)
+		checkFuzzedTransfer(
+			t, ft, address.direction(), len(data), length, maxTransferLength, n, err,
+		)
+	})
+}
+
+func FuzzInterruptTransfer(f *testing.F) {
+	f.Add(byte(0x83), 8, 8, []byte{0xFF})
+	f.Add(byte(0x03), 8, 16, []byte{})
+	f.Add(byte(0x83), 0, -1, []byte{})
+	f.Add(byte(0x03), 16, 8, []byte{})
+	f.Fuzz(func(t *testing.T, endpoint byte, bufLen int, length int, response []byte) {
+		if bufLen > 1<<17 {
+			bufLen = 1 << 17
+		}
+		data := make([]byte, max(bufLen, 0))
+		ft := &fakeTransferer{response: response}
+		address := EndpointAddress(endpoint)
+		n, err := newFakeDeviceHandle(ft).InterruptTransfer(address, data, length, 0)
+		checkFuzzedTransfer(
+			t, ft, address.direction(), len(data), length, maxTransferLength, n, err,
+		)
+	})
+}
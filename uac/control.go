@@ -0,0 +1,482 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// UAC1 requests. GET requests have bit 7 set.
+const (
+	requestSetCur = 0x01
+	requestGetCur = 0x81
+	requestGetMin = 0x82
+	requestGetMax = 0x83
+	requestGetRes = 0x84
+)
+
+// UAC2 requests, which are sent in both directions.
+const (
+	requestCur   = 0x01
+	requestRange = 0x02
+)
+
+// Control selectors.
+const (
+	selectorMute         = 0x01
+	selectorVolume       = 0x02
+	selectorSamplingFreq = 0x01
+	selectorClockSelect  = 0x01
+)
+
+// maxClockDepth bounds how many clock selectors are followed to find the
+// clock source of a terminal.
+const maxClockDepth = 8
+
+// Volume is a volume setting in steps of 1/256 dB, as both versions of the
+// class encode it.
+type Volume int16
+
+// VolumeSilence is the volume setting that silences a channel.
+const VolumeSilence Volume = math.MinInt16
+
+// DB returns the volume in decibels.
+func (v Volume) DB() float64 {
+	if v == VolumeSilence {
+		return math.Inf(-1)
+	}
+	return float64(v) / 256
+}
+
+// VolumeFromDB returns the volume setting closest to db.
+func VolumeFromDB(db float64) Volume {
+	steps := math.Round(db * 256)
+	if steps <= math.MinInt16 {
+		return VolumeSilence
+	}
+	return Volume(min(steps, math.MaxInt16))
+}
+
+// String implements the Stringer interface for Volume.
+func (v Volume) String() string {
+	if v == VolumeSilence {
+		return "silence"
+	}
+	return fmt.Sprintf("%.2f dB", v.DB())
+}
+
+// Range is a subrange of a control's values, such as the sample rates a
+// clock source supports. A single value has Min equal to Max.
+type Range struct {
+	Min uint32
+	Max uint32
+	Res uint32
+}
+
+// checkFeatureUnit checks that the device is open and has a feature unit
+// with the given ID and the control on the channel.
+func (dev *Device) checkFeatureUnit(id uint8, control int, channel uint8) error {
+	if err := dev.checkOpen(); err != nil {
+		return err
+	}
+	for _, unit := range dev.Function.Topology.FeatureUnits {
+		if unit.ID != id {
+			continue
+		}
+		if !unit.hasControl(dev.Function.Version, control, int(channel)) {
+			name := "mute"
+			if control == controlVolume {
+				name = "volume"
+			}
+			return fmt.Errorf(
+				"uac: feature unit %d has no %s control on channel %d",
+				id,
+				name,
+				channel,
+			)
+		}
+		return nil
+	}
+	return fmt.Errorf("uac: no feature unit %d", id)
+}
+
+func (dev *Device) checkOpen() error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+// entityIndex returns the wIndex of a request to an entity of the
+// AudioControl interface.
+func (dev *Device) entityIndex(id uint8) uint16 {
+	return uint16(id)<<8 | uint16(dev.Function.Control.InterfaceNumber)
+}
+
+// get sends a GET request and returns its reply, which must be length
+// bytes.
+func (dev *Device) get(
+	recipient libusb.RequestRecipient,
+	request byte,
+	value, index uint16,
+	length int,
+) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := dev.handle.ControlIn(
+		libusb.Class,
+		recipient,
+		request,
+		value,
+		index,
+		data,
+		length,
+		dev.Timeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n < length {
+		return nil, fmt.Errorf("uac: request %#02x returned %d of %d bytes", request, n, length)
+	}
+	return data, nil
+}
+
+func (dev *Device) set(
+	recipient libusb.RequestRecipient,
+	value, index uint16,
+	data []byte,
+) error {
+	_, err := dev.handle.ControlOut(
+		libusb.Class,
+		recipient,
+		requestSetCur,
+		value,
+		index,
+		data,
+		dev.Timeout,
+	)
+	return err
+}
+
+// getCur returns the current value of a control; UAC1 GET_CUR and UAC2
+// CUR differ only in the request code.
+func (dev *Device) getCur(
+	recipient libusb.RequestRecipient,
+	value, index uint16,
+	length int,
+) ([]byte, error) {
+	request := byte(requestGetCur)
+	if dev.Function.Version == UAC2 {
+		request = requestCur
+	}
+	return dev.get(recipient, request, value, index, length)
+}
+
+// Volume returns the volume of a channel of a feature unit, where channel
+// 0 is the master channel.
+func (dev *Device) Volume(unit, channel uint8) (Volume, error) {
+	if err := dev.checkFeatureUnit(unit, controlVolume, channel); err != nil {
+		return 0, err
+	}
+	data, err := dev.getCur(
+		libusb.InterfaceRecipient,
+		selectorVolume<<8|uint16(channel),
+		dev.entityIndex(unit),
+		2,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("uac: getting volume of unit %d: %w", unit, err)
+	}
+	return Volume(binary.LittleEndian.Uint16(data)), nil
+}
+
+// SetVolume sets the volume of a channel of a feature unit.
+func (dev *Device) SetVolume(unit, channel uint8, volume Volume) error {
+	if err := dev.checkFeatureUnit(unit, controlVolume, channel); err != nil {
+		return err
+	}
+	data := binary.LittleEndian.AppendUint16(nil, uint16(volume))
+	if err := dev.set(
+		libusb.InterfaceRecipient,
+		selectorVolume<<8|uint16(channel),
+		dev.entityIndex(unit),
+		data,
+	); err != nil {
+		return fmt.Errorf("uac: setting volume of unit %d: %w", unit, err)
+	}
+	return nil
+}
+
+// VolumeRange returns the minimum, maximum, and resolution of the volume
+// of a channel of a feature unit. A UAC2 unit with several subranges
+// reports the bounds of all of them and the resolution of the first.
+func (dev *Device) VolumeRange(unit, channel uint8) (lo, hi, res Volume, err error) {
+	if err := dev.checkFeatureUnit(unit, controlVolume, channel); err != nil {
+		return 0, 0, 0, err
+	}
+	value := uint16(selectorVolume<<8) | uint16(channel)
+	index := dev.entityIndex(unit)
+	if dev.Function.Version == UAC2 {
+		ranges, err := dev.ranges(value, index, 2)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("uac: getting volume range of unit %d: %w", unit, err)
+		}
+		lo, hi, res = Volume(ranges[0].Min), Volume(ranges[0].Max), Volume(ranges[0].Res)
+		for _, r := range ranges[1:] {
+			lo, hi = min(lo, Volume(r.Min)), max(hi, Volume(r.Max))
+		}
+		return lo, hi, res, nil
+	}
+	var bounds [3]Volume
+	for i, request := range []byte{requestGetMin, requestGetMax, requestGetRes} {
+		data, err := dev.get(libusb.InterfaceRecipient, request, value, index, 2)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("uac: getting volume range of unit %d: %w", unit, err)
+		}
+		bounds[i] = Volume(binary.LittleEndian.Uint16(data))
+	}
+	return bounds[0], bounds[1], bounds[2], nil
+}
+
+// Mute reports whether a channel of a feature unit is muted.
+func (dev *Device) Mute(unit, channel uint8) (bool, error) {
+	if err := dev.checkFeatureUnit(unit, controlMute, channel); err != nil {
+		return false, err
+	}
+	data, err := dev.getCur(
+		libusb.InterfaceRecipient,
+		selectorMute<<8|uint16(channel),
+		dev.entityIndex(unit),
+		1,
+	)
+	if err != nil {
+		return false, fmt.Errorf("uac: getting mute of unit %d: %w", unit, err)
+	}
+	return data[0] != 0, nil
+}
+
+// SetMute mutes or unmutes a channel of a feature unit.
+func (dev *Device) SetMute(unit, channel uint8, mute bool) error {
+	if err := dev.checkFeatureUnit(unit, controlMute, channel); err != nil {
+		return err
+	}
+	data := []byte{0}
+	if mute {
+		data[0] = 1
+	}
+	if err := dev.set(
+		libusb.InterfaceRecipient,
+		selectorMute<<8|uint16(channel),
+		dev.entityIndex(unit),
+		data,
+	); err != nil {
+		return fmt.Errorf("uac: setting mute of unit %d: %w", unit, err)
+	}
+	return nil
+}
+
+// ranges reads a UAC2 RANGE attribute of fieldSize-byte subranges: the
+// wNumSubRanges count first, then the subranges.
+func (dev *Device) ranges(value, index uint16, fieldSize int) ([]Range, error) {
+	header, err := dev.get(libusb.InterfaceRecipient, requestRange, value, index, 2)
+	if err != nil {
+		return nil, err
+	}
+	count := int(binary.LittleEndian.Uint16(header))
+	if count == 0 {
+		return nil, fmt.Errorf("uac: RANGE has no subranges")
+	}
+	data, err := dev.get(
+		libusb.InterfaceRecipient,
+		requestRange,
+		value,
+		index,
+		2+3*fieldSize*count,
+	)
+	if err != nil {
+		return nil, err
+	}
+	field := func(offset int) uint32 {
+		if fieldSize == 2 {
+			return uint32(int32(int16(binary.LittleEndian.Uint16(data[offset:]))))
+		}
+		return binary.LittleEndian.Uint32(data[offset:])
+	}
+	ranges := make([]Range, count)
+	for i := range ranges {
+		offset := 2 + 3*fieldSize*i
+		ranges[i] = Range{
+			Min: field(offset),
+			Max: field(offset + fieldSize),
+			Res: field(offset + 2*fieldSize),
+		}
+	}
+	return ranges, nil
+}
+
+// clockSource returns the clock source that drives the terminal an
+// AudioStreaming alternate setting links to, following clock selectors
+// through their current input.
+func (dev *Device) clockSource(alt *AltSetting) (uint8, error) {
+	topo := dev.Function.Topology
+	var id uint8
+	found := false
+	for _, terminals := range [][]Terminal{topo.InputTerminals, topo.OutputTerminals} {
+		for _, terminal := range terminals {
+			if terminal.ID == alt.TerminalLink {
+				id, found = terminal.ClockSourceID, true
+			}
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("uac: no terminal %d", alt.TerminalLink)
+	}
+	for depth := 0; depth < maxClockDepth; depth++ {
+		for _, source := range topo.ClockSources {
+			if source.ID == id {
+				return id, nil
+			}
+		}
+		var selector *ClockSelector
+		for i := range topo.ClockSelectors {
+			if topo.ClockSelectors[i].ID == id {
+				selector = &topo.ClockSelectors[i]
+			}
+		}
+		if selector == nil {
+			return 0, fmt.Errorf("uac: clock entity %d is not a clock source or selector", id)
+		}
+		data, err := dev.getCur(
+			libusb.InterfaceRecipient,
+			selectorClockSelect<<8,
+			dev.entityIndex(id),
+			1,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("uac: getting input of clock selector %d: %w", id, err)
+		}
+		pin := int(data[0])
+		if pin < 1 || pin > len(selector.SourceIDs) {
+			return 0, fmt.Errorf("uac: clock selector %d selects input %d", id, pin)
+		}
+		id = selector.SourceIDs[pin-1]
+	}
+	return 0, fmt.Errorf("uac: clock selectors nested deeper than %d", maxClockDepth)
+}
+
+// SampleRate returns the current sample rate of an AudioStreaming
+// alternate setting: that of its endpoint for UAC1, of its clock source
+// for UAC2.
+func (dev *Device) SampleRate(alt *AltSetting) (uint32, error) {
+	if _, err := dev.checkAltSetting(alt); err != nil {
+		return 0, err
+	}
+	if dev.Function.Version == UAC2 {
+		clock, err := dev.clockSource(alt)
+		if err != nil {
+			return 0, err
+		}
+		data, err := dev.getCur(
+			libusb.InterfaceRecipient,
+			selectorSamplingFreq<<8,
+			dev.entityIndex(clock),
+			4,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("uac: getting sample rate of clock %d: %w", clock, err)
+		}
+		return binary.LittleEndian.Uint32(data), nil
+	}
+	data, err := dev.getCur(
+		libusb.EndpointRecipient,
+		selectorSamplingFreq<<8,
+		uint16(alt.Endpoint.EndpointAddress),
+		3,
+	)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"uac: getting sample rate of endpoint %#02x: %w",
+			uint8(alt.Endpoint.EndpointAddress),
+			err,
+		)
+	}
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16, nil
+}
+
+// SetSampleRate sets the sample rate of an AudioStreaming alternate
+// setting. UAC1 endpoints take the rate while their alternate setting is
+// selected, so StartPlayback and StartCapture set it after selecting one.
+func (dev *Device) SetSampleRate(alt *AltSetting, rate uint32) error {
+	if _, err := dev.checkAltSetting(alt); err != nil {
+		return err
+	}
+	if !alt.SupportsRate(rate) {
+		return fmt.Errorf("uac: %v does not support %d Hz", alt, rate)
+	}
+	if dev.Function.Version == UAC2 {
+		clock, err := dev.clockSource(alt)
+		if err != nil {
+			return err
+		}
+		if err := dev.set(
+			libusb.InterfaceRecipient,
+			selectorSamplingFreq<<8,
+			dev.entityIndex(clock),
+			binary.LittleEndian.AppendUint32(nil, rate),
+		); err != nil {
+			return fmt.Errorf("uac: setting sample rate of clock %d: %w", clock, err)
+		}
+		return nil
+	}
+	if err := dev.set(
+		libusb.EndpointRecipient,
+		selectorSamplingFreq<<8,
+		uint16(alt.Endpoint.EndpointAddress),
+		[]byte{byte(rate), byte(rate >> 8), byte(rate >> 16)},
+	); err != nil {
+		return fmt.Errorf(
+			"uac: setting sample rate of endpoint %#02x: %w",
+			uint8(alt.Endpoint.EndpointAddress),
+			err,
+		)
+	}
+	return nil
+}
+
+// SampleRates returns the sample rates an AudioStreaming alternate setting
+// can run at: those its format lists for UAC1, and those its clock
+// source's RANGE reports for UAC2.
+func (dev *Device) SampleRates(alt *AltSetting) ([]Range, error) {
+	if _, err := dev.checkAltSetting(alt); err != nil {
+		return nil, err
+	}
+	if dev.Function.Version == UAC2 {
+		clock, err := dev.clockSource(alt)
+		if err != nil {
+			return nil, err
+		}
+		ranges, err := dev.ranges(selectorSamplingFreq<<8, dev.entityIndex(clock), 4)
+		if err != nil {
+			return nil, fmt.Errorf("uac: getting sample rates of clock %d: %w", clock, err)
+		}
+		return ranges, nil
+	}
+	if len(alt.SampleRates) == 0 {
+		return []Range{{Min: alt.MinRate, Max: alt.MaxRate, Res: 1}}, nil
+	}
+	ranges := make([]Range, len(alt.SampleRates))
+	for i, rate := range alt.SampleRates {
+		ranges[i] = Range{Min: rate, Max: rate}
+	}
+	return ranges, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVolumeDB(t *testing.T) {
+	testCases := []struct {
+		db     float64
+		volume Volume
+		str    string
+	}{
+		{0, 0, "0.00 dB"},
+		{-6.5, -0x0680, "-6.50 dB"},
+		{127.99609375, 0x7FFF, "128.00 dB"},
+		{-200, VolumeSilence, "silence"},
+	}
+	for _, tc := range testCases {
+		if got := VolumeFromDB(tc.db); got != tc.volume {
+			t.Errorf("VolumeFromDB(%v) = %#04x, want %#04x", tc.db, got, tc.volume)
+		}
+		if got := tc.volume.String(); got != tc.str {
+			t.Errorf("Volume(%d).String() = %q, want %q", tc.volume, got, tc.str)
+		}
+	}
+}
+
+func TestUAC1Controls(t *testing.T) {
+	fh := &fakeHandle{controls: map[controlKey][]byte{
+		{requestGetMin, 0x0200, 0x0200}: {0x00, 0xC0},
+		{requestGetMax, 0x0200, 0x0200}: {0x00, 0x00},
+		{requestGetRes, 0x0200, 0x0200}: {0x80, 0x00},
+	}}
+	dev := openTestDevice(t, fh, UAC1)
+	defer dev.Close()
+
+	if err := dev.SetVolume(2, 0, -0x0C00); err != nil {
+		t.Fatalf("SetVolume: unexpected error %v", err)
+	}
+	if v, err := dev.Volume(2, 0); err != nil || v != -0x0C00 {
+		t.Errorf("Volume = %v, %v; want -12 dB", v, err)
+	}
+	lo, hi, res, err := dev.VolumeRange(2, 0)
+	if err != nil || lo != -0x4000 || hi != 0 || res != 0x80 {
+		t.Errorf("VolumeRange = %v %v %v, %v", lo, hi, res, err)
+	}
+	if err := dev.SetMute(2, 0, true); err != nil {
+		t.Fatalf("SetMute: unexpected error %v", err)
+	}
+	if mute, err := dev.Mute(2, 0); err != nil || !mute {
+		t.Errorf("Mute = %v, %v; want true", mute, err)
+	}
+	if _, err := dev.Mute(2, 1); err == nil {
+		t.Error("Mute on a channel without it: expected error, got nil")
+	}
+	if _, err := dev.Volume(7, 0); err == nil {
+		t.Error("Volume of a missing unit: expected error, got nil")
+	}
+
+	alt := dev.Function.Streams[0].AltSettings[0]
+	if err := dev.SetSampleRate(alt, 44100); err != nil {
+		t.Fatalf("SetSampleRate: unexpected error %v", err)
+	}
+	if rate, err := dev.SampleRate(alt); err != nil || rate != 44100 {
+		t.Errorf("SampleRate = %d, %v; want 44100", rate, err)
+	}
+	if err := dev.SetSampleRate(alt, 32000); err == nil {
+		t.Error("SetSampleRate of an unlisted rate: expected error, got nil")
+	}
+	ranges, err := dev.SampleRates(alt)
+	if err != nil || !slices.Equal(ranges, []Range{{44100, 44100, 0}, {48000, 48000, 0}}) {
+		t.Errorf("SampleRates = %v, %v", ranges, err)
+	}
+
+	want := []string{
+		"set 0x0200 0x0200 00 f4",
+		"set 0x0100 0x0200 01",
+		"set 0x0100 0x0001 44 ac 00",
+	}
+	if got := fh.Calls()[3:]; !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestUAC2Controls(t *testing.T) {
+	fh := &fakeHandle{controls: map[controlKey][]byte{
+		// Clock selector 10 selects its second input, clock source 9.
+		{requestCur, 0x0100, 0x0A00}: {2},
+		{requestRange, 0x0100, 0x0900}: {
+			2, 0,
+			0x44, 0xAC, 0, 0, 0x44, 0xAC, 0, 0, 0, 0, 0, 0,
+			0x80, 0xBB, 0, 0, 0x00, 0xEE, 2, 0, 0x80, 0xBB, 0, 0,
+		},
+		{requestRange, 0x0201, 0x0200}: {
+			1, 0,
+			0x00, 0x80, 0x00, 0x00, 0x00, 0x01,
+		},
+	}}
+	dev := openTestDevice(t, fh, UAC2)
+	defer dev.Close()
+
+	if err := dev.SetVolume(2, 1, -0x0100); err != nil {
+		t.Fatalf("SetVolume: unexpected error %v", err)
+	}
+	if v, err := dev.Volume(2, 1); err != nil || v != -0x0100 {
+		t.Errorf("Volume = %v, %v; want -1 dB", v, err)
+	}
+	lo, hi, res, err := dev.VolumeRange(2, 1)
+	if err != nil || lo != -0x8000 || hi != 0 || res != 0x100 {
+		t.Errorf("VolumeRange = %v %v %v, %v", lo, hi, res, err)
+	}
+	if _, err := dev.Mute(2, 1); err == nil {
+		t.Error("Mute on a channel without it: expected error, got nil")
+	}
+
+	alt := dev.Function.Streams[0].AltSettings[0]
+	if err := dev.SetSampleRate(alt, 96000); err != nil {
+		t.Fatalf("SetSampleRate: unexpected error %v", err)
+	}
+	if rate, err := dev.SampleRate(alt); err != nil || rate != 96000 {
+		t.Errorf("SampleRate = %d, %v; want 96000", rate, err)
+	}
+	ranges, err := dev.SampleRates(alt)
+	want := []Range{{44100, 44100, 0}, {48000, 192000, 48000}}
+	if err != nil || !slices.Equal(ranges, want) {
+		t.Errorf("SampleRates = %v, %v; want %v", ranges, err, want)
+	}
+	if got := fh.Calls()[3]; got != "set 0x0100 0x0900 00 77 01 00" {
+		t.Errorf("SetSampleRate sent %q", got)
+	}
+
+	fh.controls[controlKey{requestCur, 0x0100, 0x0A00}] = []byte{3}
+	if _, err := dev.SampleRate(alt); err == nil {
+		t.Error("clock selector on a missing input: expected error, got nil")
+	}
+}
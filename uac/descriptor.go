@@ -0,0 +1,496 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// descriptorTypeCSInterface is the type of class-specific interface
+// descriptors.
+const descriptorTypeCSInterface = 0x24
+
+// AudioControl interface descriptor subtypes.
+const (
+	acHeader          = 0x01
+	acInputTerminal   = 0x02
+	acOutputTerminal  = 0x03
+	acFeatureUnit     = 0x06
+	acClockSource     = 0x0A
+	acClockSelector   = 0x0B
+	acClockMultiplier = 0x0C
+)
+
+// AudioStreaming interface descriptor subtypes.
+const (
+	asGeneral    = 0x01
+	asFormatType = 0x02
+)
+
+// FormatTypeI is the bFormatType of PCM-like formats, the only type this
+// package streams.
+const FormatTypeI = 0x01
+
+// Version is the version of the Audio Device Class specification a
+// function implements, told apart by its interface protocol.
+type Version uint8
+
+// Audio class versions.
+const (
+	UAC1 Version = 0x00
+	UAC2 Version = 0x20
+)
+
+var versions = map[Version]string{
+	UAC1: "UAC1",
+	UAC2: "UAC2",
+}
+
+// String implements the Stringer interface for Version.
+func (v Version) String() string {
+	if s, ok := versions[v]; ok {
+		return s
+	}
+	return fmt.Sprintf("audio protocol %#02x", uint8(v))
+}
+
+// Common terminal types. Audio passes to and from the host through USB
+// streaming terminals.
+const (
+	TerminalUSBStreaming = 0x0101
+	TerminalMicrophone   = 0x0201
+	TerminalSpeaker      = 0x0301
+)
+
+// Topology models the class-specific descriptors of an AudioControl
+// interface: the terminals, feature units, and, for UAC2, the clock
+// entities of the function.
+type Topology struct {
+	// ADCVersion is the bcdADC of the header, 0x0100 or 0x0200.
+	ADCVersion uint16
+	// StreamingInterfaces are the numbers of the AudioStreaming interfaces
+	// a UAC1 header lists. UAC2 groups them with an interface association
+	// instead.
+	StreamingInterfaces []int
+	InputTerminals      []Terminal
+	OutputTerminals     []Terminal
+	FeatureUnits        []FeatureUnit
+	ClockSources        []ClockSource
+	ClockSelectors      []ClockSelector
+}
+
+// Terminal is an input or output terminal.
+type Terminal struct {
+	ID           uint8
+	TerminalType uint16
+	// SourceID is the entity an output terminal is fed by.
+	SourceID uint8
+	// ClockSourceID is the clock entity of a UAC2 terminal.
+	ClockSourceID uint8
+	// Channels is the number of logical channels of an input terminal.
+	Channels uint8
+}
+
+// FeatureUnit is a feature unit, which holds the volume and mute controls.
+type FeatureUnit struct {
+	ID       uint8
+	SourceID uint8
+	// Controls holds the bmaControls of the master channel, at index 0,
+	// and of each logical channel.
+	Controls []uint32
+}
+
+// ClockSource is a UAC2 clock source.
+type ClockSource struct {
+	ID         uint8
+	Attributes uint8
+	// Controls is the bmControls bitmap; bits 0..1 are the sampling
+	// frequency control.
+	Controls      uint8
+	AssocTerminal uint8
+}
+
+// ClockSelector is a UAC2 clock selector, which picks one of several clock
+// entities.
+type ClockSelector struct {
+	ID        uint8
+	SourceIDs []uint8
+}
+
+// ParseTopology parses the class-specific descriptors that follow an
+// AudioControl interface descriptor of the given version.
+func ParseTopology(extra []byte, version Version) (*Topology, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("uac: %w", err)
+	}
+	var topo *Topology
+	for _, desc := range descs {
+		if len(desc) < 3 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		if desc[2] == acHeader {
+			if topo, err = parseACHeader(desc, version); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if topo == nil {
+			return nil, fmt.Errorf(
+				"uac: AudioControl descriptor subtype %#02x before the header",
+				desc[2],
+			)
+		}
+		if err := topo.parseEntity(desc, version); err != nil {
+			return nil, err
+		}
+	}
+	if topo == nil {
+		return nil, fmt.Errorf("uac: no AudioControl header descriptor")
+	}
+	return topo, nil
+}
+
+func parseACHeader(desc []byte, version Version) (*Topology, error) {
+	if version == UAC2 {
+		if len(desc) < 9 {
+			return nil, fmt.Errorf("uac: AudioControl header is %d bytes", len(desc))
+		}
+		return &Topology{ADCVersion: binary.LittleEndian.Uint16(desc[3:5])}, nil
+	}
+	if len(desc) < 8 || len(desc) < 8+int(desc[7]) {
+		return nil, fmt.Errorf("uac: AudioControl header is %d bytes", len(desc))
+	}
+	topo := &Topology{ADCVersion: binary.LittleEndian.Uint16(desc[3:5])}
+	for _, num := range desc[8 : 8+int(desc[7])] {
+		topo.StreamingInterfaces = append(topo.StreamingInterfaces, int(num))
+	}
+	return topo, nil
+}
+
+// entityLengths are the minimum lengths of the entity descriptors for each
+// version, up to the fields parseEntity reads unconditionally.
+var entityLengths = map[Version]map[uint8]int{
+	UAC1: {
+		acInputTerminal:  12,
+		acOutputTerminal: 9,
+		acFeatureUnit:    7,
+	},
+	UAC2: {
+		acInputTerminal:  17,
+		acOutputTerminal: 12,
+		acFeatureUnit:    6,
+		acClockSource:    8,
+		acClockSelector:  5,
+	},
+}
+
+func (topo *Topology) parseEntity(desc []byte, version Version) error {
+	subtype := desc[2]
+	want, ok := entityLengths[version][subtype]
+	if !ok {
+		// Mixer, selector, processing, and extension units and clock
+		// multipliers carry no controls this package uses.
+		return nil
+	}
+	if len(desc) < want {
+		return fmt.Errorf(
+			"uac: AudioControl descriptor subtype %#02x is %d bytes; want at least %d",
+			subtype,
+			len(desc),
+			want,
+		)
+	}
+	id := desc[3]
+	switch subtype {
+	case acInputTerminal:
+		terminal := Terminal{ID: id, TerminalType: binary.LittleEndian.Uint16(desc[4:6])}
+		if version == UAC2 {
+			terminal.ClockSourceID, terminal.Channels = desc[7], desc[8]
+		} else {
+			terminal.Channels = desc[7]
+		}
+		topo.InputTerminals = append(topo.InputTerminals, terminal)
+	case acOutputTerminal:
+		terminal := Terminal{
+			ID:           id,
+			TerminalType: binary.LittleEndian.Uint16(desc[4:6]),
+			SourceID:     desc[7],
+		}
+		if version == UAC2 {
+			terminal.ClockSourceID = desc[8]
+		}
+		topo.OutputTerminals = append(topo.OutputTerminals, terminal)
+	case acFeatureUnit:
+		unit, err := parseFeatureUnit(desc, version)
+		if err != nil {
+			return err
+		}
+		topo.FeatureUnits = append(topo.FeatureUnits, unit)
+	case acClockSource:
+		topo.ClockSources = append(topo.ClockSources, ClockSource{
+			ID:            id,
+			Attributes:    desc[4],
+			Controls:      desc[5],
+			AssocTerminal: desc[6],
+		})
+	case acClockSelector:
+		pins := int(desc[4])
+		if len(desc) < 5+pins {
+			return fmt.Errorf("uac: clock selector %d is too short for %d inputs", id, pins)
+		}
+		topo.ClockSelectors = append(topo.ClockSelectors, ClockSelector{
+			ID:        id,
+			SourceIDs: desc[5 : 5+pins],
+		})
+	}
+	return nil
+}
+
+// parseFeatureUnit decodes the per-channel controls, which are
+// bControlSize bytes each in UAC1 and four bytes in UAC2, and are followed
+// by the iFeature string index.
+func parseFeatureUnit(desc []byte, version Version) (FeatureUnit, error) {
+	unit := FeatureUnit{ID: desc[3], SourceID: desc[4]}
+	offset, size := 5, 4
+	if version == UAC1 {
+		offset, size = 6, int(desc[5])
+		if size == 0 {
+			return unit, fmt.Errorf("uac: feature unit %d has a zero bControlSize", unit.ID)
+		}
+	}
+	count := (len(desc) - offset - 1) / size
+	for i := 0; i < count; i++ {
+		var control uint32
+		for j := size - 1; j >= 0; j-- {
+			control = control<<8 | uint32(desc[offset+i*size+j])
+		}
+		unit.Controls = append(unit.Controls, control)
+	}
+	return unit, nil
+}
+
+// Feature unit control bits. UAC1 has one bit per control; UAC2 has two,
+// of which the upper says the host may set it.
+const (
+	controlMute   = 0
+	controlVolume = 1
+)
+
+// hasControl reports whether the feature unit has control on the channel.
+func (fu *FeatureUnit) hasControl(version Version, control int, channel int) bool {
+	if channel < 0 || channel >= len(fu.Controls) {
+		return false
+	}
+	if version == UAC2 {
+		return fu.Controls[channel]>>(2*control)&0x03 != 0
+	}
+	return fu.Controls[channel]>>control&0x01 != 0
+}
+
+// StreamInterface is an AudioStreaming interface and its alternate
+// settings with a format; alternate setting 0, which has no bandwidth, is
+// not listed.
+type StreamInterface struct {
+	Number      int
+	AltSettings []*AltSetting
+}
+
+// AltSetting is an operational alternate setting of an AudioStreaming
+// interface.
+type AltSetting struct {
+	Interface *libusb.InterfaceDescriptor
+	// TerminalLink is the ID of the USB streaming terminal the interface
+	// is connected to.
+	TerminalLink uint8
+	// Formats is the wFormatTag of UAC1 or the bmFormats of UAC2; PCM is
+	// 0x0001 in both.
+	Formats       uint32
+	FormatType    uint8
+	Channels      uint8
+	SubframeSize  uint8
+	BitResolution uint8
+	// SampleRates lists the discrete rates of a UAC1 format. A continuous
+	// range has no SampleRates but MinRate and MaxRate. UAC2 formats list
+	// neither; their rates come from the clock source.
+	SampleRates []uint32
+	MinRate     uint32
+	MaxRate     uint32
+	// Endpoint is the isochronous data endpoint; Feedback is its explicit
+	// feedback endpoint, or nil.
+	Endpoint *libusb.EndpointDescriptor
+	Feedback *libusb.EndpointDescriptor
+}
+
+// FrameSize returns the bytes of one audio frame: one subframe for each
+// channel.
+func (alt *AltSetting) FrameSize() int {
+	return int(alt.Channels) * int(alt.SubframeSize)
+}
+
+// Playback reports whether the alternate setting carries audio from the
+// host to the device.
+func (alt *AltSetting) Playback() bool {
+	return alt.Endpoint.Direction() == libusb.EndpointOut
+}
+
+// SupportsRate reports whether a UAC1 format lists the sample rate. It
+// returns true for UAC2 formats, which leave rates to the clock source,
+// unless the rate is 0.
+func (alt *AltSetting) SupportsRate(rate uint32) bool {
+	if rate == 0 {
+		return false
+	}
+	if len(alt.SampleRates) > 0 {
+		for _, r := range alt.SampleRates {
+			if r == rate {
+				return true
+			}
+		}
+		return false
+	}
+	if alt.MaxRate == 0 {
+		return true
+	}
+	return rate >= alt.MinRate && rate <= alt.MaxRate
+}
+
+// String implements the Stringer interface for AltSetting.
+func (alt *AltSetting) String() string {
+	return fmt.Sprintf(
+		"alternate setting %d: %d channels, %d bits",
+		alt.Interface.AlternateSetting,
+		alt.Channels,
+		alt.BitResolution,
+	)
+}
+
+// Usage type bits of isochronous endpoint attributes.
+const (
+	endpointUsageMask     = 0x30
+	endpointUsageFeedback = 0x10
+)
+
+// ParseAltSetting parses the class-specific descriptors of an
+// AudioStreaming alternate setting and finds its data and feedback
+// endpoints. Only Type I formats are accepted.
+func ParseAltSetting(iface *libusb.InterfaceDescriptor, version Version) (*AltSetting, error) {
+	descs, err := libusb.SplitDescriptors(iface.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("uac: %w", err)
+	}
+	alt := &AltSetting{Interface: iface}
+	var general, format []byte
+	for _, desc := range descs {
+		if len(desc) < 3 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		switch desc[2] {
+		case asGeneral:
+			general = desc
+		case asFormatType:
+			format = desc
+		}
+	}
+	if general == nil || format == nil {
+		return nil, fmt.Errorf(
+			"uac: interface %d alternate setting %d has no AS_GENERAL or FORMAT_TYPE descriptor",
+			iface.InterfaceNumber,
+			iface.AlternateSetting,
+		)
+	}
+	if err := alt.parseFormat(general, format, version); err != nil {
+		return nil, fmt.Errorf(
+			"uac: interface %d alternate setting %d: %w",
+			iface.InterfaceNumber,
+			iface.AlternateSetting,
+			err,
+		)
+	}
+	for _, ep := range iface.EndpointDescriptors {
+		if ep.TransferType() != libusb.IsochronousTransfer {
+			continue
+		}
+		if uint8(ep.Attributes)&endpointUsageMask == endpointUsageFeedback {
+			alt.Feedback = ep
+		} else if alt.Endpoint == nil {
+			alt.Endpoint = ep
+		}
+	}
+	if alt.Endpoint == nil {
+		return nil, fmt.Errorf(
+			"uac: interface %d alternate setting %d has no isochronous data endpoint",
+			iface.InterfaceNumber,
+			iface.AlternateSetting,
+		)
+	}
+	return alt, nil
+}
+
+func (alt *AltSetting) parseFormat(general, format []byte, version Version) error {
+	if version == UAC2 {
+		if len(general) < 16 || len(format) < 6 {
+			return fmt.Errorf("AS_GENERAL or FORMAT_TYPE descriptor too short")
+		}
+		alt.TerminalLink = general[3]
+		alt.Formats = binary.LittleEndian.Uint32(general[6:10])
+		alt.Channels = general[10]
+		alt.FormatType = format[3]
+		alt.SubframeSize = format[4]
+		alt.BitResolution = format[5]
+	} else {
+		if len(general) < 7 || len(format) < 8 {
+			return fmt.Errorf("AS_GENERAL or FORMAT_TYPE descriptor too short")
+		}
+		alt.TerminalLink = general[3]
+		alt.Formats = uint32(binary.LittleEndian.Uint16(general[5:7]))
+		alt.FormatType = format[3]
+		alt.Channels = format[4]
+		alt.SubframeSize = format[5]
+		alt.BitResolution = format[6]
+		if err := alt.parseRates(format); err != nil {
+			return err
+		}
+	}
+	if alt.FormatType != FormatTypeI {
+		return fmt.Errorf("format type %d is not supported", alt.FormatType)
+	}
+	if alt.Channels == 0 || alt.SubframeSize == 0 {
+		return fmt.Errorf("%d channels of %d bytes", alt.Channels, alt.SubframeSize)
+	}
+	return nil
+}
+
+// parseRates decodes the 3-byte tSamFreq entries of a UAC1 Type I format:
+// bSamFreqType of them, or a lower and upper bound if it is zero.
+func (alt *AltSetting) parseRates(format []byte) error {
+	count := int(format[7])
+	if count == 0 {
+		count = 2
+	}
+	if len(format) < 8+3*count {
+		return fmt.Errorf("FORMAT_TYPE descriptor too short for %d sample rates", count)
+	}
+	rates := make([]uint32, count)
+	for i := range rates {
+		b := format[8+3*i:]
+		rates[i] = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+	}
+	if format[7] == 0 {
+		alt.MinRate, alt.MaxRate = rates[0], rates[1]
+		return nil
+	}
+	alt.SampleRates = rates
+	alt.MinRate, alt.MaxRate = rates[0], rates[0]
+	for _, rate := range rates {
+		alt.MinRate = min(alt.MinRate, rate)
+		alt.MaxRate = max(alt.MaxRate, rate)
+	}
+	return nil
+}
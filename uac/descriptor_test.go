@@ -0,0 +1,174 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func TestParseTopology(t *testing.T) {
+	topo, err := ParseTopology(testUAC1Control(), UAC1)
+	if err != nil {
+		t.Fatalf("ParseTopology UAC1: unexpected error %v", err)
+	}
+	if topo.ADCVersion != 0x0100 || !slices.Equal(topo.StreamingInterfaces, []int{1, 2}) {
+		t.Errorf("UAC1 header = %#04x %v", topo.ADCVersion, topo.StreamingInterfaces)
+	}
+	if len(topo.InputTerminals) != 2 || len(topo.OutputTerminals) != 2 {
+		t.Errorf("UAC1 terminals = %+v %+v", topo.InputTerminals, topo.OutputTerminals)
+	}
+	got := topo.InputTerminals[0]
+	if got.TerminalType != TerminalUSBStreaming || got.Channels != 2 {
+		t.Errorf("UAC1 input terminal = %+v", got)
+	}
+	if got := topo.OutputTerminals[0]; got.TerminalType != TerminalSpeaker || got.SourceID != 2 {
+		t.Errorf("UAC1 output terminal = %+v", got)
+	}
+	unit := topo.FeatureUnits[0]
+	if unit.ID != 2 || !slices.Equal(unit.Controls, []uint32{0x03, 0x02, 0x02}) {
+		t.Errorf("UAC1 feature unit = %+v", unit)
+	}
+
+	topo, err = ParseTopology(testUAC2Control(), UAC2)
+	if err != nil {
+		t.Fatalf("ParseTopology UAC2: unexpected error %v", err)
+	}
+	if topo.ADCVersion != 0x0200 || topo.StreamingInterfaces != nil {
+		t.Errorf("UAC2 header = %#04x %v", topo.ADCVersion, topo.StreamingInterfaces)
+	}
+	if len(topo.ClockSources) != 2 || topo.ClockSources[1].ID != 9 {
+		t.Errorf("UAC2 clock sources = %+v", topo.ClockSources)
+	}
+	selectors := topo.ClockSelectors
+	if len(selectors) != 1 || !slices.Equal(selectors[0].SourceIDs, []uint8{8, 9}) {
+		t.Errorf("UAC2 clock selectors = %+v", topo.ClockSelectors)
+	}
+	if got := topo.InputTerminals[0]; got.ClockSourceID != 10 || got.Channels != 2 {
+		t.Errorf("UAC2 input terminal = %+v", got)
+	}
+	if got := topo.OutputTerminals[0]; got.ClockSourceID != 10 || got.SourceID != 2 {
+		t.Errorf("UAC2 output terminal = %+v", got)
+	}
+	unit = topo.FeatureUnits[0]
+	if !slices.Equal(unit.Controls, []uint32{0x0F, 0x0C, 0}) {
+		t.Errorf("UAC2 feature unit = %+v", unit)
+	}
+	if !unit.hasControl(UAC2, controlMute, 0) || unit.hasControl(UAC2, controlMute, 1) ||
+		!unit.hasControl(UAC2, controlVolume, 1) || unit.hasControl(UAC2, controlVolume, 3) {
+		t.Error("UAC2 feature unit reports the wrong controls")
+	}
+}
+
+func TestParseTopologyErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		extra   []byte
+		version Version
+	}{
+		{"no header", desc(acInputTerminal, 1, 0x01, 0x01, 0, 2, 0, 0, 0, 0), UAC1},
+		{"short header", desc(acHeader, 0x00, 0x01, 0, 0, 3, 1), UAC1},
+		{
+			"short terminal",
+			append(desc(acHeader, 0x00, 0x02, 1, 0, 0, 0), desc(acInputTerminal, 1, 0x01)...),
+			UAC2,
+		},
+		{
+			"zero control size",
+			append(desc(acHeader, 0x00, 0x01, 0, 0, 0), desc(acFeatureUnit, 2, 1, 0, 0)...),
+			UAC1,
+		},
+		{"truncated", []byte{9, descriptorTypeCSInterface, acHeader}, UAC1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseTopology(tc.extra, tc.version); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseAltSetting(t *testing.T) {
+	data := &libusb.EndpointDescriptor{EndpointAddress: 0x01, Attributes: 0x05}
+	feedback := &libusb.EndpointDescriptor{EndpointAddress: 0x81, Attributes: 0x11}
+	iface := &libusb.InterfaceDescriptor{
+		InterfaceNumber:     1,
+		AlternateSetting:    1,
+		Extra:               testUAC1Streaming(1, 2, 16, 44100, 48000),
+		EndpointDescriptors: libusb.EndpointDescriptors{feedback, data},
+	}
+	alt, err := ParseAltSetting(iface, UAC1)
+	if err != nil {
+		t.Fatalf("ParseAltSetting UAC1: unexpected error %v", err)
+	}
+	if alt.Endpoint != data || alt.Feedback != feedback || !alt.Playback() {
+		t.Errorf("UAC1 endpoints = %+v %+v", alt.Endpoint, alt.Feedback)
+	}
+	if alt.TerminalLink != 1 || alt.Formats != 1 || alt.FrameSize() != 4 ||
+		!slices.Equal(alt.SampleRates, []uint32{44100, 48000}) {
+		t.Errorf("UAC1 alternate setting = %+v", alt)
+	}
+	if !alt.SupportsRate(44100) || alt.SupportsRate(96000) {
+		t.Error("UAC1 SupportsRate checked the wrong rates")
+	}
+
+	iface.Extra = testUAC1Streaming(1, 2, 16, 8000, 96000)
+	iface.Extra[len(iface.Extra)-7] = 0
+	if alt, err = ParseAltSetting(iface, UAC1); err != nil {
+		t.Fatalf("ParseAltSetting continuous: unexpected error %v", err)
+	}
+	if alt.SampleRates != nil || alt.MinRate != 8000 || alt.MaxRate != 96000 ||
+		!alt.SupportsRate(22050) {
+		t.Errorf("continuous rates = %v %d..%d", alt.SampleRates, alt.MinRate, alt.MaxRate)
+	}
+
+	iface.Extra = testUAC2Streaming(2, 24)
+	iface.EndpointDescriptors = libusb.EndpointDescriptors{data}
+	if alt, err = ParseAltSetting(iface, UAC2); err != nil {
+		t.Fatalf("ParseAltSetting UAC2: unexpected error %v", err)
+	}
+	if alt.Channels != 2 || alt.SubframeSize != 3 || alt.BitResolution != 24 ||
+		alt.Feedback != nil || !alt.SupportsRate(192000) {
+		t.Errorf("UAC2 alternate setting = %+v", alt)
+	}
+	if got, want := alt.String(), "alternate setting 1: 2 channels, 24 bits"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	iface.Extra = desc(asGeneral, 1, 1, 0x01, 0x00)
+	if _, err := ParseAltSetting(iface, UAC1); err == nil {
+		t.Error("missing FORMAT_TYPE: expected error, got nil")
+	}
+	iface.Extra = testUAC1Streaming(1, 2, 16, 48000)
+	iface.Extra[10] = 0x02
+	if _, err := ParseAltSetting(iface, UAC1); err == nil {
+		t.Error("Type II format: expected error, got nil")
+	}
+	iface.Extra = testUAC1Streaming(1, 2, 16, 48000)
+	iface.EndpointDescriptors = libusb.EndpointDescriptors{feedback}
+	if _, err := ParseAltSetting(iface, UAC1); err == nil {
+		t.Error("no data endpoint: expected error, got nil")
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	testCases := []struct {
+		version Version
+		want    string
+	}{
+		{UAC1, "UAC1"},
+		{UAC2, "UAC2"},
+		{0x30, "audio protocol 0x30"},
+	}
+	for _, tc := range testCases {
+		if got := tc.version.String(); got != tc.want {
+			t.Errorf("Version(%#02x).String() = %q, want %q", uint8(tc.version), got, tc.want)
+		}
+	}
+}
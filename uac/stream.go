@@ -0,0 +1,482 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+const (
+	// transferMillis is the audio each transfer carries.
+	transferMillis = 8
+	// transfersQueued is the number of transfers kept queued on a data
+	// endpoint.
+	transfersQueued = 3
+	// feedbackQueued is the number of transfers kept queued on a feedback
+	// endpoint.
+	feedbackQueued = 2
+)
+
+// isoStreamer is the part of *libusb.DeviceHandle that queues isochronous
+// transfers ahead of time.
+type isoStreamer interface {
+	IsochronousStream(
+		endpoint libusb.EndpointAddress,
+		packetLengths []int,
+		transfers int,
+		timeout int,
+	) (*libusb.IsoStream, error)
+}
+
+// maxPacketSize returns the bytes an isochronous endpoint moves per
+// service interval, counting the additional transactions of high-bandwidth
+// endpoints.
+func maxPacketSize(ep *libusb.EndpointDescriptor) int {
+	return int(ep.MaxPacketSize&0x07FF) * (1 + int(ep.MaxPacketSize>>11&0x03))
+}
+
+// packetsPerSecond returns the service rate of an isochronous endpoint.
+// UAC2 functions run at high speed, where bInterval counts 125 µs
+// microframes; UAC1 functions at full speed, where it counts 1 ms frames.
+func (dev *Device) packetsPerSecond(ep *libusb.EndpointDescriptor) int {
+	perSecond := 1000
+	if dev.Function.Version == UAC2 {
+		perSecond = 8000
+	}
+	if ep.Interval > 1 && ep.Interval <= 16 {
+		perSecond >>= ep.Interval - 1
+	}
+	return max(perSecond, 1)
+}
+
+// startStream selects an alternate setting and sets its sample rate, and
+// registers the stream so Device.Close stops it.
+func (dev *Device) startStream(
+	alt *AltSetting,
+	rate uint32,
+	playback bool,
+	stream interface{ Close() error },
+) (*StreamInterface, error) {
+	si, err := dev.checkAltSetting(alt)
+	if err != nil {
+		return nil, err
+	}
+	if alt.Playback() != playback {
+		return nil, fmt.Errorf("uac: %v streams the other direction", alt)
+	}
+	if !alt.SupportsRate(rate) {
+		return nil, fmt.Errorf("uac: %v does not support %d Hz", alt, rate)
+	}
+	if size := maxPacketSize(alt.Endpoint); size < alt.FrameSize() {
+		return nil, fmt.Errorf(
+			"uac: %v has %d byte packets, smaller than a %d byte frame",
+			alt,
+			size,
+			alt.FrameSize(),
+		)
+	}
+	dev.mu.Lock()
+	if _, ok := dev.streams[si.Number]; ok {
+		dev.mu.Unlock()
+		return nil, fmt.Errorf("uac: interface %d is already streaming", si.Number)
+	}
+	dev.streams[si.Number] = stream
+	dev.mu.Unlock()
+
+	num := si.Number
+	if err := dev.handle.SetInterfaceAltSetting(num, alt.Interface.AlternateSetting); err != nil {
+		dev.forget(num)
+		return nil, fmt.Errorf(
+			"uac: selecting alternate setting %d of interface %d: %w",
+			alt.Interface.AlternateSetting,
+			num,
+			err,
+		)
+	}
+	if err := dev.SetSampleRate(alt, rate); err != nil {
+		dev.forget(num)
+		return nil, errors.Join(err, dev.handle.SetInterfaceAltSetting(num, 0))
+	}
+	return si, nil
+}
+
+// stopStream selects alternate setting 0 again, which frees the
+// isochronous bandwidth, and forgets the stream.
+func (dev *Device) stopStream(num int) error {
+	defer dev.forget(num)
+	if err := dev.handle.SetInterfaceAltSetting(num, 0); err != nil {
+		return fmt.Errorf("uac: selecting alternate setting 0 of interface %d: %w", num, err)
+	}
+	return nil
+}
+
+func (dev *Device) forget(num int) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	delete(dev.streams, num)
+}
+
+// isoEndpoint reads or writes an isochronous endpoint with an IsoStream
+// when the handle has one, and with one synchronous transfer at a time
+// otherwise.
+type isoEndpoint struct {
+	handle   Handle
+	stream   *libusb.IsoStream
+	endpoint libusb.EndpointAddress
+	timeout  int
+}
+
+func openIsoEndpoint(
+	handle Handle,
+	endpoint libusb.EndpointAddress,
+	packetLengths []int,
+	transfers int,
+	timeout int,
+) (*isoEndpoint, error) {
+	ie := &isoEndpoint{handle: handle, endpoint: endpoint, timeout: timeout}
+	if streamer, ok := handle.(isoStreamer); ok {
+		stream, err := streamer.IsochronousStream(endpoint, packetLengths, transfers, timeout)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"uac: queuing transfers on endpoint %#02x: %w",
+				uint8(endpoint),
+				err,
+			)
+		}
+		ie.stream = stream
+	}
+	return ie, nil
+}
+
+func (ie *isoEndpoint) read(data []byte, packetLengths []int) ([]libusb.IsoPacket, error) {
+	if ie.stream != nil {
+		return ie.stream.Read(data)
+	}
+	return ie.handle.IsochronousTransfer(ie.endpoint, data, packetLengths, ie.timeout)
+}
+
+func (ie *isoEndpoint) write(data []byte, packetLengths []int) error {
+	if ie.stream != nil {
+		return ie.stream.Write(data, packetLengths)
+	}
+	_, err := ie.handle.IsochronousTransfer(ie.endpoint, data, packetLengths, ie.timeout)
+	return err
+}
+
+func (ie *isoEndpoint) close() error {
+	if ie.stream == nil {
+		return nil
+	}
+	return ie.stream.Close()
+}
+
+// Playback streams PCM to an AudioStreaming alternate setting. Write takes
+// interleaved frames of the setting's channel count and subframe size, in
+// little-endian order.
+type Playback struct {
+	dev  *Device
+	si   *StreamInterface
+	Alt  *AltSetting
+	Rate uint32
+
+	data      *isoEndpoint
+	frameSize int
+	maxPacket int
+	packets   int
+	// nominal is the frames per packet the rate asks for, in 16.16 fixed
+	// point; feedback is what the feedback endpoint last reported, or 0.
+	nominal  uint32
+	feedback atomic.Uint32
+	fraction uint32
+
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+
+	stop     chan struct{}
+	feedDone chan struct{}
+}
+
+// StartPlayback selects a playback alternate setting, sets the sample rate,
+// and returns a Playback to write PCM to. If the endpoint has a feedback
+// endpoint, it is read in the background to pace the packets.
+func (dev *Device) StartPlayback(alt *AltSetting, rate uint32) (*Playback, error) {
+	pb := &Playback{dev: dev, Alt: alt, Rate: rate, stop: make(chan struct{})}
+	// Device.Close may close pb as soon as it is registered; hold it
+	// until it is set up, and leave it closed if that fails.
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	pb.closed = true
+	si, err := dev.startStream(alt, rate, true, pb)
+	if err != nil {
+		return nil, err
+	}
+	pb.si = si
+	perSecond := dev.packetsPerSecond(alt.Endpoint)
+	pb.frameSize = alt.FrameSize()
+	pb.maxPacket = maxPacketSize(alt.Endpoint)
+	pb.packets = max(perSecond*transferMillis/1000, 1)
+	pb.nominal = uint32(uint64(rate) << 16 / uint64(perSecond))
+	lengths := make([]int, pb.packets)
+	for i := range lengths {
+		lengths[i] = pb.maxPacket
+	}
+	pb.data, err = openIsoEndpoint(dev.handle, alt.Endpoint.EndpointAddress, lengths,
+		transfersQueued, dev.Timeout)
+	if err != nil {
+		return nil, errors.Join(err, dev.stopStream(si.Number))
+	}
+	if alt.Feedback != nil {
+		size := maxPacketSize(alt.Feedback)
+		feedback, err := openIsoEndpoint(dev.handle, alt.Feedback.EndpointAddress, []int{size},
+			feedbackQueued, dev.Timeout)
+		if err != nil {
+			return nil, errors.Join(err, pb.data.close(), dev.stopStream(si.Number))
+		}
+		pb.feedDone = make(chan struct{})
+		go pb.readFeedback(feedback, size)
+	}
+	pb.closed = false
+	return pb, nil
+}
+
+// readFeedback keeps the rate the device asks for up to date. Full-speed
+// endpoints report frames per frame as 10.14 fixed point in three bytes,
+// high-speed ones frames per microframe as 16.16 in four. Values far from
+// the nominal rate are ignored, as some devices send garbage at startup.
+func (pb *Playback) readFeedback(feedback *isoEndpoint, size int) {
+	defer close(pb.feedDone)
+	defer feedback.close()
+	buf := make([]byte, size)
+	lengths := []int{size}
+	for {
+		select {
+		case <-pb.stop:
+			return
+		default:
+		}
+		packets, err := feedback.read(buf, lengths)
+		if err != nil && !usbif.IsTimeout(err) {
+			return
+		}
+		if len(packets) == 0 || packets[0].Err != nil {
+			continue
+		}
+		var value uint32
+		switch packets[0].ActualLength {
+		case 3:
+			value = (uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16) << 2
+		case 4:
+			value = uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16 | uint32(buf[3])<<24
+		default:
+			continue
+		}
+		if value > pb.nominal-pb.nominal/4 && value < pb.nominal+pb.nominal/4 {
+			pb.feedback.Store(value)
+		}
+	}
+}
+
+// FramesPerPacket returns the frames each packet carries on average, as
+// the feedback endpoint last reported or as the nominal rate asks for.
+func (pb *Playback) FramesPerPacket() float64 {
+	rate := pb.feedback.Load()
+	if rate == 0 {
+		rate = pb.nominal
+	}
+	return float64(rate) / 65536
+}
+
+// plan returns the packet lengths of the next transfer, their total, and
+// the fraction of a frame left over, which is carried from packet to
+// packet so that over time the packets carry exactly the rate asked for.
+func (pb *Playback) plan() ([]int, int, uint32) {
+	rate := pb.feedback.Load()
+	if rate == 0 {
+		rate = pb.nominal
+	}
+	fraction := pb.fraction
+	lengths := make([]int, pb.packets)
+	total := 0
+	for i := range lengths {
+		fraction += rate
+		frames := int(fraction >> 16)
+		fraction &= 0xFFFF
+		lengths[i] = min(frames*pb.frameSize, pb.maxPacket/pb.frameSize*pb.frameSize)
+		total += lengths[i]
+	}
+	return lengths, total, fraction
+}
+
+// Write queues PCM for playback, sending a transfer whenever enough is
+// pending for one. Write blocks while the queued transfers are full, which
+// paces the writer to the device's clock.
+func (pb *Playback) Write(p []byte) (int, error) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	if pb.closed {
+		return 0, os.ErrClosed
+	}
+	pb.pending = append(pb.pending, p...)
+	if err := pb.flush(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush sends the pending PCM in whole transfers; with final set, the
+// remainder is padded with silence and sent too.
+func (pb *Playback) flush(final bool) error {
+	for {
+		lengths, total, fraction := pb.plan()
+		if total == 0 {
+			return fmt.Errorf("uac: playback at %d Hz plans transfers of no frames", pb.Rate)
+		}
+		if total > len(pb.pending) {
+			if !final || len(pb.pending) == 0 {
+				return nil
+			}
+			pb.pending = append(pb.pending, make([]byte, total-len(pb.pending))...)
+		}
+		if err := pb.data.write(pb.pending[:total], lengths); err != nil {
+			pb.pending = pb.pending[:0]
+			return fmt.Errorf(
+				"uac: writing to endpoint %#02x: %w",
+				uint8(pb.Alt.Endpoint.EndpointAddress),
+				err,
+			)
+		}
+		pb.fraction = fraction
+		pb.pending = pb.pending[:copy(pb.pending, pb.pending[total:])]
+	}
+}
+
+// Close sends what is still pending, padded with silence to a whole
+// transfer, stops the feedback reader, and selects alternate setting 0.
+// Calling Close again returns os.ErrClosed.
+func (pb *Playback) Close() error {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	if pb.closed {
+		return os.ErrClosed
+	}
+	pb.closed = true
+	errs := []error{pb.flush(true)}
+	close(pb.stop)
+	if pb.feedDone != nil {
+		<-pb.feedDone
+	}
+	errs = append(errs, pb.data.close(), pb.dev.stopStream(pb.si.Number))
+	return errors.Join(errs...)
+}
+
+// Capture streams PCM from an AudioStreaming alternate setting. Read
+// returns interleaved frames of the setting's channel count and subframe
+// size, in little-endian order.
+type Capture struct {
+	dev  *Device
+	si   *StreamInterface
+	Alt  *AltSetting
+	Rate uint32
+
+	data    *isoEndpoint
+	buf     []byte
+	lengths []int
+
+	mu      sync.Mutex
+	pending []byte
+	lost    uint64
+	closed  bool
+}
+
+// StartCapture selects a capture alternate setting, sets the sample rate,
+// and returns a Capture to read PCM from.
+func (dev *Device) StartCapture(alt *AltSetting, rate uint32) (*Capture, error) {
+	c := &Capture{dev: dev, Alt: alt, Rate: rate}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	si, err := dev.startStream(alt, rate, false, c)
+	if err != nil {
+		return nil, err
+	}
+	c.si = si
+	size := maxPacketSize(alt.Endpoint)
+	packets := max(dev.packetsPerSecond(alt.Endpoint)*transferMillis/1000, 1)
+	c.buf = make([]byte, size*packets)
+	c.lengths = make([]int, packets)
+	for i := range c.lengths {
+		c.lengths[i] = size
+	}
+	c.data, err = openIsoEndpoint(dev.handle, alt.Endpoint.EndpointAddress, c.lengths,
+		transfersQueued, dev.Timeout)
+	if err != nil {
+		return nil, errors.Join(err, dev.stopStream(si.Number))
+	}
+	c.closed = false
+	return c, nil
+}
+
+// Read reads captured PCM. It blocks for a transfer when nothing is
+// pending, and returns whole frames as long as p holds at least one.
+func (c *Capture) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, os.ErrClosed
+	}
+	for len(c.pending) == 0 {
+		packets, err := c.data.read(c.buf, c.lengths)
+		if err != nil && !usbif.IsTimeout(err) {
+			return 0, fmt.Errorf(
+				"uac: reading from endpoint %#02x: %w",
+				uint8(c.Alt.Endpoint.EndpointAddress),
+				err,
+			)
+		}
+		for _, packet := range packets {
+			if packet.Err != nil {
+				c.lost++
+				continue
+			}
+			c.pending = append(c.pending, c.buf[packet.Offset:packet.Offset+packet.ActualLength]...)
+		}
+	}
+	n := len(p)
+	if frameSize := c.Alt.FrameSize(); n >= frameSize {
+		n -= n % frameSize
+	}
+	n = copy(p[:n], c.pending)
+	c.pending = c.pending[:copy(c.pending, c.pending[n:])]
+	return n, nil
+}
+
+// Lost returns the number of packets that failed and whose audio is
+// missing from what Read returned.
+func (c *Capture) Lost() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lost
+}
+
+// Close stops the capture and selects alternate setting 0. Calling Close
+// again returns os.ErrClosed.
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return os.ErrClosed
+	}
+	c.closed = true
+	return errors.Join(c.data.close(), c.dev.stopStream(c.si.Number))
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlayback(t *testing.T) {
+	fh := &fakeHandle{}
+	dev := openTestDevice(t, fh, UAC1)
+	defer dev.Close()
+	alt := dev.Function.FindAltSetting(true, 2, 16, 48000)
+
+	pb, err := dev.StartPlayback(alt, 48000)
+	if err != nil {
+		t.Fatalf("StartPlayback: unexpected error %v", err)
+	}
+	if _, err := dev.StartPlayback(alt, 48000); err == nil {
+		t.Error("second StartPlayback: expected error, got nil")
+	}
+	// 8 ms of 48 kHz stereo is 1536 bytes; write two and a bit transfers.
+	pcm := make([]byte, 2*1536+100)
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	if n, err := pb.Write(pcm); err != nil || n != len(pcm) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if err := pb.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if _, err := pb.Write(pcm); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed", err)
+	}
+	if err := pb.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+
+	fh.mu.Lock()
+	written, lengths := fh.written, fh.lengths
+	fh.mu.Unlock()
+	if len(written) != 3*1536 || !bytes.Equal(written[:len(pcm)], pcm) {
+		t.Errorf("wrote %d bytes, want the PCM padded to %d", len(written), 3*1536)
+	}
+	if slices.ContainsFunc(written[len(pcm):], func(b byte) bool { return b != 0 }) {
+		t.Error("padding is not silence")
+	}
+	for _, l := range lengths {
+		if !slices.Equal(l, []int{192, 192, 192, 192, 192, 192, 192, 192}) {
+			t.Errorf("packet lengths = %v", l)
+		}
+	}
+	want := []string{"alt 1 1", "set 0x0100 0x0001 80 bb 00", "alt 1 0"}
+	if got := fh.Calls()[3:]; !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+
+	// A stopped stream can be started again.
+	if pb, err = dev.StartPlayback(alt, 44100); err != nil {
+		t.Fatalf("restarting playback: unexpected error %v", err)
+	}
+	if _, err := dev.StartPlayback(dev.Function.Streams[1].AltSettings[0], 48000); err == nil {
+		t.Error("StartPlayback of a capture setting: expected error, got nil")
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Device.Close: unexpected error %v", err)
+	}
+	if err := pb.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Close after Device.Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestStartPlaybackErrors(t *testing.T) {
+	dev := openTestDevice(t, &fakeHandle{controls: map[controlKey][]byte{
+		{requestCur, 0x0100, 0x0A00}: {1},
+	}}, UAC2)
+	defer dev.Close()
+	alt := dev.Function.Streams[0].AltSettings[0]
+	if _, err := dev.StartPlayback(alt, 0); err == nil {
+		t.Error("StartPlayback at 0 Hz: expected error, got nil")
+	}
+	if err := dev.SetSampleRate(alt, 0); err == nil {
+		t.Error("SetSampleRate of 0 Hz: expected error, got nil")
+	}
+	alt.Endpoint.MaxPacketSize = uint16(alt.FrameSize() - 1)
+	if _, err := dev.StartPlayback(alt, 48000); err == nil {
+		t.Error("StartPlayback with packets smaller than a frame: expected error, got nil")
+	}
+}
+
+func TestFlushWithoutFrames(t *testing.T) {
+	// A plan of empty packets would never consume the pending PCM.
+	alt := &AltSetting{Channels: 2, SubframeSize: 2}
+	pb := &Playback{Alt: alt, packets: 8, frameSize: 4, maxPacket: 192}
+	pb.pending = make([]byte, 64)
+	if err := pb.flush(false); err == nil {
+		t.Error("flush at a nominal rate of 0: expected error, got nil")
+	}
+}
+
+func TestPlaybackPacing(t *testing.T) {
+	testCases := []struct {
+		name     string
+		version  Version
+		rate     uint32
+		feedback []byte
+		// frames is what FramesPerPacket should settle on.
+		frames float64
+	}{
+		{"UAC1 nominal", UAC1, 44100, nil, 44.1},
+		{"UAC1 10.14 feedback", UAC1, 44100, []byte{0x00, 0x00, 0x0B}, 44},
+		{"UAC1 implausible feedback", UAC1, 44100, []byte{0x00, 0x00, 0x02}, 44.1},
+		{"UAC2 16.16 feedback", UAC2, 48000, []byte{0x00, 0x80, 0x06, 0x00}, 6.5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := &fakeHandle{feedback: tc.feedback, controls: map[controlKey][]byte{
+				{requestCur, 0x0100, 0x0A00}: {1},
+			}}
+			dev := openTestDevice(t, fh, tc.version)
+			defer dev.Close()
+			pb, err := dev.StartPlayback(dev.Function.Streams[0].AltSettings[0], tc.rate)
+			if err != nil {
+				t.Fatalf("StartPlayback: unexpected error %v", err)
+			}
+			near := func() bool {
+				diff := pb.FramesPerPacket() - tc.frames
+				return diff > -0.001 && diff < 0.001
+			}
+			for deadline := time.Now().Add(time.Second); !near() && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			if !near() {
+				t.Fatalf("FramesPerPacket = %v, want %v", pb.FramesPerPacket(), tc.frames)
+			}
+			// Five transfers' worth of PCM at the settled rate, plus the
+			// fractions that carry over.
+			frameSize := pb.Alt.FrameSize()
+			frames := int(5 * float64(pb.packets) * tc.frames)
+			if _, err := pb.Write(make([]byte, (frames+1)*frameSize)); err != nil {
+				t.Fatalf("Write: unexpected error %v", err)
+			}
+			fh.mu.Lock()
+			sent := len(fh.written) / frameSize
+			fh.mu.Unlock()
+			if sent < frames-1 || sent > frames+1 {
+				t.Errorf("sent %d frames in five transfers, want %d", sent, frames)
+			}
+		})
+	}
+}
+
+func TestCapture(t *testing.T) {
+	fh := &fakeHandle{}
+	for i := 0; i < 8; i++ {
+		p := bytes.Repeat([]byte{byte(i)}, 96)
+		if i == 3 {
+			p = nil
+		}
+		fh.captured = append(fh.captured, p)
+	}
+	dev := openTestDevice(t, fh, UAC1)
+	defer dev.Close()
+	alt := dev.Function.FindAltSetting(false, 1, 16, 48000)
+	c, err := dev.StartCapture(alt, 48000)
+	if err != nil {
+		t.Fatalf("StartCapture: unexpected error %v", err)
+	}
+
+	var got []byte
+	buf := make([]byte, 101)
+	for len(got) < 7*96 {
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: unexpected error %v", err)
+		}
+		if n%alt.FrameSize() != 0 {
+			t.Fatalf("Read returned %d bytes, not whole frames", n)
+		}
+		got = append(got, buf[:n]...)
+	}
+	var want []byte
+	for i := 0; i < 8; i++ {
+		if i != 3 {
+			want = append(want, bytes.Repeat([]byte{byte(i)}, 96)...)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Read returned % x, want % x", got, want)
+	}
+	if lost := c.Lost(); lost != 1 {
+		t.Errorf("Lost = %d, want 1", lost)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	if _, err := c.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read after Close: got %v, want os.ErrClosed", err)
+	}
+	wantCalls := []string{"alt 2 1", "set 0x0100 0x0082 80 bb 00", "alt 2 0"}
+	if calls := fh.Calls()[3:]; !slices.Equal(calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", calls, wantCalls)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package uac implements the USB Audio Device Class, versions 1.0 and 2.0, on
+top of libusb.
+
+An audio function is an AudioControl interface, whose class-specific
+descriptors describe the terminals, feature units, and UAC2 clock entities
+that make up its topology, and the AudioStreaming interfaces that carry
+the audio. FindFunctions parses both, and Open claims the interfaces,
+detaching the kernel's snd-usb-audio driver if it is bound.
+
+Device has the sample rate, volume, and mute controls, sent to the
+endpoint or feature unit for UAC1 and to the clock source or feature unit
+for UAC2. StartPlayback and StartCapture select an alternate setting and
+stream interleaved PCM through an io.Writer or io.Reader. Asynchronous
+playback endpoints are paced by their feedback endpoint, so the device's
+clock decides how many frames each packet carries.
+*/
+package uac
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds. It
+// bounds the sample rate, volume and mute requests, and each streaming
+// transfer when the handle can't queue them.
+const DefaultTimeout = 1000
+
+// Interface subclasses of the audio class.
+const (
+	SubclassAudioControl   = 0x01
+	SubclassAudioStreaming = 0x02
+	SubclassMIDIStreaming  = 0x03
+)
+
+// Handle is what a Device needs of a *libusb.DeviceHandle. A handle
+// that also has IsochronousStream, as *libusb.DeviceHandle does, streams
+// through it so that no packets are lost between transfers.
+type Handle interface {
+	usbif.Claimer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	IsochronousTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		packetLengths []int,
+		timeout int,
+	) ([]libusb.IsoPacket, error)
+}
+
+// Function is an audio function: its AudioControl interface, the topology
+// its descriptors describe, and its AudioStreaming interfaces.
+type Function struct {
+	Control  *libusb.InterfaceDescriptor
+	Version  Version
+	Topology *Topology
+	Streams  []*StreamInterface
+}
+
+// FindFunctions returns the audio functions of a configuration. The
+// AudioStreaming interfaces of a UAC1 function are those its header lists;
+// those of a UAC2 function are the others of its interface association.
+// Alternate settings with formats this package can't stream are skipped.
+func FindFunctions(config *libusb.ConfigDescriptor) ([]*Function, error) {
+	if config == nil {
+		return nil, nil
+	}
+	var fns []*Function
+	for _, group := range config.Functions() {
+		for _, supported := range group.Interfaces {
+			control := altSetting(supported.InterfaceDescriptors, 0)
+			if control == nil || control.InterfaceClass != libusb.InterfaceClassAudio ||
+				control.InterfaceSubClass != SubclassAudioControl {
+				continue
+			}
+			version := Version(control.InterfaceProtocol)
+			topo, err := ParseTopology(control.Extra, version)
+			if err != nil {
+				return nil, err
+			}
+			fn := &Function{Control: control, Version: version, Topology: topo}
+			nums := topo.StreamingInterfaces
+			if version == UAC2 {
+				nums = nil
+				for _, other := range group.Interfaces {
+					if alt0 := altSetting(other.InterfaceDescriptors, 0); alt0 != nil &&
+						alt0.InterfaceClass == libusb.InterfaceClassAudio &&
+						alt0.InterfaceSubClass == SubclassAudioStreaming {
+						nums = append(nums, alt0.InterfaceNumber)
+					}
+				}
+			}
+			for _, num := range nums {
+				si, err := findStreamInterface(config, num, version)
+				if err != nil {
+					return nil, err
+				}
+				fn.Streams = append(fn.Streams, si)
+			}
+			fns = append(fns, fn)
+		}
+	}
+	return fns, nil
+}
+
+func findStreamInterface(
+	config *libusb.ConfigDescriptor,
+	num int,
+	version Version,
+) (*StreamInterface, error) {
+	for _, supported := range config.SupportedInterfaces {
+		alt0 := altSetting(supported.InterfaceDescriptors, 0)
+		if alt0 == nil || alt0.InterfaceNumber != num {
+			continue
+		}
+		if alt0.InterfaceClass != libusb.InterfaceClassAudio ||
+			alt0.InterfaceSubClass != SubclassAudioStreaming {
+			return nil, fmt.Errorf("uac: interface %d is not an AudioStreaming interface", num)
+		}
+		si := &StreamInterface{Number: num}
+		for _, iface := range supported.InterfaceDescriptors {
+			if len(iface.EndpointDescriptors) == 0 {
+				continue
+			}
+			if alt, err := ParseAltSetting(iface, version); err == nil {
+				si.AltSettings = append(si.AltSettings, alt)
+			}
+		}
+		return si, nil
+	}
+	return nil, fmt.Errorf("uac: AudioStreaming interface %d not in the configuration", num)
+}
+
+func altSetting(
+	ifaces libusb.InterfaceDescriptors,
+	alt int,
+) *libusb.InterfaceDescriptor {
+	for _, iface := range ifaces {
+		if iface.AlternateSetting == alt {
+			return iface
+		}
+	}
+	return nil
+}
+
+// FindAltSetting returns the first alternate setting of the function with
+// the direction, channel count, and bit resolution asked for, and that
+// lists the sample rate, or nil if there is none. A zero channel count or
+// resolution matches any.
+func (fn *Function) FindAltSetting(
+	playback bool,
+	channels uint8,
+	bits uint8,
+	rate uint32,
+) *AltSetting {
+	for _, si := range fn.Streams {
+		for _, alt := range si.AltSettings {
+			if alt.Playback() == playback &&
+				(channels == 0 || alt.Channels == channels) &&
+				(bits == 0 || alt.BitResolution == bits) &&
+				alt.SupportsRate(rate) {
+				return alt
+			}
+		}
+	}
+	return nil
+}
+
+// Device is a claimed audio function.
+type Device struct {
+	handle   Handle
+	Function *Function
+	// Timeout is the timeout in milliseconds of class requests and, when
+	// the handle can't queue transfers, of each streaming transfer.
+	Timeout int
+
+	mu      sync.Mutex
+	closed  bool
+	claims  *usbif.Claims
+	streams map[int]interface{ Close() error }
+}
+
+// Open claims the AudioControl and AudioStreaming interfaces of an audio
+// function, taking them from snd-usb-audio where it has them.
+func Open(handle Handle, fn *Function) (*Device, error) {
+	if handle == nil || fn == nil || fn.Control == nil || fn.Topology == nil {
+		return nil, fmt.Errorf("uac: nil handle or function")
+	}
+	dev := &Device{
+		handle:   handle,
+		Function: fn,
+		Timeout:  DefaultTimeout,
+		streams:  make(map[int]interface{ Close() error }),
+		claims:   usbif.NewClaims(handle, "uac"),
+	}
+	nums := []int{fn.Control.InterfaceNumber}
+	for _, si := range fn.Streams {
+		nums = append(nums, si.Number)
+	}
+	for _, num := range nums {
+		if err := dev.claims.Claim(num); err != nil {
+			return nil, errors.Join(err, dev.claims.Release())
+		}
+	}
+	return dev, nil
+}
+
+// Close stops playback and capture, then gives the interfaces back, to
+// snd-usb-audio where Open took them from it. Calling Close again returns
+// os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	if dev.closed {
+		dev.mu.Unlock()
+		return os.ErrClosed
+	}
+	dev.closed = true
+	streams := make([]interface{ Close() error }, 0, len(dev.streams))
+	for _, stream := range dev.streams {
+		streams = append(streams, stream)
+	}
+	dev.mu.Unlock()
+	var errs []error
+	for _, stream := range streams {
+		if err := stream.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(append(errs, dev.claims.Release())...)
+}
+
+// checkAltSetting checks that the device is open and alt belongs to one
+// of its AudioStreaming interfaces, which it returns.
+func (dev *Device) checkAltSetting(alt *AltSetting) (*StreamInterface, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return nil, os.ErrClosed
+	}
+	for _, si := range dev.Function.Streams {
+		if slices.Contains(si.AltSettings, alt) {
+			return si, nil
+		}
+	}
+	return nil, fmt.Errorf("uac: alternate setting is not part of the device's function")
+}
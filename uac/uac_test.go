@@ -0,0 +1,384 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uac
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+// controlKey identifies a class request to the fake handle.
+type controlKey struct {
+	request byte
+	value   uint16
+	index   uint16
+}
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// controls are the replies to GET requests; SET requests store into it
+	// under the matching GET_CUR or CUR request.
+	controls map[controlKey][]byte
+	// feedback is returned by each read of the feedback endpoint 0x81.
+	feedback []byte
+	// captured are returned one per packet read from endpoint 0x82; a
+	// zero-length payload fails its packet.
+	captured [][]byte
+	// written collects what was written to endpoint 0x01, and lengths the
+	// packet lengths of each write.
+	written []byte
+	lengths [][]int
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	reply, ok := fh.controls[controlKey{request, value, index}]
+	if !ok {
+		return 0, libusb.ErrPipe
+	}
+	return copy(data[:maxReceiveLength], reply), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.Record("set %#04x %#04x % x", value, index, data)
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if fh.controls == nil {
+		fh.controls = make(map[controlKey][]byte)
+	}
+	fh.controls[controlKey{requestGetCur, value, index}] = slices.Clone(data)
+	fh.controls[controlKey{requestCur, value, index}] = slices.Clone(data)
+	return len(data), nil
+}
+
+func (fh *fakeHandle) IsochronousTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	packetLengths []int,
+	timeout int,
+) ([]libusb.IsoPacket, error) {
+	time.Sleep(time.Millisecond)
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	packets := make([]libusb.IsoPacket, len(packetLengths))
+	offset := 0
+	for i, length := range packetLengths {
+		packets[i] = libusb.IsoPacket{Offset: offset, Length: length}
+		switch endpoint {
+		case 0x01:
+			packets[i].ActualLength = length
+		case 0x81:
+			packets[i].ActualLength = copy(data[offset:offset+length], fh.feedback)
+		case 0x82:
+			if len(fh.captured) == 0 {
+				break
+			}
+			p := fh.captured[0]
+			fh.captured = fh.captured[1:]
+			if len(p) == 0 {
+				packets[i].Err = libusb.ErrIO
+			}
+			packets[i].ActualLength = copy(data[offset:offset+length], p)
+		}
+		offset += length
+	}
+	if endpoint == 0x01 {
+		fh.written = append(fh.written, data[:offset]...)
+		fh.lengths = append(fh.lengths, slices.Clone(packetLengths))
+	}
+	return packets, nil
+}
+
+// desc returns a class-specific interface descriptor of the subtype.
+func desc(subtype byte, body ...byte) []byte {
+	return append([]byte{byte(3 + len(body)), descriptorTypeCSInterface, subtype}, body...)
+}
+
+// testUAC1Control returns the AudioControl descriptors of a UAC1 function
+// with a playback path, USB streaming terminal 1 through feature unit 2
+// to speaker 3, and a capture path, microphone 4 to USB streaming terminal
+// 5. Streaming interfaces 1 and 2 are listed in the header.
+func testUAC1Control() []byte {
+	var extra []byte
+	for _, d := range [][]byte{
+		desc(acHeader, 0x00, 0x01, 0, 0, 2, 1, 2),
+		desc(acInputTerminal, 1, 0x01, 0x01, 0, 2, 0x03, 0x00, 0, 0),
+		// Mute and volume on the master channel, volume on both channels.
+		desc(acFeatureUnit, 2, 1, 1, 0x03, 0x02, 0x02, 0),
+		desc(acOutputTerminal, 3, 0x01, 0x03, 0, 2, 0),
+		desc(acInputTerminal, 4, 0x01, 0x02, 0, 1, 0x00, 0x00, 0, 0),
+		desc(acOutputTerminal, 5, 0x01, 0x01, 0, 4, 0),
+	} {
+		extra = append(extra, d...)
+	}
+	return extra
+}
+
+// testUAC1Streaming returns the AudioStreaming descriptors of a UAC1 Type
+// I format linked to the terminal, with the sample rates.
+func testUAC1Streaming(link, channels, bits byte, rates ...uint32) []byte {
+	extra := desc(asGeneral, link, 1, 0x01, 0x00)
+	format := []byte{FormatTypeI, channels, bits / 8, bits, byte(len(rates))}
+	for _, rate := range rates {
+		format = append(format, byte(rate), byte(rate>>8), byte(rate>>16))
+	}
+	return append(extra, desc(asFormatType, format...)...)
+}
+
+// testUAC2Control returns the AudioControl descriptors of a UAC2 function:
+// clock sources 8 and 9 behind clock selector 10, USB streaming terminal
+// 1 through feature unit 2 to speaker 3, all clocked by the selector.
+func testUAC2Control() []byte {
+	var extra []byte
+	for _, d := range [][]byte{
+		desc(acHeader, 0x00, 0x02, 0x01, 0, 0, 0),
+		desc(acClockSource, 8, 0x01, 0x07, 0, 0),
+		desc(acClockSource, 9, 0x01, 0x07, 0, 0),
+		desc(acClockSelector, 10, 2, 8, 9, 0x03, 0),
+		desc(acInputTerminal, 1, 0x01, 0x01, 0, 10, 2, 0x03, 0, 0, 0, 0, 0, 0, 0),
+		// Mute and volume on the master channel, volume on channel 1.
+		desc(acFeatureUnit, 2, 1,
+			0x0F, 0, 0, 0,
+			0x0C, 0, 0, 0,
+			0, 0, 0, 0,
+			0),
+		desc(acOutputTerminal, 3, 0x01, 0x03, 0, 2, 10, 0, 0, 0),
+	} {
+		extra = append(extra, d...)
+	}
+	return extra
+}
+
+// testUAC2Streaming returns the AudioStreaming descriptors of a UAC2 PCM
+// format linked to terminal 1.
+func testUAC2Streaming(channels, bits byte) []byte {
+	extra := desc(asGeneral, 1, 0, FormatTypeI, 0x01, 0, 0, 0, channels, 0, 0, 0, 0, 0)
+	return append(extra, desc(asFormatType, FormatTypeI, bits/8, bits)...)
+}
+
+func streamingInterface(
+	num int,
+	extra []byte,
+	eps ...*libusb.EndpointDescriptor,
+) *libusb.SupportedInterface {
+	return &libusb.SupportedInterface{InterfaceDescriptors: libusb.InterfaceDescriptors{
+		{
+			InterfaceNumber:   num,
+			InterfaceClass:    libusb.InterfaceClassAudio,
+			InterfaceSubClass: SubclassAudioStreaming,
+		},
+		{
+			InterfaceNumber:     num,
+			AlternateSetting:    1,
+			InterfaceClass:      libusb.InterfaceClassAudio,
+			InterfaceSubClass:   SubclassAudioStreaming,
+			Extra:               extra,
+			EndpointDescriptors: eps,
+		},
+	}}
+}
+
+// testConfig returns a configuration with an audio function of the
+// version. The UAC1 function plays 16-bit stereo at 44.1 or 48 kHz to
+// endpoint 0x01, with feedback on 0x81, and captures 16-bit mono at 48 kHz
+// from 0x82. The UAC2 function plays 24-bit stereo at high speed to 0x01,
+// with feedback on 0x81, and is grouped by an interface association.
+func testConfig(version Version) *libusb.ConfigDescriptor {
+	control := &libusb.InterfaceDescriptor{
+		InterfaceClass:    libusb.InterfaceClassAudio,
+		InterfaceSubClass: SubclassAudioControl,
+		InterfaceProtocol: uint8(version),
+	}
+	feedback := &libusb.EndpointDescriptor{
+		EndpointAddress: 0x81,
+		Attributes:      0x11,
+		MaxPacketSize:   3,
+		Interval:        1,
+	}
+	if version == UAC2 {
+		control.Extra = testUAC2Control()
+		feedback.MaxPacketSize = 4
+		return &libusb.ConfigDescriptor{
+			InterfaceAssociations: []*libusb.InterfaceAssociationDescriptor{{
+				FirstInterface: 0,
+				InterfaceCount: 2,
+				FunctionClass:  uint8(libusb.InterfaceClassAudio),
+			}},
+			SupportedInterfaces: libusb.SupportedInterfaces{
+				{InterfaceDescriptors: libusb.InterfaceDescriptors{control}},
+				streamingInterface(1, testUAC2Streaming(2, 24),
+					&libusb.EndpointDescriptor{
+						EndpointAddress: 0x01,
+						Attributes:      0x05,
+						MaxPacketSize:   48,
+						Interval:        1,
+					},
+					feedback,
+				),
+			},
+		}
+	}
+	control.Extra = testUAC1Control()
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{control}},
+			streamingInterface(1, testUAC1Streaming(1, 2, 16, 44100, 48000),
+				&libusb.EndpointDescriptor{
+					EndpointAddress: 0x01,
+					Attributes:      0x05,
+					MaxPacketSize:   196,
+					Interval:        1,
+				},
+				feedback,
+			),
+			streamingInterface(2, testUAC1Streaming(5, 1, 16, 48000),
+				&libusb.EndpointDescriptor{
+					EndpointAddress: 0x82,
+					Attributes:      0x05,
+					MaxPacketSize:   96,
+					Interval:        1,
+				},
+			),
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle, version Version) *Device {
+	t.Helper()
+	fns, err := FindFunctions(testConfig(version))
+	if err != nil || len(fns) != 1 {
+		t.Fatalf("FindFunctions = %d functions, %v", len(fns), err)
+	}
+	dev, err := Open(fh, fns[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindFunctions(t *testing.T) {
+	testCases := []struct {
+		version Version
+		streams []int
+	}{
+		{UAC1, []int{1, 2}},
+		{UAC2, []int{1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version.String(), func(t *testing.T) {
+			fns, err := FindFunctions(testConfig(tc.version))
+			if err != nil {
+				t.Fatalf("FindFunctions: unexpected error %v", err)
+			}
+			if len(fns) != 1 || fns[0].Version != tc.version {
+				t.Fatalf("FindFunctions = %+v", fns)
+			}
+			var streams []int
+			for _, si := range fns[0].Streams {
+				streams = append(streams, si.Number)
+				if len(si.AltSettings) != 1 {
+					t.Errorf("interface %d has %d alternate settings",
+						si.Number, len(si.AltSettings))
+				}
+			}
+			if !slices.Equal(streams, tc.streams) {
+				t.Errorf("streams = %v, want %v", streams, tc.streams)
+			}
+		})
+	}
+
+	config := testConfig(UAC1)
+	config.SupportedInterfaces = config.SupportedInterfaces[:2]
+	if _, err := FindFunctions(config); err == nil {
+		t.Error("missing AudioStreaming interface: expected error, got nil")
+	}
+}
+
+func TestFindAltSetting(t *testing.T) {
+	fns, err := FindFunctions(testConfig(UAC1))
+	if err != nil {
+		t.Fatalf("FindFunctions: unexpected error %v", err)
+	}
+	fn := fns[0]
+	testCases := []struct {
+		name     string
+		playback bool
+		channels uint8
+		bits     uint8
+		rate     uint32
+		want     int
+	}{
+		{"stereo playback", true, 2, 16, 44100, 1},
+		{"any playback", true, 0, 0, 48000, 1},
+		{"mono capture", false, 1, 16, 48000, 2},
+		{"unlisted rate", true, 2, 16, 96000, 0},
+		{"no 24-bit", true, 2, 24, 48000, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alt := fn.FindAltSetting(tc.playback, tc.channels, tc.bits, tc.rate)
+			got := 0
+			if alt != nil {
+				got = alt.Interface.InterfaceNumber
+			}
+			if got != tc.want {
+				t.Errorf("FindAltSetting found interface %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	dev := openTestDevice(t, fh, UAC1)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{
+		"detach 0", "claim 0", "detach 1", "claim 1", "detach 2", "claim 2",
+		"release 2", "release 1", "release 0", "attach 2", "attach 1", "attach 0",
+	}
+	if got := fh.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.Volume(2, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Volume after Close: got %v, want os.ErrClosed", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbmidi
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// Class-specific descriptor types.
+const (
+	descriptorTypeCSInterface = 0x24
+	descriptorTypeCSEndpoint  = 0x25
+)
+
+// MIDIStreaming interface descriptor subtypes.
+const (
+	msHeader      = 0x01
+	msMIDIInJack  = 0x02
+	msMIDIOutJack = 0x03
+)
+
+// msGeneral is the subtype of the class-specific endpoint descriptor that
+// lists an endpoint's embedded jacks.
+const msGeneral = 0x01
+
+// JackType tells embedded jacks, which connect to a USB endpoint, from
+// external ones, which stand for the device's physical MIDI ports.
+type JackType uint8
+
+// Jack types.
+const (
+	JackEmbedded JackType = 0x01
+	JackExternal JackType = 0x02
+)
+
+var jackTypes = map[JackType]string{
+	JackEmbedded: "embedded",
+	JackExternal: "external",
+}
+
+// String implements the Stringer interface for JackType.
+func (jt JackType) String() string {
+	if s, ok := jackTypes[jt]; ok {
+		return s
+	}
+	return fmt.Sprintf("jack type %#02x", uint8(jt))
+}
+
+// Jack is a MIDI IN or MIDI OUT jack.
+type Jack struct {
+	ID   uint8
+	Type JackType
+	// Sources are the jacks or elements an OUT jack is fed by, as pairs of
+	// entity ID and output pin. IN jacks have none.
+	Sources []Source
+	// StringIndex is the iJack string descriptor index, or 0.
+	StringIndex uint8
+}
+
+// Source is an input pin of an OUT jack.
+type Source struct {
+	ID  uint8
+	Pin uint8
+}
+
+// Streaming models the class-specific descriptors of a MIDIStreaming
+// interface.
+type Streaming struct {
+	// MSCVersion is the bcdMSC of the header: 0x0100 for the event packets
+	// this package speaks, 0x0200 for USB MIDI 2.0 alternate settings.
+	MSCVersion uint16
+	InJacks    []Jack
+	OutJacks   []Jack
+}
+
+// Jack returns the IN or OUT jack with the ID, or nil.
+func (ms *Streaming) Jack(id uint8) *Jack {
+	for _, jacks := range [][]Jack{ms.InJacks, ms.OutJacks} {
+		for i := range jacks {
+			if jacks[i].ID == id {
+				return &jacks[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ParseStreaming parses the class-specific descriptors that follow a
+// MIDIStreaming interface descriptor.
+func ParseStreaming(extra []byte) (*Streaming, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("usbmidi: %w", err)
+	}
+	var ms *Streaming
+	for _, desc := range descs {
+		if len(desc) < 3 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		subtype := desc[2]
+		if subtype == msHeader {
+			if len(desc) < 7 {
+				return nil, fmt.Errorf("usbmidi: MS header is %d bytes", len(desc))
+			}
+			ms = &Streaming{MSCVersion: binary.LittleEndian.Uint16(desc[3:5])}
+			continue
+		}
+		if ms == nil {
+			return nil, fmt.Errorf(
+				"usbmidi: MIDIStreaming descriptor subtype %#02x before the header",
+				subtype,
+			)
+		}
+		switch subtype {
+		case msMIDIInJack:
+			if len(desc) < 6 {
+				return nil, fmt.Errorf("usbmidi: MIDI IN jack is %d bytes", len(desc))
+			}
+			ms.InJacks = append(ms.InJacks, Jack{
+				ID:          desc[4],
+				Type:        JackType(desc[3]),
+				StringIndex: desc[5],
+			})
+		case msMIDIOutJack:
+			jack, err := parseOutJack(desc)
+			if err != nil {
+				return nil, err
+			}
+			ms.OutJacks = append(ms.OutJacks, jack)
+		}
+	}
+	if ms == nil {
+		return nil, fmt.Errorf("usbmidi: no MS header descriptor")
+	}
+	return ms, nil
+}
+
+// parseOutJack decodes a MIDI OUT jack: its type and ID, bNrInputPins
+// pairs of source ID and pin, then iJack.
+func parseOutJack(desc []byte) (Jack, error) {
+	if len(desc) < 6 || len(desc) < 7+2*int(desc[5]) {
+		return Jack{}, fmt.Errorf("usbmidi: MIDI OUT jack is %d bytes", len(desc))
+	}
+	pins := int(desc[5])
+	jack := Jack{ID: desc[4], Type: JackType(desc[3]), StringIndex: desc[6+2*pins]}
+	for i := 0; i < pins; i++ {
+		jack.Sources = append(jack.Sources, Source{ID: desc[6+2*i], Pin: desc[7+2*i]})
+	}
+	return jack, nil
+}
+
+// EndpointJacks returns the IDs of the embedded jacks a MIDIStreaming
+// endpoint is connected to, from the MS_GENERAL descriptor in its extra
+// bytes. Cable number n of the endpoint's event packets is jack n.
+func EndpointJacks(ep *libusb.EndpointDescriptor) ([]uint8, error) {
+	descs, err := libusb.SplitDescriptors(ep.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("usbmidi: %w", err)
+	}
+	for _, desc := range descs {
+		if len(desc) < 4 || desc[1] != descriptorTypeCSEndpoint || desc[2] != msGeneral {
+			continue
+		}
+		count := int(desc[3])
+		if len(desc) < 4+count {
+			return nil, fmt.Errorf(
+				"usbmidi: MS_GENERAL endpoint descriptor is %d bytes",
+				len(desc),
+			)
+		}
+		return desc[4 : 4+count], nil
+	}
+	return nil, fmt.Errorf(
+		"usbmidi: endpoint %#02x has no MS_GENERAL descriptor",
+		uint8(ep.EndpointAddress),
+	)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbmidi
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// desc returns a class-specific interface descriptor of the subtype.
+func desc(subtype byte, body ...byte) []byte {
+	return append([]byte{byte(3 + len(body)), descriptorTypeCSInterface, subtype}, body...)
+}
+
+// testStreaming returns the descriptors of a MIDIStreaming interface with
+// one port: embedded IN jack 1 feeds external OUT jack 4, and external IN
+// jack 2 feeds embedded OUT jack 3.
+func testStreaming(version uint16) []byte {
+	var extra []byte
+	for _, d := range [][]byte{
+		desc(msHeader, byte(version), byte(version>>8), 0x41, 0x00),
+		desc(msMIDIInJack, byte(JackEmbedded), 1, 0),
+		desc(msMIDIInJack, byte(JackExternal), 2, 5),
+		desc(msMIDIOutJack, byte(JackEmbedded), 3, 1, 2, 1, 0),
+		desc(msMIDIOutJack, byte(JackExternal), 4, 1, 1, 1, 6),
+	} {
+		extra = append(extra, d...)
+	}
+	return extra
+}
+
+// endpointJacks returns the MS_GENERAL endpoint descriptor for the jacks.
+func endpointJacks(jacks ...byte) []byte {
+	return append([]byte{byte(4 + len(jacks)), descriptorTypeCSEndpoint, msGeneral,
+		byte(len(jacks))}, jacks...)
+}
+
+func TestParseStreaming(t *testing.T) {
+	ms, err := ParseStreaming(testStreaming(0x0100))
+	if err != nil {
+		t.Fatalf("ParseStreaming: unexpected error %v", err)
+	}
+	if ms.MSCVersion != 0x0100 || len(ms.InJacks) != 2 || len(ms.OutJacks) != 2 {
+		t.Fatalf("ParseStreaming = %+v", ms)
+	}
+	if jack := ms.Jack(2); jack == nil || jack.Type != JackExternal || jack.StringIndex != 5 {
+		t.Errorf("IN jack 2 = %+v", jack)
+	}
+	jack := ms.Jack(4)
+	if jack == nil || jack.StringIndex != 6 || !slices.Equal(jack.Sources, []Source{{1, 1}}) {
+		t.Errorf("OUT jack 4 = %+v", jack)
+	}
+	if ms.Jack(9) != nil {
+		t.Error("Jack(9) found a jack")
+	}
+
+	testCases := []struct {
+		name  string
+		extra []byte
+	}{
+		{"no header", desc(msMIDIInJack, 1, 1, 0)},
+		{"short header", desc(msHeader, 0x00, 0x01)},
+		{"short IN jack", append(testStreaming(0x0100), desc(msMIDIInJack, 1, 7)...)},
+		{"short OUT jack", append(testStreaming(0x0100), desc(msMIDIOutJack, 1, 8, 2, 1, 1)...)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseStreaming(tc.extra); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestEndpointJacks(t *testing.T) {
+	ep := &libusb.EndpointDescriptor{EndpointAddress: 0x81, Extra: endpointJacks(3, 5)}
+	jacks, err := EndpointJacks(ep)
+	if err != nil || !slices.Equal(jacks, []uint8{3, 5}) {
+		t.Errorf("EndpointJacks = %v, %v", jacks, err)
+	}
+	ep.Extra = ep.Extra[:5]
+	ep.Extra[0] = 5
+	if _, err := EndpointJacks(ep); err == nil {
+		t.Error("truncated jack list: expected error, got nil")
+	}
+	ep.Extra = nil
+	if _, err := EndpointJacks(ep); err == nil {
+		t.Error("no MS_GENERAL descriptor: expected error, got nil")
+	}
+}
+
+func TestJackTypeString(t *testing.T) {
+	testCases := []struct {
+		jt   JackType
+		want string
+	}{
+		{JackEmbedded, "embedded"},
+		{JackExternal, "external"},
+		{0x07, "jack type 0x07"},
+	}
+	for _, tc := range testCases {
+		if got := tc.jt.String(); got != tc.want {
+			t.Errorf("JackType(%d).String() = %q, want %q", uint8(tc.jt), got, tc.want)
+		}
+	}
+}
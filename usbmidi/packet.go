@@ -0,0 +1,263 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbmidi
+
+import "fmt"
+
+// Code index numbers, the low nibble of an event packet's header, which
+// classify the MIDI bytes that follow.
+const (
+	cinSystemCommon2 = 0x2
+	cinSystemCommon3 = 0x3
+	cinSysExStart    = 0x4
+	cinSysExEnd1     = 0x5
+	cinSysExEnd2     = 0x6
+	cinSysExEnd3     = 0x7
+	cinSingleByte    = 0xF
+)
+
+// cinLengths are the MIDI bytes each code index number carries. The
+// reserved numbers 0x0 and 0x1 carry none this package understands.
+var cinLengths = [16]int{0, 0, 2, 3, 3, 1, 2, 3, 3, 3, 3, 3, 2, 2, 3, 1}
+
+// MIDI status bytes with special handling.
+const (
+	statusSysEx        = 0xF0
+	statusTimeCode     = 0xF1
+	statusSongPosition = 0xF2
+	statusSongSelect   = 0xF3
+	statusTuneRequest  = 0xF6
+	statusEndOfSysEx   = 0xF7
+	statusRealTime     = 0xF8
+)
+
+// MaxSysExLength bounds the SysEx messages a Decoder reassembles; longer
+// ones are dropped rather than buffered without limit.
+const MaxSysExLength = 1 << 20
+
+// Packet is a USB-MIDI event packet: a header with the cable number in the
+// high nibble and the code index number in the low one, and up to three
+// MIDI bytes padded with zeros.
+type Packet [4]byte
+
+// Cable returns the virtual cable the packet belongs to.
+func (pkt Packet) Cable() uint8 {
+	return pkt[0] >> 4
+}
+
+// CIN returns the packet's code index number.
+func (pkt Packet) CIN() uint8 {
+	return pkt[0] & 0x0F
+}
+
+// MIDI returns the MIDI bytes the packet carries.
+func (pkt Packet) MIDI() []byte {
+	return pkt[1 : 1+cinLengths[pkt.CIN()]]
+}
+
+// String implements the Stringer interface for Packet.
+func (pkt Packet) String() string {
+	return fmt.Sprintf("cable %d: % x", pkt.Cable(), pkt.MIDI())
+}
+
+func newPacket(cable, cin uint8, midi []byte) Packet {
+	pkt := Packet{cable<<4 | cin&0x0F}
+	copy(pkt[1:], midi)
+	return pkt
+}
+
+// Message is a complete MIDI message from a cable: a channel, system
+// common, or real-time message, or a whole SysEx message from 0xF0 to 0xF7.
+type Message struct {
+	Cable uint8
+	Data  []byte
+}
+
+// String implements the Stringer interface for Message.
+func (msg Message) String() string {
+	return fmt.Sprintf("cable %d: % x", msg.Cable, msg.Data)
+}
+
+// messageLength returns the length of the message a status byte starts, or
+// 0 for status bytes that don't start one of a fixed length.
+func messageLength(status byte) int {
+	switch {
+	case status < 0x80:
+		return 0
+	case status < 0xC0 || status >= 0xE0 && status < 0xF0:
+		return 3
+	case status < 0xE0:
+		return 2
+	case status == statusTimeCode || status == statusSongSelect:
+		return 2
+	case status == statusSongPosition:
+		return 3
+	case status == statusTuneRequest || status >= statusRealTime:
+		return 1
+	}
+	return 0
+}
+
+// Encoder turns the MIDI byte stream of one cable into event packets. It
+// follows running status, lets real-time bytes through in the middle of
+// other messages, and splits SysEx messages into three-byte packets.
+// Bytes that belong to no message are dropped.
+type Encoder struct {
+	cable   uint8
+	running byte
+	buf     []byte
+	want    int
+	sysex   bool
+}
+
+// NewEncoder returns an Encoder for the cable, which must be below 16.
+func NewEncoder(cable uint8) *Encoder {
+	return &Encoder{cable: cable & 0x0F}
+}
+
+// Encode returns the packets for the complete messages in p. The bytes of
+// an incomplete message are kept for the next call.
+func (enc *Encoder) Encode(p []byte) []Packet {
+	var packets []Packet
+	for _, b := range p {
+		switch {
+		case b >= statusRealTime:
+			packets = append(packets, newPacket(enc.cable, cinSingleByte, []byte{b}))
+		case b == statusEndOfSysEx:
+			if enc.sysex {
+				enc.buf = append(enc.buf, b)
+				packets = append(packets, enc.endSysEx())
+			}
+		case b >= 0x80:
+			// Any other status byte cuts short a SysEx message; its last
+			// bytes go out in an end packet, so the receiver drops it.
+			if enc.sysex && len(enc.buf) > 0 {
+				packets = append(packets, enc.endSysEx())
+			}
+			enc.sysex = false
+			enc.buf = enc.buf[:0]
+			enc.running = 0
+			switch {
+			case b == statusSysEx:
+				enc.sysex = true
+				enc.buf = append(enc.buf, b)
+			case b < statusSysEx:
+				enc.running = b
+				enc.buf = append(enc.buf, b)
+				enc.want = messageLength(b)
+			case messageLength(b) == 1:
+				packets = append(packets, newPacket(enc.cable, cinSysExEnd1, []byte{b}))
+			case messageLength(b) > 1:
+				enc.buf = append(enc.buf, b)
+				enc.want = messageLength(b)
+			}
+		case enc.sysex:
+			enc.buf = append(enc.buf, b)
+			if len(enc.buf) == 3 {
+				packets = append(packets, newPacket(enc.cable, cinSysExStart, enc.buf))
+				enc.buf = enc.buf[:0]
+			}
+		default:
+			if len(enc.buf) == 0 {
+				if enc.running == 0 {
+					continue
+				}
+				enc.buf = append(enc.buf, enc.running)
+				enc.want = messageLength(enc.running)
+			}
+			enc.buf = append(enc.buf, b)
+			if len(enc.buf) == enc.want {
+				packets = append(packets, enc.message())
+				enc.buf = enc.buf[:0]
+			}
+		}
+	}
+	return packets
+}
+
+// message returns the packet of the complete channel or system common
+// message in buf.
+func (enc *Encoder) message() Packet {
+	status := enc.buf[0]
+	switch {
+	case status < statusSysEx:
+		return newPacket(enc.cable, status>>4, enc.buf)
+	case len(enc.buf) == 2:
+		return newPacket(enc.cable, cinSystemCommon2, enc.buf)
+	default:
+		return newPacket(enc.cable, cinSystemCommon3, enc.buf)
+	}
+}
+
+// endSysEx returns the packet that ends a SysEx message with the one to
+// three bytes in buf.
+func (enc *Encoder) endSysEx() Packet {
+	pkt := newPacket(enc.cable, cinSysExEnd1+uint8(len(enc.buf))-1, enc.buf)
+	enc.sysex = false
+	enc.buf = enc.buf[:0]
+	return pkt
+}
+
+// Decoder turns event packets back into MIDI messages, reassembling the
+// SysEx messages of each cable from their packets.
+type Decoder struct {
+	sysex [16][]byte
+}
+
+// Decode returns the message a packet completes, if any. Real-time
+// messages are returned as they come, even in the middle of a SysEx
+// message. Padding packets of all zeros and reserved code index numbers
+// return nothing.
+func (dec *Decoder) Decode(pkt Packet) (Message, bool) {
+	cable := pkt.Cable()
+	data := pkt.MIDI()
+	if len(data) == 0 {
+		return Message{}, false
+	}
+	switch cin := pkt.CIN(); {
+	case cin == cinSysExStart:
+		return dec.appendSysEx(cable, data, false)
+	case cin == cinSysExEnd2 || cin == cinSysExEnd3:
+		return dec.appendSysEx(cable, data, true)
+	case cin == cinSysExEnd1 || cin == cinSingleByte:
+		// A lone byte is a SysEx byte if it is a data byte or starts or ends
+		// a SysEx message; some devices send all SysEx data this way.
+		b := data[0]
+		switch {
+		case b == statusEndOfSysEx:
+			return dec.appendSysEx(cable, data, true)
+		case b == statusSysEx || b < 0x80:
+			return dec.appendSysEx(cable, data, false)
+		}
+	}
+	return Message{Cable: cable, Data: append([]byte(nil), data...)}, true
+}
+
+// appendSysEx adds data to the SysEx message of a cable and returns the
+// message once end is set. A 0xF0 starts over, dropping any unfinished
+// message; bytes without a message in progress are dropped, and so is the
+// rest of a message that grows past MaxSysExLength.
+func (dec *Decoder) appendSysEx(cable uint8, data []byte, end bool) (Message, bool) {
+	if data[0] == statusSysEx {
+		dec.sysex[cable] = nil
+	} else if len(dec.sysex[cable]) == 0 {
+		return Message{}, false
+	}
+	if len(dec.sysex[cable])+len(data) > MaxSysExLength {
+		dec.sysex[cable] = nil
+		return Message{}, false
+	}
+	dec.sysex[cable] = append(dec.sysex[cable], data...)
+	if !end {
+		return Message{}, false
+	}
+	msg := Message{Cable: cable, Data: dec.sysex[cable]}
+	dec.sysex[cable] = nil
+	if msg.Data[len(msg.Data)-1] != statusEndOfSysEx {
+		return Message{}, false
+	}
+	return msg, true
+}
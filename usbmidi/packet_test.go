@@ -0,0 +1,246 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbmidi
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cable uint8
+		midi  []byte
+		want  []Packet
+	}{
+		{
+			"note on and off",
+			0,
+			[]byte{0x90, 0x3C, 0x40, 0x80, 0x3C, 0x00},
+			[]Packet{{0x09, 0x90, 0x3C, 0x40}, {0x08, 0x80, 0x3C, 0x00}},
+		},
+		{
+			"running status",
+			1,
+			[]byte{0x91, 0x3C, 0x40, 0x3E, 0x40},
+			[]Packet{{0x19, 0x91, 0x3C, 0x40}, {0x19, 0x91, 0x3E, 0x40}},
+		},
+		{
+			"program change and pressure",
+			2,
+			[]byte{0xC0, 0x05, 0x06, 0xD0, 0x70},
+			[]Packet{{0x2C, 0xC0, 0x05}, {0x2C, 0xC0, 0x06}, {0x2D, 0xD0, 0x70}},
+		},
+		{
+			"real-time inside a message",
+			0,
+			[]byte{0xB0, 0x07, 0xF8, 0x64},
+			[]Packet{{0x0F, 0xF8}, {0x0B, 0xB0, 0x07, 0x64}},
+		},
+		{
+			"system common",
+			0,
+			[]byte{0xF1, 0x21, 0xF2, 0x10, 0x02, 0xF3, 0x04, 0xF6},
+			[]Packet{
+				{0x02, 0xF1, 0x21},
+				{0x03, 0xF2, 0x10, 0x02},
+				{0x02, 0xF3, 0x04},
+				{0x05, 0xF6},
+			},
+		},
+		{
+			"system common cancels running status",
+			0,
+			[]byte{0x90, 0x3C, 0x40, 0xF6, 0x3C, 0x00},
+			[]Packet{{0x09, 0x90, 0x3C, 0x40}, {0x05, 0xF6}},
+		},
+		{
+			"SysEx over two packets",
+			3,
+			[]byte{0xF0, 0x7E, 0x7F, 0x06, 0x01, 0xF7},
+			[]Packet{{0x34, 0xF0, 0x7E, 0x7F}, {0x37, 0x06, 0x01, 0xF7}},
+		},
+		{
+			"short SysEx",
+			0,
+			[]byte{0xF0, 0x41, 0xF7},
+			[]Packet{{0x07, 0xF0, 0x41, 0xF7}},
+		},
+		{
+			"SysEx ending with two bytes",
+			0,
+			[]byte{0xF0, 0x41, 0x10, 0x42, 0xF7},
+			[]Packet{{0x04, 0xF0, 0x41, 0x10}, {0x06, 0x42, 0xF7}},
+		},
+		{
+			"SysEx ending on a packet boundary",
+			0,
+			[]byte{0xF0, 0x41, 0x10, 0xF7},
+			[]Packet{{0x04, 0xF0, 0x41, 0x10}, {0x05, 0xF7}},
+		},
+		{
+			"SysEx cut short",
+			0,
+			[]byte{0xF0, 0x41, 0x90, 0x3C, 0x40},
+			[]Packet{{0x06, 0xF0, 0x41}, {0x09, 0x90, 0x3C, 0x40}},
+		},
+		{
+			"stray data and undefined status",
+			0,
+			[]byte{0x3C, 0xF7, 0xF4, 0x01},
+			nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewEncoder(tc.cable).Encode(tc.midi)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Encode = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeAcrossCalls(t *testing.T) {
+	enc := NewEncoder(0)
+	var got []Packet
+	for _, b := range []byte{0xF0, 0x01, 0x02, 0x03, 0x04, 0xF7, 0x90, 0x3C, 0x40} {
+		got = append(got, enc.Encode([]byte{b})...)
+	}
+	want := []Packet{{0x04, 0xF0, 0x01, 0x02}, {0x07, 0x03, 0x04, 0xF7}, {0x09, 0x90, 0x3C, 0x40}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		packets []Packet
+		want    []Message
+	}{
+		{
+			"channel messages",
+			[]Packet{{0x09, 0x90, 0x3C, 0x40}, {0x1C, 0xC1, 0x05}},
+			[]Message{{0, []byte{0x90, 0x3C, 0x40}}, {1, []byte{0xC1, 0x05}}},
+		},
+		{
+			"padding and reserved",
+			[]Packet{{}, {0x00, 0x01, 0x02, 0x03}, {0x01, 0x01}},
+			nil,
+		},
+		{
+			"SysEx with real-time inside",
+			[]Packet{{0x04, 0xF0, 0x7E, 0x7F}, {0x0F, 0xF8}, {0x06, 0x06, 0xF7}},
+			[]Message{{0, []byte{0xF8}}, {0, []byte{0xF0, 0x7E, 0x7F, 0x06, 0xF7}}},
+		},
+		{
+			"SysEx on two cables",
+			[]Packet{
+				{0x04, 0xF0, 0x01, 0x02},
+				{0x14, 0xF0, 0x11, 0x12},
+				{0x05, 0xF7},
+				{0x17, 0x13, 0x14, 0xF7},
+			},
+			[]Message{
+				{0, []byte{0xF0, 0x01, 0x02, 0xF7}},
+				{1, []byte{0xF0, 0x11, 0x12, 0x13, 0x14, 0xF7}},
+			},
+		},
+		{
+			"SysEx in single bytes",
+			[]Packet{{0x0F, 0xF0}, {0x0F, 0x41}, {0x0F, 0xF7}},
+			[]Message{{0, []byte{0xF0, 0x41, 0xF7}}},
+		},
+		{
+			"SysEx restarted",
+			[]Packet{{0x04, 0xF0, 0x01, 0x02}, {0x07, 0xF0, 0x03, 0xF7}},
+			[]Message{{0, []byte{0xF0, 0x03, 0xF7}}},
+		},
+		{
+			"SysEx cut short",
+			[]Packet{{0x06, 0xF0, 0x41}, {0x05, 0xF7}},
+			nil,
+		},
+		{
+			"continuation without a start",
+			[]Packet{{0x04, 0x01, 0x02, 0x03}, {0x06, 0x04, 0xF7}},
+			nil,
+		},
+		{
+			"tune request",
+			[]Packet{{0x05, 0xF6}},
+			[]Message{{0, []byte{0xF6}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var dec Decoder
+			var got []Message
+			for _, pkt := range tc.packets {
+				if msg, ok := dec.Decode(pkt); ok {
+					got = append(got, msg)
+				}
+			}
+			if !slices.EqualFunc(got, tc.want, func(a, b Message) bool {
+				return a.Cable == b.Cable && bytes.Equal(a.Data, b.Data)
+			}) {
+				t.Errorf("Decode = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSysExLimit(t *testing.T) {
+	var dec Decoder
+	dec.Decode(Packet{0x04, 0xF0, 0x01, 0x02})
+	for i := 0; i < MaxSysExLength/3; i++ {
+		dec.Decode(Packet{0x04, 0x01, 0x02, 0x03})
+	}
+	if msg, ok := dec.Decode(Packet{0x05, 0xF7}); ok {
+		t.Errorf("oversized SysEx of %d bytes was returned", len(msg.Data))
+	}
+	if _, ok := dec.Decode(Packet{0x07, 0xF0, 0x01, 0xF7}); !ok {
+		t.Error("SysEx after an oversized one was dropped")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	midi := []byte{
+		0xF0, 0x43, 0x10, 0x4C, 0x00, 0x00, 0x7E, 0x00, 0xF7,
+		0x90, 0x3C, 0x40, 0x3E, 0x40,
+		0xFE,
+		0xE0, 0x00, 0x40,
+		0xF0, 0x7E, 0xF7,
+	}
+	var dec Decoder
+	var got []byte
+	for _, pkt := range NewEncoder(5).Encode(midi) {
+		if pkt.Cable() != 5 {
+			t.Fatalf("packet %v is on cable %d", pkt, pkt.Cable())
+		}
+		if msg, ok := dec.Decode(pkt); ok {
+			got = append(got, msg.Data...)
+		}
+	}
+	// Running status is expanded on the way back.
+	want := slices.Insert(slices.Clone(midi), 12, 0x90)
+	if !bytes.Equal(got, want) {
+		t.Errorf("round trip = % x, want % x", got, want)
+	}
+}
+
+func TestPacketString(t *testing.T) {
+	if got, want := (Packet{0x29, 0x92, 0x3C, 0x40}).String(), "cable 2: 92 3c 40"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	msg := Message{Cable: 1, Data: []byte{0xF8}}
+	if got, want := msg.String(), "cable 1: f8"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
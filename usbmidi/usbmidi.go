@@ -0,0 +1,323 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package usbmidi implements USB-MIDI 1.0 devices, the MIDIStreaming subclass
+of the audio class, on top of libusb.
+
+A MIDIStreaming interface describes its MIDI IN and OUT jacks with
+class-specific descriptors and moves MIDI on a pair of bulk endpoints as
+4-byte event packets. Each packet carries one MIDI message, or three bytes
+of a SysEx message, and a cable number that picks one of the up to 16
+embedded jacks of its endpoint. FindInterfaces parses the jacks, and Open
+claims the interface, detaching the kernel's snd-usb-audio driver if it is
+bound.
+
+Device reads and writes packets directly, and converts them to and from
+MIDI: ReadMessage returns whole messages, with SysEx messages reassembled
+from their packets, and Writer returns an io.Writer that takes the MIDI
+byte stream of a cable. Encoder and Decoder do the same conversions
+without a device.
+*/
+package usbmidi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Device.Timeout that Open sets, in milliseconds. A
+// keyboard nobody plays sends nothing, so readers waiting for input may
+// want a longer one, or none.
+const DefaultTimeout = 1000
+
+// SubclassMIDIStreaming is the audio interface subclass of MIDI interfaces.
+const SubclassMIDIStreaming = 0x03
+
+// packetSize is the size of an event packet.
+const packetSize = 4
+
+// Handle is what a Device needs of a *libusb.DeviceHandle: claiming the
+// MIDIStreaming interface and moving event packets over its bulk or
+// interrupt endpoints.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	InterruptTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		length int,
+		timeout int,
+	) (int, error)
+}
+
+// Interface is a MIDIStreaming alternate setting that speaks USB-MIDI 1.0
+// event packets.
+type Interface struct {
+	Descriptor *libusb.InterfaceDescriptor
+	Streaming  *Streaming
+	// In and Out are the endpoints that carry packets from and to the
+	// device; either may be nil for a device that only sends or receives.
+	In  *libusb.EndpointDescriptor
+	Out *libusb.EndpointDescriptor
+	// InCables and OutCables hold the embedded jack ID of each cable of the
+	// In and Out endpoints, indexed by cable number.
+	InCables  []uint8
+	OutCables []uint8
+}
+
+// FindInterfaces returns the MIDIStreaming alternate settings of a
+// configuration that speak USB-MIDI 1.0. USB MIDI 2.0 alternate settings,
+// which carry Universal MIDI Packets instead, are skipped.
+func FindInterfaces(config *libusb.ConfigDescriptor) ([]*Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("usbmidi: nil configuration descriptor")
+	}
+	var found []*Interface
+	for _, desc := range config.SupportedInterfaces.GetAllInterfacesByClass(
+		libusb.InterfaceClassAudio,
+	) {
+		if desc.InterfaceSubClass != SubclassMIDIStreaming {
+			continue
+		}
+		ms, err := ParseStreaming(desc.Extra)
+		if err != nil {
+			return nil, err
+		}
+		if ms.MSCVersion >= 0x0200 {
+			continue
+		}
+		iface := &Interface{Descriptor: desc, Streaming: ms}
+		for _, ep := range desc.EndpointDescriptors {
+			if t := ep.TransferType(); t != libusb.BulkTransfer && t != libusb.InterruptTransfer {
+				continue
+			}
+			jacks, err := EndpointJacks(ep)
+			if err != nil {
+				return nil, err
+			}
+			if ep.Direction() == libusb.EndpointIn && iface.In == nil {
+				iface.In, iface.InCables = ep, jacks
+			} else if ep.Direction() == libusb.EndpointOut && iface.Out == nil {
+				iface.Out, iface.OutCables = ep, jacks
+			}
+		}
+		if iface.In != nil || iface.Out != nil {
+			found = append(found, iface)
+		}
+	}
+	return found, nil
+}
+
+// Device is a claimed MIDIStreaming interface. Reads may run concurrently
+// with writes.
+type Device struct {
+	handle    Handle
+	Interface *Interface
+	// Timeout is the transfer timeout in milliseconds. Zero waits forever.
+	Timeout int
+
+	readMu  sync.Mutex
+	readBuf []byte
+	pending []Packet
+	decoder Decoder
+
+	writeMu  sync.Mutex
+	encoders [16]*Encoder
+
+	mu     sync.Mutex
+	closed bool
+	claims *usbif.Claims
+}
+
+// Open claims a MIDIStreaming interface, taking it from snd-usb-audio if
+// that driver has it, and selects its alternate setting if it isn't the
+// default.
+func Open(handle Handle, iface *Interface) (*Device, error) {
+	if handle == nil || iface == nil || iface.Descriptor == nil {
+		return nil, fmt.Errorf("usbmidi: nil handle or interface")
+	}
+	dev := &Device{handle: handle, Interface: iface, Timeout: DefaultTimeout}
+	if iface.In != nil {
+		size := max(int(iface.In.MaxPacketSize)/packetSize*packetSize, packetSize)
+		dev.readBuf = make([]byte, size)
+	}
+	num := iface.Descriptor.InterfaceNumber
+	claims, err := usbif.Claim(handle, "usbmidi", num)
+	if err != nil {
+		return nil, err
+	}
+	dev.claims = claims
+	if alt := iface.Descriptor.AlternateSetting; alt != 0 {
+		if err := handle.SetInterfaceAltSetting(num, alt); err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("usbmidi: selecting alternate setting %d: %w", alt, err),
+				dev.Close(),
+			)
+		}
+	}
+	return dev, nil
+}
+
+// Close releases the MIDIStreaming interface and hands it back to
+// snd-usb-audio if Open took it. Notes still sounding are left to the
+// caller to turn off. Calling Close again returns os.ErrClosed.
+func (dev *Device) Close() error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closed {
+		return os.ErrClosed
+	}
+	dev.closed = true
+	return dev.claims.Release()
+}
+
+func (dev *Device) isClosed() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.closed
+}
+
+// transfer moves data on an endpoint with the transfer type it has.
+func (dev *Device) transfer(ep *libusb.EndpointDescriptor, data []byte) (int, error) {
+	if ep.TransferType() == libusb.InterruptTransfer {
+		return dev.handle.InterruptTransfer(ep.EndpointAddress, data, len(data), dev.Timeout)
+	}
+	return dev.handle.BulkTransfer(ep.EndpointAddress, data, len(data), dev.Timeout)
+}
+
+// ReadPackets reads one transfer of event packets from the IN endpoint.
+// Padding packets of all zeros are left out, so it may return none.
+func (dev *Device) ReadPackets() ([]Packet, error) {
+	if dev.isClosed() {
+		return nil, os.ErrClosed
+	}
+	if dev.Interface.In == nil {
+		return nil, fmt.Errorf("usbmidi: interface has no IN endpoint")
+	}
+	dev.readMu.Lock()
+	defer dev.readMu.Unlock()
+	return dev.readPackets()
+}
+
+func (dev *Device) readPackets() ([]Packet, error) {
+	n, err := dev.transfer(dev.Interface.In, dev.readBuf)
+	if err != nil {
+		return nil, fmt.Errorf("usbmidi: read: %w", err)
+	}
+	var packets []Packet
+	for i := 0; i+packetSize <= n; i += packetSize {
+		pkt := Packet(dev.readBuf[i : i+packetSize])
+		if pkt != (Packet{}) {
+			packets = append(packets, pkt)
+		}
+	}
+	return packets, nil
+}
+
+// ReadMessage returns the next MIDI message from any cable, reading
+// packets until one completes a message. SysEx messages are returned
+// whole. On a timeout, the packets of an unfinished SysEx message are
+// kept for the next call.
+func (dev *Device) ReadMessage() (Message, error) {
+	if dev.isClosed() {
+		return Message{}, os.ErrClosed
+	}
+	if dev.Interface.In == nil {
+		return Message{}, fmt.Errorf("usbmidi: interface has no IN endpoint")
+	}
+	dev.readMu.Lock()
+	defer dev.readMu.Unlock()
+	for {
+		for len(dev.pending) > 0 {
+			pkt := dev.pending[0]
+			dev.pending = dev.pending[1:]
+			if msg, ok := dev.decoder.Decode(pkt); ok {
+				return msg, nil
+			}
+		}
+		packets, err := dev.readPackets()
+		if err != nil {
+			return Message{}, err
+		}
+		dev.pending = packets
+	}
+}
+
+// WritePackets writes event packets to the OUT endpoint in one transfer.
+func (dev *Device) WritePackets(packets ...Packet) error {
+	if dev.isClosed() {
+		return os.ErrClosed
+	}
+	if dev.Interface.Out == nil {
+		return fmt.Errorf("usbmidi: interface has no OUT endpoint")
+	}
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+	return dev.writePackets(packets)
+}
+
+func (dev *Device) writePackets(packets []Packet) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	data := make([]byte, 0, len(packets)*packetSize)
+	for _, pkt := range packets {
+		data = append(data, pkt[:]...)
+	}
+	n, err := dev.transfer(dev.Interface.Out, data)
+	if err != nil {
+		return fmt.Errorf("usbmidi: write: %w", err)
+	}
+	if n != len(data) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// WriteMIDI writes the MIDI bytes in p to a cable. Running status and
+// unfinished messages carry over from one call to the next for the same
+// cable, so p need not hold whole messages.
+func (dev *Device) WriteMIDI(cable uint8, p []byte) (int, error) {
+	if dev.isClosed() {
+		return 0, os.ErrClosed
+	}
+	if dev.Interface.Out == nil {
+		return 0, fmt.Errorf("usbmidi: interface has no OUT endpoint")
+	}
+	if cable > 15 {
+		return 0, fmt.Errorf("usbmidi: cable %d out of range", cable)
+	}
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+	if dev.encoders[cable] == nil {
+		dev.encoders[cable] = NewEncoder(cable)
+	}
+	if err := dev.writePackets(dev.encoders[cable].Encode(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Writer returns an io.Writer that writes a MIDI byte stream to a cable.
+func (dev *Device) Writer(cable uint8) io.Writer {
+	return cableWriter{dev: dev, cable: cable}
+}
+
+type cableWriter struct {
+	dev   *Device
+	cable uint8
+}
+
+func (cw cableWriter) Write(p []byte) (int, error) {
+	return cw.dev.WriteMIDI(cw.cable, p)
+}
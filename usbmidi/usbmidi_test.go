@@ -0,0 +1,241 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package usbmidi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	// reads are returned one per IN transfer; once they run out, reads
+	// time out.
+	reads [][]byte
+	// written collects each OUT transfer.
+	written [][]byte
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	if endpoint&0x80 == 0 {
+		fh.written = append(fh.written, slices.Clone(data[:length]))
+		return length, nil
+	}
+	if len(fh.reads) == 0 {
+		return 0, libusb.ErrTimeout
+	}
+	p := fh.reads[0]
+	fh.reads = fh.reads[1:]
+	return copy(data[:length], p), nil
+}
+
+func (fh *fakeHandle) InterruptTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	fh.Record("interrupt %#02x", uint8(endpoint))
+	return fh.BulkTransfer(endpoint, data, length, timeout)
+}
+
+// testConfig returns a configuration with an AudioControl interface 0 and
+// a MIDIStreaming interface 1, whose bulk endpoints 0x01 and 0x81 carry
+// the embedded jacks 1 and 3 on cable 0. With midi2 set, the interface
+// also has a USB MIDI 2.0 alternate setting.
+func testConfig(midi2 bool) *libusb.ConfigDescriptor {
+	streaming := libusb.InterfaceDescriptors{{
+		InterfaceNumber:   1,
+		InterfaceClass:    libusb.InterfaceClassAudio,
+		InterfaceSubClass: SubclassMIDIStreaming,
+		Extra:             testStreaming(0x0100),
+		EndpointDescriptors: libusb.EndpointDescriptors{
+			{EndpointAddress: 0x01, Attributes: 0x02, MaxPacketSize: 64, Extra: endpointJacks(1)},
+			{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 64, Extra: endpointJacks(3)},
+		},
+	}}
+	if midi2 {
+		streaming = append(streaming, &libusb.InterfaceDescriptor{
+			InterfaceNumber:   1,
+			AlternateSetting:  1,
+			InterfaceClass:    libusb.InterfaceClassAudio,
+			InterfaceSubClass: SubclassMIDIStreaming,
+			Extra:             desc(msHeader, 0x00, 0x02, 0x07, 0x00),
+		})
+	}
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{{
+				InterfaceClass:    libusb.InterfaceClassAudio,
+				InterfaceSubClass: 0x01,
+			}}},
+			{InterfaceDescriptors: streaming},
+		},
+	}
+}
+
+func openTestDevice(t *testing.T, fh *fakeHandle) *Device {
+	t.Helper()
+	ifaces, err := FindInterfaces(testConfig(false))
+	if err != nil || len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces = %d interfaces, %v", len(ifaces), err)
+	}
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return dev
+}
+
+func TestFindInterfaces(t *testing.T) {
+	ifaces, err := FindInterfaces(testConfig(true))
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	if len(ifaces) != 1 {
+		t.Fatalf("FindInterfaces found %d interfaces, want 1", len(ifaces))
+	}
+	iface := ifaces[0]
+	if iface.Descriptor.InterfaceNumber != 1 || iface.In.EndpointAddress != 0x81 ||
+		iface.Out.EndpointAddress != 0x01 {
+		t.Errorf("interface = %+v", iface)
+	}
+	if !slices.Equal(iface.OutCables, []uint8{1}) || !slices.Equal(iface.InCables, []uint8{3}) {
+		t.Errorf("cables = %v %v", iface.OutCables, iface.InCables)
+	}
+
+	config := testConfig(false)
+	config.SupportedInterfaces[1].InterfaceDescriptors[0].EndpointDescriptors[0].Extra = nil
+	if _, err := FindInterfaces(config); err == nil {
+		t.Error("endpoint without MS_GENERAL: expected error, got nil")
+	}
+	if _, err := FindInterfaces(nil); err == nil {
+		t.Error("nil configuration: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	dev := openTestDevice(t, fh)
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{"detach 1", "claim 1", "release 1", "attach 1"}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := dev.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.ReadMessage(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ReadMessage after Close: got %v, want os.ErrClosed", err)
+	}
+	if _, err := dev.WriteMIDI(0, []byte{0xF8}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WriteMIDI after Close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	fh := &fakeHandle{reads: [][]byte{
+		{0x09, 0x90, 0x3C, 0x40, 0x14, 0xF0, 0x7E, 0x7F, 0, 0, 0, 0},
+		{},
+		{0x1F, 0xF8, 0, 0, 0x16, 0x09, 0xF7, 0},
+	}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	want := []Message{
+		{0, []byte{0x90, 0x3C, 0x40}},
+		{1, []byte{0xF8}},
+		{1, []byte{0xF0, 0x7E, 0x7F, 0x09, 0xF7}},
+	}
+	for _, w := range want {
+		msg, err := dev.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage: unexpected error %v", err)
+		}
+		if msg.Cable != w.Cable || !bytes.Equal(msg.Data, w.Data) {
+			t.Errorf("ReadMessage = %v, want %v", msg, w)
+		}
+	}
+	var timeout interface{ Timeout() bool }
+	if _, err := dev.ReadMessage(); !errors.As(err, &timeout) || !timeout.Timeout() {
+		t.Errorf("ReadMessage with nothing to read: got %v, want a timeout", err)
+	}
+}
+
+func TestReadPackets(t *testing.T) {
+	fh := &fakeHandle{reads: [][]byte{{0x0B, 0xB0, 0x07, 0x64, 0, 0, 0, 0, 0x0F}}}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+	packets, err := dev.ReadPackets()
+	if err != nil || !slices.Equal(packets, []Packet{{0x0B, 0xB0, 0x07, 0x64}}) {
+		t.Errorf("ReadPackets = %v, %v", packets, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	fh := &fakeHandle{}
+	dev := openTestDevice(t, fh)
+	defer dev.Close()
+
+	if err := dev.WritePackets(Packet{0x0F, 0xFA}, Packet{0x09, 0x90, 0x3C, 0x40}); err != nil {
+		t.Fatalf("WritePackets: unexpected error %v", err)
+	}
+	w := dev.Writer(1)
+	for _, p := range [][]byte{{0x91, 0x3C}, {0x40, 0x3E, 0x40}, {0xF0, 0x01}, {0xF7}} {
+		if n, err := w.Write(p); err != nil || n != len(p) {
+			t.Fatalf("Write(% x) = %d, %v", p, n, err)
+		}
+	}
+	if _, err := dev.WriteMIDI(16, []byte{0xF8}); err == nil {
+		t.Error("WriteMIDI to cable 16: expected error, got nil")
+	}
+	want := [][]byte{
+		{0x0F, 0xFA, 0, 0, 0x09, 0x90, 0x3C, 0x40},
+		{0x19, 0x91, 0x3C, 0x40, 0x19, 0x91, 0x3E, 0x40},
+		{0x17, 0xF0, 0x01, 0xF7},
+	}
+	if !slices.EqualFunc(fh.written, want, bytes.Equal) {
+		t.Errorf("written = % x, want % x", fh.written, want)
+	}
+}
+
+func TestInterruptEndpoints(t *testing.T) {
+	fh := &fakeHandle{reads: [][]byte{{0x0C, 0xC0, 0x05, 0}}}
+	ifaces, err := FindInterfaces(testConfig(false))
+	if err != nil {
+		t.Fatalf("FindInterfaces: unexpected error %v", err)
+	}
+	ifaces[0].In.Attributes = 0x03
+	dev, err := Open(fh, ifaces[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	defer dev.Close()
+	if msg, err := dev.ReadMessage(); err != nil || !bytes.Equal(msg.Data, []byte{0xC0, 0x05}) {
+		t.Errorf("ReadMessage = %v, %v", msg, err)
+	}
+	if !slices.Contains(fh.Calls(), "interrupt 0x81") {
+		t.Errorf("calls = %q, want an interrupt transfer", fh.Calls())
+	}
+}
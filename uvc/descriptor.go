@@ -0,0 +1,504 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+// descriptorTypeCSInterface is the type of class-specific interface
+// descriptors.
+const descriptorTypeCSInterface = 0x24
+
+// VideoControl interface descriptor subtypes.
+const (
+	vcHeader         = 0x01
+	vcInputTerminal  = 0x02
+	vcOutputTerminal = 0x03
+	vcSelectorUnit   = 0x04
+	vcProcessingUnit = 0x05
+	vcExtensionUnit  = 0x06
+	vcEncodingUnit   = 0x07
+)
+
+// VideoStreaming interface descriptor subtypes.
+const (
+	vsInputHeader        = 0x01
+	vsFormatUncompressed = 0x04
+	vsFrameUncompressed  = 0x05
+	vsFormatMJPEG        = 0x06
+	vsFrameMJPEG         = 0x07
+	vsFormatFrameBased   = 0x10
+	vsFrameFrameBased    = 0x11
+)
+
+const (
+	vcHeaderSize           = 12
+	vsInputHeaderSize      = 13
+	formatUncompressedSize = 27
+	formatMJPEGSize        = 11
+	formatFrameBasedSize   = 28
+	frameSize              = 26
+	frameIntervalsOffset   = 26
+	// frameBasedIntervalType is the offset of bFrameIntervalType in
+	// frame-based frame descriptors.
+	frameBasedIntervalType = 21
+)
+
+// TerminalCamera is the wTerminalType of a camera input terminal.
+const TerminalCamera = 0x0201
+
+// Interval is a frame interval in the 100 ns units UVC uses.
+type Interval uint32
+
+// Duration returns the interval as a time.Duration.
+func (iv Interval) Duration() time.Duration {
+	return time.Duration(iv) * 100 * time.Nanosecond
+}
+
+// FPS returns the frame rate of the interval.
+func (iv Interval) FPS() float64 {
+	if iv == 0 {
+		return 0
+	}
+	return 1e7 / float64(iv)
+}
+
+// String implements the Stringer interface for Interval.
+func (iv Interval) String() string {
+	return fmt.Sprintf("%.4g fps", iv.FPS())
+}
+
+// VideoControl models the class-specific descriptors of a VideoControl
+// interface: the header and the terminals and units that make up the
+// function's topology.
+type VideoControl struct {
+	// UVCVersion is the bcdUVC of the header, such as 0x0110 for UVC 1.1.
+	UVCVersion     uint16
+	ClockFrequency uint32
+	// StreamingInterfaces are the numbers of the VideoStreaming interfaces
+	// of the function.
+	StreamingInterfaces []int
+	InputTerminals      []InputTerminal
+	OutputTerminals     []OutputTerminal
+	Units               []Unit
+}
+
+// InputTerminal is an input terminal, usually the camera sensor.
+type InputTerminal struct {
+	ID           uint8
+	TerminalType uint16
+	// Controls is the bmControls bitmap of a camera terminal, nil for other
+	// terminal types.
+	Controls []byte
+}
+
+// OutputTerminal is an output terminal, usually the USB streaming
+// terminal that a VideoStreaming interface links to.
+type OutputTerminal struct {
+	ID           uint8
+	TerminalType uint16
+	SourceID     uint8
+}
+
+// Unit is a selector, processing, extension, or encoding unit.
+type Unit struct {
+	ID uint8
+	// Subtype is the descriptor subtype, which tells the kind of unit.
+	Subtype   uint8
+	SourceIDs []uint8
+	// Controls is the unit's bmControls bitmap.
+	Controls []byte
+	// GUID is the guidExtensionCode of an extension unit.
+	GUID [16]byte
+}
+
+// ParseVideoControl parses the class-specific descriptors that follow a
+// VideoControl interface descriptor, as found in its Extra bytes.
+func ParseVideoControl(extra []byte) (*VideoControl, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("uvc: %w", err)
+	}
+	var vc *VideoControl
+	for _, desc := range descs {
+		if len(desc) < 4 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		if desc[2] == vcHeader {
+			if vc, err = parseVCHeader(desc); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if vc == nil {
+			return nil, fmt.Errorf(
+				"uvc: VideoControl descriptor subtype %#02x before the header",
+				desc[2],
+			)
+		}
+		if err := vc.parseEntity(desc); err != nil {
+			return nil, err
+		}
+	}
+	if vc == nil {
+		return nil, fmt.Errorf("uvc: no VideoControl header descriptor")
+	}
+	return vc, nil
+}
+
+func parseVCHeader(desc []byte) (*VideoControl, error) {
+	if len(desc) < vcHeaderSize || len(desc) < vcHeaderSize+int(desc[11]) {
+		return nil, fmt.Errorf("uvc: VideoControl header is %d bytes", len(desc))
+	}
+	vc := &VideoControl{
+		UVCVersion:     binary.LittleEndian.Uint16(desc[3:5]),
+		ClockFrequency: binary.LittleEndian.Uint32(desc[7:11]),
+	}
+	for _, num := range desc[vcHeaderSize : vcHeaderSize+int(desc[11])] {
+		vc.StreamingInterfaces = append(vc.StreamingInterfaces, int(num))
+	}
+	return vc, nil
+}
+
+// entityLengths are the minimum lengths of the terminal and unit
+// descriptors, up to the fields parseEntity reads unconditionally.
+var entityLengths = map[uint8]int{
+	vcInputTerminal:  8,
+	vcOutputTerminal: 9,
+	vcSelectorUnit:   5,
+	vcProcessingUnit: 8,
+	vcExtensionUnit:  22,
+	vcEncodingUnit:   5,
+}
+
+func (vc *VideoControl) parseEntity(desc []byte) error {
+	subtype := desc[2]
+	want, ok := entityLengths[subtype]
+	if !ok {
+		return nil
+	}
+	if len(desc) < want {
+		return fmt.Errorf(
+			"uvc: VideoControl descriptor subtype %#02x is %d bytes; want at least %d",
+			subtype,
+			len(desc),
+			want,
+		)
+	}
+	id := desc[3]
+	switch subtype {
+	case vcInputTerminal:
+		it := InputTerminal{ID: id, TerminalType: binary.LittleEndian.Uint16(desc[4:6])}
+		if it.TerminalType == TerminalCamera && len(desc) >= 15 {
+			it.Controls = bitmap(desc, 14)
+		}
+		vc.InputTerminals = append(vc.InputTerminals, it)
+	case vcOutputTerminal:
+		vc.OutputTerminals = append(vc.OutputTerminals, OutputTerminal{
+			ID:           id,
+			TerminalType: binary.LittleEndian.Uint16(desc[4:6]),
+			SourceID:     desc[7],
+		})
+	case vcSelectorUnit:
+		pins := int(desc[4])
+		if len(desc) < 5+pins {
+			return fmt.Errorf("uvc: selector unit %d is too short for %d inputs", id, pins)
+		}
+		vc.Units = append(vc.Units, Unit{ID: id, Subtype: subtype, SourceIDs: desc[5 : 5+pins]})
+	case vcProcessingUnit:
+		vc.Units = append(vc.Units, Unit{
+			ID:        id,
+			Subtype:   subtype,
+			SourceIDs: desc[4:5],
+			Controls:  bitmap(desc, 7),
+		})
+	case vcExtensionUnit:
+		pins := int(desc[21])
+		if len(desc) < 23+pins {
+			return fmt.Errorf("uvc: extension unit %d is too short for %d inputs", id, pins)
+		}
+		unit := Unit{
+			ID:        id,
+			Subtype:   subtype,
+			SourceIDs: desc[22 : 22+pins],
+			Controls:  bitmap(desc, 22+pins),
+		}
+		copy(unit.GUID[:], desc[4:20])
+		vc.Units = append(vc.Units, unit)
+	case vcEncodingUnit:
+		vc.Units = append(vc.Units, Unit{ID: id, Subtype: subtype, SourceIDs: desc[4:5]})
+	}
+	return nil
+}
+
+// bitmap returns the bmControls that follows the bControlSize at offset, or
+// nil if the descriptor is too short to hold it.
+func bitmap(desc []byte, offset int) []byte {
+	if offset >= len(desc) {
+		return nil
+	}
+	size := int(desc[offset])
+	if offset+1+size > len(desc) {
+		return nil
+	}
+	return desc[offset+1 : offset+1+size]
+}
+
+// FormatType is the descriptor subtype of a video format.
+type FormatType uint8
+
+// Video formats this package parses.
+const (
+	FormatUncompressed FormatType = vsFormatUncompressed
+	FormatMJPEG        FormatType = vsFormatMJPEG
+	FormatFrameBased   FormatType = vsFormatFrameBased
+)
+
+var formatTypes = map[FormatType]string{
+	FormatUncompressed: "uncompressed",
+	FormatMJPEG:        "MJPEG",
+	FormatFrameBased:   "frame-based",
+}
+
+// String implements the Stringer interface for FormatType.
+func (ft FormatType) String() string {
+	if s, ok := formatTypes[ft]; ok {
+		return s
+	}
+	return fmt.Sprintf("format type %#02x", uint8(ft))
+}
+
+// Streaming models the class-specific descriptors of a VideoStreaming
+// interface: the input header and the formats with their frames.
+type Streaming struct {
+	// EndpointAddress is the endpoint video data arrives on.
+	EndpointAddress libusb.EndpointAddress
+	// TerminalLink is the ID of the output terminal the interface is
+	// connected to.
+	TerminalLink       uint8
+	StillCaptureMethod uint8
+	Formats            []*Format
+}
+
+// Format is a video format and the frame sizes it is offered in.
+type Format struct {
+	Type  FormatType
+	Index uint8
+	// GUID identifies the pixel format of uncompressed and frame-based
+	// formats; its first four bytes are the FOURCC.
+	GUID              [16]byte
+	BitsPerPixel      uint8
+	DefaultFrameIndex uint8
+	Frames            []*Frame
+}
+
+// FourCC returns the four character code of the format, such as "YUY2",
+// "NV12", "H264", or "MJPG".
+func (f *Format) FourCC() string {
+	if f.Type == FormatMJPEG {
+		return "MJPG"
+	}
+	return string(f.GUID[:4])
+}
+
+// Frame returns the frame descriptor with the given index, or nil.
+func (f *Format) Frame(index uint8) *Frame {
+	for _, frame := range f.Frames {
+		if frame.Index == index {
+			return frame
+		}
+	}
+	return nil
+}
+
+// String implements the Stringer interface for Format.
+func (f *Format) String() string {
+	return fmt.Sprintf("%d: %s (%v)", f.Index, f.FourCC(), f.Type)
+}
+
+// Frame is a frame size of a format and the intervals it can be streamed
+// at. Discrete intervals are listed in Intervals; a continuous range has
+// no Intervals but MinInterval, MaxInterval, and IntervalStep.
+type Frame struct {
+	Index  uint8
+	Width  uint16
+	Height uint16
+	// MaxFrameSize is the dwMaxVideoFrameBufferSize, zero for frame-based
+	// formats.
+	MaxFrameSize    uint32
+	DefaultInterval Interval
+	Intervals       []Interval
+	MinInterval     Interval
+	MaxInterval     Interval
+	IntervalStep    Interval
+}
+
+// String implements the Stringer interface for Frame.
+func (fr *Frame) String() string {
+	return fmt.Sprintf("%d: %dx%d", fr.Index, fr.Width, fr.Height)
+}
+
+// Supports reports whether the frame can be streamed at the interval.
+func (fr *Frame) Supports(iv Interval) bool {
+	if len(fr.Intervals) > 0 {
+		for _, discrete := range fr.Intervals {
+			if discrete == iv {
+				return true
+			}
+		}
+		return false
+	}
+	if iv < fr.MinInterval || iv > fr.MaxInterval {
+		return false
+	}
+	return fr.IntervalStep == 0 || (iv-fr.MinInterval)%fr.IntervalStep == 0
+}
+
+// ParseStreaming parses the class-specific descriptors that follow a
+// VideoStreaming interface descriptor, as found in the Extra bytes of its
+// alternate setting 0. Formats other than uncompressed, MJPEG, and
+// frame-based, and the frames of skipped formats, are ignored.
+func ParseStreaming(extra []byte) (*Streaming, error) {
+	descs, err := libusb.SplitDescriptors(extra)
+	if err != nil {
+		return nil, fmt.Errorf("uvc: %w", err)
+	}
+	var vs *Streaming
+	var format *Format
+	for _, desc := range descs {
+		if len(desc) < 4 || desc[1] != descriptorTypeCSInterface {
+			continue
+		}
+		subtype := desc[2]
+		if subtype == vsInputHeader {
+			if len(desc) < vsInputHeaderSize {
+				return nil, fmt.Errorf("uvc: VideoStreaming input header is %d bytes", len(desc))
+			}
+			vs = &Streaming{
+				EndpointAddress:    libusb.EndpointAddress(desc[6]),
+				TerminalLink:       desc[8],
+				StillCaptureMethod: desc[9],
+			}
+			continue
+		}
+		if vs == nil {
+			return nil, fmt.Errorf(
+				"uvc: VideoStreaming descriptor subtype %#02x before the input header",
+				subtype,
+			)
+		}
+		switch subtype {
+		case vsFormatUncompressed, vsFormatMJPEG, vsFormatFrameBased:
+			if format, err = parseFormat(desc); err != nil {
+				return nil, err
+			}
+			vs.Formats = append(vs.Formats, format)
+		case vsFrameUncompressed, vsFrameMJPEG, vsFrameFrameBased:
+			if format == nil || subtype != uint8(format.Type)+1 {
+				// A frame of a format this package doesn't parse.
+				continue
+			}
+			frame, err := parseFrame(desc)
+			if err != nil {
+				return nil, err
+			}
+			format.Frames = append(format.Frames, frame)
+		default:
+			// Still image frames, color matching, and other formats end the
+			// frames of the current format.
+			format = nil
+		}
+	}
+	if vs == nil {
+		return nil, fmt.Errorf("uvc: no VideoStreaming input header descriptor")
+	}
+	return vs, nil
+}
+
+func parseFormat(desc []byte) (*Format, error) {
+	ft := FormatType(desc[2])
+	want := map[FormatType]int{
+		FormatUncompressed: formatUncompressedSize,
+		FormatMJPEG:        formatMJPEGSize,
+		FormatFrameBased:   formatFrameBasedSize,
+	}[ft]
+	if len(desc) < want {
+		return nil, fmt.Errorf(
+			"uvc: %v format descriptor is %d bytes; want at least %d",
+			ft,
+			len(desc),
+			want,
+		)
+	}
+	format := &Format{Type: ft, Index: desc[3]}
+	if ft == FormatMJPEG {
+		format.DefaultFrameIndex = desc[6]
+		return format, nil
+	}
+	copy(format.GUID[:], desc[5:21])
+	format.BitsPerPixel = desc[21]
+	format.DefaultFrameIndex = desc[22]
+	return format, nil
+}
+
+func parseFrame(desc []byte) (*Frame, error) {
+	if len(desc) < frameSize {
+		return nil, fmt.Errorf(
+			"uvc: frame descriptor is %d bytes; want at least %d",
+			len(desc),
+			frameSize,
+		)
+	}
+	frame := &Frame{
+		Index:  desc[3],
+		Width:  binary.LittleEndian.Uint16(desc[5:7]),
+		Height: binary.LittleEndian.Uint16(desc[7:9]),
+	}
+	// The frame-based frame has no dwMaxVideoFrameBufferSize, so its
+	// interval fields start four bytes earlier, and it has dwBytesPerLine
+	// before the intervals.
+	intervalType := int(desc[frameIntervalsOffset-1])
+	if desc[2] == vsFrameFrameBased {
+		frame.DefaultInterval = Interval(binary.LittleEndian.Uint32(desc[17:21]))
+		intervalType = int(desc[frameBasedIntervalType])
+	} else {
+		frame.MaxFrameSize = binary.LittleEndian.Uint32(desc[17:21])
+		frame.DefaultInterval = Interval(binary.LittleEndian.Uint32(desc[21:25]))
+	}
+	count := intervalType
+	if intervalType == 0 {
+		count = 3
+	}
+	if len(desc) < frameIntervalsOffset+4*count {
+		return nil, fmt.Errorf(
+			"uvc: frame %d descriptor is too short for %d intervals",
+			frame.Index,
+			count,
+		)
+	}
+	intervals := make([]Interval, count)
+	for i := range intervals {
+		offset := frameIntervalsOffset + 4*i
+		intervals[i] = Interval(binary.LittleEndian.Uint32(desc[offset : offset+4]))
+	}
+	if intervalType == 0 {
+		frame.MinInterval, frame.MaxInterval, frame.IntervalStep =
+			intervals[0], intervals[1], intervals[2]
+	} else {
+		frame.Intervals = intervals
+		frame.MinInterval, frame.MaxInterval = intervals[0], intervals[0]
+		for _, iv := range intervals {
+			frame.MinInterval = min(frame.MinInterval, iv)
+			frame.MaxInterval = max(frame.MaxInterval, iv)
+		}
+	}
+	return frame, nil
+}
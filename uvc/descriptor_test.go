@@ -0,0 +1,186 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+// le32 returns v as 4 little-endian bytes.
+func le32(v uint32) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+}
+
+// desc prefixes the body of a class-specific interface descriptor with its
+// bLength and bDescriptorType.
+func desc(body ...[]byte) []byte {
+	data := []byte{0, descriptorTypeCSInterface}
+	for _, b := range body {
+		data = append(data, b...)
+	}
+	data[0] = byte(len(data))
+	return data
+}
+
+func testVideoControl() []byte {
+	var extra []byte
+	// Header, UVC 1.1, 48 MHz clock, streaming interface 1.
+	extra = append(extra, desc([]byte{vcHeader, 0x10, 0x01, 0, 0}, le32(48000000),
+		[]byte{1, 1})...)
+	// Camera terminal 1 with auto exposure and focus controls.
+	extra = append(extra, desc([]byte{vcInputTerminal, 1, 0x01, 0x02, 0, 0},
+		make([]byte, 6), []byte{3, 0x0A, 0x00, 0x02})...)
+	// Processing unit 2 fed by the camera.
+	extra = append(extra, desc([]byte{vcProcessingUnit, 2, 1, 0, 0, 2, 0x3F, 0x00, 0})...)
+	// Extension unit 3 fed by the processing unit.
+	extra = append(extra, desc([]byte{vcExtensionUnit, 3}, []byte("0123456789abcdef"),
+		[]byte{1, 1, 2, 1, 0x01, 0})...)
+	// USB streaming terminal 4 fed by the extension unit.
+	extra = append(extra, desc([]byte{vcOutputTerminal, 4, 0x01, 0x01, 0, 3, 0})...)
+	return extra
+}
+
+func testStreaming() []byte {
+	var extra []byte
+	// Input header with two formats on endpoint 0x81, linked to terminal 4.
+	extra = append(extra, desc([]byte{vsInputHeader, 2, 0, 0, 0x81, 0, 4, 1, 0, 0, 1, 0, 0})...)
+	// YUY2 with one 640x480 frame at 30 or 15 fps.
+	guid := []byte("YUY2\x00\x00\x10\x00\x80\x00\x00\xAA\x00\x38\x9B\x71")
+	extra = append(extra, desc([]byte{vsFormatUncompressed, 1, 1}, guid,
+		[]byte{16, 1, 0, 0, 0, 0})...)
+	extra = append(extra, desc([]byte{vsFrameUncompressed, 1, 0, 0x80, 0x02, 0xE0, 0x01},
+		le32(0), le32(0), le32(640*480*2), le32(333333), []byte{2},
+		le32(333333), le32(666666))...)
+	// A still image frame, which is skipped.
+	extra = append(extra, desc([]byte{0x03, 0, 1, 0x80, 0x02, 0xE0, 0x01, 0})...)
+	// MJPEG with one 1280x720 frame at 5 to 30 fps in steps of 1/30 s.
+	extra = append(extra, desc([]byte{vsFormatMJPEG, 2, 1, 1, 1, 0, 0, 0, 0})...)
+	extra = append(extra, desc([]byte{vsFrameMJPEG, 1, 0, 0x00, 0x05, 0xD0, 0x02},
+		le32(0), le32(0), le32(1280*720*2), le32(333333), []byte{0},
+		le32(333333), le32(2000000), le32(333333))...)
+	// Color matching.
+	extra = append(extra, desc([]byte{0x0D, 1, 1, 4})...)
+	return extra
+}
+
+func TestParseVideoControl(t *testing.T) {
+	vc, err := ParseVideoControl(testVideoControl())
+	if err != nil {
+		t.Fatalf("ParseVideoControl: unexpected error %v", err)
+	}
+	if vc.UVCVersion != 0x0110 || vc.ClockFrequency != 48000000 ||
+		!slices.Equal(vc.StreamingInterfaces, []int{1}) {
+		t.Errorf("header = %+v", vc)
+	}
+	if len(vc.InputTerminals) != 1 || vc.InputTerminals[0].TerminalType != TerminalCamera ||
+		!bytes.Equal(vc.InputTerminals[0].Controls, []byte{0x0A, 0x00, 0x02}) {
+		t.Errorf("input terminals = %+v", vc.InputTerminals)
+	}
+	if len(vc.OutputTerminals) != 1 || vc.OutputTerminals[0].SourceID != 3 {
+		t.Errorf("output terminals = %+v", vc.OutputTerminals)
+	}
+	if len(vc.Units) != 2 {
+		t.Fatalf("got %d units, want 2", len(vc.Units))
+	}
+	pu, xu := vc.Units[0], vc.Units[1]
+	if pu.ID != 2 || !bytes.Equal(pu.SourceIDs, []byte{1}) ||
+		!bytes.Equal(pu.Controls, []byte{0x3F, 0x00}) {
+		t.Errorf("processing unit = %+v", pu)
+	}
+	if xu.ID != 3 || string(xu.GUID[:]) != "0123456789abcdef" ||
+		!bytes.Equal(xu.SourceIDs, []byte{2}) || !bytes.Equal(xu.Controls, []byte{0x01}) {
+		t.Errorf("extension unit = %+v", xu)
+	}
+}
+
+func TestParseStreaming(t *testing.T) {
+	vs, err := ParseStreaming(testStreaming())
+	if err != nil {
+		t.Fatalf("ParseStreaming: unexpected error %v", err)
+	}
+	if vs.EndpointAddress != 0x81 || vs.TerminalLink != 4 || len(vs.Formats) != 2 {
+		t.Fatalf("streaming = %+v", vs)
+	}
+	yuy2, mjpeg := vs.Formats[0], vs.Formats[1]
+	if yuy2.FourCC() != "YUY2" || yuy2.BitsPerPixel != 16 || len(yuy2.Frames) != 1 {
+		t.Errorf("uncompressed format = %v %+v", yuy2, yuy2)
+	}
+	vga := yuy2.Frame(1)
+	if vga == nil || vga.Width != 640 || vga.Height != 480 || vga.MaxFrameSize != 640*480*2 {
+		t.Fatalf("uncompressed frame = %+v", vga)
+	}
+	if !slices.Equal(vga.Intervals, []Interval{333333, 666666}) ||
+		vga.MinInterval != 333333 || vga.MaxInterval != 666666 {
+		t.Errorf("discrete intervals = %v, %v..%v", vga.Intervals, vga.MinInterval, vga.MaxInterval)
+	}
+	if !vga.Supports(666666) || vga.Supports(500000) {
+		t.Error("Supports decoded the discrete intervals wrong")
+	}
+
+	if mjpeg.FourCC() != "MJPG" || mjpeg.String() != "2: MJPG (MJPEG)" {
+		t.Errorf("MJPEG format = %v", mjpeg)
+	}
+	hd := mjpeg.Frame(1)
+	if hd == nil || hd.Width != 1280 || hd.Height != 720 || hd.Intervals != nil {
+		t.Fatalf("MJPEG frame = %+v", hd)
+	}
+	if hd.MinInterval != 333333 || hd.MaxInterval != 2000000 || hd.IntervalStep != 333333 {
+		t.Errorf("continuous intervals = %v..%v step %v",
+			hd.MinInterval, hd.MaxInterval, hd.IntervalStep)
+	}
+	if !hd.Supports(666666) || hd.Supports(400000) || hd.Supports(2333333) {
+		t.Error("Supports decoded the continuous intervals wrong")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	vc := testVideoControl()
+	testCases := []struct {
+		name  string
+		parse func([]byte) error
+		given []byte
+	}{
+		{"no header", parseVC, vc[13:]},
+		{"truncated descriptor", parseVC, vc[:len(vc)-1]},
+		{"short terminal", parseVC, append(vc[:13:13], desc([]byte{vcOutputTerminal, 4})...)},
+		{"no input header", parseVS, desc([]byte{vsFormatMJPEG, 2, 1, 1, 1, 0, 0, 0, 0})},
+		{
+			"short frame",
+			parseVS,
+			append(desc([]byte{vsInputHeader, 1, 0, 0, 0x81, 0, 4, 1, 0, 0, 1, 0}),
+				append(desc([]byte{vsFormatMJPEG, 1, 1, 1, 1, 0, 0, 0, 0}),
+					desc([]byte{vsFrameMJPEG, 1, 0, 0, 5})...)...),
+		},
+	}
+	for _, tc := range testCases {
+		if err := tc.parse(tc.given); err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+	}
+}
+
+func parseVC(data []byte) error {
+	_, err := ParseVideoControl(data)
+	return err
+}
+
+func parseVS(data []byte) error {
+	_, err := ParseStreaming(data)
+	return err
+}
+
+func TestInterval(t *testing.T) {
+	iv := Interval(333333)
+	if iv.Duration() != 33333300*time.Nanosecond {
+		t.Errorf("Duration() = %v", iv.Duration())
+	}
+	if got := iv.String(); got != "30 fps" {
+		t.Errorf("String() = %q, want %q", got, "30 fps")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Sizes of the video probe and commit control for each UVC version.
+const (
+	streamControlSize10 = 26
+	streamControlSize11 = 34
+	streamControlSize15 = 48
+)
+
+// HintFrameInterval is the bmHint bit asking the device to keep
+// FrameInterval fixed while negotiating the other fields.
+const HintFrameInterval = 0x0001
+
+// StreamControl is the video probe and commit control, the parameters a
+// host and a VideoStreaming interface agree on before streaming starts.
+// The fields after MaxPayloadTransferSize were added in UVC 1.1 and 1.5
+// and are only sent to devices of those versions.
+type StreamControl struct {
+	Hint                   uint16
+	FormatIndex            uint8
+	FrameIndex             uint8
+	FrameInterval          Interval
+	KeyFrameRate           uint16
+	PFrameRate             uint16
+	CompQuality            uint16
+	CompWindowSize         uint16
+	Delay                  uint16
+	MaxVideoFrameSize      uint32
+	MaxPayloadTransferSize uint32
+
+	ClockFrequency   uint32
+	FramingInfo      uint8
+	PreferredVersion uint8
+	MinVersion       uint8
+	MaxVersion       uint8
+
+	Usage            uint8
+	BitDepthLuma     uint8
+	Settings         uint8
+	MaxRefFrames     uint8
+	RateControlModes uint16
+	LayoutPerStream  uint64
+}
+
+// streamControlSize returns the length of the probe and commit control
+// for a bcdUVC.
+func streamControlSize(uvcVersion uint16) int {
+	switch {
+	case uvcVersion >= 0x0150:
+		return streamControlSize15
+	case uvcVersion >= 0x0110:
+		return streamControlSize11
+	default:
+		return streamControlSize10
+	}
+}
+
+// marshal encodes the control at the given length, one of the
+// streamControlSize constants.
+func (sc *StreamControl) marshal(size int) []byte {
+	data := make([]byte, size)
+	binary.LittleEndian.PutUint16(data[0:2], sc.Hint)
+	data[2] = sc.FormatIndex
+	data[3] = sc.FrameIndex
+	binary.LittleEndian.PutUint32(data[4:8], uint32(sc.FrameInterval))
+	binary.LittleEndian.PutUint16(data[8:10], sc.KeyFrameRate)
+	binary.LittleEndian.PutUint16(data[10:12], sc.PFrameRate)
+	binary.LittleEndian.PutUint16(data[12:14], sc.CompQuality)
+	binary.LittleEndian.PutUint16(data[14:16], sc.CompWindowSize)
+	binary.LittleEndian.PutUint16(data[16:18], sc.Delay)
+	binary.LittleEndian.PutUint32(data[18:22], sc.MaxVideoFrameSize)
+	binary.LittleEndian.PutUint32(data[22:26], sc.MaxPayloadTransferSize)
+	if size >= streamControlSize11 {
+		binary.LittleEndian.PutUint32(data[26:30], sc.ClockFrequency)
+		data[30] = sc.FramingInfo
+		data[31] = sc.PreferredVersion
+		data[32] = sc.MinVersion
+		data[33] = sc.MaxVersion
+	}
+	if size >= streamControlSize15 {
+		data[34] = sc.Usage
+		data[35] = sc.BitDepthLuma
+		data[36] = sc.Settings
+		data[37] = sc.MaxRefFrames
+		binary.LittleEndian.PutUint16(data[38:40], sc.RateControlModes)
+		binary.LittleEndian.PutUint64(data[40:48], sc.LayoutPerStream)
+	}
+	return data
+}
+
+// ParseStreamControl decodes a probe or commit control of any UVC
+// version. Fields the data is too short for are left zero.
+func ParseStreamControl(data []byte) (*StreamControl, error) {
+	if len(data) < streamControlSize10 {
+		return nil, fmt.Errorf(
+			"uvc: probe control is %d bytes; want at least %d",
+			len(data),
+			streamControlSize10,
+		)
+	}
+	sc := &StreamControl{
+		Hint:                   binary.LittleEndian.Uint16(data[0:2]),
+		FormatIndex:            data[2],
+		FrameIndex:             data[3],
+		FrameInterval:          Interval(binary.LittleEndian.Uint32(data[4:8])),
+		KeyFrameRate:           binary.LittleEndian.Uint16(data[8:10]),
+		PFrameRate:             binary.LittleEndian.Uint16(data[10:12]),
+		CompQuality:            binary.LittleEndian.Uint16(data[12:14]),
+		CompWindowSize:         binary.LittleEndian.Uint16(data[14:16]),
+		Delay:                  binary.LittleEndian.Uint16(data[16:18]),
+		MaxVideoFrameSize:      binary.LittleEndian.Uint32(data[18:22]),
+		MaxPayloadTransferSize: binary.LittleEndian.Uint32(data[22:26]),
+	}
+	if len(data) >= streamControlSize11 {
+		sc.ClockFrequency = binary.LittleEndian.Uint32(data[26:30])
+		sc.FramingInfo = data[30]
+		sc.PreferredVersion = data[31]
+		sc.MinVersion = data[32]
+		sc.MaxVersion = data[33]
+	}
+	if len(data) >= streamControlSize15 {
+		sc.Usage = data[34]
+		sc.BitDepthLuma = data[35]
+		sc.Settings = data[36]
+		sc.MaxRefFrames = data[37]
+		sc.RateControlModes = binary.LittleEndian.Uint16(data[38:40])
+		sc.LayoutPerStream = binary.LittleEndian.Uint64(data[40:48])
+	}
+	return sc, nil
+}
+
+// Probe sends ctrl as the probe control of a VideoStreaming interface and
+// returns the values the device settled on, which may differ from those
+// asked for. The device is not streaming until the values are committed.
+func (cam *Camera) Probe(si *StreamInterface, ctrl *StreamControl) (*StreamControl, error) {
+	if err := cam.setStreamControl(si, vsProbeControl, ctrl); err != nil {
+		return nil, err
+	}
+	data, err := cam.request(
+		requestGetCur,
+		vsProbeControl<<8,
+		uint16(si.Number),
+		cam.controlSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("uvc: GET_CUR probe on interface %d: %w", si.Number, err)
+	}
+	return ParseStreamControl(data)
+}
+
+// Commit sets ctrl, as returned by Probe, as the commit control of a
+// VideoStreaming interface. Streaming starts once an alternate setting
+// with bandwidth is selected, as StartStream does.
+func (cam *Camera) Commit(si *StreamInterface, ctrl *StreamControl) error {
+	return cam.setStreamControl(si, vsCommitControl, ctrl)
+}
+
+func (cam *Camera) setStreamControl(
+	si *StreamInterface,
+	selector uint16,
+	ctrl *StreamControl,
+) error {
+	if err := cam.checkStream(si); err != nil {
+		return err
+	}
+	if err := cam.setCur(
+		selector<<8,
+		uint16(si.Number),
+		ctrl.marshal(cam.controlSize),
+	); err != nil {
+		name := "probe"
+		if selector == vsCommitControl {
+			name = "commit"
+		}
+		return fmt.Errorf("uvc: SET_CUR %s on interface %d: %w", name, si.Number, err)
+	}
+	return nil
+}
+
+// Negotiate probes a VideoStreaming interface for a format, frame, and
+// interval and commits what the device settles on, which it returns. With
+// a zero interval the frame's default is asked for.
+func (cam *Camera) Negotiate(
+	si *StreamInterface,
+	format *Format,
+	frame *Frame,
+	interval Interval,
+) (*StreamControl, error) {
+	if format == nil || frame == nil {
+		return nil, fmt.Errorf("uvc: nil format or frame")
+	}
+	if interval == 0 {
+		interval = frame.DefaultInterval
+	}
+	ctrl, err := cam.Probe(si, &StreamControl{
+		Hint:          HintFrameInterval,
+		FormatIndex:   format.Index,
+		FrameIndex:    frame.Index,
+		FrameInterval: interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ctrl.FormatIndex != format.Index || ctrl.FrameIndex != frame.Index {
+		return nil, fmt.Errorf(
+			"uvc: device settled on format %d frame %d instead of format %d frame %d",
+			ctrl.FormatIndex,
+			ctrl.FrameIndex,
+			format.Index,
+			frame.Index,
+		)
+	}
+	if err := cam.Commit(si, ctrl); err != nil {
+		return nil, err
+	}
+	return ctrl, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStreamControl(t *testing.T) {
+	ctrl := StreamControl{
+		Hint:                   HintFrameInterval,
+		FormatIndex:            2,
+		FrameIndex:             3,
+		FrameInterval:          333333,
+		CompQuality:            5000,
+		MaxVideoFrameSize:      614400,
+		MaxPayloadTransferSize: 3072,
+		ClockFrequency:         48000000,
+		FramingInfo:            0x03,
+		PreferredVersion:       1,
+		MaxVersion:             2,
+		Usage:                  1,
+		RateControlModes:       0x0102,
+		LayoutPerStream:        0x0807060504030201,
+	}
+	testCases := []struct {
+		uvcVersion uint16
+		size       int
+	}{
+		{0x0100, 26},
+		{0x0110, 34},
+		{0x0150, 48},
+	}
+	for _, tc := range testCases {
+		size := streamControlSize(tc.uvcVersion)
+		if size != tc.size {
+			t.Errorf("UVC %#04x: control size %d, want %d", tc.uvcVersion, size, tc.size)
+		}
+		data := ctrl.marshal(size)
+		got, err := ParseStreamControl(data)
+		if err != nil {
+			t.Fatalf("UVC %#04x: ParseStreamControl: unexpected error %v", tc.uvcVersion, err)
+		}
+		want := ctrl
+		if size < streamControlSize15 {
+			want.Usage, want.RateControlModes, want.LayoutPerStream = 0, 0, 0
+		}
+		if size < streamControlSize11 {
+			want.ClockFrequency, want.FramingInfo = 0, 0
+			want.PreferredVersion, want.MaxVersion = 0, 0
+		}
+		if *got != want {
+			t.Errorf("UVC %#04x: round trip = %+v, want %+v", tc.uvcVersion, *got, want)
+		}
+	}
+	if !bytes.Equal(ctrl.marshal(26)[:8], []byte{1, 0, 2, 3, 0x15, 0x16, 0x05, 0x00}) {
+		t.Errorf("marshal = % x", ctrl.marshal(26)[:8])
+	}
+	if _, err := ParseStreamControl(make([]byte, 25)); err == nil {
+		t.Error("25-byte control: expected error, got nil")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	fh := &fakeHandle{maxPayload: 3000}
+	cam, si := openTestCamera(t, fh, false)
+	format := si.Format(1)
+	ctrl, err := cam.Negotiate(si, format, format.Frame(1), 0)
+	if err != nil {
+		t.Fatalf("Negotiate: unexpected error %v", err)
+	}
+	if len(fh.probe) != 34 {
+		t.Errorf("probe control is %d bytes, want 34 for UVC 1.1", len(fh.probe))
+	}
+	if ctrl.FormatIndex != 1 || ctrl.FrameIndex != 1 || ctrl.FrameInterval != 333333 ||
+		ctrl.MaxPayloadTransferSize != 3000 || ctrl.Hint != HintFrameInterval {
+		t.Errorf("negotiated control = %+v", ctrl)
+	}
+	if !bytes.Equal(fh.commit, ctrl.marshal(34)) {
+		t.Errorf("committed % x, want the probe reply", fh.commit)
+	}
+	if fh.lastValue != vsCommitControl<<8 || fh.lastIndex != 1 {
+		t.Errorf("commit wValue = %#x, wIndex = %#x", fh.lastValue, fh.lastIndex)
+	}
+
+	other := &StreamInterface{Number: 5}
+	if _, err := cam.Negotiate(other, format, format.Frame(1), 0); err == nil {
+		t.Error("Negotiate on a foreign interface: expected error, got nil")
+	}
+}
@@ -0,0 +1,472 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// Payload header bmHeaderInfo bits.
+const (
+	headerFrameID     = 0x01
+	headerEndOfFrame  = 0x02
+	headerPTS         = 0x04
+	headerSCR         = 0x08
+	headerStillImage  = 0x20
+	headerError       = 0x40
+	headerEndOfHeader = 0x80
+)
+
+const (
+	// packetsPerTransfer is the number of packets of each isochronous
+	// transfer: 4 ms at high speed, 32 ms at full speed.
+	packetsPerTransfer = 32
+	// transfersQueued is the number of isochronous transfers kept queued.
+	transfersQueued = 4
+	// frameQueueLength is the number of frames that wait on the Frames
+	// channel before newer frames are dropped.
+	frameQueueLength = 4
+	// descriptorTypeSSEndpointCompanion is the type of the SuperSpeed
+	// endpoint companion descriptor, which follows the endpoint descriptor.
+	descriptorTypeSSEndpointCompanion = 0x30
+)
+
+// VideoFrame is a frame reassembled from payloads.
+type VideoFrame struct {
+	Data []byte
+	// Sequence counts the frames of the stream, including those dropped.
+	Sequence uint64
+	// PTS is the presentation time stamp from the payload headers, in
+	// units of the device clock, if HasPTS is set.
+	PTS    uint32
+	HasPTS bool
+	// Still is set for a still image sent with still image method 2 or 3.
+	Still bool
+	// Corrupt is set if the device flagged an error or a packet of the
+	// frame was lost; Data is then incomplete.
+	Corrupt bool
+}
+
+// EndpointBandwidth returns the bytes an isochronous endpoint moves per
+// service interval, counting the additional transactions of high-bandwidth
+// high-speed endpoints and the SuperSpeed companion's wBytesPerInterval.
+func EndpointBandwidth(ep *libusb.EndpointDescriptor) int {
+	if descs, err := libusb.SplitDescriptors(ep.Extra); err == nil {
+		for _, desc := range descs {
+			if desc[1] == descriptorTypeSSEndpointCompanion && len(desc) >= 6 {
+				return int(binary.LittleEndian.Uint16(desc[4:6]))
+			}
+		}
+	}
+	size := int(ep.MaxPacketSize & 0x07FF)
+	return size * (1 + int(ep.MaxPacketSize>>11&0x03))
+}
+
+// SelectAltSetting returns the alternate setting of a VideoStreaming
+// interface to stream with a payload size of maxPayload. For a bulk
+// endpoint that is alternate setting 0. For an isochronous endpoint it is
+// the setting with the least bandwidth that carries maxPayload in one
+// service interval.
+func SelectAltSetting(
+	si *StreamInterface,
+	maxPayload uint32,
+) (*libusb.InterfaceDescriptor, error) {
+	var best *libusb.InterfaceDescriptor
+	bestBandwidth := 0
+	for _, alt := range si.AltSettings {
+		ep := streamEndpoint(alt, si.Streaming.EndpointAddress)
+		if ep == nil {
+			continue
+		}
+		switch ep.TransferType() {
+		case libusb.BulkTransfer:
+			if alt.AlternateSetting == 0 {
+				return alt, nil
+			}
+		case libusb.IsochronousTransfer:
+			bandwidth := EndpointBandwidth(ep)
+			if bandwidth >= int(maxPayload) && (best == nil || bandwidth < bestBandwidth) {
+				best, bestBandwidth = alt, bandwidth
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf(
+			"uvc: interface %d has no alternate setting for %d-byte payloads",
+			si.Number,
+			maxPayload,
+		)
+	}
+	return best, nil
+}
+
+func streamEndpoint(
+	alt *libusb.InterfaceDescriptor,
+	address libusb.EndpointAddress,
+) *libusb.EndpointDescriptor {
+	for _, ep := range alt.EndpointDescriptors {
+		if ep.EndpointAddress == address {
+			return ep
+		}
+	}
+	return nil
+}
+
+// Stream is a running video stream.
+type Stream struct {
+	cam              *Camera
+	Interface        *StreamInterface
+	Control          *StreamControl
+	AlternateSetting int
+	Endpoint         libusb.EndpointAddress
+
+	source  payloadSource
+	frames  chan *VideoFrame
+	stop    chan struct{}
+	done    chan struct{}
+	err     error
+	dropped atomic.Uint64
+
+	stopOnce sync.Once
+}
+
+// StartStream starts streaming from a VideoStreaming interface with a
+// control committed by Negotiate or Commit, selecting the alternate
+// setting for its MaxPayloadTransferSize.
+func (cam *Camera) StartStream(si *StreamInterface, ctrl *StreamControl) (*Stream, error) {
+	if err := cam.checkStream(si); err != nil {
+		return nil, err
+	}
+	if ctrl == nil {
+		return nil, fmt.Errorf("uvc: nil stream control")
+	}
+	alt, err := SelectAltSetting(si, ctrl.MaxPayloadTransferSize)
+	if err != nil {
+		return nil, err
+	}
+	ep := streamEndpoint(alt, si.Streaming.EndpointAddress)
+	stream := &Stream{
+		cam:              cam,
+		Interface:        si,
+		Control:          ctrl,
+		AlternateSetting: alt.AlternateSetting,
+		Endpoint:         ep.EndpointAddress,
+		frames:           make(chan *VideoFrame, frameQueueLength),
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+
+	cam.mu.Lock()
+	defer cam.mu.Unlock()
+	if _, ok := cam.streams[si.Number]; ok {
+		return nil, fmt.Errorf("uvc: interface %d is already streaming", si.Number)
+	}
+	if err := cam.handle.SetInterfaceAltSetting(si.Number, alt.AlternateSetting); err != nil {
+		return nil, fmt.Errorf(
+			"uvc: selecting alternate setting %d of interface %d: %w",
+			alt.AlternateSetting,
+			si.Number,
+			err,
+		)
+	}
+	if ep.TransferType() == libusb.BulkTransfer {
+		stream.source = &bulkSource{
+			handle:   cam.handle,
+			endpoint: ep.EndpointAddress,
+			buf:      make([]byte, max(int(ctrl.MaxPayloadTransferSize), 512)),
+			timeout:  cam.Timeout,
+		}
+	} else {
+		source, err := newIsoSource(cam.handle, ep.EndpointAddress, EndpointBandwidth(ep),
+			cam.Timeout)
+		if err != nil {
+			return nil, errors.Join(err, cam.handle.SetInterfaceAltSetting(si.Number, 0))
+		}
+		stream.source = source
+	}
+	cam.streams[si.Number] = stream
+	go stream.run(int(ctrl.MaxVideoFrameSize))
+	return stream, nil
+}
+
+// Frames returns the channel frames are delivered on. The channel is
+// closed when the stream stops, after which Err tells why. Frames that
+// arrive while the channel is full are dropped.
+func (s *Stream) Frames() <-chan *VideoFrame {
+	return s.frames
+}
+
+// Dropped returns the number of frames dropped because the Frames channel
+// was full.
+func (s *Stream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns the error that ended the stream once the Frames channel is
+// closed, or nil if Stop ended it.
+func (s *Stream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Stop stops the stream and selects alternate setting 0 again, which frees
+// the isochronous bandwidth. Calling Stop again returns os.ErrClosed.
+func (s *Stream) Stop() error {
+	err := os.ErrClosed
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		errs := []error{s.source.close()}
+		if s.AlternateSetting != 0 {
+			if err := s.cam.handle.SetInterfaceAltSetting(s.Interface.Number, 0); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"uvc: selecting alternate setting 0 of interface %d: %w",
+					s.Interface.Number,
+					err,
+				))
+			}
+		}
+		s.cam.mu.Lock()
+		delete(s.cam.streams, s.Interface.Number)
+		s.cam.mu.Unlock()
+		err = errors.Join(errs...)
+	})
+	return err
+}
+
+func (s *Stream) run(maxFrameSize int) {
+	defer close(s.done)
+	defer close(s.frames)
+	asm := &assembler{
+		fid:          -1,
+		maxFrameSize: maxFrameSize,
+		emit: func(frame *VideoFrame) {
+			select {
+			case s.frames <- frame:
+			default:
+				s.dropped.Add(1)
+			}
+		},
+	}
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		payloads, err := s.source.next()
+		if err != nil && !usbif.IsTimeout(err) {
+			s.err = fmt.Errorf("uvc: streaming from endpoint %#02x: %w", uint8(s.Endpoint), err)
+			return
+		}
+		for _, p := range payloads {
+			asm.add(p)
+		}
+	}
+}
+
+// payload is the data of one isochronous packet or bulk transfer; lost is
+// set for packets that failed.
+type payload struct {
+	data []byte
+	lost bool
+}
+
+// payloadSource reads the payloads of a stream in order.
+type payloadSource interface {
+	next() ([]payload, error)
+	close() error
+}
+
+type bulkSource struct {
+	handle   Handle
+	endpoint libusb.EndpointAddress
+	buf      []byte
+	timeout  int
+}
+
+func (bs *bulkSource) next() ([]payload, error) {
+	n, err := bs.handle.BulkTransfer(bs.endpoint, bs.buf, len(bs.buf), bs.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return []payload{{data: bs.buf[:n]}}, nil
+}
+
+func (bs *bulkSource) close() error {
+	return nil
+}
+
+// isoStreamer is the part of *libusb.DeviceHandle that queues isochronous
+// transfers ahead of time.
+type isoStreamer interface {
+	IsochronousStream(
+		endpoint libusb.EndpointAddress,
+		packetLengths []int,
+		transfers int,
+		timeout int,
+	) (*libusb.IsoStream, error)
+}
+
+// isoSource reads packets with an IsoStream when the handle has one, and
+// with one synchronous transfer at a time otherwise.
+type isoSource struct {
+	handle        Handle
+	stream        *libusb.IsoStream
+	endpoint      libusb.EndpointAddress
+	buf           []byte
+	packetLengths []int
+	timeout       int
+}
+
+func newIsoSource(
+	handle Handle,
+	endpoint libusb.EndpointAddress,
+	packetSize int,
+	timeout int,
+) (*isoSource, error) {
+	is := &isoSource{
+		handle:        handle,
+		endpoint:      endpoint,
+		buf:           make([]byte, packetsPerTransfer*packetSize),
+		packetLengths: make([]int, packetsPerTransfer),
+		timeout:       timeout,
+	}
+	for i := range is.packetLengths {
+		is.packetLengths[i] = packetSize
+	}
+	if streamer, ok := handle.(isoStreamer); ok {
+		stream, err := streamer.IsochronousStream(endpoint, is.packetLengths, transfersQueued,
+			timeout)
+		if err != nil {
+			return nil, fmt.Errorf("uvc: queuing transfers on endpoint %#02x: %w",
+				uint8(endpoint), err)
+		}
+		is.stream = stream
+	}
+	return is, nil
+}
+
+func (is *isoSource) next() ([]payload, error) {
+	var packets []libusb.IsoPacket
+	var err error
+	if is.stream != nil {
+		packets, err = is.stream.Read(is.buf)
+	} else {
+		packets, err = is.handle.IsochronousTransfer(is.endpoint, is.buf, is.packetLengths,
+			is.timeout)
+	}
+	payloads := make([]payload, 0, len(packets))
+	for _, packet := range packets {
+		if packet.Err != nil {
+			payloads = append(payloads, payload{lost: true})
+			continue
+		}
+		if packet.ActualLength == 0 {
+			continue
+		}
+		payloads = append(payloads, payload{
+			data: is.buf[packet.Offset : packet.Offset+packet.ActualLength],
+		})
+	}
+	return payloads, err
+}
+
+func (is *isoSource) close() error {
+	if is.stream == nil {
+		return nil
+	}
+	return is.stream.Close()
+}
+
+// assembler reassembles frames from payloads. A frame ends at a payload
+// with the end of frame bit or, for devices that don't set it, when the
+// frame ID bit toggles. Data before the first frame boundary belongs to a
+// frame whose start was missed and is discarded.
+type assembler struct {
+	fid          int
+	synced       bool
+	buf          []byte
+	corrupt      bool
+	pts          uint32
+	hasPTS       bool
+	still        bool
+	sequence     uint64
+	maxFrameSize int
+	emit         func(*VideoFrame)
+}
+
+func (a *assembler) add(p payload) {
+	if p.lost {
+		a.corrupt = true
+		return
+	}
+	if len(p.data) < 2 || int(p.data[0]) < 2 || int(p.data[0]) > len(p.data) {
+		a.corrupt = true
+		return
+	}
+	headerLength, info := int(p.data[0]), p.data[1]
+	fid := int(info & headerFrameID)
+	if a.fid >= 0 && fid != a.fid {
+		a.finish()
+	}
+	a.fid = fid
+	if info&headerPTS != 0 && headerLength >= 6 {
+		a.pts = binary.LittleEndian.Uint32(p.data[2:6])
+		a.hasPTS = true
+	}
+	if info&headerError != 0 {
+		a.corrupt = true
+	}
+	if info&headerStillImage != 0 {
+		a.still = true
+	}
+	if a.buf == nil && a.maxFrameSize > 0 {
+		a.buf = make([]byte, 0, a.maxFrameSize)
+	}
+	a.buf = append(a.buf, p.data[headerLength:]...)
+	if a.maxFrameSize > 0 && len(a.buf) > a.maxFrameSize {
+		a.corrupt = true
+	}
+	if info&headerEndOfFrame != 0 {
+		a.finish()
+	}
+}
+
+// finish ends the current frame and emits it.
+func (a *assembler) finish() {
+	synced := a.synced
+	a.synced = true
+	if len(a.buf) == 0 {
+		// Nothing to emit; a lost packet counts against the next frame.
+		return
+	}
+	frame := &VideoFrame{
+		Data:     a.buf,
+		Sequence: a.sequence,
+		PTS:      a.pts,
+		HasPTS:   a.hasPTS,
+		Still:    a.still,
+		Corrupt:  a.corrupt,
+	}
+	a.buf, a.corrupt, a.pts, a.hasPTS, a.still = nil, false, 0, false, false
+	if synced {
+		a.sequence++
+		a.emit(frame)
+	}
+}
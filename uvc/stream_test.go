@@ -0,0 +1,210 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+)
+
+func TestEndpointBandwidth(t *testing.T) {
+	testCases := []struct {
+		maxPacketSize uint16
+		extra         []byte
+		expected      int
+	}{
+		{1023, nil, 1023},
+		{0x1400, nil, 3072},
+		{0x0800 | 944, nil, 2 * 944},
+		{1024, []byte{6, 0x30, 15, 0, 0x00, 0x60}, 0x6000},
+	}
+	for _, tc := range testCases {
+		ep := &libusb.EndpointDescriptor{MaxPacketSize: tc.maxPacketSize, Extra: tc.extra}
+		if got := EndpointBandwidth(ep); got != tc.expected {
+			t.Errorf("wMaxPacketSize %#04x: bandwidth %d, want %d",
+				tc.maxPacketSize, got, tc.expected)
+		}
+	}
+}
+
+func TestSelectAltSetting(t *testing.T) {
+	fns, err := FindFunctions(testConfig(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := fns[0].Streams[0]
+	testCases := []struct {
+		maxPayload uint32
+		expected   int
+	}{
+		{100, 1},
+		{128, 1},
+		{129, 2},
+		{3000, 3},
+		{4000, -1},
+	}
+	for _, tc := range testCases {
+		alt, err := SelectAltSetting(si, tc.maxPayload)
+		got := -1
+		if err == nil {
+			got = alt.AlternateSetting
+		}
+		if got != tc.expected {
+			t.Errorf("%d-byte payloads: alternate setting %d, want %d",
+				tc.maxPayload, got, tc.expected)
+		}
+	}
+
+	fns, err = FindFunctions(testConfig(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alt, err := SelectAltSetting(fns[0].Streams[0], 1<<20)
+	if err != nil || alt.AlternateSetting != 0 {
+		t.Errorf("bulk endpoint: got %v, %v; want alternate setting 0", alt, err)
+	}
+}
+
+// header returns a payload header with bmHeaderInfo info, carrying a PTS
+// if pts is nonzero, followed by data.
+func header(info byte, pts uint32, data string) []byte {
+	if pts != 0 {
+		return append(append([]byte{6, info | headerPTS | headerEndOfHeader}, le32(pts)...),
+			data...)
+	}
+	return append([]byte{2, info | headerEndOfHeader}, data...)
+}
+
+func TestAssembler(t *testing.T) {
+	var frames []*VideoFrame
+	asm := &assembler{
+		fid:  -1,
+		emit: func(frame *VideoFrame) { frames = append(frames, frame) },
+	}
+	payloads := []payload{
+		// The tail of a frame whose start was missed.
+		{data: header(0, 0, "lost")},
+		// A frame ended by the end of frame bit.
+		{data: header(1, 100, "ab")},
+		{data: header(1, 100, "cd")},
+		{data: header(1|headerEndOfFrame, 100, "e")},
+		// A trailing header without data, as some cameras send.
+		{data: header(1, 0, "")},
+		// A frame ended by the frame ID toggling, with a lost packet.
+		{data: header(0, 0, "fg")},
+		{lost: true},
+		{data: header(0, 0, "h")},
+		// A still image flagged with an error.
+		{data: header(1|headerStillImage|headerError, 0, "i")},
+		{data: header(1|headerEndOfFrame, 0, "j")},
+		// A malformed payload.
+		{data: []byte{9, 0}},
+		{data: header(0|headerEndOfFrame, 0, "k")},
+	}
+	for _, p := range payloads {
+		asm.add(p)
+	}
+	want := []VideoFrame{
+		{Data: []byte("abcde"), Sequence: 0, PTS: 100, HasPTS: true},
+		{Data: []byte("fgh"), Sequence: 1, Corrupt: true},
+		{Data: []byte("ij"), Sequence: 2, Still: true, Corrupt: true},
+		{Data: []byte("k"), Sequence: 3, Corrupt: true},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		w := want[i]
+		if string(frame.Data) != string(w.Data) || frame.Sequence != w.Sequence ||
+			frame.PTS != w.PTS || frame.HasPTS != w.HasPTS || frame.Still != w.Still ||
+			frame.Corrupt != w.Corrupt {
+			t.Errorf("frame %d = %+v, want %+v", i, *frame, w)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bulk  bool
+		alt   int
+		calls []string
+	}{
+		{"isochronous", false, 3, []string{"alt 1 3", "alt 1 0"}},
+		{"bulk", true, 0, []string{"alt 1 0"}},
+	}
+	for _, tc := range testCases {
+		fh := &fakeHandle{
+			maxPayload: 3000,
+			payloads: [][]byte{
+				header(1|headerEndOfFrame, 0, "partial"),
+				header(0, 0, "hello, "),
+				{},
+				header(0|headerEndOfFrame, 0, "world"),
+				header(1, 0, "second "),
+				header(1|headerEndOfFrame, 0, "frame"),
+			},
+		}
+		if tc.bulk {
+			// Lost packets only happen on isochronous endpoints.
+			fh.payloads = slices.Delete(fh.payloads, 2, 3)
+		}
+		cam, si := openTestCamera(t, fh, tc.bulk)
+		format := si.Format(1)
+		ctrl, err := cam.Negotiate(si, format, format.Frame(1), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fh.Reset()
+		stream, err := cam.StartStream(si, ctrl)
+		if err != nil {
+			t.Fatalf("%s: StartStream: unexpected error %v", tc.name, err)
+		}
+		if stream.AlternateSetting != tc.alt || stream.Endpoint != 0x81 {
+			t.Errorf("%s: stream = alternate setting %d, endpoint %#02x",
+				tc.name, stream.AlternateSetting, stream.Endpoint)
+		}
+		if _, err := cam.StartStream(si, ctrl); err == nil {
+			t.Errorf("%s: second StartStream: expected error, got nil", tc.name)
+		}
+		var got []string
+		for len(got) < 2 {
+			select {
+			case frame := <-stream.Frames():
+				got = append(got, string(frame.Data))
+				if frame.Corrupt != (!tc.bulk && len(got) == 1) {
+					t.Errorf("%s: frame %d Corrupt = %t", tc.name, len(got), frame.Corrupt)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: timed out waiting for frames, got %q", tc.name, got)
+			}
+		}
+		if !slices.Equal(got, []string{"hello, world", "second frame"}) {
+			t.Errorf("%s: frames = %q", tc.name, got)
+		}
+		if err := cam.Close(); err != nil {
+			t.Fatalf("%s: Close: unexpected error %v", tc.name, err)
+		}
+		if _, ok := <-stream.Frames(); ok {
+			t.Errorf("%s: Frames channel still open after Close", tc.name)
+		}
+		if err := stream.Err(); err != nil {
+			t.Errorf("%s: Err() = %v, want nil", tc.name, err)
+		}
+		if err := stream.Stop(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s: Stop after Close: got %v, want os.ErrClosed", tc.name, err)
+		}
+		want := append(tc.calls, "release 1", "release 0")
+		if calls := fh.Calls(); !slices.Equal(calls, want) {
+			t.Errorf("%s: calls = %q, want %q", tc.name, calls, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+/*
+Package uvc implements the USB Video Class on top of libusb.
+
+A video function is a VideoControl interface, whose class-specific
+descriptors describe the camera's terminals and units, and one or more
+VideoStreaming interfaces, whose descriptors list the formats and frame
+sizes the camera streams. FindFunctions parses both, and Open claims the
+interfaces, detaching the kernel's uvcvideo driver if it is bound.
+
+Streaming starts with the probe and commit negotiation, which Negotiate
+does for a format, frame, and interval. StartStream then selects the
+alternate setting with the bandwidth the device asked for, or keeps
+alternate setting 0 for bulk endpoints, and reassembles the payloads into
+frames that are delivered on a channel.
+*/
+package uvc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif"
+)
+
+// DefaultTimeout is the Camera.Timeout that Open sets, in milliseconds, for
+// the probe and commit requests and for each transfer of a stream.
+const DefaultTimeout = 1000
+
+// Interface subclasses of the video class.
+const (
+	SubclassVideoControl   = 0x01
+	SubclassVideoStreaming = 0x02
+)
+
+// Video class-specific requests.
+const (
+	requestSetCur = 0x01
+	requestGetCur = 0x81
+)
+
+// VideoStreaming interface control selectors.
+const (
+	vsProbeControl  = 0x01
+	vsCommitControl = 0x02
+)
+
+// Handle is what a Camera needs of a *libusb.DeviceHandle. A handle
+// that also has IsochronousStream, as *libusb.DeviceHandle does, streams
+// through it so that no packets are lost between transfers.
+type Handle interface {
+	usbif.Claimer
+	usbif.BulkTransferer
+	SetInterfaceAltSetting(interfaceNum int, alternateSetting int) error
+	ControlIn(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		maxReceiveLength int,
+		timeout int,
+	) (int, error)
+	ControlOut(
+		reqType libusb.RequestType,
+		recipient libusb.RequestRecipient,
+		request byte,
+		value uint16,
+		index uint16,
+		data []byte,
+		timeout int,
+	) (int, error)
+	IsochronousTransfer(
+		endpoint libusb.EndpointAddress,
+		data []byte,
+		packetLengths []int,
+		timeout int,
+	) ([]libusb.IsoPacket, error)
+}
+
+// Function is a video function: its VideoControl interface and the
+// VideoStreaming interfaces the VideoControl header lists.
+type Function struct {
+	Control      *libusb.InterfaceDescriptor
+	VideoControl *VideoControl
+	Streams      []*StreamInterface
+}
+
+// StreamInterface is a VideoStreaming interface with its descriptors,
+// which are parsed from alternate setting 0, and all of its alternate
+// settings, which differ in the bandwidth of their isochronous endpoint.
+type StreamInterface struct {
+	Number      int
+	Streaming   *Streaming
+	AltSettings libusb.InterfaceDescriptors
+}
+
+// Format returns the format with the given index, or nil.
+func (si *StreamInterface) Format(index uint8) *Format {
+	for _, format := range si.Streaming.Formats {
+		if format.Index == index {
+			return format
+		}
+	}
+	return nil
+}
+
+// FindFunctions returns the video functions of a configuration. A
+// function whose descriptors don't parse is an error, since a camera with
+// broken descriptors can't be streamed from.
+func FindFunctions(config *libusb.ConfigDescriptor) ([]*Function, error) {
+	if config == nil {
+		return nil, nil
+	}
+	var fns []*Function
+	for _, supported := range config.SupportedInterfaces {
+		control := altSetting(supported.InterfaceDescriptors, 0)
+		if control == nil || control.InterfaceClass != libusb.InterfaceClassVideo ||
+			control.InterfaceSubClass != SubclassVideoControl {
+			continue
+		}
+		vc, err := ParseVideoControl(control.Extra)
+		if err != nil {
+			return nil, err
+		}
+		fn := &Function{Control: control, VideoControl: vc}
+		for _, num := range vc.StreamingInterfaces {
+			si, err := findStreamInterface(config, num)
+			if err != nil {
+				return nil, err
+			}
+			fn.Streams = append(fn.Streams, si)
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+func findStreamInterface(config *libusb.ConfigDescriptor, num int) (*StreamInterface, error) {
+	for _, supported := range config.SupportedInterfaces {
+		alt0 := altSetting(supported.InterfaceDescriptors, 0)
+		if alt0 == nil || alt0.InterfaceNumber != num {
+			continue
+		}
+		if alt0.InterfaceClass != libusb.InterfaceClassVideo ||
+			alt0.InterfaceSubClass != SubclassVideoStreaming {
+			return nil, fmt.Errorf("uvc: interface %d is not a VideoStreaming interface", num)
+		}
+		vs, err := ParseStreaming(alt0.Extra)
+		if err != nil {
+			return nil, err
+		}
+		return &StreamInterface{
+			Number:      num,
+			Streaming:   vs,
+			AltSettings: supported.InterfaceDescriptors,
+		}, nil
+	}
+	return nil, fmt.Errorf("uvc: VideoStreaming interface %d not in the configuration", num)
+}
+
+func altSetting(
+	ifaces libusb.InterfaceDescriptors,
+	alt int,
+) *libusb.InterfaceDescriptor {
+	for _, iface := range ifaces {
+		if iface.AlternateSetting == alt {
+			return iface
+		}
+	}
+	return nil
+}
+
+// Camera is a claimed video function.
+type Camera struct {
+	handle   Handle
+	Function *Function
+	// Timeout is the timeout in milliseconds of class requests and of each
+	// streaming transfer.
+	Timeout int
+
+	controlSize int
+
+	mu      sync.Mutex
+	closed  bool
+	claims  *usbif.Claims
+	streams map[int]*Stream
+}
+
+// Open claims the VideoControl and VideoStreaming interfaces of a video
+// function, detaching the kernel driver from any that have it bound.
+func Open(handle Handle, fn *Function) (*Camera, error) {
+	if handle == nil || fn == nil || fn.Control == nil || fn.VideoControl == nil {
+		return nil, fmt.Errorf("uvc: nil handle or function")
+	}
+	cam := &Camera{
+		handle:      handle,
+		Function:    fn,
+		Timeout:     DefaultTimeout,
+		controlSize: streamControlSize(fn.VideoControl.UVCVersion),
+		streams:     make(map[int]*Stream),
+		claims:      usbif.NewClaims(handle, "uvc"),
+	}
+	nums := []int{fn.Control.InterfaceNumber}
+	for _, si := range fn.Streams {
+		nums = append(nums, si.Number)
+	}
+	for _, num := range nums {
+		if err := cam.claims.Claim(num); err != nil {
+			return nil, errors.Join(err, cam.claims.Release())
+		}
+	}
+	return cam, nil
+}
+
+// Close stops any streams and hands the interfaces back to uvcvideo where
+// Open took them from it. Calling Close again returns os.ErrClosed.
+func (cam *Camera) Close() error {
+	cam.mu.Lock()
+	if cam.closed {
+		cam.mu.Unlock()
+		return os.ErrClosed
+	}
+	cam.closed = true
+	streams := make([]*Stream, 0, len(cam.streams))
+	for _, stream := range cam.streams {
+		streams = append(streams, stream)
+	}
+	cam.mu.Unlock()
+	var errs []error
+	for _, stream := range streams {
+		if err := stream.Stop(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(append(errs, cam.claims.Release())...)
+}
+
+// checkStream checks that the camera is open and si is one of its
+// VideoStreaming interfaces.
+func (cam *Camera) checkStream(si *StreamInterface) error {
+	cam.mu.Lock()
+	defer cam.mu.Unlock()
+	if cam.closed {
+		return os.ErrClosed
+	}
+	if si == nil || !slices.Contains(cam.Function.Streams, si) {
+		return fmt.Errorf("uvc: stream interface is not part of the camera's function")
+	}
+	return nil
+}
+
+// request performs a GET request to an interface and returns the reply.
+func (cam *Camera) request(request byte, value, index uint16, length int) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := cam.handle.ControlIn(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		request,
+		value,
+		index,
+		data,
+		length,
+		cam.Timeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (cam *Camera) setCur(value, index uint16, data []byte) error {
+	_, err := cam.handle.ControlOut(
+		libusb.Class,
+		libusb.InterfaceRecipient,
+		requestSetCur,
+		value,
+		index,
+		data,
+		cam.Timeout,
+	)
+	return err
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package uvc
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotmc/libusb/v2"
+	"github.com/gotmc/libusb/v2/internal/usbif/usbiftest"
+)
+
+type fakeHandle struct {
+	usbiftest.Claimer
+	mu sync.Mutex
+	// probe is the last probe control set; GET_CUR returns it with
+	// maxPayload filled in.
+	probe      []byte
+	commit     []byte
+	maxPayload uint32
+	lastValue  uint16
+	lastIndex  uint16
+	// payloads are returned one per isochronous packet or bulk transfer.
+	payloads [][]byte
+}
+
+func (fh *fakeHandle) SetInterfaceAltSetting(iface int, alt int) error {
+	fh.Record("alt %d %d", iface, alt)
+	return nil
+}
+
+func (fh *fakeHandle) ControlIn(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	maxReceiveLength int,
+	timeout int,
+) (int, error) {
+	fh.lastValue, fh.lastIndex = value, index
+	if request != requestGetCur || value != vsProbeControl<<8 || fh.probe == nil {
+		return 0, libusb.ErrPipe
+	}
+	reply := slices.Clone(fh.probe)
+	binary.LittleEndian.PutUint32(reply[18:22], 640*480*2)
+	binary.LittleEndian.PutUint32(reply[22:26], fh.maxPayload)
+	return copy(data[:maxReceiveLength], reply), nil
+}
+
+func (fh *fakeHandle) ControlOut(
+	reqType libusb.RequestType,
+	recipient libusb.RequestRecipient,
+	request byte,
+	value uint16,
+	index uint16,
+	data []byte,
+	timeout int,
+) (int, error) {
+	fh.lastValue, fh.lastIndex = value, index
+	if request != requestSetCur {
+		return 0, libusb.ErrPipe
+	}
+	switch value >> 8 {
+	case vsProbeControl:
+		fh.probe = slices.Clone(data)
+	case vsCommitControl:
+		fh.commit = slices.Clone(data)
+	default:
+		return 0, libusb.ErrPipe
+	}
+	return len(data), nil
+}
+
+// nextPayload returns the next queued payload, or nil once they run out.
+func (fh *fakeHandle) nextPayload() []byte {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if len(fh.payloads) == 0 {
+		return nil
+	}
+	p := fh.payloads[0]
+	fh.payloads = fh.payloads[1:]
+	return p
+}
+
+func (fh *fakeHandle) BulkTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	length int,
+	timeout int,
+) (int, error) {
+	p := fh.nextPayload()
+	if p == nil {
+		time.Sleep(time.Millisecond)
+		return 0, libusb.ErrTimeout
+	}
+	return copy(data[:length], p), nil
+}
+
+func (fh *fakeHandle) IsochronousTransfer(
+	endpoint libusb.EndpointAddress,
+	data []byte,
+	packetLengths []int,
+	timeout int,
+) ([]libusb.IsoPacket, error) {
+	packets := make([]libusb.IsoPacket, len(packetLengths))
+	offset := 0
+	for i, length := range packetLengths {
+		packets[i] = libusb.IsoPacket{Offset: offset, Length: length}
+		if p := fh.nextPayload(); p != nil {
+			if len(p) == 0 {
+				packets[i].Err = libusb.ErrIO
+			}
+			packets[i].ActualLength = copy(data[offset:offset+length], p)
+		}
+		offset += length
+	}
+	time.Sleep(time.Millisecond)
+	return packets, nil
+}
+
+// testConfig returns the configuration of a UVC 1.1 camera with
+// VideoControl interface 0 and VideoStreaming interface 1, whose
+// endpoint 0x81 is isochronous or bulk.
+func testConfig(bulk bool) *libusb.ConfigDescriptor {
+	control := &libusb.InterfaceDescriptor{
+		InterfaceNumber:   0,
+		InterfaceClass:    libusb.InterfaceClassVideo,
+		InterfaceSubClass: SubclassVideoControl,
+		Extra:             testVideoControl(),
+	}
+	streaming := libusb.InterfaceDescriptors{{
+		InterfaceNumber:   1,
+		InterfaceClass:    libusb.InterfaceClassVideo,
+		InterfaceSubClass: SubclassVideoStreaming,
+		Extra:             testStreaming(),
+	}}
+	if bulk {
+		streaming[0].EndpointDescriptors = libusb.EndpointDescriptors{
+			{EndpointAddress: 0x81, Attributes: 0x02, MaxPacketSize: 512},
+		}
+	} else {
+		for alt, mps := range []uint16{128, 512, 0x1400} {
+			streaming = append(streaming, &libusb.InterfaceDescriptor{
+				InterfaceNumber:   1,
+				AlternateSetting:  alt + 1,
+				InterfaceClass:    libusb.InterfaceClassVideo,
+				InterfaceSubClass: SubclassVideoStreaming,
+				EndpointDescriptors: libusb.EndpointDescriptors{
+					{EndpointAddress: 0x81, Attributes: 0x05, MaxPacketSize: mps},
+				},
+			})
+		}
+	}
+	return &libusb.ConfigDescriptor{
+		SupportedInterfaces: libusb.SupportedInterfaces{
+			{InterfaceDescriptors: libusb.InterfaceDescriptors{control}},
+			{InterfaceDescriptors: streaming},
+		},
+	}
+}
+
+func openTestCamera(t *testing.T, fh *fakeHandle, bulk bool) (*Camera, *StreamInterface) {
+	t.Helper()
+	fns, err := FindFunctions(testConfig(bulk))
+	if err != nil || len(fns) != 1 {
+		t.Fatalf("FindFunctions = %d functions, %v", len(fns), err)
+	}
+	cam, err := Open(fh, fns[0])
+	if err != nil {
+		t.Fatalf("Open: unexpected error %v", err)
+	}
+	return cam, fns[0].Streams[0]
+}
+
+func TestFindFunctions(t *testing.T) {
+	fns, err := FindFunctions(testConfig(false))
+	if err != nil {
+		t.Fatalf("FindFunctions: unexpected error %v", err)
+	}
+	if len(fns) != 1 || len(fns[0].Streams) != 1 {
+		t.Fatalf("FindFunctions = %+v", fns)
+	}
+	si := fns[0].Streams[0]
+	if si.Number != 1 || len(si.AltSettings) != 4 || len(si.Streaming.Formats) != 2 {
+		t.Errorf("stream interface = %+v", si)
+	}
+	if si.Format(2) == nil || si.Format(3) != nil {
+		t.Error("Format looked up the wrong formats")
+	}
+
+	config := testConfig(false)
+	config.SupportedInterfaces = config.SupportedInterfaces[:1]
+	if _, err := FindFunctions(config); err == nil {
+		t.Error("missing VideoStreaming interface: expected error, got nil")
+	}
+}
+
+func TestOpenAndClose(t *testing.T) {
+	fh := &fakeHandle{Claimer: usbiftest.Claimer{Active: true}}
+	cam, _ := openTestCamera(t, fh, false)
+	if err := cam.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %v", err)
+	}
+	want := []string{
+		"detach 0", "claim 0", "detach 1", "claim 1",
+		"release 1", "release 0", "attach 1", "attach 0",
+	}
+	if calls := fh.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if err := cam.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close: got %v, want os.ErrClosed", err)
+	}
+	_, err := cam.Probe(cam.Function.Streams[0], &StreamControl{})
+	if !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Probe after Close: got %v, want os.ErrClosed", err)
+	}
+}
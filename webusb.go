@@ -0,0 +1,166 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	webUSBCapabilitySize   = 4
+	webUSBRequestGetURL    = 0x0002
+	webUSBDescriptorURL    = 0x03
+	webUSBURLHeaderSize    = 3
+	maxWebUSBURLDescriptor = 255
+)
+
+// WebUSBPlatformUUID identifies the BOS platform capability that describes a
+// device's WebUSB support, {3408B638-09A9-47A0-8BFD-A0768815B665}.
+var WebUSBPlatformUUID = PlatformUUID{
+	0x38, 0xB6, 0x08, 0x34, 0xA9, 0x09, 0xA0, 0x47,
+	0x8B, 0xFD, 0xA0, 0x76, 0x88, 0x15, 0xB6, 0x65,
+}
+
+// URLScheme is the bScheme of a WebUSB URL descriptor.
+type URLScheme byte
+
+// WebUSB URL schemes.
+const (
+	URLSchemeHTTP  URLScheme = 0
+	URLSchemeHTTPS URLScheme = 1
+	URLSchemeNone  URLScheme = 255
+)
+
+var urlSchemes = map[URLScheme]string{
+	URLSchemeHTTP:  "http://",
+	URLSchemeHTTPS: "https://",
+	URLSchemeNone:  "",
+}
+
+// String implements the Stringer interface for URLScheme, returning the
+// prefix the scheme stands for.
+func (scheme URLScheme) String() string {
+	return urlSchemes[scheme]
+}
+
+// WebUSBCapability models the CapabilityData of the WebUSB platform
+// capability. A LandingPage of zero means the device has no landing page.
+type WebUSBCapability struct {
+	Version     bcd
+	VendorCode  uint8
+	LandingPage uint8
+}
+
+// WebUSBURLDescriptor models a WebUSB URL descriptor.
+type WebUSBURLDescriptor struct {
+	Scheme URLScheme
+	URL    string
+}
+
+// String implements the Stringer interface for WebUSBURLDescriptor, returning
+// the complete URL.
+func (desc WebUSBURLDescriptor) String() string {
+	return desc.Scheme.String() + desc.URL
+}
+
+// WebUSB decodes the platform capability as a WebUSB capability. The second
+// return value is false if the UUID isn't WebUSBPlatformUUID or the
+// capability data is too short.
+func (platform *PlatformCapability) WebUSB() (*WebUSBCapability, bool) {
+	if platform.UUID != WebUSBPlatformUUID || len(platform.Data) < webUSBCapabilitySize {
+		return nil, false
+	}
+	return &WebUSBCapability{
+		Version:     bcd(binary.LittleEndian.Uint16(platform.Data[0:2])),
+		VendorCode:  platform.Data[2],
+		LandingPage: platform.Data[3],
+	}, true
+}
+
+// WebUSBCapability reads the BOS descriptor and returns its WebUSB platform
+// capability. It returns a wrapped errorNotFound if the device doesn't
+// advertise WebUSB.
+func (dh *DeviceHandle) WebUSBCapability() (*WebUSBCapability, error) {
+	bos, err := dh.BOSDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	for _, platform := range bos.PlatformCapabilities(WebUSBPlatformUUID) {
+		if webUSB, ok := platform.WebUSB(); ok {
+			return webUSB, nil
+		}
+	}
+	return nil, fmt.Errorf("no WebUSB platform capability: %w", ErrorCode(errorNotFound))
+}
+
+// WebUSBURL returns the landing page URL advertised by the device's WebUSB
+// platform capability. It returns a wrapped errorNotFound if the device
+// doesn't advertise WebUSB or has no landing page.
+func (dh *DeviceHandle) WebUSBURL() (string, error) {
+	webUSB, err := dh.WebUSBCapability()
+	if err != nil {
+		return "", err
+	}
+	if webUSB.LandingPage == 0 {
+		return "", fmt.Errorf("no WebUSB landing page: %w", ErrorCode(errorNotFound))
+	}
+	desc, err := dh.WebUSBURLDescriptor(webUSB.VendorCode, webUSB.LandingPage)
+	if err != nil {
+		return "", err
+	}
+	return desc.String(), nil
+}
+
+// WebUSBURLDescriptor issues the WebUSB GET_URL vendor request for the URL
+// descriptor at the given index, using the vendor code from the
+// WebUSBCapability.
+func (dh *DeviceHandle) WebUSBURLDescriptor(
+	vendorCode uint8,
+	urlIndex uint8,
+) (*WebUSBURLDescriptor, error) {
+	if dh == nil || dh.transferer == nil {
+		return nil, ErrorCode(errorInvalidParam)
+	}
+	data, err := dh.vendorIn(
+		DeviceRecipient,
+		vendorCode,
+		uint16(urlIndex),
+		webUSBRequestGetURL,
+		maxWebUSBURLDescriptor,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseWebUSBURLDescriptor(data)
+}
+
+// parseWebUSBURLDescriptor converts a raw WebUSB URL descriptor into a
+// WebUSBURLDescriptor. The URL is UTF-8 encoded.
+func parseWebUSBURLDescriptor(data []byte) (*WebUSBURLDescriptor, error) {
+	if len(data) < webUSBURLHeaderSize {
+		return nil, fmt.Errorf(
+			"WebUSB URL descriptor is %d bytes; want at least %d",
+			len(data),
+			webUSBURLHeaderSize,
+		)
+	}
+	if data[1] != webUSBDescriptorURL {
+		return nil, fmt.Errorf("descriptor type %#02x is not a WebUSB URL", data[1])
+	}
+	length := int(data[0])
+	if length < webUSBURLHeaderSize || length > len(data) {
+		return nil, fmt.Errorf("WebUSB URL descriptor has invalid bLength %d", length)
+	}
+	scheme := URLScheme(data[2])
+	if _, ok := urlSchemes[scheme]; !ok {
+		return nil, fmt.Errorf("unknown WebUSB URL scheme %d", scheme)
+	}
+	return &WebUSBURLDescriptor{
+		Scheme: scheme,
+		URL:    string(data[webUSBURLHeaderSize:length]),
+	}, nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2015-2025 The libusb developers. All rights reserved.
+// Project site: https://github.com/gotmc/libusb
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE.txt file for the project.
+
+package libusb
+
+import (
+	"errors"
+	"testing"
+)
+
+func webUSBURLBytes(scheme URLScheme, url string) []byte {
+	data := []byte{byte(webUSBURLHeaderSize + len(url)), webUSBDescriptorURL, byte(scheme)}
+	return append(data, url...)
+}
+
+// fakeWebUSBDevice answers BOS requests with a WebUSB platform capability
+// and GET_URL requests with urls.
+func fakeWebUSBDevice(
+	vendorCode, landingPage uint8,
+	urls map[uint16][]byte,
+) func(setup SetupPacket, data []byte) (int, error) {
+	bosDevice := fakeBOSDevice(buildBOS(
+		usb2Extension,
+		buildPlatformCapability(WebUSBPlatformUUID, []byte{0x00, 0x01, vendorCode, landingPage}),
+	))
+	return func(setup SetupPacket, data []byte) (int, error) {
+		if setup.Type() != Vendor {
+			return bosDevice(setup, data)
+		}
+		url, ok := urls[setup.Value]
+		if setup.Request != vendorCode || setup.Index != webUSBRequestGetURL || !ok {
+			return 0, ErrorCode(errorPipe)
+		}
+		return copy(data, url), nil
+	}
+}
+
+func TestPlatformCapabilityWebUSB(t *testing.T) {
+	platform := &PlatformCapability{
+		UUID: WebUSBPlatformUUID,
+		Data: []byte{0x00, 0x01, 0x07, 0x01},
+	}
+	webUSB, ok := platform.WebUSB()
+	if !ok {
+		t.Fatal("WebUSB: got !ok for a WebUSB capability")
+	}
+	want := WebUSBCapability{Version: 0x0100, VendorCode: 0x07, LandingPage: 1}
+	if *webUSB != want {
+		t.Errorf("WebUSB = %+v, want %+v", *webUSB, want)
+	}
+	if _, ok := (&PlatformCapability{UUID: WebUSBPlatformUUID, Data: []byte{0, 1}}).WebUSB(); ok {
+		t.Error("WebUSB: got ok for short capability data")
+	}
+	if _, ok := (&PlatformCapability{UUID: MSOS20PlatformUUID, Data: platform.Data}).WebUSB(); ok {
+		t.Error("WebUSB: got ok for the Microsoft OS 2.0 UUID")
+	}
+}
+
+func TestParseWebUSBURLDescriptor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+		wantErr  bool
+	}{
+		{"https", webUSBURLBytes(URLSchemeHTTPS, "example.com/app"), "https://example.com/app", false},
+		{"http", webUSBURLBytes(URLSchemeHTTP, "localhost:8000"), "http://localhost:8000", false},
+		{"none", webUSBURLBytes(URLSchemeNone, "urn:example"), "urn:example", false},
+		{"utf-8", webUSBURLBytes(URLSchemeHTTPS, "例え.jp"), "https://例え.jp", false},
+		{"short", []byte{0x02, webUSBDescriptorURL}, "", true},
+		{"wrong type", []byte{0x04, 0x02, 0x01, 'a'}, "", true},
+		{"bLength overruns", []byte{0x10, webUSBDescriptorURL, 0x01, 'a'}, "", true},
+		{"unknown scheme", webUSBURLBytes(URLScheme(2), "example.com"), "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			desc, err := parseWebUSBURLDescriptor(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseWebUSBURLDescriptor error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && desc.String() != tc.expected {
+				t.Errorf("URL = %q, want %q", desc.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestWebUSBURL(t *testing.T) {
+	urls := map[uint16][]byte{1: webUSBURLBytes(URLSchemeHTTPS, "example.com")}
+	ft := &fakeTransferer{controlFunc: fakeWebUSBDevice(0x07, 1, urls)}
+	url, err := newFakeDeviceHandle(ft).WebUSBURL()
+	if err != nil {
+		t.Fatalf("WebUSBURL: unexpected error %v", err)
+	}
+	if url != "https://example.com" {
+		t.Errorf("WebUSBURL = %q, want https://example.com", url)
+	}
+	want := SetupPacket{0xC0, 0x07, 1, webUSBRequestGetURL, maxWebUSBURLDescriptor}
+	if got := ft.transfers[len(ft.transfers)-1].setup; got != want {
+		t.Errorf("setup = %v, want %v", got, want)
+	}
+}
+
+func TestWebUSBURLNotFound(t *testing.T) {
+	testCases := []struct {
+		name string
+		ft   *fakeTransferer
+	}{
+		{"no landing page", &fakeTransferer{controlFunc: fakeWebUSBDevice(0x07, 0, nil)}},
+		{"no capability", &fakeTransferer{controlFunc: fakeBOSDevice(buildBOS(usb2Extension))}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newFakeDeviceHandle(tc.ft).WebUSBURL()
+			if !errors.Is(err, ErrorCode(errorNotFound)) {
+				t.Errorf("WebUSBURL: got %v, want errorNotFound", err)
+			}
+		})
+	}
+}
+
+func TestURLSchemeString(t *testing.T) {
+	testCases := []struct {
+		scheme   URLScheme
+		expected string
+	}{
+		{URLSchemeHTTP, "http://"},
+		{URLSchemeHTTPS, "https://"},
+		{URLSchemeNone, ""},
+	}
+	for _, tc := range testCases {
+		if got := tc.scheme.String(); got != tc.expected {
+			t.Errorf("URLScheme(%d).String() = %q, want %q", tc.scheme, got, tc.expected)
+		}
+	}
+}
+
+func TestWebUSBNilHandle(t *testing.T) {
+	var dh *DeviceHandle
+	if _, err := dh.WebUSBCapability(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("WebUSBCapability: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.WebUSBURL(); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("WebUSBURL: got %v, want errorInvalidParam", err)
+	}
+	if _, err := dh.WebUSBURLDescriptor(0x07, 1); err != ErrorCode(errorInvalidParam) {
+		t.Errorf("WebUSBURLDescriptor: got %v, want errorInvalidParam", err)
+	}
+}